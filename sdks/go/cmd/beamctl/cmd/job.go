@@ -0,0 +1,256 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/metricsx"
+	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobCmd = &cobra.Command{
+		Use:   "job",
+		Short: "Job management commands",
+	}
+
+	jobListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List jobs",
+		RunE:  jobListFn,
+		Args:  cobra.NoArgs,
+	}
+
+	jobStateCmd = &cobra.Command{
+		Use:   "state <job id>",
+		Short: "Print the state of a job",
+		RunE:  jobStateFn,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	jobMessagesCmd = &cobra.Command{
+		Use:   "messages <job id>",
+		Short: "Stream the messages and state changes of a job until it ends",
+		RunE:  jobMessagesFn,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	jobMetricsCmd = &cobra.Command{
+		Use:   "metrics <job id>",
+		Short: "Print the metrics of a job",
+		RunE:  jobMetricsFn,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	jobCancelCmd = &cobra.Command{
+		Use:   "cancel <job id>",
+		Short: "Cancel a job",
+		RunE:  jobCancelFn,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	jobPipelineCmd = &cobra.Command{
+		Use:   "pipeline <job id>",
+		Short: "Describe the pipeline graph of a job",
+		RunE:  jobPipelineFn,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	pipelineProto bool
+)
+
+func init() {
+	jobCmd.AddCommand(jobListCmd, jobStateCmd, jobMessagesCmd, jobMetricsCmd, jobCancelCmd, jobPipelineCmd)
+	jobPipelineCmd.Flags().BoolVar(&pipelineProto, "proto", false, "Print the pipeline proto instead of its transforms")
+}
+
+func jobListFn(cmd *cobra.Command, args []string) error {
+	ctx, cc, err := dial()
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+
+	resp, err := jobpb.NewJobServiceClient(cc).GetJobs(ctx, &jobpb.GetJobsRequest{})
+	if err != nil {
+		return err
+	}
+	for _, info := range resp.GetJobInfo() {
+		cmd.Printf("%v\t%v\t%v\n", info.GetJobId(), info.GetJobName(), info.GetState())
+	}
+	return nil
+}
+
+func jobStateFn(cmd *cobra.Command, args []string) error {
+	ctx, cc, err := dial()
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+
+	resp, err := jobpb.NewJobServiceClient(cc).GetState(ctx, &jobpb.GetJobStateRequest{JobId: args[0]})
+	if err != nil {
+		return err
+	}
+	cmd.Println(resp.GetState())
+	return nil
+}
+
+func jobMessagesFn(cmd *cobra.Command, args []string) error {
+	ctx, cc, err := dial()
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+
+	stream, err := jobpb.NewJobServiceClient(cc).GetMessageStream(ctx, &jobpb.JobMessagesRequest{JobId: args[0]})
+	if err != nil {
+		return err
+	}
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if state := msg.GetStateResponse(); state != nil {
+			cmd.Printf("Job state: %v\n", state.GetState())
+			if isTerminal(state.GetState()) {
+				return nil
+			}
+			continue
+		}
+		m := msg.GetMessageResponse()
+		cmd.Printf("%v %v (%v): %v\n", strings.TrimPrefix(m.GetImportance().String(), "JOB_MESSAGE_"), m.GetTime(), m.GetMessageId(), m.GetMessageText())
+	}
+}
+
+// isTerminal returns whether a job in the state has ended.
+func isTerminal(state jobpb.JobState_Enum) bool {
+	switch state {
+	case jobpb.JobState_DONE, jobpb.JobState_FAILED, jobpb.JobState_CANCELLED, jobpb.JobState_DRAINED, jobpb.JobState_UPDATED:
+		return true
+	}
+	return false
+}
+
+func jobMetricsFn(cmd *cobra.Command, args []string) error {
+	ctx, cc, err := dial()
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+
+	resp, err := jobpb.NewJobServiceClient(cc).GetJobMetrics(ctx, &jobpb.GetJobMetricsRequest{JobId: args[0]})
+	if err != nil {
+		return err
+	}
+	all := metricsx.FromMonitoringInfos(resp.GetMetrics().GetAttempted(), resp.GetMetrics().GetCommitted()).AllMetrics()
+	for _, c := range all.Counters() {
+		cmd.Printf("%v\t%v.%v\tcounter\t%v\n", c.Key.Step, c.Key.Namespace, c.Key.Name, c.Result())
+	}
+	for _, d := range all.Distributions() {
+		v := d.Result()
+		cmd.Printf("%v\t%v.%v\tdistribution\tcount=%v sum=%v min=%v max=%v\n", d.Key.Step, d.Key.Namespace, d.Key.Name, v.Count, v.Sum, v.Min, v.Max)
+	}
+	for _, g := range all.Gauges() {
+		v := g.Result()
+		cmd.Printf("%v\t%v.%v\tgauge\t%v at %v\n", g.Key.Step, g.Key.Namespace, g.Key.Name, v.Value, v.Timestamp)
+	}
+	return nil
+}
+
+func jobCancelFn(cmd *cobra.Command, args []string) error {
+	ctx, cc, err := dial()
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+
+	resp, err := jobpb.NewJobServiceClient(cc).Cancel(ctx, &jobpb.CancelJobRequest{JobId: args[0]})
+	if err != nil {
+		return err
+	}
+	cmd.Println(resp.GetState())
+	return nil
+}
+
+func jobPipelineFn(cmd *cobra.Command, args []string) error {
+	ctx, cc, err := dial()
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+
+	resp, err := jobpb.NewJobServiceClient(cc).GetPipeline(ctx, &jobpb.GetJobPipelineRequest{JobId: args[0]})
+	if err != nil {
+		return err
+	}
+	if pipelineProto {
+		cmd.Print(proto.MarshalTextString(resp.GetPipeline()))
+		return nil
+	}
+	cmd.Print(describePipeline(resp.GetPipeline()))
+	return nil
+}
+
+// describePipeline returns the tree of transforms of the pipeline, with the
+// URN and the input and output PCollections of each transform.
+func describePipeline(p *pipepb.Pipeline) string {
+	var sb strings.Builder
+	transforms := p.GetComponents().GetTransforms()
+	var describe func(id string, depth int)
+	describe = func(id string, depth int) {
+		t := transforms[id]
+		indent := strings.Repeat("  ", depth)
+		sb.WriteString(indent + t.GetUniqueName())
+		if urn := t.GetSpec().GetUrn(); urn != "" {
+			sb.WriteString(" [" + urn + "]")
+		}
+		sb.WriteString("\n")
+		if in := sortedValues(t.GetInputs()); len(in) > 0 {
+			sb.WriteString(indent + "  inputs: " + strings.Join(in, ", ") + "\n")
+		}
+		if out := sortedValues(t.GetOutputs()); len(out) > 0 {
+			sb.WriteString(indent + "  outputs: " + strings.Join(out, ", ") + "\n")
+		}
+		for _, sub := range t.GetSubtransforms() {
+			describe(sub, depth+1)
+		}
+	}
+	for _, id := range p.GetRootTransformIds() {
+		describe(id, 0)
+	}
+	return sb.String()
+}
+
+// sortedValues returns the values of the map, sorted by key.
+func sortedValues(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var values []string
+	for _, k := range keys {
+		values = append(values, m[k])
+	}
+	return values
+}
@@ -38,7 +38,7 @@ var (
 )
 
 func init() {
-	RootCmd.AddCommand(artifactCmd, provisionCmd)
+	RootCmd.AddCommand(artifactCmd, jobCmd, provisionCmd)
 	RootCmd.PersistentFlags().StringVarP(&endpoint, "endpoint", "e", "", "Server endpoint, such as localhost:123")
 	RootCmd.PersistentFlags().StringVarP(&id, "id", "i", "", "Client ID")
 }
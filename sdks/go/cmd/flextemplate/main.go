@@ -0,0 +1,109 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// flextemplate builds and launches Dataflow Flex Templates of Go pipelines.
+//
+// Usage:
+//
+//	flextemplate build --template_path=gs://bucket/template.json --image=gcr.io/project/pipeline --metadata=metadata.json --project=project
+//	flextemplate launch --template_path=gs://bucket/template.json --job_name=job --parameters='{"input": "gs://bucket/input"}' --project=project --region=region
+//	flextemplate dockerfile --binary=pipeline
+//
+// The metadata file holds the name, description and parameters of the
+// template, in the JSON format of Dataflow template metadata, as produced by
+// encoding a TemplateMetadata, for example with the parameters from
+// flextemplate.Parameters.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/runners/dataflow/dataflowlib"
+	"github.com/apache/beam/sdks/go/pkg/beam/runners/dataflow/flextemplate"
+	df "google.golang.org/api/dataflow/v1b3"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %v build|launch|dockerfile [flags]\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	templatePath := fs.String("template_path", "", "GCS path of the template.")
+	project := fs.String("project", "", "Google Cloud project.")
+	region := fs.String("region", "us-central1", "Dataflow region to launch the job in.")
+	image := fs.String("image", "", "Container image of the pipeline, for build.")
+	metadata := fs.String("metadata", "", "Template metadata file, for build.")
+	jobName := fs.String("job_name", "", "Name of the launched job, for launch.")
+	parameters := fs.String("parameters", "", "JSON-formatted map[string]string of template parameters, for launch.")
+	endpoint := fs.String("dataflow_endpoint", "", "Dataflow endpoint, for launch (optional).")
+	binary := fs.String("binary", "pipeline", "Path of the pipeline binary in the build context, for dockerfile.")
+	fs.Parse(os.Args[2:])
+
+	ctx := context.Background()
+	switch os.Args[1] {
+	case "build":
+		data, err := ioutil.ReadFile(*metadata)
+		if err != nil {
+			log.Fatalf("Failed to read metadata: %v", err)
+		}
+		var m df.TemplateMetadata
+		if err := json.Unmarshal(data, &m); err != nil {
+			log.Fatalf("Invalid metadata file %v: %v", *metadata, err)
+		}
+		spec := &df.ContainerSpec{
+			Image:    *image,
+			Metadata: &m,
+			SdkInfo:  &df.SDKInfo{Language: "GO"},
+		}
+		if err := flextemplate.Build(ctx, *project, *templatePath, spec); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Built template %v", *templatePath)
+
+	case "launch":
+		var params map[string]string
+		if *parameters != "" {
+			if err := json.Unmarshal([]byte(*parameters), &params); err != nil {
+				log.Fatalf("Invalid --parameters: %v", err)
+			}
+		}
+		client, err := dataflowlib.NewClient(ctx, *endpoint)
+		if err != nil {
+			log.Fatal(err)
+		}
+		job, err := flextemplate.Launch(ctx, client, *project, *region, *templatePath, *jobName, params)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Launched job: %v", job.Id)
+
+	case "dockerfile":
+		fmt.Print(flextemplate.Dockerfile(*binary))
+
+	default:
+		usage()
+	}
+}
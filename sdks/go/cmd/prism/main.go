@@ -0,0 +1,42 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// prism runs a standalone prism job service, which executes portable
+// pipelines with LOOPBACK environments. Pipelines are submitted to it with the
+// prism runner, or the universal runner with --environment_type=LOOPBACK:
+//
+//	prism --job_port=8073
+//	go run wordcount.go --runner=prism --endpoint=localhost:8073
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/runners/prism"
+)
+
+var port = flag.Int("job_port", 8073, "Port of the job service.")
+
+func main() {
+	flag.Parse()
+	s, err := prism.StartServer(context.Background(), *port)
+	if err != nil {
+		log.Fatalf("Failed to start job service: %v", err)
+	}
+	log.Printf("Serving jobs at %v", s.Endpoint())
+	select {}
+}
@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/apache/beam/sdks/go/container/pool"
 	"github.com/apache/beam/sdks/go/pkg/beam/artifact"
 	"github.com/apache/beam/sdks/go/pkg/beam/provision"
 	"github.com/apache/beam/sdks/go/pkg/beam/util/execx"
@@ -39,10 +40,17 @@ var (
 	provisionEndpoint = flag.String("provision_endpoint", "", "Local provision endpoint for FnHarness (required).")
 	controlEndpoint   = flag.String("control_endpoint", "", "Local control endpoint for FnHarness (required).")
 	semiPersistDir    = flag.String("semi_persist_dir", "/tmp", "Local semi-persistent directory (optional).")
+
+	workerPool     = flag.Bool("worker_pool", false, "Run as a worker pool, starting workers on requests to the external worker pool service, instead of a single worker (optional).")
+	workerPoolPort = flag.Int("worker_pool_port", 50000, "Port of the external worker pool service, with --worker_pool (optional).")
 )
 
 func main() {
 	flag.Parse()
+	if *workerPool {
+		runWorkerPool()
+		return
+	}
 	if *id == "" {
 		log.Fatal("No id provided.")
 	}
@@ -122,8 +130,12 @@ func main() {
 	}
 
 	// (3) The persist dir may be on a noexec volume, so we must
-	// copy the binary to a different location to execute.
-	const prog = "/bin/worker"
+	// copy the binary to a different location to execute. Workers
+	// of a worker pool share the container, so each has its own copy.
+	prog := "/bin/worker"
+	if os.Getenv(poolWorkerEnv) != "" {
+		prog += "_" + strings.Map(sanitize, *id)
+	}
 	if err := copyExe(filepath.Join(dir, name), prog); err != nil {
 		log.Fatalf("Failed to copy worker binary: %v", err)
 	}
@@ -143,6 +155,38 @@ func main() {
 	log.Fatalf("User program exited: %v", execx.Execute(prog, args...))
 }
 
+// poolWorkerEnv is set in the environment of the workers started by a
+// worker pool.
+const poolWorkerEnv = "BEAM_WORKER_POOL_WORKER"
+
+// runWorkerPool serves the external worker pool service, whose workers
+// are started by running this program again, until the service fails.
+func runWorkerPool() {
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Failed to find the boot program for the worker pool: %v", err)
+	}
+	if err := os.Setenv(poolWorkerEnv, "1"); err != nil {
+		log.Fatalf("Failed to set up the worker pool environment: %v", err)
+	}
+	wp, err := pool.New(context.Background(), *workerPoolPort, exe, *semiPersistDir)
+	if err != nil {
+		log.Fatalf("Failed to start worker pool: %v", err)
+	}
+	log.Fatalf("Worker pool exited: %v", wp.ServeAndWait())
+}
+
+// sanitize replaces the characters of worker IDs that aren't safe in file
+// names.
+func sanitize(r rune) rune {
+	switch {
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-', r == '_':
+		return r
+	default:
+		return '_'
+	}
+}
+
 func copyExe(from, to string) error {
 	src, err := os.Open(from)
 	if err != nil {
@@ -0,0 +1,179 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pool provides a worker pool service, which starts SDK harnesses
+// as subprocesses of the container. It lets runners such as Flink, and
+// deployments on Kubernetes, run several workers in one container, started
+// and stopped with the BeamFnExternalWorkerPool API.
+package pool
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	"google.golang.org/grpc"
+)
+
+// New initializes a worker pool, serving the ExternalWorkerService at the
+// given port. Workers are started by running the container executable with
+// the endpoints of the StartWorker request, and a semi-persistent directory
+// of their own under the given one.
+func New(ctx context.Context, port int, containerExecutable, semiPersistDir string) (*Process, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on port %v: %v", port, err)
+	}
+	log.Infof(ctx, "starting worker pool server at %v", lis.Addr())
+
+	root, cancel := context.WithCancel(ctx)
+	s := &Process{
+		lis:                 lis,
+		root:                root,
+		rootCancel:          cancel,
+		workers:             map[string]*worker{},
+		grpcServer:          grpc.NewServer(),
+		containerExecutable: containerExecutable,
+		semiPersistDir:      semiPersistDir,
+	}
+	fnpb.RegisterBeamFnExternalWorkerPoolServer(s.grpcServer, s)
+	return s, nil
+}
+
+// Process implements fnpb.BeamFnExternalWorkerPoolServer, by starting
+// workers in subprocesses.
+type Process struct {
+	lis        net.Listener
+	root       context.Context
+	rootCancel context.CancelFunc
+
+	mu      sync.Mutex
+	workers map[string]*worker
+	// running tracks the worker subprocesses until they exit.
+	running sync.WaitGroup
+
+	grpcServer          *grpc.Server
+	containerExecutable string
+	semiPersistDir      string
+}
+
+// StartWorker starts a worker subprocess, implementing BeamFnExternalWorkerPoolServer.StartWorker.
+func (s *Process) StartWorker(ctx context.Context, req *fnpb.StartWorkerRequest) (*fnpb.StartWorkerResponse, error) {
+	log.Infof(ctx, "starting worker %v", req.GetWorkerId())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.workers[req.GetWorkerId()]; ok {
+		return &fnpb.StartWorkerResponse{
+			Error: fmt.Sprintf("worker with ID %q already exists", req.GetWorkerId()),
+		}, nil
+	}
+	if req.GetProvisionEndpoint() == nil {
+		return &fnpb.StartWorkerResponse{Error: fmt.Sprintf("Missing provision endpoint for worker %v", req.GetWorkerId())}, nil
+	}
+
+	args := []string{
+		"--id=" + req.GetWorkerId(),
+		"--provision_endpoint=" + req.GetProvisionEndpoint().GetUrl(),
+		"--semi_persist_dir=" + filepath.Join(s.semiPersistDir, req.GetWorkerId()),
+	}
+	// The other endpoints can also be obtained from the provision service.
+	if url := req.GetLoggingEndpoint().GetUrl(); url != "" {
+		args = append(args, "--logging_endpoint="+url)
+	}
+	if url := req.GetArtifactEndpoint().GetUrl(); url != "" {
+		args = append(args, "--artifact_endpoint="+url)
+	}
+	if url := req.GetControlEndpoint().GetUrl(); url != "" {
+		args = append(args, "--control_endpoint="+url)
+	}
+
+	// Canceling the context kills the subprocess.
+	ctx, cancel := context.WithCancel(s.root)
+	cmd := exec.CommandContext(ctx, s.containerExecutable, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return &fnpb.StartWorkerResponse{Error: fmt.Sprintf("failed to start worker %v: %v", req.GetWorkerId(), err)}, nil
+	}
+	w := &worker{cancel: cancel}
+	s.workers[req.GetWorkerId()] = w
+
+	s.running.Add(1)
+	go s.wait(ctx, req.GetWorkerId(), w, cmd)
+	return &fnpb.StartWorkerResponse{}, nil
+}
+
+// worker is a worker subprocess of the pool.
+type worker struct {
+	cancel context.CancelFunc
+}
+
+// wait waits for the worker subprocess to exit, and then drops the worker,
+// so that the runner can start a worker with the same ID again.
+func (s *Process) wait(ctx context.Context, id string, w *worker, cmd *exec.Cmd) {
+	defer s.running.Done()
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		log.Errorf(s.root, "worker %v failed: %v", id, err)
+	}
+	w.cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.workers[id] == w {
+		delete(s.workers, id)
+	}
+}
+
+// StopWorker kills a worker subprocess, implementing BeamFnExternalWorkerPoolServer.StopWorker.
+func (s *Process) StopWorker(ctx context.Context, req *fnpb.StopWorkerRequest) (*fnpb.StopWorkerResponse, error) {
+	log.Infof(ctx, "stopping worker %v", req.GetWorkerId())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w, ok := s.workers[req.GetWorkerId()]; ok {
+		w.cancel()
+		delete(s.workers, req.GetWorkerId())
+		return &fnpb.StopWorkerResponse{}, nil
+	}
+	return &fnpb.StopWorkerResponse{
+		Error: fmt.Sprintf("no worker with id %q running", req.GetWorkerId()),
+	}, nil
+}
+
+// Stop terminates the service and stops all workers.
+func (s *Process) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	log.Infof(ctx, "stopping worker pool, and %d workers", len(s.workers))
+	s.workers = map[string]*worker{}
+	s.mu.Unlock()
+
+	// Canceling the root context kills the workers.
+	s.rootCancel()
+	s.grpcServer.GracefulStop()
+	s.lis.Close()
+	s.running.Wait()
+	return nil
+}
+
+// ServeAndWait serves the worker pool until it's stopped.
+func (s *Process) ServeAndWait() error {
+	return s.grpcServer.Serve(s.lis)
+}
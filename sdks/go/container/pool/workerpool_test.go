@@ -0,0 +1,124 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+)
+
+func TestProcess(t *testing.T) {
+	// The workers are shell scripts that run until they're killed.
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skipf("no shell to run workers: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "workerpool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exe := filepath.Join(dir, "boot")
+	if err := ioutil.WriteFile(exe, []byte("#!"+sh+"\nexec sleep 60\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	endpoint := &pipepb.ApiServiceDescriptor{Url: "localhost:0"}
+
+	ctx := context.Background()
+	server, err := New(ctx, 0, exe, dir)
+	if err != nil {
+		t.Fatalf("Unable to start server: %v", err)
+	}
+	go server.ServeAndWait()
+
+	startTests := []struct {
+		req         *fnpb.StartWorkerRequest
+		errExpected bool
+	}{
+		{
+			req: &fnpb.StartWorkerRequest{
+				WorkerId:          "Worker1",
+				ControlEndpoint:   endpoint,
+				LoggingEndpoint:   endpoint,
+				ProvisionEndpoint: endpoint,
+			},
+		}, {
+			req: &fnpb.StartWorkerRequest{
+				WorkerId:          "Worker2",
+				ProvisionEndpoint: endpoint,
+			},
+		}, {
+			req: &fnpb.StartWorkerRequest{
+				WorkerId:          "Worker1",
+				ProvisionEndpoint: endpoint,
+			},
+			errExpected: true, // Repeated start
+		}, {
+			req: &fnpb.StartWorkerRequest{
+				WorkerId:        "missingProvision",
+				ControlEndpoint: endpoint,
+				LoggingEndpoint: endpoint,
+			},
+			errExpected: true,
+		},
+	}
+	for _, test := range startTests {
+		resp, err := server.StartWorker(ctx, test.req)
+		if test.errExpected {
+			if err != nil || resp.Error == "" {
+				t.Errorf("StartWorker(%v) = %v, %v; want error response", test.req.GetWorkerId(), resp, err)
+			}
+		} else if err != nil || resp.Error != "" {
+			t.Errorf("StartWorker(%v) = %v, %v; want success", test.req.GetWorkerId(), resp, err)
+		}
+	}
+
+	stopTests := []struct {
+		req         *fnpb.StopWorkerRequest
+		errExpected bool
+	}{
+		{req: &fnpb.StopWorkerRequest{WorkerId: "Worker1"}},
+		{req: &fnpb.StopWorkerRequest{WorkerId: "Worker1"}, errExpected: true}, // Repeated stop
+		{req: &fnpb.StopWorkerRequest{WorkerId: "NonExistent"}, errExpected: true},
+	}
+	for _, test := range stopTests {
+		resp, err := server.StopWorker(ctx, test.req)
+		if test.errExpected {
+			if err != nil || resp.Error == "" {
+				t.Errorf("StopWorker(%v) = %v, %v; want error response", test.req.GetWorkerId(), resp, err)
+			}
+		} else if err != nil || resp.Error != "" {
+			t.Errorf("StopWorker(%v) = %v, %v; want success", test.req.GetWorkerId(), resp, err)
+		}
+	}
+
+	// A stopped worker can be started again.
+	restart := &fnpb.StartWorkerRequest{WorkerId: "Worker1", ProvisionEndpoint: endpoint}
+	if resp, err := server.StartWorker(ctx, restart); err != nil || resp.Error != "" {
+		t.Errorf("StartWorker(Worker1) after stop = %v, %v; want success", resp, err)
+	}
+
+	if err := server.Stop(ctx); err != nil {
+		t.Errorf("error on Stop: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/protox"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+)
+
+// workerName is the staged name of the worker binary.
+const workerName = "worker"
+
+var (
+	filesMu sync.Mutex
+	// files maps the names of the files to stage to their local paths.
+	files = make(map[string]string)
+	// stagedDir is the directory staged files are retrieved into on workers.
+	stagedDir string
+)
+
+// StageFile stages the local file at the given path as an artifact of the
+// pipelines submitted afterwards, so that DoFns can load it on workers, such
+// as a model, a dictionary or a certificate. The file is available on workers
+// at the path returned by StagedFile for its base name, which must be unique
+// among the staged files. Files are staged by the runners staging artifacts,
+// such as the universal and Dataflow runners, and may also be staged with
+// --files_to_stage.
+func StageFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrapf(err, "invalid file to stage %v", path)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stage file %v", path)
+	}
+	if info.IsDir() {
+		return errors.Errorf("failed to stage file %v: directories cannot be staged", path)
+	}
+	name := filepath.Base(abs)
+	if name == workerName {
+		return errors.Errorf("failed to stage file %v: the name %v is reserved for the worker binary", path, name)
+	}
+
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	if prev, ok := files[name]; ok && prev != abs {
+		return errors.Errorf("failed to stage file %v: file %v is already staged as %v", path, prev, name)
+	}
+	files[name] = abs
+	return nil
+}
+
+// FileDependencies returns the artifacts of the files to stage, in order of
+// their names, for the dependencies of environments.
+func FileDependencies() []*pipepb.ArtifactInformation {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ret []*pipepb.ArtifactInformation
+	for _, name := range names {
+		ret = append(ret, &pipepb.ArtifactInformation{
+			TypeUrn:     URNFileArtifact,
+			TypePayload: protox.MustEncode(&pipepb.ArtifactFilePayload{Path: files[name]}),
+			RoleUrn:     URNStagingTo,
+			RolePayload: protox.MustEncode(&pipepb.ArtifactStagingToRolePayload{StagedName: name}),
+		})
+	}
+	return ret
+}
+
+// SetStagedDir sets the directory that staged files are retrieved into on
+// workers. It is called by the harness.
+func SetStagedDir(dir string) {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	stagedDir = dir
+}
+
+// StagedFile returns the local path of the staged file with the given name,
+// the base name of the staged file. Workers started by runners retrieve the
+// staged files into their staged directory. For workers run in the process
+// staging the files, such as LOOPBACK workers, it is the path of the staged
+// file itself.
+func StagedFile(name string) (string, error) {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+
+	if stagedDir == "" {
+		if path, ok := files[name]; ok {
+			return path, nil
+		}
+		return "", errors.Errorf("file %v not staged", name)
+	}
+	path := filepath.Join(stagedDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", errors.Wrapf(err, "staged file %v not found", name)
+	}
+	return path, nil
+}
@@ -0,0 +1,98 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+func resetFiles() {
+	files = make(map[string]string)
+	stagedDir = ""
+}
+
+func TestStageFile(t *testing.T) {
+	defer resetFiles()
+	dir := t.TempDir()
+	dict := filepath.Join(dir, "dict.txt")
+	if err := ioutil.WriteFile(dict, []byte("words"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "other")
+	if err := os.Mkdir(other, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(other, "dict.txt"), []byte("other words"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := StageFile(dict); err != nil {
+		t.Fatalf("StageFile(%v) failed: %v", dict, err)
+	}
+	if err := StageFile(dict); err != nil {
+		t.Errorf("StageFile(%v) again failed: %v", dict, err)
+	}
+	for _, path := range []string{filepath.Join(other, "dict.txt"), filepath.Join(dir, "missing"), other} {
+		if err := StageFile(path); err == nil {
+			t.Errorf("StageFile(%v) succeeded, want error", path)
+		}
+	}
+
+	deps := FileDependencies()
+	if len(deps) != 1 {
+		t.Fatalf("FileDependencies() = %v, want one dependency", deps)
+	}
+	var file pipepb.ArtifactFilePayload
+	var role pipepb.ArtifactStagingToRolePayload
+	if err := proto.Unmarshal(deps[0].GetTypePayload(), &file); err != nil {
+		t.Fatal(err)
+	}
+	if err := proto.Unmarshal(deps[0].GetRolePayload(), &role); err != nil {
+		t.Fatal(err)
+	}
+	if file.GetPath() != dict || role.GetStagedName() != "dict.txt" {
+		t.Errorf("FileDependencies() = %v staged as %v, want %v staged as dict.txt", file.GetPath(), role.GetStagedName(), dict)
+	}
+
+	if got, err := StagedFile("dict.txt"); err != nil || got != dict {
+		t.Errorf("StagedFile(dict.txt) = %v, %v, want %v", got, err, dict)
+	}
+	if _, err := StagedFile("missing"); err == nil {
+		t.Error("StagedFile(missing) succeeded, want error")
+	}
+}
+
+func TestStagedFile_Worker(t *testing.T) {
+	defer resetFiles()
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "model.bin"), []byte("model"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	SetStagedDir(dir)
+
+	if got, err := StagedFile("model.bin"); err != nil || got != filepath.Join(dir, "model.bin") {
+		t.Errorf("StagedFile(model.bin) = %v, %v, want the file in the staged directory", got, err)
+	}
+	if _, err := StagedFile("missing"); err == nil {
+		t.Error("StagedFile(missing) succeeded, want error")
+	}
+}
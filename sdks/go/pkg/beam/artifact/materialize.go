@@ -18,13 +18,17 @@ package artifact
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"io"
 	"log"
 	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -43,6 +47,9 @@ import (
 // TODO(lostluck): 2018/05/28 Extract these from their enum descriptors in the pipeline_v1 proto
 const (
 	URNFileArtifact        = "beam:artifact:type:file:v1"
+	URNUrlArtifact         = "beam:artifact:type:url:v1"
+	URNEmbeddedArtifact    = "beam:artifact:type:embedded:v1"
+	URNDeferredArtifact    = "beam:artifact:type:deferred:v1"
 	URNPipRequirementsFile = "beam:artifact:role:pip_requirements_file:v1"
 	URNStagingTo           = "beam:artifact:role:staging_to:v1"
 	NoArtifactsStaged      = "__no_artifacts_staged__"
@@ -64,6 +71,16 @@ func Materialize(ctx context.Context, endpoint string, dependencies []*pipepb.Ar
 	}
 }
 
+// MaterializeWithClient is Materialize for a connected artifact retrieval
+// service, such as one requiring transport credentials. Only dependencies are
+// supported, not legacy retrieval tokens.
+func MaterializeWithClient(ctx context.Context, client jobpb.ArtifactRetrievalServiceClient, dependencies []*pipepb.ArtifactInformation, dest string) ([]*pipepb.ArtifactInformation, error) {
+	if len(dependencies) == 0 {
+		return []*pipepb.ArtifactInformation{}, nil
+	}
+	return newMaterializeWithClient(ctx, client, dependencies, dest)
+}
+
 func newMaterialize(ctx context.Context, endpoint string, dependencies []*pipepb.ArtifactInformation, dest string) ([]*pipepb.ArtifactInformation, error) {
 	cc, err := grpcx.Dial(ctx, endpoint, 2*time.Minute)
 	if err != nil {
@@ -83,21 +100,21 @@ func newMaterializeWithClient(ctx context.Context, client jobpb.ArtifactRetrieva
 	var artifacts []*pipepb.ArtifactInformation
 	var list []retrievable
 	for _, dep := range resolution.Replacements {
+		if dep.TypeUrn == URNDeferredArtifact {
+			return nil, errors.Errorf("failed to resolve deferred artifact with role %v", dep.RoleUrn)
+		}
 		path, err := extractStagingToPath(dep)
 		if err != nil {
 			return nil, err
 		}
-		filePayload := pipepb.ArtifactFilePayload{
-			Path: path,
-		}
-		if dep.TypeUrn == URNFileArtifact {
-			typePayload := pipepb.ArtifactFilePayload{}
-			if err := proto.Unmarshal(dep.TypePayload, &typePayload); err != nil {
-				return nil, errors.Wrap(err, "failed to parse artifact file payload")
-			}
-			filePayload.Sha256 = typePayload.Sha256
+		hash, err := extractSHA256(dep)
+		if err != nil {
+			return nil, err
 		}
-		newTypePayload, err := proto.Marshal(&filePayload)
+		newTypePayload, err := proto.Marshal(&pipepb.ArtifactFilePayload{
+			Path:   path,
+			Sha256: hash,
+		})
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create artifact type payload")
 		}
@@ -122,6 +139,8 @@ func newMaterializeWithClient(ctx context.Context, client jobpb.ArtifactRetrieva
 				RoleUrn:     URNStagingTo,
 				RolePayload: rolePayload,
 			},
+			path:   path,
+			sha256: hash,
 		})
 	}
 
@@ -150,37 +169,78 @@ func extractStagingToPath(artifact *pipepb.ArtifactInformation) (string, error)
 			return "", err
 		}
 		stagedName = generateId() + "-" + filepath.Base(ty.Path)
+	} else if artifact.TypeUrn == URNUrlArtifact {
+		ty := pipepb.ArtifactUrlPayload{}
+		if err := proto.Unmarshal(artifact.TypePayload, &ty); err != nil {
+			return "", err
+		}
+		u, err := url.Parse(ty.Url)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid artifact url %v", ty.Url)
+		}
+		stagedName = generateId() + "-" + path.Base(u.Path)
+	} else if artifact.TypeUrn == URNEmbeddedArtifact {
+		stagedName = generateId() + "-embedded"
 	} else {
 		return "", errors.Errorf("failed to extract staging path for artifact type %v role %v", artifact.TypeUrn, artifact.RoleUrn)
 	}
 	return stagedName, nil
 }
 
+// extractSHA256 returns the SHA256 hash of the content of the artifact, if
+// known from its type payload.
+func extractSHA256(artifact *pipepb.ArtifactInformation) (string, error) {
+	switch artifact.TypeUrn {
+	case URNFileArtifact:
+		ty := pipepb.ArtifactFilePayload{}
+		if err := proto.Unmarshal(artifact.TypePayload, &ty); err != nil {
+			return "", errors.Wrap(err, "failed to parse artifact file payload")
+		}
+		return ty.Sha256, nil
+	case URNUrlArtifact:
+		ty := pipepb.ArtifactUrlPayload{}
+		if err := proto.Unmarshal(artifact.TypePayload, &ty); err != nil {
+			return "", errors.Wrap(err, "failed to parse artifact url payload")
+		}
+		return ty.Sha256, nil
+	}
+	return "", nil
+}
+
 func MustExtractFilePayload(artifact *pipepb.ArtifactInformation) (string, string) {
 	if artifact.TypeUrn != URNFileArtifact {
-		log.Fatalf("Unsupported artifact type #{artifact.TypeUrn}")
+		log.Fatalf("Unsupported artifact type %v", artifact.TypeUrn)
 	}
 	ty := pipepb.ArtifactFilePayload{}
 	if err := proto.Unmarshal(artifact.TypePayload, &ty); err != nil {
-		log.Fatalf("failed to parse artifact file payload: #{err}")
+		log.Fatalf("failed to parse artifact file payload: %v", err)
 	}
 	return ty.Path, ty.Sha256
 }
 
+// artifact is a resolved artifact, retrieved as the path under the dest
+// directory. Embedded artifacts are written out directly, and URL artifacts
+// are downloaded by the worker, since not all runners serve them. Other
+// artifacts are retrieved from the retrieval service.
 type artifact struct {
 	client jobpb.ArtifactRetrievalServiceClient
 	dep    *pipepb.ArtifactInformation
+	path   string
+	// sha256 is the expected hash of the content, if known.
+	sha256 string
 }
 
 func (a artifact) retrieve(ctx context.Context, dest string) error {
-	path, err := extractStagingToPath(a.dep)
-	if err != nil {
-		return err
-	}
+	filename := filepath.Join(dest, filepath.FromSlash(a.path))
 
-	filename := filepath.Join(dest, filepath.FromSlash(path))
-
-	_, err = os.Stat(filename)
+	_, err := os.Stat(filename)
+	if err == nil && a.sha256 != "" {
+		// Artifacts retrieved by a previous worker in the same directory
+		// are valid if their content matches.
+		if hash, err := computeSHA256(filename); err == nil && hash == a.sha256 {
+			return nil
+		}
+	}
 	if err == nil {
 		if err = os.Remove(filename); err != nil {
 			return errors.Errorf("failed to delete: %v (remove: %v)", filename, err)
@@ -193,18 +253,13 @@ func (a artifact) retrieve(ctx context.Context, dest string) error {
 		return err
 	}
 
-	stream, err := a.client.GetArtifact(ctx, &jobpb.GetArtifactRequest{Artifact: a.dep})
-	if err != nil {
-		return err
-	}
-
 	fd, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
 	if err != nil {
 		return err
 	}
 	w := bufio.NewWriter(fd)
 
-	sha256Hash, err := writeChunks(stream, w)
+	sha256Hash, err := a.write(ctx, w)
 	if err != nil {
 		fd.Close() // drop any buffered content
 		return errors.Wrapf(err, "failed to retrieve chunk for %v", filename)
@@ -216,7 +271,62 @@ func (a artifact) retrieve(ctx context.Context, dest string) error {
 	stat, _ := fd.Stat()
 	log.Printf("Downloaded: %v (sha256: %v, size: %v)", filename, sha256Hash, stat.Size())
 
-	return fd.Close()
+	if err := fd.Close(); err != nil {
+		return err
+	}
+	if a.sha256 != "" && sha256Hash != a.sha256 {
+		return errors.Errorf("bad SHA256 for %v: %v, want %v", filename, sha256Hash, a.sha256)
+	}
+	return nil
+}
+
+// write writes the content of the artifact, and returns its SHA256 hash.
+func (a artifact) write(ctx context.Context, w io.Writer) (string, error) {
+	switch a.dep.TypeUrn {
+	case URNEmbeddedArtifact:
+		ty := pipepb.EmbeddedFilePayload{}
+		if err := proto.Unmarshal(a.dep.TypePayload, &ty); err != nil {
+			return "", errors.Wrap(err, "failed to parse artifact embedded payload")
+		}
+		return writeHashed(bytes.NewReader(ty.Data), w)
+	case URNUrlArtifact:
+		ty := pipepb.ArtifactUrlPayload{}
+		if err := proto.Unmarshal(a.dep.TypePayload, &ty); err != nil {
+			return "", errors.Wrap(err, "failed to parse artifact url payload")
+		}
+		return download(ctx, ty.Url, w)
+	default:
+		stream, err := a.client.GetArtifact(ctx, &jobpb.GetArtifactRequest{Artifact: a.dep})
+		if err != nil {
+			return "", err
+		}
+		return writeChunks(stream, w)
+	}
+}
+
+// download writes the content at the url, and returns its SHA256 hash.
+func download(ctx context.Context, rawURL string, w io.Writer) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid artifact url %v", rawURL)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download %v", rawURL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to download %v: %v", rawURL, resp.Status)
+	}
+	return writeHashed(resp.Body, w)
+}
+
+func writeHashed(r io.Reader, w io.Writer) (string, error) {
+	sha256W := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, sha256W), r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sha256W.Sum(nil)), nil
 }
 
 func writeChunks(stream jobpb.ArtifactRetrievalService_GetArtifactClient, w io.Writer) (string, error) {
@@ -21,10 +21,13 @@ import (
 	"encoding/hex"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/protox"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
 	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
@@ -212,6 +215,83 @@ func TestNewRetrieveWithResolution(t *testing.T) {
 	checkStagedFiles(mds, dest, expected, t)
 }
 
+func TestNewRetrieveWithEmbeddedAndURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "from url")
+	}))
+	defer srv.Close()
+
+	client := &fakeRetrievalService{}
+	deps := []*pipepb.ArtifactInformation{
+		{
+			TypeUrn:     URNEmbeddedArtifact,
+			TypePayload: protox.MustEncode(&pipepb.EmbeddedFilePayload{Data: []byte("embedded")}),
+			RoleUrn:     URNStagingTo,
+			RolePayload: protox.MustEncode(&pipepb.ArtifactStagingToRolePayload{StagedName: "a.txt"}),
+		},
+		{
+			TypeUrn:     URNUrlArtifact,
+			TypePayload: protox.MustEncode(&pipepb.ArtifactUrlPayload{Url: srv.URL + "/b.txt", Sha256: sha256Hex("from url")}),
+			RoleUrn:     URNStagingTo,
+			RolePayload: protox.MustEncode(&pipepb.ArtifactStagingToRolePayload{StagedName: "b.txt"}),
+		},
+	}
+
+	dest := makeTempDir(t)
+	defer os.RemoveAll(dest)
+	ctx := grpcx.WriteWorkerID(context.Background(), "worker")
+
+	mds, err := newMaterializeWithClient(ctx, client, deps, dest)
+	if err != nil {
+		t.Fatalf("materialize failed: %v", err)
+	}
+
+	checkStagedFiles(mds, dest, map[string]string{"a.txt": "embedded", "b.txt": "from url"}, t)
+	if _, hash := MustExtractFilePayload(mds[1]); hash != sha256Hex("from url") {
+		t.Errorf("staged sha256 = %v, want %v", hash, sha256Hex("from url"))
+	}
+}
+
+func TestNewRetrieveWithBadSHA256(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "corrupt")
+	}))
+	defer srv.Close()
+
+	dest := makeTempDir(t)
+	defer os.RemoveAll(dest)
+
+	a := artifact{
+		dep: &pipepb.ArtifactInformation{
+			TypeUrn:     URNUrlArtifact,
+			TypePayload: protox.MustEncode(&pipepb.ArtifactUrlPayload{Url: srv.URL + "/a.txt"}),
+		},
+		path:   "a.txt",
+		sha256: sha256Hex("expected"),
+	}
+	if err := a.retrieve(context.Background(), dest); err == nil {
+		t.Errorf("retrieve of corrupt artifact succeeded, want a bad SHA256 error")
+	}
+}
+
+func TestNewRetrieveWithDeferred(t *testing.T) {
+	client := &fakeRetrievalService{}
+	deps := []*pipepb.ArtifactInformation{{TypeUrn: URNDeferredArtifact, RoleUrn: URNStagingTo}}
+
+	dest := makeTempDir(t)
+	defer os.RemoveAll(dest)
+	ctx := grpcx.WriteWorkerID(context.Background(), "worker")
+
+	if _, err := newMaterializeWithClient(ctx, client, deps, dest); err == nil {
+		t.Errorf("materialize of unresolved deferred artifact succeeded, want error")
+	}
+}
+
+func sha256Hex(data string) string {
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
 func checkStagedFiles(mds []*pipepb.ArtifactInformation, dest string, expected map[string]string, t *testing.T) {
 	if len(mds) != len(expected) {
 		t.Errorf("wrong number of artifacts staged %v vs %v", len(mds), len(expected))
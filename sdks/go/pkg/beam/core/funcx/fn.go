@@ -20,6 +20,8 @@ import (
 	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
 	"reflect"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/state"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/timers"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
@@ -70,6 +72,15 @@ const (
 	// FnRTracker indicates a function input parameter that implements
 	// sdf.RTracker.
 	FnRTracker FnParamKind = 0x100
+	// FnStateProvider indicates a function input parameter of type
+	// state.Provider.
+	FnStateProvider FnParamKind = 0x200
+	// FnTimerProvider indicates a function input parameter of type
+	// timers.Provider.
+	FnTimerProvider FnParamKind = 0x400
+	// FnBundleFinalization indicates a function input parameter of type
+	// typex.BundleFinalization.
+	FnBundleFinalization FnParamKind = 0x800
 )
 
 func (k FnParamKind) String() string {
@@ -92,6 +103,12 @@ func (k FnParamKind) String() string {
 		return "Window"
 	case FnRTracker:
 		return "RTracker"
+	case FnStateProvider:
+		return "StateProvider"
+	case FnTimerProvider:
+		return "TimerProvider"
+	case FnBundleFinalization:
+		return "BundleFinalization"
 	default:
 		return fmt.Sprintf("%v", int(k))
 	}
@@ -245,6 +262,39 @@ func (u *Fn) RTracker() (pos int, exists bool) {
 	return -1, false
 }
 
+// StateProvider returns (index, true) iff the function expects a
+// state.Provider.
+func (u *Fn) StateProvider() (pos int, exists bool) {
+	for i, p := range u.Param {
+		if p.Kind == FnStateProvider {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// TimerProvider returns (index, true) iff the function expects a
+// timers.Provider.
+func (u *Fn) TimerProvider() (pos int, exists bool) {
+	for i, p := range u.Param {
+		if p.Kind == FnTimerProvider {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// BundleFinalization returns (index, true) iff the function expects a
+// typex.BundleFinalization.
+func (u *Fn) BundleFinalization() (pos int, exists bool) {
+	for i, p := range u.Param {
+		if p.Kind == FnBundleFinalization {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
 // Error returns (index, true) iff the function returns an error.
 func (u *Fn) Error() (pos int, exists bool) {
 	for i, p := range u.Ret {
@@ -291,6 +341,11 @@ func (u *Fn) String() string {
 	return fmt.Sprintf("{Fn:{Name:%v Kind:%v} Param:%+v Ret:%+v}", u.Fn.Name(), u.Fn.Type(), u.Param, u.Ret)
 }
 
+var (
+	stateProviderType = reflect.TypeOf((*state.Provider)(nil)).Elem()
+	timerProviderType = reflect.TypeOf((*timers.Provider)(nil)).Elem()
+)
+
 // New returns a Fn from a user function, if valid. Closures and dynamically
 // created functions are considered valid here, but will be rejected if they
 // are attempted to be serialized.
@@ -309,8 +364,14 @@ func New(fn reflectx.Func) (*Fn, error) {
 			kind = FnWindow
 		case t == reflectx.Type:
 			kind = FnType
+		case t == typex.BundleFinalizationType:
+			kind = FnBundleFinalization
 		case t.Implements(reflect.TypeOf((*sdf.RTracker)(nil)).Elem()):
 			kind = FnRTracker
+		case t == stateProviderType:
+			kind = FnStateProvider
+		case t == timerProviderType:
+			kind = FnTimerProvider
 		case typex.IsContainer(t), typex.IsConcrete(t), typex.IsUniversal(t):
 			kind = FnValue
 		case IsEmit(t):
@@ -376,7 +437,7 @@ func SubReturns(list []ReturnParam, indices ...int) []ReturnParam {
 }
 
 // The order of present parameters and return values must be as follows:
-// func(FnContext?, FnWindow?, FnEventTime?, FnType?, FnRTracker?, (FnValue, SideInput*)?, FnEmit*) (RetEventTime?, RetOutput?, RetError?)
+// func(FnContext?, FnWindow?, FnEventTime?, FnType?, FnBundleFinalization?, FnRTracker?, FnStateProvider?, FnTimerProvider?, (FnValue, SideInput*)?, FnEmit*) (RetEventTime?, RetOutput?, RetError?)
 //     where ? indicates 0 or 1, and * indicates any number.
 //     and  a SideInput is one of FnValue or FnIter or FnReIter
 // Note: Fns with inputs must have at least one FnValue as the main input.
@@ -400,12 +461,15 @@ func validateOrder(u *Fn) error {
 }
 
 var (
-	errContextParam             = errors.New("may only have a single context.Context parameter and it must be the first parameter")
-	errWindowParamPrecedence    = errors.New("may only have a single Window parameter and it must precede the EventTime and main input parameter")
-	errEventTimeParamPrecedence = errors.New("may only have a single beam.EventTime parameter and it must precede the main input parameter")
-	errReflectTypePrecedence    = errors.New("may only have a single reflect.Type parameter and it must precede the main input parameter")
-	errRTrackerPrecedence       = errors.New("may only have a single sdf.RTracker parameter and it must precede the main input parameter")
-	errInputPrecedence          = errors.New("inputs parameters must precede emit function parameters")
+	errContextParam                 = errors.New("may only have a single context.Context parameter and it must be the first parameter")
+	errWindowParamPrecedence        = errors.New("may only have a single Window parameter and it must precede the EventTime and main input parameter")
+	errEventTimeParamPrecedence     = errors.New("may only have a single beam.EventTime parameter and it must precede the main input parameter")
+	errReflectTypePrecedence        = errors.New("may only have a single reflect.Type parameter and it must precede the main input parameter")
+	errBundleFinalizationPrecedence = errors.New("may only have a single beam.BundleFinalization parameter and it must precede the sdf.RTracker and main input parameters")
+	errRTrackerPrecedence           = errors.New("may only have a single sdf.RTracker parameter and it must precede the main input parameter")
+	errStateProviderPrecedence      = errors.New("may only have a single state.Provider parameter and it must precede the timers.Provider and main input parameters")
+	errTimerProviderPrecedence      = errors.New("may only have a single timers.Provider parameter and it must precede the main input parameter")
+	errInputPrecedence              = errors.New("inputs parameters must precede emit function parameters")
 )
 
 type paramState int
@@ -419,6 +483,9 @@ const (
 	psInput
 	psOutput
 	psRTracker
+	psStateProvider
+	psTimerProvider
+	psBundleFinalization
 )
 
 func nextParamState(cur paramState, transition FnParamKind) (paramState, error) {
@@ -433,8 +500,14 @@ func nextParamState(cur paramState, transition FnParamKind) (paramState, error)
 			return psEventTime, nil
 		case FnType:
 			return psType, nil
+		case FnBundleFinalization:
+			return psBundleFinalization, nil
 		case FnRTracker:
 			return psRTracker, nil
+		case FnStateProvider:
+			return psStateProvider, nil
+		case FnTimerProvider:
+			return psTimerProvider, nil
 		}
 	case psContext:
 		switch transition {
@@ -444,8 +517,14 @@ func nextParamState(cur paramState, transition FnParamKind) (paramState, error)
 			return psEventTime, nil
 		case FnType:
 			return psType, nil
+		case FnBundleFinalization:
+			return psBundleFinalization, nil
 		case FnRTracker:
 			return psRTracker, nil
+		case FnStateProvider:
+			return psStateProvider, nil
+		case FnTimerProvider:
+			return psTimerProvider, nil
 		}
 	case psWindow:
 		switch transition {
@@ -453,22 +532,61 @@ func nextParamState(cur paramState, transition FnParamKind) (paramState, error)
 			return psEventTime, nil
 		case FnType:
 			return psType, nil
+		case FnBundleFinalization:
+			return psBundleFinalization, nil
 		case FnRTracker:
 			return psRTracker, nil
+		case FnStateProvider:
+			return psStateProvider, nil
+		case FnTimerProvider:
+			return psTimerProvider, nil
 		}
 	case psEventTime:
 		switch transition {
 		case FnType:
 			return psType, nil
+		case FnBundleFinalization:
+			return psBundleFinalization, nil
 		case FnRTracker:
 			return psRTracker, nil
+		case FnStateProvider:
+			return psStateProvider, nil
+		case FnTimerProvider:
+			return psTimerProvider, nil
 		}
 	case psType:
 		switch transition {
+		case FnBundleFinalization:
+			return psBundleFinalization, nil
 		case FnRTracker:
 			return psRTracker, nil
+		case FnStateProvider:
+			return psStateProvider, nil
+		case FnTimerProvider:
+			return psTimerProvider, nil
+		}
+	case psBundleFinalization:
+		switch transition {
+		case FnRTracker:
+			return psRTracker, nil
+		case FnStateProvider:
+			return psStateProvider, nil
+		case FnTimerProvider:
+			return psTimerProvider, nil
 		}
 	case psRTracker:
+		switch transition {
+		case FnStateProvider:
+			return psStateProvider, nil
+		case FnTimerProvider:
+			return psTimerProvider, nil
+		}
+	case psStateProvider:
+		switch transition {
+		case FnTimerProvider:
+			return psTimerProvider, nil
+		}
+	case psTimerProvider:
 		// Completely handled by the default clause
 	case psInput:
 		switch transition {
@@ -491,8 +609,14 @@ func nextParamState(cur paramState, transition FnParamKind) (paramState, error)
 		return -1, errEventTimeParamPrecedence
 	case FnType:
 		return -1, errReflectTypePrecedence
+	case FnBundleFinalization:
+		return -1, errBundleFinalizationPrecedence
 	case FnRTracker:
 		return -1, errRTrackerPrecedence
+	case FnStateProvider:
+		return -1, errStateProviderPrecedence
+	case FnTimerProvider:
+		return -1, errTimerProviderPrecedence
 	case FnIter, FnReIter, FnValue:
 		return psInput, nil
 	case FnEmit:
@@ -22,6 +22,9 @@ import (
 	"testing"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/state"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/timers"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
 )
@@ -75,6 +78,16 @@ func TestNew(t *testing.T) {
 			Fn:    func(typex.Window, typex.EventTime, reflect.Type, []byte) {},
 			Param: []FnParamKind{FnWindow, FnEventTime, FnType, FnValue},
 		},
+		{
+			Name:  "good6",
+			Fn:    func(typex.Window, state.Provider, timers.Provider, string, int, func(int)) {},
+			Param: []FnParamKind{FnWindow, FnStateProvider, FnTimerProvider, FnValue, FnValue, FnEmit},
+		},
+		{
+			Name:  "good7",
+			Fn:    func(context.Context, typex.BundleFinalization, sdf.RTracker, []byte) {},
+			Param: []FnParamKind{FnContext, FnBundleFinalization, FnRTracker, FnValue},
+		},
 		{
 			Name:  "good-method",
 			Fn:    foo{1}.Do,
@@ -147,6 +160,26 @@ func TestNew(t *testing.T) {
 			},
 			Err: errReflectTypePrecedence,
 		},
+		{
+			Name: "errStateProviderPrecedence: after TimerProvider",
+			Fn:   func(timers.Provider, state.Provider, string, int) {},
+			Err:  errStateProviderPrecedence,
+		},
+		{
+			Name: "errStateProviderPrecedence: after value",
+			Fn:   func(string, state.Provider, int) {},
+			Err:  errStateProviderPrecedence,
+		},
+		{
+			Name: "errTimerProviderPrecedence: after value",
+			Fn:   func(string, int, timers.Provider) {},
+			Err:  errTimerProviderPrecedence,
+		},
+		{
+			Name: "errBundleFinalizationPrecedence: after RTracker",
+			Fn:   func(sdf.RTracker, typex.BundleFinalization, []byte) {},
+			Err:  errBundleFinalizationPrecedence,
+		},
 		{
 			Name: "errInputPrecedence- Iter before after output",
 			Fn:   func(int, func(int), func(*int) bool, func(*int, *string) bool) {},
@@ -66,3 +66,14 @@ func DecodeBytes(r io.Reader) ([]byte, error) {
 	}
 	return ioutilx.ReadN(r, (int)(size))
 }
+
+// DecodeBytesBorrowed decodes a length prefixed []byte like DecodeBytes, but
+// without copying the bytes if the reader lends them. The returned bytes must
+// not be modified, and must not be used after the next read from the reader.
+func DecodeBytesBorrowed(r io.Reader) ([]byte, error) {
+	size, err := DecodeVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	return ioutilx.ReadNBorrowed(r, (int)(size))
+}
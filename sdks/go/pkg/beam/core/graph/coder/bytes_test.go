@@ -59,3 +59,39 @@ func TestEncodeDecodeBytes(t *testing.T) {
 		})
 	}
 }
+
+// lendingReader lends the bytes of its buffer.
+type lendingReader struct {
+	*bytes.Buffer
+}
+
+func (r *lendingReader) Lend(n int) ([]byte, bool) {
+	if r.Len() < n {
+		return nil, false
+	}
+	return r.Next(n), true
+}
+
+func TestDecodeBytesBorrowed(t *testing.T) {
+	encoded := []byte{3, 1, 2, 3, 42}
+	r := &lendingReader{Buffer: bytes.NewBuffer(encoded)}
+	got, err := DecodeBytesBorrowed(r)
+	if err != nil {
+		t.Fatalf("DecodeBytesBorrowed(%v) = %v", encoded, err)
+	}
+	if d := cmp.Diff([]byte{1, 2, 3}, got); d != "" {
+		t.Errorf("DecodeBytesBorrowed(%v) diff(-want,+got):\n %v", encoded, d)
+	}
+	if &got[0] != &encoded[1] {
+		t.Errorf("DecodeBytesBorrowed(%v) copied the bytes, want them borrowed", encoded)
+	}
+
+	// Readers that don't lend their bytes are read from.
+	got, err = DecodeBytesBorrowed(bytes.NewBuffer(encoded))
+	if err != nil {
+		t.Fatalf("DecodeBytesBorrowed(%v) = %v", encoded, err)
+	}
+	if d := cmp.Diff([]byte{1, 2, 3}, got); d != "" {
+		t.Errorf("DecodeBytesBorrowed(%v) diff(-want,+got):\n %v", encoded, d)
+	}
+}
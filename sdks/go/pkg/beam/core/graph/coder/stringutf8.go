@@ -53,6 +53,12 @@ func encodeStringUTF8(s string, w io.Writer) error {
 
 // decodeStringUTF8 reads l bytes and produces a string.
 func decodeStringUTF8(l int64, r io.Reader) (string, error) {
+	// Strings are copied out of borrowed bytes in one allocation.
+	if lender, ok := r.(ioutilx.Lender); ok {
+		if b, ok := lender.Lend(int(l)); ok {
+			return string(b), nil
+		}
+	}
 	var builder strings.Builder
 	var b [bufCap]byte
 	i := l
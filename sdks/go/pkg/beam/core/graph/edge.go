@@ -153,14 +153,15 @@ type MultiEdge struct {
 	parent *Scope
 
 	Op               Opcode
-	DoFn             *DoFn              // ParDo
-	RestrictionCoder *coder.Coder       // SplittableParDo
-	CombineFn        *CombineFn         // Combine
-	AccumCoder       *coder.Coder       // Combine
-	Value            []byte             // Impulse
-	External         *ExternalTransform // Current External Transforms API
-	Payload          *Payload           // Legacy External Transforms API
-	WindowFn         *window.Fn         // WindowInto
+	DoFn             *DoFn                   // ParDo
+	RestrictionCoder *coder.Coder            // SplittableParDo
+	StateCoders      map[string]*coder.Coder // Stateful ParDo
+	CombineFn        *CombineFn              // Combine
+	AccumCoder       *coder.Coder            // Combine
+	Value            []byte                  // Impulse
+	External         *ExternalTransform      // Current External Transforms API
+	Payload          *Payload                // Legacy External Transforms API
+	WindowFn         *window.Fn              // WindowInto
 
 	Input  []*Inbound
 	Output []*Outbound
@@ -314,6 +315,21 @@ func NewCrossLanguage(g *Graph, s *Scope, ext *ExternalTransform, ins []*Inbound
 	return edge, isBoundedUpdater
 }
 
+// SetCrossLanguageOutputType changes the type of the output of the given index
+// of a cross-language edge, such as to the Go type of the schema of its
+// expanded output. It must be called before the output is consumed, as node
+// types are fixed otherwise.
+func SetCrossLanguageOutputType(e *MultiEdge, i int, t typex.FullType) {
+	if e.Op != External {
+		panic(fmt.Sprintf("edge %v is not a cross-language edge", e))
+	}
+	if !typex.IsBound(t) {
+		panic(fmt.Sprintf("Node type not bound: %v", t))
+	}
+	out := e.Output[i]
+	out.To.t, out.Type = t, t
+}
+
 // NamedInboundLinks returns an array of new Inbound links and a map (tag ->
 // index of Inbound in MultiEdge.Input) of corresponding indices with respect to
 // their names.
@@ -21,6 +21,7 @@ import (
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/funcx"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/state"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
@@ -162,6 +163,7 @@ const (
 	processElementName = "ProcessElement"
 	finishBundleName   = "FinishBundle"
 	teardownName       = "Teardown"
+	onTimerName        = "OnTimer"
 
 	createInitialRestrictionName = "CreateInitialRestriction"
 	splitRestrictionName         = "SplitRestriction"
@@ -183,6 +185,7 @@ var doFnNames = []string{
 	processElementName,
 	finishBundleName,
 	teardownName,
+	onTimerName,
 	createInitialRestrictionName,
 	splitRestrictionName,
 	restrictionSizeName,
@@ -250,6 +253,11 @@ func (f *DoFn) TeardownFn() *funcx.Fn {
 	return f.methods[teardownName]
 }
 
+// OnTimerFn returns the "OnTimer" function, if present.
+func (f *DoFn) OnTimerFn() *funcx.Fn {
+	return f.methods[onTimerName]
+}
+
 // Annotations returns the optional annotations of the DoFn, if present.
 func (f *DoFn) Annotations() map[string][]byte {
 	return f.annotations
@@ -268,6 +276,35 @@ func (f *DoFn) IsSplittable() bool {
 	return ok
 }
 
+// IsStateful returns whether the DoFn keeps state or sets timers, which its
+// ProcessElement method takes a state.Provider or a timers.Provider for.
+func (f *DoFn) IsStateful() bool {
+	processFn := f.methods[processElementName]
+	_, hasState := processFn.StateProvider()
+	_, hasTimers := processFn.TimerProvider()
+	return hasState || hasTimers
+}
+
+// RequestsFinalization returns whether the DoFn registers callbacks to run
+// once its bundles are committed, which its StartBundle, ProcessElement or
+// FinishBundle methods take a typex.BundleFinalization for.
+func (f *DoFn) RequestsFinalization() bool {
+	for _, name := range []string{startBundleName, processElementName, finishBundleName} {
+		if fn, ok := f.methods[name]; ok {
+			if _, ok := fn.BundleFinalization(); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// StateCells returns the state cells of the DoFn, which are the state.Value
+// and state.Bag fields of its struct.
+func (f *DoFn) StateCells() []state.Cell {
+	return state.Cells(f.Recv)
+}
+
 // SplittableDoFn represents a DoFn implementing SDF methods.
 type SplittableDoFn DoFn
 
@@ -484,6 +521,11 @@ func AsDoFn(fn *Fn, numMainIn mainInputs) (*DoFn, error) {
 		}
 	}
 
+	// Perform validation on the state and timers of stateful DoFns.
+	if err := validateStateful(fn, numMainIn, isSdf, processFnEmits); err != nil {
+		return nil, addContext(err, fn)
+	}
+
 	return (*DoFn)(fn), nil
 }
 
@@ -729,6 +771,112 @@ func validateIsSdf(fn *Fn) (bool, error) {
 	return isSdf, nil
 }
 
+// validateStateful validates the state and timers of a Fn. For a Fn to be
+// stateful, ProcessElement must:
+//   * Take a state.Provider or a timers.Provider before its main input.
+//   * Have a KV main input, as state and timers are kept per key.
+//   * Not be part of a splittable DoFn.
+// The Fn must declare state cells with distinct IDs if ProcessElement takes a
+// state.Provider, and have an OnTimer method if and only if it takes a
+// timers.Provider. OnTimer takes the key and the string family of the timer
+// as its only inputs, and the emitters of ProcessElement, and may only return
+// an error.
+func validateStateful(fn *Fn, numMainIn mainInputs, isSdf bool, processFnEmits []funcx.FnParam) error {
+	processFn := fn.methods[processElementName]
+	_, hasState := processFn.StateProvider()
+	_, hasTimers := processFn.TimerProvider()
+	onTimerFn, hasOnTimer := fn.methods[onTimerName]
+	if hasOnTimer && !hasTimers {
+		err := errors.Errorf("method %v present, but %v has no timers.Provider", onTimerName, processElementName)
+		return errors.SetTopLevelMsgf(err, "Method %v is present in DoFn %v, but %v has no "+
+			"timers.Provider parameter to set timers with.",
+			onTimerName, fn.Name(), processElementName)
+	}
+	if !hasState && !hasTimers {
+		return nil
+	}
+	if isSdf {
+		err := errors.Errorf("method %v has state or timers in a splittable DoFn", processElementName)
+		return errors.SetTopLevelMsgf(err, "Method %v of DoFn %v takes a state.Provider or a "+
+			"timers.Provider, but splittable DoFns cannot be stateful.",
+			processElementName, fn.Name())
+	}
+	if numMainIn == MainSingle {
+		err := errors.Errorf("method %v has state or timers without a KV main input", processElementName)
+		return errors.SetTopLevelMsgf(err, "Method %v of DoFn %v takes a state.Provider or a "+
+			"timers.Provider, but its main input is not a KV. State and timers are kept per key.",
+			processElementName, fn.Name())
+	}
+	if err := validateStateCells(fn, hasState); err != nil {
+		return err
+	}
+	if hasTimers && !hasOnTimer {
+		err := errors.Errorf("method %v has timers.Provider, but %v is missing", processElementName, onTimerName)
+		return errors.SetTopLevelMsgf(err, "Method %v of DoFn %v takes a timers.Provider, but the "+
+			"DoFn has no %v method to call when the timers fire.",
+			processElementName, fn.Name(), onTimerName)
+	}
+	if !hasOnTimer {
+		return nil
+	}
+
+	pos, _, _ := processFn.Inputs()
+	keyT := processFn.Param[pos].T
+	var inputs []funcx.FnParam
+	for _, p := range onTimerFn.Param {
+		switch p.Kind {
+		case funcx.FnContext, funcx.FnWindow, funcx.FnEventTime, funcx.FnStateProvider, funcx.FnTimerProvider, funcx.FnEmit:
+		case funcx.FnValue:
+			inputs = append(inputs, p)
+		default:
+			err := errors.Errorf("method %v has invalid parameter of kind %v", onTimerName, p.Kind)
+			return errors.SetTopLevelMsgf(err, "Method %v of DoFn %v has a parameter of type %v, "+
+				"but only takes the key, the family of the timer and emitters as inputs.",
+				onTimerName, fn.Name(), p.T)
+		}
+	}
+	if len(inputs) != 2 || inputs[0].T != keyT || inputs[1].T != reflectx.String {
+		err := errors.Errorf("method %v has inputs %v, expected the key of type %v and a string timer family",
+			onTimerName, inputs, keyT)
+		return errors.SetTopLevelMsgf(err, "Method %v of DoFn %v must take the key, of type %v as in "+
+			"%v, and the string family of the timer as its inputs.",
+			onTimerName, fn.Name(), keyT, processElementName)
+	}
+	if err := validateEmits(processFnEmits, onTimerFn, onTimerName); err != nil {
+		return err
+	}
+	if returns := onTimerFn.Ret; len(returns) > 1 || (len(returns) == 1 && returns[0].Kind != funcx.RetError) {
+		err := errors.Errorf("method %v has invalid return values, only allowed an optional error", onTimerName)
+		return errors.SetTopLevelMsgf(err, "Method %v of DoFns should have no return values other "+
+			"than an optional error, but invalid return values are present in DoFn %v.",
+			onTimerName, fn.Name())
+	}
+	return nil
+}
+
+// validateStateCells validates that a Fn taking a state.Provider declares
+// state cells, and that their IDs are distinct.
+func validateStateCells(fn *Fn, hasState bool) error {
+	cells := state.Cells(fn.Recv)
+	if hasState && len(cells) == 0 {
+		err := errors.Errorf("method %v has state.Provider, but no state cells are declared", processElementName)
+		return errors.SetTopLevelMsgf(err, "Method %v of DoFn %v takes a state.Provider, but the "+
+			"DoFn has no state.Value or state.Bag fields to keep state in.",
+			processElementName, fn.Name())
+	}
+	ids := make(map[string]bool)
+	for _, c := range cells {
+		if c.ID == "" || ids[c.ID] {
+			err := errors.Errorf("state %v has an empty or duplicate ID %q", c.Kind, c.ID)
+			return errors.SetTopLevelMsgf(err, "The state cells of DoFn %v must have distinct, "+
+				"non-empty IDs, but a state.%v has ID %q.",
+				fn.Name(), c.Kind, c.ID)
+		}
+		ids[c.ID] = true
+	}
+	return nil
+}
+
 // validateSdfSignatures validates that types in the SDF methods of a Fn are
 // consistent with each other (for example, element and restriction types should
 // match with each other). Returns an error if one is found, or nil if the
@@ -20,6 +20,8 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/state"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/timers"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 )
 
@@ -227,6 +229,82 @@ func TestNewDoFnSdf(t *testing.T) {
 	})
 }
 
+func TestNewDoFnStateful(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		tests := []struct {
+			dfn interface{}
+		}{
+			{dfn: &GoodStatefulDoFn{Count: state.Value{ID: "count"}}},
+			{dfn: newGoodStatefulDoFnTimers()},
+		}
+		for _, test := range tests {
+			t.Run(reflect.TypeOf(test.dfn).String(), func(t *testing.T) {
+				if _, err := NewDoFn(test.dfn); err != nil {
+					t.Fatalf("NewDoFn with state failed: %v", err)
+				}
+				fn, err := NewDoFn(test.dfn, NumMainInputs(MainKv))
+				if err != nil {
+					t.Fatalf("NewDoFn(NumMainInputs(MainKv)) with state failed: %v", err)
+				}
+				if !fn.IsStateful() {
+					t.Errorf("NewDoFn(%v).IsStateful() = false, want true", fn.Name())
+				}
+			})
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		tests := []struct {
+			dfn interface{}
+		}{
+			{dfn: func(state.Provider, int) {}},         // Main input is not a KV.
+			{dfn: func(state.Provider, string, int) {}}, // No state cells.
+			{dfn: &GoodStatefulDoFn{}},                  // Empty state ID.
+			{dfn: &BadStatefulDoFnDuplicateID{Count: state.Value{ID: "count"}, Buffer: state.Bag{ID: "count"}}},
+			{dfn: &BadStatefulDoFnMissingOnTimer{}},
+			{dfn: &BadStatefulDoFnOnTimerWithoutTimers{Count: state.Value{ID: "count"}}},
+			{dfn: &BadStatefulDoFnOnTimerKey{newGoodStatefulDoFnTimers()}},
+			{dfn: &BadStatefulDoFnOnTimerFamily{newGoodStatefulDoFnTimers()}},
+			{dfn: &BadStatefulDoFnOnTimerEmits{newGoodStatefulDoFnTimers()}},
+			{dfn: &BadStatefulDoFnOnTimerReturn{newGoodStatefulDoFnTimers()}},
+			{dfn: &BadStatefulSdf{}},
+		}
+		for _, test := range tests {
+			t.Run(reflect.TypeOf(test.dfn).String(), func(t *testing.T) {
+				if cfn, err := NewDoFn(test.dfn); err != nil {
+					t.Logf("NewDoFn with state failed as expected:\n%v", err)
+				} else {
+					t.Errorf("NewDoFn(%v) = %v, want failure", cfn.Name(), cfn)
+				}
+				if cfn, err := NewDoFn(test.dfn, NumMainInputs(MainKv)); err != nil {
+					t.Logf("NewDoFn(NumMainInputs(MainKv)) with state failed as expected:\n%v", err)
+				} else {
+					t.Errorf("NewDoFn(%v, NumMainInputs(MainKv)) = %v, want failure", cfn.Name(), cfn)
+				}
+			})
+		}
+	})
+}
+
+func TestDoFnRequestsFinalization(t *testing.T) {
+	tests := []struct {
+		dfn  interface{}
+		want bool
+	}{
+		{dfn: func(int) {}, want: false},
+		{dfn: func(typex.BundleFinalization, int) {}, want: true},
+		{dfn: &GoodFinalizingDoFn{}, want: true},
+	}
+	for _, test := range tests {
+		fn, err := NewDoFn(test.dfn)
+		if err != nil {
+			t.Fatalf("NewDoFn(%T) failed: %v", test.dfn, err)
+		}
+		if got := fn.RequestsFinalization(); got != test.want {
+			t.Errorf("NewDoFn(%v).RequestsFinalization() = %v, want %v", fn.Name(), got, test.want)
+		}
+	}
+}
+
 func TestNewCombineFn(t *testing.T) {
 	t.Run("valid", func(t *testing.T) {
 		tests := []struct {
@@ -868,6 +946,100 @@ func (fn *BadSdfMismatchedRTracker) ProcessElement(*OtherRTrackerT, int) int {
 	return 0
 }
 
+// GoodFinalizingDoFn registers bundle finalization callbacks in FinishBundle.
+type GoodFinalizingDoFn struct{}
+
+func (fn *GoodFinalizingDoFn) ProcessElement(int) {}
+
+func (fn *GoodFinalizingDoFn) FinishBundle(typex.BundleFinalization) {}
+
+// Examples of correct stateful DoFn signatures
+
+type GoodStatefulDoFn struct {
+	Count state.Value
+}
+
+func (fn *GoodStatefulDoFn) ProcessElement(state.Provider, string, int) int {
+	return 0
+}
+
+type GoodStatefulDoFnTimers struct {
+	Buffer state.Bag
+}
+
+func newGoodStatefulDoFnTimers() *GoodStatefulDoFnTimers {
+	return &GoodStatefulDoFnTimers{Buffer: state.Bag{ID: "buffer"}}
+}
+
+func (fn *GoodStatefulDoFnTimers) ProcessElement(typex.Window, state.Provider, timers.Provider, string, int, func(int)) {
+}
+
+func (fn *GoodStatefulDoFnTimers) OnTimer(context.Context, typex.EventTime, state.Provider, timers.Provider, string, string, func(int)) error {
+	return nil
+}
+
+// Examples of incorrect stateful DoFn signatures
+
+type BadStatefulDoFnMissingOnTimer struct{}
+
+func (fn *BadStatefulDoFnMissingOnTimer) ProcessElement(timers.Provider, string, int) {
+}
+
+type BadStatefulDoFnDuplicateID struct {
+	Count  state.Value
+	Buffer state.Bag
+}
+
+func (fn *BadStatefulDoFnDuplicateID) ProcessElement(state.Provider, string, int) {
+}
+
+type BadStatefulDoFnOnTimerWithoutTimers struct {
+	Count state.Value
+}
+
+func (fn *BadStatefulDoFnOnTimerWithoutTimers) ProcessElement(state.Provider, string, int) {
+}
+
+func (fn *BadStatefulDoFnOnTimerWithoutTimers) OnTimer(string, string) {
+}
+
+type BadStatefulDoFnOnTimerKey struct {
+	*GoodStatefulDoFnTimers
+}
+
+func (fn *BadStatefulDoFnOnTimerKey) OnTimer(int, string, func(int)) {
+}
+
+type BadStatefulDoFnOnTimerFamily struct {
+	*GoodStatefulDoFnTimers
+}
+
+func (fn *BadStatefulDoFnOnTimerFamily) OnTimer(string, func(int)) {
+}
+
+type BadStatefulDoFnOnTimerEmits struct {
+	*GoodStatefulDoFnTimers
+}
+
+func (fn *BadStatefulDoFnOnTimerEmits) OnTimer(string, string, func(string)) {
+}
+
+type BadStatefulDoFnOnTimerReturn struct {
+	*GoodStatefulDoFnTimers
+}
+
+func (fn *BadStatefulDoFnOnTimerReturn) OnTimer(string, string, func(int)) int {
+	return 0
+}
+
+type BadStatefulSdf struct {
+	*GoodSdfKv
+}
+
+func (fn *BadStatefulSdf) ProcessElement(*RTrackerT, state.Provider, int, int) int {
+	return 0
+}
+
 // Examples of correct CombineFn signatures
 
 type MyAccum struct{}
@@ -28,7 +28,8 @@ import (
 // must be a complete type, i.e., not include any type variables.
 type Node struct {
 	id int
-	// t is the type of underlying data and cannot change. It must be equal to
+	// t is the type of underlying data and cannot change, except for outputs
+	// of cross-language edges before they are consumed. It must be equal to
 	// the coder type. The type must be bound, i.e., it cannot contain any
 	// type variables.
 	t typex.FullType
@@ -16,6 +16,7 @@
 package graph
 
 import (
+	"crypto/tls"
 	"math/rand"
 	"strings"
 	"time"
@@ -66,9 +67,20 @@ type ExternalTransform struct {
 	Urn           string
 	Payload       []byte
 	ExpansionAddr string
+	// ExpansionTLS, if set, secures the connections to the expansion service
+	// at ExpansionAddr, which are insecure otherwise. It does not apply to the
+	// services started for managed addresses, which only listen locally.
+	ExpansionTLS *tls.Config
+	// ExpansionHeaders are sent as gRPC metadata with the requests to the
+	// expansion service, such as to authorize them.
+	ExpansionHeaders map[string]string
 
 	InputsMap  map[string]int
 	OutputsMap map[string]int
+	// AllowUnconsumedOutputs permits the expanded transform to have named
+	// outputs missing from OutputsMap, such as the results of writes, which
+	// are left unconsumed. Otherwise, every output must be requested.
+	AllowUnconsumedOutputs bool
 
 	Expanded *ExpandedTransform
 }
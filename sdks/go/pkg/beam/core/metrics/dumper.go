@@ -74,6 +74,17 @@ func dumperExtractor(store *Store, p func(format string, args ...interface{})) {
 		GaugeInt64: func(l Labels, v int64, t time.Time) {
 			m[l] = &gauge{v: v, t: t}
 		},
+		StringSet: func(l Labels, vs []string) {
+			s := &stringSet{}
+			s.add(vs...)
+			m[l] = s
+		},
+		HistogramInt64: func(l Labels, v HistogramValue) {
+			m[l] = &histogram{v: v}
+		},
+		BoundedTrie: func(l Labels, v BoundedTrieValue) {
+			m[l] = &boundedTrie{root: v.trie()}
+		},
 	}
 	e.ExtractFrom(store)
 	dumpTo(m, p)
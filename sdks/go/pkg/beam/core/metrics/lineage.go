@@ -0,0 +1,92 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Lineage metrics report the data sources read and the data sinks written by
+// the IOs of a pipeline, so that data catalogs can track the lineage of the
+// data of a pipeline. They are StringSets of the fully qualified names of the
+// sources and sinks, in the lineage namespace.
+const (
+	LineageNamespace = "lineage"
+	LineageSources   = "sources"
+	LineageSinks     = "sinks"
+)
+
+var (
+	lineageSources = NewStringSet(LineageNamespace, LineageSources)
+	lineageSinks   = NewStringSet(LineageNamespace, LineageSinks)
+)
+
+// AddLineageSource reports that the transform of the context reads from the
+// source with the given fully qualified name, such as those created by
+// LineageFQN.
+func AddLineageSource(ctx context.Context, fqn string) {
+	lineageSources.Add(ctx, fqn)
+}
+
+// AddLineageSink reports that the transform of the context writes to the
+// sink with the given fully qualified name, such as those created by
+// LineageFQN.
+func AddLineageSink(ctx context.Context, fqn string) {
+	lineageSinks.Add(ctx, fqn)
+}
+
+// LineageFQN returns the fully qualified name of a source or sink of the
+// given system, such as "bigquery" or "gcs", from the segments of its name
+// within the system, such as "bigquery:project.dataset.table". Segments that
+// have reserved characters are quoted with backticks.
+func LineageFQN(system string, segments ...string) string {
+	var sb strings.Builder
+	sb.WriteString(system)
+	sb.WriteByte(':')
+	for i, s := range segments {
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		if strings.ContainsAny(s, ":. \t\n\r") {
+			sb.WriteByte('`')
+			sb.WriteString(s)
+			sb.WriteByte('`')
+		} else {
+			sb.WriteString(s)
+		}
+	}
+	return sb.String()
+}
+
+// LineageResults returns the fully qualified names of the sources or sinks
+// reported by the lineage metrics of the results, given LineageSources or
+// LineageSinks, sorted and without duplicates.
+func LineageResults(r Results, kind string) []string {
+	seen := make(map[string]bool)
+	var ret []string
+	for _, s := range r.Query(Filter{Namespace: LineageNamespace, Name: kind}).StringSets() {
+		for _, v := range s.Result() {
+			if !seen[v] {
+				seen[v] = true
+				ret = append(ret, v)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
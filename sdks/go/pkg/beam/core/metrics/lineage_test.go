@@ -0,0 +1,59 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLineageFQN(t *testing.T) {
+	tests := []struct {
+		system   string
+		segments []string
+		want     string
+	}{
+		{"bigquery", []string{"project", "dataset", "table"}, "bigquery:project.dataset.table"},
+		{"gcs", []string{"bucket", "dir/file.txt"}, "gcs:bucket.`dir/file.txt`"},
+		{"filesystem", []string{"localhost", "/tmp/my file"}, "filesystem:localhost.`/tmp/my file`"},
+		{"kafka", nil, "kafka:"},
+	}
+	for _, test := range tests {
+		if got := LineageFQN(test.system, test.segments...); got != test.want {
+			t.Errorf("LineageFQN(%v, %v) = %v, want %v", test.system, test.segments, got, test.want)
+		}
+	}
+}
+
+func TestLineageResults(t *testing.T) {
+	ctx := SetBundleID(context.Background(), bID)
+	ctxA := SetPTransformID(ctx, "A")
+	ctxB := SetPTransformID(ctx, "B")
+	AddLineageSource(ctxA, "gcs:bucket.in")
+	AddLineageSource(ctxB, "bigquery:p.d.t")
+	AddLineageSource(ctxB, "gcs:bucket.in")
+	AddLineageSink(ctxB, "gcs:bucket.out")
+
+	r := ResultsFromStore(GetStore(ctx))
+	if got, want := LineageResults(r, LineageSources), []string{"bigquery:p.d.t", "gcs:bucket.in"}; !cmp.Equal(got, want) {
+		t.Errorf("LineageResults(sources) = %v, want %v", got, want)
+	}
+	if got, want := LineageResults(r, LineageSinks), []string{"gcs:bucket.out"}; !cmp.Equal(got, want) {
+		t.Errorf("LineageResults(sinks) = %v, want %v", got, want)
+	}
+}
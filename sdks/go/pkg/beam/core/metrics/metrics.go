@@ -50,6 +50,10 @@ import (
 	"context"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -66,8 +70,9 @@ import (
 type ctxKey string
 
 const (
-	counterSetKey ctxKey = "beam:counterset"
-	storeKey      ctxKey = "beam:bundlestore"
+	counterSetKey   ctxKey = "beam:counterset"
+	storeKey        ctxKey = "beam:bundlestore"
+	ptransformIDKey ctxKey = "beam:ptransformid"
 )
 
 // beamCtx is a caching context for IDs necessary to place metric updates.
@@ -97,6 +102,9 @@ func (ctx *beamCtx) Value(key interface{}) interface{} {
 					counters:      make(map[nameHash]*counter),
 					distributions: make(map[nameHash]*distribution),
 					gauges:        make(map[nameHash]*gauge),
+					stringSets:    make(map[nameHash]*stringSet),
+					histograms:    make(map[nameHash]*histogram),
+					boundedTries:  make(map[nameHash]*boundedTrie),
 				}
 				ctx.store.css = append(ctx.store.css, cs)
 				ctx.cs = cs
@@ -111,6 +119,11 @@ func (ctx *beamCtx) Value(key interface{}) interface{} {
 			}
 		}
 		return ctx.store
+	case ptransformIDKey:
+		if ctx.ptransformID == "" {
+			return nil
+		}
+		return ctx.ptransformID
 	}
 	return ctx.Context.Value(key)
 }
@@ -154,6 +167,16 @@ func GetStore(ctx context.Context) *Store {
 	return nil
 }
 
+// GetPTransformID returns the id of the PTransform the given context is for,
+// if any.
+func GetPTransformID(ctx context.Context) (string, bool) {
+	if bctx, ok := ctx.(*beamCtx); ok {
+		return bctx.ptransformID, bctx.ptransformID != ""
+	}
+	id, ok := ctx.Value(ptransformIDKey).(string)
+	return id, ok
+}
+
 const (
 	bundleIDUnset     = "(bundle id unset)"
 	ptransformIDUnset = "(ptransform id unset)"
@@ -178,6 +201,9 @@ const (
 	kindSumCounter
 	kindDistribution
 	kindGauge
+	kindStringSet
+	kindHistogram
+	kindBoundedTrie
 )
 
 func (t kind) String() string {
@@ -188,6 +214,12 @@ func (t kind) String() string {
 		return "Distribution"
 	case kindGauge:
 		return "Gauge"
+	case kindStringSet:
+		return "StringSet"
+	case kindHistogram:
+		return "Histogram"
+	case kindBoundedTrie:
+		return "BoundedTrie"
 	default:
 		panic(fmt.Sprintf("Unknown metric type value: %v", uint8(t)))
 	}
@@ -386,14 +418,16 @@ type DistributionValue struct {
 	Count, Sum, Min, Max int64
 }
 
-// Gauge is a time, value pair metric.
+// Gauge is a time, value pair metric. It reports the latest value set, with
+// the time it was set, and is aggregated across bundles and workers by
+// keeping the most recent value.
 type Gauge struct {
 	name name
 	hash nameHash
 }
 
 func (m *Gauge) String() string {
-	return fmt.Sprintf("Guage metric %s", m.name)
+	return fmt.Sprintf("Gauge metric %s", m.name)
 }
 
 // NewGauge returns the Gauge with the given namespace and name.
@@ -460,12 +494,574 @@ type GaugeValue struct {
 	Timestamp time.Time
 }
 
+// StringSet is a metric reporting the set of distinct strings added to it, such
+// as the tables or files touched by a transform. It is aggregated across bundles
+// and workers by taking the union of the sets.
+//
+// The set is bounded: once the strings of a set reach 1 MiB in total,
+// further strings are dropped.
+type StringSet struct {
+	name name
+	hash nameHash
+}
+
+func (m *StringSet) String() string {
+	return fmt.Sprintf("StringSet metric %s", m.name)
+}
+
+// NewStringSet returns the StringSet with the given namespace and name.
+func NewStringSet(ns, n string) *StringSet {
+	return &StringSet{
+		name: newName(ns, n),
+		hash: hashName(ns, n),
+	}
+}
+
+// Add adds the given string to the set within the given PTransform context.
+func (m *StringSet) Add(ctx context.Context, v string) {
+	cs := getCounterSet(ctx)
+	if cs == nil {
+		return
+	}
+	if s, ok := cs.stringSets[m.hash]; ok {
+		s.add(v)
+		return
+	}
+	// We're the first to create this metric!
+	s := &stringSet{}
+	s.add(v)
+	cs.stringSets[m.hash] = s
+	GetStore(ctx).storeMetric(cs.pid, m.name, s)
+}
+
+// maxStringSetSize is the maximum total size in bytes of the strings of
+// a StringSet, to bound the size of the metric reported to the runner.
+const maxStringSetSize = 1 << 20
+
+// stringSet is a metric cell for string set values.
+type stringSet struct {
+	mu   sync.Mutex
+	set  map[string]struct{}
+	size int
+}
+
+func (m *stringSet) add(vs ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.set == nil {
+		m.set = make(map[string]struct{})
+	}
+	for _, v := range vs {
+		if _, ok := m.set[v]; ok {
+			continue
+		}
+		if m.size+len(v) > maxStringSetSize {
+			continue
+		}
+		m.set[v] = struct{}{}
+		m.size += len(v)
+	}
+}
+
+func (m *stringSet) kind() kind {
+	return kindStringSet
+}
+
+func (m *stringSet) String() string {
+	return fmt.Sprintf("%v values: %q", m.kind(), m.get())
+}
+
+// get returns the strings of the set in sorted order.
+func (m *stringSet) get() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vs := make([]string, 0, len(m.set))
+	for v := range m.set {
+		vs = append(vs, v)
+	}
+	sort.Strings(vs)
+	return vs
+}
+
+// Histogram is a metric counting the values reported to it in buckets, which
+// unlike a Distribution allows estimating percentiles of the values, such as
+// those of request latencies.
+type Histogram struct {
+	name    name
+	hash    nameHash
+	buckets Buckets
+}
+
+func (m *Histogram) String() string {
+	return fmt.Sprintf("Histogram metric %s", m.name)
+}
+
+// NewHistogram returns the Histogram with the given namespace, name and
+// buckets. It panics if the buckets are invalid.
+func NewHistogram(ns, n string, b Buckets) *Histogram {
+	if err := b.validate(); err != nil {
+		panic(fmt.Sprintf("invalid buckets for histogram %s.%s: %v", ns, n, err))
+	}
+	return &Histogram{
+		name:    newName(ns, n),
+		hash:    hashName(ns, n),
+		buckets: b,
+	}
+}
+
+// Update adds v to the histogram within the given PTransform context.
+func (m *Histogram) Update(ctx context.Context, v int64) {
+	cs := getCounterSet(ctx)
+	if cs == nil {
+		return
+	}
+	if h, ok := cs.histograms[m.hash]; ok {
+		h.update(v)
+		return
+	}
+	// We're the first to create this metric!
+	h := &histogram{v: HistogramValue{Buckets: m.buckets, Counts: make([]int64, m.buckets.N)}}
+	h.update(v)
+	cs.histograms[m.hash] = h
+	GetStore(ctx).storeMetric(cs.pid, m.name, h)
+}
+
+// Buckets are the buckets of a Histogram. Besides its N buckets, a histogram
+// has an underflow and an overflow bucket for the values below and above them.
+type Buckets struct {
+	// Exponential is whether the bucket widths grow exponentially,
+	// rather than being constant.
+	Exponential bool
+	// Start is the lower bound of the first bucket.
+	Start float64
+	// Width is the width of linear buckets, or the factor by which the
+	// bounds of exponential buckets grow.
+	Width float64
+	// N is the number of buckets.
+	N int
+}
+
+// LinearBuckets returns n buckets of the given width, the first starting at start.
+func LinearBuckets(start, width float64, n int) Buckets {
+	return Buckets{Start: start, Width: width, N: n}
+}
+
+// ExponentialBuckets returns n buckets, the first starting at start > 0, and
+// whose bounds each grow by the given factor > 1.
+func ExponentialBuckets(start, factor float64, n int) Buckets {
+	return Buckets{Exponential: true, Start: start, Width: factor, N: n}
+}
+
+func (b Buckets) validate() error {
+	switch {
+	case b.N <= 0:
+		return fmt.Errorf("number of buckets must be positive, got %d", b.N)
+	case !b.Exponential && b.Width <= 0:
+		return fmt.Errorf("width of linear buckets must be positive, got %v", b.Width)
+	case b.Exponential && b.Start <= 0:
+		return fmt.Errorf("start of exponential buckets must be positive, got %v", b.Start)
+	case b.Exponential && b.Width <= 1:
+		return fmt.Errorf("factor of exponential buckets must be greater than 1, got %v", b.Width)
+	}
+	return nil
+}
+
+// Bound returns the lower bound of the i-th bucket, which is also the upper
+// bound of the previous one. Bound(N) is the upper bound of the last bucket.
+func (b Buckets) Bound(i int) float64 {
+	if b.Exponential {
+		return b.Start * math.Pow(b.Width, float64(i))
+	}
+	return b.Start + float64(i)*b.Width
+}
+
+// index returns the bucket of v, which is -1 for the underflow bucket and N
+// for the overflow bucket.
+func (b Buckets) index(v float64) int {
+	if v < b.Start {
+		return -1
+	}
+	var i float64
+	if b.Exponential {
+		i = math.Floor(math.Log(v/b.Start) / math.Log(b.Width))
+	} else {
+		i = math.Floor((v - b.Start) / b.Width)
+	}
+	if i >= float64(b.N) {
+		return b.N
+	}
+	// Rounding can put values at the bounds in the neighbouring bucket.
+	idx := int(i)
+	if v < b.Bound(idx) {
+		idx--
+	} else if idx+1 < b.N && v >= b.Bound(idx+1) {
+		idx++
+	}
+	return idx
+}
+
+// histogram is a metric cell for histogram values.
+type histogram struct {
+	mu sync.Mutex
+	v  HistogramValue
+}
+
+func (m *histogram) update(v int64) {
+	m.mu.Lock()
+	switch i := m.v.Buckets.index(float64(v)); {
+	case i < 0:
+		m.v.Underflow++
+	case i >= m.v.Buckets.N:
+		m.v.Overflow++
+	default:
+		m.v.Counts[i]++
+	}
+	m.mu.Unlock()
+}
+
+func (m *histogram) kind() kind {
+	return kindHistogram
+}
+
+func (m *histogram) String() string {
+	v := m.get()
+	return fmt.Sprintf("%v count: %d p50: %v p90: %v p99: %v", m.kind(), v.Count(), v.Percentile(50), v.Percentile(90), v.Percentile(99))
+}
+
+// get returns a copy of the histogram value.
+func (m *histogram) get() HistogramValue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v := m.v
+	v.Counts = append([]int64(nil), m.v.Counts...)
+	return v
+}
+
+// HistogramValue is the value of a Histogram metric.
+type HistogramValue struct {
+	Buckets Buckets
+	// Counts are the number of values in each of the buckets.
+	Counts []int64
+	// Underflow and Overflow are the number of values below and above the buckets.
+	Underflow, Overflow int64
+}
+
+// Count returns the number of values in the histogram.
+func (v HistogramValue) Count() int64 {
+	n := v.Underflow + v.Overflow
+	for _, c := range v.Counts {
+		n += c
+	}
+	return n
+}
+
+// Percentile returns an estimate of the p-th percentile of the values, for
+// p between 0 and 100, by linear interpolation within its bucket. Percentiles
+// in the underflow and overflow buckets are estimated as the lower and upper
+// bounds of the buckets. Returns NaN if the histogram is empty.
+func (v HistogramValue) Percentile(p float64) float64 {
+	n := v.Count()
+	if n == 0 {
+		return math.NaN()
+	}
+	rank := p / 100 * float64(n)
+	cum := float64(v.Underflow)
+	if rank <= cum && v.Underflow > 0 {
+		return v.Buckets.Bound(0)
+	}
+	for i, c := range v.Counts {
+		if c == 0 {
+			continue
+		}
+		if rank <= cum+float64(c) {
+			lo, hi := v.Buckets.Bound(i), v.Buckets.Bound(i+1)
+			return lo + (rank-cum)/float64(c)*(hi-lo)
+		}
+		cum += float64(c)
+	}
+	return v.Buckets.Bound(v.Buckets.N)
+}
+
+// merge adds the counts of o to v, if they have the same buckets.
+func (v *HistogramValue) merge(o HistogramValue) bool {
+	if v.Buckets != o.Buckets || len(v.Counts) != len(o.Counts) {
+		return false
+	}
+	for i, c := range o.Counts {
+		v.Counts[i] += c
+	}
+	v.Underflow += o.Underflow
+	v.Overflow += o.Overflow
+	return true
+}
+
+// BoundedTrie is a metric reporting the set of paths added to it, such as
+// the directories and files, or the datasets and tables, touched by a
+// transform. It is aggregated across bundles and workers by taking the union
+// of the paths.
+//
+// The trie is bounded: once it holds more than 100 paths, the largest subtrees
+// are truncated into a single path standing for all the paths under it, so
+// connectors touching many paths report them with bounded memory.
+type BoundedTrie struct {
+	name name
+	hash nameHash
+}
+
+func (m *BoundedTrie) String() string {
+	return fmt.Sprintf("BoundedTrie metric %s", m.name)
+}
+
+// NewBoundedTrie returns the BoundedTrie with the given namespace and name.
+func NewBoundedTrie(ns, n string) *BoundedTrie {
+	return &BoundedTrie{
+		name: newName(ns, n),
+		hash: hashName(ns, n),
+	}
+}
+
+// Add adds the path with the given segments to the trie within the given
+// PTransform context.
+func (m *BoundedTrie) Add(ctx context.Context, segments ...string) {
+	cs := getCounterSet(ctx)
+	if cs == nil {
+		return
+	}
+	if t, ok := cs.boundedTries[m.hash]; ok {
+		t.add(segments)
+		return
+	}
+	// We're the first to create this metric!
+	t := &boundedTrie{}
+	t.add(segments)
+	cs.boundedTries[m.hash] = t
+	GetStore(ctx).storeMetric(cs.pid, m.name, t)
+}
+
+// maxBoundedTrieSize is the maximum number of paths of a BoundedTrie, beyond
+// which its largest subtrees are truncated.
+const maxBoundedTrieSize = 100
+
+// boundedTrie is a metric cell for bounded trie values.
+type boundedTrie struct {
+	mu   sync.Mutex
+	root *trieNode
+}
+
+func (m *boundedTrie) add(segments []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.root == nil {
+		m.root = newTrieNode()
+	}
+	m.root.add(segments)
+	m.root.bound(maxBoundedTrieSize)
+}
+
+func (m *boundedTrie) kind() kind {
+	return kindBoundedTrie
+}
+
+func (m *boundedTrie) String() string {
+	return fmt.Sprintf("%v paths: %v", m.kind(), m.get().Paths)
+}
+
+func (m *boundedTrie) get() BoundedTrieValue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.root.value()
+}
+
+// trieNode is a node of a bounded trie, whose size is the number of paths
+// through it.
+type trieNode struct {
+	children  map[string]*trieNode
+	truncated bool
+	size      int
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{size: 1}
+}
+
+// add adds the path below the node, and returns the change of its size. A
+// path that is the prefix of other paths is subsumed by them.
+func (n *trieNode) add(segments []string) int {
+	if n.truncated || len(segments) == 0 {
+		return 0
+	}
+	if n.children == nil {
+		n.children = make(map[string]*trieNode)
+	}
+	delta := 0
+	child, ok := n.children[segments[0]]
+	if !ok {
+		if len(n.children) > 0 {
+			delta = 1
+		}
+		child = newTrieNode()
+		n.children[segments[0]] = child
+	}
+	delta += child.add(segments[1:])
+	n.size += delta
+	return delta
+}
+
+// merge adds the paths of o below the node, and returns the change of its
+// size.
+func (n *trieNode) merge(o *trieNode) int {
+	if n.truncated {
+		return 0
+	}
+	if o.truncated {
+		delta := 1 - n.size
+		n.children, n.truncated, n.size = nil, true, 1
+		return delta
+	}
+	if len(o.children) == 0 {
+		return 0
+	}
+	if len(n.children) == 0 {
+		n.children = make(map[string]*trieNode, len(o.children))
+		for k, c := range o.children {
+			n.children[k] = c.copy()
+		}
+		delta := o.size - n.size
+		n.size = o.size
+		return delta
+	}
+	delta := 0
+	for k, oc := range o.children {
+		if c, ok := n.children[k]; ok {
+			delta += c.merge(oc)
+		} else {
+			n.children[k] = oc.copy()
+			delta += oc.size
+		}
+	}
+	n.size += delta
+	return delta
+}
+
+func (n *trieNode) copy() *trieNode {
+	c := &trieNode{truncated: n.truncated, size: n.size}
+	if n.children != nil {
+		c.children = make(map[string]*trieNode, len(n.children))
+		for k, v := range n.children {
+			c.children[k] = v.copy()
+		}
+	}
+	return c
+}
+
+// trim truncates the largest subtree whose children are all leaves, and
+// returns the change of the size of the node.
+func (n *trieNode) trim() int {
+	if len(n.children) == 0 {
+		return 0
+	}
+	var largest *trieNode
+	for _, c := range n.children {
+		if largest == nil || c.size > largest.size {
+			largest = c
+		}
+	}
+	var delta int
+	if largest.size == 1 {
+		delta = 1 - n.size
+		n.children, n.truncated = nil, true
+	} else {
+		delta = largest.trim()
+	}
+	n.size += delta
+	return delta
+}
+
+// bound trims the trie until it has at most max paths.
+func (n *trieNode) bound(max int) {
+	for n.size > max {
+		n.trim()
+	}
+}
+
+// value returns the paths of the trie, sorted by their segments.
+func (n *trieNode) value() BoundedTrieValue {
+	var v BoundedTrieValue
+	if n == nil || (len(n.children) == 0 && !n.truncated) {
+		return v
+	}
+	n.paths(nil, &v.Paths)
+	return v
+}
+
+func (n *trieNode) paths(prefix []string, ps *[]BoundedTriePath) {
+	if n.truncated || len(n.children) == 0 {
+		*ps = append(*ps, BoundedTriePath{Segments: append([]string(nil), prefix...), Truncated: n.truncated})
+		return
+	}
+	keys := make([]string, 0, len(n.children))
+	for k := range n.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		n.children[k].paths(append(prefix, k), ps)
+	}
+}
+
+// BoundedTrieValue is the value of a BoundedTrie metric.
+type BoundedTrieValue struct {
+	// Paths are the paths of the trie, sorted by their segments.
+	Paths []BoundedTriePath
+}
+
+// BoundedTriePath is a path of a BoundedTrie.
+type BoundedTriePath struct {
+	Segments []string
+	// Truncated is whether the path stands for all the paths it is a prefix
+	// of, which were dropped to bound the trie.
+	Truncated bool
+}
+
+// Merge returns the union of the paths of the values, bounded like those of
+// a BoundedTrie.
+func (v BoundedTrieValue) Merge(o BoundedTrieValue) BoundedTrieValue {
+	root := v.trie()
+	root.merge(o.trie())
+	root.bound(maxBoundedTrieSize)
+	return root.value()
+}
+
+// trie returns the trie with the paths of the value.
+func (v BoundedTrieValue) trie() *trieNode {
+	root := newTrieNode()
+	for _, p := range v.Paths {
+		// Each path is merged as a trie of a single branch.
+		leaf := &trieNode{truncated: p.Truncated, size: 1}
+		path := leaf
+		for i := len(p.Segments) - 1; i >= 0; i-- {
+			path = &trieNode{children: map[string]*trieNode{p.Segments[i]: path}, size: 1}
+		}
+		root.merge(path)
+	}
+	return root
+}
+
 // Results represents all metrics gathered during the job's execution.
 // It allows for querying metrics using a provided filter.
+//
+// Each result has an attempted and a committed value. Committed values
+// include only the work that contributed to the results of the pipeline,
+// while attempted values also include work that was retried. Runners that
+// don't support committed metrics report attempted values only, so Result
+// methods fall back to the attempted value.
 type Results struct {
 	counters      []CounterResult
 	distributions []DistributionResult
 	gauges        []GaugeResult
+	stringSets    []StringSetResult
+	histograms    []HistogramResult
+	boundedTries  []BoundedTrieResult
 }
 
 // NewResults creates a new Results.
@@ -473,15 +1069,115 @@ func NewResults(
 	counters []CounterResult,
 	distributions []DistributionResult,
 	gauges []GaugeResult) *Results {
-	return &Results{counters, distributions, gauges}
+	return &Results{counters: counters, distributions: distributions, gauges: gauges}
+}
+
+// WithStringSets sets the string set results of the Results, and returns
+// them.
+func (mr *Results) WithStringSets(stringSets []StringSetResult) *Results {
+	mr.stringSets = stringSets
+	return mr
+}
+
+// WithHistograms sets the histogram results of the Results, and returns them.
+func (mr *Results) WithHistograms(histograms []HistogramResult) *Results {
+	mr.histograms = histograms
+	return mr
+}
+
+// WithBoundedTries sets the bounded trie results of the Results, and
+// returns them.
+func (mr *Results) WithBoundedTries(boundedTries []BoundedTrieResult) *Results {
+	mr.boundedTries = boundedTries
+	return mr
 }
 
 // AllMetrics returns all metrics from a Results instance.
 func (mr Results) AllMetrics() QueryResults {
-	return QueryResults{mr.counters, mr.distributions, mr.gauges}
+	return QueryResults{mr.counters, mr.distributions, mr.gauges, mr.stringSets, mr.histograms, mr.boundedTries}
+}
+
+// Query returns the metrics of a Results instance that match the filter.
+func (mr Results) Query(f Filter) QueryResults {
+	var qr QueryResults
+	for _, r := range mr.counters {
+		if f.Matches(r.Key) {
+			qr.counters = append(qr.counters, r)
+		}
+	}
+	for _, r := range mr.distributions {
+		if f.Matches(r.Key) {
+			qr.distributions = append(qr.distributions, r)
+		}
+	}
+	for _, r := range mr.gauges {
+		if f.Matches(r.Key) {
+			qr.gauges = append(qr.gauges, r)
+		}
+	}
+	for _, r := range mr.stringSets {
+		if f.Matches(r.Key) {
+			qr.stringSets = append(qr.stringSets, r)
+		}
+	}
+	for _, r := range mr.histograms {
+		if f.Matches(r.Key) {
+			qr.histograms = append(qr.histograms, r)
+		}
+	}
+	for _, r := range mr.boundedTries {
+		if f.Matches(r.Key) {
+			qr.boundedTries = append(qr.boundedTries, r)
+		}
+	}
+	return qr
 }
 
-// TODO(BEAM-11217): Implement Query(Filter) and metrics filtering
+// Filter selects metrics by their namespace, name and step. The empty
+// filter selects all metrics.
+type Filter struct {
+	// Namespace selects the metrics of the namespace, if set.
+	Namespace string
+	// Name selects the metrics with the name, if set.
+	Name string
+	// Steps select the metrics of any of the steps, if set. Steps are the
+	// paths of transforms within their composites, separated by '/', such
+	// as "CountWords/main.extractFn". A step selects the metrics of the
+	// steps it is a sub-path of, so a composite step, such as "CountWords",
+	// selects the metrics of all the transforms within it.
+	Steps []string
+}
+
+// Matches returns whether the filter selects the metric with the key.
+func (f Filter) Matches(k StepKey) bool {
+	if f.Namespace != "" && f.Namespace != k.Namespace {
+		return false
+	}
+	if f.Name != "" && f.Name != k.Name {
+		return false
+	}
+	if len(f.Steps) == 0 {
+		return true
+	}
+	for _, s := range f.Steps {
+		if subPathMatches(k.Step, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// subPathMatches returns whether the path components of sub are a contiguous
+// run of those of step.
+func subPathMatches(step, sub string) bool {
+	if sub == "" {
+		return false
+	}
+	return step == sub ||
+		strings.HasPrefix(step, sub+"/") ||
+		strings.HasSuffix(step, "/"+sub) ||
+		strings.Contains(step, "/"+sub+"/")
+}
 
 // QueryResults is the result of a query. Allows accessing all of the
 // metrics that matched the filter.
@@ -489,6 +1185,9 @@ type QueryResults struct {
 	counters      []CounterResult
 	distributions []DistributionResult
 	gauges        []GaugeResult
+	stringSets    []StringSetResult
+	histograms    []HistogramResult
+	boundedTries  []BoundedTrieResult
 }
 
 // Counters returns a slice of counter metrics.
@@ -512,43 +1211,65 @@ func (qr QueryResults) Gauges() []GaugeResult {
 	return out
 }
 
+// StringSets returns a slice of string set metrics.
+func (qr QueryResults) StringSets() []StringSetResult {
+	out := make([]StringSetResult, len(qr.stringSets))
+	copy(out, qr.stringSets)
+	return out
+}
+
+// Histograms returns a slice of histogram metrics.
+func (qr QueryResults) Histograms() []HistogramResult {
+	out := make([]HistogramResult, len(qr.histograms))
+	copy(out, qr.histograms)
+	return out
+}
+
+// BoundedTries returns a slice of bounded trie metrics.
+func (qr QueryResults) BoundedTries() []BoundedTrieResult {
+	out := make([]BoundedTrieResult, len(qr.boundedTries))
+	copy(out, qr.boundedTries)
+	return out
+}
+
 // CounterResult is an attempted and a commited value of a counter metric plus
 // key.
 type CounterResult struct {
 	Attempted, Committed int64
 	Key                  StepKey
+	// HasCommitted is whether the runner reported the committed value.
+	HasCommitted bool
 }
 
-// Result returns committed metrics. Falls back to attempted metrics if committed
-// are not populated (e.g. due to not being supported on a given runner).
+// Result returns the committed value, or the attempted value if the committed
+// one isn't populated.
 func (r CounterResult) Result() int64 {
-	if r.Committed != 0 {
+	if r.HasCommitted || r.Committed != 0 {
 		return r.Committed
 	}
 	return r.Attempted
 }
 
+// mergeKeys returns the keys of the attempted and committed metric values,
+// which are maps keyed by StepKey, and whether each key has a committed value.
+func mergeKeys(attempted, committed interface{}) map[StepKey]bool {
+	keys := make(map[StepKey]bool)
+	for _, k := range reflect.ValueOf(attempted).MapKeys() {
+		keys[k.Interface().(StepKey)] = false
+	}
+	for _, k := range reflect.ValueOf(committed).MapKeys() {
+		keys[k.Interface().(StepKey)] = true
+	}
+	return keys
+}
+
 // MergeCounters combines counter metrics that share a common key.
 func MergeCounters(
 	attempted map[StepKey]int64,
 	committed map[StepKey]int64) []CounterResult {
 	res := make([]CounterResult, 0)
-	merged := map[StepKey]CounterResult{}
-
-	for k, v := range attempted {
-		merged[k] = CounterResult{Attempted: v, Key: k}
-	}
-	for k, v := range committed {
-		m, ok := merged[k]
-		if ok {
-			merged[k] = CounterResult{Attempted: m.Attempted, Committed: v, Key: k}
-		} else {
-			merged[k] = CounterResult{Committed: v, Key: k}
-		}
-	}
-
-	for _, v := range merged {
-		res = append(res, v)
+	for k, hasCommitted := range mergeKeys(attempted, committed) {
+		res = append(res, CounterResult{Attempted: attempted[k], Committed: committed[k], Key: k, HasCommitted: hasCommitted})
 	}
 	return res
 }
@@ -558,13 +1279,15 @@ func MergeCounters(
 type DistributionResult struct {
 	Attempted, Committed DistributionValue
 	Key                  StepKey
+	// HasCommitted is whether the runner reported the committed value.
+	HasCommitted bool
 }
 
-// Result returns committed metrics. Falls back to attempted metrics if committed
-// are not populated (e.g. due to not being supported on a given runner).
+// Result returns the committed value, or the attempted value if the committed
+// one isn't populated.
 func (r DistributionResult) Result() DistributionValue {
 	empty := DistributionValue{}
-	if r.Committed != empty {
+	if r.HasCommitted || r.Committed != empty {
 		return r.Committed
 	}
 	return r.Attempted
@@ -575,22 +1298,8 @@ func MergeDistributions(
 	attempted map[StepKey]DistributionValue,
 	committed map[StepKey]DistributionValue) []DistributionResult {
 	res := make([]DistributionResult, 0)
-	merged := map[StepKey]DistributionResult{}
-
-	for k, v := range attempted {
-		merged[k] = DistributionResult{Attempted: v, Key: k}
-	}
-	for k, v := range committed {
-		m, ok := merged[k]
-		if ok {
-			merged[k] = DistributionResult{Attempted: m.Attempted, Committed: v, Key: k}
-		} else {
-			merged[k] = DistributionResult{Committed: v, Key: k}
-		}
-	}
-
-	for _, v := range merged {
-		res = append(res, v)
+	for k, hasCommitted := range mergeKeys(attempted, committed) {
+		res = append(res, DistributionResult{Attempted: attempted[k], Committed: committed[k], Key: k, HasCommitted: hasCommitted})
 	}
 	return res
 }
@@ -600,13 +1309,15 @@ func MergeDistributions(
 type GaugeResult struct {
 	Attempted, Committed GaugeValue
 	Key                  StepKey
+	// HasCommitted is whether the runner reported the committed value.
+	HasCommitted bool
 }
 
-// Result returns committed metrics. Falls back to attempted metrics if committed
-// are not populated (e.g. due to not being supported on a given runner).
+// Result returns the committed value, or the attempted value if the committed
+// one isn't populated.
 func (r GaugeResult) Result() GaugeValue {
 	empty := GaugeValue{}
-	if r.Committed != empty {
+	if r.HasCommitted || r.Committed != empty {
 		return r.Committed
 	}
 	return r.Attempted
@@ -622,22 +1333,95 @@ func MergeGauges(
 	attempted map[StepKey]GaugeValue,
 	committed map[StepKey]GaugeValue) []GaugeResult {
 	res := make([]GaugeResult, 0)
-	merged := map[StepKey]GaugeResult{}
+	for k, hasCommitted := range mergeKeys(attempted, committed) {
+		res = append(res, GaugeResult{Attempted: attempted[k], Committed: committed[k], Key: k, HasCommitted: hasCommitted})
+	}
+	return res
+}
 
-	for k, v := range attempted {
-		merged[k] = GaugeResult{Attempted: v, Key: k}
+// StringSetResult is an attempted and a commited value of a string set metric
+// plus key. The strings of the values are sorted.
+type StringSetResult struct {
+	Attempted, Committed []string
+	Key                  StepKey
+	// HasCommitted is whether the runner reported the committed value.
+	HasCommitted bool
+}
+
+// Result returns the committed value, or the attempted value if the committed
+// one isn't populated.
+func (r StringSetResult) Result() []string {
+	if r.HasCommitted || len(r.Committed) != 0 {
+		return r.Committed
 	}
-	for k, v := range committed {
-		m, ok := merged[k]
-		if ok {
-			merged[k] = GaugeResult{Attempted: m.Attempted, Committed: v, Key: k}
-		} else {
-			merged[k] = GaugeResult{Committed: v, Key: k}
-		}
+	return r.Attempted
+}
+
+// MergeStringSets combines string set metrics that share a common key.
+func MergeStringSets(
+	attempted map[StepKey][]string,
+	committed map[StepKey][]string) []StringSetResult {
+	res := make([]StringSetResult, 0)
+	for k, hasCommitted := range mergeKeys(attempted, committed) {
+		res = append(res, StringSetResult{Attempted: attempted[k], Committed: committed[k], Key: k, HasCommitted: hasCommitted})
 	}
+	return res
+}
+
+// HistogramResult is an attempted and a commited value of a histogram metric
+// plus key.
+type HistogramResult struct {
+	Attempted, Committed HistogramValue
+	Key                  StepKey
+	// HasCommitted is whether the runner reported the committed value.
+	HasCommitted bool
+}
+
+// Result returns the committed value, or the attempted value if the committed
+// one isn't populated.
+func (r HistogramResult) Result() HistogramValue {
+	if r.HasCommitted || r.Committed.Count() != 0 {
+		return r.Committed
+	}
+	return r.Attempted
+}
+
+// MergeHistograms combines histogram metrics that share a common key.
+func MergeHistograms(
+	attempted map[StepKey]HistogramValue,
+	committed map[StepKey]HistogramValue) []HistogramResult {
+	res := make([]HistogramResult, 0)
+	for k, hasCommitted := range mergeKeys(attempted, committed) {
+		res = append(res, HistogramResult{Attempted: attempted[k], Committed: committed[k], Key: k, HasCommitted: hasCommitted})
+	}
+	return res
+}
+
+// BoundedTrieResult is an attempted and a commited value of a bounded trie
+// metric plus key.
+type BoundedTrieResult struct {
+	Attempted, Committed BoundedTrieValue
+	Key                  StepKey
+	// HasCommitted is whether the runner reported the committed value.
+	HasCommitted bool
+}
+
+// Result returns the committed value, or the attempted value if the committed
+// one isn't populated.
+func (r BoundedTrieResult) Result() BoundedTrieValue {
+	if r.HasCommitted || len(r.Committed.Paths) != 0 {
+		return r.Committed
+	}
+	return r.Attempted
+}
 
-	for _, v := range merged {
-		res = append(res, v)
+// MergeBoundedTries combines bounded trie metrics that share a common key.
+func MergeBoundedTries(
+	attempted map[StepKey]BoundedTrieValue,
+	committed map[StepKey]BoundedTrieValue) []BoundedTrieResult {
+	res := make([]BoundedTrieResult, 0)
+	for k, hasCommitted := range mergeKeys(attempted, committed) {
+		res = append(res, BoundedTrieResult{Attempted: attempted[k], Committed: committed[k], Key: k, HasCommitted: hasCommitted})
 	}
 	return res
 }
@@ -18,6 +18,8 @@ package metrics
 import (
 	"context"
 	"fmt"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -238,6 +240,165 @@ func TestGauge_Set(t *testing.T) {
 	}
 }
 
+func TestStringSet_Add(t *testing.T) {
+	ctx := ctxWith(bID, "A")
+	m := NewStringSet("set1", "tables")
+	for _, v := range []string{"b", "a", "b", "c"} {
+		m.Add(ctx, v)
+	}
+	s := getCounterSet(ctx).stringSets[m.hash]
+	if got, want := s.get(), []string{"a", "b", "c"}; !cmp.Equal(got, want) {
+		t.Errorf("StringSet.Add(b, a, b, c) got %v, want %v", got, want)
+	}
+
+	// Strings beyond the size bound are dropped.
+	big := strings.Repeat("x", maxStringSetSize-s.size)
+	m.Add(ctx, big)
+	m.Add(ctx, "d")
+	if got, want := len(s.get()), 4; got != want {
+		t.Errorf("StringSet.Add beyond the bound got %d strings, want %d", got, want)
+	}
+	if got, want := s.size, maxStringSetSize; got != want {
+		t.Errorf("StringSet.Add beyond the bound got size %d, want %d", got, want)
+	}
+}
+
+func TestHistogram_Update(t *testing.T) {
+	tests := []struct {
+		name    string
+		buckets Buckets
+		vs      []int64
+		want    HistogramValue
+	}{
+		{
+			name:    "linear",
+			buckets: LinearBuckets(0, 10, 3),
+			vs:      []int64{-1, 0, 9, 10, 25, 29, 30, 100},
+			want:    HistogramValue{Buckets: LinearBuckets(0, 10, 3), Counts: []int64{2, 1, 2}, Underflow: 1, Overflow: 2},
+		}, {
+			name:    "exponential",
+			buckets: ExponentialBuckets(1, 2, 4),
+			vs:      []int64{0, 1, 2, 3, 4, 7, 8, 15, 16},
+			want:    HistogramValue{Buckets: ExponentialBuckets(1, 2, 4), Counts: []int64{1, 2, 2, 2}, Underflow: 1, Overflow: 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := ctxWith(bID, test.name)
+			m := NewHistogram("hist", test.name, test.buckets)
+			for _, v := range test.vs {
+				m.Update(ctx, v)
+			}
+			got := getCounterSet(ctx).histograms[m.hash].get()
+			if d := cmp.Diff(test.want, got); d != "" {
+				t.Errorf("Histogram.Update(%v) diff:\n%v", test.vs, d)
+			}
+		})
+	}
+}
+
+func TestNewHistogram_InvalidBuckets(t *testing.T) {
+	for _, b := range []Buckets{
+		LinearBuckets(0, 10, 0),
+		LinearBuckets(0, 0, 10),
+		ExponentialBuckets(0, 2, 10),
+		ExponentialBuckets(1, 1, 10),
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewHistogram(%+v) didn't panic", b)
+				}
+			}()
+			NewHistogram("hist", "invalid", b)
+		}()
+	}
+}
+
+func TestHistogramValue_Percentile(t *testing.T) {
+	v := HistogramValue{Buckets: LinearBuckets(0, 10, 4), Counts: []int64{0, 4, 4, 0}, Underflow: 1, Overflow: 1}
+	tests := []struct {
+		p, want float64
+	}{
+		{p: 0, want: 0},
+		{p: 10, want: 0},
+		{p: 30, want: 15},
+		{p: 50, want: 20},
+		{p: 90, want: 30},
+		{p: 100, want: 40},
+	}
+	for _, test := range tests {
+		if got := v.Percentile(test.p); got != test.want {
+			t.Errorf("Percentile(%v) = %v, want %v", test.p, got, test.want)
+		}
+	}
+	if got := (HistogramValue{Buckets: LinearBuckets(0, 10, 4), Counts: make([]int64, 4)}).Percentile(50); !math.IsNaN(got) {
+		t.Errorf("Percentile(50) of an empty histogram = %v, want NaN", got)
+	}
+}
+
+func TestBoundedTrie_Add(t *testing.T) {
+	ctx := ctxWith(bID, "A")
+	m := NewBoundedTrie("trie1", "paths")
+	m.Add(ctx, "a", "b")
+	m.Add(ctx, "a")
+	m.Add(ctx, "a", "c")
+	m.Add(ctx, "d")
+	b := getCounterSet(ctx).boundedTries[m.hash]
+	want := BoundedTrieValue{Paths: []BoundedTriePath{
+		{Segments: []string{"a", "b"}},
+		{Segments: []string{"a", "c"}},
+		{Segments: []string{"d"}},
+	}}
+	if d := cmp.Diff(want, b.get()); d != "" {
+		t.Errorf("BoundedTrie.Add diff (-want, +got):\n%v", d)
+	}
+
+	// The largest subtree is truncated beyond the size bound, and paths
+	// added under it are dropped.
+	ctx = ctxWith(bID, "B")
+	for i := 0; i < maxBoundedTrieSize; i++ {
+		m.Add(ctx, "big", fmt.Sprint(i))
+	}
+	m.Add(ctx, "small", "x")
+	m.Add(ctx, "big", "y")
+	b = getCounterSet(ctx).boundedTries[m.hash]
+	want = BoundedTrieValue{Paths: []BoundedTriePath{
+		{Segments: []string{"big"}, Truncated: true},
+		{Segments: []string{"small", "x"}},
+	}}
+	if d := cmp.Diff(want, b.get()); d != "" {
+		t.Errorf("BoundedTrie.Add beyond the bound diff (-want, +got):\n%v", d)
+	}
+}
+
+func TestBoundedTrieValue_Merge(t *testing.T) {
+	a := BoundedTrieValue{Paths: []BoundedTriePath{{Segments: []string{"a", "b"}}}}
+	b := BoundedTrieValue{Paths: []BoundedTriePath{
+		{Segments: []string{"a", "c"}},
+		{Segments: []string{"e"}, Truncated: true},
+	}}
+	got := a.Merge(b)
+	want := BoundedTrieValue{Paths: []BoundedTriePath{
+		{Segments: []string{"a", "b"}},
+		{Segments: []string{"a", "c"}},
+		{Segments: []string{"e"}, Truncated: true},
+	}}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("Merge diff (-want, +got):\n%v", d)
+	}
+
+	// Truncated paths absorb the paths they are a prefix of.
+	got = got.Merge(BoundedTrieValue{Paths: []BoundedTriePath{{Segments: []string{"a"}, Truncated: true}}})
+	want = BoundedTrieValue{Paths: []BoundedTriePath{
+		{Segments: []string{"a"}, Truncated: true},
+		{Segments: []string{"e"}, Truncated: true},
+	}}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("Merge with truncated path diff (-want, +got):\n%v", d)
+	}
+}
+
 func TestNameCollisions(t *testing.T) {
 	ns, c, d, g := "collisions", "counter", "distribution", "gauge"
 	// Checks that user code panics if a counter attempts to be defined in the same PTransform
@@ -322,7 +483,7 @@ func TestMergeCounters(t *testing.T) {
 			committed: map[StepKey]int64{
 				realKey: 7,
 			},
-			want: []CounterResult{{Attempted: 5, Committed: 7, Key: realKey}},
+			want: []CounterResult{{Attempted: 5, Committed: 7, Key: realKey, HasCommitted: true}},
 		}, {
 			name: "attempted only",
 			attempted: map[StepKey]int64{
@@ -336,7 +497,7 @@ func TestMergeCounters(t *testing.T) {
 			committed: map[StepKey]int64{
 				realKey: 7,
 			},
-			want: []CounterResult{{Committed: 7, Key: realKey}},
+			want: []CounterResult{{Committed: 7, Key: realKey, HasCommitted: true}},
 		},
 	}
 	less := func(a, b CounterResult) bool {
@@ -369,7 +530,7 @@ func TestMergeDistributions(t *testing.T) {
 			committed: map[StepKey]DistributionValue{
 				realKey: distB,
 			},
-			want: []DistributionResult{{Attempted: distA, Committed: distB, Key: realKey}},
+			want: []DistributionResult{{Attempted: distA, Committed: distB, Key: realKey, HasCommitted: true}},
 		}, {
 			name: "attempted only",
 			attempted: map[StepKey]DistributionValue{
@@ -383,7 +544,7 @@ func TestMergeDistributions(t *testing.T) {
 			committed: map[StepKey]DistributionValue{
 				realKey: distB,
 			},
-			want: []DistributionResult{{Committed: distB, Key: realKey}},
+			want: []DistributionResult{{Committed: distB, Key: realKey, HasCommitted: true}},
 		},
 	}
 	less := func(a, b DistributionResult) bool {
@@ -418,7 +579,7 @@ func TestMergeGauges(t *testing.T) {
 			committed: map[StepKey]GaugeValue{
 				realKey: gaugeB,
 			},
-			want: []GaugeResult{{Attempted: gaugeA, Committed: gaugeB, Key: realKey}},
+			want: []GaugeResult{{Attempted: gaugeA, Committed: gaugeB, Key: realKey, HasCommitted: true}},
 		}, {
 			name: "attempted only",
 			attempted: map[StepKey]GaugeValue{
@@ -432,7 +593,7 @@ func TestMergeGauges(t *testing.T) {
 			committed: map[StepKey]GaugeValue{
 				realKey: gaugeB,
 			},
-			want: []GaugeResult{{Committed: gaugeB, Key: realKey}},
+			want: []GaugeResult{{Committed: gaugeB, Key: realKey, HasCommitted: true}},
 		},
 	}
 	less := func(a, b DistributionResult) bool {
@@ -501,3 +662,108 @@ func BenchmarkMetrics(b *testing.B) {
 		})
 	}
 }
+
+// TestResultsFromStore validates that the metrics of several counter sets of
+// the same PTransform are merged.
+func TestResultsFromStore(t *testing.T) {
+	ctx := SetBundleID(context.Background(), bID)
+	ctxA1 := SetPTransformID(ctx, "A")
+	ctxA2 := SetPTransformID(ctx, "A")
+	ctxB := SetPTransformID(ctx, "B")
+
+	c := NewCounter("ns", "count")
+	c.Inc(ctxA1, 2)
+	c.Inc(ctxA2, 3)
+	c.Inc(ctxB, 7)
+	d := NewDistribution("ns", "dist")
+	d.Update(ctxA1, 1)
+	d.Update(ctxA2, 5)
+
+	res := ResultsFromStore(GetStore(ctx)).AllMetrics()
+
+	aKey, bKey := StepKey{Step: "A", Name: "count", Namespace: "ns"}, StepKey{Step: "B", Name: "count", Namespace: "ns"}
+	wantCounters := []CounterResult{{Attempted: 5, Committed: 5, Key: aKey, HasCommitted: true}, {Attempted: 7, Committed: 7, Key: bKey, HasCommitted: true}}
+	less := func(a, b CounterResult) bool {
+		return a.Key.Step < b.Key.Step
+	}
+	if d := cmp.Diff(wantCounters, res.Counters(), cmpopts.SortSlices(less)); d != "" {
+		t.Errorf("ResultsFromStore counters diff:\n%v", d)
+	}
+	dv := DistributionValue{Count: 2, Sum: 6, Min: 1, Max: 5}
+	wantDists := []DistributionResult{{Attempted: dv, Committed: dv, Key: StepKey{Step: "A", Name: "dist", Namespace: "ns"}, HasCommitted: true}}
+	if d := cmp.Diff(wantDists, res.Distributions()); d != "" {
+		t.Errorf("ResultsFromStore distributions diff:\n%v", d)
+	}
+}
+
+// TestResultsFromStore_StringSets validates that the string sets of several
+// counter sets of the same PTransform are merged by their union.
+func TestResultsFromStore_StringSets(t *testing.T) {
+	ctx := SetBundleID(context.Background(), bID)
+	ctxA1 := SetPTransformID(ctx, "A")
+	ctxA2 := SetPTransformID(ctx, "A")
+
+	s := NewStringSet("ns", "files")
+	s.Add(ctxA1, "gs://b/1")
+	s.Add(ctxA2, "gs://b/2")
+	s.Add(ctxA2, "gs://b/1")
+
+	res := ResultsFromStore(GetStore(ctx)).AllMetrics()
+
+	vs := []string{"gs://b/1", "gs://b/2"}
+	want := []StringSetResult{{Attempted: vs, Committed: vs, Key: StepKey{Step: "A", Name: "files", Namespace: "ns"}, HasCommitted: true}}
+	if d := cmp.Diff(want, res.StringSets()); d != "" {
+		t.Errorf("ResultsFromStore string sets diff:\n%v", d)
+	}
+}
+
+func TestResults_Query(t *testing.T) {
+	keys := []StepKey{
+		{Step: "main.fn", Namespace: "ns1", Name: "count"},
+		{Step: "CountWords/main.extractFn", Namespace: "ns1", Name: "count"},
+		{Step: "CountWords/Inner/main.countFn", Namespace: "ns2", Name: "count"},
+		{Step: "CountWordsAgain/main.fn", Namespace: "ns2", Name: "size"},
+	}
+	counters := make(map[StepKey]int64)
+	gauges := make(map[StepKey]GaugeValue)
+	for i, k := range keys {
+		counters[k] = int64(i)
+		gauges[k] = GaugeValue{Value: int64(i)}
+	}
+	res := NewResults(MergeCounters(counters, nil), nil, MergeGauges(gauges, nil))
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []StepKey
+	}{
+		{name: "all", filter: Filter{}, want: keys},
+		{name: "namespace", filter: Filter{Namespace: "ns2"}, want: keys[2:]},
+		{name: "name", filter: Filter{Name: "count"}, want: keys[:3]},
+		{name: "namespaceAndName", filter: Filter{Namespace: "ns1", Name: "count"}, want: keys[:2]},
+		{name: "leafStep", filter: Filter{Steps: []string{"main.fn"}}, want: []StepKey{keys[0], keys[3]}},
+		{name: "compositeStep", filter: Filter{Steps: []string{"CountWords"}}, want: keys[1:3]},
+		{name: "subPath", filter: Filter{Steps: []string{"Inner/main.countFn"}}, want: keys[2:3]},
+		{name: "partialComponent", filter: Filter{Steps: []string{"Count"}}, want: nil},
+		{name: "anyStep", filter: Filter{Steps: []string{"main.extractFn", "CountWordsAgain"}}, want: []StepKey{keys[1], keys[3]}},
+	}
+	less := func(a, b StepKey) bool { return a.Step < b.Step }
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			qr := res.Query(test.filter)
+			var gotCounters, gotGauges []StepKey
+			for _, c := range qr.Counters() {
+				gotCounters = append(gotCounters, c.Key)
+			}
+			for _, g := range qr.Gauges() {
+				gotGauges = append(gotGauges, g.Key)
+			}
+			if d := cmp.Diff(test.want, gotCounters, cmpopts.SortSlices(less), cmpopts.EquateEmpty()); d != "" {
+				t.Errorf("Query(%+v) counters diff:\n%v", test.filter, d)
+			}
+			if d := cmp.Diff(test.want, gotGauges, cmpopts.SortSlices(less), cmpopts.EquateEmpty()); d != "" {
+				t.Errorf("Query(%+v) gauges diff:\n%v", test.filter, d)
+			}
+		})
+	}
+}
@@ -40,6 +40,9 @@ func (l Labels) Namespace() string { return l.namespace }
 // Name returns the name for this metric.
 func (l Labels) Name() string { return l.name }
 
+// PCollection returns the pcollection context for this metric, if available.
+func (l Labels) PCollection() string { return l.pcollection }
+
 // UserLabels builds a Labels for user metrics.
 // Intended for framework use.
 func UserLabels(transform, namespace, name string) Labels {
@@ -69,6 +72,12 @@ type Extractor struct {
 	DistributionInt64 func(labels Labels, count, sum, min, max int64)
 	// GaugeInt64 extracts data from Gauge Int64 counters.
 	GaugeInt64 func(labels Labels, v int64, t time.Time)
+	// StringSet extracts data from StringSet metrics. The strings are sorted.
+	StringSet func(labels Labels, vs []string)
+	// HistogramInt64 extracts data from Histogram Int64 metrics.
+	HistogramInt64 func(labels Labels, v HistogramValue)
+	// BoundedTrie extracts data from BoundedTrie metrics.
+	BoundedTrie func(labels Labels, v BoundedTrieValue)
 }
 
 // ExtractFrom the given metrics Store all the metrics for
@@ -78,7 +87,7 @@ func (e Extractor) ExtractFrom(store *Store) error {
 	store.mu.RLock()
 	defer store.mu.RUnlock()
 
-	if e.SumInt64 == nil && e.DistributionInt64 == nil && e.GaugeInt64 == nil {
+	if e.SumInt64 == nil && e.DistributionInt64 == nil && e.GaugeInt64 == nil && e.StringSet == nil && e.HistogramInt64 == nil && e.BoundedTrie == nil {
 		return fmt.Errorf("no Extractor fields were set")
 	}
 
@@ -86,29 +95,147 @@ func (e Extractor) ExtractFrom(store *Store) error {
 		switch um.kind() {
 		case kindSumCounter:
 			if e.SumInt64 != nil {
-				data := um.(*counter).get()
+				var data int64
+				for _, c := range cellsOf(um) {
+					data += c.(*counter).get()
+				}
 				e.SumInt64(l, data)
 			}
 		case kindDistribution:
 			if e.DistributionInt64 != nil {
-				count, sum, min, max := um.(*distribution).get()
+				var count, sum, min, max int64
+				for i, c := range cellsOf(um) {
+					cc, cs, cmin, cmax := c.(*distribution).get()
+					if i == 0 || cmin < min {
+						min = cmin
+					}
+					if i == 0 || cmax > max {
+						max = cmax
+					}
+					count += cc
+					sum += cs
+				}
 				e.DistributionInt64(l, count, sum, min, max)
 			}
 		case kindGauge:
 			if e.GaugeInt64 != nil {
-				v, t := um.(*gauge).get()
+				var v int64
+				var t time.Time
+				for _, c := range cellsOf(um) {
+					if cv, ct := c.(*gauge).get(); !ct.Before(t) {
+						v, t = cv, ct
+					}
+				}
 				e.GaugeInt64(l, v, t)
 			}
+		case kindStringSet:
+			if e.StringSet != nil {
+				cs := cellsOf(um)
+				if len(cs) == 1 {
+					e.StringSet(l, cs[0].(*stringSet).get())
+					continue
+				}
+				var set stringSet
+				for _, c := range cs {
+					set.add(c.(*stringSet).get()...)
+				}
+				e.StringSet(l, set.get())
+			}
+		case kindHistogram:
+			if e.HistogramInt64 != nil {
+				var v HistogramValue
+				for i, c := range cellsOf(um) {
+					if i == 0 {
+						v = c.(*histogram).get()
+						continue
+					}
+					// Cells of histograms declared with other buckets under
+					// the same name can't be merged, and are dropped.
+					v.merge(c.(*histogram).get())
+				}
+				e.HistogramInt64(l, v)
+			}
+		case kindBoundedTrie:
+			if e.BoundedTrie != nil {
+				var v BoundedTrieValue
+				for i, c := range cellsOf(um) {
+					if i == 0 {
+						v = c.(*boundedTrie).get()
+						continue
+					}
+					v = v.Merge(c.(*boundedTrie).get())
+				}
+				e.BoundedTrie(l, v)
+			}
 		}
 	}
 	return nil
 }
 
+// ResultsFromStore returns the metrics of the given Store as Results. The metrics
+// of a completed pipeline are both attempted and committed.
+func ResultsFromStore(store *Store) Results {
+	counters := make(map[StepKey]int64)
+	distributions := make(map[StepKey]DistributionValue)
+	gauges := make(map[StepKey]GaugeValue)
+	stringSets := make(map[StepKey][]string)
+	histograms := make(map[StepKey]HistogramValue)
+	boundedTries := make(map[StepKey]BoundedTrieValue)
+	Extractor{
+		SumInt64: func(l Labels, v int64) {
+			counters[l.stepKey()] = v
+		},
+		DistributionInt64: func(l Labels, count, sum, min, max int64) {
+			distributions[l.stepKey()] = DistributionValue{Count: count, Sum: sum, Min: min, Max: max}
+		},
+		GaugeInt64: func(l Labels, v int64, t time.Time) {
+			gauges[l.stepKey()] = GaugeValue{Value: v, Timestamp: t}
+		},
+		StringSet: func(l Labels, vs []string) {
+			stringSets[l.stepKey()] = vs
+		},
+		HistogramInt64: func(l Labels, v HistogramValue) {
+			histograms[l.stepKey()] = v
+		},
+		BoundedTrie: func(l Labels, v BoundedTrieValue) {
+			boundedTries[l.stepKey()] = v
+		},
+	}.ExtractFrom(store)
+	return Results{
+		counters:      MergeCounters(counters, counters),
+		distributions: MergeDistributions(distributions, distributions),
+		gauges:        MergeGauges(gauges, gauges),
+		stringSets:    MergeStringSets(stringSets, stringSets),
+		histograms:    MergeHistograms(histograms, histograms),
+		boundedTries:  MergeBoundedTries(boundedTries, boundedTries),
+	}
+}
+
+func (l Labels) stepKey() StepKey {
+	return StepKey{Step: l.transform, Name: l.name, Namespace: l.namespace}
+}
+
 // userMetric knows what kind it is.
 type userMetric interface {
 	kind() kind
 }
 
+// cells are the metric cells with the same labels in several counter sets,
+// such as those of concurrent replicas of a PTransform.
+type cells []userMetric
+
+func (c cells) kind() kind {
+	return c[0].kind()
+}
+
+// cellsOf returns the cells of a stored metric.
+func cellsOf(m userMetric) cells {
+	if c, ok := m.(cells); ok {
+		return c
+	}
+	return cells{m}
+}
+
 type nameHash uint64
 
 // ptCounterSet is the internal tracking struct for a single ptransform
@@ -121,6 +248,9 @@ type ptCounterSet struct {
 	counters      map[nameHash]*counter
 	distributions map[nameHash]*distribution
 	gauges        map[nameHash]*gauge
+	stringSets    map[nameHash]*stringSet
+	histograms    map[nameHash]*histogram
+	boundedTries  map[nameHash]*boundedTrie
 }
 
 // Store retains per transform countersets, intended for per bundle use.
@@ -137,7 +267,8 @@ func newStore() *Store {
 
 // storeMetric stores a metric away on its first use so it may be retrieved later on.
 // In the event of a name collision, storeMetric can panic, so it's prudent to release
-// locks if they are no longer required.
+// locks if they are no longer required. Metrics stored by several counter sets of the
+// same PTransform are merged when extracted.
 func (b *Store) storeMetric(pid string, n name, m userMetric) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -146,6 +277,7 @@ func (b *Store) storeMetric(pid string, n name, m userMetric) {
 		if ms.kind() != m.kind() {
 			panic(fmt.Sprintf("metric name %s being reused for a different metric type in a single PTransform", n))
 		}
+		b.store[l] = append(cellsOf(ms), m)
 		return
 	}
 	b.store[l] = m
@@ -40,6 +40,9 @@ type Combine struct {
 
 	PID string
 	ctx context.Context
+	// timer tracks the execution time of the phases of the transform in its
+	// plan, if any.
+	timer *transformTracker
 
 	binaryMergeFn reflectx.Func2x1 // optimized caller in the case of binary merge accumulators
 
@@ -57,6 +60,10 @@ func (n *Combine) GetPID() string {
 	return n.PID
 }
 
+func (n *Combine) setExecutionTracker(t *executionTracker) {
+	n.timer = t.transform(n.PID)
+}
+
 // ID returns the UnitID for this node.
 func (n *Combine) ID() UnitID {
 	return n.UID
@@ -133,6 +140,9 @@ func (n *Combine) ProcessElement(ctx context.Context, value *FullValue, values .
 	if n.status != Active {
 		return errors.Errorf("invalid status for combine %v: %v", n.UID, n.status)
 	}
+	if n.timer != nil {
+		defer n.timer.exit(n.timer.enter(processPhase))
+	}
 
 	// Note that we do not explicitly call merge, although it may
 	// be called implicitly when adding input.
@@ -348,6 +358,9 @@ func (n *LiftedCombine) ProcessElement(ctx context.Context, value *FullValue, va
 	if n.status != Active {
 		return errors.Errorf("invalid status for precombine %v: %v", n.UID, n.status)
 	}
+	if n.timer != nil {
+		defer n.timer.exit(n.timer.enter(processPhase))
+	}
 	// The cache layer in lifted combines implicitly observes windows. Process each individually.
 	for _, w := range value.Windows {
 		err := n.processElementPerWindow(ctx, value, w)
@@ -459,6 +472,9 @@ func (n *MergeAccumulators) ProcessElement(ctx context.Context, value *FullValue
 	if n.status != Active {
 		return errors.Errorf("invalid status for combine merge %v: %v", n.UID, n.status)
 	}
+	if n.timer != nil {
+		defer n.timer.exit(n.timer.enter(processPhase))
+	}
 	a, err := n.newAccum(n.Combine.ctx, value.Elm)
 	if err != nil {
 		return n.fail(err)
@@ -514,6 +530,9 @@ func (n *ExtractOutput) ProcessElement(ctx context.Context, value *FullValue, va
 	if n.status != Active {
 		return errors.Errorf("invalid status for combine extract %v: %v", n.UID, n.status)
 	}
+	if n.timer != nil {
+		defer n.timer.exit(n.timer.enter(processPhase))
+	}
 	out, err := n.extract(n.Combine.ctx, value.Elm2)
 	if err != nil {
 		return n.fail(err)
@@ -535,6 +554,9 @@ func (n *ConvertToAccumulators) ProcessElement(ctx context.Context, value *FullV
 	if n.status != Active {
 		return errors.Errorf("invalid status for combine convert %v: %v", n.UID, n.status)
 	}
+	if n.timer != nil {
+		defer n.timer.exit(n.timer.enter(processPhase))
+	}
 	a, err := n.newAccum(n.Combine.ctx, value.Elm)
 	if err != nil {
 		return n.fail(err)
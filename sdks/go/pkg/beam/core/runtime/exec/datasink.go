@@ -37,6 +37,7 @@ type DataSink struct {
 	enc   ElementEncoder
 	wEnc  WindowEncoder
 	w     io.WriteCloser
+	buf   bytes.Buffer // reused to encode each element
 	count int64
 	start time.Time
 }
@@ -64,14 +65,15 @@ func (n *DataSink) StartBundle(ctx context.Context, id string, data DataContext)
 
 func (n *DataSink) ProcessElement(ctx context.Context, value *FullValue, values ...ReStream) error {
 	// Marshal the pieces into a temporary buffer since they must be transmitted on FnAPI as a single
-	// unit.
-	var b bytes.Buffer
+	// unit. The writer copies the bytes, so the buffer is reused for the next element.
+	b := &n.buf
+	b.Reset()
 
 	atomic.AddInt64(&n.count, 1)
-	if err := EncodeWindowedValueHeader(n.wEnc, value.Windows, value.Timestamp, &b); err != nil {
+	if err := EncodeWindowedValueHeader(n.wEnc, value.Windows, value.Timestamp, b); err != nil {
 		return err
 	}
-	if err := n.enc.Encode(value, &b); err != nil {
+	if err := n.enc.Encode(value, b); err != nil {
 		return errors.WithContextf(err, "encoding element %v with coder %v", value, n.enc)
 	}
 	if _, err := n.w.Write(b.Bytes()); err != nil {
@@ -63,7 +63,11 @@ func (n *DataSource) InitSplittable() {
 	if n.Out == nil {
 		return
 	}
-	if u, ok := n.Out.(*ProcessSizedElementsAndRestrictions); ok == true {
+	out := n.Out
+	if c, ok := out.(*PCollection); ok {
+		out = c.Out
+	}
+	if u, ok := out.(*ProcessSizedElementsAndRestrictions); ok == true {
 		n.su = u.SU
 	}
 }
@@ -267,6 +271,17 @@ func (n *DataSource) incrementIndexAndCheckSplit() bool {
 type ProgressReportSnapshot struct {
 	ID, Name, PID string
 	Count         int64
+
+	// Work is the progress of the splittable unit the source feeds, if it's
+	// processing an element.
+	Work *WorkProgress
+}
+
+// WorkProgress captures the work completed and remaining on the element a
+// splittable transform is processing.
+type WorkProgress struct {
+	TransformID          string
+	Completed, Remaining float64
 }
 
 // Progress returns a snapshot of the source's progress.
@@ -278,12 +293,36 @@ func (n *DataSource) Progress() ProgressReportSnapshot {
 	// The count is the number of "completely processed elements"
 	// which matches the index of the currently processing element.
 	c := n.index
+	work := n.workProgress()
 	n.mu.Unlock()
 	// Do not sent negative progress reports, index is initialized to 0.
 	if c < 0 {
 		c = 0
 	}
-	return ProgressReportSnapshot{PID: n.outputPID, ID: n.SID.PtransformID, Name: n.Name, Count: c}
+	return ProgressReportSnapshot{PID: n.outputPID, ID: n.SID.PtransformID, Name: n.Name, Count: c, Work: work}
+}
+
+// workProgress returns the progress of the splittable unit, if it's
+// processing an element. Unlike splits, progress reports don't wait for an
+// element to start processing. Requires n.mu to be held, so the splittable
+// unit isn't received by a split meanwhile.
+func (n *DataSource) workProgress() *WorkProgress {
+	if n.su == nil {
+		return nil
+	}
+	select {
+	case su := <-n.su:
+		defer func() {
+			n.su <- su
+		}()
+		if su == nil {
+			return nil
+		}
+		d, r := su.GetWorkProgress()
+		return &WorkProgress{TransformID: su.GetTransformId(), Completed: d, Remaining: r}
+	default:
+		return nil
+	}
 }
 
 // Split takes a sorted set of potential split indices and a fraction of the
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"reflect"
 	"testing"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
@@ -586,6 +587,30 @@ func TestDataSource_Split(t *testing.T) {
 	})
 }
 
+func TestDataSource_Progress(t *testing.T) {
+	source := &DataSource{
+		SID:       StreamID{PtransformID: "myPTransform"},
+		outputPID: "myPCollection",
+		index:     3,
+	}
+	if got, want := source.Progress(), (ProgressReportSnapshot{ID: "myPTransform", PID: "myPCollection", Count: 3}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Progress() without splittable unit = %+v, want %+v", got, want)
+	}
+
+	source.su = make(chan SplittableUnit, 1)
+	if got := source.Progress().Work; got != nil {
+		t.Errorf("Progress().Work while not processing = %+v, want nil", got)
+	}
+	source.su <- &TestSplittableUnit{elm: int64(1)}
+	want := &WorkProgress{TransformID: testTransformId, Completed: 0, Remaining: 1}
+	if got := source.Progress().Work; !reflect.DeepEqual(got, want) {
+		t.Errorf("Progress().Work while processing = %+v, want %+v", got, want)
+	}
+	if len(source.su) != 1 {
+		t.Error("Progress() didn't return the splittable unit")
+	}
+}
+
 const testTransformId = "transform_id"
 const testInputId = "input_id"
 
@@ -609,6 +634,12 @@ func (n *TestSplittableUnit) GetProgress() float64 {
 	return 0
 }
 
+// GetWorkProgress always returns no completed and one remaining unit of work,
+// to keep tests consistent.
+func (n *TestSplittableUnit) GetWorkProgress() (float64, float64) {
+	return 0, 1
+}
+
 // GetTransformId returns a constant transform ID that can be tested for.
 func (n *TestSplittableUnit) GetTransformId() string {
 	return testTransformId
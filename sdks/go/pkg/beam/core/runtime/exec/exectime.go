@@ -0,0 +1,203 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// executionPhase is a phase of the processing of a bundle by a transform.
+type executionPhase int
+
+const (
+	startPhase executionPhase = iota
+	processPhase
+	finishPhase
+	numPhases
+)
+
+// samplingPeriod is the period of the samples of the executing transforms.
+const samplingPeriod = 10 * time.Millisecond
+
+// noState is the executing state when no transform is executing.
+const noState = -1
+
+// executionTracker measures the time the transforms of a plan spend in each
+// phase of a bundle, by sampling the executing transform and phase, as the
+// execution state samplers of the other SDKs do. Transforms process the
+// outputs of their upstream transforms in the same goroutine, so the time is
+// attributed to the most downstream transform executing, excluding the time
+// of its downstream transforms.
+//
+// Transforms only swap the executing state as they enter and exit phases. A
+// goroutine samples it during each bundle, and attributes the time since the
+// previous sample to it.
+type executionTracker struct {
+	// now returns the current time. Defaults to time.Now.
+	now func() time.Time
+	// period is the sampling period. Defaults to samplingPeriod.
+	period time.Duration
+
+	pids    []string // of the transforms, by their states
+	current int32    // executing state, accessed atomically
+
+	mu    sync.Mutex
+	last  time.Time // of the previous sample
+	times []time.Duration
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func (t *executionTracker) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// transform returns the tracker of the phases of a transform. Transforms are
+// added while constructing the plan.
+func (t *executionTracker) transform(pid string) *transformTracker {
+	tt := &transformTracker{t: t, state: int32(len(t.pids))}
+	for i := 0; i < int(numPhases); i++ {
+		t.pids = append(t.pids, pid)
+	}
+	return tt
+}
+
+// start resets the measured times, and samples the executing state until
+// stop is called, for a new bundle.
+func (t *executionTracker) start() {
+	t.mu.Lock()
+	atomic.StoreInt32(&t.current, noState)
+	t.times = make([]time.Duration, len(t.pids))
+	t.last = t.clock()
+	t.stop, t.done = make(chan struct{}), make(chan struct{})
+	t.mu.Unlock()
+
+	period := t.period
+	if period == 0 {
+		period = samplingPeriod
+	}
+	go t.run(period, t.stop, t.done)
+}
+
+func (t *executionTracker) run(period time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.sample()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// end ends the sampling of the bundle, sampling the executing state once
+// more.
+func (t *executionTracker) end() {
+	close(t.stop)
+	<-t.done
+	t.sample()
+}
+
+// sample attributes the time since the previous sample to the executing
+// state.
+func (t *executionTracker) sample() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock()
+	if s := atomic.LoadInt32(&t.current); s != noState && t.times != nil {
+		t.times[s] += now.Sub(t.last)
+	}
+	t.last = now
+}
+
+// snapshot returns the times measured for each transform so far, sorted by
+// transform.
+func (t *executionTracker) snapshot() []ExecutionTimeSnapshot {
+	t.sample()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	byPID := make(map[string]*ExecutionTimeSnapshot)
+	for s, d := range t.times {
+		if d == 0 {
+			continue
+		}
+		pid := t.pids[s]
+		snap, ok := byPID[pid]
+		if !ok {
+			snap = &ExecutionTimeSnapshot{PID: pid}
+			byPID[pid] = snap
+		}
+		switch executionPhase(s % int(numPhases)) {
+		case startPhase:
+			snap.Start += d
+		case processPhase:
+			snap.Process += d
+		case finishPhase:
+			snap.Finish += d
+		}
+	}
+	ret := make([]ExecutionTimeSnapshot, 0, len(byPID))
+	for _, snap := range byPID {
+		ret = append(ret, *snap)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].PID < ret[j].PID })
+	return ret
+}
+
+// transformTracker tracks the phases of a transform in the tracker of its
+// plan.
+type transformTracker struct {
+	t     *executionTracker
+	state int32 // of the start phase, followed by those of the other phases
+}
+
+// enter makes the phase of the transform the executing state, and returns the
+// previously executing state to restore on exit.
+func (tt *transformTracker) enter(phase executionPhase) int32 {
+	return atomic.SwapInt32(&tt.t.current, tt.state+int32(phase))
+}
+
+func (tt *transformTracker) exit(prev int32) {
+	atomic.StoreInt32(&tt.t.current, prev)
+}
+
+// ExecutionTimeSnapshot is the time a transform spent in each phase of a
+// bundle, excluding the time of its downstream transforms.
+type ExecutionTimeSnapshot struct {
+	PID                    string
+	Start, Process, Finish time.Duration
+}
+
+// Total returns the time the transform spent in all phases.
+func (s ExecutionTimeSnapshot) Total() time.Duration {
+	return s.Start + s.Process + s.Finish
+}
+
+// timedUnit is a unit reporting its execution times to the tracker of its
+// plan.
+type timedUnit interface {
+	setExecutionTracker(t *executionTracker)
+}
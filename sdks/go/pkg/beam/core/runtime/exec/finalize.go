@@ -0,0 +1,98 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// bundleFinalizer collects the callbacks that the DoFns of a plan register
+// during a bundle, to run once the runner has committed the bundle. It
+// implements typex.BundleFinalization.
+type bundleFinalizer struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	callbacks []finalizationCallback
+}
+
+type finalizationCallback struct {
+	deadline time.Time
+	fn       func() error
+}
+
+func newBundleFinalizer() *bundleFinalizer {
+	return &bundleFinalizer{now: time.Now}
+}
+
+// RegisterCallback registers a callback to run once the bundle is committed,
+// if that happens within the given duration.
+func (f *bundleFinalizer) RegisterCallback(d time.Duration, fn func() error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.callbacks = append(f.callbacks, finalizationCallback{deadline: f.now().Add(d), fn: fn})
+}
+
+// pending returns whether the bundle has callbacks to run.
+func (f *bundleFinalizer) pending() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.callbacks) > 0
+}
+
+// reset drops the callbacks of the previous bundle.
+func (f *bundleFinalizer) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.callbacks = nil
+}
+
+// finalize runs the callbacks whose deadlines have not passed, and drops
+// them all. It runs every callback even if some fail.
+func (f *bundleFinalizer) finalize() error {
+	f.mu.Lock()
+	callbacks := f.callbacks
+	f.callbacks = nil
+	f.mu.Unlock()
+
+	now := f.now()
+	var errs []error
+	for _, c := range callbacks {
+		if now.After(c.deadline) {
+			continue
+		}
+		if err := c.fn(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errors.Wrap(errs[0], "bundle finalization callback failed")
+	default:
+		return errors.Errorf("%v bundle finalization callbacks failed: %v", len(errs), errs)
+	}
+}
+
+// finalizingUnit is a unit whose DoFn may register bundle finalization
+// callbacks with the finalizer of its plan.
+type finalizingUnit interface {
+	setBundleFinalizer(f *bundleFinalizer)
+}
@@ -25,6 +25,8 @@ import (
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/state"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/timers"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 )
@@ -37,6 +39,11 @@ type MainInput struct {
 	Key      FullValue
 	Values   []ReStream
 	RTracker sdf.RTracker
+
+	// State and Timers are the state and timers of the key and window of the
+	// main input, for stateful DoFns.
+	State  state.Provider
+	Timers timers.Provider
 }
 
 // Invoke invokes the fn with the given values. The extra values must match the non-main
@@ -65,12 +72,18 @@ type invoker struct {
 	args []interface{}
 	// TODO(lostluck):  2018/07/06 consider replacing with a slice of functions to run over the args slice, as an improvement.
 	ctxIdx, wndIdx, etIdx int   // specialized input indexes
+	spIdx, tpIdx          int   // state and timer provider indexes
+	bfIdx                 int   // bundle finalization index
 	outEtIdx, outErrIdx   int   // specialized output indexes
 	in, out               []int // general indexes
 
 	ret                     FullValue                     // ret is a cached allocation for passing to the next Unit. Units never modify the passed in FullValue.
 	elmConvert, elm2Convert func(interface{}) interface{} // Cached conversion functions, which assums this invoker is always used with the same parameter types.
 	call                    func(ws []typex.Window, ts typex.EventTime) (*FullValue, error)
+
+	// bf collects the bundle finalization callbacks of the fn, if it takes
+	// a typex.BundleFinalization.
+	bf typex.BundleFinalization
 }
 
 func newInvoker(fn *funcx.Fn) *invoker {
@@ -90,6 +103,15 @@ func newInvoker(fn *funcx.Fn) *invoker {
 	if n.etIdx, ok = fn.EventTime(); !ok {
 		n.etIdx = -1
 	}
+	if n.spIdx, ok = fn.StateProvider(); !ok {
+		n.spIdx = -1
+	}
+	if n.tpIdx, ok = fn.TimerProvider(); !ok {
+		n.tpIdx = -1
+	}
+	if n.bfIdx, ok = fn.BundleFinalization(); !ok {
+		n.bfIdx = -1
+	}
 	if n.outEtIdx, ok = fn.OutEventTime(); !ok {
 		n.outEtIdx = -1
 	}
@@ -137,6 +159,23 @@ func (n *invoker) Invoke(ctx context.Context, ws []typex.Window, ts typex.EventT
 	if n.etIdx >= 0 {
 		args[n.etIdx] = ts
 	}
+	if n.bfIdx >= 0 {
+		if n.bf == nil {
+			return nil, errors.Errorf("DoFns requesting bundle finalization must be invoked with a bundle finalizer")
+		}
+		args[n.bfIdx] = n.bf
+	}
+	if n.spIdx >= 0 || n.tpIdx >= 0 {
+		if opt == nil || (n.spIdx >= 0 && opt.State == nil) || (n.tpIdx >= 0 && opt.Timers == nil) {
+			return nil, errors.Errorf("stateful DoFns must be invoked with the state and timers of their main input")
+		}
+		if n.spIdx >= 0 {
+			args[n.spIdx] = opt.State
+		}
+		if n.tpIdx >= 0 {
+			args[n.tpIdx] = opt.Timers
+		}
+	}
 
 	// (2) Main input from value, if any.
 	i := 0
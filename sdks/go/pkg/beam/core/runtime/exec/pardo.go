@@ -19,13 +19,17 @@ import (
 	"context"
 	"fmt"
 	"path"
+	"sync/atomic"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/funcx"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/state"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/timers"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/tracex"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/util/errorx"
 )
@@ -37,24 +41,73 @@ type ParDo struct {
 	Inbound []*graph.Inbound
 	Side    []SideInputAdapter
 	Out     []Node
+	// UserState provides the state and timers of a stateful DoFn, which
+	// only runners supporting them set.
+	UserState UserStateAdapter
 
 	PID      string
 	emitters []ReusableEmitter
 	ctx      context.Context
+	elmCtx   *elementCtx
 	inv      *invoker
+	timerInv *invoker
 
 	side  StateReader
 	cache *cacheElm
 
+	// tracker is the element tracker of the plan, if any.
+	tracker *elementTracker
+	active  activeElement
+	// timer tracks the execution time of the phases of the transform in its
+	// plan, if any.
+	timer *transformTracker
+	// finalizer collects the bundle finalization callbacks of the DoFn for
+	// its plan, if any.
+	finalizer *bundleFinalizer
+
+	// span traces the bundle in the DoFn, with its number of elements.
+	span     *tracex.Span
+	elements int64
+
 	status Status
 	err    errorx.GuardedError
 }
 
+// UserStateAdapter provides the state and timers of a stateful DoFn for each
+// key and window of its input.
+type UserStateAdapter interface {
+	// NewProviders returns the state and timers of the key and window. The
+	// timestamp is that of the element or timer being processed.
+	NewProviders(ctx context.Context, key interface{}, w typex.Window, ts typex.EventTime) (state.Provider, timers.Provider, error)
+}
+
 // GetPID returns the PTransformID for this ParDo.
 func (n *ParDo) GetPID() string {
 	return n.PID
 }
 
+func (n *ParDo) setTracker(t *elementTracker) {
+	n.tracker = t
+}
+
+func (n *ParDo) setExecutionTracker(t *executionTracker) {
+	n.timer = t.transform(n.PID)
+}
+
+func (n *ParDo) setBundleFinalizer(f *bundleFinalizer) {
+	n.finalizer = f
+}
+
+// newInvoker returns an invoker for a method of the DoFn, which registers
+// its bundle finalization callbacks with the finalizer of the plan.
+func (n *ParDo) newInvoker(fn *funcx.Fn) *invoker {
+	inv := newInvoker(fn)
+	if n.finalizer != nil {
+		inv.bf = n.finalizer
+	}
+	return inv
+}
+
 // cacheElm holds per-window cached information about side input.
 type cacheElm struct {
 	key       typex.Window
@@ -73,7 +126,13 @@ func (n *ParDo) Up(ctx context.Context) error {
 		return errors.Errorf("invalid status for pardo %v: %v, want Initializing", n.UID, n.status)
 	}
 	n.status = Up
-	n.inv = newInvoker(n.Fn.ProcessElementFn())
+	if n.Fn.IsStateful() && n.UserState == nil {
+		return n.fail(errors.Errorf("stateful DoFn %v is not supported by the runner", n.Fn.Name()))
+	}
+	n.inv = n.newInvoker(n.Fn.ProcessElementFn())
+	if fn := n.Fn.OnTimerFn(); fn != nil {
+		n.timerInv = newInvoker(fn)
+	}
 
 	// We can't cache the context during Setup since it runs only once per bundle.
 	// Subsequent bundles might run this same node, and the context here would be
@@ -101,7 +160,10 @@ func (n *ParDo) StartBundle(ctx context.Context, id string, data DataContext) er
 	// Allocating contexts all the time is expensive, but we seldom re-write them,
 	// and never accept modified contexts from users, so we will cache them per-bundle
 	// per-unit, to avoid the constant allocation overhead.
+	ctx, n.span = tracex.Start(ctx, "ParDo", tracex.String("beam.ptransform_id", n.PID), tracex.String("beam.dofn", n.Fn.Name()))
+	n.elements = 0
 	n.ctx = metrics.SetPTransformID(ctx, n.PID)
+	n.elmCtx = &elementCtx{Context: n.ctx}
 
 	if err := MultiStartBundle(n.ctx, id, data, n.Out...); err != nil {
 		return n.fail(err)
@@ -109,6 +171,9 @@ func (n *ParDo) StartBundle(ctx context.Context, id string, data DataContext) er
 
 	// TODO(BEAM-3303): what to set for StartBundle/FinishBundle window and emitter timestamp?
 
+	if n.timer != nil {
+		defer n.timer.exit(n.timer.enter(startPhase))
+	}
 	if _, err := n.invokeDataFn(n.ctx, window.SingleGlobalWindow, mtime.ZeroTimestamp, n.Fn.StartBundleFn(), nil); err != nil {
 		return n.fail(err)
 	}
@@ -133,8 +198,9 @@ func (n *ParDo) processMainInput(mainIn *MainInput) error {
 
 	// If the function observes windows, we must invoke it for each window. The expected fast path
 	// is that either there is a single window or the function doesn't observe windows, so we can
-	// optimize it by treating all windows as a single one.
-	if !mustExplodeWindows(n.inv.fn, elm, len(n.Side) > 0) {
+	// optimize it by treating all windows as a single one. Stateful DoFns keep
+	// state per window, so they are always invoked for each window.
+	if n.UserState == nil && !mustExplodeWindows(n.inv.fn, elm, len(n.Side) > 0) {
 		return n.processSingleWindow(mainIn)
 	} else {
 		for _, w := range elm.Windows {
@@ -155,7 +221,22 @@ func (n *ParDo) processMainInput(mainIn *MainInput) error {
 // each individual window by exploding the windows first.
 func (n *ParDo) processSingleWindow(mainIn *MainInput) error {
 	elm := &mainIn.Key
-	val, err := n.invokeProcessFn(n.ctx, elm.Windows, elm.Timestamp, mainIn)
+	n.elmCtx.setTimestamp(elm.Timestamp)
+	n.elements++
+	if n.tracker != nil {
+		defer n.tracker.exit(n.tracker.enter(&n.active, n.PID, elm))
+	}
+	if n.timer != nil {
+		defer n.timer.exit(n.timer.enter(processPhase))
+	}
+	if n.UserState != nil {
+		sp, tp, err := n.UserState.NewProviders(n.ctx, elm.Elm, elm.Windows[0], elm.Timestamp)
+		if err != nil {
+			return n.fail(err)
+		}
+		mainIn.State, mainIn.Timers = sp, tp
+	}
+	val, err := n.invokeProcessFn(n.elmCtx, elm.Windows, elm.Timestamp, mainIn)
 	if err != nil {
 		return n.fail(err)
 	}
@@ -170,6 +251,39 @@ func (n *ParDo) processSingleWindow(mainIn *MainInput) error {
 	return nil
 }
 
+// ProcessTimer invokes the OnTimer method of the DoFn for a timer of the
+// family that fired for the key and window. The elements it emits have the
+// given timestamp.
+func (n *ParDo) ProcessTimer(_ context.Context, key interface{}, w typex.Window, family string, ts typex.EventTime) error {
+	if n.status != Active {
+		return errors.Errorf("invalid status for pardo %v: %v, want Active", n.UID, n.status)
+	}
+	if n.timerInv == nil {
+		return n.fail(errors.Errorf("timer %v fired for DoFn %v without an OnTimer method", family, n.Fn.Name()))
+	}
+	n.elmCtx.setTimestamp(ts)
+	if n.timer != nil {
+		defer n.timer.exit(n.timer.enter(processPhase))
+	}
+	ws := []typex.Window{w}
+	sp, tp, err := n.UserState.NewProviders(n.ctx, key, w, ts)
+	if err != nil {
+		return n.fail(err)
+	}
+	if err := n.preInvoke(n.elmCtx, ws, ts); err != nil {
+		return n.fail(err)
+	}
+	// OnTimer only takes the emitters, which follow the side inputs.
+	opt := &MainInput{Key: FullValue{Elm: key, Elm2: family, Timestamp: ts, Windows: ws}, State: sp, Timers: tp}
+	if _, err := n.timerInv.Invoke(n.elmCtx, ws, ts, opt, n.cache.extra[len(n.Side):]...); err != nil {
+		return n.fail(err)
+	}
+	if err := n.postInvoke(); err != nil {
+		return n.fail(err)
+	}
+	return nil
+}
+
 func rtErrHelper(err error) error {
 	if err != nil {
 		return err
@@ -197,8 +311,11 @@ func (n *ParDo) FinishBundle(_ context.Context) error {
 	}
 	n.status = Up
 	n.inv.Reset()
+	if n.timerInv != nil {
+		n.timerInv.Reset()
+	}
 
-	if _, err := n.invokeDataFn(n.ctx, window.SingleGlobalWindow, mtime.ZeroTimestamp, n.Fn.FinishBundleFn(), nil); err != nil {
+	if err := n.finishBundle(); err != nil {
 		return n.fail(err)
 	}
 	n.side = nil
@@ -207,9 +324,22 @@ func (n *ParDo) FinishBundle(_ context.Context) error {
 	if err := MultiFinishBundle(n.ctx, n.Out...); err != nil {
 		return n.fail(err)
 	}
+	n.span.SetAttributes(tracex.Int64("beam.elements", n.elements))
+	n.span.End(nil)
 	return nil
 }
 
+// finishBundle invokes the FinishBundle method of the DoFn, whose time is
+// attributed to the transform, unlike the time the downstream nodes spend
+// finishing the bundle.
+func (n *ParDo) finishBundle() error {
+	if n.timer != nil {
+		defer n.timer.exit(n.timer.enter(finishPhase))
+	}
+	_, err := n.invokeDataFn(n.ctx, window.SingleGlobalWindow, mtime.ZeroTimestamp, n.Fn.FinishBundleFn(), nil)
+	return err
+}
+
 // Down performs best-effort teardown of DoFn resources. (May not run.)
 func (n *ParDo) Down(ctx context.Context) error {
 	if n.status == Down {
@@ -261,6 +391,15 @@ func (n *ParDo) initSideInput(ctx context.Context, w typex.Window) error {
 		}
 		streams[i] = s
 	}
+	// Start reading all side inputs before any is read in full, so that
+	// their state requests are in flight together rather than one by one.
+	if len(streams) > 1 {
+		for _, s := range streams {
+			if p, ok := s.(prefetcher); ok {
+				p.prefetch()
+			}
+		}
+	}
 
 	sideinput, err := makeSideInputs(n.Fn.ProcessElementFn(), n.Inbound, streams)
 	if err != nil {
@@ -287,7 +426,7 @@ func (n *ParDo) invokeDataFn(ctx context.Context, ws []typex.Window, ts typex.Ev
 	if err := n.preInvoke(ctx, ws, ts); err != nil {
 		return nil, err
 	}
-	val, err := Invoke(ctx, ws, ts, fn, opt, n.cache.extra...)
+	val, err := n.newInvoker(fn).Invoke(ctx, ws, ts, opt, n.cache.extra...)
 	if err != nil {
 		return nil, err
 	}
@@ -332,6 +471,7 @@ func (n *ParDo) postInvoke() error {
 
 func (n *ParDo) fail(err error) error {
 	n.status = Broken
+	n.span.End(err)
 	if err2, ok := err.(*doFnError); ok {
 		return err2
 	}
@@ -349,3 +489,36 @@ func (n *ParDo) fail(err error) error {
 func (n *ParDo) String() string {
 	return fmt.Sprintf("ParDo[%v] Out:%v", path.Base(n.Fn.Name()), IDs(n.Out...))
 }
+
+type contextKey string
+
+const elementTimestampKey contextKey = "beam:element_timestamp"
+
+// elementCtx is the context the DoFn processes elements with, which exposes
+// the event time of the current element, such as for loggers. It is reused
+// for all the elements of a bundle to avoid allocating a context per element,
+// so the timestamp is accessed atomically, in case the DoFn hands the context
+// to other goroutines.
+type elementCtx struct {
+	context.Context
+	ts int64 // typex.EventTime
+}
+
+func (c *elementCtx) setTimestamp(ts typex.EventTime) {
+	atomic.StoreInt64(&c.ts, int64(ts))
+}
+
+// Value implements the Context interface Value method for elementCtx.
+func (c *elementCtx) Value(key interface{}) interface{} {
+	if key == elementTimestampKey {
+		return typex.EventTime(atomic.LoadInt64(&c.ts))
+	}
+	return c.Context.Value(key)
+}
+
+// ElementTimestamp returns the event time of the element being processed by
+// the DoFn with the given context, if any.
+func ElementTimestamp(ctx context.Context) (typex.EventTime, bool) {
+	ts, ok := ctx.Value(elementTimestampKey).(typex.EventTime)
+	return ts, ok
+}
@@ -17,13 +17,22 @@ package exec
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/tracex"
 )
 
 func sumFn(n int, a int, b []int, c func(*int) bool, d func() func(*int) bool, e func(int)) int {
@@ -97,6 +106,278 @@ func TestParDo(t *testing.T) {
 	}
 }
 
+func timestampFn(ctx context.Context, n int, emit func(string)) {
+	ts, _ := ElementTimestamp(ctx)
+	pid, _ := metrics.GetPTransformID(ctx)
+	emit(fmt.Sprintf("%v:%v", pid, ts))
+}
+
+// TestParDo_ElementTimestamp verifies that the context of the DoFn exposes the
+// timestamp of the element being processed, and the id of its transform.
+func TestParDo_ElementTimestamp(t *testing.T) {
+	fn, err := graph.NewDoFn(timestampFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, PID: "pardo", Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{out}}
+	var in []MainInput
+	for _, ts := range []mtime.Time{5, 10, 7} {
+		in = append(in, MainInput{Key: FullValue{Windows: window.SingleGlobalWindow, Timestamp: ts, Elm: 1}})
+	}
+	n := &FixedRoot{UID: 3, Elements: in, Out: pardo}
+
+	p, err := NewPlan("a", []Unit{n, pardo, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if err := p.Down(context.Background()); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+
+	var got []interface{}
+	for _, elm := range out.Elements {
+		got = append(got, elm.Elm)
+	}
+	want := []interface{}{"pardo:5", "pardo:10", "pardo:7"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pardo(timestampFn) = %v, want %v", got, want)
+	}
+	if _, ok := ElementTimestamp(context.Background()); ok {
+		t.Error("ElementTimestamp(context.Background()) = _, true, want false")
+	}
+}
+
+// TestParDo_ActiveElement verifies that plans report the most downstream
+// ParDo processing an element, and its element, while they are processed.
+func TestParDo_ActiveElement(t *testing.T) {
+	var p *Plan
+	var got []string
+	report := func() {
+		pid, elm, ok := p.ActiveElement()
+		got = append(got, fmt.Sprintf("%v:%v:%v", pid, elm, ok))
+	}
+	outer, err := graph.NewDoFn(func(n int, emit func(int)) {
+		report()
+		emit(n * 2)
+		report()
+	})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	inner, err := graph.NewDoFn(func(n int) {
+		report()
+	})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	mN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	outerEdge, err := graph.NewParDo(g, g.Root(), outer, []*graph.Node{nN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+	innerEdge, err := graph.NewParDo(g, g.Root(), inner, []*graph.Node{mN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	innerDo := &ParDo{UID: 1, PID: "inner", Fn: innerEdge.DoFn, Inbound: innerEdge.Input}
+	outerDo := &ParDo{UID: 2, PID: "outer", Fn: outerEdge.DoFn, Inbound: outerEdge.Input, Out: []Node{innerDo}}
+	in := MainInput{Key: FullValue{Windows: window.SingleGlobalWindow, Timestamp: 5, Elm: 3}}
+	n := &FixedRoot{UID: 3, Elements: []MainInput{in}, Out: outerDo}
+
+	p, err = NewPlan("a", []Unit{n, outerDo, innerDo})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	report()
+
+	elm := func(n int) string {
+		return (&FullValue{Windows: window.SingleGlobalWindow, Timestamp: 5, Elm: n}).String()
+	}
+	want := []string{
+		"outer:" + elm(3) + ":true",
+		"inner:" + elm(6) + ":true",
+		"outer:" + elm(3) + ":true",
+		"::false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ActiveElement() = %v, want %v", got, want)
+	}
+}
+
+// TestParDo_ExecutionTimes verifies that plans measure the time ParDos spend
+// processing their bundles, excluding the time of their downstream ParDos.
+func TestParDo_ExecutionTimes(t *testing.T) {
+	var p *Plan
+	now := time.Unix(0, 0)
+	// advance advances the time, and samples the executing ParDo, as the
+	// sampling goroutine would.
+	advance := func(d time.Duration) {
+		now = now.Add(d)
+		p.timer.sample()
+	}
+	outer, err := graph.NewDoFn(func(n int, emit func(int)) {
+		advance(10 * time.Millisecond)
+		emit(n * 2)
+		advance(5 * time.Millisecond)
+	})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	inner, err := graph.NewDoFn(func(n int) {
+		advance(3 * time.Millisecond)
+	})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	mN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	outerEdge, err := graph.NewParDo(g, g.Root(), outer, []*graph.Node{nN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+	innerEdge, err := graph.NewParDo(g, g.Root(), inner, []*graph.Node{mN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	innerDo := &ParDo{UID: 1, PID: "inner", Fn: innerEdge.DoFn, Inbound: innerEdge.Input}
+	outerDo := &ParDo{UID: 2, PID: "outer", Fn: outerEdge.DoFn, Inbound: outerEdge.Input, Out: []Node{innerDo}}
+	n := &FixedRoot{UID: 3, Elements: makeInput(1, 2), Out: outerDo}
+
+	p, err = NewPlan("a", []Unit{n, outerDo, innerDo})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	p.timer.now = func() time.Time { return now }
+	p.timer.period = time.Hour
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	want := []ExecutionTimeSnapshot{
+		{PID: "inner", Process: 6 * time.Millisecond},
+		{PID: "outer", Process: 30 * time.Millisecond},
+	}
+	if got := p.ExecutionTimes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExecutionTimes() = %v, want %v", got, want)
+	}
+}
+
+// TestParDo_Trace verifies that ParDos trace their bundles, with their number
+// of elements, when the context has an exporter.
+// TestParDo_BundleFinalization tests that the callbacks registered by a DoFn
+// only run when the plan is finalized, unless their deadlines have passed.
+func TestParDo_BundleFinalization(t *testing.T) {
+	var finalized []int
+	fn, err := graph.NewDoFn(func(bf typex.BundleFinalization, n int) {
+		d := time.Hour
+		if n == 2 {
+			d = -time.Hour // Expired before the bundle is committed.
+		}
+		bf.RegisterCallback(d, func() error {
+			finalized = append(finalized, n)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	pardo := &ParDo{UID: 1, PID: "finalizing", Fn: edge.DoFn, Inbound: edge.Input}
+	n := &FixedRoot{UID: 2, Elements: makeInput(1, 2, 3), Out: pardo}
+
+	p, err := NewPlan("a", []Unit{n, pardo})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if !p.RequiresFinalization() {
+		t.Fatalf("RequiresFinalization() = false, want true")
+	}
+	if len(finalized) != 0 {
+		t.Fatalf("callbacks ran before finalization: %v", finalized)
+	}
+	if err := p.Finalize(); err != nil {
+		t.Fatalf("Finalize() failed: %v", err)
+	}
+	if want := []int{1, 3}; !reflect.DeepEqual(finalized, want) {
+		t.Errorf("finalized %v, want %v", finalized, want)
+	}
+	if p.RequiresFinalization() {
+		t.Errorf("RequiresFinalization() after Finalize() = true, want false")
+	}
+}
+
+func TestParDo_Trace(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	fn, err := graph.NewDoFn(emitSumFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, PID: "pardo", Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{out}}
+	n := &FixedRoot{UID: 3, Elements: makeInput(1, 2, 3), Out: pardo}
+	p, err := NewPlan("a", []Unit{n, pardo, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+
+	ctx := context.Background()
+	e := tracex.NewExporter(ctx, srv.URL)
+	if err := p.Execute(tracex.WithExporter(ctx, e), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if err := e.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	for _, want := range []string{`"name":"ParDo"`, `"stringValue":"pardo"`, `"key":"beam.elements","value":{"intValue":"3"}`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("exported %s, want %s", body, want)
+		}
+	}
+}
+
 func emitSumFn(n int, emit func(int)) {
 	emit(n + 1)
 }
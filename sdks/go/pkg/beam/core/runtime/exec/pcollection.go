@@ -0,0 +1,171 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+)
+
+// PCollection is a passthrough node counting the elements of a PCollection,
+// and sampling their encoded sizes, for the metrics of the PCollection. It is
+// inserted as the Out node of the producers of each PCollection of a plan.
+type PCollection struct {
+	UID    UnitID
+	PColID string
+	Coder  *coder.Coder // Element coder of the PCollection, if sizes are sampled.
+	Seed   int64
+	Out    Node
+
+	enc ElementEncoder
+	r   *rand.Rand
+	// nextSample is the count of the next element to sample.
+	nextSample int64
+
+	elementCount int64 // must be accessed atomically.
+
+	mu                                   sync.Mutex
+	sizeCount, sizeSum, sizeMin, sizeMax int64
+}
+
+// PCollectionSnapshot is the element count and sampled sizes of the elements
+// of a PCollection in a bundle.
+type PCollectionSnapshot struct {
+	ID           string
+	ElementCount int64
+	// SizeCount is the number of sampled sizes, in bytes, summarized by
+	// their SizeSum, SizeMin and SizeMax.
+	SizeCount, SizeSum, SizeMin, SizeMax int64
+}
+
+// pcollectionSampleThreshold is the number of elements of each bundle whose
+// sizes are always sampled. Beyond it, elements are sampled increasingly
+// rarely, so the number of samples grows with the logarithm of the count.
+const pcollectionSampleThreshold = 10
+
+// canSampleSizes returns whether elements of the coder can be encoded one at
+// a time, which isn't the case for grouped values.
+func canSampleSizes(c *coder.Coder) bool {
+	switch c.Kind {
+	case coder.CoGBK, coder.Row, coder.Timer:
+		return false
+	}
+	for _, cc := range c.Components {
+		if !canSampleSizes(cc) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *PCollection) ID() UnitID {
+	return n.UID
+}
+
+// Up initializes the element encoder, and the random source for sampling.
+func (n *PCollection) Up(ctx context.Context) error {
+	if n.Coder != nil {
+		n.enc = MakeElementEncoder(n.Coder)
+	}
+	n.r = rand.New(rand.NewSource(n.Seed))
+	return nil
+}
+
+// StartBundle resets the metrics of the PCollection for the bundle.
+func (n *PCollection) StartBundle(ctx context.Context, id string, data DataContext) error {
+	atomic.StoreInt64(&n.elementCount, 0)
+	n.nextSample = 1
+	n.mu.Lock()
+	n.sizeCount, n.sizeSum, n.sizeMin, n.sizeMax = 0, 0, 0, 0
+	n.mu.Unlock()
+	return MultiStartBundle(ctx, id, data, n.Out)
+}
+
+func (n *PCollection) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	count := atomic.AddInt64(&n.elementCount, 1)
+	if n.enc != nil && count == n.nextSample {
+		n.nextSample = count + n.sampleGap(count)
+		var w byteCounter
+		// Elements that fail to encode aren't sampled, so the consumers report
+		// their errors.
+		if err := n.enc.Encode(elm, &w); err == nil {
+			n.addSize(int64(w))
+		}
+	}
+	return n.Out.ProcessElement(ctx, elm, values...)
+}
+
+// sampleGap returns the number of elements until the next sampled element,
+// after the given count of elements.
+func (n *PCollection) sampleGap(count int64) int64 {
+	if count < pcollectionSampleThreshold {
+		return 1
+	}
+	return 1 + n.r.Int63n(2*count/pcollectionSampleThreshold)
+}
+
+func (n *PCollection) addSize(size int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.sizeCount == 0 || size < n.sizeMin {
+		n.sizeMin = size
+	}
+	if size > n.sizeMax {
+		n.sizeMax = size
+	}
+	n.sizeCount++
+	n.sizeSum += size
+}
+
+func (n *PCollection) FinishBundle(ctx context.Context) error {
+	return MultiFinishBundle(ctx, n.Out)
+}
+
+func (n *PCollection) Down(ctx context.Context) error {
+	return nil
+}
+
+// Snapshot returns the metrics of the PCollection in the current bundle.
+// It is safe to call while the bundle is processed.
+func (n *PCollection) Snapshot() PCollectionSnapshot {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return PCollectionSnapshot{
+		ID:           n.PColID,
+		ElementCount: atomic.LoadInt64(&n.elementCount),
+		SizeCount:    n.sizeCount,
+		SizeSum:      n.sizeSum,
+		SizeMin:      n.sizeMin,
+		SizeMax:      n.sizeMax,
+	}
+}
+
+func (n *PCollection) String() string {
+	return fmt.Sprintf("PCollection[%v] Coder:%v Out:%v", n.PColID, n.Coder, IDs(n.Out))
+}
+
+// byteCounter is a writer counting the bytes written to it.
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,102 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+)
+
+// TestPCollection verifies that the PCollection node counts the elements, and
+// samples their sizes.
+func TestPCollection(t *testing.T) {
+	a := &CaptureNode{UID: 1}
+	pcol := &PCollection{UID: 2, PColID: "p1", Coder: coder.NewString(), Seed: 42, Out: a}
+	in := &FixedRoot{UID: 3, Elements: makeInput("a", "bb", "cccc"), Out: pcol}
+
+	p, err := NewPlan("a", []Unit{a, pcol, in})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if err := p.Down(context.Background()); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+
+	expected := makeValues("a", "bb", "cccc")
+	if !equalList(a.Elements, expected) {
+		t.Errorf("pcollection returned %v, want %v", extractValues(a.Elements...), extractValues(expected...))
+	}
+	// Strings are encoded with a varint length prefix.
+	want := []PCollectionSnapshot{{ID: "p1", ElementCount: 3, SizeCount: 3, SizeSum: 10, SizeMin: 2, SizeMax: 5}}
+	if got := p.PCollections(); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("p.PCollections() = %v, want %v", got, want)
+	}
+}
+
+// TestPCollection_Sampling verifies that the sizes of large bundles are only
+// sampled, and that the counts are reset for each bundle.
+func TestPCollection_Sampling(t *testing.T) {
+	var values []interface{}
+	for i := 0; i < 10000; i++ {
+		values = append(values, "abc")
+	}
+	pcol := &PCollection{UID: 1, PColID: "p1", Coder: coder.NewString(), Seed: 42, Out: &Discard{UID: 2}}
+	in := &FixedRoot{UID: 3, Elements: makeInput(values...), Out: pcol}
+
+	p, err := NewPlan("a", []Unit{pcol.Out.(Unit), pcol, in})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := p.Execute(context.Background(), id, DataContext{}); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		got := pcol.Snapshot()
+		if got.ElementCount != 10000 {
+			t.Errorf("bundle %v: ElementCount = %v, want 10000", id, got.ElementCount)
+		}
+		if got.SizeCount <= pcollectionSampleThreshold || got.SizeCount >= 1000 {
+			t.Errorf("bundle %v: SizeCount = %v, want between %v and 1000", id, got.SizeCount, pcollectionSampleThreshold)
+		}
+		if got.SizeMin != 4 || got.SizeMax != 4 || got.SizeSum != 4*got.SizeCount {
+			t.Errorf("bundle %v: sizes = %+v, want all 4", id, got)
+		}
+	}
+}
+
+// TestPCollection_NoCoder verifies that the elements are counted, but not
+// sampled, without a coder.
+func TestPCollection_NoCoder(t *testing.T) {
+	a := &CaptureNode{UID: 1}
+	pcol := &PCollection{UID: 2, PColID: "p1", Out: a}
+	in := &FixedRoot{UID: 3, Elements: makeInput(1, 2), Out: pcol}
+
+	p, err := NewPlan("a", []Unit{a, pcol, in})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if got, want := pcol.Snapshot(), (PCollectionSnapshot{ID: "p1", ElementCount: 2}); got != want {
+		t.Errorf("pcol.Snapshot() = %v, want %v", got, want)
+	}
+}
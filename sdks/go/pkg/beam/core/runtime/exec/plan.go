@@ -46,6 +46,11 @@ type Plan struct {
 
 	// TODO: there can be more than 1 DataSource in a bundle.
 	source *DataSource
+	pcols  []*PCollection
+
+	tracker   *elementTracker
+	timer     *executionTracker
+	finalizer *bundleFinalizer
 }
 
 // hasPID provides a common interface for extracting PTransformIDs
@@ -59,6 +64,10 @@ func NewPlan(id string, units []Unit) (*Plan, error) {
 	var roots []Root
 	var source *DataSource
 	var pardoIDs []string
+	var pcols []*PCollection
+	tracker := &elementTracker{}
+	timer := &executionTracker{}
+	finalizer := newBundleFinalizer()
 
 	for _, u := range units {
 		if u == nil {
@@ -70,21 +79,37 @@ func NewPlan(id string, units []Unit) (*Plan, error) {
 		if s, ok := u.(*DataSource); ok {
 			source = s
 		}
+		if c, ok := u.(*PCollection); ok {
+			pcols = append(pcols, c)
+		}
 		if p, ok := u.(hasPID); ok {
 			pardoIDs = append(pardoIDs, p.GetPID())
 		}
+		if t, ok := u.(trackedUnit); ok {
+			t.setTracker(tracker)
+		}
+		if t, ok := u.(timedUnit); ok {
+			t.setExecutionTracker(timer)
+		}
+		if f, ok := u.(finalizingUnit); ok {
+			f.setBundleFinalizer(finalizer)
+		}
 	}
 	if len(roots) == 0 {
 		return nil, errors.Errorf("no root units")
 	}
 
 	return &Plan{
-		id:       id,
-		status:   Initializing,
-		roots:    roots,
-		units:    units,
-		parDoIDs: pardoIDs,
-		source:   source,
+		id:        id,
+		status:    Initializing,
+		roots:     roots,
+		units:     units,
+		parDoIDs:  pardoIDs,
+		source:    source,
+		pcols:     pcols,
+		tracker:   tracker,
+		timer:     timer,
+		finalizer: finalizer,
 	}, nil
 }
 
@@ -126,6 +151,9 @@ func (p *Plan) Execute(ctx context.Context, id string, manager DataContext) erro
 	// Process bundle. If there are any kinds of failures, we bail and mark the plan broken.
 
 	p.status = Active
+	p.finalizer.reset()
+	p.timer.start()
+	defer p.timer.end()
 	for _, root := range p.roots {
 		if err := callNoPanic(ctx, func(ctx context.Context) error { return root.StartBundle(ctx, id, manager) }); err != nil {
 			p.status = Broken
@@ -149,6 +177,24 @@ func (p *Plan) Execute(ctx context.Context, id string, manager DataContext) erro
 	return nil
 }
 
+// RequiresFinalization returns whether the DoFns of the plan registered
+// bundle finalization callbacks during the last bundle, which the runner
+// must call Finalize for once it has committed the bundle.
+func (p *Plan) RequiresFinalization() bool {
+	return p.finalizer.pending()
+}
+
+// Finalize runs the bundle finalization callbacks registered so far, unless
+// their deadlines have passed. Runners call it once they have committed the
+// output of the bundle, or, if they process the output in memory as it is
+// emitted, once the output emitted so far is processed.
+func (p *Plan) Finalize() error {
+	if err := p.finalizer.finalize(); err != nil {
+		return errors.Wrapf(err, "while finalizing bundle for %v", p)
+	}
+	return nil
+}
+
 // Down takes the plan and associated units down. Does not panic.
 func (p *Plan) Down(ctx context.Context) error {
 	if p.status == Down {
@@ -189,6 +235,28 @@ func (p *Plan) Progress() (ProgressReportSnapshot, bool) {
 	return ProgressReportSnapshot{}, false
 }
 
+// PCollections returns snapshots of the element counts and sampled sizes of
+// the PCollections of the plan, in the current bundle.
+func (p *Plan) PCollections() []PCollectionSnapshot {
+	var ret []PCollectionSnapshot
+	for _, c := range p.pcols {
+		ret = append(ret, c.Snapshot())
+	}
+	return ret
+}
+
+// ExecutionTimes returns snapshots of the time each transform of the plan
+// spent in each phase of the current bundle.
+func (p *Plan) ExecutionTimes() []ExecutionTimeSnapshot {
+	return p.timer.snapshot()
+}
+
+// ActiveElement returns the ID of the transform processing an element in the
+// plan, and the formatted element, if any are being processed.
+func (p *Plan) ActiveElement() (string, string, bool) {
+	return p.tracker.current()
+}
+
 // Store returns the metric store for the last use of this plan.
 func (p *Plan) Store() *metrics.Store {
 	p.storeMu.Lock()
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"math"
 	"path"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
@@ -252,6 +253,18 @@ func (n *ProcessSizedElementsAndRestrictions) ID() UnitID {
 	return n.PDo.ID()
 }
 
+func (n *ProcessSizedElementsAndRestrictions) setTracker(t *elementTracker) {
+	n.PDo.setTracker(t)
+}
+
+func (n *ProcessSizedElementsAndRestrictions) setExecutionTracker(t *executionTracker) {
+	n.PDo.setExecutionTracker(t)
+}
+
+func (n *ProcessSizedElementsAndRestrictions) setBundleFinalizer(f *bundleFinalizer) {
+	n.PDo.setBundleFinalizer(f)
+}
+
 // Up performs some one-time setup and then calls the ParDo's Up method.
 func (n *ProcessSizedElementsAndRestrictions) Up(ctx context.Context) error {
 	fn := (*graph.SplittableDoFn)(n.PDo.Fn).CreateTrackerFn()
@@ -408,6 +421,10 @@ type SplittableUnit interface {
 	// processed.)
 	GetProgress() float64
 
+	// GetWorkProgress returns the amount of work completed and remaining on
+	// the current element, in the units of its restriction tracker.
+	GetWorkProgress() (completed, remaining float64)
+
 	// GetTransformId returns the transform ID of the splittable unit.
 	GetTransformId() string
 
@@ -653,6 +670,18 @@ func (n *ProcessSizedElementsAndRestrictions) GetProgress() float64 {
 	return (float64(n.currW) + frac) / float64(n.numW)
 }
 
+// GetWorkProgress returns the current restriction tracker's completed and
+// remaining work. For window-observing DoFns, every window is assumed to have
+// as much work as the current one.
+func (n *ProcessSizedElementsAndRestrictions) GetWorkProgress() (float64, float64) {
+	d, r := n.rt.GetProgress()
+	if n.numW == 1 {
+		return d, r
+	}
+	total := d + r
+	return float64(n.currW)*total + d, float64(n.numW-n.currW-1)*total + r
+}
+
 // GetTransformId returns this transform's transform ID.
 func (n *ProcessSizedElementsAndRestrictions) GetTransformId() string {
 	return n.TfId
@@ -680,6 +709,10 @@ func (n *SdfFallback) ID() UnitID {
 	return n.PDo.UID
 }
 
+func (n *SdfFallback) setBundleFinalizer(f *bundleFinalizer) {
+	n.PDo.setBundleFinalizer(f)
+}
+
 // Up performs some one-time setup and then calls the ParDo's Up method.
 func (n *SdfFallback) Up(ctx context.Context) error {
 	dfn := (*graph.SplittableDoFn)(n.PDo.Fn)
@@ -710,31 +743,83 @@ func (n *SdfFallback) StartBundle(ctx context.Context, id string, data DataConte
 // restriction with the underlying ParDo. This executor skips the sizing step
 // because sizing information is unnecessary for unexpanded SDFs.
 func (n *SdfFallback) ProcessElement(_ context.Context, elm *FullValue, values ...ReStream) error {
+	splitRests, err := n.InitialRestrictions(elm)
+	if err != nil {
+		return err
+	}
+	for _, splitRest := range splitRests {
+		if _, err := n.ProcessRestriction(elm, splitRest, values, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InitialRestrictions creates the initial restriction of an element and
+// performs the initial split of that restriction.
+func (n *SdfFallback) InitialRestrictions(elm *FullValue) ([]interface{}, error) {
 	if n.PDo.status != Active {
 		err := errors.Errorf("invalid status %v, want Active", n.PDo.status)
-		return errors.WithContextf(err, "%v", n)
+		return nil, errors.WithContextf(err, "%v", n)
 	}
 
 	rest := n.initRestInv.Invoke(elm)
 	splitRests := n.splitInv.Invoke(elm, rest)
 	if len(splitRests) == 0 {
 		err := errors.Errorf("initial splitting returned 0 restrictions.")
-		return errors.WithContextf(err, "%v", n)
+		return nil, errors.WithContextf(err, "%v", n)
 	}
+	return splitRests, nil
+}
 
-	for _, splitRest := range splitRests {
-		rt := n.trackerInv.Invoke(splitRest)
-		mainIn := &MainInput{
-			Key:      *elm,
-			Values:   values,
-			RTracker: rt,
-		}
-		if err := n.PDo.processMainInput(mainIn); err != nil {
-			return err
-		}
+// ProcessRestriction processes an element with the underlying ParDo for a
+// single restriction. If checkpoint is positive and the restriction tracker is
+// an sdf.LockRTracker, the restriction is checkpointed once it has been
+// processed for that long, and the residual restriction is returned.
+// Otherwise the restriction is processed to completion and the residual is
+// nil.
+func (n *SdfFallback) ProcessRestriction(elm *FullValue, rest interface{}, values []ReStream, checkpoint time.Duration) (interface{}, error) {
+	if n.PDo.status != Active {
+		err := errors.Errorf("invalid status %v, want Active", n.PDo.status)
+		return nil, errors.WithContextf(err, "%v", n)
 	}
 
-	return nil
+	rt := n.trackerInv.Invoke(rest)
+	mainIn := &MainInput{
+		Key:      *elm,
+		Values:   values,
+		RTracker: rt,
+	}
+	lrt, ok := rt.(*sdf.LockRTracker)
+	if checkpoint <= 0 || !ok {
+		return nil, n.PDo.processMainInput(mainIn)
+	}
+
+	// Checkpoint from another goroutine. The ParDo stops at its next claim.
+	var residual interface{}
+	var splitErr error
+	done := make(chan struct{})
+	split := make(chan struct{})
+	go func() {
+		defer close(split)
+		timer := time.NewTimer(checkpoint)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			_, residual, splitErr = lrt.TrySplit(0)
+		case <-done:
+		}
+	}()
+	err := n.PDo.processMainInput(mainIn)
+	close(done)
+	<-split
+	if err != nil {
+		return nil, err
+	}
+	if splitErr != nil {
+		return nil, errors.WithContextf(splitErr, "checkpointing %v", n)
+	}
+	return residual, nil
 }
 
 // FinishBundle resets the invokers and then calls the ParDo's FinishBundle method.
@@ -509,6 +509,8 @@ func TestAsSplittableUnit(t *testing.T) {
 			remainingWork float64 // Will be output by RTracker's GetProgress.
 			currWindow    int
 			wantProgress  float64
+			wantCompleted float64
+			wantRemaining float64
 		}{
 			{
 				name:          "SingleWindow",
@@ -517,6 +519,8 @@ func TestAsSplittableUnit(t *testing.T) {
 				remainingWork: 1.0,
 				currWindow:    0,
 				wantProgress:  0.5,
+				wantCompleted: 1.0,
+				wantRemaining: 1.0,
 			},
 			{
 				name:          "MultipleWindows",
@@ -525,7 +529,9 @@ func TestAsSplittableUnit(t *testing.T) {
 				remainingWork: 1.0,
 				currWindow:    1,
 				// Progress should be halfway through second window.
-				wantProgress: 1.5 / 4.0,
+				wantProgress:  1.5 / 4.0,
+				wantCompleted: 3.0,
+				wantRemaining: 5.0,
 			},
 		}
 		for _, test := range tests {
@@ -565,6 +571,10 @@ func TestAsSplittableUnit(t *testing.T) {
 				if !floatEquals(got, test.wantProgress, 0.00001) {
 					t.Fatalf("SplittableUnit.GetProgress() got incorrect progress: got %v, want %v", got, test.wantProgress)
 				}
+				gotD, gotR := su.GetWorkProgress()
+				if !floatEquals(gotD, test.wantCompleted, 0.00001) || !floatEquals(gotR, test.wantRemaining, 0.00001) {
+					t.Fatalf("SplittableUnit.GetWorkProgress() got incorrect work: got (%v, %v), want (%v, %v)", gotD, gotR, test.wantCompleted, test.wantRemaining)
+				}
 			})
 		}
 	})
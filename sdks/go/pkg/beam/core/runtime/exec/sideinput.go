@@ -83,12 +83,35 @@ func (s *sideInputAdapter) String() string {
 // proxyReStream is a simple wrapper of an open function.
 type proxyReStream struct {
 	open func() (Stream, error)
+
+	// prefetched is the stream returned by the next Open, if opened early.
+	prefetched Stream
+	err        error
 }
 
 func (p *proxyReStream) Open() (Stream, error) {
+	if p.prefetched != nil || p.err != nil {
+		s, err := p.prefetched, p.err
+		p.prefetched, p.err = nil, nil
+		return s, err
+	}
 	return p.open()
 }
 
+// prefetch opens the stream returned by the next Open, so that it starts
+// reading before the stream is used.
+func (p *proxyReStream) prefetch() {
+	if p.prefetched == nil && p.err == nil {
+		p.prefetched, p.err = p.open()
+	}
+}
+
+// prefetcher is implemented by ReStreams that can start reading before they
+// are opened.
+type prefetcher interface {
+	prefetch()
+}
+
 // elementStream exposes a Stream from decoding elements.
 type elementStream struct {
 	r  io.ReadCloser
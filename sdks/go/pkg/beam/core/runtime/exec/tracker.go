@@ -0,0 +1,77 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+	"sync"
+)
+
+// maxElementDump is the maximum length of the elements reported as active.
+const maxElementDump = 1 << 10
+
+// activeElement is an element being processed by a ParDo.
+type activeElement struct {
+	pid string
+	elm FullValue
+}
+
+// elementTracker tracks the element being processed by the ParDos of a plan,
+// so that the harness can report where a bundle is stuck. Since ParDos
+// process the outputs of their upstream ParDos in the same goroutine, the
+// active element is the one of the most downstream ParDo processing one.
+type elementTracker struct {
+	mu     sync.Mutex
+	active *activeElement
+}
+
+// enter makes the element of the ParDo active, using a to avoid allocating,
+// and returns the previously active element to restore on exit.
+func (t *elementTracker) enter(a *activeElement, pid string, elm *FullValue) *activeElement {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a.pid, a.elm = pid, *elm
+	prev := t.active
+	t.active = a
+	return prev
+}
+
+func (t *elementTracker) exit(prev *activeElement) {
+	t.mu.Lock()
+	t.active = prev
+	t.mu.Unlock()
+}
+
+// current returns the transform and the formatted element being processed,
+// if any.
+func (t *elementTracker) current() (string, string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active == nil {
+		return "", "", false
+	}
+	elm := t.active.elm.String()
+	if len(elm) > maxElementDump {
+		elm = fmt.Sprintf("%v... (%d bytes)", elm[:maxElementDump], len(elm))
+	}
+	return t.active.pid, elm, true
+}
+
+// trackedUnit is a unit reporting its active elements to the tracker of its
+// plan.
+type trackedUnit interface {
+	setTracker(t *elementTracker)
+}
@@ -49,6 +49,11 @@ const (
 	urnProcessSizedElementsAndRestrictions = "beam:transform:sdf_process_sized_element_and_restrictions:v1"
 )
 
+func init() {
+	// The expanded SDF steps above are executed by the units in sdf.go.
+	graphx.RegisterCapability(graphx.URNRequiresSplittableDoFn)
+}
+
 // UnmarshalPlan converts a model bundle descriptor into an execution Plan.
 func UnmarshalPlan(desc *fnpb.ProcessBundleDescriptor) (*Plan, error) {
 	b, err := newBuilder(desc)
@@ -278,7 +283,14 @@ func (b *builder) makePCollection(id string) (Node, error) {
 		u = &Discard{UID: b.idgen.New()}
 
 	case 1:
-		return b.makeLink(id, list[0])
+		out, err := b.makeLink(id, list[0])
+		if err != nil {
+			return nil, err
+		}
+		u = b.makePCollectionMetrics(id, out)
+		b.nodes[id] = u
+		b.units = append(b.units, u)
+		return u, nil
 
 	default:
 		// Multiplex.
@@ -289,6 +301,8 @@ func (b *builder) makePCollection(id string) (Node, error) {
 		}
 		u = &Multiplex{UID: b.idgen.New(), Out: out}
 	}
+	b.units = append(b.units, u)
+	u = b.makePCollectionMetrics(id, u)
 
 	if count := b.prev[id]; count > 1 {
 		// Guard node with Flatten, if needed.
@@ -302,6 +316,17 @@ func (b *builder) makePCollection(id string) (Node, error) {
 	return u, nil
 }
 
+// makePCollectionMetrics returns a node counting the elements of the
+// PCollection, and sampling their sizes, before they reach the given node.
+func (b *builder) makePCollectionMetrics(id string, out Node) Node {
+	n := &PCollection{UID: b.idgen.New(), PColID: id, Out: out, Seed: rand.Int63()}
+	// The sizes aren't sampled if the elements can't be encoded.
+	if c, _, err := b.makeCoderForPCollection(id); err == nil && canSampleSizes(c) {
+		n.Coder = c
+	}
+	return n
+}
+
 func (b *builder) makeLinks(from string, ids []linkID) ([]Node, error) {
 	var ret []Node
 	for _, id := range ids {
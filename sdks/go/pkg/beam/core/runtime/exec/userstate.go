@@ -0,0 +1,355 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/state"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/timers"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// StatefulParDo executes a stateful DoFn, keeping its state and timers in
+// memory for each key and window of its input, for runners executing the
+// DoFn in a single process. Timers fire when the runner advances the
+// watermark or processing time past them, in the order of their firing
+// times.
+//
+// Timers set while timers fire wait for the next advancement, so timers that
+// set themselves again do not keep a single advancement from returning.
+//
+// Event time timers set after the end of their window are ignored, as the
+// window has expired by then.
+//
+// State is kept encoded with the coders of the state cells, so the values
+// read are copies of those written, as with other runners.
+type StatefulParDo struct {
+	*ParDo
+	// Key encodes the keys of the input.
+	Key ElementEncoder
+	// Window encodes the windows of the input.
+	Window WindowEncoder
+	// State has the coders of the state cells, by state ID.
+	State map[string]*StateCoder
+	// ProcessingTime returns the processing time timers are set relative to,
+	// or nil for the wall time.
+	ProcessingTime func() mtime.Time
+
+	cells   map[string]*cell
+	event   timerQueue
+	process timerQueue
+	seq     int64 // Orders timers set for the same time.
+}
+
+// StateCoder encodes and decodes the values of a state cell.
+type StateCoder struct {
+	// T is the type of the values.
+	T   reflect.Type
+	Enc ElementEncoder
+	Dec ElementDecoder
+}
+
+// NewStateCoder returns the StateCoder of state values encoded with the
+// given coder.
+func NewStateCoder(c *coder.Coder) *StateCoder {
+	return &StateCoder{T: c.T.Type(), Enc: MakeElementEncoder(c), Dec: MakeElementDecoder(c)}
+}
+
+// cell is the state and timers of a key and window. Each state has its
+// encoded values, of which a Value has one.
+type cell struct {
+	key    interface{}
+	w      typex.Window
+	state  map[string][][]byte
+	timers map[timerID]*timer
+}
+
+type timerID struct {
+	family string
+	domain timers.Domain
+}
+
+// timer is a timer set for a key and window.
+type timer struct {
+	cell   *cell
+	id     timerID
+	firing mtime.Time
+	ts     mtime.Time // Timestamp of the elements emitted when it fires.
+	seq    int64
+	index  int // Index in its queue, or -1 if not queued.
+}
+
+// Up provides the state and timers of the DoFn, and brings it up.
+func (n *StatefulParDo) Up(ctx context.Context) error {
+	n.cells = make(map[string]*cell)
+	n.ParDo.UserState = n
+	return n.ParDo.Up(ctx)
+}
+
+// NewProviders returns the state and timers of the cell of the key and
+// window.
+func (n *StatefulParDo) NewProviders(ctx context.Context, key interface{}, w typex.Window, ts typex.EventTime) (state.Provider, timers.Provider, error) {
+	var buf bytes.Buffer
+	if err := n.Key.Encode(&FullValue{Elm: key}, &buf); err != nil {
+		return nil, nil, errors.WithContextf(err, "encoding key %v for state", key)
+	}
+	if err := n.Window.Encode([]typex.Window{w}, &buf); err != nil {
+		return nil, nil, errors.WithContextf(err, "encoding window %v for state", w)
+	}
+	c, ok := n.cells[buf.String()]
+	if !ok {
+		c = &cell{key: key, w: w, state: make(map[string][][]byte), timers: make(map[timerID]*timer)}
+		n.cells[buf.String()] = c
+	}
+	return &stateProvider{n: n, cell: c}, &timerProvider{n: n, cell: c, ts: ts}, nil
+}
+
+// AdvanceWatermark fires the event time timers before the watermark.
+func (n *StatefulParDo) AdvanceWatermark(ctx context.Context, wm mtime.Time) error {
+	return n.fire(ctx, &n.event, func(t mtime.Time) bool { return t < wm })
+}
+
+// AdvanceProcessingTime fires the processing time timers up to the processing
+// time.
+func (n *StatefulParDo) AdvanceProcessingTime(ctx context.Context, pt mtime.Time) error {
+	return n.fire(ctx, &n.process, func(t mtime.Time) bool { return t <= pt })
+}
+
+// FireAll fires the remaining timers, as when the input is done, and drops
+// the timers they set, as no advancement follows.
+func (n *StatefulParDo) FireAll(ctx context.Context) error {
+	all := func(mtime.Time) bool { return true }
+	if err := n.fire(ctx, &n.event, all); err != nil {
+		return err
+	}
+	if err := n.fire(ctx, &n.process, all); err != nil {
+		return err
+	}
+	for _, q := range []*timerQueue{&n.event, &n.process} {
+		for _, t := range *q {
+			delete(t.cell.timers, t.id)
+		}
+		*q = nil
+	}
+	return nil
+}
+
+// fire processes the timers of the queue that are due, in order. Timers set
+// as they fire are queued again afterwards, rather than fired by this call.
+func (n *StatefulParDo) fire(ctx context.Context, q *timerQueue, due func(mtime.Time) bool) error {
+	seq := n.seq
+	var later []*timer
+	for q.Len() > 0 && due((*q)[0].firing) {
+		t := heap.Pop(q).(*timer)
+		if t.seq > seq {
+			later = append(later, t)
+			continue
+		}
+		delete(t.cell.timers, t.id)
+		if err := n.ProcessTimer(ctx, t.cell.key, t.cell.w, t.id.family, t.ts); err != nil {
+			return err
+		}
+	}
+	for _, t := range later {
+		// Timers cleared since are no longer in their cells.
+		if t.cell.timers[t.id] == t {
+			heap.Push(q, t)
+		}
+	}
+	return nil
+}
+
+func (n *StatefulParDo) queue(domain timers.Domain) *timerQueue {
+	if domain == timers.EventTimeDomain {
+		return &n.event
+	}
+	return &n.process
+}
+
+func (n *StatefulParDo) String() string {
+	return fmt.Sprintf("ParDo.Stateful[%v] UID:%v Out:%v", path.Base(n.Fn.Name()), n.ID(), IDs(n.Out...))
+}
+
+// stateProvider is the state.Provider of a cell.
+type stateProvider struct {
+	n    *StatefulParDo
+	cell *cell
+}
+
+func (p *stateProvider) ReadValue(id string) (interface{}, bool, error) {
+	values, err := p.ReadBag(id)
+	if err != nil || len(values) == 0 {
+		return nil, false, err
+	}
+	return values[0], true, nil
+}
+
+func (p *stateProvider) WriteValue(id string, v interface{}) error {
+	data, err := p.encode(id, v)
+	if err != nil {
+		return err
+	}
+	p.cell.state[id] = [][]byte{data}
+	return nil
+}
+
+func (p *stateProvider) ReadBag(id string) ([]interface{}, error) {
+	c, err := p.coder(id)
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	for _, data := range p.cell.state[id] {
+		v, err := c.Dec.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.WithContextf(err, "decoding state %v", id)
+		}
+		values = append(values, v.Elm)
+	}
+	return values, nil
+}
+
+func (p *stateProvider) AddBag(id string, v interface{}) error {
+	data, err := p.encode(id, v)
+	if err != nil {
+		return err
+	}
+	p.cell.state[id] = append(p.cell.state[id], data)
+	return nil
+}
+
+func (p *stateProvider) Clear(id string) error {
+	if _, err := p.coder(id); err != nil {
+		return err
+	}
+	delete(p.cell.state, id)
+	return nil
+}
+
+func (p *stateProvider) coder(id string) (*StateCoder, error) {
+	c, ok := p.n.State[id]
+	if !ok {
+		return nil, errors.Errorf("no state %v in DoFn %v", id, p.n.Fn.Name())
+	}
+	return c, nil
+}
+
+// encode encodes a value of the state with the coder of its type.
+func (p *stateProvider) encode(id string, v interface{}) ([]byte, error) {
+	c, err := p.coder(id)
+	if err != nil {
+		return nil, err
+	}
+	if t := reflect.TypeOf(v); t == nil || !t.AssignableTo(c.T) {
+		return nil, errors.Errorf("writing %v of type %T to state %v of type %v", v, v, id, c.T)
+	}
+	var buf bytes.Buffer
+	if err := c.Enc.Encode(&FullValue{Elm: v}, &buf); err != nil {
+		return nil, errors.WithContextf(err, "encoding state %v", id)
+	}
+	return buf.Bytes(), nil
+}
+
+// timerProvider is the timers.Provider of a cell, for the element or timer
+// with the given timestamp.
+type timerProvider struct {
+	n    *StatefulParDo
+	cell *cell
+	ts   mtime.Time
+}
+
+func (p *timerProvider) Set(family string, domain timers.Domain, firing mtime.Time) {
+	ts := p.ts
+	if domain == timers.EventTimeDomain {
+		if firing > p.cell.w.MaxTimestamp() {
+			return
+		}
+		ts = firing
+	}
+	id := timerID{family: family, domain: domain}
+	p.n.seq++
+	q := p.n.queue(domain)
+	if t, ok := p.cell.timers[id]; ok {
+		t.firing, t.ts, t.seq = firing, ts, p.n.seq
+		if t.index >= 0 {
+			heap.Fix(q, t.index)
+		}
+		return
+	}
+	t := &timer{cell: p.cell, id: id, firing: firing, ts: ts, seq: p.n.seq}
+	p.cell.timers[id] = t
+	heap.Push(q, t)
+}
+
+func (p *timerProvider) Clear(family string, domain timers.Domain) {
+	id := timerID{family: family, domain: domain}
+	if t, ok := p.cell.timers[id]; ok {
+		if t.index >= 0 {
+			heap.Remove(p.n.queue(domain), t.index)
+		}
+		delete(p.cell.timers, id)
+	}
+}
+
+func (p *timerProvider) ProcessingTime() mtime.Time {
+	if p.n.ProcessingTime != nil {
+		return p.n.ProcessingTime()
+	}
+	return mtime.Now()
+}
+
+// timerQueue is a heap of timers ordered by their firing times, and then by
+// the order they were set in.
+type timerQueue []*timer
+
+func (q timerQueue) Len() int { return len(q) }
+
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].firing != q[j].firing {
+		return q[i].firing < q[j].firing
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *timerQueue) Push(x interface{}) {
+	t := x.(*timer)
+	t.index = len(*q)
+	*q = append(*q, t)
+}
+
+func (q *timerQueue) Pop() interface{} {
+	old := *q
+	t := old[len(old)-1]
+	t.index = -1
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return t
+}
@@ -68,6 +68,7 @@ func knownStandardCoders() []string {
 		urnIterableCoder,
 		urnStateBackedIterableCoder,
 		urnWindowedValueCoder,
+		urnParamWindowedValueCoder,
 		urnGlobalWindow,
 		urnIntervalWindow,
 		// TODO(BEAM-9615): Add urnRowCoder once finalized.
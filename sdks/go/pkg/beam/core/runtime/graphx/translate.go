@@ -17,8 +17,13 @@ package graphx
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/artifact"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
@@ -58,22 +63,49 @@ const (
 	URNReshuffleOutput      = "beam:go:transform:reshuffleoutput:v1"
 
 	URNLegacyProgressReporting = "beam:protocol:progress_reporting:v0"
+	URNProgressReporting       = "beam:protocol:progress_reporting:v1"
 	URNMultiCore               = "beam:protocol:multi_core_bundle_processing:v1"
+	URNElementsEmbedding       = "beam:protocol:control_request_elements_embedding:v1"
+	URNMonitoringInfoShortIDs  = "beam:protocol:monitoring_info_short_ids:v1"
+	URNStateCaching            = "beam:protocol:state_caching:v1"
+	URNWorkerStatus            = "beam:protocol:worker_status:v1"
 
-	URNRequiresSplittableDoFn = "beam:requirement:pardo:splittable_dofn:v1"
+	URNRequiresSplittableDoFn     = "beam:requirement:pardo:splittable_dofn:v1"
+	URNRequiresBundleFinalization = "beam:requirement:pardo:finalization:v1"
 
 	URNArtifactGoWorker  = "beam:artifact:type:go_worker_binary:v1"
 	URNArtifactStagingTo = "beam:artifact:role:staging_to:v1"
 )
 
+var (
+	capabilitiesMu sync.Mutex
+	capabilities   = make(map[string]bool)
+)
+
+// RegisterCapability declares that Go workers support the protocol or
+// requirement with the given URN. It's meant to be called in the init
+// function of the package implementing it, so the environments declare
+// exactly the capabilities of the harness linked into the binary.
+func RegisterCapability(urn string) {
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+	capabilities[urn] = true
+}
+
+// goCapabilities returns the capabilities of Go environments: the registered
+// capabilities in order, the SDK version and the standard coders.
 func goCapabilities() []string {
-	capabilities := []string{
-		URNLegacyProgressReporting,
-		URNMultiCore,
-		// TOOD(BEAM-9614): Make this versioned.
-		"beam:version:sdk_base:go",
+	capabilitiesMu.Lock()
+	var ret []string
+	for urn := range capabilities {
+		ret = append(ret, urn)
 	}
-	return append(capabilities, knownStandardCoders()...)
+	capabilitiesMu.Unlock()
+	sort.Strings(ret)
+
+	// TOOD(BEAM-9614): Make this versioned.
+	ret = append(ret, "beam:version:sdk_base:go")
+	return append(ret, knownStandardCoders()...)
 }
 
 // CreateEnvironment produces the appropriate payload for the type of environment.
@@ -81,11 +113,16 @@ func CreateEnvironment(ctx context.Context, urn string, extractEnvironmentConfig
 	var serializedPayload []byte
 	switch urn {
 	case "beam:env:process:v1":
-		// TODO Support process based SDK Harness.
-		return nil, errors.Errorf("unsupported environment %v", urn)
+		payload, err := processPayload(extractEnvironmentConfig(ctx))
+		if err != nil {
+			return nil, err
+		}
+		serializedPayload = protox.MustEncode(payload)
 	case "beam:env:external:v1":
-		config := extractEnvironmentConfig(ctx)
-		payload := &pipepb.ExternalPayload{Endpoint: &pipepb.ApiServiceDescriptor{Url: config}}
+		payload, err := externalPayload(extractEnvironmentConfig(ctx))
+		if err != nil {
+			return nil, err
+		}
 		serializedPayload = protox.MustEncode(payload)
 	case "beam:env:docker:v1":
 		fallthrough
@@ -98,7 +135,7 @@ func CreateEnvironment(ctx context.Context, urn string, extractEnvironmentConfig
 		Urn:          urn,
 		Payload:      serializedPayload,
 		Capabilities: goCapabilities(),
-		Dependencies: []*pipepb.ArtifactInformation{
+		Dependencies: append([]*pipepb.ArtifactInformation{
 			{
 				TypeUrn: URNArtifactGoWorker,
 				RoleUrn: URNArtifactStagingTo,
@@ -106,7 +143,59 @@ func CreateEnvironment(ctx context.Context, urn string, extractEnvironmentConfig
 					StagedName: "worker",
 				}),
 			},
-		},
+		}, artifact.FileDependencies()...),
+	}, nil
+}
+
+// processConfig is the JSON environment config of process environments.
+type processConfig struct {
+	OS      string            `json:"os"`
+	Arch    string            `json:"arch"`
+	Command string            `json:"command"`
+	Env     map[string]string `json:"env"`
+}
+
+// processPayload returns the payload of a process environment, which runners
+// use to launch the command of the SDK harness on their hosts directly.
+func processPayload(config string) (*pipepb.ProcessPayload, error) {
+	var c processConfig
+	if err := json.Unmarshal([]byte(config), &c); err != nil {
+		return nil, errors.Wrapf(err, "invalid process environment config %q, want JSON of the form {\"os\": ..., \"arch\": ..., \"command\": ..., \"env\": {...}}", config)
+	}
+	if c.Command == "" {
+		return nil, errors.Errorf("process environment config %q has no command", config)
+	}
+	return &pipepb.ProcessPayload{
+		Os:      c.OS,
+		Arch:    c.Arch,
+		Command: c.Command,
+		Env:     c.Env,
+	}, nil
+}
+
+// externalConfig is the JSON environment config of external environments.
+type externalConfig struct {
+	URL    string            `json:"url"`
+	Params map[string]string `json:"params"`
+}
+
+// externalPayload returns the payload of an external environment, whose
+// config is either the endpoint of the worker pool, or JSON with the endpoint
+// and the parameters passed to the worker pool when starting workers.
+func externalPayload(config string) (*pipepb.ExternalPayload, error) {
+	if !strings.HasPrefix(strings.TrimSpace(config), "{") {
+		return &pipepb.ExternalPayload{Endpoint: &pipepb.ApiServiceDescriptor{Url: config}}, nil
+	}
+	var c externalConfig
+	if err := json.Unmarshal([]byte(config), &c); err != nil {
+		return nil, errors.Wrapf(err, "invalid external environment config %q, want an endpoint or JSON of the form {\"url\": ..., \"params\": {...}}", config)
+	}
+	if c.URL == "" {
+		return nil, errors.Errorf("external environment config %q has no url", config)
+	}
+	return &pipepb.ExternalPayload{
+		Endpoint: &pipepb.ApiServiceDescriptor{Url: c.URL},
+		Params:   c.Params,
 	}, nil
 }
 
@@ -118,6 +207,19 @@ type Options struct {
 	Environment *pipepb.Environment
 }
 
+// CheckStateless returns an error if any of the edges has a stateful DoFn.
+// The model pipeline lacks the state and timer specs of the DoFn, and the
+// harness does not serve them, so runners executing model pipelines check
+// their edges first. Only the direct runner executes stateful DoFns.
+func CheckStateless(edges []*graph.MultiEdge) error {
+	for _, edge := range edges {
+		if edge.Op == graph.ParDo && edge.DoFn.IsStateful() {
+			return errors.Errorf("stateful DoFn %v is only supported by the direct runner", edge.DoFn.Name())
+		}
+	}
+	return nil
+}
+
 // Marshal converts a graph to a model pipeline.
 func Marshal(edges []*graph.MultiEdge, opt *Options) (*pipepb.Pipeline, error) {
 	if len(edges) == 0 {
@@ -426,6 +528,10 @@ func (m *marshaller) addMultiEdge(edge NamedEdge) ([]string, error) {
 			payload.RestrictionCoderId = coderId
 			m.requirements[URNRequiresSplittableDoFn] = true
 		}
+		if edge.Edge.DoFn.RequestsFinalization() {
+			payload.RequestsFinalization = true
+			m.requirements[URNRequiresBundleFinalization] = true
+		}
 		spec = &pipepb.FunctionSpec{Urn: URNParDo, Payload: protox.MustEncode(payload)}
 		annotations = edge.Edge.DoFn.Annotations()
 
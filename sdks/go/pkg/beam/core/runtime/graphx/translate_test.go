@@ -16,6 +16,7 @@
 package graphx_test
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
@@ -26,6 +27,7 @@ import (
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/state"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
 	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
@@ -36,6 +38,7 @@ import (
 func init() {
 	runtime.RegisterFunction(pickFn)
 	runtime.RegisterType(reflect.TypeOf((*splitPickFn)(nil)).Elem())
+	runtime.RegisterType(reflect.TypeOf((*countFn)(nil)).Elem())
 }
 
 func pickFn(a int, small, big func(int)) {
@@ -179,6 +182,93 @@ func TestMarshal(t *testing.T) {
 	}
 }
 
+type countFn struct {
+	Count state.Value
+}
+
+func (fn *countFn) ProcessElement(p state.Provider, _, _ int) error {
+	return nil
+}
+
+// TestCheckStateless verifies that stateful DoFns are translated, but
+// rejected for runners executing model pipelines.
+func TestCheckStateless(t *testing.T) {
+	g := graph.New()
+	in := g.NewNode(typex.NewKV(intT(), intT()), window.DefaultWindowingStrategy(), true)
+	in.Coder = coder.NewKV([]*coder.Coder{intCoder(), intCoder()})
+	addDoFn(t, g, &countFn{Count: state.Value{ID: "count"}}, g.Root(), []*graph.Node{in}, nil, nil)
+	edges, _, err := g.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := graphx.CheckStateless(edges); err == nil {
+		t.Error("CheckStateless() succeeded, want an error for the stateful DoFn")
+	}
+	if _, err := graphx.Marshal(edges, &graphx.Options{}); err != nil {
+		t.Errorf("Marshal() failed: %v", err)
+	}
+}
+
+func TestCreateEnvironment_Process(t *testing.T) {
+	config := `{"os": "linux", "arch": "amd64", "command": "/opt/beam/boot", "env": {"LANG": "C"}}`
+	env, err := graphx.CreateEnvironment(context.Background(), "beam:env:process:v1", func(context.Context) string { return config })
+	if err != nil {
+		t.Fatalf("CreateEnvironment failed: %v", err)
+	}
+	var payload pipepb.ProcessPayload
+	if err := proto.Unmarshal(env.GetPayload(), &payload); err != nil {
+		t.Fatalf("invalid process payload: %v", err)
+	}
+	want := &pipepb.ProcessPayload{Os: "linux", Arch: "amd64", Command: "/opt/beam/boot", Env: map[string]string{"LANG": "C"}}
+	if !proto.Equal(&payload, want) {
+		t.Errorf("process payload = %v, want %v", proto.MarshalTextString(&payload), proto.MarshalTextString(want))
+	}
+
+	for _, config := range []string{"", "/opt/beam/boot", `{"os": "linux"}`} {
+		if _, err := graphx.CreateEnvironment(context.Background(), "beam:env:process:v1", func(context.Context) string { return config }); err == nil {
+			t.Errorf("CreateEnvironment(%q) succeeded, want error", config)
+		}
+	}
+}
+
+func TestCreateEnvironment_External(t *testing.T) {
+	tests := []struct {
+		config string
+		want   *pipepb.ExternalPayload
+	}{
+		{
+			config: "localhost:50000",
+			want:   &pipepb.ExternalPayload{Endpoint: &pipepb.ApiServiceDescriptor{Url: "localhost:50000"}},
+		}, {
+			config: `{"url": "localhost:50000", "params": {"pool": "sidecar"}}`,
+			want: &pipepb.ExternalPayload{
+				Endpoint: &pipepb.ApiServiceDescriptor{Url: "localhost:50000"},
+				Params:   map[string]string{"pool": "sidecar"},
+			},
+		},
+	}
+	for _, test := range tests {
+		env, err := graphx.CreateEnvironment(context.Background(), "beam:env:external:v1", func(context.Context) string { return test.config })
+		if err != nil {
+			t.Fatalf("CreateEnvironment(%q) failed: %v", test.config, err)
+		}
+		var payload pipepb.ExternalPayload
+		if err := proto.Unmarshal(env.GetPayload(), &payload); err != nil {
+			t.Fatalf("invalid external payload: %v", err)
+		}
+		if !proto.Equal(&payload, test.want) {
+			t.Errorf("CreateEnvironment(%q) payload = %v, want %v", test.config, proto.MarshalTextString(&payload), proto.MarshalTextString(test.want))
+		}
+	}
+
+	for _, config := range []string{`{"url": `, `{"params": {"pool": "sidecar"}}`} {
+		if _, err := graphx.CreateEnvironment(context.Background(), "beam:env:external:v1", func(context.Context) string { return config }); err == nil {
+			t.Errorf("CreateEnvironment(%q) succeeded, want error", config)
+		}
+	}
+}
+
 // testRT's methods can all be no-ops, we just need it to implement sdf.RTracker.
 type testRT struct {
 }
@@ -206,3 +296,28 @@ func (fn *splitPickFn) CreateTracker(_ int) *testRT          { return &testRT{}
 func (fn *splitPickFn) ProcessElement(_ *testRT, a int, small, big func(int)) {
 	pickFn(a, small, big)
 }
+
+func TestCreateEnvironment_Capabilities(t *testing.T) {
+	graphx.RegisterCapability("beam:protocol:test_b:v1")
+	graphx.RegisterCapability("beam:protocol:test_a:v1")
+	graphx.RegisterCapability("beam:protocol:test_b:v1")
+
+	env, err := graphx.CreateEnvironment(context.Background(), "beam:env:docker:v1", func(context.Context) string { return "image" })
+	if err != nil {
+		t.Fatalf("CreateEnvironment failed: %v", err)
+	}
+	count := make(map[string]int)
+	index := make(map[string]int)
+	for i, c := range env.GetCapabilities() {
+		count[c]++
+		index[c] = i
+	}
+	for _, c := range []string{"beam:protocol:test_a:v1", "beam:protocol:test_b:v1", "beam:version:sdk_base:go", "beam:coder:bytes:v1", "beam:coder:windowed_value:v1"} {
+		if count[c] != 1 {
+			t.Errorf("capabilities = %v, want %v once", env.GetCapabilities(), c)
+		}
+	}
+	if index["beam:protocol:test_a:v1"] > index["beam:protocol:test_b:v1"] {
+		t.Errorf("capabilities = %v, want the registered capabilities in order", env.GetCapabilities())
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
 )
 
 // mergeExpandedWithPipeline adds expanded components of all ExternalTransforms to the existing pipeline
@@ -123,7 +124,10 @@ func purgeOutputInput(edges []*graph.MultiEdge, p *pipepb.Pipeline) {
 	}
 }
 
-// VerifyNamedOutputs ensures the expanded outputs correspond to the correct and expected named outputs
+// VerifyNamedOutputs ensures the expanded outputs correspond to the correct and expected named outputs.
+// Named outputs that are not expected are only allowed, and left unconsumed,
+// if the transform allows unconsumed outputs, but an unnamed output must be
+// the only output.
 func VerifyNamedOutputs(ext *graph.ExternalTransform) {
 	transform, err := ExpandedTransform(ext.Expanded)
 	if err != nil {
@@ -131,7 +135,7 @@ func VerifyNamedOutputs(ext *graph.ExternalTransform) {
 	}
 	expandedOutputs := transform.GetOutputs()
 
-	if len(expandedOutputs) != len(ext.OutputsMap) {
+	if !ext.AllowUnconsumedOutputs && len(expandedOutputs) != len(ext.OutputsMap) {
 		panic(errors.Errorf("mismatched number of named outputs:\nreceived - %v\nexpected - %v", len(expandedOutputs), len(ext.OutputsMap)))
 	}
 
@@ -140,7 +144,7 @@ func VerifyNamedOutputs(ext *graph.ExternalTransform) {
 		if tag != graph.UnnamedOutputTag && !exists {
 			panic(errors.Errorf("missing named output in expanded transform: %v is expected in %v", tag, expandedOutputs))
 		}
-		if tag == graph.UnnamedOutputTag && len(expandedOutputs) > 1 {
+		if tag == graph.UnnamedOutputTag && len(expandedOutputs) != 1 {
 			panic(errors.Errorf("mismatched number of unnamed outputs:\nreceived - %v\nexpected - 1", len(expandedOutputs)))
 		}
 	}
@@ -281,3 +285,42 @@ func OutboundTagToNode(outputsMap map[string]int, outbound []*graph.Outbound) ma
 	}
 	return outputs
 }
+
+// ExpandedOutputSchemas returns the map (tag -> schema) of the outputs of an
+// ExternalTransform that are PCollections of Rows, with respect to the
+// received expanded components.
+func ExpandedOutputSchemas(e *graph.MultiEdge) (map[string]*pipepb.Schema, error) {
+	components, err := ExpandedComponents(e.External.Expanded)
+	if err != nil {
+		return nil, err
+	}
+	transform, err := ExpandedTransform(e.External.Expanded)
+	if err != nil {
+		return nil, err
+	}
+	expandedOutputs := transform.GetOutputs()
+
+	schemas := make(map[string]*pipepb.Schema)
+	for tag := range e.External.OutputsMap {
+		var id string
+		switch tag {
+		case graph.UnnamedOutputTag:
+			for _, id = range expandedOutputs {
+				// easiest way to access map with one entry (key,value)
+			}
+		default:
+			id = expandedOutputs[tag]
+		}
+
+		c := components.GetCoders()[components.GetPcollections()[id].GetCoderId()]
+		if c.GetSpec().GetUrn() != urnRowCoder {
+			continue
+		}
+		var s pipepb.Schema
+		if err := proto.Unmarshal(c.GetSpec().GetPayload(), &s); err != nil {
+			return nil, errors.Wrapf(err, "decoding schema of output %v", tag)
+		}
+		schemas[tag] = &s
+	}
+	return schemas, nil
+}
@@ -177,6 +177,44 @@ func TestExpandedTransform(t *testing.T) {
 	})
 }
 
+func TestVerifyNamedOutputs(t *testing.T) {
+	tests := []struct {
+		name            string
+		expanded        map[string]string
+		expected        map[string]int
+		allowUnconsumed bool
+		valid           bool
+	}{
+		{"Named", map[string]string{"output": "p1"}, map[string]int{"output": 0}, false, true},
+		{"UnexpectedNamed", map[string]string{"output": "p1", "errors": "p2"}, map[string]int{"output": 0}, false, false},
+		{"UnexpectedNamedAllowed", map[string]string{"output": "p1", "errors": "p2"}, map[string]int{"output": 0}, true, true},
+		{"NoneExpected", map[string]string{"snapshots": "p1"}, nil, false, false},
+		{"NoneExpectedAllowed", map[string]string{"snapshots": "p1"}, nil, true, true},
+		{"MissingNamed", map[string]string{"errors": "p1"}, map[string]int{"output": 0}, false, false},
+		{"MissingNamedAllowed", map[string]string{"errors": "p1"}, map[string]int{"output": 0}, true, false},
+		{"Unnamed", map[string]string{"out": "p1"}, map[string]int{graph.UnnamedOutputTag: 0}, false, true},
+		{"SeveralUnnamed", map[string]string{"out": "p1", "errors": "p2"}, map[string]int{graph.UnnamedOutputTag: 0}, false, false},
+		{"SeveralUnnamedAllowed", map[string]string{"out": "p1", "errors": "p2"}, map[string]int{graph.UnnamedOutputTag: 0}, true, false},
+		{"MissingUnnamed", nil, map[string]int{graph.UnnamedOutputTag: 0}, false, false},
+		{"MissingUnnamedAllowed", nil, map[string]int{graph.UnnamedOutputTag: 0}, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ext := &graph.ExternalTransform{
+				OutputsMap:             test.expected,
+				AllowUnconsumedOutputs: test.allowUnconsumed,
+				Expanded:               &graph.ExpandedTransform{Transform: &pipepb.PTransform{Outputs: test.expanded}},
+			}
+			defer func() {
+				if r := recover(); (r == nil) != test.valid {
+					t.Errorf("VerifyNamedOutputs(%v expecting %v, allowing unconsumed: %v) panicked: %v, want panic: %v", test.expanded, test.expected, test.allowUnconsumed, r, !test.valid)
+				}
+			}()
+			VerifyNamedOutputs(ext)
+		})
+	}
+}
+
 func TestExpandedComponents(t *testing.T) {
 	t.Run("Correct Components", func(t *testing.T) {
 		want := newComponents([]string{"x"})
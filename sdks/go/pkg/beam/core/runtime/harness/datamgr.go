@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/tracex"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
@@ -47,22 +48,37 @@ func NewScopedDataManager(mgr *DataChannelManager, instID instructionID) *Scoped
 	return &ScopedDataManager{mgr: mgr, instID: instID}
 }
 
-// OpenRead opens an io.ReadCloser on the given stream.
+// OpenRead opens an io.ReadCloser on the given stream. It's traced until
+// it's closed.
 func (s *ScopedDataManager) OpenRead(ctx context.Context, id exec.StreamID) (io.ReadCloser, error) {
+	_, span := tracex.Start(ctx, "DataRead", tracex.String("beam.ptransform_id", id.PtransformID))
 	ch, err := s.open(ctx, id.Port)
 	if err != nil {
+		span.End(err)
 		return nil, err
 	}
-	return ch.OpenRead(ctx, id.PtransformID, s.instID), nil
+	r := ch.OpenRead(ctx, id.PtransformID, s.instID)
+	switch r := r.(type) {
+	case *dataReader:
+		r.span = span
+	case *errReader:
+		span.End(r.err)
+	}
+	return r, nil
 }
 
-// OpenWrite opens an io.WriteCloser on the given stream.
+// OpenWrite opens an io.WriteCloser on the given stream. It's traced until
+// it's closed.
 func (s *ScopedDataManager) OpenWrite(ctx context.Context, id exec.StreamID) (io.WriteCloser, error) {
+	_, span := tracex.Start(ctx, "DataWrite", tracex.String("beam.ptransform_id", id.PtransformID))
 	ch, err := s.open(ctx, id.Port)
 	if err != nil {
+		span.End(err)
 		return nil, err
 	}
-	return ch.OpenWrite(ctx, id.PtransformID, s.instID), nil
+	w := ch.OpenWrite(ctx, id.PtransformID, s.instID)
+	w.(*dataWriter).span = span
+	return w, nil
 }
 
 func (s *ScopedDataManager) open(ctx context.Context, port exec.Port) (*DataChannel, error) {
@@ -230,7 +246,10 @@ func (c *DataChannel) OpenWrite(ctx context.Context, ptransformID string, instID
 
 func (c *DataChannel) read(ctx context.Context) {
 	cache := make(map[clientID]*dataReader)
+	memory := getMemoryLimiter(ctx)
 	for {
+		// Delay reading more elements while the heap exceeds the limit.
+		memory.wait(ctx, dataReadThrottle)
 		msg, err := c.client.Recv()
 		if err != nil {
 			// This connection is bad, so we should close and delete all extant streams.
@@ -433,14 +452,41 @@ type dataReader struct {
 	channel   *DataChannel
 	completed bool
 	err       error
+	span      *tracex.Span
 }
 
 func (r *dataReader) Close() error {
+	r.span.End(nil)
 	r.done <- true
 	r.channel.removeReader(r.id)
 	return nil
 }
 
+// Lend lends the next n bytes of the current chunk, which are never modified
+// after being received, implementing ioutilx.Lender.
+func (r *dataReader) Lend(n int) ([]byte, bool) {
+	if n == 0 {
+		return nil, true
+	}
+	if r.cur == nil {
+		b, ok := <-r.buf
+		if !ok {
+			return nil, false // Read reports the end of the stream.
+		}
+		r.cur = b
+	}
+	if len(r.cur) < n {
+		return nil, false
+	}
+	b := r.cur[:n:n]
+	if len(r.cur) == n {
+		r.cur = nil
+	} else {
+		r.cur = r.cur[n:]
+	}
+	return b, true
+}
+
 func (r *dataReader) Read(buf []byte) (int, error) {
 	if r.cur == nil {
 		b, ok := <-r.buf
@@ -470,8 +516,9 @@ func (r *dataReader) Read(buf []byte) (int, error) {
 type dataWriter struct {
 	buf []byte
 
-	id clientID
-	ch *DataChannel
+	id   clientID
+	ch   *DataChannel
+	span *tracex.Span
 }
 
 // send requires the ch.mu lock to be held.
@@ -495,11 +542,12 @@ func (w *dataWriter) send(msg *fnpb.Elements) error {
 	return nil
 }
 
-func (w *dataWriter) Close() error {
+func (w *dataWriter) Close() (err error) {
+	defer func() { w.span.End(err) }()
+
 	// Don't acquire the locks as Flush will do so.
 	l := len(w.buf)
-	err := w.Flush()
-	if err != nil {
+	if err := w.Flush(); err != nil {
 		return errors.Wrapf(err, "dataWriter[%v;%v].Close: error flushing buffer of length %d", w.id, w.ch.id, l)
 	}
 
@@ -530,22 +578,49 @@ func (w *dataWriter) Flush() error {
 		return nil
 	}
 
+	// The message may outlive the send, so it gets a copy of the chunk that
+	// is allocated once, and the grown buffer is reused for later chunks.
+	data := make([]byte, len(w.buf))
+	copy(data, w.buf)
 	msg := &fnpb.Elements{
 		Data: []*fnpb.Elements_Data{
 			{
 				InstructionId: string(w.id.instID),
 				TransformId:   w.id.ptransformID,
-				Data:          w.buf,
+				Data:          data,
 			},
 		},
 	}
 	if l := len(w.buf); l > largeBufferNotificationThreshold {
 		log.Infof(context.TODO(), "dataWriter[%v;%v].Flush flushed large buffer of length %d", w.id, w.ch.id, l)
 	}
+	putChunkBuffer(w.buf)
 	w.buf = nil
 	return w.send(msg)
 }
 
+// chunkBuffers holds the buffers of flushed chunks, so writers don't need
+// to grow new buffers for every chunk.
+var chunkBuffers sync.Pool
+
+// minPooledChunk is the minimum capacity of pooled chunk buffers. Smaller
+// buffers are cheaper to grow than to keep.
+const minPooledChunk = 64 << 10
+
+func getChunkBuffer() []byte {
+	if b, ok := chunkBuffers.Get().(*[]byte); ok {
+		return (*b)[:0]
+	}
+	return nil
+}
+
+func putChunkBuffer(b []byte) {
+	if cap(b) < minPooledChunk {
+		return
+	}
+	chunkBuffers.Put(&b)
+}
+
 func (w *dataWriter) Write(p []byte) (n int, err error) {
 	if len(w.buf)+len(p) > chunkSize {
 		l := len(w.buf)
@@ -556,6 +631,9 @@ func (w *dataWriter) Write(p []byte) (n int, err error) {
 	}
 
 	// At this point there's room in the buffer one way or another.
+	if w.buf == nil {
+		w.buf = getChunkBuffer()
+	}
 	w.buf = append(w.buf, p...)
 	return len(p), nil
 }
@@ -299,3 +299,83 @@ func TestDataChannelTerminate_Writes(t *testing.T) {
 		})
 	}
 }
+
+func TestDataReaderLend(t *testing.T) {
+	r := &dataReader{buf: make(chan []byte, 2)}
+	r.buf <- []byte{1, 2, 3, 4}
+	r.buf <- []byte{5, 6}
+	close(r.buf)
+
+	if b, ok := r.Lend(3); !ok || string(b) != "\x01\x02\x03" {
+		t.Fatalf("Lend(3) = %v, %v, want [1 2 3], true", b, ok)
+	}
+	// Lends don't span chunks, so decoders fall back to reading.
+	if b, ok := r.Lend(3); ok {
+		t.Fatalf("Lend(3) across chunks = %v, true, want false", b)
+	}
+	buf := make([]byte, 3)
+	if n, err := io.ReadFull(r, buf); err != nil || string(buf[:n]) != "\x04\x05\x06" {
+		t.Fatalf("ReadFull = %v, %v, want [4 5 6]", buf[:n], err)
+	}
+	if b, ok := r.Lend(1); ok {
+		t.Fatalf("Lend(1) at end = %v, true, want false", b)
+	}
+	if _, err := r.Read(buf); err != io.EOF {
+		t.Errorf("Read at end = %v, want io.EOF", err)
+	}
+}
+
+// recordingDataClient records the messages sent on the data stream, and
+// receives nothing until it's closed.
+type recordingDataClient struct {
+	done chan struct{}
+
+	mu   sync.Mutex
+	sent []*fnpb.Elements
+}
+
+func (f *recordingDataClient) Recv() (*fnpb.Elements, error) {
+	<-f.done
+	return nil, io.EOF
+}
+
+func (f *recordingDataClient) Send(msg *fnpb.Elements) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func TestDataWriterFlush_reusedBuffers(t *testing.T) {
+	client := &recordingDataClient{done: make(chan struct{})}
+	defer close(client.done)
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	c := makeDataChannel(ctx, "id", client, cancelFn)
+
+	// Chunks are large enough for their buffers to be reused.
+	chunks := [][]byte{
+		[]byte(strings.Repeat("a", minPooledChunk)),
+		[]byte(strings.Repeat("b", minPooledChunk)),
+	}
+	w := c.OpenWrite(ctx, "ptr", "inst_ref")
+	for _, chunk := range chunks {
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+		if err := w.(*dataWriter).Flush(); err != nil {
+			t.Fatalf("Flush() failed: %v", err)
+		}
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if got, want := len(client.sent), len(chunks); got != want {
+		t.Fatalf("sent %v messages, want %v", got, want)
+	}
+	for i, chunk := range chunks {
+		if got := client.sent[i].GetData()[0].GetData(); string(got) != string(chunk) {
+			t.Errorf("message %v has data %.8q..., want %.8q...", i, got, chunk)
+		}
+	}
+}
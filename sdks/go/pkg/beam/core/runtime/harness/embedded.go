@@ -0,0 +1,115 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+)
+
+func init() {
+	graphx.RegisterCapability(graphx.URNElementsEmbedding)
+}
+
+// embeddedDataManager is the data manager of a bundle whose elements are
+// embedded in its ProcessBundleRequest. It reads the input elements from the
+// request, instead of a data channel, and collects the output elements to be
+// embedded in the ProcessBundleResponse.
+type embeddedDataManager struct {
+	instID instructionID
+	in     map[string][][]byte // data by transform
+
+	out    []*fnpb.Elements_Data // protected by mu
+	closed bool                  // protected by mu
+	mu     sync.Mutex
+}
+
+func newEmbeddedDataManager(instID instructionID, elms *fnpb.Elements) *embeddedDataManager {
+	in := make(map[string][][]byte)
+	for _, d := range elms.GetData() {
+		if d.GetInstructionId() != string(instID) || len(d.GetData()) == 0 {
+			continue
+		}
+		in[d.GetTransformId()] = append(in[d.GetTransformId()], d.GetData())
+	}
+	return &embeddedDataManager{instID: instID, in: in}
+}
+
+// OpenRead opens an io.ReadCloser on the embedded elements of the given transform.
+func (m *embeddedDataManager) OpenRead(ctx context.Context, id exec.StreamID) (io.ReadCloser, error) {
+	var rs []io.Reader
+	for _, b := range m.in[id.PtransformID] {
+		rs = append(rs, bytes.NewReader(b))
+	}
+	return ioutil.NopCloser(io.MultiReader(rs...)), nil
+}
+
+// OpenWrite opens an io.WriteCloser, whose elements are embedded in the
+// response once it is closed.
+func (m *embeddedDataManager) OpenWrite(ctx context.Context, id exec.StreamID) (io.WriteCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, errors.Errorf("instruction %v no longer processing", m.instID)
+	}
+	return &embeddedWriter{m: m, ptransformID: id.PtransformID}, nil
+}
+
+// Close prevents new IO for this instruction.
+func (m *embeddedDataManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+// output returns the output elements of the bundle.
+func (m *embeddedDataManager) output() *fnpb.Elements {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &fnpb.Elements{Data: m.out}
+}
+
+// embeddedWriter buffers the output elements of a transform.
+type embeddedWriter struct {
+	buf          bytes.Buffer
+	m            *embeddedDataManager
+	ptransformID string
+}
+
+func (w *embeddedWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *embeddedWriter) Close() error {
+	w.m.mu.Lock()
+	defer w.m.mu.Unlock()
+	w.m.out = append(w.m.out, &fnpb.Elements_Data{
+		InstructionId: string(w.m.instID),
+		TransformId:   w.ptransformID,
+		Data:          w.buf.Bytes(),
+		IsLast:        true,
+	})
+	return nil
+}
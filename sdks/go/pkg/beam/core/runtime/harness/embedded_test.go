@@ -0,0 +1,69 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestEmbeddedDataManager(t *testing.T) {
+	ctx := context.Background()
+	m := newEmbeddedDataManager("inst", &fnpb.Elements{
+		Data: []*fnpb.Elements_Data{
+			{InstructionId: "inst", TransformId: "source", Data: []byte("ab")},
+			{InstructionId: "inst", TransformId: "other", Data: []byte("xy")},
+			{InstructionId: "inst", TransformId: "source", Data: []byte("cd")},
+			{InstructionId: "inst", TransformId: "source", IsLast: true},
+		},
+	})
+
+	r, err := m.OpenRead(ctx, exec.StreamID{PtransformID: "source"})
+	if err != nil {
+		t.Fatalf("OpenRead failed: %v", err)
+	}
+	if got, err := ioutil.ReadAll(r); err != nil || string(got) != "abcd" {
+		t.Errorf("ReadAll = %q, %v, want \"abcd\"", got, err)
+	}
+	r.Close()
+
+	w, err := m.OpenWrite(ctx, exec.StreamID{PtransformID: "sink"})
+	if err != nil {
+		t.Fatalf("OpenWrite failed: %v", err)
+	}
+	w.Write([]byte("12"))
+	w.Write([]byte("34"))
+	w.Close()
+	m.Close()
+
+	if _, err := m.OpenWrite(ctx, exec.StreamID{PtransformID: "sink"}); err == nil {
+		t.Error("OpenWrite after Close succeeded, want error")
+	}
+
+	want := &fnpb.Elements{
+		Data: []*fnpb.Elements_Data{
+			{InstructionId: "inst", TransformId: "sink", Data: []byte("1234"), IsLast: true},
+		},
+	}
+	if got := m.output(); !proto.Equal(got, want) {
+		t.Errorf("output() = %v, want %v", got, want)
+	}
+}
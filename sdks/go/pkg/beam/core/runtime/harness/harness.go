@@ -20,12 +20,16 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"os/signal"
 	"sync"
-	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/hooks"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/tracex"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
@@ -36,6 +40,15 @@ import (
 
 // TODO(herohde) 2/8/2017: for now, assume we stage a full binary (not a plugin).
 
+func init() {
+	// Instructions are handled concurrently, so bundles can be processed in
+	// parallel by a single harness.
+	graphx.RegisterCapability(graphx.URNMultiCore)
+	// The plans of bundles requiring finalization wait for FinalizeBundle
+	// requests to run the callbacks of their DoFns.
+	graphx.RegisterCapability(graphx.URNRequiresBundleFinalization)
+}
+
 // Main is the main entrypoint for the Go harness. It runs at "runtime" -- not
 // "pipeline-construction time" -- on each worker. It is a FnAPI client and
 // ultimately responsible for correctly executing user code.
@@ -43,9 +56,12 @@ func Main(ctx context.Context, loggingEndpoint, controlEndpoint string) error {
 	hooks.DeserializeHooksFromOptions(ctx)
 
 	hooks.RunInitHooks(ctx)
-	setupRemoteLogging(ctx, loggingEndpoint)
+	defer setupRemoteLogging(ctx, loggingEndpoint)()
 	recordHeader()
 
+	ctx, stopTracing := setupTracing(ctx)
+	defer stopTracing()
+
 	// Connect to FnAPI control server. Receive and execute work.
 	// TODO: setup data manager, DoFn register
 
@@ -63,24 +79,26 @@ func Main(ctx context.Context, loggingEndpoint, controlEndpoint string) error {
 		return pbd, err
 	}
 
-	stub, err := client.Control(ctx)
+	// The control stream is canceled once the harness is drained.
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+	stub, err := newControlStream(streamCtx, client)
 	if err != nil {
 		return errors.Wrapf(err, "failed to connect to control service")
 	}
+	defer stub.stop()
 
 	log.Debugf(ctx, "Successfully connected to control @ %v", controlEndpoint)
 
 	// Each ProcessBundle is a sub-graph of the original one.
 
-	var wg sync.WaitGroup
+	sent := make(chan struct{})
 	respc := make(chan *fnpb.InstructionResponse, 100)
 
-	wg.Add(1)
-
 	// gRPC requires all writers to a stream be the same goroutine, so this is the
 	// goroutine for managing responses back to the control service.
 	go func() {
-		defer wg.Done()
+		defer close(sent)
 		for resp := range respc {
 			log.Debugf(ctx, "RESP: %v", proto.MarshalTextString(resp))
 
@@ -88,33 +106,116 @@ func Main(ctx context.Context, loggingEndpoint, controlEndpoint string) error {
 				log.Errorf(ctx, "control.Send: Failed to respond: %v", err)
 			}
 		}
+		// Let the runner know that there are no more responses.
+		stub.CloseSend()
 		log.Debugf(ctx, "control response channel closed")
 	}()
 
+	// Responses are dropped once we can't send or receive anymore.
+	var respMu sync.Mutex
+	respClosed := false
+	respond := func(resp *fnpb.InstructionResponse) {
+		respMu.Lock()
+		defer respMu.Unlock()
+		if !respClosed {
+			respc <- resp
+		}
+	}
+	closeResponses := func() {
+		respMu.Lock()
+		defer respMu.Unlock()
+		if !respClosed {
+			respClosed = true
+			close(respc)
+		}
+	}
+
 	ctrl := &control{
 		lookupDesc:  lookupDesc,
 		descriptors: make(map[bundleDescriptorID]*fnpb.ProcessBundleDescriptor),
 		plans:       make(map[bundleDescriptorID][]*exec.Plan),
+		idle:        make(map[*exec.Plan]time.Time),
 		active:      make(map[instructionID]*exec.Plan),
+		started:     make(map[instructionID]time.Time),
 		inactive:    newCircleBuffer(),
 		failed:      make(map[instructionID]error),
+		finalizing:  make(map[instructionID]finalizingPlan),
 		data:        &DataChannelManager{},
 		state:       &StateChannelManager{},
+		cache:       newStateCache(stateCacheSize),
+		system:      newSystemMetrics(),
+	}
+	go ctrl.system.run(streamCtx)
+
+	// Plans are reused across bundles, so their DoFns are only set up once,
+	// and torn down once unused for a while, or when the harness exits.
+	go ctrl.evictIdlePlansPeriodically(streamCtx)
+	defer func() { ctrl.evictIdlePlans(ctx, time.Now()) }()
+
+	if limit := memoryLimit(ctx); limit > 0 {
+		memory := newMemoryLimiter(limit)
+		go memory.run(ctx)
+		ctx = withMemoryLimiter(ctx, memory)
+	}
+
+	if grace := shutdownGracePeriod(ctx); grace > 0 {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGTERM)
+		defer signal.Stop(sig)
+		d := &drainer{grace: grace, ctrl: ctrl, closeResponses: closeResponses, sent: sent, cancel: cancelStream}
+		go d.run(ctx, sig)
+	}
+
+	if timeout := stuckBundleTimeout(ctx); timeout > 0 {
+		w := &watchdog{timeout: timeout, ctrl: ctrl, reported: make(map[instructionID]time.Time)}
+		go w.run(streamCtx)
+	}
+
+	prom, stopPrometheus, err := setupPrometheus(ctx, ctrl)
+	if err != nil {
+		log.Errorf(ctx, "Prometheus metrics disabled: %v", err)
+	}
+	defer stopPrometheus()
+	ctrl.prom = prom
+
+	otlp, stopOTLP := setupOTLPMetrics(ctx)
+	defer stopOTLP()
+	ctrl.otlp = otlp
+
+	if endpoint, ok := ctx.Value(statusEndpointKey).(string); ok && endpoint != "" {
+		statusHandler, err := newWorkerStatusHandler(ctx, endpoint, ctrl)
+		if err == nil {
+			err = statusHandler.start(ctx)
+			defer statusHandler.stop(ctx)
+		}
+		if err != nil {
+			log.Errorf(ctx, "worker status reporting disabled: %v", err)
+		}
 	}
 
 	// gRPC requires all readers of a stream be the same goroutine, so this goroutine
 	// is responsible for managing the network data. All it does is pull data from
 	// the stream, and hand off the message to a goroutine to actually be handled,
 	// so as to avoid blocking the underlying network channel.
-	var shutdown int32
 	for {
 		req, err := stub.Recv()
+		if err != nil && isTransient(err) && !ctrl.isDraining() {
+			// The bundles keep being processed while reconnecting, and
+			// their responses are sent on the new stream.
+			if err = stub.reconnect(ctx, err); err == nil {
+				continue
+			}
+		}
 		if err != nil {
 			// An error means we can't send or receive anymore. Shut down.
-			atomic.AddInt32(&shutdown, 1)
-			close(respc)
-			wg.Wait()
+			stub.stop()
+			closeResponses()
+			<-sent
 
+			if ctrl.isDraining() {
+				recordFooter()
+				return ErrTerminated
+			}
 			if err == io.EOF {
 				recordFooter()
 				return nil
@@ -134,8 +235,8 @@ func Main(ctx context.Context, loggingEndpoint, controlEndpoint string) error {
 			hooks.RunResponseHooks(ctx, req, resp)
 
 			recordInstructionResponse(resp)
-			if resp != nil && atomic.LoadInt32(&shutdown) == 0 {
-				respc <- resp
+			if resp != nil {
+				respond(resp)
 			}
 		}
 
@@ -143,12 +244,13 @@ func Main(ctx context.Context, loggingEndpoint, controlEndpoint string) error {
 			// Add this to the inactive queue before allowing other requests
 			// to be processed. This prevents race conditions with split
 			// or progress requests for this instruction.
-			ctrl.mu.Lock()
-			ctrl.inactive.Add(instructionID(req.GetInstructionId()))
-			ctrl.mu.Unlock()
+			ctrl.startBundle(instructionID(req.GetInstructionId()))
 			// Only process bundles in a goroutine. We at least need to process instructions for
 			// each plan serially. Perhaps just invoke plan.Execute async?
-			go fn(ctx, req)
+			go func(req *fnpb.InstructionRequest) {
+				defer ctrl.finishBundle()
+				fn(ctx, req)
+			}(req)
 		} else {
 			fn(ctx, req)
 		}
@@ -213,20 +315,110 @@ func (c *circleBuffer) Contains(instID instructionID) bool {
 type control struct {
 	lookupDesc  func(bundleDescriptorID) (*fnpb.ProcessBundleDescriptor, error)
 	descriptors map[bundleDescriptorID]*fnpb.ProcessBundleDescriptor // protected by mu
-	// plans that are candidates for execution.
+	// plans that are candidates for execution, in order of their last use.
 	plans map[bundleDescriptorID][]*exec.Plan // protected by mu
+	// idle holds the times the candidate plans were last used.
+	idle map[*exec.Plan]time.Time // protected by mu
 	// plans that are actively being executed.
 	// a plan can only be in one of these maps at any time.
 	active map[instructionID]*exec.Plan // protected by mu
+	// started holds the times the active bundles started executing.
+	started map[instructionID]time.Time // protected by mu
 	// a plan that's either about to start or has finished recently
 	// instructions in this queue should return empty responses to control messages.
 	inactive circleBuffer // protected by mu
 	// plans that have failed during execution
 	failed map[instructionID]error // protected by mu
-	mu     sync.Mutex
+	// plans of committed bundles whose DoFns registered finalization
+	// callbacks, which wait for the runner to finalize the bundle before
+	// they're candidates for execution again.
+	finalizing map[instructionID]finalizingPlan // protected by mu
+	// pending is the number of bundles being processed or responded to.
+	pending int // protected by mu
+	// draining is set once the control stops accepting bundles, and drained
+	// is closed once no bundles are pending afterwards.
+	draining bool          // protected by mu
+	drained  chan struct{} // protected by mu
+	mu       sync.Mutex
 
 	data  *DataChannelManager
 	state *StateChannelManager
+	// cache is the state cached across bundles, if any.
+	cache *stateCache
+	// prom exports the metrics of the bundles to Prometheus, if set.
+	prom *prometheusExporter
+	// otlp exports the metrics of the committed bundles to an OpenTelemetry
+	// collector, if set.
+	otlp *otlpMetricsExporter
+	// system samples the resource usage of the worker.
+	system *systemMetrics
+}
+
+// finalizingPlan is the plan of a bundle awaiting finalization.
+type finalizingPlan struct {
+	bdID bundleDescriptorID
+	plan *exec.Plan
+	// since is when the bundle finished processing.
+	since time.Time
+}
+
+// activeBundles returns the number of bundles being executed.
+func (c *control) activeBundles() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.active)
+}
+
+// startBundle marks a bundle as pending, until finishBundle is called once
+// it's responded to.
+func (c *control) startBundle(instID instructionID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inactive.Add(instID)
+	c.pending++
+}
+
+func (c *control) finishBundle() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending--
+	c.checkDrained()
+}
+
+// drain stops the control from accepting bundles. The returned channel is
+// closed once the pending bundles are finished.
+func (c *control) drain() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.draining {
+		c.draining = true
+		c.drained = make(chan struct{})
+		c.checkDrained()
+	}
+	return c.drained
+}
+
+// checkDrained closes drained once no bundles are pending. Requires mu to be
+// held.
+func (c *control) checkDrained() {
+	if c.draining && c.pending == 0 && !isClosed(c.drained) {
+		close(c.drained)
+	}
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *control) isDraining() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.draining
 }
 
 func (c *control) getOrCreatePlan(bdID bundleDescriptorID) (*exec.Plan, error) {
@@ -234,8 +426,11 @@ func (c *control) getOrCreatePlan(bdID bundleDescriptorID) (*exec.Plan, error) {
 	plans, ok := c.plans[bdID]
 	var plan *exec.Plan
 	if ok && len(plans) > 0 {
+		// Reuse the most recently used plan, so that the least recently used
+		// ones become idle and are evicted.
 		plan = plans[len(plans)-1]
 		c.plans[bdID] = plans[:len(plans)-1]
+		delete(c.idle, plan)
 	} else {
 		desc, ok := c.descriptors[bdID]
 		if !ok {
@@ -259,6 +454,72 @@ func (c *control) getOrCreatePlan(bdID bundleDescriptorID) (*exec.Plan, error) {
 	return plan, nil
 }
 
+// releasePlan makes the plan a candidate for the next bundles of the
+// descriptor. Requires mu to be held.
+func (c *control) releasePlan(bdID bundleDescriptorID, plan *exec.Plan) {
+	if c.idle == nil {
+		c.idle = make(map[*exec.Plan]time.Time)
+	}
+	c.plans[bdID] = append(c.plans[bdID], plan)
+	c.idle[plan] = time.Now()
+}
+
+// planIdleTimeout is how long a candidate plan may go unused before it's
+// torn down, so that the DoFns of descriptors that are no longer processed,
+// and of plans created for bursts of concurrent bundles, don't stay set up.
+const planIdleTimeout = 5 * time.Minute
+
+func (c *control) evictIdlePlansPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(planIdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			c.evictIdlePlans(ctx, now.Add(-planIdleTimeout))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// evictIdlePlans tears down the candidate plans last used no later than the
+// cutoff.
+func (c *control) evictIdlePlans(ctx context.Context, cutoff time.Time) {
+	var evicted []*exec.Plan
+	c.mu.Lock()
+	for bdID, plans := range c.plans {
+		var kept []*exec.Plan
+		for _, plan := range plans {
+			if c.idle[plan].After(cutoff) {
+				kept = append(kept, plan)
+				continue
+			}
+			evicted = append(evicted, plan)
+			delete(c.idle, plan)
+		}
+		if len(kept) == 0 {
+			delete(c.plans, bdID)
+		} else {
+			c.plans[bdID] = kept
+		}
+	}
+	// Runners that never finalize the bundle would keep its plan forever.
+	for instID, f := range c.finalizing {
+		if f.since.After(cutoff) {
+			continue
+		}
+		evicted = append(evicted, f.plan)
+		delete(c.finalizing, instID)
+	}
+	c.mu.Unlock()
+
+	for _, plan := range evicted {
+		if err := plan.Down(ctx); err != nil {
+			log.Warnf(ctx, "failed to tear down idle plan %v: %v", plan.ID(), err)
+		}
+	}
+}
+
 func (c *control) handleInstruction(ctx context.Context, req *fnpb.InstructionRequest) *fnpb.InstructionResponse {
 	instID := instructionID(req.GetInstructionId())
 	ctx = setInstID(ctx, instID)
@@ -287,35 +548,72 @@ func (c *control) handleInstruction(ctx context.Context, req *fnpb.InstructionRe
 
 		bdID := bundleDescriptorID(msg.GetProcessBundleDescriptorId())
 		log.Debugf(ctx, "PB [%v]: %v", instID, msg)
+		if c.isDraining() {
+			c.mu.Lock()
+			c.inactive.Remove(instID)
+			c.mu.Unlock()
+			return fail(ctx, instID, "worker is terminating, rejected bundle %v using plan %v", instID, bdID)
+		}
+		// Wait for active bundles to release memory before starting another.
+		if c.activeBundles() > 0 {
+			getMemoryLimiter(ctx).wait(ctx, bundleStartThrottle)
+		}
 		plan, err := c.getOrCreatePlan(bdID)
 
 		// Make the plan active.
+		started := time.Now()
 		c.mu.Lock()
 		c.inactive.Remove(instID)
 		c.active[instID] = plan
+		c.started[instID] = started
 		c.mu.Unlock()
 
 		if err != nil {
 			return fail(ctx, instID, "Failed: %v", err)
 		}
 
-		data := NewScopedDataManager(c.data, instID)
+		// Bundles with embedded elements don't use the data channel.
+		var data interface {
+			exec.DataManager
+			io.Closer
+		}
+		embedded := msg.GetElements() != nil
+		if embedded {
+			data = newEmbeddedDataManager(instID, msg.GetElements())
+		} else {
+			data = NewScopedDataManager(c.data, instID)
+		}
 		state := NewScopedStateReader(c.state, instID)
-		err = plan.Execute(ctx, string(instID), exec.DataContext{Data: data, State: state})
+		state.cache = c.cache.forBundle(msg.GetCacheTokens())
+		bundleCtx, span := tracex.Start(ctx, "ProcessBundle",
+			tracex.String("beam.instruction_id", string(instID)),
+			tracex.String("beam.descriptor_id", string(bdID)),
+			tracex.Bool("beam.embedded", embedded))
+		err = plan.Execute(bundleCtx, string(instID), exec.DataContext{Data: data, State: state})
 		data.Close()
 		state.Close()
+		span.End(err)
 
 		mons, pylds := monitoring(plan)
+		c.prom.record(plan.Store(), err)
+		if err == nil {
+			c.otlp.commit(plan.Store(), started)
+		}
 		// Move the plan back to the candidate state
 		c.mu.Lock()
 		// Mark the instruction as failed.
+		finalize := err == nil && plan.RequiresFinalization()
 		if err != nil {
 			c.failed[instID] = err
+		} else if finalize {
+			// The plan is re-used once the runner finalizes the bundle.
+			c.finalizing[instID] = finalizingPlan{bdID: bdID, plan: plan, since: time.Now()}
 		} else {
 			// Non failure plans can be re-used.
-			c.plans[bdID] = append(c.plans[bdID], plan)
+			c.releasePlan(bdID, plan)
 		}
 		delete(c.active, instID)
+		delete(c.started, instID)
 
 		if removed, ok := c.inactive.Insert(instID); ok {
 			delete(c.failed, removed) // Also GC old failed bundles.
@@ -323,16 +621,31 @@ func (c *control) handleInstruction(ctx context.Context, req *fnpb.InstructionRe
 		c.mu.Unlock()
 
 		if err != nil {
-			return fail(ctx, instID, "process bundle failed for instruction %v using plan %v : %v", instID, bdID, err)
+			// Failed plans can't be reused, but their DoFns are still torn down.
+			if derr := plan.Down(ctx); derr != nil {
+				log.Warnf(ctx, "failed to tear down plan %v: %v", bdID, derr)
+			}
+			resp := fail(ctx, instID, "process bundle failed for instruction %v using plan %v : %v", instID, bdID, err)
+			// The metrics of failed bundles are reported too, so runners can
+			// include them in the attempted metrics.
+			resp.Response = &fnpb.InstructionResponse_ProcessBundle{
+				ProcessBundle: &fnpb.ProcessBundleResponse{MonitoringData: pylds, MonitoringInfos: mons},
+			}
+			return resp
 		}
 
+		resp := &fnpb.ProcessBundleResponse{
+			MonitoringData:       pylds,
+			MonitoringInfos:      mons,
+			RequiresFinalization: finalize,
+		}
+		if embedded {
+			resp.Elements = data.(*embeddedDataManager).output()
+		}
 		return &fnpb.InstructionResponse{
 			InstructionId: string(instID),
 			Response: &fnpb.InstructionResponse_ProcessBundle{
-				ProcessBundle: &fnpb.ProcessBundleResponse{
-					MonitoringData:  pylds,
-					MonitoringInfos: mons,
-				},
+				ProcessBundle: resp,
 			},
 		}
 
@@ -354,14 +667,17 @@ func (c *control) handleInstruction(ctx context.Context, req *fnpb.InstructionRe
 			}
 		}
 
-		mons, pylds := monitoring(plan)
+		// Progress is reported only by short ids, since the SDK supports the
+		// short id protocol, so the metadata of the metrics isn't serialized
+		// in every progress response. It's requested by runners once, with a
+		// MonitoringInfosMetadataRequest.
+		_, pylds := monitoring(plan)
 
 		return &fnpb.InstructionResponse{
 			InstructionId: string(instID),
 			Response: &fnpb.InstructionResponse_ProcessBundleProgress{
 				ProcessBundleProgress: &fnpb.ProcessBundleProgressResponse{
-					MonitoringData:  pylds,
-					MonitoringInfos: mons,
+					MonitoringData: pylds,
 				},
 			},
 		}
@@ -437,6 +753,31 @@ func (c *control) handleInstruction(ctx context.Context, req *fnpb.InstructionRe
 				},
 			},
 		}
+	case req.GetFinalizeBundle() != nil:
+		ref := instructionID(req.GetFinalizeBundle().GetInstructionId())
+
+		c.mu.Lock()
+		f, ok := c.finalizing[ref]
+		delete(c.finalizing, ref)
+		c.mu.Unlock()
+		if !ok {
+			return fail(ctx, instID, "failed to finalize: instruction %v is not awaiting finalization", ref)
+		}
+
+		err := f.plan.Finalize()
+		c.mu.Lock()
+		c.releasePlan(f.bdID, f.plan)
+		c.mu.Unlock()
+		if err != nil {
+			return fail(ctx, instID, "failed to finalize instruction %v: %v", ref, err)
+		}
+		return &fnpb.InstructionResponse{
+			InstructionId: string(instID),
+			Response: &fnpb.InstructionResponse_FinalizeBundle{
+				FinalizeBundle: &fnpb.FinalizeBundleResponse{},
+			},
+		}
+
 	case req.GetMonitoringInfos() != nil:
 		msg := req.GetMonitoringInfos()
 		return &fnpb.InstructionResponse{
@@ -452,8 +793,7 @@ func (c *control) handleInstruction(ctx context.Context, req *fnpb.InstructionRe
 			InstructionId: string(instID),
 			Response: &fnpb.InstructionResponse_HarnessMonitoringInfos{
 				HarnessMonitoringInfos: &fnpb.HarnessMonitoringInfosResponse{
-					// TODO(BEAM-11092): Populate with non-bundle metrics data.
-					MonitoringData: map[string][]byte{},
+					MonitoringData: c.system.monitoringData(),
 				},
 			},
 		}
@@ -16,11 +16,15 @@
 package harness
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
 	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
 	"github.com/golang/protobuf/proto"
@@ -164,6 +168,101 @@ func TestControl_getOrCreatePlan(t *testing.T) {
 
 }
 
+func TestControl_evictIdlePlans(t *testing.T) {
+	testBDID := bundleDescriptorID("test")
+	newPlan := func() *exec.Plan {
+		plan, err := exec.UnmarshalPlan(validDescriptor(t))
+		if err != nil {
+			t.Fatal("bad testPlan")
+		}
+		return plan
+	}
+	stale, recent := newPlan(), newPlan()
+	now := time.Now()
+	ctrl := &control{
+		plans: map[bundleDescriptorID][]*exec.Plan{
+			testBDID: []*exec.Plan{stale, recent},
+			"other":  []*exec.Plan{newPlan()},
+		},
+		idle: map[*exec.Plan]time.Time{
+			stale:  now.Add(-2 * planIdleTimeout),
+			recent: now,
+		},
+	}
+
+	ctrl.evictIdlePlans(context.Background(), now.Add(-planIdleTimeout))
+	if got, want := ctrl.plans[testBDID], []*exec.Plan{recent}; !reflect.DeepEqual(got, want) {
+		t.Errorf("plans after eviction = %v, want only the recently used plan %v", got, want)
+	}
+	if _, ok := ctrl.plans["other"]; ok {
+		t.Errorf("plans of other descriptor = %v, want them evicted", ctrl.plans["other"])
+	}
+
+	// Reusing a plan makes it active, so it's never evicted.
+	plan, err := ctrl.getOrCreatePlan(testBDID)
+	if err != nil || plan != recent {
+		t.Fatalf("getOrCreatePlan() = %v, %v, want the cached plan", plan, err)
+	}
+	ctrl.evictIdlePlans(context.Background(), now.Add(time.Hour))
+	ctrl.releasePlan(testBDID, plan)
+	if got, want := ctrl.plans[testBDID], []*exec.Plan{recent}; !reflect.DeepEqual(got, want) {
+		t.Errorf("plans after release = %v, want %v", got, want)
+	}
+}
+
+func TestControl_finalizeBundle(t *testing.T) {
+	testBDID := bundleDescriptorID("test")
+	plan, err := exec.UnmarshalPlan(validDescriptor(t))
+	if err != nil {
+		t.Fatal("bad testPlan")
+	}
+	ctrl := &control{
+		plans: make(map[bundleDescriptorID][]*exec.Plan),
+		finalizing: map[instructionID]finalizingPlan{
+			"bundle": {bdID: testBDID, plan: plan, since: time.Now()},
+		},
+	}
+	finalize := func(ref instructionID) *fnpb.InstructionResponse {
+		return ctrl.handleInstruction(context.Background(), &fnpb.InstructionRequest{
+			InstructionId: "finalize",
+			Request: &fnpb.InstructionRequest_FinalizeBundle{
+				FinalizeBundle: &fnpb.FinalizeBundleRequest{InstructionId: string(ref)},
+			},
+		})
+	}
+
+	if resp := finalize("bundle"); resp.GetError() != "" || resp.GetFinalizeBundle() == nil {
+		t.Fatalf("FinalizeBundle response = %v, want success", resp)
+	}
+	if got, want := ctrl.plans[testBDID], []*exec.Plan{plan}; !reflect.DeepEqual(got, want) {
+		t.Errorf("plans after finalization = %v, want the finalized plan %v", got, want)
+	}
+	if resp := finalize("bundle"); resp.GetError() == "" {
+		t.Errorf("FinalizeBundle response for finalized bundle = %v, want error", resp)
+	}
+}
+
+func TestControl_evictIdlePlans_finalizing(t *testing.T) {
+	plan, err := exec.UnmarshalPlan(validDescriptor(t))
+	if err != nil {
+		t.Fatal("bad testPlan")
+	}
+	now := time.Now()
+	ctrl := &control{
+		finalizing: map[instructionID]finalizingPlan{
+			"stale":  {bdID: "test", plan: plan, since: now.Add(-2 * planIdleTimeout)},
+			"recent": {bdID: "test", plan: plan, since: now},
+		},
+	}
+	ctrl.evictIdlePlans(context.Background(), now.Add(-planIdleTimeout))
+	if _, ok := ctrl.finalizing["stale"]; ok {
+		t.Errorf("plan never finalized was kept, want it evicted")
+	}
+	if _, ok := ctrl.finalizing["recent"]; !ok {
+		t.Errorf("plan awaiting finalization was evicted, want it kept")
+	}
+}
+
 func TestCircleBuffer(t *testing.T) {
 	expected1 := instructionID("expected1")
 	expected2 := instructionID("expected2")
@@ -229,3 +328,31 @@ func TestCircleBuffer(t *testing.T) {
 		}
 	})
 }
+
+func TestCapabilities(t *testing.T) {
+	env, err := graphx.CreateEnvironment(context.Background(), "beam:env:docker:v1", func(context.Context) string { return "image" })
+	if err != nil {
+		t.Fatalf("CreateEnvironment failed: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, c := range env.GetCapabilities() {
+		got[c] = true
+	}
+	for _, c := range []string{
+		graphx.URNMultiCore,
+		graphx.URNElementsEmbedding,
+		graphx.URNMonitoringInfoShortIDs,
+		graphx.URNProgressReporting,
+		graphx.URNStateCaching,
+		graphx.URNWorkerStatus,
+		graphx.URNRequiresSplittableDoFn,
+		graphx.URNRequiresBundleFinalization,
+	} {
+		if !got[c] {
+			t.Errorf("capabilities = %v, want %v", env.GetCapabilities(), c)
+		}
+	}
+	if got[graphx.URNLegacyProgressReporting] {
+		t.Errorf("capabilities = %v, want no %v, since progress is reported by short ids", env.GetCapabilities(), graphx.URNLegacyProgressReporting)
+	}
+}
@@ -26,9 +26,11 @@ import (
 
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"runtime/debug"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/artifact"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/harness"
 	"github.com/apache/beam/sdks/go/pkg/beam/util/grpcx"
@@ -87,6 +89,8 @@ func hook() {
 		}
 		runtime.GlobalOptions.Import(opt.Options)
 	}
+	// The container boot code retrieves the staged files into this directory.
+	artifact.SetStagedDir(filepath.Join(*semiPersistDir, "staged"))
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -107,7 +111,24 @@ func hook() {
 	// does, and establish the background context here.
 
 	ctx := grpcx.WriteWorkerID(context.Background(), *id)
-	if err := harness.Main(ctx, *loggingEndpoint, *controlEndpoint); err != nil {
+	if *statusEndpoint != "" {
+		ctx = harness.WithStatusEndpoint(ctx, *statusEndpoint)
+	}
+	// The worker owns the process, so it can drain its bundles on SIGTERM.
+	ctx = harness.WithGracefulTermination(ctx)
+	err := harness.Main(ctx, *loggingEndpoint, *controlEndpoint)
+	if err == harness.ErrTerminated {
+		fmt.Fprintln(os.Stderr, "Worker terminated after draining its bundles.")
+		switch ShutdownMode {
+		case Terminate:
+			os.Exit(0)
+		case Return:
+			return
+		default:
+			panic(fmt.Sprintf("unknown ShutdownMode: %v", ShutdownMode))
+		}
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Worker failed: %v\n", err)
 		switch ShutdownMode {
 		case Terminate:
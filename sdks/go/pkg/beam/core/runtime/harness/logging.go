@@ -21,22 +21,37 @@ import (
 	"io"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
-	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
 	"github.com/golang/protobuf/ptypes"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"google.golang.org/grpc/metadata"
 )
 
-// TODO(herohde) 10/12/2017: make this file a separate package. Then
-// populate InstructionId and TransformId properly.
+// TODO(herohde) 10/12/2017: make this file a separate package.
 
 // TODO(herohde) 10/13/2017: add top-level harness.Main panic handler that flushes logs.
 // Also make logger flush on Fatal severity messages.
 type contextKey string
 
-const instKey contextKey = "beam:inst"
+const (
+	instKey         contextKey = "beam:inst"
+	localLoggingKey contextKey = "beam:local_logging"
+)
+
+// WithLocalLogging returns a context for Main, whose harness also prints its
+// log messages of at least the given severity to stdout. It is meant for
+// harnesses run in the process of the launcher, such as LOOPBACK workers,
+// whose logs would otherwise only reach the runner.
+func WithLocalLogging(ctx context.Context, sev log.Severity) context.Context {
+	return context.WithValue(ctx, localLoggingKey, sev)
+}
 
 func setInstID(ctx context.Context, id instructionID) context.Context {
 	return context.WithValue(ctx, instKey, id)
@@ -51,10 +66,15 @@ func tryGetInstID(ctx context.Context) (string, bool) {
 }
 
 type logger struct {
-	out chan<- *fnpb.LogEntry
+	out chan *fnpb.LogEntry
+	// local prints the messages to stdout too, if set.
+	local *log.Standard
 }
 
 func (l *logger) Log(ctx context.Context, sev log.Severity, calldepth int, msg string) {
+	if l.local != nil {
+		l.local.Log(ctx, sev, calldepth+1, msg)
+	}
 	now, _ := ptypes.TimestampProto(time.Now())
 
 	entry := &fnpb.LogEntry{
@@ -68,6 +88,10 @@ func (l *logger) Log(ctx context.Context, sev log.Severity, calldepth int, msg s
 	if id, ok := tryGetInstID(ctx); ok {
 		entry.InstructionId = id
 	}
+	if id, ok := metrics.GetPTransformID(ctx); ok {
+		entry.TransformId = id
+	}
+	entry.CustomData = customData(ctx)
 
 	select {
 	case l.out <- entry:
@@ -78,6 +102,84 @@ func (l *logger) Log(ctx context.Context, sev log.Severity, calldepth int, msg s
 	}
 }
 
+// elementTimestampField is the field of the log entries holding the event
+// time of the element being processed, if any.
+const elementTimestampField = "element_timestamp"
+
+// customData returns the structured data of log entries with the context,
+// which holds the fields attached by the user, and the timestamp of the
+// element being processed. Returns nil if there are none.
+func customData(ctx context.Context) *structpb.Struct {
+	fields := log.Fields(ctx)
+	ts, hasTS := exec.ElementTimestamp(ctx)
+	if len(fields) == 0 && !hasTS {
+		return nil
+	}
+	data := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(fields)+1)}
+	for _, f := range fields {
+		data.Fields[f.Key] = convertValue(f.Value)
+	}
+	if hasTS {
+		data.Fields[elementTimestampField] = stringValue(formatTimestamp(ts))
+	}
+	return data
+}
+
+// convertValue converts a field value to a Struct value. Values other than
+// strings, numbers and booleans are formatted as strings.
+func convertValue(v interface{}) *structpb.Value {
+	var f float64
+	switch v := v.(type) {
+	case nil:
+		return &structpb.Value{Kind: &structpb.Value_NullValue{}}
+	case string:
+		return stringValue(v)
+	case bool:
+		return &structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: v}}
+	case int:
+		f = float64(v)
+	case int8:
+		f = float64(v)
+	case int16:
+		f = float64(v)
+	case int32:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case uint:
+		f = float64(v)
+	case uint8:
+		f = float64(v)
+	case uint16:
+		f = float64(v)
+	case uint32:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	default:
+		return stringValue(fmt.Sprint(v))
+	}
+	return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: f}}
+}
+
+func stringValue(s string) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: s}}
+}
+
+// formatTimestamp formats an event time as RFC 3339, except for the
+// timestamps at the ends of time.
+func formatTimestamp(ts mtime.Time) string {
+	switch ts {
+	case mtime.MinTimestamp, mtime.MaxTimestamp, mtime.EndOfGlobalWindowTime:
+		return ts.String()
+	}
+	return time.Unix(0, ts.Milliseconds()*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano)
+}
+
 func convertSeverity(sev log.Severity) fnpb.LogEntry_Severity_Enum {
 	switch sev {
 	case log.SevDebug:
@@ -95,48 +197,156 @@ func convertSeverity(sev log.Severity) fnpb.LogEntry_Severity_Enum {
 	}
 }
 
+// logFlushTimeout is how long a harness waits for its buffered log messages
+// to be sent when it exits.
+const logFlushTimeout = 5 * time.Second
+
 // setupRemoteLogging redirects local log messages to FnHarness. It will
-// try to reconnect, if a connection goes bad. Falls back to stdout.
-func setupRemoteLogging(ctx context.Context, endpoint string) {
+// try to reconnect, if a connection goes bad. Falls back to stdout. The
+// returned function stops the redirection once the harness exits, and
+// waits for the buffered messages to be sent for up to logFlushTimeout.
+func setupRemoteLogging(ctx context.Context, endpoint string) func() {
 	buf := make(chan *fnpb.LogEntry, 2000)
-	log.SetLogger(&logger{out: buf})
+	l := &logger{out: buf}
+	if sev, ok := ctx.Value(localLoggingKey).(log.Severity); ok {
+		l.local = &log.Standard{Level: sev}
+	}
+	id := workerID(ctx)
+	loggers.add(id, l)
+	setLoggerOnce.Do(func() { log.SetLogger(loggers) })
 
-	w := &remoteWriter{buf, endpoint}
+	w := &remoteWriter{buffer: buf, endpoint: endpoint, done: make(chan struct{})}
 	go w.Run(ctx)
+	return func() {
+		loggers.remove(id, l)
+		select {
+		case <-w.done:
+		case <-time.After(logFlushTimeout):
+		}
+	}
+}
+
+// workerID returns the ID of the worker that the context is for, if any.
+func workerID(ctx context.Context) string {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if ids := md.Get("worker_id"); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+var (
+	loggers       = &workerLoggers{workers: make(map[string]*logger)}
+	setLoggerOnce sync.Once
+)
+
+// workerLoggers is the global logger of harnesses. Several harnesses can run
+// in the same process, such as LOOPBACK workers, so it sends log messages to
+// the logging service of the worker logging them, and logs messages without
+// a worker to the most recently started worker. Once all workers have exited,
+// messages are logged to stdout again.
+type workerLoggers struct {
+	mu      sync.Mutex
+	workers map[string]*logger
+	order   []*logger
+}
+
+func (w *workerLoggers) add(id string, l *logger) {
+	w.mu.Lock()
+	w.workers[id] = l
+	w.order = append(w.order, l)
+	w.mu.Unlock()
+}
+
+func (w *workerLoggers) remove(id string, l *logger) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.workers[id] == l {
+		delete(w.workers, id)
+	}
+	for i, o := range w.order {
+		if o == l {
+			w.order = append(w.order[:i], w.order[i+1:]...)
+			break
+		}
+	}
+	// The logger is unreachable now, so its buffer can be closed.
+	close(l.out)
+}
+
+func (w *workerLoggers) Log(ctx context.Context, sev log.Severity, calldepth int, msg string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	l, ok := w.workers[workerID(ctx)]
+	if !ok && len(w.order) > 0 {
+		l = w.order[len(w.order)-1]
+	}
+	if l == nil {
+		(&log.Standard{}).Log(ctx, sev, calldepth+1, msg)
+		return
+	}
+	l.Log(ctx, sev, calldepth+1, msg)
 }
 
 type remoteWriter struct {
 	buffer   chan *fnpb.LogEntry
 	endpoint string
+	// done is closed once the writer stops.
+	done chan struct{}
+	// unsent is the message that failed to be sent, if any, which is sent
+	// first once reconnected.
+	unsent *fnpb.LogEntry
 }
 
 func (w *remoteWriter) Run(ctx context.Context) error {
+	defer close(w.done)
+	backoff := reconnectBackoff
 	for {
-		err := w.connect(ctx)
-		if err == io.EOF {
+		sent, err := w.connect(ctx)
+		if err == io.EOF || ctx.Err() != nil {
 			fmt.Fprintf(os.Stderr, "Remote logging shutting down.")
 			return nil
 		}
+		if sent {
+			backoff = reconnectBackoff
+		}
 
-		fmt.Fprintf(os.Stderr, "Remote logging failed: %v. Retrying in 5 sec ...\n", err)
-		time.Sleep(5 * time.Second)
+		fmt.Fprintf(os.Stderr, "Remote logging failed: %v. Retrying in %v ...\n", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff = nextBackoff(backoff)
 	}
 }
 
-func (w *remoteWriter) connect(ctx context.Context) error {
+// connect sends the buffered messages to the logging service, until the
+// harness exits or the stream fails. Returns whether any message was sent.
+func (w *remoteWriter) connect(ctx context.Context) (bool, error) {
 	conn, err := dial(ctx, w.endpoint, 30*time.Second)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer conn.Close()
 
 	client, err := fnpb.NewBeamFnLoggingClient(conn).Logging(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer client.CloseSend()
 
-	for msg := range w.buffer {
+	sent := false
+	for {
+		msg := w.unsent
+		if msg == nil {
+			var ok bool
+			if msg, ok = <-w.buffer; !ok {
+				// The harness exited.
+				return sent, io.EOF
+			}
+		}
 		// fmt.Fprintf(os.Stderr, "REMOTE: %v\n", proto.MarshalTextString(msg))
 
 		// TODO: batch up log messages
@@ -149,14 +359,22 @@ func (w *remoteWriter) connect(ctx context.Context) error {
 
 		if err := client.Send(list); err != nil {
 			if err == io.EOF {
+				// The stream ended, and its status tells whether the service
+				// ended it, or the connection dropped.
+				if _, err := client.Recv(); isTransient(err) {
+					w.unsent = msg
+					return sent, err
+				}
 				(&log.Standard{}).Log(ctx, log.SevInfo, 0, msg.GetMessage())
-				return io.EOF
+				return sent, io.EOF
 			}
 			fmt.Fprintf(os.Stderr, "Failed to send message: %v\n %v", err, msg.GetMessage())
-			return err
+			w.unsent = msg
+			return sent, err
 		}
+		w.unsent = nil
+		sent = true
 
 		// fmt.Fprintf(os.Stderr, "SENT: %v\n", msg)
 	}
-	return errors.New("internal: buffer closed?")
 }
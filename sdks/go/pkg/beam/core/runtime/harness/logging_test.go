@@ -20,8 +20,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	"github.com/apache/beam/sdks/go/pkg/beam/util/grpcx"
 )
 
 func TestLogger(t *testing.T) {
@@ -42,10 +45,97 @@ func TestLogger(t *testing.T) {
 		t.Errorf("incorrect Message: got %v, want %v", got, want)
 	}
 	// This check will fail if the imports change.
-	if got, want := e.GetLogLocation(), "logging_test.go:34"; !strings.HasSuffix(got, want) {
+	if got, want := e.GetLogLocation(), "logging_test.go:37"; !strings.HasSuffix(got, want) {
 		t.Errorf("incorrect LogLocation: got %v, want suffix %v", got, want)
 	}
 	if got, want := e.GetSeverity(), fnpb.LogEntry_Severity_INFO; got != want {
 		t.Errorf("incorrect Severity: got %v, want %v", got, want)
 	}
 }
+
+func TestLogger_structured(t *testing.T) {
+	ch := make(chan *fnpb.LogEntry, 1)
+	l := logger{out: ch}
+
+	ctx := metrics.SetPTransformID(metrics.SetBundleID(context.Background(), "INST"), "PT")
+	ctx = log.WithField(ctx, "user", "alice")
+	ctx = log.WithField(ctx, "count", 3)
+	ctx = log.WithField(ctx, "user", "bob")
+	l.Log(ctx, log.SevInfo, 0, "expectedMessage")
+
+	e := <-ch
+
+	if got, want := e.GetTransformId(), "PT"; got != want {
+		t.Errorf("incorrect TransformID: got %v, want %v", got, want)
+	}
+	fields := e.GetCustomData().GetFields()
+	if got, want := len(fields), 2; got != want {
+		t.Errorf("incorrect number of CustomData fields: got %v, want %v", fields, want)
+	}
+	if got, want := fields["user"].GetStringValue(), "bob"; got != want {
+		t.Errorf("incorrect CustomData[user]: got %v, want %v", got, want)
+	}
+	if got, want := fields["count"].GetNumberValue(), 3.0; got != want {
+		t.Errorf("incorrect CustomData[count]: got %v, want %v", got, want)
+	}
+
+	l.Log(context.Background(), log.SevInfo, 0, "expectedMessage")
+	if e := <-ch; e.GetCustomData() != nil || e.GetTransformId() != "" {
+		t.Errorf("unexpected structured data without fields: got %v, %v, want nil, \"\"", e.GetCustomData(), e.GetTransformId())
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	tests := []struct {
+		ts   mtime.Time
+		want string
+	}{
+		{mtime.Time(1500), "1970-01-01T00:00:01.5Z"},
+		{mtime.MinTimestamp, "-inf"},
+		{mtime.EndOfGlobalWindowTime, "glo"},
+	}
+	for _, test := range tests {
+		if got := formatTimestamp(test.ts); got != test.want {
+			t.Errorf("formatTimestamp(%v) = %v, want %v", int64(test.ts), got, test.want)
+		}
+	}
+}
+
+func TestWorkerLoggers(t *testing.T) {
+	w := &workerLoggers{workers: make(map[string]*logger)}
+	ch1 := make(chan *fnpb.LogEntry, 10)
+	ch2 := make(chan *fnpb.LogEntry, 10)
+	l1, l2 := &logger{out: ch1}, &logger{out: ch2}
+	w.add("worker1", l1)
+	w.add("worker2", l2)
+
+	ctx1 := grpcx.WriteWorkerID(context.Background(), "worker1")
+	ctx2 := grpcx.WriteWorkerID(context.Background(), "worker2")
+	w.Log(ctx1, log.SevInfo, 0, "one")
+	w.Log(ctx2, log.SevInfo, 0, "two")
+	w.Log(context.Background(), log.SevInfo, 0, "none")
+
+	if got, want := (<-ch1).GetMessage(), "one"; got != want {
+		t.Errorf("worker1 message = %v, want %v", got, want)
+	}
+	if got, want := (<-ch2).GetMessage(), "two"; got != want {
+		t.Errorf("worker2 message = %v, want %v", got, want)
+	}
+	// Messages without a worker go to the most recently started worker.
+	if got, want := (<-ch2).GetMessage(), "none"; got != want {
+		t.Errorf("message without worker = %v, want %v", got, want)
+	}
+
+	w.remove("worker2", l2)
+	if _, ok := <-ch2; ok {
+		t.Error("worker2 buffer open after removal, want closed")
+	}
+	w.Log(ctx2, log.SevInfo, 0, "after")
+	if got, want := (<-ch1).GetMessage(), "after"; got != want {
+		t.Errorf("message of removed worker = %v, want %v", got, want)
+	}
+	w.remove("worker1", l1)
+	if len(w.workers) != 0 || len(w.order) != 0 {
+		t.Errorf("loggers after removing all workers: %v, %v, want none", w.workers, w.order)
+	}
+}
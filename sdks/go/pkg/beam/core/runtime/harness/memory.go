@@ -0,0 +1,146 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	beamrt "github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+const (
+	memoryLimitKey contextKey = "beam:memory_limiter"
+
+	memorySamplePeriod = 100 * time.Millisecond
+	// Reads and bundles are only delayed for a while, since they can be
+	// needed to finish the bundles holding the memory.
+	dataReadThrottle    = time.Second
+	bundleStartThrottle = 30 * time.Second
+)
+
+// memoryLimit returns the worker_memory_limit pipeline option, if set.
+func memoryLimit(ctx context.Context) uint64 {
+	opt := beamrt.GlobalOptions.Get("worker_memory_limit")
+	if opt == "" || opt == "0" {
+		return 0
+	}
+	limit, err := strconv.ParseUint(opt, 10, 64)
+	if err != nil {
+		log.Errorf(ctx, "memory throttling disabled, invalid worker_memory_limit %q: %v", opt, err)
+		return 0
+	}
+	return limit
+}
+
+// memoryLimiter throttles the harness while its heap exceeds a limit, so that
+// large elements or hot keys slow the worker down instead of getting it killed
+// for running out of memory. While throttled, data channels delay reading
+// more elements, and new bundles wait for the active bundles to release
+// memory.
+type memoryLimiter struct {
+	limit    uint64
+	readHeap func() uint64
+
+	mu    sync.Mutex
+	under chan struct{} // closed while the heap is under the limit
+}
+
+func newMemoryLimiter(limit uint64) *memoryLimiter {
+	under := make(chan struct{})
+	close(under)
+	return &memoryLimiter{limit: limit, readHeap: heapAlloc, under: under}
+}
+
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// withMemoryLimiter returns a context for the harness, whose data channels and
+// bundles are throttled by the limiter.
+func withMemoryLimiter(ctx context.Context, m *memoryLimiter) context.Context {
+	return context.WithValue(ctx, memoryLimitKey, m)
+}
+
+// getMemoryLimiter returns the limiter of the context, or nil if there's none.
+func getMemoryLimiter(ctx context.Context) *memoryLimiter {
+	m, _ := ctx.Value(memoryLimitKey).(*memoryLimiter)
+	return m
+}
+
+// run samples the heap until the context is done.
+func (m *memoryLimiter) run(ctx context.Context) {
+	t := time.NewTicker(memorySamplePeriod)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.sample(ctx)
+		}
+	}
+}
+
+// sample updates whether the harness is throttled from the heap size.
+func (m *memoryLimiter) sample(ctx context.Context) {
+	heap := m.readHeap()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case <-m.under:
+		if heap >= m.limit {
+			log.Warnf(ctx, "heap of %d bytes exceeds the worker memory limit of %d bytes, throttling data reads and new bundles", heap, m.limit)
+			m.under = make(chan struct{})
+		}
+	default:
+		if heap < m.limit {
+			log.Infof(ctx, "heap of %d bytes is under the worker memory limit again", heap)
+			close(m.under)
+		}
+	}
+}
+
+// wait blocks while the heap exceeds the limit, for at most the timeout.
+// A nil limiter never blocks.
+func (m *memoryLimiter) wait(ctx context.Context, timeout time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	under := m.under
+	m.mu.Unlock()
+
+	select {
+	case <-under:
+		return
+	default:
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-under:
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
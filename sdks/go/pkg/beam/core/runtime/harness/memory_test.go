@@ -0,0 +1,63 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	var heap uint64
+	m := newMemoryLimiter(100)
+	m.readHeap = func() uint64 { return heap }
+
+	waited := func() time.Duration {
+		start := time.Now()
+		m.wait(ctx, 50*time.Millisecond)
+		return time.Since(start)
+	}
+
+	heap = 50
+	m.sample(ctx)
+	if d := waited(); d >= 50*time.Millisecond {
+		t.Errorf("wait() under the limit took %v, want no wait", d)
+	}
+
+	heap = 150
+	m.sample(ctx)
+	if d := waited(); d < 50*time.Millisecond {
+		t.Errorf("wait() over the limit took %v, want the timeout", d)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.wait(ctx, time.Minute)
+		close(done)
+	}()
+	heap = 80
+	m.sample(ctx)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait() wasn't released once the heap was under the limit")
+	}
+
+	var nilLimiter *memoryLimiter
+	nilLimiter.wait(ctx, time.Minute) // Doesn't block.
+}
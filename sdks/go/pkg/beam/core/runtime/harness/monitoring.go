@@ -23,6 +23,7 @@ import (
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/metricsx"
 	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
 )
@@ -72,7 +73,7 @@ func (c *shortIDCache) getShortID(l metrics.Labels, urn metricsx.Urn) string {
 	c.shortIds2Infos[s] = &pipepb.MonitoringInfo{
 		Urn:    metricsx.UrnToString(urn),
 		Type:   metricsx.UrnToType(urn),
-		Labels: userLabels(l),
+		Labels: monitoringLabels(l),
 	}
 	return s
 }
@@ -92,6 +93,8 @@ var defaultShortIDCache *shortIDCache
 
 func init() {
 	defaultShortIDCache = newShortIDCache()
+	graphx.RegisterCapability(graphx.URNMonitoringInfoShortIDs)
+	graphx.RegisterCapability(graphx.URNProgressReporting)
 }
 
 func getShortID(l metrics.Labels, urn metricsx.Urn) string {
@@ -160,27 +163,122 @@ func monitoring(p *exec.Plan) ([]*pipepb.MonitoringInfo, map[string][]byte) {
 				})
 
 		},
+		StringSet: func(l metrics.Labels, vs []string) {
+			payload, err := metricsx.StringSet(vs)
+			if err != nil {
+				panic(err)
+			}
+			payloads[getShortID(l, metricsx.UrnUserSetString)] = payload
+
+			monitoringInfo = append(monitoringInfo,
+				&pipepb.MonitoringInfo{
+					Urn:     metricsx.UrnToString(metricsx.UrnUserSetString),
+					Type:    metricsx.UrnToType(metricsx.UrnUserSetString),
+					Labels:  userLabels(l),
+					Payload: payload,
+				})
+		},
+		HistogramInt64: func(l metrics.Labels, v metrics.HistogramValue) {
+			payload, err := metricsx.Int64Histogram(v)
+			if err != nil {
+				panic(err)
+			}
+			payloads[getShortID(l, metricsx.UrnUserHistogramInt64)] = payload
+
+			monitoringInfo = append(monitoringInfo,
+				&pipepb.MonitoringInfo{
+					Urn:     metricsx.UrnToString(metricsx.UrnUserHistogramInt64),
+					Type:    metricsx.UrnToType(metricsx.UrnUserHistogramInt64),
+					Labels:  userLabels(l),
+					Payload: payload,
+				})
+		},
+		BoundedTrie: func(l metrics.Labels, v metrics.BoundedTrieValue) {
+			payload, err := metricsx.BoundedTrie(v)
+			if err != nil {
+				panic(err)
+			}
+			payloads[getShortID(l, metricsx.UrnUserBoundedTrie)] = payload
+
+			monitoringInfo = append(monitoringInfo,
+				&pipepb.MonitoringInfo{
+					Urn:     metricsx.UrnToString(metricsx.UrnUserBoundedTrie),
+					Type:    metricsx.UrnToType(metricsx.UrnUserBoundedTrie),
+					Labels:  userLabels(l),
+					Payload: payload,
+				})
+		},
 	}.ExtractFrom(store)
 
 	// Get the execution monitoring information from the bundle plan.
-	if snapshot, ok := p.Progress(); ok {
-		payload, err := metricsx.Int64Counter(snapshot.Count)
+	for _, c := range p.PCollections() {
+		labels := map[string]string{
+			"PCOLLECTION": c.ID,
+		}
+		payload, err := metricsx.Int64Counter(c.ElementCount)
 		if err != nil {
 			panic(err)
 		}
 
 		// TODO(BEAM-9934): This metric should account for elements in multiple windows.
-		payloads[getShortID(metrics.PCollectionLabels(snapshot.PID), metricsx.UrnElementCount)] = payload
+		payloads[getShortID(metrics.PCollectionLabels(c.ID), metricsx.UrnElementCount)] = payload
 		monitoringInfo = append(monitoringInfo,
 			&pipepb.MonitoringInfo{
-				Urn:  metricsx.UrnToString(metricsx.UrnElementCount),
-				Type: metricsx.UrnToType(metricsx.UrnElementCount),
-				Labels: map[string]string{
-					"PCOLLECTION": snapshot.PID,
-				},
+				Urn:     metricsx.UrnToString(metricsx.UrnElementCount),
+				Type:    metricsx.UrnToType(metricsx.UrnElementCount),
+				Labels:  labels,
 				Payload: payload,
 			})
 
+		if c.SizeCount == 0 {
+			continue
+		}
+		payload, err = metricsx.Int64Distribution(c.SizeCount, c.SizeSum, c.SizeMin, c.SizeMax)
+		if err != nil {
+			panic(err)
+		}
+		payloads[getShortID(metrics.PCollectionLabels(c.ID), metricsx.UrnSampledByteSize)] = payload
+		monitoringInfo = append(monitoringInfo,
+			&pipepb.MonitoringInfo{
+				Urn:     metricsx.UrnToString(metricsx.UrnSampledByteSize),
+				Type:    metricsx.UrnToType(metricsx.UrnSampledByteSize),
+				Labels:  labels,
+				Payload: payload,
+			})
+	}
+	for _, e := range p.ExecutionTimes() {
+		labels := map[string]string{
+			"PTRANSFORM": e.PID,
+		}
+		for _, t := range []struct {
+			urn metricsx.Urn
+			d   time.Duration
+		}{
+			{metricsx.UrnStartBundle, e.Start},
+			{metricsx.UrnProcessBundle, e.Process},
+			{metricsx.UrnFinishBundle, e.Finish},
+			{metricsx.UrnTransformTotalTime, e.Total()},
+		} {
+			payload, err := metricsx.Int64Counter(t.d.Milliseconds())
+			if err != nil {
+				panic(err)
+			}
+			payloads[getShortID(metrics.PTransformLabels(e.PID), t.urn)] = payload
+			monitoringInfo = append(monitoringInfo,
+				&pipepb.MonitoringInfo{
+					Urn:     metricsx.UrnToString(t.urn),
+					Type:    metricsx.UrnToType(t.urn),
+					Labels:  labels,
+					Payload: payload,
+				})
+		}
+	}
+	if snapshot, ok := p.Progress(); ok {
+		payload, err := metricsx.Int64Counter(snapshot.Count)
+		if err != nil {
+			panic(err)
+		}
+
 		payloads[getShortID(metrics.PTransformLabels(snapshot.ID), metricsx.UrnDataChannelReadIndex)] = payload
 		monitoringInfo = append(monitoringInfo,
 			&pipepb.MonitoringInfo{
@@ -191,12 +289,59 @@ func monitoring(p *exec.Plan) ([]*pipepb.MonitoringInfo, map[string][]byte) {
 				},
 				Payload: payload,
 			})
+
+		if w := snapshot.Work; w != nil {
+			labels := map[string]string{
+				"PTRANSFORM": w.TransformID,
+			}
+			for _, p := range []struct {
+				urn metricsx.Urn
+				v   float64
+			}{
+				{metricsx.UrnProgressCompleted, w.Completed},
+				{metricsx.UrnProgressRemaining, w.Remaining},
+			} {
+				urn := p.urn
+				payload, err := metricsx.Float64Progress(p.v)
+				if err != nil {
+					panic(err)
+				}
+				payloads[getShortID(metrics.PTransformLabels(w.TransformID), urn)] = payload
+				monitoringInfo = append(monitoringInfo,
+					&pipepb.MonitoringInfo{
+						Urn:     metricsx.UrnToString(urn),
+						Type:    metricsx.UrnToType(urn),
+						Labels:  labels,
+						Payload: payload,
+					})
+			}
+		}
 	}
 
 	return monitoringInfo,
 		payloads
 }
 
+// monitoringLabels returns the MonitoringInfo labels of user, pcollection,
+// transform and worker metrics.
+func monitoringLabels(l metrics.Labels) map[string]string {
+	if l == (metrics.Labels{}) {
+		// Worker metrics aren't specific to a transform or pcollection.
+		return map[string]string{}
+	}
+	if pcol := l.PCollection(); pcol != "" {
+		return map[string]string{
+			"PCOLLECTION": pcol,
+		}
+	}
+	if l.Namespace() == "" && l.Name() == "" {
+		return map[string]string{
+			"PTRANSFORM": l.Transform(),
+		}
+	}
+	return userLabels(l)
+}
+
 func userLabels(l metrics.Labels) map[string]string {
 	return map[string]string{
 		"PTRANSFORM": l.Transform(),
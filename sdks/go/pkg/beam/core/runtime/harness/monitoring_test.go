@@ -16,6 +16,7 @@
 package harness
 
 import (
+	"reflect"
 	"strconv"
 	"testing"
 
@@ -25,11 +26,12 @@ import (
 
 func TestGetShortID(t *testing.T) {
 	tests := []struct {
-		id           string
-		urn          metricsx.Urn
-		labels       metrics.Labels
-		expectedUrn  string
-		expectedType string
+		id             string
+		urn            metricsx.Urn
+		labels         metrics.Labels
+		expectedUrn    string
+		expectedType   string
+		expectedLabels map[string]string
 	}{
 		{
 			id:           "1",
@@ -73,6 +75,11 @@ func TestGetShortID(t *testing.T) {
 			labels:       metrics.UserLabels("myT", "harness", "metricNumber7"),
 			expectedUrn:  "beam:metric:user:sum_int64:v1",
 			expectedType: "beam:metrics:sum_int64:v1",
+			expectedLabels: map[string]string{
+				"PTRANSFORM": "myT",
+				"NAMESPACE":  "harness",
+				"NAME":       "metricNumber7",
+			},
 		}, {
 			id:           "8",
 			urn:          metricsx.UrnUserSumInt64,
@@ -95,6 +102,18 @@ func TestGetShortID(t *testing.T) {
 			labels:       metrics.PCollectionLabels("myPCol"),
 			expectedUrn:  "beam:metric:element_count:v1",
 			expectedType: "beam:metrics:sum_int64:v1",
+			expectedLabels: map[string]string{
+				"PCOLLECTION": "myPCol",
+			},
+		}, {
+			id:           "b",
+			urn:          metricsx.UrnProgressRemaining,
+			labels:       metrics.PTransformLabels("myT"),
+			expectedUrn:  "beam:metric:ptransform_progress:remaining:v1",
+			expectedType: "beam:metrics:progress:v1",
+			expectedLabels: map[string]string{
+				"PTRANSFORM": "myT",
+			},
 		},
 	}
 	cache := newShortIDCache()
@@ -115,6 +134,9 @@ func TestGetShortID(t *testing.T) {
 			if got, want := info.GetType(), test.expectedType; got != want {
 				t.Errorf("type got %v, want %v", got, want)
 			}
+			if test.expectedLabels != nil && !reflect.DeepEqual(info.GetLabels(), test.expectedLabels) {
+				t.Errorf("labels got %v, want %v", info.GetLabels(), test.expectedLabels)
+			}
 		})
 	}
 	// Validate that we get the same short ids with the same cache.
@@ -0,0 +1,384 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	beamrt "github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+const (
+	// maxQueuedBundleMetrics is the number of bundles whose metrics are
+	// buffered for export, after which their metrics are dropped.
+	maxQueuedBundleMetrics = 1024
+	otlpExportTimeout      = 30 * time.Second
+	// metricsFlushTimeout is how long a harness waits for the remaining
+	// metrics to be exported when it exits.
+	metricsFlushTimeout = 5 * time.Second
+)
+
+// otlpExportPeriod is how often the metrics of the committed bundles are
+// exported.
+var otlpExportPeriod = 10 * time.Second
+
+// setupOTLPMetrics returns an exporter mirroring the user metrics of the
+// bundles the harness commits to the OTLP/HTTP collector at the endpoint of
+// the otlp_metrics_endpoint pipeline option, if set. The returned function
+// exports the remaining metrics once the harness exits.
+func setupOTLPMetrics(ctx context.Context) (*otlpMetricsExporter, func()) {
+	endpoint := beamrt.GlobalOptions.Get("otlp_metrics_endpoint")
+	if endpoint == "" {
+		return nil, func() {}
+	}
+	resource := map[string]string{
+		"service.name":   "beam-go-worker",
+		"beam.worker_id": workerID(ctx),
+	}
+	if job := beamrt.GlobalOptions.Get("job_name"); job != "" {
+		resource["beam.job_name"] = job
+	}
+	e := newOTLPMetricsExporter(ctx, endpoint, resource)
+	return e, func() {
+		ctx, cancel := context.WithTimeout(ctx, metricsFlushTimeout)
+		defer cancel()
+		e.shutdown(ctx)
+	}
+}
+
+// bundleMetrics are the user metrics of a committed bundle.
+type bundleMetrics struct {
+	start, end    time.Time
+	counters      map[metrics.Labels]int64
+	distributions map[metrics.Labels]metrics.DistributionValue
+	gauges        map[metrics.Labels]metrics.GaugeValue
+}
+
+// otlpMetricsExporter exports the user metrics of committed bundles in
+// batches to an OTLP/HTTP collector, as JSON encoded
+// ExportMetricsServiceRequests. Counters and distributions are exported as
+// the deltas of each bundle, and gauges as their latest values.
+type otlpMetricsExporter struct {
+	url      string
+	resource []otlpKeyValue
+	client   *http.Client
+
+	bundles  chan bundleMetrics
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	dropped  int64 // accessed atomically
+}
+
+func newOTLPMetricsExporter(ctx context.Context, endpoint string, resource map[string]string) *otlpMetricsExporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/metrics") {
+		url += "/v1/metrics"
+	}
+	e := &otlpMetricsExporter{
+		url:      url,
+		resource: otlpAttributes(resource),
+		client:   &http.Client{Timeout: otlpExportTimeout},
+		bundles:  make(chan bundleMetrics, maxQueuedBundleMetrics),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.run(ctx)
+	return e
+}
+
+// commit queues the user metrics of the store of a bundle, which started at
+// the given time, for export. It is a no-op if the exporter is nil.
+func (e *otlpMetricsExporter) commit(store *metrics.Store, start time.Time) {
+	if e == nil || store == nil {
+		return
+	}
+	b := bundleMetrics{
+		start:         start,
+		end:           time.Now(),
+		counters:      make(map[metrics.Labels]int64),
+		distributions: make(map[metrics.Labels]metrics.DistributionValue),
+		gauges:        make(map[metrics.Labels]metrics.GaugeValue),
+	}
+	metrics.Extractor{
+		SumInt64: func(l metrics.Labels, v int64) {
+			b.counters[l] = v
+		},
+		DistributionInt64: func(l metrics.Labels, count, sum, min, max int64) {
+			b.distributions[l] = metrics.DistributionValue{Count: count, Sum: sum, Min: min, Max: max}
+		},
+		GaugeInt64: func(l metrics.Labels, v int64, t time.Time) {
+			b.gauges[l] = metrics.GaugeValue{Value: v, Timestamp: t}
+		},
+	}.ExtractFrom(store)
+	if len(b.counters)+len(b.distributions)+len(b.gauges) == 0 {
+		return
+	}
+	select {
+	case e.bundles <- b:
+	default:
+		atomic.AddInt64(&e.dropped, 1)
+	}
+}
+
+// shutdown exports the queued metrics, and stops exporting metrics. It
+// returns once the metrics are exported, or the context is done.
+func (e *otlpMetricsExporter) shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *otlpMetricsExporter) run(ctx context.Context) {
+	defer close(e.done)
+	ticker := time.NewTicker(otlpExportPeriod)
+	defer ticker.Stop()
+
+	var batch []bundleMetrics
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(ctx, batch); err != nil {
+			log.Warnf(ctx, "failed to export the metrics of %d bundles to %v: %v", len(batch), e.url, err)
+		}
+		batch = nil
+		if n := atomic.SwapInt64(&e.dropped, 0); n > 0 {
+			log.Warnf(ctx, "dropped the metrics of %d bundles, since they were committed faster than exported", n)
+		}
+	}
+	for {
+		select {
+		case b := <-e.bundles:
+			batch = append(batch, b)
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case b := <-e.bundles:
+					batch = append(batch, b)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts the metrics of the bundles to the collector.
+func (e *otlpMetricsExporter) send(ctx context.Context, bundles []bundleMetrics) error {
+	req := otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: e.resource},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "github.com/apache/beam/sdks/go"},
+			Metrics: encodeMetrics(bundles),
+		}},
+	}}}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	hreq, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hreq = hreq.WithContext(ctx)
+	hreq.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("collector responded %v: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// The JSON encoding of the OTLP ExportMetricsServiceRequest, whose 64 bit
+// integers are strings.
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name      string         `json:"name"`
+	Sum       *otlpSum       `json:"sum,omitempty"`
+	Gauge     *otlpGauge     `json:"gauge,omitempty"`
+	Histogram *otlpHistogram `json:"histogram,omitempty"`
+}
+
+// aggregationTemporalityDelta is the temporality of data points reporting
+// the changes since their start time.
+const aggregationTemporalityDelta = 1
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsInt             string         `json:"asInt"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	Count             string         `json:"count"`
+	Sum               float64        `json:"sum"`
+	Min               float64        `json:"min"`
+	Max               float64        `json:"max"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// encodeMetrics encodes the metrics of the bundles, with a metric per
+// namespace and name of a user metric, whose data points are attributed to
+// their transforms. The metrics are sorted by name.
+func encodeMetrics(bundles []bundleMetrics) []otlpMetric {
+	byName := make(map[string]*otlpMetric)
+	get := func(l metrics.Labels) *otlpMetric {
+		name := l.Namespace() + "." + l.Name()
+		m, ok := byName[name]
+		if !ok {
+			m = &otlpMetric{Name: name}
+			byName[name] = m
+		}
+		return m
+	}
+	for _, b := range bundles {
+		start := strconv.FormatInt(b.start.UnixNano(), 10)
+		end := strconv.FormatInt(b.end.UnixNano(), 10)
+		for l, v := range b.counters {
+			m := get(l)
+			if m.Sum == nil {
+				m.Sum = &otlpSum{AggregationTemporality: aggregationTemporalityDelta, IsMonotonic: true}
+			}
+			m.Sum.DataPoints = append(m.Sum.DataPoints, otlpNumberDataPoint{
+				Attributes:        transformAttributes(l),
+				StartTimeUnixNano: start,
+				TimeUnixNano:      end,
+				AsInt:             strconv.FormatInt(v, 10),
+			})
+		}
+		for l, v := range b.distributions {
+			m := get(l)
+			if m.Histogram == nil {
+				m.Histogram = &otlpHistogram{AggregationTemporality: aggregationTemporalityDelta}
+			}
+			m.Histogram.DataPoints = append(m.Histogram.DataPoints, otlpHistogramDataPoint{
+				Attributes:        transformAttributes(l),
+				StartTimeUnixNano: start,
+				TimeUnixNano:      end,
+				Count:             strconv.FormatInt(v.Count, 10),
+				Sum:               float64(v.Sum),
+				Min:               float64(v.Min),
+				Max:               float64(v.Max),
+			})
+		}
+		for l, v := range b.gauges {
+			m := get(l)
+			if m.Gauge == nil {
+				m.Gauge = &otlpGauge{}
+			}
+			m.Gauge.DataPoints = append(m.Gauge.DataPoints, otlpNumberDataPoint{
+				Attributes:   transformAttributes(l),
+				TimeUnixNano: strconv.FormatInt(v.Timestamp.UnixNano(), 10),
+				AsInt:        strconv.FormatInt(v.Value, 10),
+			})
+		}
+	}
+	ret := make([]otlpMetric, 0, len(byName))
+	for _, m := range byName {
+		ret = append(ret, *m)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+func transformAttributes(l metrics.Labels) []otlpKeyValue {
+	return []otlpKeyValue{{Key: "beam.ptransform", Value: otlpAnyValue{StringValue: l.Transform()}}}
+}
+
+// otlpAttributes returns the attributes, sorted by key.
+func otlpAttributes(attrs map[string]string) []otlpKeyValue {
+	var ret []otlpKeyValue
+	for k, v := range attrs {
+		ret = append(ret, otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: v}})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Key < ret[j].Key })
+	return ret
+}
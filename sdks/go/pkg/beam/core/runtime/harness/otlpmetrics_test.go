@@ -0,0 +1,89 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOTLPMetricsExporter(t *testing.T) {
+	var path string
+	var got otlpMetricsRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("invalid request %s: %v", body, err)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	e := newOTLPMetricsExporter(ctx, srv.URL, map[string]string{"service.name": "test"})
+
+	bctx := metrics.SetPTransformID(metrics.SetBundleID(ctx, "b1"), "e1")
+	metrics.NewCounter("ns", "count").Inc(bctx, 3)
+	metrics.NewDistribution("ns", "size").Update(bctx, 7)
+	start := time.Unix(100, 0)
+	e.commit(metrics.GetStore(bctx), start)
+	// Bundles without user metrics aren't exported.
+	e.commit(metrics.GetStore(metrics.SetBundleID(ctx, "b2")), start)
+
+	if err := e.shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if path != "/v1/metrics" {
+		t.Errorf("exported to %v, want /v1/metrics", path)
+	}
+	if len(got.ResourceMetrics) != 1 || len(got.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("exported %+v, want a single scope", got)
+	}
+	ms := got.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(ms) != 2 || ms[0].Sum == nil || ms[1].Histogram == nil {
+		t.Fatalf("exported metrics %+v, want a sum and a histogram", ms)
+	}
+	attrs := []otlpKeyValue{{Key: "beam.ptransform", Value: otlpAnyValue{StringValue: "e1"}}}
+	end := ms[0].Sum.DataPoints[0].TimeUnixNano
+	wantSum := otlpMetric{Name: "ns.count", Sum: &otlpSum{
+		AggregationTemporality: aggregationTemporalityDelta,
+		IsMonotonic:            true,
+		DataPoints:             []otlpNumberDataPoint{{Attributes: attrs, StartTimeUnixNano: "100000000000", TimeUnixNano: end, AsInt: "3"}},
+	}}
+	if d := cmp.Diff(wantSum, ms[0]); d != "" {
+		t.Errorf("exported counter diff (-want, +got):\n%v", d)
+	}
+	wantHist := otlpMetric{Name: "ns.size", Histogram: &otlpHistogram{
+		AggregationTemporality: aggregationTemporalityDelta,
+		DataPoints:             []otlpHistogramDataPoint{{Attributes: attrs, StartTimeUnixNano: "100000000000", TimeUnixNano: end, Count: "1", Sum: 7, Min: 7, Max: 7}},
+	}}
+	if d := cmp.Diff(wantHist, ms[1]); d != "" {
+		t.Errorf("exported distribution diff (-want, +got):\n%v", d)
+	}
+}
+
+func TestOTLPMetricsExporter_Nil(t *testing.T) {
+	var e *otlpMetricsExporter
+	e.commit(nil, time.Now()) // Must not panic.
+}
@@ -0,0 +1,235 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	beamrt "github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+// prometheusAddress returns the prometheus_address pipeline option, if set.
+func prometheusAddress() string {
+	return beamrt.GlobalOptions.Get("prometheus_address")
+}
+
+// prometheusKey identifies a user metric of a transform.
+type prometheusKey struct {
+	transform, namespace, name string
+}
+
+// prometheusExporter serves the metrics of the harness, and the user metrics
+// of the bundles it processed, in the Prometheus text format, so that they
+// can be scraped from the worker directly. User metrics are accumulated over
+// all bundles, failed or not, like the attempted metrics of runners.
+type prometheusExporter struct {
+	ctrl *control
+
+	mu            sync.Mutex
+	succeeded     int64
+	failed        int64
+	counters      map[prometheusKey]int64
+	distributions map[prometheusKey]metrics.DistributionValue
+	gauges        map[prometheusKey]metrics.GaugeValue
+}
+
+func newPrometheusExporter(ctrl *control) *prometheusExporter {
+	return &prometheusExporter{
+		ctrl:          ctrl,
+		counters:      make(map[prometheusKey]int64),
+		distributions: make(map[prometheusKey]metrics.DistributionValue),
+		gauges:        make(map[prometheusKey]metrics.GaugeValue),
+	}
+}
+
+// record adds the user metrics of a processed bundle to the exported ones.
+// It is a no-op if the exporter is nil.
+func (e *prometheusExporter) record(store *metrics.Store, err error) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err != nil {
+		e.failed++
+	} else {
+		e.succeeded++
+	}
+	if store == nil {
+		return
+	}
+	key := func(l metrics.Labels) prometheusKey {
+		return prometheusKey{transform: l.Transform(), namespace: l.Namespace(), name: l.Name()}
+	}
+	metrics.Extractor{
+		SumInt64: func(l metrics.Labels, v int64) {
+			e.counters[key(l)] += v
+		},
+		DistributionInt64: func(l metrics.Labels, count, sum, min, max int64) {
+			k := key(l)
+			d, ok := e.distributions[k]
+			if !ok || min < d.Min {
+				d.Min = min
+			}
+			if !ok || max > d.Max {
+				d.Max = max
+			}
+			d.Count += count
+			d.Sum += sum
+			e.distributions[k] = d
+		},
+		GaugeInt64: func(l metrics.Labels, v int64, t time.Time) {
+			k := key(l)
+			if g, ok := e.gauges[k]; !ok || !t.Before(g.Timestamp) {
+				e.gauges[k] = metrics.GaugeValue{Value: v, Timestamp: t}
+			}
+		},
+	}.ExtractFrom(store)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (e *prometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.write(w)
+}
+
+func (e *prometheusExporter) write(w io.Writer) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	writeFamily(w, "beam_worker_active_bundles", "gauge", "Number of bundles being processed by the worker.")
+	fmt.Fprintf(w, "beam_worker_active_bundles %d\n", e.ctrl.activeBundles())
+	writeFamily(w, "beam_worker_heap_bytes", "gauge", "Bytes of allocated heap objects of the worker.")
+	fmt.Fprintf(w, "beam_worker_heap_bytes %d\n", m.HeapAlloc)
+	writeFamily(w, "beam_worker_goroutines", "gauge", "Number of goroutines of the worker.")
+	fmt.Fprintf(w, "beam_worker_goroutines %d\n", runtime.NumGoroutine())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	writeFamily(w, "beam_worker_bundles_total", "counter", "Number of bundles processed by the worker.")
+	fmt.Fprintf(w, "beam_worker_bundles_total{status=\"succeeded\"} %d\n", e.succeeded)
+	fmt.Fprintf(w, "beam_worker_bundles_total{status=\"failed\"} %d\n", e.failed)
+
+	if len(e.counters) > 0 {
+		writeFamily(w, "beam_user_counter", "counter", "User counter metrics.")
+		var keys []prometheusKey
+		for k := range e.counters {
+			keys = append(keys, k)
+		}
+		for _, k := range sortKeys(keys) {
+			fmt.Fprintf(w, "beam_user_counter%v %d\n", k.labels(), e.counters[k])
+		}
+	}
+	if len(e.distributions) > 0 {
+		var keys []prometheusKey
+		for k := range e.distributions {
+			keys = append(keys, k)
+		}
+		sorted := sortKeys(keys)
+		for _, f := range []struct {
+			suffix, typ string
+			value       func(d metrics.DistributionValue) int64
+		}{
+			{"count", "counter", func(d metrics.DistributionValue) int64 { return d.Count }},
+			{"sum", "counter", func(d metrics.DistributionValue) int64 { return d.Sum }},
+			{"min", "gauge", func(d metrics.DistributionValue) int64 { return d.Min }},
+			{"max", "gauge", func(d metrics.DistributionValue) int64 { return d.Max }},
+		} {
+			name := "beam_user_distribution_" + f.suffix
+			writeFamily(w, name, f.typ, fmt.Sprintf("The %v of the values of user distribution metrics.", f.suffix))
+			for _, k := range sorted {
+				fmt.Fprintf(w, "%v%v %d\n", name, k.labels(), f.value(e.distributions[k]))
+			}
+		}
+	}
+	if len(e.gauges) > 0 {
+		var keys []prometheusKey
+		for k := range e.gauges {
+			keys = append(keys, k)
+		}
+		writeFamily(w, "beam_user_gauge", "gauge", "User gauge metrics.")
+		for _, k := range sortKeys(keys) {
+			fmt.Fprintf(w, "beam_user_gauge%v %d\n", k.labels(), e.gauges[k].Value)
+		}
+	}
+}
+
+func writeFamily(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, typ)
+}
+
+// sortKeys sorts the keys by transform, namespace and name, so that the
+// samples are written in a stable order.
+func sortKeys(keys []prometheusKey) []prometheusKey {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.transform != b.transform {
+			return a.transform < b.transform
+		}
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		return a.name < b.name
+	})
+	return keys
+}
+
+// labels formats the key as the labels of a Prometheus sample.
+func (k prometheusKey) labels() string {
+	return fmt.Sprintf("{ptransform=\"%v\",namespace=\"%v\",name=\"%v\"}",
+		escapeLabel(k.transform), escapeLabel(k.namespace), escapeLabel(k.name))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// setupPrometheus starts serving the metrics of the harness at /metrics on
+// the address of the prometheus_address pipeline option, if set. The returned
+// function stops serving them once the harness exits.
+func setupPrometheus(ctx context.Context, ctrl *control) (*prometheusExporter, func(), error) {
+	addr := prometheusAddress()
+	if addr == "" {
+		return nil, func() {}, nil
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, func() {}, errors.Wrapf(err, "failed to listen on %v", addr)
+	}
+	e := newPrometheusExporter(ctrl)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(lis)
+	log.Infof(ctx, "serving Prometheus metrics at http://%v/metrics", lis.Addr())
+	return e, func() { srv.Close() }, nil
+}
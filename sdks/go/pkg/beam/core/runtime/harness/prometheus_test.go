@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+)
+
+func TestPrometheusExporter(t *testing.T) {
+	e := newPrometheusExporter(&control{active: make(map[instructionID]*exec.Plan)})
+	for i, err := range []error{nil, errors.New("bundle failed")} {
+		ctx := metrics.SetBundleID(context.Background(), fmt.Sprintf("b%d", i))
+		ctx = metrics.SetPTransformID(ctx, "e1")
+		metrics.NewCounter("ns", "count").Inc(ctx, 2)
+		metrics.NewDistribution("ns", "size").Update(ctx, int64(10*(i+1)))
+		metrics.NewGauge("ns", `"quoted"`).Set(ctx, int64(i))
+		e.record(metrics.GetStore(ctx), err)
+	}
+
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading the response failed: %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE beam_worker_active_bundles gauge\nbeam_worker_active_bundles 0\n",
+		"beam_worker_bundles_total{status=\"succeeded\"} 1\n",
+		"beam_worker_bundles_total{status=\"failed\"} 1\n",
+		"# TYPE beam_user_counter counter\nbeam_user_counter{ptransform=\"e1\",namespace=\"ns\",name=\"count\"} 4\n",
+		"beam_user_distribution_count{ptransform=\"e1\",namespace=\"ns\",name=\"size\"} 2\n",
+		"beam_user_distribution_sum{ptransform=\"e1\",namespace=\"ns\",name=\"size\"} 30\n",
+		"beam_user_distribution_min{ptransform=\"e1\",namespace=\"ns\",name=\"size\"} 10\n",
+		"beam_user_distribution_max{ptransform=\"e1\",namespace=\"ns\",name=\"size\"} 20\n",
+		"beam_user_gauge{ptransform=\"e1\",namespace=\"ns\",name=\"\\\"quoted\\\"\"} 1\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics = %s, want %q", body, want)
+		}
+	}
+}
+
+func TestPrometheusExporter_Nil(t *testing.T) {
+	var e *prometheusExporter
+	e.record(nil, nil) // Must not panic.
+}
@@ -0,0 +1,193 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// reconnectBackoff is the delay before the first reconnect after a stream to
+// the runner fails, which doubles for each further consecutive failure up to
+// maxReconnectBackoff. It is a variable so tests can shorten it.
+var reconnectBackoff = time.Second
+
+const (
+	maxReconnectBackoff = 30 * time.Second
+	// controlReconnectTimeout is how long the harness tries to reconnect to
+	// the control service after the control stream last worked, before it
+	// gives up and fails.
+	controlReconnectTimeout = 5 * time.Minute
+)
+
+func nextBackoff(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > maxReconnectBackoff {
+		backoff = maxReconnectBackoff
+	}
+	return backoff
+}
+
+// isTransient returns whether a stream failed with an error that may be
+// fixed by reconnecting, such as a dropped connection.
+func isTransient(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
+
+// isStreamFailure returns whether sending on a stream failed because the
+// stream itself failed, in which case the cause is returned by Recv, which
+// reconnects the stream if the cause is transient.
+func isStreamFailure(err error) bool {
+	return err == io.EOF || isTransient(err)
+}
+
+// controlStream is the control stream of a harness, which is replaced by a
+// new stream when it fails with a transient error, so that the bundles being
+// processed survive network errors. Responses that fail to be sent are sent
+// again on the new stream.
+//
+// gRPC requires a single goroutine to receive, and a single goroutine to
+// send, on a stream. Recv and reconnect are called by the receiving
+// goroutine, and Send and CloseSend by the sending one.
+type controlStream struct {
+	ctx    context.Context
+	client fnpb.BeamFnControlClient
+
+	mu   sync.Mutex
+	stub fnpb.BeamFnControl_ControlClient // protected by mu
+	// ready is closed and replaced once the stream is reconnected.
+	ready chan struct{} // protected by mu
+
+	// stopped is closed once the harness stops, so responses are no longer
+	// sent again.
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	// failedAt is the time the stream first failed since it last worked,
+	// and backoff the delay before the next reconnect. Both are only used
+	// by the receiving goroutine.
+	failedAt time.Time
+	backoff  time.Duration
+}
+
+func newControlStream(ctx context.Context, client fnpb.BeamFnControlClient) (*controlStream, error) {
+	stub, err := client.Control(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &controlStream{
+		ctx:     ctx,
+		client:  client,
+		stub:    stub,
+		ready:   make(chan struct{}),
+		stopped: make(chan struct{}),
+		backoff: reconnectBackoff,
+	}, nil
+}
+
+func (s *controlStream) current() (fnpb.BeamFnControl_ControlClient, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stub, s.ready
+}
+
+// Recv receives the next instruction request from the current stream.
+func (s *controlStream) Recv() (*fnpb.InstructionRequest, error) {
+	stub, _ := s.current()
+	req, err := stub.Recv()
+	if err == nil {
+		s.failedAt = time.Time{}
+		s.backoff = reconnectBackoff
+	}
+	return req, err
+}
+
+// reconnect replaces the failed stream by a new one, retrying with backoff
+// for up to controlReconnectTimeout since the stream last worked. Returns
+// an error if the harness can't reconnect.
+func (s *controlStream) reconnect(ctx context.Context, cause error) error {
+	if s.failedAt.IsZero() {
+		s.failedAt = time.Now()
+	}
+	for {
+		if time.Since(s.failedAt) > controlReconnectTimeout {
+			return errors.Wrapf(cause, "failed to reconnect to control service within %v", controlReconnectTimeout)
+		}
+		log.Warnf(ctx, "control stream failed, reconnecting in %v: %v", s.backoff, cause)
+		select {
+		case <-time.After(s.backoff):
+		case <-s.ctx.Done():
+			return cause
+		}
+		s.backoff = nextBackoff(s.backoff)
+
+		stub, err := s.client.Control(s.ctx)
+		if err != nil {
+			cause = err
+			continue
+		}
+		s.mu.Lock()
+		s.stub = stub
+		close(s.ready)
+		s.ready = make(chan struct{})
+		s.mu.Unlock()
+
+		log.Infof(ctx, "reconnected to control service")
+		return nil
+	}
+}
+
+// Send sends the response on the current stream. If the stream fails, the
+// response is sent again once the stream is reconnected, until the harness
+// stops. Other errors, such as a response exceeding the message size limit,
+// are returned right away.
+func (s *controlStream) Send(resp *fnpb.InstructionResponse) error {
+	for {
+		stub, ready := s.current()
+		err := stub.Send(resp)
+		if err == nil {
+			return nil
+		}
+		if !isStreamFailure(err) {
+			return err
+		}
+		select {
+		case <-ready:
+			// Reconnected, so try again.
+		case <-s.stopped:
+			return err
+		}
+	}
+}
+
+// CloseSend lets the runner know that there are no more responses.
+func (s *controlStream) CloseSend() error {
+	stub, _ := s.current()
+	return stub.CloseSend()
+}
+
+// stop stops responses that fail to be sent from waiting for the stream to
+// be reconnected.
+func (s *controlStream) stop() {
+	s.stopOnce.Do(func() { close(s.stopped) })
+}
@@ -0,0 +1,218 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyControl is a control service whose streams fail with a transient
+// error after the first instruction, except for the last stream, which ends
+// normally.
+type flakyControl struct {
+	fnpb.UnimplementedBeamFnControlServer
+	fnpb.UnimplementedBeamFnLoggingServer
+
+	streams int
+
+	mu        sync.Mutex
+	opened    int
+	responses []string
+}
+
+func (c *flakyControl) Control(stream fnpb.BeamFnControl_ControlServer) error {
+	c.mu.Lock()
+	c.opened++
+	n := c.opened
+	c.mu.Unlock()
+
+	id := string(rune('a' + n - 1))
+	if err := stream.Send(&fnpb.InstructionRequest{
+		InstructionId: id,
+		Request:       &fnpb.InstructionRequest_Register{Register: &fnpb.RegisterRequest{}},
+	}); err != nil {
+		return err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.responses = append(c.responses, resp.GetInstructionId())
+	c.mu.Unlock()
+
+	if n < c.streams {
+		return status.Error(codes.Unavailable, "connection dropped")
+	}
+	return nil
+}
+
+func (c *flakyControl) Logging(stream fnpb.BeamFnLogging_LoggingServer) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return nil
+		}
+	}
+}
+
+func TestMain_reconnect(t *testing.T) {
+	defer func(orig time.Duration) { reconnectBackoff = orig }(reconnectBackoff)
+	reconnectBackoff = time.Millisecond
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	defer srv.Stop()
+	ctrl := &flakyControl{streams: 3}
+	fnpb.RegisterBeamFnControlServer(srv, ctrl)
+	fnpb.RegisterBeamFnLoggingServer(srv, ctrl)
+	go srv.Serve(lis)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	endpoint := lis.Addr().String()
+	if err := Main(ctx, endpoint, endpoint); err != nil {
+		t.Fatalf("Main() failed: %v, want it to reconnect after transient failures", err)
+	}
+
+	ctrl.mu.Lock()
+	defer ctrl.mu.Unlock()
+	if got, want := ctrl.opened, 3; got != want {
+		t.Errorf("control streams opened = %v, want %v", got, want)
+	}
+	if got, want := len(ctrl.responses), 3; got != want {
+		t.Errorf("responses = %v, want one for each of the %v streams", ctrl.responses, want)
+	}
+}
+
+func TestMain_permanentFailure(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	defer srv.Stop()
+	ctrl := &permanentlyFailingControl{}
+	fnpb.RegisterBeamFnControlServer(srv, ctrl)
+	fnpb.RegisterBeamFnLoggingServer(srv, &flakyControl{})
+	go srv.Serve(lis)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	endpoint := lis.Addr().String()
+	if err := Main(ctx, endpoint, endpoint); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("Main() = %v, want it to fail without reconnecting", err)
+	}
+	if got := atomic.LoadInt32(&ctrl.opened); got != 1 {
+		t.Errorf("control streams opened = %v, want 1", got)
+	}
+}
+
+type permanentlyFailingControl struct {
+	fnpb.UnimplementedBeamFnControlServer
+	opened int32
+}
+
+func (c *permanentlyFailingControl) Control(stream fnpb.BeamFnControl_ControlServer) error {
+	atomic.AddInt32(&c.opened, 1)
+	return status.Error(codes.PermissionDenied, "not allowed")
+}
+
+// sendFailingStub is a control stream whose sends fail with err.
+type sendFailingStub struct {
+	fnpb.BeamFnControl_ControlClient
+	err error
+
+	mu    sync.Mutex
+	sends int
+}
+
+func (s *sendFailingStub) Send(*fnpb.InstructionResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sends++
+	return s.err
+}
+
+func TestControlStream_Send(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		wait bool
+	}{
+		{"ResourceExhausted", status.Error(codes.ResourceExhausted, "response too large"), false},
+		{"InvalidArgument", status.Error(codes.InvalidArgument, "bad response"), false},
+		{"EOF", io.EOF, true},
+		{"Unavailable", status.Error(codes.Unavailable, "connection dropped"), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := &sendFailingStub{err: test.err}
+			s := &controlStream{
+				stub:    stub,
+				ready:   make(chan struct{}),
+				stopped: make(chan struct{}),
+			}
+			done := make(chan error, 1)
+			go func() { done <- s.Send(&fnpb.InstructionResponse{InstructionId: "a"}) }()
+
+			if test.wait {
+				select {
+				case err := <-done:
+					t.Fatalf("Send() = %v, want it to wait for the stream to be reconnected", err)
+				case <-time.After(50 * time.Millisecond):
+				}
+				s.stop()
+			}
+			select {
+			case err := <-done:
+				if err != test.err {
+					t.Errorf("Send() = %v, want %v", err, test.err)
+				}
+			case <-time.After(time.Minute):
+				t.Fatalf("Send() blocked, want it to return %v", test.err)
+			}
+			stub.mu.Lock()
+			defer stub.mu.Unlock()
+			if stub.sends != 1 {
+				t.Errorf("sends = %v, want 1", stub.sends)
+			}
+		})
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	if got, want := nextBackoff(time.Second), 2*time.Second; got != want {
+		t.Errorf("nextBackoff(1s) = %v, want %v", got, want)
+	}
+	if got, want := nextBackoff(maxReconnectBackoff), maxReconnectBackoff; got != want {
+		t.Errorf("nextBackoff(%v) = %v, want %v", maxReconnectBackoff, got, want)
+	}
+}
@@ -0,0 +1,103 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"os"
+	"time"
+
+	beamrt "github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+const gracefulTerminationKey contextKey = "beam:graceful_termination"
+
+// ErrTerminated is returned by Main when the harness exits after draining its
+// bundles, because the worker was sent SIGTERM.
+var ErrTerminated = errors.New("worker harness terminated")
+
+// WithGracefulTermination returns a context for Main, whose harness drains
+// its bundles when the worker is sent SIGTERM, for up to the
+// worker_shutdown_grace_period pipeline option, and then returns
+// ErrTerminated. It is meant for harnesses owning their process, such as
+// those started by the container boot code, so that downsizing the workers
+// doesn't fail their bundles.
+func WithGracefulTermination(ctx context.Context) context.Context {
+	return context.WithValue(ctx, gracefulTerminationKey, true)
+}
+
+// shutdownGracePeriod returns the worker_shutdown_grace_period pipeline
+// option, if graceful termination is enabled for the harness.
+func shutdownGracePeriod(ctx context.Context) time.Duration {
+	if enabled, _ := ctx.Value(gracefulTerminationKey).(bool); !enabled {
+		return 0
+	}
+	opt := beamrt.GlobalOptions.Get("worker_shutdown_grace_period")
+	if opt == "" {
+		return 0
+	}
+	grace, err := time.ParseDuration(opt)
+	if err != nil {
+		log.Errorf(ctx, "graceful termination disabled, invalid worker_shutdown_grace_period %q: %v", opt, err)
+		return 0
+	}
+	return grace
+}
+
+// drainer drains the harness once the worker is terminated. It stops the
+// control from accepting bundles, and waits for the pending bundles to
+// finish and their responses to be sent, before ending the control stream.
+type drainer struct {
+	grace time.Duration
+	ctrl  *control
+	// closeResponses closes the responses to the control service, once the
+	// pending ones are sent.
+	closeResponses func()
+	// sent is closed once the responses are sent.
+	sent <-chan struct{}
+	// cancel ends the control stream, so Main returns.
+	cancel func()
+}
+
+// run drains the harness once a signal is received.
+func (d *drainer) run(ctx context.Context, sig <-chan os.Signal) {
+	select {
+	case <-sig:
+		d.drain(ctx)
+	case <-ctx.Done():
+	}
+}
+
+func (d *drainer) drain(ctx context.Context) {
+	log.Infof(ctx, "worker terminated, draining bundles for up to %v", d.grace)
+	deadline := time.NewTimer(d.grace)
+	defer deadline.Stop()
+
+	select {
+	case <-d.ctrl.drain():
+		d.closeResponses()
+		select {
+		case <-d.sent:
+		case <-deadline.C:
+			log.Warnf(ctx, "responses to the control service not sent within %v", d.grace)
+		}
+	case <-deadline.C:
+		log.Warnf(ctx, "%v bundles not finished within %v, abandoning them", d.ctrl.activeBundles(), d.grace)
+	}
+	d.cancel()
+}
@@ -0,0 +1,91 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+)
+
+func TestDrainer(t *testing.T) {
+	ctx := context.Background()
+	ctrl := &control{inactive: newCircleBuffer()}
+	ctrl.startBundle("inst1")
+
+	closed, sent, canceled := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	d := &drainer{
+		grace:          time.Minute,
+		ctrl:           ctrl,
+		closeResponses: func() { close(closed); close(sent) },
+		sent:           sent,
+		cancel:         func() { close(canceled) },
+	}
+	go d.drain(ctx)
+
+	for !ctrl.isDraining() {
+		time.Sleep(time.Millisecond)
+	}
+	resp := ctrl.handleInstruction(ctx, &fnpb.InstructionRequest{
+		InstructionId: "inst2",
+		Request: &fnpb.InstructionRequest_ProcessBundle{
+			ProcessBundle: &fnpb.ProcessBundleRequest{ProcessBundleDescriptorId: "desc"},
+		},
+	})
+	if !strings.Contains(resp.GetError(), "rejected") {
+		t.Errorf("handleInstruction(ProcessBundle) while draining = %v, want rejected", resp)
+	}
+	select {
+	case <-closed:
+		t.Fatal("responses closed with a pending bundle")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	ctrl.finishBundle()
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("control stream not canceled once drained")
+	}
+	select {
+	case <-closed:
+	default:
+		t.Error("control stream canceled before closing the responses")
+	}
+}
+
+func TestDrainer_gracePeriod(t *testing.T) {
+	ctrl := &control{inactive: newCircleBuffer()}
+	ctrl.startBundle("inst1")
+
+	canceled := make(chan struct{})
+	d := &drainer{
+		grace:          10 * time.Millisecond,
+		ctrl:           ctrl,
+		closeResponses: func() { t.Error("responses closed with a pending bundle") },
+		cancel:         func() { close(canceled) },
+	}
+	go d.drain(context.Background())
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("control stream not canceled after the grace period")
+	}
+}
@@ -0,0 +1,191 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+)
+
+func init() {
+	graphx.RegisterCapability(graphx.URNStateCaching)
+}
+
+// stateCacheSize is the maximum number of bytes of state cached across
+// bundles by a harness.
+var stateCacheSize int64 = 100 << 20
+
+// stateCacheKey identifies the data of a side input read in a window, under
+// the cache token of the runner.
+type stateCacheKey struct {
+	token                              string
+	transformID, sideInputID, key, win string
+}
+
+type stateCacheEntry struct {
+	key  stateCacheKey
+	data []byte
+}
+
+// stateCache caches the state read by bundles for later bundles, with the
+// least recently used data evicted first. Runners issue cache tokens with
+// each bundle and issue new tokens when the state may have changed, so data
+// is only reused while the runner vouches for it. Thread-safe.
+//
+// The Go SDK reads side inputs from the state API, but has no user state,
+// so only side input tokens are used.
+type stateCache struct {
+	capacity int64
+
+	mu      sync.Mutex
+	size    int64
+	entries map[stateCacheKey]*list.Element
+	lru     *list.List
+
+	hits, misses, evictions int64
+}
+
+func newStateCache(capacity int64) *stateCache {
+	return &stateCache{
+		capacity: capacity,
+		entries:  make(map[stateCacheKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (c *stateCache) get(key stateCacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.lru.MoveToFront(e)
+	return e.Value.(*stateCacheEntry).data, true
+}
+
+func (c *stateCache) put(key stateCacheKey, data []byte) {
+	if int64(len(data)) > c.capacity {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.size -= int64(len(e.Value.(*stateCacheEntry).data))
+		c.lru.Remove(e)
+	}
+	c.entries[key] = c.lru.PushFront(&stateCacheEntry{key: key, data: data})
+	c.size += int64(len(data))
+	for c.size > c.capacity {
+		e := c.lru.Back()
+		entry := e.Value.(*stateCacheEntry)
+		c.lru.Remove(e)
+		delete(c.entries, entry.key)
+		c.size -= int64(len(entry.data))
+		c.evictions++
+	}
+}
+
+// String returns the statistics of the cache, for status reports.
+func (c *stateCache) String() string {
+	if c == nil {
+		return "state cache: disabled"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("state cache: %d entries, %d of %d bytes, %d hits, %d misses, %d evictions",
+		len(c.entries), c.size, c.capacity, c.hits, c.misses, c.evictions)
+}
+
+type sideInputID struct {
+	transformID, sideInputID string
+}
+
+// bundleStateCache is the state cache with the cache tokens of a bundle.
+type bundleStateCache struct {
+	cache  *stateCache
+	tokens map[sideInputID]string
+}
+
+// forBundle returns the cache for a bundle with the given cache tokens, or
+// nil if no state of the bundle can be cached.
+func (c *stateCache) forBundle(tokens []*fnpb.ProcessBundleRequest_CacheToken) *bundleStateCache {
+	if c == nil {
+		return nil
+	}
+	m := make(map[sideInputID]string)
+	for _, t := range tokens {
+		if si := t.GetSideInput(); si != nil {
+			m[sideInputID{si.GetTransformId(), si.GetSideInputId()}] = string(t.GetToken())
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return &bundleStateCache{cache: c, tokens: m}
+}
+
+// sideInputKey returns the cache key of a side input read, if the side
+// input has a cache token.
+func (b *bundleStateCache) sideInputKey(transformID, sideInput string, key, w []byte) (stateCacheKey, bool) {
+	if b == nil {
+		return stateCacheKey{}, false
+	}
+	token, ok := b.tokens[sideInputID{transformID, sideInput}]
+	if !ok {
+		return stateCacheKey{}, false
+	}
+	return stateCacheKey{token: token, transformID: transformID, sideInputID: sideInput, key: string(key), win: string(w)}, true
+}
+
+// cachingReader caches the data it reads once it has read all of it.
+type cachingReader struct {
+	r     io.ReadCloser
+	cache *stateCache
+	key   stateCacheKey
+
+	buf  []byte
+	skip bool // data too large to cache
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if !r.skip {
+		if int64(len(r.buf)+n) > r.cache.capacity {
+			r.buf, r.skip = nil, true
+		} else {
+			r.buf = append(r.buf, p[:n]...)
+		}
+	}
+	if err == io.EOF && !r.skip {
+		r.cache.put(r.key, r.buf)
+		r.skip = true // only cache once
+	}
+	return n, err
+}
+
+func (r *cachingReader) Close() error {
+	return r.r.Close()
+}
@@ -0,0 +1,99 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+)
+
+func TestStateCache_Eviction(t *testing.T) {
+	c := newStateCache(10)
+	a, b, d := stateCacheKey{key: "a"}, stateCacheKey{key: "b"}, stateCacheKey{key: "d"}
+	c.put(a, []byte("aaaa"))
+	c.put(b, []byte("bbbb"))
+	c.get(a) // b is now the least recently used.
+	c.put(d, []byte("dddd"))
+
+	if _, ok := c.get(b); ok {
+		t.Error("least recently used entry b cached, want evicted")
+	}
+	for _, k := range []stateCacheKey{a, d} {
+		if _, ok := c.get(k); !ok {
+			t.Errorf("entry %v evicted, want cached", k.key)
+		}
+	}
+	c.put(stateCacheKey{key: "large"}, make([]byte, 11))
+	if _, ok := c.get(stateCacheKey{key: "large"}); ok {
+		t.Error("entry larger than the cache cached, want skipped")
+	}
+	if got, want := c.size, int64(8); got != want {
+		t.Errorf("cache size = %v, want %v", got, want)
+	}
+}
+
+func TestStateCache_ForBundle(t *testing.T) {
+	c := newStateCache(100)
+	if b := c.forBundle(nil); b != nil {
+		t.Errorf("forBundle(no tokens) = %v, want nil", b)
+	}
+	tokens := []*fnpb.ProcessBundleRequest_CacheToken{
+		{
+			Type: &fnpb.ProcessBundleRequest_CacheToken_SideInput_{
+				SideInput: &fnpb.ProcessBundleRequest_CacheToken_SideInput{TransformId: "t", SideInputId: "s"},
+			},
+			Token: []byte("token1"),
+		},
+	}
+	b := c.forBundle(tokens)
+	if _, ok := b.sideInputKey("t", "other", nil, nil); ok {
+		t.Error("side input without token has cache key, want none")
+	}
+	key, ok := b.sideInputKey("t", "s", []byte("k"), []byte("w"))
+	if !ok {
+		t.Fatal("side input with token has no cache key")
+	}
+
+	// Data is cached once it has been read completely, and read from the
+	// cache by bundles with the same token.
+	r := &cachingReader{r: ioutil.NopCloser(bytes.NewReader([]byte("data"))), cache: c, key: key}
+	if _, ok := c.get(key); ok {
+		t.Fatal("data cached before being read")
+	}
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	s := &ScopedStateReader{instID: "inst", cache: c.forBundle(tokens)}
+	sr, err := s.OpenSideInput(context.Background(), exec.StreamID{PtransformID: "t"}, "s", []byte("k"), []byte("w"))
+	if err != nil {
+		t.Fatalf("OpenSideInput failed: %v", err)
+	}
+	if data, err := ioutil.ReadAll(sr); err != nil || string(data) != "data" {
+		t.Errorf("cached side input = %q, %v, want \"data\"", data, err)
+	}
+
+	// A new token means the data may have changed.
+	tokens[0].Token = []byte("token2")
+	key, _ = c.forBundle(tokens).sideInputKey("t", "s", []byte("k"), []byte("w"))
+	if _, ok := c.get(key); ok {
+		t.Error("data cached for new token, want miss")
+	}
+}
@@ -16,14 +16,17 @@
 package harness
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/tracex"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
@@ -35,6 +38,7 @@ import (
 type ScopedStateReader struct {
 	mgr    *StateChannelManager
 	instID instructionID
+	cache  *bundleStateCache // nil if the bundle state isn't cached
 
 	opened []io.Closer // track open readers to force close all
 	closed bool
@@ -47,10 +51,27 @@ func NewScopedStateReader(mgr *StateChannelManager, instID instructionID) *Scope
 }
 
 // OpenSideInput opens a byte stream for reading iterable side input.
+// Side inputs with cache tokens are read from the state cache, if cached.
 func (s *ScopedStateReader) OpenSideInput(ctx context.Context, id exec.StreamID, sideInputID string, key, w []byte) (io.ReadCloser, error) {
-	return s.openReader(ctx, id, func(ch *StateChannel) *stateKeyReader {
+	ck, cached := s.cache.sideInputKey(id.PtransformID, sideInputID, key, w)
+	if cached {
+		if data, ok := s.cache.cache.get(ck); ok {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil, errors.Errorf("instruction %v no longer processing", s.instID)
+			}
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+	r, err := s.openReader(ctx, id, func(ch *StateChannel) *stateKeyReader {
 		return newSideInputReader(ch, id, sideInputID, s.instID, key, w)
 	})
+	if err != nil || !cached {
+		return r, err
+	}
+	return &cachingReader{r: r, cache: s.cache.cache, key: ck}, nil
 }
 
 // OpenIterable opens a byte stream for reading unwindowed iterables from the runner.
@@ -72,8 +93,14 @@ func (s *ScopedStateReader) openReader(ctx context.Context, id exec.StreamID, re
 		return nil, errors.Errorf("instruction %v no longer processing", s.instID)
 	}
 	ret := readerFn(ch)
+	_, ret.span = tracex.Start(ctx, "StateRead", tracex.String("beam.ptransform_id", id.PtransformID))
 	s.opened = append(s.opened, ret)
 	s.mu.Unlock()
+
+	// Request the first segment right away, so that requests of readers
+	// opened together are pipelined on the channel. If the request fails,
+	// it's retried on the first read.
+	ret.request()
 	return ret, nil
 }
 
@@ -109,10 +136,15 @@ type stateKeyReader struct {
 	token []byte
 	buf   []byte
 	eof   bool
+	next  *stateResponseFuture // request of the next segment, if sent
 
 	ch     *StateChannel
 	closed bool
 	mu     sync.Mutex
+
+	// span traces the reader until it's closed, with its number of requests.
+	span     *tracex.Span
+	requests int64 // accessed atomically
 }
 
 func newSideInputReader(ch *StateChannel, id exec.StreamID, sideInputID string, instID instructionID, k, w []byte) *stateKeyReader {
@@ -154,28 +186,24 @@ func (r *stateKeyReader) Read(buf []byte) (int, error) {
 			return 0, io.EOF
 		}
 
-		// Buffer empty. Get next segment.
+		// Buffer empty. Get next segment, which may have been requested
+		// already.
 
 		r.mu.Lock()
-		if r.closed {
-			r.mu.Unlock()
+		closed := r.closed
+		r.mu.Unlock()
+		if closed {
 			return 0, errors.New("side input closed")
 		}
-		localChannel := r.ch
-		r.mu.Unlock()
-
-		req := &fnpb.StateRequest{
-			// Id: set by StateChannel
-			InstructionId: string(r.instID),
-			StateKey:      r.key,
-			Request: &fnpb.StateRequest_Get{
-				Get: &fnpb.StateGetRequest{
-					ContinuationToken: r.token,
-				},
-			},
+		if r.next == nil {
+			if err := r.request(); err != nil {
+				return 0, err
+			}
 		}
-		resp, err := localChannel.Send(req)
+		resp, err := r.next.wait()
+		r.next = nil
 		if err != nil {
+			r.span.End(err)
 			return 0, err
 		}
 		get := resp.GetGet()
@@ -188,6 +216,10 @@ func (r *stateKeyReader) Read(buf []byte) (int, error) {
 
 		if r.token == nil {
 			r.eof = true // no token == this is the last segment.
+		} else {
+			// Request the following segment while this one is consumed. If
+			// the request fails, it's retried when the segment is needed.
+			r.request()
 		}
 	}
 
@@ -207,11 +239,43 @@ func (r *stateKeyReader) Read(buf []byte) (int, error) {
 	return n, nil
 }
 
+// request sends the request for the next segment, without waiting for the
+// response.
+func (r *stateKeyReader) request() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return errors.New("side input closed")
+	}
+	localChannel := r.ch
+	r.mu.Unlock()
+
+	req := &fnpb.StateRequest{
+		// Id: set by StateChannel
+		InstructionId: string(r.instID),
+		StateKey:      r.key,
+		Request: &fnpb.StateRequest_Get{
+			Get: &fnpb.StateGetRequest{
+				ContinuationToken: r.token,
+			},
+		},
+	}
+	f, err := localChannel.sendAsync(req)
+	if err != nil {
+		return err
+	}
+	r.next = f
+	atomic.AddInt64(&r.requests, 1)
+	return nil
+}
+
 func (r *stateKeyReader) Close() error {
 	r.mu.Lock()
 	r.closed = true
 	r.ch = nil // StateChannels might be re-used if they're ok, so don't close them here.
 	r.mu.Unlock()
+	r.span.SetAttributes(tracex.Int64("beam.state_requests", atomic.LoadInt64(&r.requests)))
+	r.span.End(nil)
 	return nil
 }
 
@@ -394,6 +458,23 @@ func (c *StateChannel) write(ctx context.Context) {
 
 // Send sends a state request and returns the response.
 func (c *StateChannel) Send(req *fnpb.StateRequest) (*fnpb.StateResponse, error) {
+	f, err := c.sendAsync(req)
+	if err != nil {
+		return nil, err
+	}
+	return f.wait()
+}
+
+// stateResponseFuture is the pending response of a sent state request.
+type stateResponseFuture struct {
+	c  *StateChannel
+	id string
+	ch chan *fnpb.StateResponse
+}
+
+// sendAsync sends a state request without waiting for the response, so
+// several requests can be in flight on the channel at once.
+func (c *StateChannel) sendAsync(req *fnpb.StateRequest) (*stateResponseFuture, error) {
 	id := fmt.Sprintf("r%v", atomic.AddInt32(&c.nextRequestNo, 1))
 	req.Id = id
 
@@ -407,14 +488,18 @@ func (c *StateChannel) Send(req *fnpb.StateRequest) (*fnpb.StateResponse, error)
 	c.mu.Unlock()
 
 	c.requests <- req
+	return &stateResponseFuture{c: c, id: id, ch: ch}, nil
+}
 
+// wait waits for the response of the request.
+func (f *stateResponseFuture) wait() (*fnpb.StateResponse, error) {
 	var resp *fnpb.StateResponse
 	select {
-	case resp = <-ch:
-	case <-c.DoneCh:
-		c.mu.Lock()
-		defer c.mu.Unlock()
-		return nil, errors.Wrapf(c.closedErr, "StateChannel[%v].Send(%v): context canceled", c.id, id)
+	case resp = <-f.ch:
+	case <-f.c.DoneCh:
+		f.c.mu.Lock()
+		defer f.c.mu.Unlock()
+		return nil, errors.Wrapf(f.c.closedErr, "StateChannel[%v].Send(%v): context canceled", f.c.id, f.id)
 	}
 	if resp.Error != "" {
 		return nil, errors.New(resp.Error)
@@ -337,7 +337,10 @@ func TestStateKeyReader(t *testing.T) {
 			go func() {
 				if test.noGet {
 					req := <-ch.requests
-					ch.responses[req.Id] <- &fnpb.StateResponse{
+					ch.mu.Lock()
+					resp := ch.responses[req.Id]
+					ch.mu.Unlock()
+					resp <- &fnpb.StateResponse{
 						Id: req.Id,
 					}
 					return
@@ -354,7 +357,11 @@ func TestStateKeyReader(t *testing.T) {
 					}
 					req := <-ch.requests
 
-					ch.responses[req.Id] <- &fnpb.StateResponse{
+					// Requests are sent ahead, so responses are locked.
+					ch.mu.Lock()
+					resp := ch.responses[req.Id]
+					ch.mu.Unlock()
+					resp <- &fnpb.StateResponse{
 						Id: req.Id,
 						Response: &fnpb.StateResponse_Get{
 							Get: &fnpb.StateGetResponse{
@@ -428,3 +435,55 @@ func contains(got, want error) bool {
 	}
 	return strings.Contains(got.Error(), want.Error())
 }
+
+// TestStateKeyReader_Prefetch validates that the next segment is requested
+// before it is read.
+func TestStateKeyReader_Prefetch(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	ch := &StateChannel{
+		id:        "test",
+		requests:  make(chan *fnpb.StateRequest, 2),
+		responses: make(map[string]chan<- *fnpb.StateResponse),
+		cancelFn:  cancelFn,
+		DoneCh:    ctx.Done(),
+	}
+	respond := func(req *fnpb.StateRequest, data string, token []byte) {
+		ch.mu.Lock()
+		resp := ch.responses[req.Id]
+		ch.mu.Unlock()
+		resp <- &fnpb.StateResponse{
+			Id: req.Id,
+			Response: &fnpb.StateResponse_Get{
+				Get: &fnpb.StateGetResponse{ContinuationToken: token, Data: []byte(data)},
+			},
+		}
+	}
+
+	r := &stateKeyReader{ch: ch}
+	if err := r.request(); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	respond(<-ch.requests, "first", []byte("1"))
+
+	buf := make([]byte, 5)
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "first" {
+		t.Fatalf("Read() = %q, %v, want \"first\"", buf[:n], err)
+	}
+	// The second segment was requested by the first read.
+	select {
+	case req := <-ch.requests:
+		if got, want := string(req.GetGet().GetContinuationToken()), "1"; got != want {
+			t.Errorf("continuation token = %v, want %v", got, want)
+		}
+		respond(req, "last", nil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("second segment not requested before read")
+	}
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "last" {
+		t.Fatalf("Read() = %q, %v, want \"last\"", buf[:n], err)
+	}
+	if _, err := r.Read(buf); err != io.EOF {
+		t.Errorf("Read() error = %v, want io.EOF", err)
+	}
+}
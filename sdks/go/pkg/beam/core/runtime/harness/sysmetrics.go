@@ -0,0 +1,162 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/metricsx"
+	"github.com/apache/beam/sdks/go/pkg/beam/util/syscallx"
+)
+
+// systemMetricsPeriod is how often the harness samples its system metrics.
+const systemMetricsPeriod = 10 * time.Second
+
+// systemSample is a sample of the resource usage of the worker process.
+// The CPU time, resident memory and GC pauses are zero where the platform
+// doesn't report them.
+type systemSample struct {
+	Time          time.Time
+	CPUTime       time.Duration
+	ResidentBytes uint64
+	HeapBytes     uint64
+	GCCount       uint32
+	GCPause       time.Duration
+}
+
+// sampleSystem returns a sample of the current resource usage of the process.
+func sampleSystem() systemSample {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	s := systemSample{
+		Time:      time.Now(),
+		HeapBytes: m.HeapAlloc,
+		GCCount:   m.NumGC,
+		GCPause:   time.Duration(m.PauseTotalNs),
+	}
+	if cpu, err := syscallx.ProcessCPUTime(); err == nil {
+		s.CPUTime = cpu
+	}
+	if rss, err := syscallx.ResidentMemorySize(); err == nil {
+		s.ResidentBytes = rss
+	}
+	return s
+}
+
+// systemMetrics periodically samples the CPU, memory and GC usage of the
+// worker, so capacity issues are visible to runners through the harness
+// monitoring infos, and to users through the status reports.
+type systemMetrics struct {
+	sample func() systemSample
+
+	mu   sync.Mutex
+	last systemSample
+}
+
+func newSystemMetrics() *systemMetrics {
+	s := &systemMetrics{sample: sampleSystem}
+	s.update()
+	return s
+}
+
+// run samples the system metrics until the context is done.
+func (s *systemMetrics) run(ctx context.Context) {
+	t := time.NewTicker(systemMetricsPeriod)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.update()
+		}
+	}
+}
+
+func (s *systemMetrics) update() {
+	sample := s.sample()
+	s.mu.Lock()
+	s.last = sample
+	s.mu.Unlock()
+}
+
+// latest returns the last sample. A nil systemMetrics samples the process
+// when asked.
+func (s *systemMetrics) latest() systemSample {
+	if s == nil {
+		return sampleSystem()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// monitoringData returns the payloads of the last sample by their short ids,
+// for harness monitoring infos responses.
+func (s *systemMetrics) monitoringData() map[string][]byte {
+	sample := s.latest()
+
+	defaultShortIDCache.mu.Lock()
+	defer defaultShortIDCache.mu.Unlock()
+
+	payloads := make(map[string][]byte)
+	for _, c := range []struct {
+		urn metricsx.Urn
+		v   int64
+	}{
+		{metricsx.UrnWorkerCPUTime, sample.CPUTime.Milliseconds()},
+		{metricsx.UrnWorkerGCCount, int64(sample.GCCount)},
+		{metricsx.UrnWorkerGCPause, sample.GCPause.Milliseconds()},
+	} {
+		payload, err := metricsx.Int64Counter(c.v)
+		if err != nil {
+			panic(err)
+		}
+		payloads[getShortID(metrics.Labels{}, c.urn)] = payload
+	}
+	for _, g := range []struct {
+		urn metricsx.Urn
+		v   uint64
+	}{
+		{metricsx.UrnWorkerResidentMemory, sample.ResidentBytes},
+		{metricsx.UrnWorkerHeap, sample.HeapBytes},
+	} {
+		payload, err := metricsx.Int64Latest(sample.Time, int64(g.v))
+		if err != nil {
+			panic(err)
+		}
+		payloads[getShortID(metrics.Labels{}, g.urn)] = payload
+	}
+	return payloads
+}
+
+// write writes the last sample to a status report.
+func (s *systemMetrics) write(sb *strings.Builder) {
+	sample := s.latest()
+	sb.WriteString("\n========== SYSTEM ==========\n")
+	fmt.Fprintf(sb, "sampled at: %v\n", sample.Time.Format(time.RFC3339))
+	fmt.Fprintf(sb, "process cpu time: %v\n", sample.CPUTime)
+	fmt.Fprintf(sb, "resident memory: %d bytes\n", sample.ResidentBytes)
+	fmt.Fprintf(sb, "heap alloc: %d bytes\n", sample.HeapBytes)
+	fmt.Fprintf(sb, "gc cycles: %d\n", sample.GCCount)
+	fmt.Fprintf(sb, "gc pause total: %v\n", sample.GCPause)
+}
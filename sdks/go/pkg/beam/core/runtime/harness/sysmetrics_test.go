@@ -0,0 +1,94 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/metricsx"
+)
+
+func TestSystemMetrics(t *testing.T) {
+	sample := systemSample{
+		Time:          time.Unix(1000, 0),
+		CPUTime:       2500 * time.Millisecond,
+		ResidentBytes: 64 << 20,
+		HeapBytes:     32 << 20,
+		GCCount:       7,
+		GCPause:       30 * time.Millisecond,
+	}
+	s := &systemMetrics{sample: func() systemSample { return sample }}
+	s.update()
+
+	data := s.monitoringData()
+	infos := make(map[string][]byte)
+	for id, info := range shortIdsToInfos(keys(data)) {
+		if len(info.GetLabels()) != 0 {
+			t.Errorf("labels of %v got %v, want none", info.GetUrn(), info.GetLabels())
+		}
+		infos[info.GetUrn()] = data[id]
+	}
+	for _, c := range []struct {
+		urn  metricsx.Urn
+		want int64
+	}{
+		{metricsx.UrnWorkerCPUTime, 2500},
+		{metricsx.UrnWorkerGCCount, 7},
+		{metricsx.UrnWorkerGCPause, 30},
+	} {
+		got, err := coder.DecodeVarInt(bytes.NewReader(infos[metricsx.UrnToString(c.urn)]))
+		if err != nil || got != c.want {
+			t.Errorf("%v got %v, %v, want %v", metricsx.UrnToString(c.urn), got, err, c.want)
+		}
+	}
+	for _, g := range []struct {
+		urn  metricsx.Urn
+		want int64
+	}{
+		{metricsx.UrnWorkerResidentMemory, 64 << 20},
+		{metricsx.UrnWorkerHeap, 32 << 20},
+	} {
+		r := bytes.NewReader(infos[metricsx.UrnToString(g.urn)])
+		ts, err := coder.DecodeVarInt(r)
+		if err != nil || ts != 1000000 {
+			t.Errorf("%v timestamp got %v, %v, want 1000000", metricsx.UrnToString(g.urn), ts, err)
+		}
+		got, err := coder.DecodeVarInt(r)
+		if err != nil || got != g.want {
+			t.Errorf("%v got %v, %v, want %v", metricsx.UrnToString(g.urn), got, err, g.want)
+		}
+	}
+
+	var sb strings.Builder
+	s.write(&sb)
+	for _, want := range []string{"SYSTEM", "process cpu time: 2.5s", "resident memory: 67108864 bytes", "gc cycles: 7"} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("write() got %q, want it to contain %q", sb.String(), want)
+		}
+	}
+}
+
+func keys(m map[string][]byte) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
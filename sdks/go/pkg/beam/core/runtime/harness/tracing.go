@@ -0,0 +1,53 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"time"
+
+	beamrt "github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/tracex"
+)
+
+// traceFlushTimeout is how long a harness waits for its remaining spans to
+// be exported when it exits.
+const traceFlushTimeout = 5 * time.Second
+
+// setupTracing returns a context for the harness, whose bundles, transforms
+// and data and state operations are traced, if the otlp_endpoint pipeline
+// option is set. The spans are exported to the OTLP/HTTP collector at the
+// endpoint. The returned function exports the remaining spans once the
+// harness exits.
+func setupTracing(ctx context.Context) (context.Context, func()) {
+	endpoint := beamrt.GlobalOptions.Get("otlp_endpoint")
+	if endpoint == "" {
+		return ctx, func() {}
+	}
+	resource := []tracex.Attribute{
+		tracex.String("service.name", "beam-go-worker"),
+		tracex.String("beam.worker_id", workerID(ctx)),
+	}
+	if job := beamrt.GlobalOptions.Get("job_name"); job != "" {
+		resource = append(resource, tracex.String("beam.job_name", job))
+	}
+	e := tracex.NewExporter(ctx, endpoint, resource...)
+	return tracex.WithExporter(ctx, e), func() {
+		ctx, cancel := context.WithTimeout(ctx, traceFlushTimeout)
+		defer cancel()
+		e.Shutdown(ctx)
+	}
+}
@@ -0,0 +1,111 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	beamrt "github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+// maxWatchdogStackDump is the maximum size of the goroutine dump in stuck
+// bundle reports, which are sent as log messages.
+const maxWatchdogStackDump = 1 << 20
+
+// stuckBundleTimeout returns the stuck_bundle_timeout pipeline option, if set.
+func stuckBundleTimeout(ctx context.Context) time.Duration {
+	opt := beamrt.GlobalOptions.Get("stuck_bundle_timeout")
+	if opt == "" {
+		return 0
+	}
+	timeout, err := time.ParseDuration(opt)
+	if err != nil {
+		log.Errorf(ctx, "stuck bundle reports disabled, invalid stuck_bundle_timeout %q: %v", opt, err)
+		return 0
+	}
+	return timeout
+}
+
+// watchdog reports the bundles processing for longer than the timeout, to
+// diagnose hangs in user code or IO. The reports log the transform and the
+// element each stuck bundle is processing, and the stacks of all goroutines.
+// Bundles still stuck are reported again after each timeout.
+type watchdog struct {
+	timeout time.Duration
+	ctrl    *control
+	// reported holds the times the stuck bundles were last reported.
+	reported map[instructionID]time.Time
+}
+
+// run checks for stuck bundles until the context is canceled.
+func (w *watchdog) run(ctx context.Context) {
+	ticker := time.NewTicker(w.timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if report := w.check(now); report != "" {
+				log.Warn(ctx, report)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check returns the report of the bundles stuck at the given time, or the
+// empty string if there are none to report.
+func (w *watchdog) check(now time.Time) string {
+	var lines []string
+	w.ctrl.mu.Lock()
+	for id := range w.reported {
+		if _, ok := w.ctrl.active[id]; !ok {
+			delete(w.reported, id)
+		}
+	}
+	for id, plan := range w.ctrl.active {
+		started, ok := w.ctrl.started[id]
+		if !ok || plan == nil || now.Sub(started) < w.timeout || now.Sub(w.reported[id]) < w.timeout {
+			continue
+		}
+		w.reported[id] = now
+		line := fmt.Sprintf("bundle %v using plan %v has been processing for %v", id, plan.ID(), now.Sub(started).Round(time.Second))
+		if pid, elm, ok := plan.ActiveElement(); ok {
+			line += fmt.Sprintf(", in transform %v on element %v", pid, elm)
+		} else {
+			line += ", not in an element"
+		}
+		lines = append(lines, line+"\n")
+	}
+	w.ctrl.mu.Unlock()
+
+	if len(lines) == 0 {
+		return ""
+	}
+	sort.Strings(lines)
+	var sb strings.Builder
+	sb.WriteString("========== STUCK BUNDLES ==========\n")
+	for _, l := range lines {
+		sb.WriteString(l)
+	}
+	goroutineDump(&sb, maxWatchdogStackDump)
+	return sb.String()
+}
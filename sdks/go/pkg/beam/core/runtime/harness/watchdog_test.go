@@ -0,0 +1,68 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+)
+
+func TestWatchdog_check(t *testing.T) {
+	stuck, err := exec.UnmarshalPlan(validDescriptor(t))
+	if err != nil {
+		t.Fatalf("bad plan: %v", err)
+	}
+	recent, err := exec.UnmarshalPlan(validDescriptor(t))
+	if err != nil {
+		t.Fatalf("bad plan: %v", err)
+	}
+	now := time.Now()
+	ctrl := &control{
+		active:  map[instructionID]*exec.Plan{"stuck": stuck, "recent": recent},
+		started: map[instructionID]time.Time{"stuck": now.Add(-2 * time.Minute), "recent": now},
+	}
+	w := &watchdog{timeout: time.Minute, ctrl: ctrl, reported: make(map[instructionID]time.Time)}
+
+	report := w.check(now)
+	for _, want := range []string{"STUCK BUNDLES", "bundle stuck using plan", "processing for 2m0s", "not in an element", "GOROUTINES", "TestWatchdog_check"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%v", want, report)
+		}
+	}
+	if strings.Contains(report, "bundle recent") {
+		t.Errorf("report = %v, want only the stuck bundle", report)
+	}
+
+	// Stuck bundles are reported again once per timeout.
+	if report := w.check(now.Add(30 * time.Second)); report != "" {
+		t.Errorf("check(+30s) = %v, want no report", report)
+	}
+	if report := w.check(now.Add(time.Minute)); !strings.Contains(report, "bundle stuck") || !strings.Contains(report, "bundle recent") {
+		t.Errorf("check(+1m) = %v, want both bundles reported", report)
+	}
+
+	ctrl.mu.Lock()
+	delete(ctrl.active, "stuck")
+	delete(ctrl.started, "stuck")
+	ctrl.mu.Unlock()
+	w.check(now.Add(time.Minute))
+	if _, ok := w.reported["stuck"]; ok {
+		t.Error("finished bundle still tracked as reported")
+	}
+}
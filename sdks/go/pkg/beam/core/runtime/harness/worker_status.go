@@ -0,0 +1,182 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	"google.golang.org/grpc"
+)
+
+const statusEndpointKey contextKey = "beam:status_endpoint"
+
+func init() {
+	graphx.RegisterCapability(graphx.URNWorkerStatus)
+}
+
+// WithStatusEndpoint returns a context for Main, whose harness reports its
+// status to the worker status service at the given endpoint when the runner
+// asks for it.
+func WithStatusEndpoint(ctx context.Context, endpoint string) context.Context {
+	return context.WithValue(ctx, statusEndpointKey, endpoint)
+}
+
+// maxStackDump is the maximum size of the goroutine dump in status reports.
+const maxStackDump = 16 << 20
+
+// workerStatusHandler responds to the worker status requests of the runner
+// with the status of the harness.
+type workerStatusHandler struct {
+	conn           *grpc.ClientConn
+	ctrl           *control
+	shouldShutdown int32
+	wg             sync.WaitGroup
+}
+
+func newWorkerStatusHandler(ctx context.Context, endpoint string, ctrl *control) (*workerStatusHandler, error) {
+	conn, err := dial(ctx, endpoint, 60*time.Second)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to worker status service at %v", endpoint)
+	}
+	return &workerStatusHandler{conn: conn, ctrl: ctrl}, nil
+}
+
+// start starts responding to the status requests of the runner.
+func (w *workerStatusHandler) start(ctx context.Context) error {
+	stub, err := fnpb.NewBeamFnWorkerStatusClient(w.conn).WorkerStatus(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to worker status service")
+	}
+	w.wg.Add(1)
+	go w.reader(ctx, stub)
+	return nil
+}
+
+func (w *workerStatusHandler) isAlive() bool {
+	return atomic.LoadInt32(&w.shouldShutdown) == 0
+}
+
+func (w *workerStatusHandler) reader(ctx context.Context, stub fnpb.BeamFnWorkerStatus_WorkerStatusClient) {
+	defer w.wg.Done()
+	for w.isAlive() {
+		req, err := stub.Recv()
+		if err != nil {
+			if w.isAlive() {
+				log.Debugf(ctx, "exiting workerStatusHandler.reader(): %v", err)
+			}
+			return
+		}
+		resp := &fnpb.WorkerStatusResponse{Id: req.GetId(), StatusInfo: w.status()}
+		if err := stub.Send(resp); err != nil {
+			log.Errorf(ctx, "workerStatus.Send: failed to respond: %v", err)
+		}
+	}
+}
+
+// stop stops responding to status requests and closes the connection.
+func (w *workerStatusHandler) stop(ctx context.Context) error {
+	atomic.StoreInt32(&w.shouldShutdown, 1)
+	err := w.conn.Close()
+	w.wg.Wait()
+	if err != nil {
+		return errors.Wrap(err, "failed to close worker status connection")
+	}
+	return nil
+}
+
+// status returns the status report of the harness: its active bundles,
+// caches, memory, system usage and goroutines.
+func (w *workerStatusHandler) status() string {
+	var sb strings.Builder
+	w.activeBundles(&sb)
+	w.caches(&sb)
+	memoryUsage(&sb)
+	w.ctrl.system.write(&sb)
+	goroutineDump(&sb, maxStackDump)
+	return sb.String()
+}
+
+func (w *workerStatusHandler) activeBundles(sb *strings.Builder) {
+	sb.WriteString("========== ACTIVE BUNDLES ==========\n")
+	w.ctrl.mu.Lock()
+	var lines []string
+	for id, plan := range w.ctrl.active {
+		if plan == nil {
+			lines = append(lines, fmt.Sprintf("instruction %v: no plan\n", id))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("instruction %v: plan %v\n", id, plan.ID()))
+	}
+	w.ctrl.mu.Unlock()
+
+	sort.Strings(lines)
+	if len(lines) == 0 {
+		sb.WriteString("none\n")
+	}
+	for _, l := range lines {
+		sb.WriteString(l)
+	}
+}
+
+func (w *workerStatusHandler) caches(sb *strings.Builder) {
+	sb.WriteString("\n========== CACHES ==========\n")
+	w.ctrl.mu.Lock()
+	descriptors := len(w.ctrl.descriptors)
+	var plans int
+	for _, ps := range w.ctrl.plans {
+		plans += len(ps)
+	}
+	w.ctrl.mu.Unlock()
+	fmt.Fprintf(sb, "bundle descriptors: %d\nidle plans: %d\n%v\n", descriptors, plans, w.ctrl.cache)
+}
+
+func memoryUsage(sb *strings.Builder) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	sb.WriteString("\n========== MEMORY ==========\n")
+	fmt.Fprintf(sb, "heap alloc: %d bytes\n", m.HeapAlloc)
+	fmt.Fprintf(sb, "heap in use: %d bytes\n", m.HeapInuse)
+	fmt.Fprintf(sb, "heap objects: %d\n", m.HeapObjects)
+	fmt.Fprintf(sb, "total alloc: %d bytes\n", m.TotalAlloc)
+	fmt.Fprintf(sb, "sys: %d bytes\n", m.Sys)
+	fmt.Fprintf(sb, "gc cycles: %d\n", m.NumGC)
+	fmt.Fprintf(sb, "gc pause total: %v\n", time.Duration(m.PauseTotalNs))
+}
+
+// goroutineDump writes the stacks of all goroutines, truncated to max bytes.
+func goroutineDump(sb *strings.Builder, max int) {
+	fmt.Fprintf(sb, "\n========== GOROUTINES (%d) ==========\n", runtime.NumGoroutine())
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= max {
+			sb.Write(buf[:n])
+			return
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,78 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	"google.golang.org/grpc"
+)
+
+type beamFnWorkerStatusServer struct {
+	fnpb.UnimplementedBeamFnWorkerStatusServer
+	response chan string
+}
+
+func (w *beamFnWorkerStatusServer) WorkerStatus(b fnpb.BeamFnWorkerStatus_WorkerStatusServer) error {
+	if err := b.Send(&fnpb.WorkerStatusRequest{Id: "1"}); err != nil {
+		return err
+	}
+	resp, err := b.Recv()
+	if err != nil {
+		return err
+	}
+	w.response <- resp.GetStatusInfo()
+	return nil
+}
+
+func TestSendStatusResponse(t *testing.T) {
+	ctx := context.Background()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	defer server.Stop()
+	srv := &beamFnWorkerStatusServer{response: make(chan string, 1)}
+	fnpb.RegisterBeamFnWorkerStatusServer(server, srv)
+	go server.Serve(lis)
+
+	ctrl := &control{
+		descriptors: map[bundleDescriptorID]*fnpb.ProcessBundleDescriptor{"desc": {}},
+		plans:       make(map[bundleDescriptorID][]*exec.Plan),
+		active:      map[instructionID]*exec.Plan{"inst1": nil},
+	}
+	statusHandler, err := newWorkerStatusHandler(ctx, lis.Addr().String(), ctrl)
+	if err != nil {
+		t.Fatalf("unable to create status handler: %v", err)
+	}
+	if err := statusHandler.start(ctx); err != nil {
+		t.Fatalf("unable to start status handler: %v", err)
+	}
+	defer statusHandler.stop(ctx)
+
+	status := <-srv.response
+	for _, want := range []string{"ACTIVE BUNDLES", "instruction inst1", "bundle descriptors: 1", "MEMORY", "SYSTEM", "GOROUTINES", "TestSendStatusResponse"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status response missing %q:\n%v", want, status)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
@@ -27,21 +28,34 @@ import (
 )
 
 // FromMonitoringInfos extracts metrics from monitored states and
-// groups them into counters, distributions and gauges.
+// groups them into counters, distributions, gauges, string sets, histograms and
+// bounded tries.
 func FromMonitoringInfos(attempted []*pipepb.MonitoringInfo, committed []*pipepb.MonitoringInfo) *metrics.Results {
-	ac, ad, ag := groupByType(attempted)
-	cc, cd, cg := groupByType(committed)
+	ac, ad, ag, as, ah, ab := groupByType(attempted)
+	cc, cd, cg, cs, ch, cb := groupByType(committed)
 
-	return metrics.NewResults(metrics.MergeCounters(ac, cc), metrics.MergeDistributions(ad, cd), metrics.MergeGauges(ag, cg))
+	return metrics.NewResults(metrics.MergeCounters(ac, cc), metrics.MergeDistributions(ad, cd), metrics.MergeGauges(ag, cg)).
+		WithStringSets(metrics.MergeStringSets(as, cs)).
+		WithHistograms(metrics.MergeHistograms(ah, ch)).
+		WithBoundedTries(metrics.MergeBoundedTries(ab, cb))
 }
 
+// groupByType decodes the metrics of the monitored states by type. Metrics
+// reported several times, such as by runners reporting the metrics of each
+// bundle, are aggregated.
 func groupByType(minfos []*pipepb.MonitoringInfo) (
 	map[metrics.StepKey]int64,
 	map[metrics.StepKey]metrics.DistributionValue,
-	map[metrics.StepKey]metrics.GaugeValue) {
+	map[metrics.StepKey]metrics.GaugeValue,
+	map[metrics.StepKey][]string,
+	map[metrics.StepKey]metrics.HistogramValue,
+	map[metrics.StepKey]metrics.BoundedTrieValue) {
 	counters := make(map[metrics.StepKey]int64)
 	distributions := make(map[metrics.StepKey]metrics.DistributionValue)
 	gauges := make(map[metrics.StepKey]metrics.GaugeValue)
+	stringSets := make(map[metrics.StepKey][]string)
+	histograms := make(map[metrics.StepKey]metrics.HistogramValue)
+	boundedTries := make(map[metrics.StepKey]metrics.BoundedTrieValue)
 
 	for _, minfo := range minfos {
 		key, err := extractKey(minfo)
@@ -59,29 +73,62 @@ func groupByType(minfos []*pipepb.MonitoringInfo) (
 				log.Println(err)
 				continue
 			}
-			counters[key] = value
+			counters[key] += value
 		case "beam:metrics:distribution_int64:v1":
 			value, err := extractDistributionValue(r)
 			if err != nil {
 				log.Println(err)
 				continue
 			}
+			if d, ok := distributions[key]; ok {
+				value = mergeDistributions(d, value)
+			}
 			distributions[key] = value
-		case
-			"beam:metrics:latest_int64:v1",
-			"beam:metrics:top_n_int64:v1",
-			"beam:metrics:bottom_n_int64:v1":
+		case "beam:metrics:latest_int64:v1":
 			value, err := extractGaugeValue(r)
 			if err != nil {
 				log.Println(err)
 				continue
 			}
+			if g, ok := gauges[key]; ok && g.Timestamp.After(value.Timestamp) {
+				continue
+			}
 			gauges[key] = value
+		case "beam:metrics:set_string:v1":
+			value, err := extractStringSetValue(r)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if ss, ok := stringSets[key]; ok {
+				value = mergeStringSets(ss, value)
+			}
+			stringSets[key] = value
+		case "beam:metrics:histogram_int64:v1":
+			value, err := extractHistogramValue(r)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if h, ok := histograms[key]; ok {
+				value = mergeHistograms(h, value)
+			}
+			histograms[key] = value
+		case "beam:metrics:bounded_trie:v1":
+			value, err := extractBoundedTrieValue(r)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if b, ok := boundedTries[key]; ok {
+				value = b.Merge(value)
+			}
+			boundedTries[key] = value
 		default:
 			log.Println("unknown metric type")
 		}
 	}
-	return counters, distributions, gauges
+	return counters, distributions, gauges, stringSets, histograms, boundedTries
 }
 
 func extractKey(mi *pipepb.MonitoringInfo) (metrics.StepKey, error) {
@@ -117,6 +164,128 @@ func extractGaugeValue(reader *bytes.Reader) (metrics.GaugeValue, error) {
 	return metrics.GaugeValue{Timestamp: time.Unix(0, values[0]*int64(time.Millisecond)), Value: values[1]}, nil
 }
 
+func extractStringSetValue(reader *bytes.Reader) ([]string, error) {
+	n, err := coder.DecodeInt32(reader)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, n)
+	for i := range values {
+		if values[i], err = coder.DecodeStringUTF8(reader); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+func extractHistogramValue(reader *bytes.Reader) (metrics.HistogramValue, error) {
+	var v metrics.HistogramValue
+	exp, err := coder.DecodeByte(reader)
+	if err != nil {
+		return v, err
+	}
+	v.Buckets.Exponential = exp != 0
+	if v.Buckets.Start, err = coder.DecodeDouble(reader); err != nil {
+		return v, err
+	}
+	if v.Buckets.Width, err = coder.DecodeDouble(reader); err != nil {
+		return v, err
+	}
+	n, err := coder.DecodeVarInt(reader)
+	if err != nil {
+		return v, err
+	}
+	if n < 0 || n > int64(reader.Len()) {
+		return v, fmt.Errorf("invalid number of histogram buckets: %v", n)
+	}
+	v.Buckets.N = int(n)
+	values, err := decodeMany(reader, v.Buckets.N+2)
+	if err != nil {
+		return v, err
+	}
+	v.Underflow, v.Counts, v.Overflow = values[0], values[1:n+1], values[n+1]
+	return v, nil
+}
+
+func extractBoundedTrieValue(reader *bytes.Reader) (metrics.BoundedTrieValue, error) {
+	var v metrics.BoundedTrieValue
+	n, err := coder.DecodeInt32(reader)
+	if err != nil {
+		return v, err
+	}
+	if n < 0 || int(n) > reader.Len() {
+		return v, fmt.Errorf("invalid number of bounded trie paths: %v", n)
+	}
+	v.Paths = make([]metrics.BoundedTriePath, n)
+	for i := range v.Paths {
+		m, err := coder.DecodeInt32(reader)
+		if err != nil {
+			return v, err
+		}
+		if m < 0 || int(m) > reader.Len() {
+			return v, fmt.Errorf("invalid number of bounded trie path segments: %v", m)
+		}
+		p := &v.Paths[i]
+		p.Segments = make([]string, m)
+		for j := range p.Segments {
+			if p.Segments[j], err = coder.DecodeStringUTF8(reader); err != nil {
+				return v, err
+			}
+		}
+		truncated, err := coder.DecodeByte(reader)
+		if err != nil {
+			return v, err
+		}
+		p.Truncated = truncated != 0
+	}
+	// Merging with an empty value bounds and sorts the paths.
+	return v.Merge(metrics.BoundedTrieValue{}), nil
+}
+
+func mergeDistributions(a, b metrics.DistributionValue) metrics.DistributionValue {
+	if b.Min < a.Min {
+		a.Min = b.Min
+	}
+	if b.Max > a.Max {
+		a.Max = b.Max
+	}
+	a.Count += b.Count
+	a.Sum += b.Sum
+	return a
+}
+
+func mergeStringSets(a, b []string) []string {
+	set := make(map[string]bool, len(a)+len(b))
+	var out []string
+	for _, vs := range [][]string{a, b} {
+		for _, v := range vs {
+			if !set[v] {
+				set[v] = true
+				out = append(out, v)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// mergeHistograms adds the counts of the histograms, which only histograms
+// with the same buckets can. Otherwise, the first histogram is kept.
+func mergeHistograms(a, b metrics.HistogramValue) metrics.HistogramValue {
+	if a.Buckets != b.Buckets || len(a.Counts) != len(b.Counts) {
+		return a
+	}
+	counts := make([]int64, len(a.Counts))
+	for i := range counts {
+		counts[i] = a.Counts[i] + b.Counts[i]
+	}
+	a.Counts = counts
+	a.Underflow += b.Underflow
+	a.Overflow += b.Overflow
+	return a
+}
+
 func newLabels(miLabels map[string]string) *metrics.Labels {
 	labels := metrics.UserLabels(miLabels["PTRANSFORM"], miLabels["NAMESPACE"], miLabels["NAME"])
 	return &labels
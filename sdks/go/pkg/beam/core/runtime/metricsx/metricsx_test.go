@@ -22,6 +22,7 @@ import (
 	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
 	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestFromMonitoringInfos_Counters(t *testing.T) {
@@ -164,3 +165,224 @@ func TestFromMonitoringInfos_Gauges(t *testing.T) {
 			got[0], want, d)
 	}
 }
+
+func TestFromMonitoringInfos_TopNNotGauges(t *testing.T) {
+	payload, err := Int64Counter(3)
+	if err != nil {
+		t.Fatalf("Failed to encode Int64Counter: %v", err)
+	}
+	mInfo := &pipepb.MonitoringInfo{
+		Urn:  UrnToString(UrnUserTopNInt64),
+		Type: UrnToType(UrnUserTopNInt64),
+		Labels: map[string]string{
+			"PTRANSFORM": "main.customDoFn",
+			"NAMESPACE":  "customDoFn",
+			"NAME":       "customTopN",
+		},
+		Payload: payload,
+	}
+
+	got := FromMonitoringInfos([]*pipepb.MonitoringInfo{mInfo}, nil).AllMetrics().Gauges()
+	if len(got) != 0 {
+		t.Errorf("FromMonitoringInfos(top_n).Gauges() = %v, want none, since top n values aren't latest values", got)
+	}
+}
+
+func TestFromMonitoringInfos_StringSets(t *testing.T) {
+	want := metrics.StringSetResult{
+		Attempted: []string{"table1", "table2"},
+		Key: metrics.StepKey{
+			Step:      "main.customDoFn",
+			Name:      "customStringSet",
+			Namespace: "customDoFn",
+		}}
+
+	payload, err := StringSet([]string{"table2", "table1"})
+	if err != nil {
+		t.Fatalf("Failed to encode StringSet: %v", err)
+	}
+
+	labels := map[string]string{
+		"PTRANSFORM": "main.customDoFn",
+		"NAMESPACE":  "customDoFn",
+		"NAME":       "customStringSet",
+	}
+
+	mInfo := &pipepb.MonitoringInfo{
+		Urn:     UrnToString(UrnUserSetString),
+		Type:    UrnToType(UrnUserSetString),
+		Labels:  labels,
+		Payload: payload,
+	}
+
+	attempted := []*pipepb.MonitoringInfo{mInfo}
+	committed := []*pipepb.MonitoringInfo{}
+
+	got := FromMonitoringInfos(attempted, committed).AllMetrics().StringSets()
+	size := len(got)
+	if size < 1 {
+		t.Fatalf("Invalid array's size: got: %v, want: %v", size, 1)
+	}
+	if d := cmp.Diff(want, got[0]); d != "" {
+		t.Fatalf("Invalid string set: got: %v, want: %v, diff(-want,+got):\n %v",
+			got[0], want, d)
+	}
+}
+
+func TestFromMonitoringInfos_Histograms(t *testing.T) {
+	value := metrics.HistogramValue{
+		Buckets:   metrics.ExponentialBuckets(1, 2, 3),
+		Counts:    []int64{5, 0, 7},
+		Underflow: 1,
+		Overflow:  2,
+	}
+	want := metrics.HistogramResult{
+		Attempted: value,
+		Key: metrics.StepKey{
+			Step:      "main.customDoFn",
+			Name:      "customHistogram",
+			Namespace: "customDoFn",
+		}}
+
+	payload, err := Int64Histogram(value)
+	if err != nil {
+		t.Fatalf("Failed to encode Int64Histogram: %v", err)
+	}
+
+	labels := map[string]string{
+		"PTRANSFORM": "main.customDoFn",
+		"NAMESPACE":  "customDoFn",
+		"NAME":       "customHistogram",
+	}
+
+	mInfo := &pipepb.MonitoringInfo{
+		Urn:     UrnToString(UrnUserHistogramInt64),
+		Type:    UrnToType(UrnUserHistogramInt64),
+		Labels:  labels,
+		Payload: payload,
+	}
+
+	attempted := []*pipepb.MonitoringInfo{mInfo}
+	committed := []*pipepb.MonitoringInfo{}
+
+	got := FromMonitoringInfos(attempted, committed).AllMetrics().Histograms()
+	size := len(got)
+	if size < 1 {
+		t.Fatalf("Invalid array's size: got: %v, want: %v", size, 1)
+	}
+	if d := cmp.Diff(want, got[0]); d != "" {
+		t.Fatalf("Invalid histogram: got: %v, want: %v, diff(-want,+got):\n %v",
+			got[0], want, d)
+	}
+}
+
+func TestFromMonitoringInfos_BoundedTries(t *testing.T) {
+	value := metrics.BoundedTrieValue{Paths: []metrics.BoundedTriePath{
+		{Segments: []string{"gcs", "bucket", "a.txt"}},
+		{Segments: []string{"gcs", "other"}, Truncated: true},
+	}}
+	want := metrics.BoundedTrieResult{
+		Attempted: value,
+		Key: metrics.StepKey{
+			Step:      "main.customDoFn",
+			Name:      "customBoundedTrie",
+			Namespace: "customDoFn",
+		}}
+
+	payload, err := BoundedTrie(value)
+	if err != nil {
+		t.Fatalf("Failed to encode BoundedTrie: %v", err)
+	}
+
+	labels := map[string]string{
+		"PTRANSFORM": "main.customDoFn",
+		"NAMESPACE":  "customDoFn",
+		"NAME":       "customBoundedTrie",
+	}
+
+	mInfo := &pipepb.MonitoringInfo{
+		Urn:     UrnToString(UrnUserBoundedTrie),
+		Type:    UrnToType(UrnUserBoundedTrie),
+		Labels:  labels,
+		Payload: payload,
+	}
+
+	attempted := []*pipepb.MonitoringInfo{mInfo}
+	committed := []*pipepb.MonitoringInfo{}
+
+	got := FromMonitoringInfos(attempted, committed).AllMetrics().BoundedTries()
+	size := len(got)
+	if size < 1 {
+		t.Fatalf("Invalid array's size: got: %v, want: %v", size, 1)
+	}
+	if d := cmp.Diff(want, got[0]); d != "" {
+		t.Fatalf("Invalid bounded trie: got: %v, want: %v, diff(-want,+got):\n %v",
+			got[0], want, d)
+	}
+}
+
+// TestFromMonitoringInfos_Aggregated validates that metrics reported several
+// times, such as for each bundle, are aggregated, and only reported committed
+// values are committed.
+func TestFromMonitoringInfos_Aggregated(t *testing.T) {
+	labels := map[string]string{
+		"PTRANSFORM": "main.customDoFn",
+		"NAMESPACE":  "customDoFn",
+		"NAME":       "customCounter",
+	}
+	var infos []*pipepb.MonitoringInfo
+	for _, v := range []int64{2, 3} {
+		payload, err := Int64Counter(v)
+		if err != nil {
+			t.Fatalf("Failed to encode Int64Counter: %v", err)
+		}
+		infos = append(infos, &pipepb.MonitoringInfo{
+			Urn:     UrnToString(UrnUserSumInt64),
+			Type:    UrnToType(UrnUserSumInt64),
+			Labels:  labels,
+			Payload: payload,
+		})
+	}
+
+	got := FromMonitoringInfos(infos, infos[:1]).AllMetrics().Counters()
+	want := []metrics.CounterResult{{
+		Attempted:    5,
+		Committed:    2,
+		HasCommitted: true,
+		Key: metrics.StepKey{
+			Step:      "main.customDoFn",
+			Name:      "customCounter",
+			Namespace: "customDoFn",
+		}}}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Fatalf("Invalid counters: got: %v, want: %v, diff(-want,+got):\n %v", got, want, d)
+	}
+
+	got = FromMonitoringInfos(infos, nil).AllMetrics().Counters()
+	if len(got) != 1 || got[0].HasCommitted || got[0].Result() != 5 {
+		t.Errorf("Invalid counters without committed values: got: %+v, want only an attempted value of 5", got)
+	}
+}
+
+func TestWorkerUrns(t *testing.T) {
+	tests := []struct {
+		urn  Urn
+		spec pipepb.MonitoringInfoSpecs_Enum
+	}{
+		{UrnWorkerCPUTime, pipepb.MonitoringInfoSpecs_WORKER_PROCESS_CPU_MSECS},
+		{UrnWorkerResidentMemory, pipepb.MonitoringInfoSpecs_WORKER_RESIDENT_MEMORY_BYTES},
+		{UrnWorkerHeap, pipepb.MonitoringInfoSpecs_WORKER_HEAP_BYTES},
+		{UrnWorkerGCCount, pipepb.MonitoringInfoSpecs_WORKER_GC_COUNT},
+		{UrnWorkerGCPause, pipepb.MonitoringInfoSpecs_WORKER_GC_PAUSE_MSECS},
+	}
+	for _, test := range tests {
+		opts := test.spec.Descriptor().Values().ByNumber(test.spec.Number()).Options()
+		spec := proto.GetExtension(opts, pipepb.E_MonitoringInfoSpec).(*pipepb.MonitoringInfoSpec)
+		if got, want := UrnToString(test.urn), spec.GetUrn(); got != want {
+			t.Errorf("UrnToString(%v) = %v, want %v from the spec", test.spec, got, want)
+		}
+		if got, want := UrnToType(test.urn), spec.GetType(); got != want {
+			t.Errorf("UrnToType(%v) = %v, want %v from the spec", test.spec, got, want)
+		}
+	}
+}
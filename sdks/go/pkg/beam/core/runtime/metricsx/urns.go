@@ -21,6 +21,7 @@ import (
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 )
 
 // Urn is an enum type for representing urns of metrics and monitored states.
@@ -38,6 +39,9 @@ var sUrns = [...]string{
 	"beam:metric:user:top_n_double:v1",
 	"beam:metric:user:bottom_n_int64:v1",
 	"beam:metric:user:bottom_n_double:v1",
+	"beam:metric:user:set_string:v1",
+	"beam:metric:user:histogram_int64:v1",
+	"beam:metric:user:bounded_trie:v1",
 
 	"beam:metric:element_count:v1",
 	"beam:metric:sampled_byte_size:v1",
@@ -51,6 +55,12 @@ var sUrns = [...]string{
 	"beam:metric:ptransform_progress:completed:v1",
 	"beam:metric:data_channel:read_index:v1",
 
+	"beam:metric:worker:process_cpu_msecs:v1",
+	"beam:metric:worker:resident_memory_bytes:v1",
+	"beam:metric:worker:heap_bytes:v1",
+	"beam:metric:worker:gc_count:v1",
+	"beam:metric:worker:gc_pause_msecs:v1",
+
 	"TestingSentinelUrn", // Must remain last.
 }
 
@@ -66,6 +76,9 @@ const (
 	UrnUserTopNFloat64
 	UrnUserBottomNInt64
 	UrnUserBottomNFloat64
+	UrnUserSetString
+	UrnUserHistogramInt64
+	UrnUserBoundedTrie
 
 	UrnElementCount
 	UrnSampledByteSize
@@ -79,6 +92,12 @@ const (
 	UrnProgressCompleted
 	UrnDataChannelReadIndex
 
+	UrnWorkerCPUTime
+	UrnWorkerResidentMemory
+	UrnWorkerHeap
+	UrnWorkerGCCount
+	UrnWorkerGCPause
+
 	UrnTestSentinel // Must remain last.
 )
 
@@ -111,11 +130,21 @@ func UrnToType(u Urn) string {
 		return "beam:metrics:bottom_n_int64:v1"
 	case UrnUserBottomNFloat64:
 		return "beam:metrics:bottom_n_double:v1"
+	case UrnUserSetString:
+		return "beam:metrics:set_string:v1"
+	case UrnUserHistogramInt64:
+		return "beam:metrics:histogram_int64:v1"
+	case UrnUserBoundedTrie:
+		return "beam:metrics:bounded_trie:v1"
 
 	case UrnProgressRemaining, UrnProgressCompleted:
 		return "beam:metrics:progress:v1"
 	case UrnDataChannelReadIndex:
 		return "beam:metrics:sum_int64:v1"
+	case UrnWorkerCPUTime, UrnWorkerGCCount, UrnWorkerGCPause:
+		return "beam:metrics:sum_int64:v1"
+	case UrnWorkerResidentMemory, UrnWorkerHeap:
+		return "beam:metrics:latest_int64:v1"
 
 	// Monitoring Table isn't currently in the protos.
 	// case ???:
@@ -168,3 +197,98 @@ func Int64Distribution(count, sum, min, max int64) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// Float64Progress returns an encoded payload of progress values, such as the
+// work completed or remaining.
+func Float64Progress(vs ...float64) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := coder.EncodeInt32(int32(len(vs)), &buf); err != nil {
+		return nil, err
+	}
+	for _, v := range vs {
+		if err := coder.EncodeDouble(v, &buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// StringSet returns an encoded payload of a set of strings.
+func StringSet(vs []string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := coder.EncodeInt32(int32(len(vs)), &buf); err != nil {
+		return nil, err
+	}
+	for _, v := range vs {
+		if err := coder.EncodeStringUTF8(v, &buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Int64Histogram returns an encoded payload of the histogram of an integer
+// value. The payload holds whether the buckets are exponential as a byte,
+// the start and width of the buckets as doubles, the number of buckets as
+// a varint, and then the underflow count, the count of each bucket and the
+// overflow count, as varints.
+func Int64Histogram(v metrics.HistogramValue) ([]byte, error) {
+	var buf bytes.Buffer
+	var exp byte
+	if v.Buckets.Exponential {
+		exp = 1
+	}
+	if err := coder.EncodeByte(exp, &buf); err != nil {
+		return nil, err
+	}
+	if err := coder.EncodeDouble(v.Buckets.Start, &buf); err != nil {
+		return nil, err
+	}
+	if err := coder.EncodeDouble(v.Buckets.Width, &buf); err != nil {
+		return nil, err
+	}
+	if err := coder.EncodeVarInt(int64(len(v.Counts)), &buf); err != nil {
+		return nil, err
+	}
+	if err := coder.EncodeVarInt(v.Underflow, &buf); err != nil {
+		return nil, err
+	}
+	for _, c := range v.Counts {
+		if err := coder.EncodeVarInt(c, &buf); err != nil {
+			return nil, err
+		}
+	}
+	if err := coder.EncodeVarInt(v.Overflow, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// BoundedTrie returns an encoded payload of the paths of a bounded trie. The
+// payload holds the number of paths as an int32, and then for each path, the
+// number of its segments as an int32, the segments as strings, and whether
+// the path is truncated as a byte.
+func BoundedTrie(v metrics.BoundedTrieValue) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := coder.EncodeInt32(int32(len(v.Paths)), &buf); err != nil {
+		return nil, err
+	}
+	for _, p := range v.Paths {
+		if err := coder.EncodeInt32(int32(len(p.Segments)), &buf); err != nil {
+			return nil, err
+		}
+		for _, s := range p.Segments {
+			if err := coder.EncodeStringUTF8(s, &buf); err != nil {
+				return nil, err
+			}
+		}
+		var truncated byte
+		if p.Truncated {
+			truncated = 1
+		}
+		if err := coder.EncodeByte(truncated, &buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,45 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xlangx
+
+import (
+	"context"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// dialExpansionService connects to the expansion service of the transform at
+// the given endpoint, with TLS if the transform configures it and the service
+// is not managed. The returned context sends the transform's headers with the
+// requests made with it.
+func dialExpansionService(ctx context.Context, ext *graph.ExternalTransform, endpoint string, managed bool) (context.Context, *grpc.ClientConn, error) {
+	creds := grpc.WithInsecure()
+	if ext.ExpansionTLS != nil && !managed {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(ext.ExpansionTLS))
+	}
+	conn, err := grpc.Dial(endpoint, creds)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to connect to expansion service at %v", endpoint)
+	}
+	for k, v := range ext.ExpansionHeaders {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
+	return ctx, conn, nil
+}
@@ -0,0 +1,168 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xlangx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeExpansionService records the metadata of the requests it receives.
+type fakeExpansionService struct {
+	jobpb.UnimplementedExpansionServiceServer
+	md chan metadata.MD
+}
+
+func (f *fakeExpansionService) Expand(ctx context.Context, req *jobpb.ExpansionRequest) (*jobpb.ExpansionResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.md <- md
+	return &jobpb.ExpansionResponse{}, nil
+}
+
+// serveExpansion serves a fake expansion service on a local port, with TLS if
+// a certificate is given, and returns its endpoint.
+func serveExpansion(t *testing.T, cert *tls.Certificate) (string, *fakeExpansionService) {
+	t.Helper()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	var opts []grpc.ServerOption
+	if cert != nil {
+		opts = append(opts, grpc.Creds(credentials.NewServerTLSFromCert(cert)))
+	}
+	srv := grpc.NewServer(opts...)
+	fake := &fakeExpansionService{md: make(chan metadata.MD, 1)}
+	jobpb.RegisterExpansionServiceServer(srv, fake)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+	return l.Addr().String(), fake
+}
+
+// selfSignedCert returns a certificate for localhost, and a pool to trust it.
+func selfSignedCert(t *testing.T) (*tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestDialExpansionService(t *testing.T) {
+	cert, pool := selfSignedCert(t)
+	tests := []struct {
+		name string
+		cert *tls.Certificate
+		tls  *tls.Config
+	}{
+		{"Insecure", nil, nil},
+		{"TLS", cert, &tls.Config{RootCAs: pool}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			endpoint, fake := serveExpansion(t, test.cert)
+			ext := &graph.ExternalTransform{
+				ExpansionAddr:    endpoint,
+				ExpansionTLS:     test.tls,
+				ExpansionHeaders: map[string]string{"authorization": "Bearer token"},
+			}
+			ctx, conn, err := dialExpansionService(context.Background(), ext, endpoint, false)
+			if err != nil {
+				t.Fatalf("dialExpansionService failed: %v", err)
+			}
+			defer conn.Close()
+			if _, err := queryExpansionService(ctx, nil, nil, "ns", jobpb.NewExpansionServiceClient(conn)); err != nil {
+				t.Fatalf("queryExpansionService failed: %v", err)
+			}
+			md := <-fake.md
+			if got, want := md.Get("authorization"), "Bearer token"; len(got) != 1 || got[0] != want {
+				t.Errorf("authorization header = %v, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDialExpansionService_Managed(t *testing.T) {
+	// Managed services only listen locally, and are connected to insecurely
+	// regardless of the TLS configuration.
+	endpoint, fake := serveExpansion(t, nil)
+	ext := &graph.ExternalTransform{
+		ExpansionAddr: AutoJava("sdks:java:io:expansion-service:shadowJar"),
+		ExpansionTLS:  &tls.Config{},
+	}
+	ctx, conn, err := dialExpansionService(context.Background(), ext, endpoint, true)
+	if err != nil {
+		t.Fatalf("dialExpansionService failed: %v", err)
+	}
+	defer conn.Close()
+	if _, err := queryExpansionService(ctx, nil, nil, "ns", jobpb.NewExpansionServiceClient(conn)); err != nil {
+		t.Fatalf("queryExpansionService failed: %v", err)
+	}
+	<-fake.md
+}
+
+func TestDialExpansionService_UntrustedTLS(t *testing.T) {
+	cert, _ := selfSignedCert(t)
+	endpoint, _ := serveExpansion(t, cert)
+	ext := &graph.ExternalTransform{ExpansionAddr: endpoint, ExpansionTLS: &tls.Config{}}
+	ctx, conn, err := dialExpansionService(context.Background(), ext, endpoint, false)
+	if err != nil {
+		t.Fatalf("dialExpansionService failed: %v", err)
+	}
+	defer conn.Close()
+	if _, err := queryExpansionService(ctx, nil, nil, "ns", jobpb.NewExpansionServiceClient(conn)); err == nil {
+		t.Error("queryExpansionService succeeded with an untrusted certificate, want error")
+	}
+}
@@ -26,13 +26,14 @@ import (
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
 	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
-	"google.golang.org/grpc"
 )
 
 // Expand expands an unexpanded graph.ExternalTransform as a
 // graph.ExpandedTransform and assigns it to the ExternalTransform's Expanded
 // field. This requires querying an expansion service based on the configuration
-// details within the ExternalTransform.
+// details within the ExternalTransform, which may require TLS and headers for
+// connecting to it. Expansion services of managed addresses, such as those
+// returned by AutoJava, are started for the query and stopped afterwards.
 func Expand(edge *graph.MultiEdge, ext *graph.ExternalTransform) error {
 	// Build the ExpansionRequest
 
@@ -64,8 +65,21 @@ func Expand(edge *graph.MultiEdge, ext *graph.ExternalTransform) error {
 	addNamespace(extTransform, p.GetComponents(), ext.Namespace)
 	delete(transforms, extTransformID)
 
-	// Querying the expansion service
-	res, err := queryExpansionService(context.Background(), p.GetComponents(), extTransform, ext.Namespace, ext.ExpansionAddr)
+	// Querying the expansion service, which is started for managed addresses
+	ctx := context.Background()
+	var svcs services
+	defer svcs.stop(ctx)
+	endpoint, err := svcs.endpoint(ctx, ext.ExpansionAddr)
+	if err != nil {
+		return err
+	}
+	ctx, conn, err := dialExpansionService(ctx, ext, endpoint, svcs.managed(ext.ExpansionAddr))
+	if err != nil {
+		return errors.WithContextf(err, "expanding transform %v", ext.Urn)
+	}
+	defer conn.Close()
+	client := jobpb.NewExpansionServiceClient(conn)
+	res, err := queryExpansionService(ctx, p.GetComponents(), extTransform, ext.Namespace, client)
 	if err != nil {
 		return err
 	}
@@ -96,7 +110,7 @@ func queryExpansionService(
 	comps *pipepb.Components,
 	transform *pipepb.PTransform,
 	namespace string,
-	expansionAddr string) (*jobpb.ExpansionResponse, error) {
+	client jobpb.ExpansionServiceClient) (*jobpb.ExpansionResponse, error) {
 	// Querying Expansion Service
 
 	// Build expansion request proto.
@@ -106,15 +120,6 @@ func queryExpansionService(
 		Namespace:  namespace,
 	}
 
-	// Setting grpc client
-	conn, err := grpc.Dial(expansionAddr, grpc.WithInsecure())
-	if err != nil {
-		err = errors.Wrapf(err, "unable to connect to expansion service at %v", expansionAddr)
-		return nil, errors.WithContextf(err, "expanding transform with ExpansionRequest: %v", req)
-	}
-	defer conn.Close()
-	client := jobpb.NewExpansionServiceClient(conn)
-
 	// Handling ExpansionResponse
 	res, err := client.Expand(ctx, req)
 	if err != nil {
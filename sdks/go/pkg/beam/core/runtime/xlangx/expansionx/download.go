@@ -0,0 +1,126 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expansionx
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+const (
+	beamGroupPath = "org/apache/beam"
+	jarCacheDir   = ".apache_beam/cache/jars"
+)
+
+var (
+	// repository is the Maven repository Beam jars are downloaded from. It
+	// is a variable so tests can substitute a local server.
+	repository = "https://repo.maven.apache.org/maven2"
+
+	// cacheHome returns the directory holding the caches of downloaded jars
+	// and Python environments. It is a variable so tests can substitute a
+	// temporary directory.
+	cacheHome = os.UserHomeDir
+)
+
+// GetBeamJar returns the local path of the jar built by the given Gradle
+// target of the Beam repository, such as
+// "sdks:java:io:expansion-service:shadowJar", for the given released version
+// of Beam. The jar is downloaded from Maven Central, unless it is cached from
+// an earlier call. Development versions are not released, so their jars must
+// be built from source.
+func GetBeamJar(gradleTarget, version string) (string, error) {
+	if strings.HasSuffix(version, ".dev") || strings.HasSuffix(version, "-SNAPSHOT") {
+		return "", errors.Errorf("jars of development version %v of Beam are not released, "+
+			"build target %v from source and run it as a local jar instead", version, gradleTarget)
+	}
+	artifact, err := artifactID(gradleTarget)
+	if err != nil {
+		return "", err
+	}
+	home, err := cacheHome()
+	if err != nil {
+		return "", errors.Wrap(err, "locating jar cache")
+	}
+	jar := fmt.Sprintf("%v-%v.jar", artifact, version)
+	path := filepath.Join(home, jarCacheDir, jar)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	url := strings.Join([]string{repository, beamGroupPath, artifact, version, jar}, "/")
+	if err := download(url, path); err != nil {
+		return "", errors.Wrapf(err, "downloading jar of %v for Beam %v", gradleTarget, version)
+	}
+	return path, nil
+}
+
+// artifactID returns the Maven artifact ID of the jar built by the given
+// Gradle target, such as "beam-sdks-java-io-expansion-service" for the target
+// "sdks:java:io:expansion-service:shadowJar".
+func artifactID(gradleTarget string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(gradleTarget, ":"), ":")
+	if len(parts) < 2 {
+		return "", errors.Errorf("invalid Gradle target %q, want a project and a task", gradleTarget)
+	}
+	project, task := parts[:len(parts)-1], parts[len(parts)-1]
+	id := "beam-" + strings.Join(project, "-")
+	switch task {
+	case "shadowJar":
+		return id, nil
+	case "shadowTestJar":
+		return id + "-tests", nil
+	default:
+		return "", errors.Errorf("invalid Gradle target %q, want a shadowJar or shadowTestJar task", gradleTarget)
+	}
+}
+
+// download fetches the file at the given URL to the given path. The file is
+// written to a temporary file first, so an interrupted download never leaves
+// a partial jar in the cache.
+func download(url, path string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("GET %v: %v", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,120 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expansionx
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArtifactID(t *testing.T) {
+	tests := []struct {
+		target, want string
+	}{
+		{"sdks:java:io:expansion-service:shadowJar", "beam-sdks-java-io-expansion-service"},
+		{":sdks:java:extensions:sql:expansion-service:shadowJar", "beam-sdks-java-extensions-sql-expansion-service"},
+		{"sdks:java:core:shadowTestJar", "beam-sdks-java-core-tests"},
+	}
+	for _, test := range tests {
+		got, err := artifactID(test.target)
+		if err != nil {
+			t.Errorf("artifactID(%q) failed: %v", test.target, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("artifactID(%q) = %q, want %q", test.target, got, test.want)
+		}
+	}
+}
+
+func TestArtifactID_Bad(t *testing.T) {
+	for _, target := range []string{"", "shadowJar", "sdks:java:io:expansion-service:runExpansionService"} {
+		if got, err := artifactID(target); err == nil {
+			t.Errorf("artifactID(%q) = %q, want error", target, got)
+		}
+	}
+}
+
+func TestGetBeamJar(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if !strings.HasSuffix(r.URL.Path, ".jar") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("jar"))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	substitute(t, srv.URL, dir)
+
+	for i := 0; i < 2; i++ {
+		path, err := GetBeamJar("sdks:java:io:expansion-service:shadowJar", "2.32.0")
+		if err != nil {
+			t.Fatalf("GetBeamJar failed: %v", err)
+		}
+		if want := filepath.Join(dir, jarCacheDir, "beam-sdks-java-io-expansion-service-2.32.0.jar"); path != want {
+			t.Errorf("GetBeamJar = %v, want %v", path, want)
+		}
+		if b, err := ioutil.ReadFile(path); err != nil || string(b) != "jar" {
+			t.Errorf("jar = %q, %v, want %q", b, err, "jar")
+		}
+	}
+	// The second call is served from the cache.
+	want := []string{"/org/apache/beam/beam-sdks-java-io-expansion-service/2.32.0/beam-sdks-java-io-expansion-service-2.32.0.jar"}
+	if len(requests) != 1 || requests[0] != want[0] {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
+
+func TestGetBeamJar_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	dir := t.TempDir()
+	substitute(t, srv.URL, dir)
+
+	tests := []struct {
+		target, version string
+	}{
+		{"sdks:java:io:expansion-service:shadowJar", "2.33.0.dev"},
+		{"sdks:java:io:expansion-service:shadowJar", "2.33.0-SNAPSHOT"},
+		{"sdks:java:io:expansion-service:runExpansionService", "2.32.0"},
+		{"sdks:java:io:expansion-service:shadowJar", "2.32.0"}, // Not found.
+	}
+	for _, test := range tests {
+		if path, err := GetBeamJar(test.target, test.version); err == nil {
+			t.Errorf("GetBeamJar(%q, %q) = %v, want error", test.target, test.version, path)
+		}
+	}
+	if files, _ := ioutil.ReadDir(filepath.Join(dir, jarCacheDir)); len(files) != 0 {
+		t.Errorf("cache holds %v files after failed downloads, want none", len(files))
+	}
+}
+
+// substitute makes jars download from the given repository, and caches live
+// in the given directory, for the duration of the test.
+func substitute(t *testing.T, repo, dir string) {
+	t.Helper()
+	oldRepo, oldHome := repository, cacheHome
+	repository = repo
+	cacheHome = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { repository, cacheHome = oldRepo, oldHome })
+}
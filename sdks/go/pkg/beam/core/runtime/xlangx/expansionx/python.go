@@ -0,0 +1,147 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expansionx
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+const (
+	venvCacheDir = ".apache_beam/cache/venvs"
+
+	pythonServiceModule = "apache_beam.runners.portability.expansion_service_main"
+)
+
+// python is the Python interpreter virtual environments are created with. It
+// is a variable so tests can substitute a fake interpreter.
+var python = "python3"
+
+// PythonEnv returns the interpreter of a virtual environment with Apache Beam
+// and the packages of the given requirements file installed, if any. Beam is
+// installed at the given version, unless the requirements list it too. The
+// environment is created with pip, unless it is cached from an earlier call
+// with the same requirements.
+func PythonEnv(requirementsFile, version string) (string, error) {
+	var reqs []byte
+	if requirementsFile != "" {
+		var err error
+		if reqs, err = ioutil.ReadFile(requirementsFile); err != nil {
+			return "", errors.Wrap(err, "reading Python requirements")
+		}
+	}
+	pkgs := []string{}
+	if !requiresBeam(reqs) {
+		if strings.HasSuffix(version, ".dev") {
+			return "", errors.Errorf("Python SDK of development version %v of Beam is not released, "+
+				"list the apache-beam package to install in the requirements", version)
+		}
+		pkgs = append(pkgs, "apache-beam=="+version)
+	}
+
+	home, err := cacheHome()
+	if err != nil {
+		return "", errors.Wrap(err, "locating virtual environment cache")
+	}
+	dir := filepath.Join(home, venvCacheDir, venvKey(reqs, pkgs))
+	interpreter := filepath.Join(dir, "bin", "python")
+	if _, err := os.Stat(interpreter); err == nil {
+		return interpreter, nil
+	}
+
+	// The environment is created in a temporary directory first, so that a
+	// failed installation never leaves a broken environment in the cache.
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(dir), filepath.Base(dir)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := run(python, "-m", "venv", tmp); err != nil {
+		return "", errors.Wrap(err, "creating Python virtual environment")
+	}
+	args := append([]string{"install"}, pkgs...)
+	if requirementsFile != "" {
+		args = append(args, "-r", requirementsFile)
+	}
+	if err := run(filepath.Join(tmp, "bin", "pip"), args...); err != nil {
+		return "", errors.Wrap(err, "installing Python requirements")
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", err
+	}
+	return interpreter, nil
+}
+
+// NewPythonService returns the Python expansion service of the given
+// interpreter on a free local port, which expands transforms by their fully
+// qualified names. The requirements file, if any, is staged for the workers
+// of the expanded transforms.
+func NewPythonService(interpreter, requirementsFile string) (*Service, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"-m", pythonServiceModule, "--port", strconv.Itoa(port), "--fully_qualified_name_glob=*"}
+	if requirementsFile != "" {
+		args = append(args, "--requirements_file="+requirementsFile)
+	}
+	return newService(exec.Command(interpreter, args...), fmt.Sprintf("localhost:%d", port)), nil
+}
+
+// requiresBeam returns whether the given requirements list Apache Beam.
+func requiresBeam(reqs []byte) bool {
+	s := bufio.NewScanner(strings.NewReader(string(reqs)))
+	for s.Scan() {
+		line := strings.ToLower(strings.TrimSpace(s.Text()))
+		if strings.HasPrefix(line, "apache-beam") || strings.HasPrefix(line, "apache_beam") {
+			return true
+		}
+	}
+	return false
+}
+
+// venvKey returns the name of the cached virtual environment of the given
+// requirements and packages.
+func venvKey(reqs []byte, pkgs []string) string {
+	h := sha256.New()
+	h.Write(reqs)
+	for _, p := range pkgs {
+		fmt.Fprintf(h, "\n%v", p)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// run runs the given command, and returns its output with any failure.
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "running %v %v:\n%s", name, strings.Join(args, " "), out)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expansionx
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakePython substitutes an interpreter whose virtual environments log the
+// arguments of pip to the returned file.
+func fakePython(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake interpreter is a shell script")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "pip.log")
+	script := `#!/bin/sh
+# Called as: python3 -m venv DIR
+mkdir -p "$3/bin"
+touch "$3/bin/python"
+printf '#!/bin/sh\necho "$@" >> ` + log + `\n' > "$3/bin/pip"
+chmod +x "$3/bin/pip"
+`
+	fake := filepath.Join(dir, "python3")
+	if err := ioutil.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := python
+	python = fake
+	t.Cleanup(func() { python = old })
+	return log
+}
+
+func TestPythonEnv(t *testing.T) {
+	log := fakePython(t)
+	dir := t.TempDir()
+	substitute(t, "", dir)
+	reqs := filepath.Join(t.TempDir(), "requirements.txt")
+	if err := ioutil.WriteFile(reqs, []byte("numpy==1.21.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var first string
+	for i := 0; i < 2; i++ {
+		interpreter, err := PythonEnv(reqs, "2.32.0")
+		if err != nil {
+			t.Fatalf("PythonEnv failed: %v", err)
+		}
+		if _, err := os.Stat(interpreter); err != nil {
+			t.Errorf("interpreter %v missing: %v", interpreter, err)
+		}
+		if !strings.HasPrefix(interpreter, filepath.Join(dir, venvCacheDir)) {
+			t.Errorf("interpreter %v is not cached in %v", interpreter, dir)
+		}
+		if i == 0 {
+			first = interpreter
+		} else if interpreter != first {
+			t.Errorf("PythonEnv = %v, want cached %v", interpreter, first)
+		}
+	}
+	// The second call is served from the cache.
+	got, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "install apache-beam==2.32.0 -r " + reqs + "\n"; string(got) != want {
+		t.Errorf("pip calls = %q, want %q", got, want)
+	}
+}
+
+func TestPythonEnv_Errors(t *testing.T) {
+	fakePython(t)
+	substitute(t, "", t.TempDir())
+
+	if _, err := PythonEnv("", "2.33.0.dev"); err == nil {
+		t.Error("PythonEnv of development version succeeded, want error")
+	}
+	if _, err := PythonEnv(filepath.Join(t.TempDir(), "missing.txt"), "2.32.0"); err == nil {
+		t.Error("PythonEnv of missing requirements succeeded, want error")
+	}
+}
+
+func TestRequiresBeam(t *testing.T) {
+	tests := []struct {
+		reqs string
+		want bool
+	}{
+		{"", false},
+		{"numpy\npandas>=1.0\n", false},
+		{"numpy\n  apache-beam[gcp]==2.32.0\n", true},
+		{"Apache_Beam\n", true},
+	}
+	for _, test := range tests {
+		if got := requiresBeam([]byte(test.reqs)); got != test.want {
+			t.Errorf("requiresBeam(%q) = %v, want %v", test.reqs, got, test.want)
+		}
+	}
+}
+
+func TestVenvKey(t *testing.T) {
+	a := venvKey([]byte("numpy"), []string{"apache-beam==2.32.0"})
+	if b := venvKey([]byte("numpy"), []string{"apache-beam==2.31.0"}); a == b {
+		t.Errorf("venvKey of different Beam versions = %v, want different keys", a)
+	}
+	if b := venvKey([]byte("pandas"), []string{"apache-beam==2.32.0"}); a == b {
+		t.Errorf("venvKey of different requirements = %v, want different keys", a)
+	}
+}
+
+func TestNewPythonService(t *testing.T) {
+	s, err := NewPythonService("/venv/bin/python", "requirements.txt")
+	if err != nil {
+		t.Fatalf("NewPythonService failed: %v", err)
+	}
+	args := strings.Join(s.cmd.Args, " ")
+	for _, want := range []string{"-m " + pythonServiceModule, "--fully_qualified_name_glob=*", "--requirements_file=requirements.txt"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("service args %q, want %q", args, want)
+		}
+	}
+}
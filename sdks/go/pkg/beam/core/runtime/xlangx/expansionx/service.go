@@ -0,0 +1,190 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expansionx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"google.golang.org/grpc"
+)
+
+// StartTimeout is how long Start waits for an expansion service to accept
+// connections.
+var StartTimeout = 2 * time.Minute
+
+// Service is an expansion service run by this process, either as a Java
+// process serving an expansion service jar, or as a container.
+type Service struct {
+	cmd      *exec.Cmd
+	endpoint string
+	// stop stops the service, if killing its command is not enough.
+	stop func() error
+
+	out    syncBuffer
+	exited chan struct{}
+}
+
+// NewJarService returns an expansion service serving the given jar on a free
+// local port. The arguments are passed to the service after the port, such
+// as pipeline options of the Java expansion service.
+func NewJarService(jarPath string, args ...string) (*Service, error) {
+	java, err := exec.LookPath("java")
+	if err != nil {
+		return nil, errors.Wrap(err, "running expansion service jar requires java")
+	}
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(java, append([]string{"-jar", jarPath, strconv.Itoa(port)}, args...)...)
+	return newService(cmd, fmt.Sprintf("localhost:%d", port)), nil
+}
+
+// NewContainerService returns an expansion service run by the given container
+// image, which must serve the expansion service on the given port of the
+// container. The port is published on a free local port.
+func NewContainerService(image string, containerPort int) (*Service, error) {
+	docker, err := exec.LookPath("docker")
+	if err != nil {
+		return nil, errors.Wrap(err, "running expansion service container requires docker")
+	}
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("beam-expansion-service-%d", port)
+	cmd := exec.Command(docker, "run", "--rm", "--name", name,
+		"-p", fmt.Sprintf("127.0.0.1:%d:%d", port, containerPort), image)
+	s := newService(cmd, fmt.Sprintf("localhost:%d", port))
+	s.stop = func() error {
+		return exec.Command(docker, "stop", name).Run()
+	}
+	return s, nil
+}
+
+func newService(cmd *exec.Cmd, endpoint string) *Service {
+	s := &Service{cmd: cmd, endpoint: endpoint, exited: make(chan struct{})}
+	cmd.Stdout = &s.out
+	cmd.Stderr = &s.out
+	return s
+}
+
+// Endpoint returns the address of the service.
+func (s *Service) Endpoint() string {
+	return s.endpoint
+}
+
+// Start starts the service, and waits for up to StartTimeout for it to
+// accept connections. The service is stopped if it fails to.
+func (s *Service) Start(ctx context.Context) error {
+	if err := s.cmd.Start(); err != nil {
+		return errors.Wrapf(err, "starting expansion service %v", s.cmd.Args)
+	}
+	go func() {
+		s.cmd.Wait()
+		close(s.exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, StartTimeout)
+	defer cancel()
+	for {
+		if s.ready(ctx) {
+			return nil
+		}
+		select {
+		case <-s.exited:
+			return errors.Errorf("expansion service %v exited before accepting connections: %v\n%s",
+				s.cmd.Args, s.cmd.ProcessState, s.out.Bytes())
+		case <-ctx.Done():
+			s.Stop()
+			return errors.Wrapf(ctx.Err(), "waiting for expansion service %v at %v", s.cmd.Args, s.endpoint)
+		default:
+		}
+	}
+}
+
+// ready returns whether the service completes a gRPC handshake within a
+// second. A TCP connection alone is not enough, as the port of a container is
+// published before its service listens.
+func (s *Service) ready(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, s.endpoint, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Stop stops the service and waits for it to exit.
+func (s *Service) Stop() error {
+	if s.cmd.Process == nil {
+		return nil
+	}
+	var err error
+	if s.stop != nil {
+		err = s.stop()
+	}
+	select {
+	case <-s.exited:
+		return err
+	default:
+	}
+	if err := s.cmd.Process.Kill(); err != nil && err != os.ErrProcessDone {
+		return errors.Wrapf(err, "stopping expansion service %v", s.cmd.Args)
+	}
+	<-s.exited
+	return err
+}
+
+// freePort returns a local port that is free at the time of the call.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, errors.Wrap(err, "finding a free port for expansion service")
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// syncBuffer is a buffer for the output of a service, which is written by the
+// command and read on failures concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
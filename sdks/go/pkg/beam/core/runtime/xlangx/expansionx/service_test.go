@@ -0,0 +1,123 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expansionx
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// TestHelperProcess is not a test, but the expansion service run by the
+// tests, which serves a gRPC server on the port given by the environment.
+func TestHelperProcess(t *testing.T) {
+	addr := os.Getenv("EXPANSIONX_HELPER_ADDR")
+	if addr == "" {
+		return
+	}
+	if addr == "fail" {
+		fmt.Println("bad jar")
+		os.Exit(3)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	grpc.NewServer().Serve(l)
+	os.Exit(0)
+}
+
+func helperService(t *testing.T, addr string) *Service {
+	t.Helper()
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := fmt.Sprintf("localhost:%d", port)
+	if addr == "" {
+		addr = endpoint
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+	cmd.Env = append(os.Environ(), "EXPANSIONX_HELPER_ADDR="+addr)
+	return newService(cmd, endpoint)
+}
+
+func TestService(t *testing.T) {
+	s := helperService(t, "")
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	conn, err := net.Dial("tcp", s.Endpoint())
+	if err != nil {
+		t.Errorf("service is not serving at %v: %v", s.Endpoint(), err)
+	} else {
+		conn.Close()
+	}
+	if err := s.Stop(); err != nil {
+		t.Errorf("Stop failed: %v", err)
+	}
+	select {
+	case <-s.exited:
+	default:
+		t.Error("service still running after Stop")
+	}
+}
+
+func TestService_Exited(t *testing.T) {
+	s := helperService(t, "fail")
+	err := s.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "bad jar") {
+		t.Errorf("Start = %v, want error with the output of the service", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Errorf("Stop after exit failed: %v", err)
+	}
+}
+
+func TestService_Timeout(t *testing.T) {
+	// The service never listens on its endpoint.
+	s := helperService(t, "localhost:0")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.Start(ctx); err == nil {
+		t.Error("Start succeeded, want timeout")
+	}
+	select {
+	case <-s.exited:
+	default:
+		t.Error("service still running after timing out")
+	}
+}
+
+func TestFreePort(t *testing.T) {
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("freePort failed: %v", err)
+	}
+	l, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		t.Fatalf("port %v is not free: %v", port, err)
+	}
+	l.Close()
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx/schema"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -31,31 +32,15 @@ import (
 // the original type and the original value encoded as a Row. This is intended
 // to be used as the expansion payload for an External transform.
 func EncodeStructPayload(pl interface{}) ([]byte, error) {
-	rt := reflect.TypeOf(pl)
-
-	// Encode payload value as a Row.
-	enc, err := coder.RowEncoderForStruct(rt)
+	row, scm, err := encodeRow(pl)
 	if err != nil {
-		err = errors.WithContext(err, "creating Row encoder for payload")
-		return []byte{}, errors.WithContextf(err, "encoding external payload %v", pl)
-	}
-	var buf bytes.Buffer
-	if err := enc(pl, &buf); err != nil {
-		err = errors.WithContext(err, "encoding payload as Row")
-		return []byte{}, errors.WithContextf(err, "encoding external payload %v", pl)
-	}
-
-	// Convert payload type into Schema representation.
-	scm, err := schema.FromType(rt)
-	if err != nil {
-		err = errors.WithContext(err, "creating schema for payload")
 		return []byte{}, errors.WithContextf(err, "encoding external payload %v", pl)
 	}
 
 	// Put schema and row into payload proto, and marshal it.
 	ecp := &pipepb.ExternalConfigurationPayload{
 		Schema:  scm,
-		Payload: buf.Bytes(),
+		Payload: row,
 	}
 	plBytes, err := proto.Marshal(ecp)
 	if err != nil {
@@ -65,3 +50,73 @@ func EncodeStructPayload(pl interface{}) ([]byte, error) {
 
 	return plBytes, nil
 }
+
+// EncodeRow encodes a native Go struct as a Row of the schema of its type, such
+// as configurations nested in payloads.
+func EncodeRow(v interface{}) ([]byte, error) {
+	row, _, err := encodeRow(v)
+	return row, err
+}
+
+// encodeRow encodes a native Go struct as a Row, and returns it with the schema
+// of its type.
+func encodeRow(v interface{}) ([]byte, *pipepb.Schema, error) {
+	rt := reflect.TypeOf(v)
+
+	// Encode value as a Row.
+	enc, err := coder.RowEncoderForStruct(rt)
+	if err != nil {
+		return nil, nil, errors.WithContext(err, "creating Row encoder")
+	}
+	var buf bytes.Buffer
+	if err := enc(v, &buf); err != nil {
+		return nil, nil, errors.WithContext(err, "encoding as Row")
+	}
+
+	// Convert type into Schema representation.
+	scm, err := schema.FromType(rt)
+	if err != nil {
+		return nil, nil, errors.WithContext(err, "creating schema")
+	}
+	return buf.Bytes(), scm, nil
+}
+
+// SchemaTransformURN is the URN of transforms of SchemaTransformProviders,
+// whose payloads are encoded by EncodeSchemaTransformPayload.
+const SchemaTransformURN = "beam:expansion:payload:schematransform:v1"
+
+// Field numbers of the SchemaTransformPayload proto of the expansion API.
+const (
+	schemaTransformIdentifierField = 1
+	schemaTransformSchemaField     = 2
+	schemaTransformRowField        = 3
+)
+
+// EncodeSchemaTransformPayload takes the identifier of a SchemaTransformProvider
+// and a native Go struct of its configuration, and returns a marshaled
+// SchemaTransformPayload proto, containing the identifier, a Schema
+// representation of the configuration type and the configuration encoded as a
+// Row. This is intended to be used as the expansion payload for an External
+// transform of SchemaTransformURN.
+func EncodeSchemaTransformPayload(identifier string, config interface{}) ([]byte, error) {
+	row, scm, err := encodeRow(config)
+	if err != nil {
+		return []byte{}, errors.WithContextf(err, "encoding configuration %v of schema transform %v", config, identifier)
+	}
+	scmBytes, err := proto.Marshal(scm)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to marshal schema as proto")
+		return []byte{}, errors.WithContextf(err, "encoding configuration %v of schema transform %v", config, identifier)
+	}
+
+	// The generated protos predate SchemaTransformPayload, so it is encoded
+	// field by field.
+	var b []byte
+	b = protowire.AppendTag(b, schemaTransformIdentifierField, protowire.BytesType)
+	b = protowire.AppendString(b, identifier)
+	b = protowire.AppendTag(b, schemaTransformSchemaField, protowire.BytesType)
+	b = protowire.AppendBytes(b, scmBytes)
+	b = protowire.AppendTag(b, schemaTransformRowField, protowire.BytesType)
+	b = protowire.AppendBytes(b, row)
+	return b, nil
+}
@@ -0,0 +1,107 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xlangx
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+type testConfig struct {
+	Topic    string   `beam:"topic"`
+	MaxReads *int64   `beam:"max_reads"`
+	Servers  []string `beam:"servers"`
+}
+
+func TestEncodeRow(t *testing.T) {
+	n := int64(10)
+	want := testConfig{Topic: "events", MaxReads: &n, Servers: []string{"a:9092", "b:9092"}}
+	b, err := EncodeRow(want)
+	if err != nil {
+		t.Fatalf("EncodeRow failed: %v", err)
+	}
+	got := decodeTestConfig(t, b)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("decoded Row mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestEncodeSchemaTransformPayload(t *testing.T) {
+	cfg := testConfig{Topic: "events", Servers: []string{"a:9092"}}
+	b, err := EncodeSchemaTransformPayload("beam:schematransform:test:v1", cfg)
+	if err != nil {
+		t.Fatalf("EncodeSchemaTransformPayload failed: %v", err)
+	}
+
+	fields := make(map[protowire.Number][]byte)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 || typ != protowire.BytesType {
+			t.Fatalf("bad payload field %v of type %v: %v", num, typ, protowire.ParseError(n))
+		}
+		b = b[n:]
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			t.Fatalf("bad payload field %v: %v", num, protowire.ParseError(n))
+		}
+		fields[num] = v
+		b = b[n:]
+	}
+
+	if got, want := string(fields[schemaTransformIdentifierField]), "beam:schematransform:test:v1"; got != want {
+		t.Errorf("identifier = %q, want %q", got, want)
+	}
+	var scm pipepb.Schema
+	if err := proto.Unmarshal(fields[schemaTransformSchemaField], &scm); err != nil {
+		t.Fatalf("bad configuration schema: %v", err)
+	}
+	var names []string
+	for _, f := range scm.GetFields() {
+		names = append(names, f.GetName())
+	}
+	if diff := cmp.Diff([]string{"topic", "max_reads", "servers"}, names); diff != "" {
+		t.Errorf("configuration schema fields mismatch (-want +got):\n%v", diff)
+	}
+	if diff := cmp.Diff(cfg, decodeTestConfig(t, fields[schemaTransformRowField])); diff != "" {
+		t.Errorf("configuration Row mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestEncodeSchemaTransformPayload_NotStruct(t *testing.T) {
+	if _, err := EncodeSchemaTransformPayload("beam:schematransform:test:v1", "config"); err == nil {
+		t.Error("EncodeSchemaTransformPayload of string succeeded, want error")
+	}
+}
+
+func decodeTestConfig(t *testing.T, b []byte) testConfig {
+	t.Helper()
+	dec, err := coder.RowDecoderForStruct(reflect.TypeOf(testConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := dec(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("decoding Row failed: %v", err)
+	}
+	return v.(testConfig)
+}
@@ -25,10 +25,13 @@ import (
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/protox"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
 	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
 )
 
-// ResolveArtifacts acquires all dependencies for a cross-language transform
+// ResolveArtifacts acquires all dependencies for a cross-language transform.
+// It panics on failure, so runners should call ResolveArtifactsWithConfig
+// instead.
 func ResolveArtifacts(ctx context.Context, edges []*graph.MultiEdge, p *pipepb.Pipeline) {
 	_, err := ResolveArtifactsWithConfig(ctx, edges, ResolveConfig{})
 	if err != nil {
@@ -52,6 +55,10 @@ type ResolveConfig struct {
 	// JoinFn is a function for combining SdkPath and individual artifact names.
 	// If not specified, it defaults to using filepath.Join.
 	JoinFn func(path, name string) string
+
+	// LocalPath is the local directory that artifacts are retrieved to. If
+	// not specified, it defaults to "/tmp/artifacts".
+	LocalPath string
 }
 
 func defaultJoinFn(path, name string) string {
@@ -68,10 +75,21 @@ func defaultJoinFn(path, name string) string {
 //   3. Adds the dependencies to the transform's stored environment proto.
 // The changes that can be configured are documented in ResolveConfig.
 //
+// Runners then stage the dependencies as those of the environments they are
+// assigned to. Environments without dependencies are skipped, and the
+// environments shared by several transforms are resolved once.
+//
+// Expansion services of managed addresses are started again for retrieving
+// the dependencies, once for all transforms of the same address. Services of
+// other addresses are connected to with the TLS and headers of the transforms.
+//
 // This returns a map of "local path" to "sdk path". By default these are
 // identical, unless ResolveConfig.SdkPath has been set.
 func ResolveArtifactsWithConfig(ctx context.Context, edges []*graph.MultiEdge, cfg ResolveConfig) (paths map[string]string, err error) {
-	tmpPath, err := filepath.Abs("/tmp/artifacts")
+	if cfg.LocalPath == "" {
+		cfg.LocalPath = "/tmp/artifacts"
+	}
+	tmpPath, err := filepath.Abs(cfg.LocalPath)
 	if err != nil {
 		return nil, errors.WithContext(err, "resolving remote artifacts")
 	}
@@ -79,6 +97,10 @@ func ResolveArtifactsWithConfig(ctx context.Context, edges []*graph.MultiEdge, c
 		cfg.JoinFn = defaultJoinFn
 	}
 	paths = make(map[string]string)
+	// Environments are resolved once, even if shared by several transforms.
+	resolved := make(map[*pipepb.Environment]bool)
+	var svcs services
+	defer svcs.stop(ctx)
 	for _, e := range edges {
 		if e.Op == graph.External && e.External != nil {
 			components, err := graphx.ExpandedComponents(e.External.Expanded)
@@ -88,11 +110,20 @@ func ResolveArtifactsWithConfig(ctx context.Context, edges []*graph.MultiEdge, c
 			}
 			envs := components.Environments
 			for eid, env := range envs {
-				if strings.HasPrefix(eid, "go") {
+				if strings.HasPrefix(eid, "go") || resolved[env] {
 					continue
 				}
+				resolved[env] = true
 				deps := env.GetDependencies()
-				resolvedArtifacts, err := artifact.Materialize(ctx, e.External.ExpansionAddr, deps, "", tmpPath)
+				if len(deps) == 0 {
+					continue
+				}
+				endpoint, err := svcs.endpoint(ctx, e.External.ExpansionAddr)
+				if err != nil {
+					return nil, errors.WithContextf(err,
+						"resolving remote artifacts for env %v in edge %v", eid, e.Name())
+				}
+				resolvedArtifacts, err := materialize(ctx, e.External, endpoint, svcs.managed(e.External.ExpansionAddr), deps, tmpPath)
 				if err != nil {
 					return nil, errors.WithContextf(err,
 						"resolving remote artifacts for env %v in edge %v", eid, e.Name())
@@ -127,3 +158,14 @@ func ResolveArtifactsWithConfig(ctx context.Context, edges []*graph.MultiEdge, c
 	}
 	return paths, nil
 }
+
+// materialize retrieves the dependencies of a transform from its expansion
+// service, connected to as configured by the transform.
+func materialize(ctx context.Context, ext *graph.ExternalTransform, endpoint string, managed bool, deps []*pipepb.ArtifactInformation, dest string) ([]*pipepb.ArtifactInformation, error) {
+	ctx, conn, err := dialExpansionService(ctx, ext, endpoint, managed)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return artifact.MaterializeWithClient(ctx, jobpb.NewArtifactRetrievalServiceClient(conn), deps, dest)
+}
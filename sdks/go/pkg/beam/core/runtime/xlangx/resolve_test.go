@@ -0,0 +1,162 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xlangx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/artifact"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/protox"
+	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// fakeRetrievalService serves the same content for all artifacts, and counts
+// the resolution requests.
+type fakeRetrievalService struct {
+	jobpb.UnimplementedArtifactRetrievalServiceServer
+	content  []byte
+	resolves int32
+}
+
+func (f *fakeRetrievalService) ResolveArtifacts(ctx context.Context, req *jobpb.ResolveArtifactsRequest) (*jobpb.ResolveArtifactsResponse, error) {
+	atomic.AddInt32(&f.resolves, 1)
+	return &jobpb.ResolveArtifactsResponse{Replacements: req.GetArtifacts()}, nil
+}
+
+func (f *fakeRetrievalService) GetArtifact(req *jobpb.GetArtifactRequest, stream jobpb.ArtifactRetrievalService_GetArtifactServer) error {
+	return stream.Send(&jobpb.GetArtifactResponse{Data: f.content})
+}
+
+func serveRetrieval(t *testing.T, content string) (string, *fakeRetrievalService) {
+	t.Helper()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	fake := &fakeRetrievalService{content: []byte(content)}
+	jobpb.RegisterArtifactRetrievalServiceServer(srv, fake)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+	return l.Addr().String(), fake
+}
+
+func jarDependency(path, name, sum string) *pipepb.ArtifactInformation {
+	return &pipepb.ArtifactInformation{
+		TypeUrn:     artifact.URNFileArtifact,
+		TypePayload: protox.MustEncode(&pipepb.ArtifactFilePayload{Path: path, Sha256: sum}),
+		RoleUrn:     artifact.URNStagingTo,
+		RolePayload: protox.MustEncode(&pipepb.ArtifactStagingToRolePayload{StagedName: name}),
+	}
+}
+
+func externalEdge(addr string, envs map[string]*pipepb.Environment) *graph.MultiEdge {
+	return &graph.MultiEdge{
+		Op: graph.External,
+		External: &graph.ExternalTransform{
+			ExpansionAddr: addr,
+			Expanded: &graph.ExpandedTransform{
+				Components: &pipepb.Components{Environments: envs},
+			},
+		},
+	}
+}
+
+func TestResolveArtifactsWithConfig(t *testing.T) {
+	addr, fake := serveRetrieval(t, "jar")
+	hash := sha256.Sum256([]byte("jar"))
+	sha := hex.EncodeToString(hash[:])
+	goDep := jarDependency("/remote/worker", "worker", "")
+	java := &pipepb.Environment{Urn: "beam:env:docker:v1", Dependencies: []*pipepb.ArtifactInformation{jarDependency("/remote/service.jar", "service.jar", sha)}}
+	goEnv := &pipepb.Environment{Dependencies: []*pipepb.ArtifactInformation{goDep}}
+	empty := &pipepb.Environment{Urn: "beam:env:docker:v1"}
+	// Both transforms share the Java environment.
+	edges := []*graph.MultiEdge{
+		externalEdge(addr, map[string]*pipepb.Environment{"go": goEnv, "java": java}),
+		externalEdge(addr, map[string]*pipepb.Environment{"java": java, "empty": empty}),
+	}
+
+	dir := t.TempDir()
+	paths, err := ResolveArtifactsWithConfig(context.Background(), edges, ResolveConfig{
+		SdkPath:   "gs://bucket/xlang",
+		JoinFn:    func(path, name string) string { return path + "/" + name },
+		LocalPath: dir,
+	})
+	if err != nil {
+		t.Fatalf("ResolveArtifactsWithConfig failed: %v", err)
+	}
+
+	if got, want := atomic.LoadInt32(&fake.resolves), int32(1); got != want {
+		t.Errorf("resolved dependencies %v times, want %v", got, want)
+	}
+	local := filepath.Join(dir, "service.jar")
+	if diff := cmp.Diff(map[string]string{local: "gs://bucket/xlang/service.jar"}, paths); diff != "" {
+		t.Errorf("paths mismatch (-want +got):\n%s", diff)
+	}
+	if content, err := ioutil.ReadFile(local); err != nil || string(content) != "jar" {
+		t.Errorf("retrieved %v = %q, %v, want %q", local, content, err, "jar")
+	}
+
+	// The file payload is compared decoded, as its encoding is not canonical.
+	want := []*pipepb.ArtifactInformation{{
+		TypeUrn:     artifact.URNFileArtifact,
+		RoleUrn:     artifact.URNStagingTo,
+		RolePayload: protox.MustEncode(&pipepb.ArtifactStagingToRolePayload{StagedName: "service.jar"}),
+	}}
+	if diff := cmp.Diff(want, java.GetDependencies(), protocmp.Transform(), protocmp.IgnoreFields(&pipepb.ArtifactInformation{}, "type_payload")); diff != "" {
+		t.Errorf("Java dependencies mismatch (-want +got):\n%s", diff)
+	}
+	var got pipepb.ArtifactFilePayload
+	if err := proto.Unmarshal(java.GetDependencies()[0].GetTypePayload(), &got); err != nil {
+		t.Fatalf("invalid file payload: %v", err)
+	}
+	if got.GetPath() != "gs://bucket/xlang/service.jar" || got.GetSha256() != sha {
+		t.Errorf("file payload = %v, want path %q with the SHA256 of the content", &got, "gs://bucket/xlang/service.jar")
+	}
+	if !proto.Equal(goEnv.GetDependencies()[0], goDep) {
+		t.Errorf("Go dependencies = %v, want unchanged %v", goEnv.GetDependencies(), goDep)
+	}
+}
+
+func TestResolveArtifactsWithConfig_Unavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	java := &pipepb.Environment{Dependencies: []*pipepb.ArtifactInformation{jarDependency("/remote/service.jar", "service.jar", "")}}
+	edges := []*graph.MultiEdge{externalEdge(addr, map[string]*pipepb.Environment{"java": java})}
+
+	_, err = ResolveArtifactsWithConfig(context.Background(), edges, ResolveConfig{LocalPath: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "resolving remote artifacts") {
+		t.Errorf("ResolveArtifactsWithConfig = %v, want error resolving remote artifacts", err)
+	}
+}
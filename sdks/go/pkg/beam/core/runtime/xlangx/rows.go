@@ -0,0 +1,234 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xlangx
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx/schema"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// CheckRows returns an error if the given coder encodes elements as Rows whose
+// types cannot be represented by schemas, which other SDKs need to decode them.
+func CheckRows(c *coder.Coder) error {
+	if c == nil {
+		return nil
+	}
+	if c.Kind == coder.Row {
+		if _, err := schema.FromType(c.T.Type()); err != nil {
+			return errors.Wrapf(err, "type %v cannot be represented as a Row", c.T)
+		}
+	}
+	for _, cc := range c.Components {
+		if err := CheckRows(cc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewRowConverter returns a function converting structs of one type into
+// another, such as the Go type of the schema of a foreign SDK's Rows into the
+// struct type the user asked for. The fields of the types are matched by their
+// schema names, which are their "beam" tags or Go names. Names that differ only
+// in case and underscores match too, such as "user_id" and "UserID".
+//
+// Every field of the target type must match a field of the source type whose
+// type converts to its type, and the fields of the source type without a match
+// are dropped. Fields convert if their types are equal or both numeric, if one
+// is a pointer to a type that converts to the other, or if they are structs,
+// slices or maps of types that convert.
+func NewRowConverter(from, to reflect.Type) (func(interface{}) interface{}, error) {
+	conv, err := converter(from, to)
+	if err != nil {
+		return nil, errors.WithContextf(err, "converting Rows of %v to %v", from, to)
+	}
+	return func(v interface{}) interface{} {
+		return conv(reflect.ValueOf(v)).Interface()
+	}, nil
+}
+
+type convertFn func(reflect.Value) reflect.Value
+
+// converter returns the function converting values of one type into another.
+func converter(from, to reflect.Type) (convertFn, error) {
+	switch {
+	case from == to:
+		return func(v reflect.Value) reflect.Value { return v }, nil
+
+	case from.Kind() == reflect.Ptr && to.Kind() == reflect.Ptr:
+		elm, err := converter(from.Elem(), to.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) reflect.Value {
+			if v.IsNil() {
+				return reflect.Zero(to)
+			}
+			ret := reflect.New(to.Elem())
+			ret.Elem().Set(elm(v.Elem()))
+			return ret
+		}, nil
+
+	case from.Kind() == reflect.Ptr:
+		// Nil values of nullable fields convert to zero values.
+		elm, err := converter(from.Elem(), to)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) reflect.Value {
+			if v.IsNil() {
+				return reflect.Zero(to)
+			}
+			return elm(v.Elem())
+		}, nil
+
+	case to.Kind() == reflect.Ptr:
+		elm, err := converter(from, to.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) reflect.Value {
+			ret := reflect.New(to.Elem())
+			ret.Elem().Set(elm(v))
+			return ret
+		}, nil
+
+	case isNumeric(from) && isNumeric(to), from.Kind() == to.Kind() && from.ConvertibleTo(to) && isBasic(from):
+		return func(v reflect.Value) reflect.Value { return v.Convert(to) }, nil
+
+	case from.Kind() == reflect.Slice && to.Kind() == reflect.Slice:
+		elm, err := converter(from.Elem(), to.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) reflect.Value {
+			if v.IsNil() {
+				return reflect.Zero(to)
+			}
+			ret := reflect.MakeSlice(to, v.Len(), v.Len())
+			for i := 0; i < v.Len(); i++ {
+				ret.Index(i).Set(elm(v.Index(i)))
+			}
+			return ret
+		}, nil
+
+	case from.Kind() == reflect.Map && to.Kind() == reflect.Map:
+		key, err := converter(from.Key(), to.Key())
+		if err != nil {
+			return nil, err
+		}
+		elm, err := converter(from.Elem(), to.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) reflect.Value {
+			if v.IsNil() {
+				return reflect.Zero(to)
+			}
+			ret := reflect.MakeMapWithSize(to, v.Len())
+			iter := v.MapRange()
+			for iter.Next() {
+				ret.SetMapIndex(key(iter.Key()), elm(iter.Value()))
+			}
+			return ret
+		}, nil
+
+	case from.Kind() == reflect.Struct && to.Kind() == reflect.Struct:
+		return structConverter(from, to)
+
+	default:
+		return nil, errors.Errorf("cannot convert %v to %v", from, to)
+	}
+}
+
+// structConverter returns the function converting structs of one type into
+// another by the names of their fields.
+func structConverter(from, to reflect.Type) (convertFn, error) {
+	exact, loose := make(map[string]int), make(map[string]int)
+	for i := 0; i < from.NumField(); i++ {
+		if name, ok := fieldName(from.Field(i)); ok {
+			exact[name] = i
+			loose[normalize(name)] = i
+		}
+	}
+
+	type field struct {
+		from, to int
+		convert  convertFn
+	}
+	var fields []field
+	for i := 0; i < to.NumField(); i++ {
+		f := to.Field(i)
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		j, ok := exact[name]
+		if !ok {
+			if j, ok = loose[normalize(name)]; !ok {
+				return nil, errors.Errorf("field %v of %v has no matching field in %v", f.Name, to, from)
+			}
+		}
+		conv, err := converter(from.Field(j).Type, f.Type)
+		if err != nil {
+			return nil, errors.WithContextf(err, "converting field %v of %v to field %v of %v", from.Field(j).Name, from, f.Name, to)
+		}
+		fields = append(fields, field{from: j, to: i, convert: conv})
+	}
+	return func(v reflect.Value) reflect.Value {
+		ret := reflect.New(to).Elem()
+		for _, f := range fields {
+			ret.Field(f.to).Set(f.convert(v.Field(f.from)))
+		}
+		return ret
+	}, nil
+}
+
+// fieldName returns the schema name of an exported struct field.
+func fieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	if tag := f.Tag.Get("beam"); tag != "" {
+		if name := strings.Split(tag, ",")[0]; name != "" {
+			return name, true
+		}
+	}
+	return f.Name, true
+}
+
+// normalize returns the field name without case and underscores.
+func normalize(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+func isNumeric(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func isBasic(t reflect.Type) bool {
+	return t.Kind() == reflect.String || t.Kind() == reflect.Bool
+}
@@ -0,0 +1,141 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xlangx
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/google/go-cmp/cmp"
+)
+
+// javaOrder is the Go type of the schema of a Java SDK's Rows.
+type javaOrder struct {
+	User_id  int32    `beam:"user_id"`
+	Discount *float64 `beam:"discount"`
+	Total    float64  `beam:"total"`
+	Items    []javaItem
+	Labels   map[string]int32 `beam:"labels"`
+	Comment  *string          `beam:"comment"`
+}
+
+type javaItem struct {
+	Sku   string `beam:"sku"`
+	Count int32  `beam:"count"`
+}
+
+type order struct {
+	Total    float64
+	UserID   int64
+	Discount float64
+	Comment  *string `beam:"comment"`
+	Items    []item
+	Labels   map[string]int64
+}
+
+type item struct {
+	Count int
+	SKU   string
+}
+
+func TestNewRowConverter(t *testing.T) {
+	conv, err := NewRowConverter(reflect.TypeOf(javaOrder{}), reflect.TypeOf(order{}))
+	if err != nil {
+		t.Fatalf("NewRowConverter failed: %v", err)
+	}
+
+	comment := "gift"
+	tests := []struct {
+		name string
+		in   javaOrder
+		want order
+	}{
+		{
+			"Full",
+			javaOrder{
+				User_id: 7, Discount: func() *float64 { d := 0.5; return &d }(), Total: 10.5,
+				Items:   []javaItem{{Sku: "a", Count: 2}},
+				Labels:  map[string]int32{"x": 1},
+				Comment: &comment,
+			},
+			order{
+				Total: 10.5, UserID: 7, Discount: 0.5, Comment: &comment,
+				Items:  []item{{Count: 2, SKU: "a"}},
+				Labels: map[string]int64{"x": 1},
+			},
+		},
+		{
+			"Nulls",
+			javaOrder{User_id: 1, Total: 2},
+			order{Total: 2, UserID: 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, conv(test.in)); diff != "" {
+				t.Errorf("converted row mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestNewRowConverter_DropsFields(t *testing.T) {
+	type total struct {
+		Total float32 `beam:"total"`
+	}
+	conv, err := NewRowConverter(reflect.TypeOf(javaOrder{}), reflect.TypeOf(total{}))
+	if err != nil {
+		t.Fatalf("NewRowConverter failed: %v", err)
+	}
+	if got, want := conv(javaOrder{User_id: 1, Total: 2.5}), (total{Total: 2.5}); got != want {
+		t.Errorf("converted row = %v, want %v", got, want)
+	}
+}
+
+func TestNewRowConverter_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		to   interface{}
+	}{
+		{"MissingField", struct{ Customer string }{}},
+		{"BadFieldType", struct{ Total string }{}},
+		{"BadNestedType", struct{ Items []struct{ Sku bool } }{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewRowConverter(reflect.TypeOf(javaOrder{}), reflect.TypeOf(test.to)); err == nil {
+				t.Errorf("NewRowConverter to %T succeeded, want error", test.to)
+			}
+		})
+	}
+}
+
+func TestCheckRows(t *testing.T) {
+	good := coder.NewR(typex.New(reflect.TypeOf(order{})))
+	if err := CheckRows(coder.NewKV([]*coder.Coder{coder.NewString(), good})); err != nil {
+		t.Errorf("CheckRows(%v) failed: %v", good, err)
+	}
+
+	type bad struct {
+		Any interface{}
+	}
+	badCoder := coder.NewR(typex.New(reflect.TypeOf(bad{})))
+	if err := CheckRows(coder.NewKV([]*coder.Coder{coder.NewString(), badCoder})); err == nil {
+		t.Errorf("CheckRows(%v) succeeded, want error", badCoder)
+	}
+}
@@ -0,0 +1,163 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xlangx
+
+import (
+	"context"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx/expansionx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+// Expansion addresses with the following prefixes make cross-language
+// transforms start their expansion service, rather than connect to a running
+// one. The service is stopped once the transform has been expanded, and
+// started again to resolve the artifacts of the transform when the pipeline
+// is submitted.
+const (
+	// autoJavaPrefix is followed by the Gradle target of a Beam Java
+	// expansion service jar, such as "sdks:java:io:expansion-service:shadowJar".
+	// The jar of the version of this SDK is downloaded and cached, unless the
+	// target is followed by "@" and another version of Beam.
+	autoJavaPrefix = "autojava:"
+	// jarPrefix is followed by the path of a local expansion service jar.
+	jarPrefix = "jar:"
+	// containerPrefix is followed by a container image, which must serve
+	// the expansion service on ContainerPort.
+	containerPrefix = "container:"
+	// pythonPrefix is followed by the path of a Python requirements file,
+	// which may be empty. The Python expansion service runs in a virtual
+	// environment with Beam and the requirements installed.
+	pythonPrefix = "python:"
+)
+
+// ContainerPort is the port of the containers of "container:" expansion
+// addresses that their expansion service is served on.
+var ContainerPort = 8097
+
+// AutoJava returns the expansion address of the Beam Java expansion service
+// jar built by the given Gradle target, such as
+// "sdks:java:io:expansion-service:shadowJar". The jar released for the version
+// of this SDK is downloaded, started for expanding transforms and stopped
+// again. Development versions of the SDK must use the address of a running
+// service, or Jar, instead.
+func AutoJava(gradleTarget string) string {
+	return autoJavaPrefix + gradleTarget
+}
+
+// Jar returns the expansion address of the expansion service jar at the given
+// local path, which is started for expanding transforms and stopped again.
+func Jar(path string) string {
+	return jarPrefix + path
+}
+
+// Container returns the expansion address of the given container image, which
+// is started for expanding transforms and stopped again.
+func Container(image string) string {
+	return containerPrefix + image
+}
+
+// Python returns the expansion address of the Python expansion service, which
+// runs in a virtual environment with the packages of the given requirements
+// file installed, if any. It expands Python transforms by their fully
+// qualified names, and is started for expanding transforms and stopped again.
+func Python(requirementsFile string) string {
+	return pythonPrefix + requirementsFile
+}
+
+// newService returns the expansion service to start for the given address,
+// or nil if the address is the endpoint of a running service. It is a
+// variable so tests can substitute services that need neither java nor
+// docker.
+var newService = func(addr string) (*expansionx.Service, error) {
+	switch {
+	case addr == "":
+		return nil, errors.Errorf("no expansion address, want the endpoint of a running expansion service, "+
+			"or an address prefixed by %q, %q, %q or %q", autoJavaPrefix, jarPrefix, containerPrefix, pythonPrefix)
+	case strings.HasPrefix(addr, autoJavaPrefix):
+		target, version := strings.TrimPrefix(addr, autoJavaPrefix), core.SdkVersion
+		if i := strings.LastIndex(target, "@"); i >= 0 {
+			target, version = target[:i], target[i+1:]
+		}
+		jar, err := expansionx.GetBeamJar(target, version)
+		if err != nil {
+			return nil, err
+		}
+		return expansionx.NewJarService(jar)
+	case strings.HasPrefix(addr, jarPrefix):
+		return expansionx.NewJarService(strings.TrimPrefix(addr, jarPrefix))
+	case strings.HasPrefix(addr, containerPrefix):
+		return expansionx.NewContainerService(strings.TrimPrefix(addr, containerPrefix), ContainerPort)
+	case strings.HasPrefix(addr, pythonPrefix):
+		reqs := strings.TrimPrefix(addr, pythonPrefix)
+		interpreter, err := expansionx.PythonEnv(reqs, core.SdkVersion)
+		if err != nil {
+			return nil, err
+		}
+		return expansionx.NewPythonService(interpreter, reqs)
+	default:
+		return nil, nil
+	}
+}
+
+// services holds the expansion services started for expansion addresses, so
+// that several transforms of the same address share a service.
+type services struct {
+	running map[string]*expansionx.Service
+}
+
+// endpoint returns the endpoint of the expansion service of the given
+// address, starting it if needed.
+func (s *services) endpoint(ctx context.Context, addr string) (string, error) {
+	if svc, ok := s.running[addr]; ok {
+		return svc.Endpoint(), nil
+	}
+	svc, err := newService(addr)
+	if err != nil {
+		return "", errors.WithContextf(err, "starting expansion service for %v", addr)
+	}
+	if svc == nil {
+		return addr, nil
+	}
+	log.Infof(ctx, "Starting expansion service for %v at %v", addr, svc.Endpoint())
+	if err := svc.Start(ctx); err != nil {
+		return "", errors.WithContextf(err, "starting expansion service for %v", addr)
+	}
+	if s.running == nil {
+		s.running = make(map[string]*expansionx.Service)
+	}
+	s.running[addr] = svc
+	return svc.Endpoint(), nil
+}
+
+// managed returns whether a service has been started for the given address.
+func (s *services) managed(addr string) bool {
+	_, ok := s.running[addr]
+	return ok
+}
+
+// stop stops all started expansion services.
+func (s *services) stop(ctx context.Context) {
+	for addr, svc := range s.running {
+		if err := svc.Stop(); err != nil {
+			log.Warnf(ctx, "Failed to stop expansion service for %v: %v", addr, err)
+		}
+		delete(s.running, addr)
+	}
+}
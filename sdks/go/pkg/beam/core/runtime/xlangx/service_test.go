@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xlangx
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAddresses(t *testing.T) {
+	tests := []struct {
+		got, want string
+	}{
+		{AutoJava("sdks:java:io:expansion-service:shadowJar"), "autojava:sdks:java:io:expansion-service:shadowJar"},
+		{Jar("/tmp/service.jar"), "jar:/tmp/service.jar"},
+		{Container("apache/beam_java8_sdk:2.32.0"), "container:apache/beam_java8_sdk:2.32.0"},
+		{Python("requirements.txt"), "python:requirements.txt"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("address = %q, want %q", test.got, test.want)
+		}
+	}
+}
+
+func TestServices_Running(t *testing.T) {
+	ctx := context.Background()
+	var svcs services
+	defer svcs.stop(ctx)
+	got, err := svcs.endpoint(ctx, "localhost:8097")
+	if err != nil {
+		t.Fatalf("endpoint failed: %v", err)
+	}
+	if want := "localhost:8097"; got != want {
+		t.Errorf("endpoint = %q, want %q", got, want)
+	}
+	if len(svcs.running) != 0 {
+		t.Errorf("started %v services for the endpoint of a running service, want none", len(svcs.running))
+	}
+}
+
+func TestServices_Errors(t *testing.T) {
+	tests := []struct {
+		addr, want string
+	}{
+		{"", "no expansion address"},
+		// Jars of development versions are not released.
+		{AutoJava("sdks:java:io:expansion-service:shadowJar") + "@2.33.0.dev", "development version"},
+		{AutoJava("sdks:java:io:expansion-service:runExpansionService") + "@2.32.0", "invalid Gradle target"},
+	}
+	ctx := context.Background()
+	for _, test := range tests {
+		var svcs services
+		got, err := svcs.endpoint(ctx, test.addr)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("endpoint(%q) = %q, %v, want error containing %q", test.addr, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+// Package state contains the interfaces of the state of stateful DoFns, which
+// is kept separately for each key and window of their input.
+//
+// A DoFn is stateful if its ProcessElement method takes a Provider, which
+// must precede its main input, which must be a KV. The state cells of the
+// DoFn are the Value and Bag fields of its struct, which declare the type of
+// their values, from which their coders are inferred:
+//
+//	type countFn struct {
+//		Count state.Value
+//	}
+//
+//	func (fn *countFn) ProcessElement(p state.Provider, key string, _ int) (string, int, error) {
+//		n, _, err := fn.Count.Read(p)
+//		...
+//	}
+//
+//	beam.ParDo(s, &countFn{Count: state.Value{ID: "count", Type: reflectx.Int}}, keyed)
+//
+// Warning: State is only supported by the direct runner.
+package state
+
+import "reflect"
+
+// Provider reads and writes the state of the key and window of the element
+// being processed. Values are encoded with the coder of the type of their
+// cell, so the values read are copies of those written.
+type Provider interface {
+	// ReadValue returns the value of the Value with the given ID, and whether
+	// it is set.
+	ReadValue(id string) (interface{}, bool, error)
+	// WriteValue sets the value of the Value with the given ID.
+	WriteValue(id string, v interface{}) error
+	// ReadBag returns the values added to the Bag with the given ID.
+	ReadBag(id string) ([]interface{}, error)
+	// AddBag adds a value to the Bag with the given ID.
+	AddBag(id string, v interface{}) error
+	// Clear clears the state with the given ID.
+	Clear(id string) error
+}
+
+// Kind is the kind of a state cell.
+type Kind int
+
+const (
+	// ValueKind cells are Values.
+	ValueKind Kind = iota
+	// BagKind cells are Bags.
+	BagKind
+)
+
+func (k Kind) String() string {
+	switch k {
+	case ValueKind:
+		return "Value"
+	case BagKind:
+		return "Bag"
+	default:
+		return "Unknown"
+	}
+}
+
+// Cell describes a state cell of a DoFn.
+type Cell struct {
+	ID   string
+	Kind Kind
+	Type reflect.Type
+}
+
+// Cells returns the state cells of a DoFn, which are the Value and Bag fields
+// of its struct, if it is one, including those of its embedded structs.
+func Cells(fn interface{}) []Cell {
+	return appendCells(nil, reflect.ValueOf(fn))
+}
+
+func appendCells(cells []Cell, v reflect.Value) []Cell {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return cells
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanInterface() {
+			continue
+		}
+		switch s := f.Interface().(type) {
+		case Value:
+			cells = append(cells, Cell{ID: s.ID, Kind: ValueKind, Type: s.Type})
+		case Bag:
+			cells = append(cells, Cell{ID: s.ID, Kind: BagKind, Type: s.Type})
+		default:
+			if v.Type().Field(i).Anonymous {
+				cells = appendCells(cells, f)
+			}
+		}
+	}
+	return cells
+}
+
+// Value is a single value of state, such as a running count. The ID
+// distinguishes it from the other state of the DoFn, and Type is the type of
+// its value, which is only needed to construct the pipeline.
+type Value struct {
+	ID   string
+	Type reflect.Type `json:"-"`
+}
+
+// Read returns the value, and whether it is set.
+func (s Value) Read(p Provider) (interface{}, bool, error) {
+	return p.ReadValue(s.ID)
+}
+
+// Write sets the value.
+func (s Value) Write(p Provider, v interface{}) error {
+	return p.WriteValue(s.ID, v)
+}
+
+// Clear unsets the value.
+func (s Value) Clear(p Provider) error {
+	return p.Clear(s.ID)
+}
+
+// Bag is an unordered collection of values of state, such as the elements
+// buffered until a timer fires. The ID distinguishes it from the other state
+// of the DoFn, and Type is the type of its values, which is only needed to
+// construct the pipeline.
+type Bag struct {
+	ID   string
+	Type reflect.Type `json:"-"`
+}
+
+// Read returns the values added to the bag.
+func (s Bag) Read(p Provider) ([]interface{}, error) {
+	return p.ReadBag(s.ID)
+}
+
+// Add adds a value to the bag.
+func (s Bag) Add(p Provider, v interface{}) error {
+	return p.AddBag(s.ID, v)
+}
+
+// Clear removes the values of the bag.
+func (s Bag) Clear(p Provider) error {
+	return p.Clear(s.ID)
+}
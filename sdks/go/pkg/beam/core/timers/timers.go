@@ -0,0 +1,123 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package timers contains the interfaces of the timers of stateful DoFns,
+// which are set separately for each key and window of their input.
+//
+// A DoFn sets timers if its ProcessElement method takes a Provider, which
+// must precede its main input, which must be a KV. When a timer fires, the
+// OnTimer method of the DoFn is called with the key, and the family of the
+// timer:
+//
+//	type bufferFn struct {
+//		Flush timers.EventTime
+//	}
+//
+//	func (fn *bufferFn) ProcessElement(w beam.Window, p timers.Provider, key string, _ int, emit func(string)) {
+//		fn.Flush.Set(p, w.MaxTimestamp())
+//	}
+//
+//	func (fn *bufferFn) OnTimer(p timers.Provider, key string, family string, emit func(string)) {
+//		...
+//	}
+//
+// OnTimer may also take a context.Context, a beam.EventTime, which is the
+// timestamp of the elements it emits, a state.Provider and a Provider, in the
+// same order as ProcessElement, and emits to the same outputs.
+//
+// Warning: Timers are only supported by the direct runner.
+package timers
+
+import (
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+)
+
+// Domain is the time domain of a timer.
+type Domain int
+
+const (
+	// EventTimeDomain timers fire once the watermark passes their firing
+	// time, and emit elements timestamped with it.
+	EventTimeDomain Domain = iota
+	// ProcessingTimeDomain timers fire once processing time passes their
+	// firing time, and emit elements timestamped with the element that set
+	// them.
+	ProcessingTimeDomain
+)
+
+func (d Domain) String() string {
+	switch d {
+	case EventTimeDomain:
+		return "EventTime"
+	case ProcessingTimeDomain:
+		return "ProcessingTime"
+	default:
+		return "Unknown"
+	}
+}
+
+// Provider sets the timers of the key and window of the element being
+// processed. Each key and window has at most one timer of each family and
+// domain.
+type Provider interface {
+	// Set sets the timer of the family to fire at the given time, replacing
+	// the time it was set to before, if any.
+	Set(family string, domain Domain, firing mtime.Time)
+	// Clear unsets the timer of the family.
+	Clear(family string, domain Domain)
+	// ProcessingTime returns the current processing time of the runner,
+	// which may differ from the wall time, such as in tests.
+	ProcessingTime() mtime.Time
+}
+
+// EventTime is a timer firing once the watermark passes the set time. The
+// family distinguishes it from the other timers of the DoFn.
+type EventTime struct {
+	Family string
+}
+
+// Set sets the timer to fire at the given time.
+func (t EventTime) Set(p Provider, firing mtime.Time) {
+	p.Set(t.Family, EventTimeDomain, firing)
+}
+
+// Clear unsets the timer.
+func (t EventTime) Clear(p Provider) {
+	p.Clear(t.Family, EventTimeDomain)
+}
+
+// ProcessingTime is a timer firing once processing time passes the set time.
+// The family distinguishes it from the other timers of the DoFn.
+type ProcessingTime struct {
+	Family string
+}
+
+// Set sets the timer to fire at the given time.
+func (t ProcessingTime) Set(p Provider, firing mtime.Time) {
+	p.Set(t.Family, ProcessingTimeDomain, firing)
+}
+
+// SetAfter sets the timer to fire once the given duration of processing time
+// has passed.
+func (t ProcessingTime) SetAfter(p Provider, d time.Duration) {
+	p.Set(t.Family, ProcessingTimeDomain, p.ProcessingTime().Add(d))
+}
+
+// Clear unsets the timer.
+func (t ProcessingTime) Clear(p Provider) {
+	p.Clear(t.Family, ProcessingTimeDomain)
+}
@@ -17,6 +17,7 @@ package typex
 
 import (
 	"reflect"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 )
@@ -36,6 +37,8 @@ var (
 	EventTimeType = reflect.TypeOf((*EventTime)(nil)).Elem()
 	WindowType    = reflect.TypeOf((*Window)(nil)).Elem()
 
+	BundleFinalizationType = reflect.TypeOf((*BundleFinalization)(nil)).Elem()
+
 	KVType            = reflect.TypeOf((*KV)(nil)).Elem()
 	CoGBKType         = reflect.TypeOf((*CoGBK)(nil)).Elem()
 	WindowedValueType = reflect.TypeOf((*WindowedValue)(nil)).Elem()
@@ -64,6 +67,16 @@ type Window interface {
 	Equals(o Window) bool
 }
 
+// BundleFinalization allows a DoFn to register callbacks that run once the
+// runner has durably committed the output of the bundle, such as to
+// acknowledge messages to an external system only after they are processed.
+type BundleFinalization interface {
+	// RegisterCallback registers a callback to run once the bundle is
+	// committed, unless the bundle is not committed within the given
+	// duration.
+	RegisterCallback(time.Duration, func() error)
+}
+
 // KV, CoGBK, WindowedValue represent composite generic types. They are not used
 // directly in user code signatures, but only in FullTypes.
 
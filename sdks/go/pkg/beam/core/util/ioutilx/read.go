@@ -44,6 +44,31 @@ func ReadN(r io.Reader, n int) ([]byte, error) {
 	}
 }
 
+// Lender is implemented by readers that can lend their buffered bytes to
+// decoders without copying them.
+type Lender interface {
+	io.Reader
+
+	// Lend returns the next n bytes of the reader and advances past them, if
+	// they are buffered contiguously. Otherwise, it returns false and reads
+	// nothing. The returned bytes alias the buffer of the reader, so they
+	// must not be modified, and must not be used after the next call on the
+	// reader.
+	Lend(n int) ([]byte, bool)
+}
+
+// ReadNBorrowed reads exactly N bytes from the reader, like ReadN, but
+// without copying them if the reader lends them. The returned bytes must not
+// be modified, and must not be used after the next read from the reader.
+func ReadNBorrowed(r io.Reader, n int) ([]byte, error) {
+	if l, ok := r.(Lender); ok {
+		if b, ok := l.Lend(n); ok {
+			return b, nil
+		}
+	}
+	return ReadN(r, n)
+}
+
 // ReadNBufUnsafe reads exactly cap(buf) bytes from the reader. Fails otherwise.
 // Uses the unsafe package unsafely to convince escape analysis that the passed
 // in []byte doesn't escape this function through the io.Reader.
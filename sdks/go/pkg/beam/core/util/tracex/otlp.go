@@ -0,0 +1,285 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracex
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+const (
+	// maxQueuedSpans is the number of ended spans buffered for export, after
+	// which spans are dropped.
+	maxQueuedSpans = 2048
+	// maxBatchSize is the maximum number of spans per export request.
+	maxBatchSize = 512
+
+	exportTimeout = 30 * time.Second
+)
+
+// exportPeriod is how often the ended spans are exported.
+var exportPeriod = 5 * time.Second
+
+// Exporter exports ended spans in batches to an OTLP/HTTP collector, as
+// JSON encoded ExportTraceServiceRequests.
+type Exporter struct {
+	url      string
+	resource []keyValue
+	client   *http.Client
+
+	spans    chan *Span
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	dropped  int64 // accessed atomically
+}
+
+// NewExporter returns an exporter sending spans to the OTLP/HTTP collector at
+// the given endpoint, such as http://localhost:4318, whose spans come from
+// the resource with the given attributes, such as service.name. Spans are
+// exported until Shutdown is called.
+func NewExporter(ctx context.Context, endpoint string, resource ...Attribute) *Exporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	e := &Exporter{
+		url:      url,
+		resource: keyValues(resource),
+		client:   &http.Client{Timeout: exportTimeout},
+		spans:    make(chan *Span, maxQueuedSpans),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.run(ctx)
+	return e
+}
+
+// export queues the ended span for export, or drops it if the queue is full.
+func (e *Exporter) export(s *Span) {
+	select {
+	case e.spans <- s:
+	default:
+		atomic.AddInt64(&e.dropped, 1)
+	}
+}
+
+// Shutdown exports the queued spans, and stops exporting spans. It returns
+// once the spans are exported, or the context is done.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Exporter) run(ctx context.Context) {
+	defer close(e.done)
+	ticker := time.NewTicker(exportPeriod)
+	defer ticker.Stop()
+
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(ctx, batch); err != nil {
+			log.Warnf(ctx, "failed to export %d trace spans to %v: %v", len(batch), e.url, err)
+		}
+		batch = nil
+		if n := atomic.SwapInt64(&e.dropped, 0); n > 0 {
+			log.Warnf(ctx, "dropped %d trace spans, since they ended faster than exported", n)
+		}
+	}
+	for {
+		select {
+		case s := <-e.spans:
+			if batch = append(batch, s); len(batch) >= maxBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case s := <-e.spans:
+					if batch = append(batch, s); len(batch) >= maxBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts the spans to the collector.
+func (e *Exporter) send(ctx context.Context, spans []*Span) error {
+	req := exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: resource{Attributes: e.resource},
+		ScopeSpans: []scopeSpans{{
+			Scope: scope{Name: "github.com/apache/beam/sdks/go"},
+			Spans: encodeSpans(spans),
+		}},
+	}}}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	hreq, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hreq = hreq.WithContext(ctx)
+	hreq.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("collector responded %v: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// The JSON encoding of the OTLP ExportTraceServiceRequest, whose ids are hex
+// encoded, and 64 bit integers are strings.
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+const (
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func encodeSpans(spans []*Span) []span {
+	ret := make([]span, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		enc := span{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        keyValues(s.attrs),
+		}
+		if s.parentID != [8]byte{} {
+			enc.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != nil {
+			enc.Status = status{Code: statusCodeError, Message: s.err.Error()}
+		}
+		s.mu.Unlock()
+		ret = append(ret, enc)
+	}
+	return ret
+}
+
+func keyValues(attrs []Attribute) []keyValue {
+	var ret []keyValue
+	for _, a := range attrs {
+		var v anyValue
+		switch val := a.Value.(type) {
+		case string:
+			v.StringValue = &val
+		case int:
+			i := strconv.Itoa(val)
+			v.IntValue = &i
+		case int64:
+			i := strconv.FormatInt(val, 10)
+			v.IntValue = &i
+		case float64:
+			v.DoubleValue = &val
+		case bool:
+			v.BoolValue = &val
+		default:
+			str := fmt.Sprint(val)
+			v.StringValue = &str
+		}
+		ret = append(ret, keyValue{Key: a.Key, Value: v})
+	}
+	return ret
+}
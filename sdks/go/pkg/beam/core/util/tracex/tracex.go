@@ -0,0 +1,130 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracex records trace spans of the work of Go workers, such as their
+// bundles, transforms and data and state operations, and exports them with
+// the OpenTelemetry protocol (OTLP) over HTTP, so that pipeline latency can be
+// analyzed in standard tracing backends.
+//
+// Spans are only recorded in contexts with an Exporter. Otherwise, Start
+// returns a nil span, whose methods are no-ops.
+package tracex
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+type contextKey string
+
+const (
+	exporterKey contextKey = "beam:trace_exporter"
+	spanKey     contextKey = "beam:trace_span"
+)
+
+// WithExporter returns a context whose spans are exported by the exporter.
+func WithExporter(ctx context.Context, e *Exporter) context.Context {
+	return context.WithValue(ctx, exporterKey, e)
+}
+
+// Attribute is a key and value describing a span. Values are strings,
+// integers, floats or booleans.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int64 returns an integer attribute.
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span is an operation of a trace, once started until it's ended.
+type Span struct {
+	e        *Exporter
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte // zero for root spans
+	name     string
+	start    time.Time
+
+	end   time.Time   // protected by mu
+	attrs []Attribute // protected by mu
+	err   error       // protected by mu
+	ended bool        // protected by mu
+	mu    sync.Mutex
+}
+
+// Start starts a span with the given name, which is a child of the span of
+// the context, if any. Returns a context holding the span, for its children.
+// If the context has no exporter, it returns the context and a nil span.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	e, ok := ctx.Value(exporterKey).(*Exporter)
+	if !ok || e == nil {
+		return ctx, nil
+	}
+	s := &Span{e: e, name: name, start: time.Now(), attrs: attrs}
+	if parent, ok := ctx.Value(spanKey).(*Span); ok {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey, s), s
+}
+
+// SetAttributes adds attributes to the span, unless it has ended.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.attrs = append(s.attrs, attrs...)
+	}
+}
+
+// End ends the span, which failed if the error is non-nil, and exports it.
+// Only the first call ends the span.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.err = err
+	s.mu.Unlock()
+
+	s.e.export(s)
+}
@@ -0,0 +1,107 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestStart_noExporter(t *testing.T) {
+	ctx := context.Background()
+	got, s := Start(ctx, "span", String("key", "value"))
+	if s != nil || got != ctx {
+		t.Errorf("Start() = %v, %v, want the context and a nil span", got, s)
+	}
+	// Nil spans are no-ops.
+	s.SetAttributes(Int64("count", 1))
+	s.End(nil)
+}
+
+func TestExporter(t *testing.T) {
+	var mu sync.Mutex
+	var reqs []exportRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("export request to %v of %v, want JSON to /v1/traces", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var req exportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid export request: %v", err)
+		}
+		mu.Lock()
+		reqs = append(reqs, req)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	e := NewExporter(ctx, srv.URL, String("service.name", "test"))
+	ctx = WithExporter(ctx, e)
+
+	rootCtx, root := Start(ctx, "root", String("key", "value"))
+	_, child := Start(rootCtx, "child")
+	child.SetAttributes(Int64("count", 3), Bool("ok", false))
+	child.End(errors.New("failed"))
+	child.End(nil) // Only the first End counts.
+	root.End(nil)
+
+	if err := e.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	var spans []span
+	for _, req := range reqs {
+		for _, rs := range req.ResourceSpans {
+			if len(rs.Resource.Attributes) != 1 || *rs.Resource.Attributes[0].Value.StringValue != "test" {
+				t.Errorf("resource = %+v, want service.name test", rs.Resource)
+			}
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2: %+v", len(spans), spans)
+	}
+	c, r := spans[0], spans[1]
+	if c.Name != "child" || r.Name != "root" {
+		t.Fatalf("exported spans %v, %v, want child, root", c.Name, r.Name)
+	}
+	if c.TraceID != r.TraceID || len(r.TraceID) != 32 {
+		t.Errorf("trace ids = %v, %v, want the same 16 byte id", c.TraceID, r.TraceID)
+	}
+	if c.ParentSpanID != r.SpanID || r.ParentSpanID != "" || len(r.SpanID) != 16 {
+		t.Errorf("child parent = %v, root span = %v, root parent = %v, want the root as parent of the child", c.ParentSpanID, r.SpanID, r.ParentSpanID)
+	}
+	if c.Status != (status{Code: statusCodeError, Message: "failed"}) || r.Status != (status{}) {
+		t.Errorf("statuses = %+v, %+v, want child failed", c.Status, r.Status)
+	}
+	if len(c.Attributes) != 2 || *c.Attributes[0].Value.IntValue != "3" || *c.Attributes[1].Value.BoolValue {
+		t.Errorf("child attributes = %+v, want count 3 and ok false", c.Attributes)
+	}
+	if len(r.Attributes) != 1 || *r.Attributes[0].Value.StringValue != "value" {
+		t.Errorf("root attributes = %+v, want key value", r.Attributes)
+	}
+	if r.StartTimeUnixNano > r.EndTimeUnixNano && len(r.StartTimeUnixNano) == len(r.EndTimeUnixNano) {
+		t.Errorf("root started at %v after it ended at %v", r.StartTimeUnixNano, r.EndTimeUnixNano)
+	}
+}
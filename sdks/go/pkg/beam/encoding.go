@@ -287,7 +287,8 @@ func timeDec(reflect.Type) (func(io.Reader) (interface{}, error), error) {
 		if err := coder.ReadSimpleRowHeader(1, r); err != nil {
 			return nil, errors.Wrap(err, "decoding time.Time schema override")
 		}
-		data, err := coder.DecodeBytes(r)
+		// The text is parsed right away, so it can be borrowed.
+		data, err := coder.DecodeBytesBorrowed(r)
 		if err != nil {
 			return nil, errors.Wrap(err, "retrieving time data: %v")
 		}
@@ -201,6 +201,11 @@ type EventTime = typex.EventTime
 // be a part of multiple windows, based on the element's event time.
 type Window = typex.Window
 
+// BundleFinalization allows a DoFn to register callbacks that run once the
+// output of the bundle is committed by the runner. DoFns take it as a
+// parameter of their StartBundle, ProcessElement or FinishBundle methods.
+type BundleFinalization = typex.BundleFinalization
+
 // These are the reflect.Type instances of the universal types, which are used
 // when binding actual types to "generic" DoFns that use Universal Types.
 var (
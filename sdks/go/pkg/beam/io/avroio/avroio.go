@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 	"github.com/linkedin/goavro"
@@ -94,6 +95,7 @@ func (f *avroReadFn) ProcessElement(ctx context.Context, filename string, emit f
 		return
 	}
 	defer fd.Close()
+	metrics.AddLineageSource(ctx, filesystem.LineageFQN(filename))
 
 	ar, err := goavro.NewOCFReader(fd)
 	if err != nil {
@@ -162,6 +164,7 @@ func (w *writeAvroFn) ProcessElement(ctx context.Context, _ int, lines func(*str
 	if err != nil {
 		return
 	}
+	metrics.AddLineageSink(ctx, filesystem.LineageFQN(w.Filename))
 
 	defer fd.Close()
 
@@ -27,6 +27,7 @@ import (
 
 	"cloud.google.com/go/bigquery"
 	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	bq "google.golang.org/api/bigquery/v2"
@@ -63,6 +64,12 @@ func (qn QualifiedTableName) String() string {
 	return fmt.Sprintf("%v:%v.%v", qn.Project, qn.Dataset, qn.Table)
 }
 
+// lineageFQN returns the fully qualified name of the table for lineage
+// metrics.
+func (qn QualifiedTableName) lineageFQN() string {
+	return metrics.LineageFQN("bigquery", qn.Project, qn.Dataset, qn.Table)
+}
+
 // NewQualifiedTableName parses "<project>:<dataset>.<table>" into a QualifiedTableName.
 func NewQualifiedTableName(s string) (QualifiedTableName, error) {
 	c := strings.LastIndex(s, ":")
@@ -151,7 +158,26 @@ func (f *queryFn) ProcessElement(ctx context.Context, _ []byte, emit func(beam.X
 		q.UseLegacySQL = true
 	}
 
-	it, err := q.Read(ctx)
+	job, err := q.Run(ctx)
+	if err != nil {
+		return err
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	if err := status.Err(); err != nil {
+		return err
+	}
+	if status.Statistics != nil {
+		if stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok {
+			for _, t := range stats.ReferencedTables {
+				qn := QualifiedTableName{Project: t.ProjectID, Dataset: t.DatasetID, Table: t.TableID}
+				metrics.AddLineageSource(ctx, qn.lineageFQN())
+			}
+		}
+	}
+	it, err := job.Read(ctx)
 	if err != nil {
 		return err
 	}
@@ -268,6 +294,7 @@ func (f *writeFn) ProcessElement(ctx context.Context, _ int, iter func(*beam.X)
 			return err
 		}
 	}
+	metrics.AddLineageSink(ctx, f.Table.lineageFQN())
 
 	var data []reflect.Value
 	// This stores the running byte size estimate of a BQ request.
@@ -36,3 +36,23 @@ func TestNewQualifiedTableName(t *testing.T) {
 		}
 	}
 }
+
+func TestQualifiedTableName_LineageFQN(t *testing.T) {
+	tests := []struct {
+		Name string
+		Exp  string
+	}{
+		{"a:b.c", "bigquery:a.b.c"},
+		{"foo.com:a:b.c", "bigquery:`foo.com:a`.b.c"},
+	}
+
+	for _, test := range tests {
+		qn, err := NewQualifiedTableName(test.Name)
+		if err != nil {
+			t.Fatalf("NewQualifiedTableName(%v) failed: %v", test.Name, err)
+		}
+		if actual := qn.lineageFQN(); actual != test.Exp {
+			t.Errorf("lineageFQN(%v) = %v, want %v", test.Name, actual, test.Exp)
+		}
+	}
+}
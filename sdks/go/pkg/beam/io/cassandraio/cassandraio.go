@@ -0,0 +1,231 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cassandraio contains transforms for reading from and writing to
+// Apache Cassandra and ScyllaDB tables.
+//
+// Rows are mapped to and from Go structs. Each exported field is stored in the
+// column named by its `cql` struct tag, or by the lower-cased field name if it
+// has no tag. Fields tagged `cql:"-"` are ignored. Struct fields that are not
+// time.Time values are mapped to user-defined types (UDTs) with the same rules,
+// so nested UDTs do not need to be tagged field by field:
+//
+//	type Address struct {
+//		Street string
+//		City   string
+//	}
+//
+//	type User struct {
+//		ID      gocql.UUID `cql:"user_id"`
+//		Name    string
+//		Address Address // A column of UDT type address.
+//	}
+//
+// Experimental.
+package cassandraio
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/gocql/gocql"
+)
+
+// Option configures a Read or Write.
+type Option func(*config)
+
+// config holds the configuration of a Read or Write. Options that apply to
+// only one of them are ignored by the other.
+type config struct {
+	clusterConfig
+
+	NumSplits    int
+	PartitionKey []string
+	BatchSize    int
+	LoggedBatch  bool
+}
+
+// clusterConfig holds the configuration for connecting to a cluster, shared by
+// the DoFns.
+type clusterConfig struct {
+	Hosts       []string      `json:"hosts"`
+	Keyspace    string        `json:"keyspace"`
+	Consistency string        `json:"consistency"`
+	Username    string        `json:"username"`
+	Password    string        `json:"password"`
+	PageSize    int           `json:"pageSize"`
+	Timeout     time.Duration `json:"timeout"`
+}
+
+func newConfig(hosts []string, keyspace string, opts []Option) config {
+	cfg := config{
+		clusterConfig: clusterConfig{
+			Hosts:       hosts,
+			Keyspace:    keyspace,
+			Consistency: "LOCAL_QUORUM",
+		},
+		NumSplits: 16,
+		BatchSize: 50,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Consistency is an Option that sets the consistency level of queries and
+// writes, such as "ONE", "LOCAL_QUORUM" or "QUORUM".
+//
+// Default: LOCAL_QUORUM
+func Consistency(level string) Option {
+	return func(cfg *config) {
+		cfg.Consistency = level
+	}
+}
+
+// Credentials is an Option that authenticates to the cluster with a username
+// and password. The credentials are stored in the pipeline graph.
+func Credentials(username, password string) Option {
+	return func(cfg *config) {
+		cfg.Username = username
+		cfg.Password = password
+	}
+}
+
+// Timeout is an Option that sets the timeout of each request to the cluster.
+//
+// Default: the gocql default of 600ms
+func Timeout(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.Timeout = d
+	}
+}
+
+// PageSize is a Read Option that sets the number of rows fetched per page of
+// query results.
+//
+// Default: the gocql default of 5000
+func PageSize(n int) Option {
+	return func(cfg *config) {
+		cfg.PageSize = n
+	}
+}
+
+// NumSplits is a Read Option that sets the number of token ranges the table is
+// split into. Each range is queried independently, so this bounds the
+// parallelism of the read.
+//
+// Default: 16
+func NumSplits(n int) Option {
+	return func(cfg *config) {
+		cfg.NumSplits = n
+	}
+}
+
+// PartitionKey is a Read Option that sets the partition key columns of the
+// table, in order. By default they are looked up in the system_schema
+// keyspace, which is only available from Cassandra 3.0 and ScyllaDB 2.0.
+func PartitionKey(columns ...string) Option {
+	return func(cfg *config) {
+		cfg.PartitionKey = columns
+	}
+}
+
+// BatchSize is a Write Option that sets the maximum number of rows inserted
+// in one batch.
+//
+// Default: 50
+func BatchSize(n int) Option {
+	return func(cfg *config) {
+		cfg.BatchSize = n
+	}
+}
+
+// LoggedBatch is a Write Option that writes logged batches, which guarantee
+// that every row of a batch is eventually written if any of them are, at the
+// cost of extra coordination. By default batches are unlogged.
+func LoggedBatch() Option {
+	return func(cfg *config) {
+		cfg.LoggedBatch = true
+	}
+}
+
+// iterator iterates over the rows of a query result.
+type iterator interface {
+	Scan(dest ...interface{}) bool
+	Close() error
+}
+
+// session is the subset of a gocql.Session used by the transforms.
+type session interface {
+	Query(ctx context.Context, stmt string, values ...interface{}) iterator
+	ExecuteBatch(ctx context.Context, typ gocql.BatchType, entries []gocql.BatchEntry) error
+	Close()
+}
+
+// newSession connects to a cluster. It is a variable so tests can substitute
+// a fake cluster.
+var newSession = func(cfg clusterConfig) (session, error) {
+	cluster := gocql.NewCluster(cfg.Hosts...)
+	cluster.Keyspace = cfg.Keyspace
+	c, err := gocql.ParseConsistencyWrapper(cfg.Consistency)
+	if err != nil {
+		return nil, err
+	}
+	cluster.Consistency = c
+	if cfg.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{Username: cfg.Username, Password: cfg.Password}
+	}
+	if cfg.PageSize > 0 {
+		cluster.PageSize = cfg.PageSize
+	}
+	if cfg.Timeout > 0 {
+		cluster.Timeout = cfg.Timeout
+	}
+	s, err := cluster.CreateSession()
+	if err != nil {
+		return nil, err
+	}
+	return &gocqlSession{s}, nil
+}
+
+// gocqlSession adapts a gocql.Session to the session interface.
+type gocqlSession struct {
+	s *gocql.Session
+}
+
+func (s *gocqlSession) Query(ctx context.Context, stmt string, values ...interface{}) iterator {
+	return s.s.Query(stmt, values...).WithContext(ctx).Iter()
+}
+
+func (s *gocqlSession) ExecuteBatch(ctx context.Context, typ gocql.BatchType, entries []gocql.BatchEntry) error {
+	b := s.s.NewBatch(typ).WithContext(ctx)
+	b.Entries = entries
+	return s.s.ExecuteBatch(b)
+}
+
+func (s *gocqlSession) Close() {
+	s.s.Close()
+}
+
+// connect creates a session for the cluster.
+func connect(cfg clusterConfig) (session, error) {
+	s, err := newSession(cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cassandraio: failed to connect to %v", cfg.Hosts)
+	}
+	return s, nil
+}
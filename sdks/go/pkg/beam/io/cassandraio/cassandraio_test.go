@@ -0,0 +1,193 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandraio
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"github.com/gocql/gocql"
+)
+
+type user struct {
+	ID   int `cql:"user_id"`
+	Name string
+	Skip string `cql:"-"`
+}
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*user)(nil)).Elem())
+}
+
+// fakeRow is a row of the fake table with its partition key token.
+type fakeRow struct {
+	token  int64
+	values []interface{}
+}
+
+// fakeSession is an in-memory cluster holding a single table, which records
+// the batches written to it.
+type fakeSession struct {
+	rows      []fakeRow
+	failWrite bool
+
+	mu      sync.Mutex
+	queries []string
+	batches [][]gocql.BatchEntry
+}
+
+func (s *fakeSession) Query(_ context.Context, stmt string, values ...interface{}) iterator {
+	s.mu.Lock()
+	s.queries = append(s.queries, stmt)
+	s.mu.Unlock()
+	if strings.Contains(stmt, "system_schema.columns") {
+		return &fakeIter{rows: [][]interface{}{
+			{"name", "regular", -1},
+			{"bucket", "partition_key", 1},
+			{"user_id", "partition_key", 0},
+		}}
+	}
+	start, end := values[0].(int64), values[1].(int64)
+	var rows [][]interface{}
+	for _, r := range s.rows {
+		if r.token >= start && r.token <= end {
+			rows = append(rows, r.values)
+		}
+	}
+	return &fakeIter{rows: rows}
+}
+
+func (s *fakeSession) ExecuteBatch(_ context.Context, _ gocql.BatchType, entries []gocql.BatchEntry) error {
+	if s.failWrite {
+		return errors.New("write timeout")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, entries)
+	return nil
+}
+
+func (s *fakeSession) Close() {}
+
+type fakeIter struct {
+	rows [][]interface{}
+}
+
+func (it *fakeIter) Scan(dest ...interface{}) bool {
+	if len(it.rows) == 0 {
+		return false
+	}
+	for i, v := range it.rows[0] {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+	it.rows = it.rows[1:]
+	return true
+}
+
+func (it *fakeIter) Close() error { return nil }
+
+// withFakeSession substitutes the fake session for created sessions, and
+// returns a function restoring the original.
+func withFakeSession(s *fakeSession) func() {
+	orig := newSession
+	newSession = func(clusterConfig) (session, error) { return s, nil }
+	return func() { newSession = orig }
+}
+
+func TestRead(t *testing.T) {
+	s := &fakeSession{rows: []fakeRow{
+		{token: math.MinInt64, values: []interface{}{1, "a"}},
+		{token: -1, values: []interface{}{2, "b"}},
+		{token: 0, values: []interface{}{3, "c"}},
+		{token: math.MaxInt64, values: []interface{}{4, "d"}},
+	}}
+	defer withFakeSession(s)()
+
+	p, scope := beam.NewPipelineWithRoot()
+	users := Read(scope, []string{"fake"}, "ks", "users", reflect.TypeOf(user{}), NumSplits(3))
+	passert.Equals(scope, users, user{1, "a", ""}, user{2, "b", ""}, user{3, "c", ""}, user{4, "d", ""})
+	ptest.RunAndValidate(t, p)
+
+	want := `SELECT "user_id", "name" FROM ks.users WHERE token("user_id", "bucket") >= ? AND token("user_id", "bucket") <= ?`
+	if got := s.queries[len(s.queries)-1]; got != want {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+}
+
+func TestSplitRing(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 16, 1000} {
+		ranges := splitRing(n)
+		if len(ranges) != n {
+			t.Fatalf("splitRing(%v) returned %v ranges", n, len(ranges))
+		}
+		if ranges[0][0] != math.MinInt64 || ranges[n-1][1] != math.MaxInt64 {
+			t.Errorf("splitRing(%v) = [%v, %v], want the full ring", n, ranges[0][0], ranges[n-1][1])
+		}
+		for i, r := range ranges {
+			if r[0] > r[1] {
+				t.Errorf("splitRing(%v)[%v] = %v is empty", n, i, r)
+			}
+			if i > 0 && r[0] != ranges[i-1][1]+1 {
+				t.Errorf("splitRing(%v)[%v] = %v does not follow %v", n, i, r, ranges[i-1])
+			}
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	s := &fakeSession{}
+	defer withFakeSession(s)()
+
+	p, scope := beam.NewPipelineWithRoot()
+	col := beam.Create(scope, user{1, "a", ""}, user{2, "b", ""}, user{3, "c", ""})
+	Write(scope, []string{"fake"}, "ks", "users", col, BatchSize(2))
+	ptest.RunAndValidate(t, p)
+
+	var n int
+	for _, b := range s.batches {
+		if len(b) > 2 {
+			t.Errorf("wrote batch of %v rows, want at most 2", len(b))
+		}
+		for _, e := range b {
+			if want := `INSERT INTO ks.users ("user_id", "name") VALUES (?, ?)`; e.Stmt != want {
+				t.Errorf("statement = %v, want %v", e.Stmt, want)
+			}
+			n++
+		}
+	}
+	if n != 3 {
+		t.Errorf("wrote %v rows, want 3", n)
+	}
+}
+
+func TestWrite_Failure(t *testing.T) {
+	s := &fakeSession{failWrite: true}
+	defer withFakeSession(s)()
+
+	p, scope := beam.NewPipelineWithRoot()
+	Write(scope, []string{"fake"}, "ks", "users", beam.Create(scope, user{1, "a", ""}))
+	if err := ptest.Run(p); err == nil {
+		t.Error("pipeline succeeded, want error for failed write")
+	}
+}
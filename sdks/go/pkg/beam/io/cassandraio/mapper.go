@@ -0,0 +1,168 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandraio
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/gocql/gocql"
+)
+
+// field is a struct field mapped to a column or UDT field.
+type field struct {
+	name  string
+	index int
+}
+
+// fieldCache caches the mapped fields of struct types.
+var fieldCache sync.Map // reflect.Type -> []field
+
+// fields returns the mapped fields of the struct type t, in declaration order.
+func fields(t reflect.Type) []field {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.([]field)
+	}
+	var fs []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Tag.Get("cql")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fs = append(fs, field{name: name, index: i})
+	}
+	fieldCache.Store(t, fs)
+	return fs
+}
+
+// columns returns the quoted column names of the struct type t.
+func columns(t reflect.Type) ([]string, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("cassandraio: rows must be structs, got %v", t)
+	}
+	fs := fields(t)
+	if len(fs) == 0 {
+		return nil, errors.Errorf("cassandraio: %v has no exported fields", t)
+	}
+	cols := make([]string, len(fs))
+	for i, f := range fs {
+		cols[i] = quote(f.name)
+	}
+	return cols, nil
+}
+
+// quote quotes a CQL identifier, so it is matched case-sensitively.
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+var (
+	timeType           = reflect.TypeOf(time.Time{})
+	marshalerType      = reflect.TypeOf((*gocql.Marshaler)(nil)).Elem()
+	udtMarshalerType   = reflect.TypeOf((*gocql.UDTMarshaler)(nil)).Elem()
+	unmarshalerType    = reflect.TypeOf((*gocql.Unmarshaler)(nil)).Elem()
+	udtUnmarshalerType = reflect.TypeOf((*gocql.UDTUnmarshaler)(nil)).Elem()
+)
+
+// isUDT returns whether values of type t are mapped to UDTs by field name.
+// Structs that marshal themselves are left to gocql.
+func isUDT(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	return !pt.Implements(marshalerType) && !pt.Implements(udtMarshalerType) &&
+		!pt.Implements(unmarshalerType) && !pt.Implements(udtUnmarshalerType)
+}
+
+// bindValues returns the values of the mapped fields of the struct v, to be
+// bound to the columns of a statement.
+func bindValues(v reflect.Value) []interface{} {
+	fs := fields(v.Type())
+	values := make([]interface{}, len(fs))
+	for i, f := range fs {
+		values[i] = bindValue(v.Field(f.index))
+	}
+	return values
+}
+
+func bindValue(v reflect.Value) interface{} {
+	if isUDT(v.Type()) {
+		return udt{v}
+	}
+	return v.Interface()
+}
+
+// scanDests returns pointers to the mapped fields of the addressable struct
+// v, to be scanned from the columns of a row.
+func scanDests(v reflect.Value) []interface{} {
+	fs := fields(v.Type())
+	dests := make([]interface{}, len(fs))
+	for i, f := range fs {
+		dests[i] = scanDest(v.Field(f.index))
+	}
+	return dests
+}
+
+func scanDest(v reflect.Value) interface{} {
+	if isUDT(v.Type()) {
+		return udt{v}
+	}
+	return v.Addr().Interface()
+}
+
+// udt maps a struct value to a user-defined type. UDT fields without a
+// matching struct field are written as null and ignored when read.
+type udt struct {
+	v reflect.Value
+}
+
+func (u udt) lookup(name string) (reflect.Value, bool) {
+	for _, f := range fields(u.v.Type()) {
+		if f.name == name {
+			return u.v.Field(f.index), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// MarshalUDT marshals the struct field mapped to the named UDT field.
+func (u udt) MarshalUDT(name string, info gocql.TypeInfo) ([]byte, error) {
+	f, ok := u.lookup(name)
+	if !ok {
+		return nil, nil
+	}
+	return gocql.Marshal(info, bindValue(f))
+}
+
+// UnmarshalUDT unmarshals the named UDT field into its mapped struct field.
+func (u udt) UnmarshalUDT(name string, info gocql.TypeInfo, data []byte) error {
+	f, ok := u.lookup(name)
+	if !ok {
+		return nil
+	}
+	return gocql.Unmarshal(info, data, scanDest(f))
+}
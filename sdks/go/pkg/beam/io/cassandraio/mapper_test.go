@@ -0,0 +1,92 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandraio
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+type geo struct {
+	Lat float64
+	Lng float64 `cql:"lon"`
+}
+
+type address struct {
+	Street string
+	Geo    geo
+}
+
+type account struct {
+	ID      int `cql:"account_id"`
+	Address address
+	Created time.Time
+	secret  string
+}
+
+func TestColumns(t *testing.T) {
+	got, err := columns(reflect.TypeOf(account{}))
+	if err != nil {
+		t.Fatalf("columns failed: %v", err)
+	}
+	if want := []string{`"account_id"`, `"address"`, `"created"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("columns = %v, want %v", got, want)
+	}
+	if _, err := columns(reflect.TypeOf(0)); err == nil {
+		t.Errorf("columns(int) succeeded, want error")
+	}
+}
+
+// TestUDT tests that nested structs round trip through user-defined types,
+// matching UDT fields to untagged struct fields by their lower-cased names.
+func TestUDT(t *testing.T) {
+	native := func(typ gocql.Type) gocql.NativeType { return gocql.NewNativeType(4, typ, "") }
+	info := gocql.UDTTypeInfo{
+		NativeType: native(gocql.TypeUDT),
+		Name:       "address",
+		Elements: []gocql.UDTField{
+			{Name: "street", Type: native(gocql.TypeVarchar)},
+			{Name: "zip", Type: native(gocql.TypeVarchar)}, // Not mapped.
+			{Name: "geo", Type: gocql.UDTTypeInfo{
+				NativeType: native(gocql.TypeUDT),
+				Name:       "geo",
+				Elements: []gocql.UDTField{
+					{Name: "lat", Type: native(gocql.TypeDouble)},
+					{Name: "lon", Type: native(gocql.TypeDouble)},
+				},
+			}},
+		},
+	}
+
+	in := account{Address: address{Street: "1 Main St", Geo: geo{Lat: 1.5, Lng: -2.5}}}
+	values := bindValues(reflect.ValueOf(in))
+	data, err := gocql.Marshal(info, values[1])
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var out account
+	dests := scanDests(reflect.ValueOf(&out).Elem())
+	if err := gocql.Unmarshal(info, data, dests[1]); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.Address != in.Address {
+		t.Errorf("round trip of %+v = %+v", in.Address, out.Address)
+	}
+}
@@ -0,0 +1,203 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandraio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*splitFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*readFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*split)(nil)).Elem())
+}
+
+// Read reads all rows of the given table, and returns a PCollection<t> where
+// t is a struct type mapped to the columns of the table. Only the columns
+// mapped by t are read.
+//
+// The token ring of the cluster is split into NumSplits contiguous ranges,
+// which are queried in parallel. Read requires the table to be in a cluster
+// using the default Murmur3Partitioner.
+//
+// Example:
+//
+//	hosts := []string{"cassandra-1:9042", "cassandra-2:9042"}
+//	users := cassandraio.Read(s, hosts, "app", "users", reflect.TypeOf(User{}),
+//		cassandraio.NumSplits(64), cassandraio.Consistency("ONE"))
+func Read(s beam.Scope, hosts []string, keyspace, table string, t reflect.Type, opts ...Option) beam.PCollection {
+	s = s.Scope("cassandraio.Read")
+
+	cols, err := columns(t)
+	if err != nil {
+		panic(err)
+	}
+	cfg := newConfig(hosts, keyspace, opts)
+	if cfg.NumSplits < 1 {
+		panic(errors.Errorf("cassandraio.Read requires at least one split, got %v", cfg.NumSplits))
+	}
+
+	imp := beam.Impulse(s)
+	splits := beam.ParDo(s, &splitFn{
+		clusterConfig: cfg.clusterConfig,
+		Table:         table,
+		Columns:       cols,
+		PartitionKey:  cfg.PartitionKey,
+		NumSplits:     cfg.NumSplits,
+	}, imp)
+	splits = beam.Reshuffle(s, splits)
+	return beam.ParDo(s, &readFn{clusterConfig: cfg.clusterConfig, Type: beam.EncodedType{T: t}}, splits, beam.TypeDefinition{Var: beam.XType, T: t})
+}
+
+// split is a query over the inclusive token range [Start, End].
+type split struct {
+	Query string
+	Start int64
+	End   int64
+}
+
+// splitRing splits the Murmur3 token ring, which covers all int64 values,
+// into n contiguous inclusive ranges of nearly equal size.
+func splitRing(n int) [][2]int64 {
+	const min = uint64(1) << 63 // math.MinInt64 as a two's complement uint64.
+	width := math.MaxUint64 / uint64(n)
+	ranges := make([][2]int64, n)
+	for i := range ranges {
+		ranges[i][0] = int64(min + uint64(i)*width)
+		if i > 0 {
+			ranges[i-1][1] = ranges[i][0] - 1
+		}
+	}
+	ranges[n-1][1] = math.MaxInt64
+	return ranges
+}
+
+// splitFn emits a query for each token range of a table.
+type splitFn struct {
+	clusterConfig
+	Table        string   `json:"table"`
+	Columns      []string `json:"columns"`
+	PartitionKey []string `json:"partitionKey"`
+	NumSplits    int      `json:"numSplits"`
+}
+
+func (fn *splitFn) ProcessElement(ctx context.Context, _ []byte, emit func(split)) error {
+	key := fn.PartitionKey
+	if len(key) == 0 {
+		var err error
+		if key, err = fn.lookupPartitionKey(ctx); err != nil {
+			return err
+		}
+	}
+	quoted := make([]string, len(key))
+	for i, k := range key {
+		quoted[i] = quote(k)
+	}
+	token := fmt.Sprintf("token(%v)", strings.Join(quoted, ", "))
+	q := fmt.Sprintf("SELECT %v FROM %v.%v WHERE %v >= ? AND %v <= ?",
+		strings.Join(fn.Columns, ", "), fn.Keyspace, fn.Table, token, token)
+
+	log.Infof(ctx, "cassandraio: reading %v.%v in %v splits", fn.Keyspace, fn.Table, fn.NumSplits)
+	for _, r := range splitRing(fn.NumSplits) {
+		emit(split{Query: q, Start: r[0], End: r[1]})
+	}
+	return nil
+}
+
+// lookupPartitionKey returns the partition key columns of the table, in order.
+func (fn *splitFn) lookupPartitionKey(ctx context.Context) ([]string, error) {
+	s, err := connect(fn.clusterConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	iter := s.Query(ctx, "SELECT column_name, kind, position FROM system_schema.columns WHERE keyspace_name = ? AND table_name = ?", fn.Keyspace, fn.Table)
+	type keyColumn struct {
+		name     string
+		position int
+	}
+	var key []keyColumn
+	var name, kind string
+	var position int
+	for iter.Scan(&name, &kind, &position) {
+		if kind == "partition_key" {
+			key = append(key, keyColumn{name, position})
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, errors.Wrapf(err, "cassandraio: failed to look up partition key of %v.%v", fn.Keyspace, fn.Table)
+	}
+	if len(key) == 0 {
+		return nil, errors.Errorf("cassandraio: table %v.%v not found", fn.Keyspace, fn.Table)
+	}
+	sort.Slice(key, func(i, j int) bool { return key[i].position < key[j].position })
+	names := make([]string, len(key))
+	for i, k := range key {
+		names[i] = k.name
+	}
+	return names, nil
+}
+
+// readFn queries the rows in a token range.
+type readFn struct {
+	clusterConfig
+	// Type is the encoded row type.
+	Type beam.EncodedType `json:"type"`
+
+	session session
+}
+
+// Setup connects to the cluster.
+func (fn *readFn) Setup() error {
+	s, err := connect(fn.clusterConfig)
+	if err != nil {
+		return err
+	}
+	fn.session = s
+	return nil
+}
+
+func (fn *readFn) ProcessElement(ctx context.Context, sp split, emit func(beam.X)) error {
+	iter := fn.session.Query(ctx, sp.Query, sp.Start, sp.End)
+	for {
+		row := reflect.New(fn.Type.T).Elem()
+		if !iter.Scan(scanDests(row)...) {
+			break
+		}
+		emit(row.Interface())
+	}
+	if err := iter.Close(); err != nil {
+		return errors.Wrapf(err, "cassandraio: failed to read token range [%v, %v]", sp.Start, sp.End)
+	}
+	return nil
+}
+
+// Teardown closes the session.
+func (fn *readFn) Teardown() {
+	if fn.session != nil {
+		fn.session.Close()
+	}
+}
@@ -0,0 +1,128 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandraio
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/gocql/gocql"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*writeFn)(nil)).Elem())
+}
+
+// Write inserts the elements of the given PCollection<t> into the table,
+// where t is a struct type mapped to the columns of the table. Every mapped
+// column is written, so rows with the same primary key replace each other.
+//
+// The INSERT statement is prepared once per connection, and rows are written
+// in batches of up to BatchSize rows. Batches are unlogged by default, which
+// makes them a throughput optimization rather than a transaction.
+//
+// Example:
+//
+//	hosts := []string{"cassandra-1:9042"}
+//	cassandraio.Write(s, hosts, "app", "users", users, cassandraio.BatchSize(100))
+func Write(s beam.Scope, hosts []string, keyspace, table string, col beam.PCollection, opts ...Option) {
+	s = s.Scope("cassandraio.Write")
+
+	t := col.Type().Type()
+	cols, err := columns(t)
+	if err != nil {
+		panic(err)
+	}
+	cfg := newConfig(hosts, keyspace, opts)
+	if cfg.BatchSize < 1 {
+		panic(errors.Errorf("cassandraio.Write requires a positive batch size, got %v", cfg.BatchSize))
+	}
+
+	stmt := fmt.Sprintf("INSERT INTO %v.%v (%v) VALUES (%v)",
+		keyspace, table, strings.Join(cols, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", "))
+	beam.ParDo0(s, &writeFn{
+		clusterConfig: cfg.clusterConfig,
+		Statement:     stmt,
+		BatchSize:     cfg.BatchSize,
+		LoggedBatch:   cfg.LoggedBatch,
+	}, col)
+}
+
+// writeFn inserts rows in batches.
+type writeFn struct {
+	clusterConfig
+	Statement   string `json:"statement"`
+	BatchSize   int    `json:"batchSize"`
+	LoggedBatch bool   `json:"loggedBatch"`
+
+	session session
+	batch   []gocql.BatchEntry
+}
+
+// Setup connects to the cluster.
+func (fn *writeFn) Setup() error {
+	s, err := connect(fn.clusterConfig)
+	if err != nil {
+		return err
+	}
+	fn.session = s
+	return nil
+}
+
+func (fn *writeFn) ProcessElement(ctx context.Context, row beam.X) error {
+	fn.batch = append(fn.batch, gocql.BatchEntry{
+		Stmt:       fn.Statement,
+		Args:       bindValues(reflect.ValueOf(row)),
+		Idempotent: true,
+	})
+	if len(fn.batch) >= fn.BatchSize {
+		return fn.flush(ctx)
+	}
+	return nil
+}
+
+// FinishBundle writes any remaining rows of the bundle.
+func (fn *writeFn) FinishBundle(ctx context.Context) error {
+	return fn.flush(ctx)
+}
+
+func (fn *writeFn) flush(ctx context.Context) error {
+	if len(fn.batch) == 0 {
+		return nil
+	}
+	typ := gocql.UnloggedBatch
+	if fn.LoggedBatch {
+		typ = gocql.LoggedBatch
+	}
+	n := len(fn.batch)
+	err := fn.session.ExecuteBatch(ctx, typ, fn.batch)
+	fn.batch = nil
+	if err != nil {
+		return errors.Wrapf(err, "cassandraio: failed to write batch of %v rows", n)
+	}
+	return nil
+}
+
+// Teardown closes the session.
+func (fn *writeFn) Teardown() {
+	if fn.session != nil {
+		fn.session.Close()
+	}
+}
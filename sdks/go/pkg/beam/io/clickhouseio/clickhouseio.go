@@ -0,0 +1,236 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clickhouseio contains transforms for writing to ClickHouse tables
+// over the native protocol.
+//
+// Experimental.
+package clickhouseio
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go"
+	"github.com/ClickHouse/clickhouse-go/lib/data"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*writeFn)(nil)).Elem())
+}
+
+// openDirect opens a native protocol connection. It is a variable so tests
+// can substitute a fake server.
+var openDirect = clickhouse.OpenDirect
+
+// Write inserts the elements of the given PCollection<t> into the table, where
+// t is a struct type. Each exported field is written to the column named by
+// its `ch` struct tag, or by the lower-cased field name if it has no tag.
+// Fields tagged `ch:"-"` are ignored.
+//
+// Rows are buffered into columnar blocks of up to BlockSize rows, and each
+// block is sent as a single insert. Numeric and boolean field values are
+// converted to the types of their columns, so for example an int field can be
+// written to an Int32 column, a bool field to a UInt8 column, and a []int
+// field to an Array(Int64) column.
+//
+// The dsn is a clickhouse-go connection string, such as
+// "tcp://localhost:9000?database=analytics&username=beam".
+//
+// Example:
+//
+//	clickhouseio.Write(s, "tcp://localhost:9000?database=analytics", "events", events,
+//		clickhouseio.BlockSize(500000))
+func Write(s beam.Scope, dsn, table string, col beam.PCollection, opts ...writeOption) {
+	s = s.Scope("clickhouseio.Write")
+
+	t := col.Type().Type()
+	cols, err := columns(t)
+	if err != nil {
+		panic(err)
+	}
+	fn := &writeFn{
+		DSN:       dsn,
+		Table:     table,
+		Columns:   cols,
+		BlockSize: 100000,
+	}
+	for _, opt := range opts {
+		opt(fn)
+	}
+	if fn.BlockSize < 1 {
+		panic(errors.Errorf("clickhouseio.Write requires a positive block size, got %v", fn.BlockSize))
+	}
+	beam.ParDo0(s, fn, col)
+}
+
+type writeOption func(*writeFn)
+
+// BlockSize is a Write option that sets the maximum number of rows in each
+// inserted block. ClickHouse performs best with large, infrequent inserts, so
+// blocks should hold at least several thousand rows where possible. A bundle
+// always ends its last block, so small bundles produce small blocks.
+//
+// Default: 100000
+func BlockSize(n int) writeOption {
+	return func(fn *writeFn) {
+		fn.BlockSize = n
+	}
+}
+
+// columns returns the column names of the struct type t.
+func columns(t reflect.Type) ([]string, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("clickhouseio: rows must be structs, got %v", t)
+	}
+	var cols []string
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := columnName(t.Field(i)); ok {
+			cols = append(cols, name)
+		}
+	}
+	if len(cols) == 0 {
+		return nil, errors.Errorf("clickhouseio: %v has no exported fields", t)
+	}
+	return cols, nil
+}
+
+// columnName returns the column of a struct field, and whether it is mapped.
+func columnName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	name := f.Tag.Get("ch")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name, true
+}
+
+// writeFn inserts rows in columnar blocks.
+type writeFn struct {
+	DSN       string   `json:"dsn"`
+	Table     string   `json:"table"`
+	Columns   []string `json:"columns"`
+	BlockSize int      `json:"blockSize"`
+
+	conn  clickhouse.Clickhouse
+	block *data.Block
+}
+
+// Setup connects to the server.
+func (fn *writeFn) Setup() error {
+	conn, err := openDirect(fn.DSN)
+	if err != nil {
+		return errors.Wrap(err, "clickhouseio: failed to connect to server")
+	}
+	fn.conn = conn
+	return nil
+}
+
+// begin starts an insert, and prepares a block with the column types
+// reported by the server.
+func (fn *writeFn) begin() error {
+	if _, err := fn.conn.Begin(); err != nil {
+		return errors.Wrap(err, "clickhouseio: failed to begin insert")
+	}
+	quoted := make([]string, len(fn.Columns))
+	for i, c := range fn.Columns {
+		quoted[i] = "`" + strings.Replace(c, "`", "\\`", -1) + "`"
+	}
+	q := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)",
+		fn.Table, strings.Join(quoted, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(quoted)), ", "))
+	if _, err := fn.conn.Prepare(q); err != nil {
+		fn.conn.Rollback()
+		return errors.Wrapf(err, "clickhouseio: failed to prepare insert into %v", fn.Table)
+	}
+	block, err := fn.conn.Block()
+	if err != nil {
+		fn.conn.Rollback()
+		return errors.Wrapf(err, "clickhouseio: failed to create block for %v", fn.Table)
+	}
+	if len(block.Columns) != len(fn.Columns) {
+		fn.conn.Rollback()
+		return errors.Errorf("clickhouseio: insert into %v has %v columns, want %v", fn.Table, len(block.Columns), len(fn.Columns))
+	}
+	block.Reserve()
+	fn.block = block
+	return nil
+}
+
+func (fn *writeFn) ProcessElement(ctx context.Context, row beam.X) error {
+	if fn.block == nil {
+		if err := fn.begin(); err != nil {
+			return err
+		}
+	}
+	v := reflect.ValueOf(row)
+	values := make([]driver.Value, 0, len(fn.block.Columns))
+	for i := 0; i < v.NumField(); i++ {
+		if _, ok := columnName(v.Type().Field(i)); !ok {
+			continue
+		}
+		values = append(values, convert(v.Field(i), fn.block.Columns[len(values)].ScanType()))
+	}
+	if err := fn.block.AppendRow(values); err != nil {
+		return errors.Wrapf(err, "clickhouseio: failed to append row to %v", fn.Table)
+	}
+	if fn.block.NumRows >= uint64(fn.BlockSize) {
+		return fn.flush(ctx)
+	}
+	return nil
+}
+
+// FinishBundle inserts the last block of the bundle.
+func (fn *writeFn) FinishBundle(ctx context.Context) error {
+	return fn.flush(ctx)
+}
+
+func (fn *writeFn) flush(ctx context.Context) error {
+	if fn.block == nil {
+		return nil
+	}
+	block := fn.block
+	fn.block = nil
+	if err := fn.conn.WriteBlock(block); err != nil {
+		fn.conn.Rollback()
+		return errors.Wrapf(err, "clickhouseio: failed to write block of %v rows to %v", block.NumRows, fn.Table)
+	}
+	if err := fn.conn.Commit(); err != nil {
+		return errors.Wrapf(err, "clickhouseio: failed to commit block of %v rows to %v", block.NumRows, fn.Table)
+	}
+	log.Debugf(ctx, "clickhouseio: inserted %v rows into %v", block.NumRows, fn.Table)
+	return nil
+}
+
+// Teardown abandons any unfinished insert and closes the connection.
+func (fn *writeFn) Teardown() {
+	if fn.conn == nil {
+		return
+	}
+	if fn.block != nil {
+		fn.conn.Rollback()
+	}
+	fn.conn.Close()
+}
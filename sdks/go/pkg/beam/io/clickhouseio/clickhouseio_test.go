@@ -0,0 +1,178 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouseio
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go"
+	"github.com/ClickHouse/clickhouse-go/lib/column"
+	"github.com/ClickHouse/clickhouse-go/lib/data"
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+type event struct {
+	ID     int `ch:"event_id"`
+	Name   string
+	Active bool
+	Tags   []int
+	Score  *float32
+	Note   string `ch:"-"`
+}
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*event)(nil)).Elem())
+}
+
+// fakeServer is a ClickHouse server with a single table, which records the
+// inserts made to it.
+type fakeServer struct {
+	clickhouse.Clickhouse
+	types      []string // Column types of the table, in order.
+	failWrites bool
+
+	queries []string
+	blocks  []uint64 // Number of rows in each committed block.
+	pending uint64
+}
+
+func (s *fakeServer) Begin() (driver.Tx, error) { return nil, nil }
+
+func (s *fakeServer) Prepare(query string) (driver.Stmt, error) {
+	s.queries = append(s.queries, query)
+	return nil, nil
+}
+
+func (s *fakeServer) Block() (*data.Block, error) {
+	b := &data.Block{NumColumns: uint64(len(s.types))}
+	for i, typ := range s.types {
+		c, err := column.Factory(string(rune('a'+i)), typ, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		b.Columns = append(b.Columns, c)
+	}
+	return b, nil
+}
+
+func (s *fakeServer) WriteBlock(b *data.Block) error {
+	if s.failWrites {
+		return errors.New("connection reset")
+	}
+	s.pending = b.NumRows
+	return nil
+}
+
+func (s *fakeServer) Commit() error {
+	s.blocks = append(s.blocks, s.pending)
+	return nil
+}
+
+func (s *fakeServer) Rollback() error { return nil }
+func (s *fakeServer) Close() error    { return nil }
+
+// withFakeServer substitutes the fake server for opened connections, and
+// returns a function restoring the original.
+func withFakeServer(s *fakeServer) func() {
+	orig := openDirect
+	openDirect = func(string) (clickhouse.Clickhouse, error) { return s, nil }
+	return func() { openDirect = orig }
+}
+
+func TestWrite(t *testing.T) {
+	s := &fakeServer{types: []string{"Int32", "String", "UInt8", "Array(Int64)", "Nullable(Float64)"}}
+	defer withFakeServer(s)()
+
+	p, scope := beam.NewPipelineWithRoot()
+	score := float32(0.5)
+	col := beam.Create(scope,
+		event{ID: 1, Name: "a", Active: true, Tags: []int{1, 2}, Score: &score},
+		event{ID: 2, Name: "b"},
+		event{ID: 3, Name: "c", Tags: []int{3}})
+	Write(scope, "tcp://fake", "events", col, BlockSize(2))
+	ptest.RunAndValidate(t, p)
+
+	if got, want := s.blocks, []uint64{2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("committed blocks of %v rows, want %v", got, want)
+	}
+	want := "INSERT INTO events (`event_id`, `name`, `active`, `tags`, `score`) VALUES (?, ?, ?, ?, ?)"
+	if got := s.queries[0]; got != want {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+}
+
+func TestWrite_SchemaMismatch(t *testing.T) {
+	s := &fakeServer{types: []string{"Int32", "String"}}
+	defer withFakeServer(s)()
+
+	p, scope := beam.NewPipelineWithRoot()
+	Write(scope, "tcp://fake", "events", beam.Create(scope, event{ID: 1}))
+	if err := ptest.Run(p); err == nil || !strings.Contains(err.Error(), "columns") {
+		t.Errorf("pipeline error = %v, want column count mismatch", err)
+	}
+}
+
+func TestWrite_Failure(t *testing.T) {
+	s := &fakeServer{types: []string{"Int32", "String", "UInt8", "Array(Int64)", "Nullable(Float64)"}, failWrites: true}
+	defer withFakeServer(s)()
+
+	p, scope := beam.NewPipelineWithRoot()
+	Write(scope, "tcp://fake", "events", beam.Create(scope, event{ID: 1}))
+	if err := ptest.Run(p); err == nil {
+		t.Error("pipeline succeeded, want error for failed insert")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	f := float32(1.5)
+	tests := []struct {
+		name string
+		in   interface{}
+		t    interface{}
+		want interface{}
+	}{
+		{"same", int32(1), int32(0), int32(1)},
+		{"numeric", 7, int32(0), int32(7)},
+		{"float", float32(0.5), float64(0), float64(0.5)},
+		{"bool", true, uint8(0), uint8(1)},
+		{"named", status("ok"), "", "ok"},
+		{"nullable", &f, (*float64)(nil), 1.5},
+		{"nil", (*float32)(nil), (*float64)(nil), nil},
+		{"array", []int{1, 2}, []int64(nil), []int64{1, 2}},
+		{"nullable array", []int{1}, []*int64(nil), []*int64{int64Ptr(1)}},
+		{"unconverted", int64(1), time.Time{}, int64(1)},
+		{"bytes", []byte("b"), "", []byte("b")},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got := convert(reflect.ValueOf(test.in), reflect.TypeOf(test.t))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("convert(%v, %T) = %#v, want %#v", test.in, test.t, got, test.want)
+			}
+		})
+	}
+}
+
+type status string
+
+func int64Ptr(v int64) *int64 { return &v }
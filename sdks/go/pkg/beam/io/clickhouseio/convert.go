@@ -0,0 +1,80 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouseio
+
+import (
+	"reflect"
+)
+
+// convert converts a field value to the Go type t that a column scans into. A
+// value is converted if it has the same kind as t, or if both are numeric, and
+// booleans are converted to 0 or 1. Pointers are dereferenced for Nullable
+// columns, whose type is a pointer, and slices for Array columns are converted
+// element by element. Any other value is returned unchanged, to be validated
+// by the column.
+func convert(v reflect.Value, t reflect.Type) interface{} {
+	if v.Type() == t {
+		return v.Interface()
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		return convert(v.Elem(), t)
+	}
+	if t.Kind() == reflect.Ptr {
+		return convert(v, t.Elem())
+	}
+	switch {
+	case v.Kind() == reflect.Slice && t.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+		out := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e := reflect.ValueOf(convert(v.Index(i), t.Elem()))
+			if !e.IsValid() {
+				continue // Leave nil elements unset.
+			}
+			if t.Elem().Kind() == reflect.Ptr && e.Kind() != reflect.Ptr {
+				p := reflect.New(e.Type())
+				p.Elem().Set(e)
+				e = p
+			}
+			if !e.Type().AssignableTo(t.Elem()) {
+				return v.Interface()
+			}
+			out.Index(i).Set(e)
+		}
+		return out.Interface()
+	case v.Kind() == reflect.Bool && isNumeric(t.Kind()):
+		var n int64
+		if v.Bool() {
+			n = 1
+		}
+		return reflect.ValueOf(n).Convert(t).Interface()
+	case v.Kind() == t.Kind() && v.Type().ConvertibleTo(t), isNumeric(v.Kind()) && isNumeric(t.Kind()):
+		return v.Convert(t).Interface()
+	}
+	return v.Interface()
+}
+
+func isNumeric(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,345 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azure contains an Azure Blob Storage implementation of the Beam
+// file system. Blobs are named by paths of the form
+//
+//	azblob://<account>/<container>/<blob>
+//
+// or, for accounts with a hierarchical namespace, by their Data Lake Storage
+// Gen2 URIs, which are accessed through the Blob endpoint of the account:
+//
+//	abfss://<container>@<account>.dfs.core.windows.net/<blob>
+//
+// Experimental.
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
+	"github.com/google/uuid"
+)
+
+func init() {
+	filesystem.Register("azblob", New)
+	filesystem.Register("abfss", New)
+}
+
+// optionsKey is the pipeline option holding the encoded Options.
+const optionsKey = "azure_filesystem_options"
+
+// Options configures the Azure Blob Storage file system. The credentials are
+// carried in the pipeline options, so prefer short-lived SAS tokens or
+// managed identities over account keys.
+type Options struct {
+	// SASToken is a shared access signature granting access to the blobs,
+	// such as "sv=2020-02-10&ss=b&srt=sco&sp=rwl&sig=...".
+	SASToken string `json:"sasToken,omitempty"`
+	// AccountKey is the shared key of the storage account. It takes
+	// precedence over the other credentials.
+	AccountKey string `json:"accountKey,omitempty"`
+	// UseManagedIdentity authenticates with the managed identity of the
+	// Azure VM or container the pipeline runs on.
+	UseManagedIdentity bool `json:"useManagedIdentity,omitempty"`
+	// ManagedIdentityClientID is the client ID of a user-assigned managed
+	// identity. If empty, the system-assigned identity is used.
+	ManagedIdentityClientID string `json:"managedIdentityClientID,omitempty"`
+	// Endpoint is the Blob service URL, such as
+	// "http://127.0.0.1:10000/devstoreaccount1" for the Azurite emulator.
+	// If empty, the public Azure endpoint of the account is used.
+	Endpoint string `json:"endpoint,omitempty"`
+	// BlockSize is the number of bytes buffered and staged in each block of
+	// an upload. If zero, blocks of 8MiB are used.
+	BlockSize int `json:"blockSize,omitempty"`
+	// MaxRetries is the number of times a failed request is retried. Blobs
+	// are read as streams that resume from their last position, up to
+	// MaxRetries times. If zero, requests are retried 5 times. If negative,
+	// requests are not retried.
+	MaxRetries int `json:"maxRetries,omitempty"`
+}
+
+// SetOptions configures the Azure Blob Storage file system for the pipeline.
+// The options are carried to remote workers as pipeline options, so they must
+// be set before the pipeline is executed.
+func SetOptions(opts Options) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to encode Azure options"))
+	}
+	runtime.GlobalOptions.Set(optionsKey, string(data))
+}
+
+// options returns the options of the pipeline.
+func options() (Options, error) {
+	var opts Options
+	if data := runtime.GlobalOptions.Get(optionsKey); data != "" {
+		if err := json.Unmarshal([]byte(data), &opts); err != nil {
+			return Options{}, errors.Wrap(err, "failed to decode Azure options")
+		}
+	}
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = 8 << 20
+	}
+	switch {
+	case opts.MaxRetries == 0:
+		opts.MaxRetries = 5
+	case opts.MaxRetries < 0:
+		opts.MaxRetries = 0
+	}
+	return opts, nil
+}
+
+// blob identifies a blob, or a prefix of blob names.
+type blob struct {
+	account, container, name string
+}
+
+// parseBlob parses an azblob:// or abfss:// path into its blob, and the
+// prefix of the path preceding the blob name.
+func parseBlob(path string) (blob, string, error) {
+	var b blob
+	var rest string
+	switch {
+	case strings.HasPrefix(path, "azblob://"):
+		parts := strings.SplitN(strings.TrimPrefix(path, "azblob://"), "/", 3)
+		if len(parts) < 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return blob{}, "", errors.Errorf("invalid Azure path %q: want azblob://account/container/blob", path)
+		}
+		b = blob{account: parts[0], container: parts[1], name: parts[2]}
+		rest = parts[2]
+	case strings.HasPrefix(path, "abfss://"):
+		parts := strings.SplitN(strings.TrimPrefix(path, "abfss://"), "/", 2)
+		at := strings.Index(parts[0], "@")
+		if len(parts) < 2 || parts[1] == "" || at <= 0 {
+			return blob{}, "", errors.Errorf("invalid Azure path %q: want abfss://container@account.dfs.core.windows.net/blob", path)
+		}
+		host := parts[0][at+1:]
+		account := strings.SplitN(host, ".", 2)[0]
+		if account == "" {
+			return blob{}, "", errors.Errorf("invalid Azure path %q: missing account", path)
+		}
+		b = blob{account: account, container: parts[0][:at], name: parts[1]}
+		rest = parts[1]
+	default:
+		return blob{}, "", errors.Errorf("invalid Azure path %q: want azblob:// or abfss:// scheme", path)
+	}
+	return b, strings.TrimSuffix(path, rest), nil
+}
+
+type fs struct {
+	client client
+	opts   Options
+}
+
+// New creates a new Azure Blob Storage filesystem, configured with the
+// options given to SetOptions. Without credentials, blobs are accessed
+// anonymously, which only works for public containers.
+//
+// Blobs are written as block blobs, by staging a block for every BlockSize
+// bytes written and committing the block list when the writer is closed.
+// The file system implements filesystem.RangeReader.
+func New(ctx context.Context) filesystem.Interface {
+	opts, err := options()
+	if err != nil {
+		panic(err)
+	}
+	c, err := newClient(ctx, opts)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to create Azure Blob Storage client"))
+	}
+	return &fs{client: c, opts: opts}
+}
+
+func (f *fs) Close() error {
+	return nil
+}
+
+func (f *fs) List(ctx context.Context, glob string) ([]string, error) {
+	b, prefix, err := parseBlob(glob)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	if index := strings.IndexAny(b.name, "*?["); index >= 0 {
+		// We handle globs by listing all blobs with the literal prefix of
+		// the pattern and matching them here.
+		p := b
+		p.name = b.name[:index]
+		var marker string
+		for {
+			names, next, err := f.client.ListBlobs(ctx, p, marker)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to list %v", glob)
+			}
+			for _, name := range names {
+				match, err := filepath.Match(b.name, name)
+				if err != nil {
+					return nil, err
+				}
+				if match {
+					candidates = append(candidates, name)
+				}
+			}
+			if next == "" {
+				break
+			}
+			marker = next
+		}
+	} else {
+		// Single blob.
+		candidates = []string{b.name}
+	}
+
+	var ret []string
+	for _, name := range candidates {
+		ret = append(ret, prefix+name)
+	}
+	return ret, nil
+}
+
+func (f *fs) OpenRead(ctx context.Context, filename string) (io.ReadCloser, error) {
+	return f.OpenReadRange(ctx, filename, 0, -1)
+}
+
+// OpenReadRange opens a blob for reading length bytes from the given offset.
+// A negative length reads to the end of the blob.
+func (f *fs) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	b, _, err := parseBlob(filename)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+	if length < 0 {
+		length = 0 // Read to the end.
+	}
+	r, err := f.client.Download(ctx, b, offset, length)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %v", filename)
+	}
+	return r, nil
+}
+
+// OpenWrite opens a block blob for writing. The blob is created, or
+// replaced, when the writer is closed.
+func (f *fs) OpenWrite(ctx context.Context, filename string) (io.WriteCloser, error) {
+	b, _, err := parseBlob(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &writer{
+		ctx:      ctx,
+		client:   f.client,
+		blob:     b,
+		filename: filename,
+		size:     f.opts.BlockSize,
+		prefix:   uuid.New().String(),
+	}, nil
+}
+
+func (f *fs) Size(ctx context.Context, filename string) (int64, error) {
+	b, _, err := parseBlob(filename)
+	if err != nil {
+		return -1, err
+	}
+	size, err := f.client.Size(ctx, b)
+	if err != nil {
+		return -1, errors.Wrapf(err, "failed to get size of %v", filename)
+	}
+	return size, nil
+}
+
+// writer stages the written bytes as blocks of a block blob, and commits
+// them on close.
+type writer struct {
+	ctx      context.Context
+	client   client
+	blob     blob
+	filename string
+	size     int
+	// prefix makes the block IDs of this writer unique, so concurrent
+	// writers of the same blob do not overwrite each other's uncommitted
+	// blocks.
+	prefix string
+
+	buf []byte
+	ids []string
+	err error
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		k := w.size - len(w.buf)
+		if k > len(p) {
+			k = len(p)
+		}
+		w.buf = append(w.buf, p[:k]...)
+		p = p[k:]
+		if len(w.buf) == w.size {
+			if err := w.stage(); err != nil {
+				return n - len(p) - k, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// stage stages the buffered bytes as the next block.
+func (w *writer) stage() error {
+	// Block IDs must have the same length within a blob.
+	id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%v-%08d", w.prefix, len(w.ids))))
+	if err := w.client.StageBlock(w.ctx, w.blob, id, w.buf); err != nil {
+		w.err = errors.Wrapf(err, "failed to stage block %v of %v", len(w.ids), w.filename)
+		return w.err
+	}
+	w.ids = append(w.ids, id)
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close stages any remaining bytes and commits the blob.
+func (w *writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) > 0 {
+		if err := w.stage(); err != nil {
+			return err
+		}
+	}
+	if err := w.client.CommitBlockList(w.ctx, w.blob, w.ids); err != nil {
+		w.err = errors.Wrapf(err, "failed to commit %v", w.filename)
+		return w.err
+	}
+	w.err = errors.Errorf("%v is closed", w.filename)
+	return nil
+}
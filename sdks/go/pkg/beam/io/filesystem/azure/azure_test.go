@@ -0,0 +1,275 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/fakes"
+)
+
+// fakeClient is an in-memory Blob service.
+type fakeClient struct {
+	pageSize   int
+	failStages bool
+
+	mu      sync.Mutex
+	blobs   map[blob][]byte
+	staged  map[blob]map[string][]byte
+	commits int
+	pages   int
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{pageSize: 1000, blobs: map[blob][]byte{}, staged: map[blob]map[string][]byte{}}
+}
+
+// ListBlobs lists names in order, in pages of pageSize names. The marker is
+// the last name of the previous page.
+func (c *fakeClient) ListBlobs(_ context.Context, prefix blob, marker string) ([]string, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages++
+	var names []string
+	for b := range c.blobs {
+		if b.account == prefix.account && b.container == prefix.container && strings.HasPrefix(b.name, prefix.name) && b.name > marker {
+			names = append(names, b.name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > c.pageSize {
+		names = names[:c.pageSize]
+		return names, names[len(names)-1], nil
+	}
+	return names, "", nil
+}
+
+func (c *fakeClient) Download(_ context.Context, b blob, offset, count int64) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.blobs[b]
+	if !ok {
+		return nil, errors.New("BlobNotFound")
+	}
+	data = data[offset:]
+	if count > 0 && count < int64(len(data)) {
+		data = data[:count]
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (c *fakeClient) Size(_ context.Context, b blob) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.blobs[b]
+	if !ok {
+		return -1, errors.New("BlobNotFound")
+	}
+	return int64(len(data)), nil
+}
+
+func (c *fakeClient) StageBlock(_ context.Context, b blob, id string, data []byte) error {
+	if c.failStages {
+		return errors.New("AuthorizationFailure")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.staged[b] == nil {
+		c.staged[b] = map[string][]byte{}
+	}
+	c.staged[b][id] = append([]byte(nil), data...)
+	return nil
+}
+
+func (c *fakeClient) CommitBlockList(_ context.Context, b blob, ids []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commits++
+	data := []byte{}
+	for _, id := range ids {
+		block, ok := c.staged[b][id]
+		if !ok {
+			return fmt.Errorf("InvalidBlockList: %v", id)
+		}
+		data = append(data, block...)
+	}
+	c.blobs[b] = data
+	delete(c.staged, b)
+	return nil
+}
+
+// newClient returns the fake client, substituting for created clients.
+func (c *fakeClient) newClient(context.Context, Options) (client, error) {
+	return c, nil
+}
+
+func TestParseBlob(t *testing.T) {
+	tests := []struct {
+		path   string
+		blob   blob
+		prefix string
+		ok     bool
+	}{
+		{"azblob://acct/cont/dir/file.txt", blob{"acct", "cont", "dir/file.txt"}, "azblob://acct/cont/", true},
+		{"abfss://cont@acct.dfs.core.windows.net/dir/file.txt", blob{"acct", "cont", "dir/file.txt"}, "abfss://cont@acct.dfs.core.windows.net/", true},
+		{"azblob://acct/cont", blob{}, "", false},
+		{"azblob://acct/cont/", blob{}, "", false},
+		{"abfss://acct.dfs.core.windows.net/file.txt", blob{}, "", false},
+		{"s3://bucket/key", blob{}, "", false},
+	}
+	for _, test := range tests {
+		b, prefix, err := parseBlob(test.path)
+		if (err == nil) != test.ok || b != test.blob || prefix != test.prefix {
+			t.Errorf("parseBlob(%q) = (%v, %q, %v), want (%v, %q, ok=%v)", test.path, b, prefix, err, test.blob, test.prefix, test.ok)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	c := newFakeClient()
+	c.pageSize = 2
+	for _, name := range []string{"dir/a.txt", "dir/b.txt", "dir/c.csv", "dir/d.txt", "other/e.txt"} {
+		c.blobs[blob{"acct", "cont", name}] = []byte(name)
+	}
+	fakes.Override(t, &newClient, c.newClient)
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	got, err := fs.List(ctx, "azblob://acct/cont/dir/*.txt")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := "[azblob://acct/cont/dir/a.txt azblob://acct/cont/dir/b.txt azblob://acct/cont/dir/d.txt]"; fmt.Sprint(got) != want {
+		t.Errorf("List(dir/*.txt) = %v, want %v", got, want)
+	}
+	if c.pages != 2 {
+		t.Errorf("List(dir/*.txt) listed %v pages, want 2", c.pages)
+	}
+
+	got, err = fs.List(ctx, "abfss://cont@acct.dfs.core.windows.net/other/*")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := "[abfss://cont@acct.dfs.core.windows.net/other/e.txt]"; fmt.Sprint(got) != want {
+		t.Errorf("List(other/*) = %v, want %v", got, want)
+	}
+}
+
+func TestReadRange(t *testing.T) {
+	c := newFakeClient()
+	c.blobs[blob{"acct", "cont", "file"}] = []byte("0123456789")
+	fakes.Override(t, &newClient, c.newClient)
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{3, -1, "3456789"},
+		{3, 4, "3456"},
+		{8, 10, "89"},
+		{5, 0, ""},
+	}
+	for _, test := range tests {
+		r, err := fs.(filesystem.RangeReader).OpenReadRange(ctx, "azblob://acct/cont/file", test.offset, test.length)
+		if err != nil {
+			t.Fatalf("OpenReadRange(%v, %v) failed: %v", test.offset, test.length, err)
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("OpenReadRange(%v, %v) read failed: %v", test.offset, test.length, err)
+		}
+		if string(data) != test.want {
+			t.Errorf("OpenReadRange(%v, %v) read %q, want %q", test.offset, test.length, data, test.want)
+		}
+	}
+
+	size, err := fs.Size(ctx, "azblob://acct/cont/file")
+	if err != nil || size != 10 {
+		t.Errorf("Size(file) = (%v, %v), want (10, nil)", size, err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	c := newFakeClient()
+	fakes.Override(t, &newClient, c.newClient)
+	defer SetOptions(Options{})
+	SetOptions(Options{BlockSize: 4})
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	w, err := fs.OpenWrite(ctx, "azblob://acct/cont/file")
+	if err != nil {
+		t.Fatalf("OpenWrite failed: %v", err)
+	}
+	for _, s := range []string{"012", "3456789", "a"} {
+		if _, err := io.WriteString(w, s); err != nil {
+			t.Fatalf("Write(%q) failed: %v", s, err)
+		}
+	}
+	if len(c.staged[blob{"acct", "cont", "file"}]) != 2 {
+		t.Errorf("staged %v blocks before close, want 2", len(c.staged[blob{"acct", "cont", "file"}]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := string(c.blobs[blob{"acct", "cont", "file"}]); got != "0123456789a" {
+		t.Errorf("committed %q, want 0123456789a", got)
+	}
+
+	if err := filesystem.Write(ctx, fs, "azblob://acct/cont/empty", nil); err != nil {
+		t.Fatalf("Write(empty) failed: %v", err)
+	}
+	if data, ok := c.blobs[blob{"acct", "cont", "empty"}]; !ok || len(data) != 0 {
+		t.Errorf("Write(empty) committed (%q, %v), want an empty blob", data, ok)
+	}
+}
+
+func TestWrite_Failure(t *testing.T) {
+	c := newFakeClient()
+	c.failStages = true
+	fakes.Override(t, &newClient, c.newClient)
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	if err := filesystem.Write(ctx, fs, "azblob://acct/cont/file", []byte("hello")); err == nil {
+		t.Error("Write succeeded, want error for failed block")
+	}
+	if c.commits != 0 {
+		t.Errorf("committed %v blobs after a failed block, want 0", c.commits)
+	}
+}
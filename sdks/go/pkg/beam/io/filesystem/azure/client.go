@@ -0,0 +1,220 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// client is the subset of the Blob service used by the file system.
+type client interface {
+	// ListBlobs returns a page of the names of blobs with the given prefix,
+	// starting at marker, and the marker of the next page. The marker of
+	// the last page is empty.
+	ListBlobs(ctx context.Context, prefix blob, marker string) ([]string, string, error)
+	// Download reads count bytes of a blob from offset. A zero count reads
+	// to the end of the blob.
+	Download(ctx context.Context, b blob, offset, count int64) (io.ReadCloser, error)
+	// Size returns the size of a blob in bytes.
+	Size(ctx context.Context, b blob) (int64, error)
+	// StageBlock uploads an uncommitted block of a block blob.
+	StageBlock(ctx context.Context, b blob, id string, data []byte) error
+	// CommitBlockList creates or replaces a block blob with the given staged
+	// blocks, in order.
+	CommitBlockList(ctx context.Context, b blob, ids []string) error
+}
+
+// storageResource is the OAuth resource of Azure Storage.
+const storageResource = "https://storage.azure.com/"
+
+// newClient creates a Blob service client. It is a variable so tests can
+// substitute a fake service.
+var newClient = func(ctx context.Context, opts Options) (client, error) {
+	c := &serviceClient{opts: opts, pipelines: make(map[string]pipeline.Pipeline)}
+	switch {
+	case opts.AccountKey != "":
+		// Shared key credentials are bound to an account, so they are
+		// created with the pipeline of each account.
+	case opts.UseManagedIdentity:
+		cred, err := managedIdentityCredential(opts.ManagedIdentityClientID)
+		if err != nil {
+			return nil, err
+		}
+		c.cred = cred
+	default:
+		c.cred = azblob.NewAnonymousCredential()
+	}
+	return c, nil
+}
+
+// managedIdentityCredential returns a credential with tokens of the managed
+// identity, which are refreshed before they expire.
+func managedIdentityCredential(clientID string) (azblob.Credential, error) {
+	endpoint, err := adal.GetMSIVMEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	var spt *adal.ServicePrincipalToken
+	if clientID != "" {
+		spt, err = adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(endpoint, storageResource, clientID)
+	} else {
+		spt, err = adal.NewServicePrincipalTokenFromMSI(endpoint, storageResource)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := spt.Refresh(); err != nil {
+		return nil, errors.Wrap(err, "failed to get managed identity token")
+	}
+	return azblob.NewTokenCredential(spt.Token().AccessToken, func(c azblob.TokenCredential) time.Duration {
+		if err := spt.Refresh(); err != nil {
+			// Retry soon, rather than stopping refreshes altogether.
+			return time.Minute
+		}
+		t := spt.Token()
+		c.SetToken(t.AccessToken)
+		return time.Until(t.Expires()) - 5*time.Minute
+	}), nil
+}
+
+// serviceClient is a client of the Blob service of any account.
+type serviceClient struct {
+	opts Options
+	cred azblob.Credential
+
+	mu        sync.Mutex
+	pipelines map[string]pipeline.Pipeline // account -> pipeline
+}
+
+// pipeline returns the request pipeline of the account.
+func (c *serviceClient) pipeline(account string) (pipeline.Pipeline, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.pipelines[account]; ok {
+		return p, nil
+	}
+	cred := c.cred
+	if c.opts.AccountKey != "" {
+		key, err := azblob.NewSharedKeyCredential(account, c.opts.AccountKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key for account %v", account)
+		}
+		cred = key
+	}
+	p := azblob.NewPipeline(cred, azblob.PipelineOptions{
+		Retry: azblob.RetryOptions{MaxTries: int32(c.opts.MaxRetries) + 1},
+	})
+	c.pipelines[account] = p
+	return p, nil
+}
+
+// containerURL returns the URL of the container of the blob.
+func (c *serviceClient) containerURL(b blob) (azblob.ContainerURL, error) {
+	endpoint := c.opts.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%v.blob.core.windows.net", b.account)
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/") + "/" + b.container)
+	if err != nil {
+		return azblob.ContainerURL{}, errors.Wrapf(err, "invalid Azure endpoint %q", endpoint)
+	}
+	if c.opts.SASToken != "" {
+		u.RawQuery = strings.TrimPrefix(c.opts.SASToken, "?")
+	}
+	p, err := c.pipeline(b.account)
+	if err != nil {
+		return azblob.ContainerURL{}, err
+	}
+	return azblob.NewContainerURL(*u, p), nil
+}
+
+func (c *serviceClient) ListBlobs(ctx context.Context, prefix blob, marker string) ([]string, string, error) {
+	cu, err := c.containerURL(prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	m := azblob.Marker{}
+	if marker != "" {
+		m.Val = &marker
+	}
+	resp, err := cu.ListBlobsFlatSegment(ctx, m, azblob.ListBlobsSegmentOptions{Prefix: prefix.name})
+	if err != nil {
+		return nil, "", err
+	}
+	var names []string
+	for _, item := range resp.Segment.BlobItems {
+		names = append(names, item.Name)
+	}
+	var next string
+	if resp.NextMarker.NotDone() {
+		next = *resp.NextMarker.Val
+	}
+	return names, next, nil
+}
+
+func (c *serviceClient) Download(ctx context.Context, b blob, offset, count int64) (io.ReadCloser, error) {
+	cu, err := c.containerURL(b)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cu.NewBlobURL(b.name).Download(ctx, offset, count, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: c.opts.MaxRetries}), nil
+}
+
+func (c *serviceClient) Size(ctx context.Context, b blob) (int64, error) {
+	cu, err := c.containerURL(b)
+	if err != nil {
+		return -1, err
+	}
+	resp, err := cu.NewBlobURL(b.name).GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return -1, err
+	}
+	return resp.ContentLength(), nil
+}
+
+func (c *serviceClient) StageBlock(ctx context.Context, b blob, id string, data []byte) error {
+	cu, err := c.containerURL(b)
+	if err != nil {
+		return err
+	}
+	_, err = cu.NewBlockBlobURL(b.name).StageBlock(ctx, id, bytes.NewReader(data), azblob.LeaseAccessConditions{}, nil, azblob.ClientProvidedKeyOptions{})
+	return err
+}
+
+func (c *serviceClient) CommitBlockList(ctx context.Context, b blob, ids []string) error {
+	cu, err := c.containerURL(b)
+	if err != nil {
+		return err
+	}
+	_, err = cu.NewBlockBlobURL(b.name).CommitBlockList(ctx, ids, azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{})
+	return err
+}
@@ -23,6 +23,7 @@ import (
 	"io"
 	"strings"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 )
 
@@ -65,6 +66,14 @@ type Interface interface {
 	Size(ctx context.Context, filename string) (int64, error)
 }
 
+// RangeReader is an optional interface for file systems that can read part
+// of a file without reading its beginning.
+type RangeReader interface {
+	// OpenReadRange opens a file for reading length bytes from the given
+	// offset. A negative length reads to the end of the file.
+	OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error)
+}
+
 func getScheme(path string) string {
 	if index := strings.Index(path, "://"); index > 0 {
 		return path[:index]
@@ -72,6 +81,26 @@ func getScheme(path string) string {
 	return "default"
 }
 
+// LineageFQN returns the fully qualified name of the file with the given path,
+// for the lineage metrics of the IOs reading or writing it. The system of the
+// file is its scheme, "gcs" for the "gs" scheme, or "filesystem" for local
+// files, and its segments are the bucket or host, and the path within it.
+func LineageFQN(path string) string {
+	scheme := getScheme(path)
+	if scheme == "default" {
+		return metrics.LineageFQN("filesystem", "localhost", path)
+	}
+	rest := strings.TrimPrefix(path, scheme+"://")
+	if scheme == "gs" {
+		scheme = "gcs"
+	}
+	segments := strings.SplitN(rest, "/", 2)
+	if len(segments) == 2 && segments[1] == "" {
+		segments = segments[:1]
+	}
+	return metrics.LineageFQN(scheme, segments...)
+}
+
 // ValidateScheme panics if the given path's scheme does not have a
 // corresponding file system registered.
 func ValidateScheme(path string) {
@@ -19,12 +19,15 @@ package gcs
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"cloud.google.com/go/storage"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
@@ -37,24 +40,94 @@ func init() {
 	filesystem.Register("gs", New)
 }
 
+// optionsKey is the pipeline option holding the encoded Options.
+const optionsKey = "gcs_filesystem_options"
+
+// Options configures the GCS file system.
+type Options struct {
+	// ChunkSize is the number of bytes buffered and sent in each request of a
+	// resumable upload. Objects smaller than ChunkSize are uploaded in a
+	// single request. A failed chunk is retried by the client, so larger
+	// chunks use more memory but fewer requests. If zero, the client default
+	// of 16MiB is used. If negative, uploads are not resumable.
+	ChunkSize int `json:"chunkSize,omitempty"`
+	// MaxRetries is the number of times an operation that failed with a
+	// transient error is retried. If zero, operations are retried 5 times.
+	// If negative, operations are not retried.
+	MaxRetries int `json:"maxRetries,omitempty"`
+	// KMSKeyName is the Cloud KMS key used to encrypt written objects, such as
+	// "projects/p/locations/l/keyRings/r/cryptoKeys/k". If empty, the
+	// bucket's default encryption is used.
+	KMSKeyName string `json:"kmsKeyName,omitempty"`
+	// ContentType is the content type of written objects. If empty, it is
+	// detected from the object's content.
+	ContentType string `json:"contentType,omitempty"`
+	// Metadata is the custom metadata of written objects.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// SetOptions configures the GCS file system for the pipeline. The options are
+// carried to remote workers as pipeline options, so they must be set before
+// the pipeline is executed.
+func SetOptions(opts Options) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to encode GCS options"))
+	}
+	runtime.GlobalOptions.Set(optionsKey, string(data))
+}
+
+// options returns the options of the pipeline.
+func options() (Options, error) {
+	var opts Options
+	if data := runtime.GlobalOptions.Get(optionsKey); data != "" {
+		if err := json.Unmarshal([]byte(data), &opts); err != nil {
+			return Options{}, errors.Wrap(err, "failed to decode GCS options")
+		}
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 5
+	}
+	return opts, nil
+}
+
 type fs struct {
 	client *storage.Client
+	opts   Options
 }
 
 // New creates a new Google Cloud Storage filesystem using application
 // default credentials. If it fails, it falls back to unauthenticated
-// access.
+// access. If the STORAGE_EMULATOR_HOST variable is set, to the host and
+// port of an emulator, the file system connects to the emulator instead. The file system is configured with the options given to
+// SetOptions.
+//
+// Operations that fail with transient errors, such as server errors or rate
+// limiting, are retried with exponential backoff. Objects are read as
+// streams that resume from their last position, at the same object
+// generation, if the connection fails. The file system implements
+// filesystem.RangeReader.
 func New(ctx context.Context) filesystem.Interface {
-	client, err := storage.NewClient(ctx, option.WithScopes(storage.ScopeReadWrite))
+	opts, err := options()
+	if err != nil {
+		panic(err)
+	}
+	var endpoint []option.ClientOption
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		// The client derives only the hosts of downloads and uploads from
+		// the emulator host, so the JSON API endpoint is set explicitly.
+		endpoint = append(endpoint, option.WithEndpoint("http://"+host+"/storage/v1/"))
+	}
+	client, err := storage.NewClient(ctx, append(endpoint, option.WithScopes(storage.ScopeReadWrite))...)
 	if err != nil {
 		log.Warnf(ctx, "Warning: falling back to unauthenticated GCS access: %v", err)
 
-		client, err = storage.NewClient(ctx, option.WithoutAuthentication())
+		client, err = storage.NewClient(ctx, append(endpoint, option.WithoutAuthentication())...)
 		if err != nil {
 			panic(errors.Wrapf(err, "failed to create GCS client"))
 		}
 	}
-	return &fs{client: client}
+	return &fs{client: client, opts: opts}
 }
 
 func (f *fs) Close() error {
@@ -73,25 +146,31 @@ func (f *fs) List(ctx context.Context, glob string) ([]string, error) {
 		// For now, we assume * is the first matching character to make a
 		// prefix listing and not list the entire bucket.
 
-		it := f.client.Bucket(bucket).Objects(ctx, &storage.Query{
-			Prefix: object[:index],
-		})
-		for {
-			obj, err := it.Next()
-			if err == iterator.Done {
-				break
-			}
-			if err != nil {
-				return nil, err
-			}
+		err := retry(ctx, f.opts.MaxRetries, func() error {
+			candidates = nil
+			it := f.client.Bucket(bucket).Objects(ctx, &storage.Query{
+				Prefix: object[:index],
+			})
+			for {
+				obj, err := it.Next()
+				if err == iterator.Done {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
 
-			match, err := filepath.Match(object, obj.Name)
-			if err != nil {
-				return nil, err
-			}
-			if match {
-				candidates = append(candidates, obj.Name)
+				match, err := filepath.Match(object, obj.Name)
+				if err != nil {
+					return permanent{err}
+				}
+				if match {
+					candidates = append(candidates, obj.Name)
+				}
 			}
+		})
+		if err != nil {
+			return nil, err
 		}
 	} else {
 		// Single object.
@@ -106,23 +185,58 @@ func (f *fs) List(ctx context.Context, glob string) ([]string, error) {
 }
 
 func (f *fs) OpenRead(ctx context.Context, filename string) (io.ReadCloser, error) {
+	return f.OpenReadRange(ctx, filename, 0, -1)
+}
+
+// OpenReadRange opens an object for reading length bytes from the given
+// offset. A negative length reads to the end of the object.
+func (f *fs) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
 	bucket, object, err := gcsx.ParseObject(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	return f.client.Bucket(bucket).Object(object).NewReader(ctx)
+	obj := f.client.Bucket(bucket).Object(object)
+	var generation int64
+	open := func(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+		o := obj
+		if generation != 0 {
+			// Resume reading the same content, even if the object has since
+			// been overwritten.
+			o = obj.Generation(generation)
+		}
+		r, err := o.NewRangeReader(ctx, offset, length)
+		if err != nil {
+			return nil, err
+		}
+		generation = r.Attrs.Generation
+		return r, nil
+	}
+	return newResumingReader(ctx, open, offset, length, f.opts.MaxRetries)
 }
 
 // TODO(herohde) 7/12/2017: should we create the bucket in OpenWrite? For now, "no".
 
+// OpenWrite opens an object for writing. Objects are uploaded with resumable
+// uploads in chunks of the configured size, and failed chunks are retried by
+// the client.
 func (f *fs) OpenWrite(ctx context.Context, filename string) (io.WriteCloser, error) {
 	bucket, object, err := gcsx.ParseObject(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	return f.client.Bucket(bucket).Object(object).NewWriter(ctx), nil
+	w := f.client.Bucket(bucket).Object(object).NewWriter(ctx)
+	switch {
+	case f.opts.ChunkSize > 0:
+		w.ChunkSize = f.opts.ChunkSize
+	case f.opts.ChunkSize < 0:
+		w.ChunkSize = 0
+	}
+	w.KMSKeyName = f.opts.KMSKeyName
+	w.ContentType = f.opts.ContentType
+	w.Metadata = f.opts.Metadata
+	return w, nil
 }
 
 func (f *fs) Size(ctx context.Context, filename string) (int64, error) {
@@ -132,7 +246,11 @@ func (f *fs) Size(ctx context.Context, filename string) (int64, error) {
 	}
 
 	obj := f.client.Bucket(bucket).Object(object)
-	attrs, err := obj.Attrs(ctx)
+	var attrs *storage.ObjectAttrs
+	err = retry(ctx, f.opts.MaxRetries, func() error {
+		attrs, err = obj.Attrs(ctx)
+		return err
+	})
 	if err != nil {
 		return -1, err
 	}
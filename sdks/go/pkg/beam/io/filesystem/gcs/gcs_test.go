@@ -0,0 +1,148 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/fakes"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestList(t *testing.T) {
+	srv := fakes.NewGCSServer(t)
+	for _, name := range []string{"in/a.txt", "in/b.txt", "in/c.csv", "out/a.txt"} {
+		srv.Put("bucket", name, []byte(name))
+	}
+	ctx := context.Background()
+	f := New(ctx)
+	defer f.Close()
+
+	got, err := f.List(ctx, "gs://bucket/in/*.txt")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if d := cmp.Diff([]string{"gs://bucket/in/a.txt", "gs://bucket/in/b.txt"}, got); d != "" {
+		t.Errorf("List(gs://bucket/in/*.txt) (-want +got):\n%v", d)
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunkSize int
+		size      int
+		// chunks is the number of chunks of a resumable upload.
+		chunks int
+	}{
+		{"multipart", 0, 1000, 0},
+		{"resumable", 256 << 10, 600 << 10, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := fakes.NewGCSServer(t)
+			defer SetOptions(Options{})
+			SetOptions(Options{ChunkSize: test.chunkSize, ContentType: "text/plain"})
+			ctx := context.Background()
+			f := New(ctx)
+			defer f.Close()
+
+			data := bytes.Repeat([]byte("0123456789"), test.size/10)
+			w, err := f.OpenWrite(ctx, "gs://bucket/dir/file")
+			if err != nil {
+				t.Fatalf("OpenWrite failed: %v", err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			if got, _ := srv.Object("bucket", "dir/file"); !bytes.Equal(got, data) {
+				t.Fatalf("stored %d bytes, want %d", len(got), len(data))
+			}
+			chunks := 0
+			for _, r := range srv.Requests() {
+				if strings.Contains(r.Path, "upload_id=") {
+					chunks++
+				}
+			}
+			if chunks != test.chunks {
+				t.Errorf("uploaded %d chunks, want %d", chunks, test.chunks)
+			}
+
+			size, err := f.Size(ctx, "gs://bucket/dir/file")
+			if err != nil || size != int64(len(data)) {
+				t.Errorf("Size() = %v, %v, want %v", size, err, len(data))
+			}
+			r, err := f.OpenRead(ctx, "gs://bucket/dir/file")
+			if err != nil {
+				t.Fatalf("OpenRead failed: %v", err)
+			}
+			defer r.Close()
+			if got, err := ioutil.ReadAll(r); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("read %d bytes, %v, want %d", len(got), err, len(data))
+			}
+		})
+	}
+}
+
+func TestReadRange(t *testing.T) {
+	srv := fakes.NewGCSServer(t)
+	srv.Put("bucket", "file", []byte("0123456789"))
+	ctx := context.Background()
+	f := New(ctx).(*fs)
+	defer f.Close()
+
+	r, err := f.OpenReadRange(ctx, "gs://bucket/file", 3, 4)
+	if err != nil {
+		t.Fatalf("OpenReadRange failed: %v", err)
+	}
+	defer r.Close()
+	if got, err := ioutil.ReadAll(r); err != nil || string(got) != "3456" {
+		t.Errorf("OpenReadRange(3, 4) read %q, %v, want 3456", got, err)
+	}
+}
+
+func TestSize_Retry(t *testing.T) {
+	defer withFastBackoff()()
+	srv := fakes.NewGCSServer(t)
+	srv.Put("bucket", "file", []byte("abc"))
+	ctx := context.Background()
+	f := New(ctx)
+	defer f.Close()
+
+	before := len(srv.Requests())
+	srv.FailNext(2, http.StatusServiceUnavailable)
+	size, err := f.Size(ctx, "gs://bucket/file")
+	if err != nil || size != 3 {
+		t.Fatalf("Size() = %v, %v, want 3", size, err)
+	}
+	if n := len(srv.Requests()) - before; n < 3 {
+		t.Errorf("sent %d requests, want at least 3 for 2 failures", n)
+	}
+
+	if _, err := f.Size(ctx, "gs://bucket/missing"); !errors.Is(err, storage.ErrObjectNotExist) {
+		t.Errorf("Size() of a missing object failed with %v, want %v", err, storage.ErrObjectNotExist)
+	}
+}
@@ -0,0 +1,178 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+var (
+	// initialBackoff is the delay before the first retry of an operation,
+	// which doubles for each further retry up to maxBackoff. They are
+	// variables so tests can shorten them.
+	initialBackoff = 200 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+)
+
+// permanent wraps an error that should not be retried, regardless of its
+// cause.
+type permanent struct {
+	err error
+}
+
+func (p permanent) Error() string { return p.err.Error() }
+
+// isTransient returns whether an error is likely to succeed if retried.
+func isTransient(err error) bool {
+	var p permanent
+	if errors.As(err, &p) {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests,
+			http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// backoff returns the delay before the given retry, with jitter.
+func backoff(retry int) time.Duration {
+	d := initialBackoff << uint(retry)
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// sleep waits for the given duration, or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retry calls fn until it succeeds, it fails with an error that is not
+// transient, or it has been retried maxRetries times.
+func retry(ctx context.Context, maxRetries int, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if !isTransient(err) || attempt >= maxRetries {
+			var p permanent
+			if errors.As(err, &p) {
+				return p.err
+			}
+			return err
+		}
+		if err := sleep(ctx, backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// resumingReader reads a byte range of an object as a stream. If reading fails
+// with a transient error, the stream is reopened at the next unread offset.
+type resumingReader struct {
+	ctx        context.Context
+	open       func(ctx context.Context, offset, length int64) (io.ReadCloser, error)
+	offset     int64 // Offset of the next byte to read.
+	remaining  int64 // Number of bytes left to read, or negative if unbounded.
+	maxRetries int
+	failures   int // Number of consecutive failed reads.
+	r          io.ReadCloser
+}
+
+func newResumingReader(ctx context.Context, open func(ctx context.Context, offset, length int64) (io.ReadCloser, error), offset, length int64, maxRetries int) (*resumingReader, error) {
+	rr := &resumingReader{ctx: ctx, open: open, offset: offset, remaining: length, maxRetries: maxRetries}
+	if err := rr.reopen(); err != nil {
+		return nil, err
+	}
+	return rr, nil
+}
+
+func (rr *resumingReader) reopen() error {
+	return retry(rr.ctx, rr.maxRetries, func() error {
+		r, err := rr.open(rr.ctx, rr.offset, rr.remaining)
+		rr.r = r
+		return err
+	})
+}
+
+func (rr *resumingReader) Read(p []byte) (int, error) {
+	for {
+		if rr.remaining == 0 {
+			return 0, io.EOF
+		}
+		if rr.r == nil {
+			if err := rr.reopen(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := rr.r.Read(p)
+		rr.offset += int64(n)
+		if rr.remaining > 0 {
+			rr.remaining -= int64(n)
+		}
+		if n > 0 {
+			rr.failures = 0
+		}
+		if err == nil || err == io.EOF || !isTransient(err) || rr.failures >= rr.maxRetries {
+			return n, err
+		}
+
+		rr.r.Close()
+		rr.r = nil
+		if n > 0 {
+			return n, nil // Reopen on the next read.
+		}
+		if err := sleep(rr.ctx, backoff(rr.failures)); err != nil {
+			return 0, err
+		}
+		rr.failures++
+	}
+}
+
+func (rr *resumingReader) Close() error {
+	if rr.r == nil {
+		return nil
+	}
+	return rr.r.Close()
+}
@@ -0,0 +1,133 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func withFastBackoff() func() {
+	origInitial, origMax := initialBackoff, maxBackoff
+	initialBackoff, maxBackoff = time.Millisecond, time.Millisecond
+	return func() { initialBackoff, maxBackoff = origInitial, origMax }
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&googleapi.Error{Code: 503}, true},
+		{&googleapi.Error{Code: 429}, true},
+		{fmt.Errorf("wrapped: %w", &googleapi.Error{Code: 500}), true},
+		{&googleapi.Error{Code: 404}, false},
+		{&googleapi.Error{Code: 403}, false},
+		{io.ErrUnexpectedEOF, true},
+		{permanent{io.ErrUnexpectedEOF}, false},
+		{errors.New("bad glob"), false},
+	}
+	for _, test := range tests {
+		if got := isTransient(test.err); got != test.want {
+			t.Errorf("isTransient(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	defer withFastBackoff()()
+
+	var calls int
+	err := retry(context.Background(), 3, func() error {
+		calls++
+		if calls < 3 {
+			return &googleapi.Error{Code: 503}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("retry = %v after %v calls, want success after 3 calls", err, calls)
+	}
+
+	calls = 0
+	err = retry(context.Background(), 3, func() error {
+		calls++
+		return &googleapi.Error{Code: 404}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("retry = %v after %v calls, want failure after 1 call", err, calls)
+	}
+}
+
+// flakyReader returns an error after reading a limited number of bytes.
+type flakyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func (f *flakyReader) Close() error { return nil }
+
+// TestResumingReader tests that reads resume after transient failures from
+// the offset of the last byte read.
+func TestResumingReader(t *testing.T) {
+	defer withFastBackoff()()
+
+	const content = "0123456789abcdefghij"
+	var offsets []int64
+	open := func(_ context.Context, offset, length int64) (io.ReadCloser, error) {
+		offsets = append(offsets, offset)
+		end := int64(len(content))
+		if length >= 0 {
+			end = offset + length
+		}
+		if len(offsets) < 3 {
+			// Fail after 4 bytes on the first two connections.
+			return &flakyReader{r: strings.NewReader(content[offset : offset+4]), err: io.ErrUnexpectedEOF}, nil
+		}
+		return ioutil.NopCloser(strings.NewReader(content[offset:end])), nil
+	}
+
+	r, err := newResumingReader(context.Background(), open, 2, 15, 2)
+	if err != nil {
+		t.Fatalf("newResumingReader failed: %v", err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if want := content[2:17]; string(got) != want {
+		t.Errorf("read %q, want %q", got, want)
+	}
+	if want := "[2 6 10]"; fmt.Sprint(offsets) != want {
+		t.Errorf("opened at offsets %v, want %v", offsets, want)
+	}
+}
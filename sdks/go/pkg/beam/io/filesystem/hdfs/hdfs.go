@@ -0,0 +1,279 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hdfs contains a Hadoop Distributed File System (HDFS)
+// implementation of the Beam file system, which accesses the cluster through
+// the WebHDFS REST API of its NameNode. Files are named by paths of the form
+//
+//	hdfs://<namenode>/<path>
+//
+// Clusters with Kerberos security are accessed with SPNEGO authentication,
+// using a keytab or credential cache available on every worker.
+//
+// Experimental.
+package hdfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
+)
+
+func init() {
+	filesystem.Register("hdfs", New)
+}
+
+// optionsKey is the pipeline option holding the encoded Options.
+const optionsKey = "hdfs_filesystem_options"
+
+// Options configures the HDFS file system.
+type Options struct {
+	// Address is the WebHDFS URL of the NameNode, such as
+	// "http://namenode:9870", or "https://namenode:9871" for clusters that
+	// only serve WebHDFS over TLS. If empty, the host of each path is used
+	// with the default WebHDFS port of 9870.
+	Address string `json:"address,omitempty"`
+	// User is the user files are accessed as on clusters without Kerberos
+	// security. If empty, the HADOOP_USER_NAME or USER environment variable
+	// of the worker is used.
+	User string `json:"user,omitempty"`
+	// KerberosPrincipal is the principal the pipeline authenticates as, such
+	// as "beam@EXAMPLE.COM". Setting it, or KerberosCCache, enables
+	// Kerberos authentication.
+	KerberosPrincipal string `json:"kerberosPrincipal,omitempty"`
+	// KerberosKeytab is the path of the keytab of KerberosPrincipal on the
+	// workers.
+	KerberosKeytab string `json:"kerberosKeytab,omitempty"`
+	// KerberosCCache is the path of a credential cache on the workers, such
+	// as one created by kinit. It is used if KerberosKeytab is empty.
+	KerberosCCache string `json:"kerberosCCache,omitempty"`
+	// Krb5Config is the path of the Kerberos configuration on the workers.
+	// If empty, /etc/krb5.conf is used.
+	Krb5Config string `json:"krb5Config,omitempty"`
+	// ServicePrincipal is the service principal of the NameNode's HTTP
+	// endpoint. If empty, "HTTP/<namenode host>" is used.
+	ServicePrincipal string `json:"servicePrincipal,omitempty"`
+	// Replication is the replication factor of written files. If zero, the
+	// cluster default is used.
+	Replication int `json:"replication,omitempty"`
+	// BlockSize is the block size of written files in bytes. If zero, the
+	// cluster default is used.
+	BlockSize int64 `json:"blockSize,omitempty"`
+}
+
+// SetOptions configures the HDFS file system for the pipeline. The options
+// are carried to remote workers as pipeline options, so they must be set
+// before the pipeline is executed.
+func SetOptions(opts Options) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to encode HDFS options"))
+	}
+	runtime.GlobalOptions.Set(optionsKey, string(data))
+}
+
+// options returns the options of the pipeline.
+func options() (Options, error) {
+	var opts Options
+	if data := runtime.GlobalOptions.Get(optionsKey); data != "" {
+		if err := json.Unmarshal([]byte(data), &opts); err != nil {
+			return Options{}, errors.Wrap(err, "failed to decode HDFS options")
+		}
+	}
+	return opts, nil
+}
+
+type fs struct {
+	opts Options
+
+	mu      sync.Mutex
+	clients map[string]*client // NameNode address -> client
+}
+
+// New creates a new HDFS filesystem, configured with the options given to
+// SetOptions. The file system implements filesystem.RangeReader.
+func New(ctx context.Context) filesystem.Interface {
+	opts, err := options()
+	if err != nil {
+		panic(err)
+	}
+	return &fs{opts: opts, clients: make(map[string]*client)}
+}
+
+func (f *fs) Close() error {
+	return nil
+}
+
+// parsePath splits an hdfs:// path into its NameNode host and absolute path.
+func parsePath(p string) (host, name string, err error) {
+	u, err := url.Parse(p)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "invalid HDFS path %q", p)
+	}
+	if u.Scheme != "hdfs" || u.Path == "" || u.Path == "/" {
+		return "", "", errors.Errorf("invalid HDFS path %q: want hdfs://namenode/path", p)
+	}
+	return u.Host, u.Path, nil
+}
+
+// client returns the WebHDFS client for the NameNode of the given path.
+func (f *fs) client(p string) (*client, string, error) {
+	host, name, err := parsePath(p)
+	if err != nil {
+		return nil, "", err
+	}
+	addr := f.opts.Address
+	if addr == "" {
+		if host == "" {
+			return nil, "", errors.Errorf("invalid HDFS path %q: no NameNode address is configured", p)
+		}
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			// The port of the path is the RPC port of the NameNode.
+			host = host[:i]
+		}
+		addr = "http://" + host + ":9870"
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.clients[addr]; ok {
+		return c, name, nil
+	}
+	c, err := newClient(addr, f.opts)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to create WebHDFS client for %v", addr)
+	}
+	f.clients[addr] = c
+	return c, name, nil
+}
+
+// hasMeta returns whether the path component contains glob characters.
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// List expands a pattern to the files it matches. Wildcards may appear in
+// any component of the path, and match within that component only.
+func (f *fs) List(ctx context.Context, glob string) ([]string, error) {
+	c, name, err := f.client(glob)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimSuffix(glob, name)
+	if !hasMeta(name) {
+		// Single file.
+		return []string{glob}, nil
+	}
+
+	comps := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	dirs := []string{"/"}
+	for i, comp := range comps {
+		last := i == len(comps)-1
+		if !hasMeta(comp) {
+			for j := range dirs {
+				dirs[j] = path.Join(dirs[j], comp)
+			}
+			continue
+		}
+		var matches []string
+		for _, dir := range dirs {
+			entries, err := c.listStatus(ctx, dir)
+			if isNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to list %v", glob)
+			}
+			for _, e := range entries {
+				if (last && e.Type != "FILE") || (!last && e.Type != "DIRECTORY") {
+					continue
+				}
+				match, err := filepath.Match(comp, e.PathSuffix)
+				if err != nil {
+					return nil, err
+				}
+				if match {
+					matches = append(matches, path.Join(dir, e.PathSuffix))
+				}
+			}
+		}
+		dirs = matches
+	}
+
+	var ret []string
+	for _, dir := range dirs {
+		ret = append(ret, prefix+dir)
+	}
+	return ret, nil
+}
+
+func (f *fs) OpenRead(ctx context.Context, filename string) (io.ReadCloser, error) {
+	return f.OpenReadRange(ctx, filename, 0, -1)
+}
+
+// OpenReadRange opens a file for reading length bytes from the given offset.
+// A negative length reads to the end of the file.
+func (f *fs) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	c, name, err := f.client(filename)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+	r, err := c.open(ctx, name, offset, length)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %v", filename)
+	}
+	return r, nil
+}
+
+// OpenWrite opens a file for writing, replacing any existing file. The
+// content is streamed to a DataNode as it is written, and the file is
+// complete when the writer is closed.
+func (f *fs) OpenWrite(ctx context.Context, filename string) (io.WriteCloser, error) {
+	c, name, err := f.client(filename)
+	if err != nil {
+		return nil, err
+	}
+	w, err := c.create(ctx, name, f.opts.Replication, f.opts.BlockSize)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create %v", filename)
+	}
+	return w, nil
+}
+
+func (f *fs) Size(ctx context.Context, filename string) (int64, error) {
+	c, name, err := f.client(filename)
+	if err != nil {
+		return -1, err
+	}
+	st, err := c.getFileStatus(ctx, name)
+	if err != nil {
+		return -1, errors.Wrapf(err, "failed to get size of %v", filename)
+	}
+	return st.Length, nil
+}
@@ -0,0 +1,257 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hdfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
+)
+
+// fakeServer is an in-memory WebHDFS NameNode, which redirects reads and
+// writes to its own DataNode endpoint.
+type fakeServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	files map[string][]byte
+	users []string
+}
+
+func newFakeServer(files map[string][]byte) *fakeServer {
+	s := &fakeServer{files: files}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *fakeServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := r.URL.Query()
+	if p := strings.TrimPrefix(r.URL.Path, "/data"); p != r.URL.Path {
+		s.handleData(w, r, p)
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, "/webhdfs/v1")
+	s.users = append(s.users, q.Get("user.name"))
+
+	switch q.Get("op") {
+	case "GETFILESTATUS":
+		data, ok := s.files[p]
+		if !ok {
+			notFound(w, p)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"FileStatus": fileStatus{Type: "FILE", Length: int64(len(data))}})
+	case "LISTSTATUS":
+		entries := map[string]string{}
+		for name := range s.files {
+			if rest := strings.TrimPrefix(name, strings.TrimSuffix(p, "/")+"/"); rest != name {
+				if i := strings.Index(rest, "/"); i >= 0 {
+					entries[rest[:i]] = "DIRECTORY"
+				} else {
+					entries[rest] = "FILE"
+				}
+			}
+		}
+		if len(entries) == 0 {
+			notFound(w, p)
+			return
+		}
+		var statuses []fileStatus
+		for name, typ := range entries {
+			statuses = append(statuses, fileStatus{PathSuffix: name, Type: typ})
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].PathSuffix < statuses[j].PathSuffix })
+		json.NewEncoder(w).Encode(map[string]interface{}{"FileStatuses": map[string]interface{}{"FileStatus": statuses}})
+	case "OPEN", "CREATE":
+		if _, ok := s.files[p]; !ok && q.Get("op") == "OPEN" {
+			notFound(w, p)
+			return
+		}
+		q.Del("user.name")
+		http.Redirect(w, r, s.URL+"/data"+p+"?"+q.Encode(), http.StatusTemporaryRedirect)
+	default:
+		http.Error(w, "unsupported", http.StatusBadRequest)
+	}
+}
+
+func (s *fakeServer) handleData(w http.ResponseWriter, r *http.Request, p string) {
+	q := r.URL.Query()
+	switch q.Get("op") {
+	case "OPEN":
+		data := s.files[p]
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		data = data[offset:]
+		if length, err := strconv.Atoi(q.Get("length")); err == nil && length < len(data) {
+			data = data[:length]
+		}
+		w.Write(data)
+	case "CREATE":
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if strings.HasPrefix(p, "/readonly/") {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"RemoteException": remoteError{Exception: "AccessControlException", Message: "Permission denied: " + p}})
+			return
+		}
+		s.files[p] = data
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func notFound(w http.ResponseWriter, p string) {
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]interface{}{"RemoteException": remoteError{Exception: "FileNotFoundException", Message: "File does not exist: " + p}})
+}
+
+// withServer configures the file system to use the server, and returns a
+// function restoring the default options.
+func withServer(s *fakeServer, opts Options) func() {
+	opts.Address = s.URL
+	SetOptions(opts)
+	return func() { SetOptions(Options{}) }
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path       string
+		host, name string
+		ok         bool
+	}{
+		{"hdfs://namenode:8020/dir/file", "namenode:8020", "/dir/file", true},
+		{"hdfs:///dir/file", "", "/dir/file", true},
+		{"hdfs://namenode/", "", "", false},
+		{"s3://bucket/key", "", "", false},
+	}
+	for _, test := range tests {
+		host, name, err := parsePath(test.path)
+		if (err == nil) != test.ok || host != test.host || name != test.name {
+			t.Errorf("parsePath(%q) = (%q, %q, %v), want (%q, %q, ok=%v)", test.path, host, name, err, test.host, test.name, test.ok)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	s := newFakeServer(map[string][]byte{
+		"/logs/2021-01/a.txt":     nil,
+		"/logs/2021-01/b.csv":     nil,
+		"/logs/2021-02/c.txt":     nil,
+		"/logs/2021-02/sub/d.txt": nil,
+		"/logs/other/e.txt":       nil,
+	})
+	defer s.Close()
+	defer withServer(s, Options{User: "beam"})()
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	got, err := fs.List(ctx, "hdfs://namenode/logs/2021-*/*.txt")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := "[hdfs://namenode/logs/2021-01/a.txt hdfs://namenode/logs/2021-02/c.txt]"; fmt.Sprint(got) != want {
+		t.Errorf("List(logs/2021-*/*.txt) = %v, want %v", got, want)
+	}
+	for _, u := range s.users {
+		if u != "beam" {
+			t.Errorf("request user.name = %q, want beam", u)
+		}
+	}
+
+	got, err = fs.List(ctx, "hdfs://namenode/missing/*")
+	if err != nil || len(got) != 0 {
+		t.Errorf("List(missing/*) = (%v, %v), want no files", got, err)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	s := newFakeServer(map[string][]byte{})
+	defer s.Close()
+	defer withServer(s, Options{})()
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	if err := filesystem.Write(ctx, fs, "hdfs://namenode/dir/file", []byte("0123456789")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got := string(s.files["/dir/file"]); got != "0123456789" {
+		t.Errorf("Write stored %q, want 0123456789", got)
+	}
+
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{3, -1, "3456789"},
+		{3, 4, "3456"},
+		{5, 0, ""},
+	}
+	for _, test := range tests {
+		r, err := fs.(filesystem.RangeReader).OpenReadRange(ctx, "hdfs://namenode/dir/file", test.offset, test.length)
+		if err != nil {
+			t.Fatalf("OpenReadRange(%v, %v) failed: %v", test.offset, test.length, err)
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("OpenReadRange(%v, %v) read failed: %v", test.offset, test.length, err)
+		}
+		if string(data) != test.want {
+			t.Errorf("OpenReadRange(%v, %v) read %q, want %q", test.offset, test.length, data, test.want)
+		}
+	}
+
+	size, err := fs.Size(ctx, "hdfs://namenode/dir/file")
+	if err != nil || size != 10 {
+		t.Errorf("Size(file) = (%v, %v), want (10, nil)", size, err)
+	}
+	if _, err := fs.OpenRead(ctx, "hdfs://namenode/dir/missing"); err == nil || !strings.Contains(err.Error(), "FileNotFoundException") {
+		t.Errorf("OpenRead(missing) = %v, want FileNotFoundException", err)
+	}
+}
+
+func TestWrite_Failure(t *testing.T) {
+	s := newFakeServer(map[string][]byte{})
+	defer s.Close()
+	defer withServer(s, Options{})()
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	err := filesystem.Write(ctx, fs, "hdfs://namenode/readonly/file", []byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "AccessControlException") {
+		t.Errorf("Write(readonly/file) = %v, want AccessControlException", err)
+	}
+}
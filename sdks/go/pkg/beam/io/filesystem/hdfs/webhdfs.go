@@ -0,0 +1,325 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hdfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	krbclient "github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// doer sends HTTP requests. It is implemented by both *http.Client and the
+// SPNEGO *spnego.Client.
+type doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// client is a WebHDFS client of a NameNode.
+type client struct {
+	addr string
+	// user is the user.name of requests with simple authentication, or
+	// empty with Kerberos authentication.
+	user string
+	// namenode sends requests to the NameNode, without following
+	// redirects.
+	namenode doer
+	// datanode sends requests to the DataNode URLs the NameNode redirects
+	// to, which carry their own authorization.
+	datanode *http.Client
+}
+
+func newClient(addr string, opts Options) (*client, error) {
+	noRedirect := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	c := &client{addr: strings.TrimSuffix(addr, "/"), namenode: noRedirect, datanode: &http.Client{}}
+	if opts.KerberosPrincipal == "" && opts.KerberosCCache == "" {
+		c.user = opts.User
+		if c.user == "" {
+			c.user = os.Getenv("HADOOP_USER_NAME")
+		}
+		if c.user == "" {
+			c.user = os.Getenv("USER")
+		}
+		return c, nil
+	}
+
+	krb, err := kerberosClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	spn := opts.ServicePrincipal
+	if spn == "" {
+		u, err := url.Parse(c.addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid WebHDFS address %q", addr)
+		}
+		spn = "HTTP/" + u.Hostname()
+	}
+	c.namenode = spnego.NewClient(krb, noRedirect, spn)
+	return c, nil
+}
+
+// kerberosClient returns a Kerberos client logged in with the keytab or
+// credential cache of the options.
+func kerberosClient(opts Options) (*krbclient.Client, error) {
+	path := opts.Krb5Config
+	if path == "" {
+		path = "/etc/krb5.conf"
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load Kerberos configuration %v", path)
+	}
+
+	if opts.KerberosKeytab == "" {
+		cc, err := credentials.LoadCCache(opts.KerberosCCache)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load Kerberos credential cache %v", opts.KerberosCCache)
+		}
+		return krbclient.NewFromCCache(cc, cfg, krbclient.DisablePAFXFAST(true))
+	}
+
+	kt, err := keytab.Load(opts.KerberosKeytab)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load Kerberos keytab %v", opts.KerberosKeytab)
+	}
+	i := strings.LastIndex(opts.KerberosPrincipal, "@")
+	if i < 0 {
+		return nil, errors.Errorf("invalid Kerberos principal %q: want user@REALM", opts.KerberosPrincipal)
+	}
+	krb := krbclient.NewWithKeytab(opts.KerberosPrincipal[:i], opts.KerberosPrincipal[i+1:], kt, cfg, krbclient.DisablePAFXFAST(true))
+	if err := krb.Login(); err != nil {
+		return nil, errors.Wrapf(err, "failed to log in as %v", opts.KerberosPrincipal)
+	}
+	return krb, nil
+}
+
+// remoteError is an error returned by WebHDFS.
+type remoteError struct {
+	Status    int    `json:"-"`
+	Exception string `json:"exception"`
+	Message   string `json:"message"`
+}
+
+func (e *remoteError) Error() string {
+	if e.Exception == "" {
+		return fmt.Sprintf("WebHDFS request failed with status %v", e.Status)
+	}
+	return fmt.Sprintf("%v: %v", e.Exception, e.Message)
+}
+
+// isNotFound returns whether the error is a WebHDFS FileNotFoundException.
+func isNotFound(err error) bool {
+	re, ok := err.(*remoteError)
+	return ok && (re.Status == http.StatusNotFound || re.Exception == "FileNotFoundException")
+}
+
+// checkResponse returns the error of a response without the wanted status,
+// and closes its body.
+func checkResponse(resp *http.Response, want int) error {
+	if resp.StatusCode == want {
+		return nil
+	}
+	defer resp.Body.Close()
+	var body struct {
+		RemoteException remoteError `json:"RemoteException"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	body.RemoteException.Status = resp.StatusCode
+	return &body.RemoteException
+}
+
+// do sends a WebHDFS operation on a path to the NameNode.
+func (c *client) do(ctx context.Context, method, path, op string, params url.Values) (*http.Response, error) {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("op", op)
+	if c.user != "" {
+		params.Set("user.name", c.user)
+	}
+	u := c.addr + "/webhdfs/v1" + (&url.URL{Path: path}).EscapedPath() + "?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.namenode.Do(req)
+}
+
+// fileStatus is the status of a file or directory.
+type fileStatus struct {
+	PathSuffix string `json:"pathSuffix"`
+	Type       string `json:"type"`
+	Length     int64  `json:"length"`
+}
+
+func (c *client) getFileStatus(ctx context.Context, path string) (fileStatus, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, "GETFILESTATUS", nil)
+	if err != nil {
+		return fileStatus{}, err
+	}
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return fileStatus{}, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		FileStatus fileStatus `json:"FileStatus"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fileStatus{}, errors.Wrap(err, "invalid GETFILESTATUS response")
+	}
+	return body.FileStatus, nil
+}
+
+// listStatus returns the entries of a directory.
+func (c *client) listStatus(ctx context.Context, dir string) ([]fileStatus, error) {
+	resp, err := c.do(ctx, http.MethodGet, dir, "LISTSTATUS", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		FileStatuses struct {
+			FileStatus []fileStatus `json:"FileStatus"`
+		} `json:"FileStatuses"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, errors.Wrap(err, "invalid LISTSTATUS response")
+	}
+	return body.FileStatuses.FileStatus, nil
+}
+
+// redirect returns the DataNode location a NameNode response redirects to.
+func redirect(resp *http.Response) (string, error) {
+	if err := checkResponse(resp, http.StatusTemporaryRedirect); err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return "", errors.New("WebHDFS redirect has no location")
+	}
+	return loc, nil
+}
+
+// open reads length bytes of a file from offset. A negative length reads to
+// the end of the file.
+func (c *client) open(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	params := url.Values{}
+	if offset > 0 {
+		params.Set("offset", strconv.FormatInt(offset, 10))
+	}
+	if length > 0 {
+		params.Set("length", strconv.FormatInt(length, 10))
+	}
+	resp, err := c.do(ctx, http.MethodGet, path, "OPEN", params)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := redirect(resp)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, loc, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err = c.datanode.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// create creates or replaces a file, and returns a writer streaming its
+// content to a DataNode.
+func (c *client) create(ctx context.Context, path string, replication int, blockSize int64) (io.WriteCloser, error) {
+	params := url.Values{"overwrite": {"true"}}
+	if replication > 0 {
+		params.Set("replication", strconv.Itoa(replication))
+	}
+	if blockSize > 0 {
+		params.Set("blocksize", strconv.FormatInt(blockSize, 10))
+	}
+	resp, err := c.do(ctx, http.MethodPut, path, "CREATE", params)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := redirect(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, loc, pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	w := &writer{pw: pw, done: make(chan error, 1)}
+	go func() {
+		resp, err := c.datanode.Do(req)
+		if err == nil {
+			err = checkResponse(resp, http.StatusCreated)
+			if err == nil {
+				io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		}
+		// Fail any further writes, so the writer does not block on an
+		// abandoned upload.
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+// writer streams written bytes to a DataNode.
+type writer struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close completes the upload and returns its error, if any.
+func (w *writer) Close() error {
+	w.pw.Close()
+	return <-w.done
+}
@@ -14,6 +14,17 @@
 // limitations under the License.
 
 // Package memfs contains a in-memory Beam filesystem. Useful for testing.
+//
+// All memfs:// paths share a global store in the process, so tests can
+// preload the input files of a pipeline and inspect its output files after
+// running it on the direct runner:
+//
+//	memfs.Reset()
+//	memfs.Preload(map[string][]byte{"memfs://in/a.txt": []byte("a\nb\n")})
+//	lines := textio.Read(s, "memfs://in/*.txt")
+//	textio.Write(s, "memfs://out.txt", lines)
+//	ptest.RunAndValidate(t, p)
+//	out, err := memfs.Read("memfs://out.txt")
 package memfs
 
 import (
@@ -22,6 +33,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -49,28 +61,52 @@ func (f *fs) Close() error {
 	return nil
 }
 
-func (f *fs) List(_ context.Context, _ string) ([]string, error) {
+// List returns the keys matching the glob, in order.
+func (f *fs) List(_ context.Context, glob string) ([]string, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	pattern := normalize(glob)
 	var ret []string
 	for k := range f.m {
-		ret = append(ret, k)
+		match, err := filepath.Match(pattern, k)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			ret = append(ret, k)
+		}
 	}
 	sort.Strings(ret)
 	return ret, nil
 }
 
-func (f *fs) OpenRead(_ context.Context, filename string) (io.ReadCloser, error) {
+func (f *fs) OpenRead(ctx context.Context, filename string) (io.ReadCloser, error) {
+	return f.OpenReadRange(ctx, filename, 0, -1)
+}
+
+// OpenReadRange opens a file for reading length bytes from the given offset.
+// A negative length reads to the end of the file.
+func (f *fs) OpenReadRange(_ context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	if v, ok := f.m[normalize(filename)]; ok {
-		return ioutil.NopCloser(bytes.NewReader(v)), nil
+	v, ok := f.m[normalize(filename)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	if offset > int64(len(v)) {
+		offset = int64(len(v))
+	}
+	v = v[offset:]
+	if length >= 0 && length < int64(len(v)) {
+		v = v[:length]
 	}
-	return nil, os.ErrNotExist
+	return ioutil.NopCloser(bytes.NewReader(v)), nil
 }
 
+// OpenWrite opens a file for writing. The file is stored when the writer is
+// closed.
 func (f *fs) OpenWrite(_ context.Context, filename string) (io.WriteCloser, error) {
 	return &commitWriter{key: filename}, nil
 }
@@ -96,6 +132,50 @@ func Write(key string, value []byte) {
 	instance.m[normalize(key)] = cp
 }
 
+// Preload stores the given files in the global store, replacing any files
+// with the same keys.
+func Preload(files map[string][]byte) {
+	for k, v := range files {
+		Write(k, v)
+	}
+}
+
+// Read returns a copy of the value of the given key in the global store, or
+// os.ErrNotExist if there is none.
+func Read(key string) ([]byte, error) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	v, ok := instance.m[normalize(key)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	cp := make([]byte, len(v))
+	copy(cp, v)
+	return cp, nil
+}
+
+// Keys returns the keys of the global store, in order.
+func Keys() []string {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	var ret []string
+	for k := range instance.m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Reset removes all files from the global store.
+func Reset() {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	instance.m = make(map[string][]byte)
+}
+
 func normalize(key string) string {
 	if strings.HasPrefix(key, "memfs://") {
 		return key
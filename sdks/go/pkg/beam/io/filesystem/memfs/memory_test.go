@@ -17,6 +17,8 @@ package memfs
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -92,3 +94,81 @@ func TestSize(t *testing.T) {
 		}
 	}
 }
+
+func TestList(t *testing.T) {
+	Reset()
+	Preload(map[string][]byte{
+		"memfs://in/a.txt": nil,
+		"memfs://in/b.txt": nil,
+		"memfs://in/c.csv": nil,
+		"memfs://out.txt":  nil,
+	})
+	ctx := context.Background()
+	fs := New(ctx)
+
+	got, err := fs.List(ctx, "memfs://in/*.txt")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := "[memfs://in/a.txt memfs://in/b.txt]"; fmt.Sprint(got) != want {
+		t.Errorf("List(in/*.txt) = %v, want %v", got, want)
+	}
+	got, err = fs.List(ctx, "out.txt")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := "[memfs://out.txt]"; fmt.Sprint(got) != want {
+		t.Errorf("List(out.txt) = %v, want %v", got, want)
+	}
+}
+
+func TestReadRange(t *testing.T) {
+	Reset()
+	Write("memfs://file", []byte("0123456789"))
+	ctx := context.Background()
+	fs := New(ctx).(filesystem.RangeReader)
+
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{3, -1, "3456789"},
+		{3, 4, "3456"},
+		{8, 10, "89"},
+		{12, 2, ""},
+	}
+	for _, test := range tests {
+		r, err := fs.OpenReadRange(ctx, "memfs://file", test.offset, test.length)
+		if err != nil {
+			t.Fatalf("OpenReadRange(%v, %v) failed: %v", test.offset, test.length, err)
+		}
+		data, _ := ioutil.ReadAll(r)
+		if string(data) != test.want {
+			t.Errorf("OpenReadRange(%v, %v) read %q, want %q", test.offset, test.length, data, test.want)
+		}
+	}
+}
+
+// TestInspect tests that written files can be inspected through the store.
+func TestInspect(t *testing.T) {
+	Reset()
+	ctx := context.Background()
+	fs := New(ctx)
+
+	if err := filesystem.Write(ctx, fs, "memfs://out/part-0", []byte("out")); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(Keys()); got != "[memfs://out/part-0]" {
+		t.Errorf("Keys() = %v, want [memfs://out/part-0]", got)
+	}
+	data, err := Read("out/part-0")
+	if err != nil || string(data) != "out" {
+		t.Errorf("Read(out/part-0) = (%q, %v), want out", data, err)
+	}
+
+	Reset()
+	if _, err := Read("out/part-0"); err != os.ErrNotExist {
+		t.Errorf("Read(out/part-0) after Reset = %v, want os.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,336 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package s3 contains an Amazon S3 implementation of the Beam file system.
+//
+// Experimental.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+func init() {
+	filesystem.Register("s3", New)
+}
+
+// optionsKey is the pipeline option holding the encoded Options.
+const optionsKey = "s3_filesystem_options"
+
+// Options configures the S3 file system.
+type Options struct {
+	// Region is the AWS region of the buckets, such as "us-east-1". If empty,
+	// the region is taken from the environment or shared configuration.
+	Region string `json:"region,omitempty"`
+	// Endpoint is the URL of an S3 compatible service, such as a MinIO
+	// server. If empty, the AWS endpoint of the region is used.
+	Endpoint string `json:"endpoint,omitempty"`
+	// UsePathStyle addresses buckets in the path of requests, rather than in
+	// the host name, as most S3 compatible services require.
+	UsePathStyle bool `json:"usePathStyle,omitempty"`
+	// RoleARN is the IAM role assumed to access the buckets, such as
+	// "arn:aws:iam::123456789012:role/beam". If empty, the default
+	// credentials of the environment are used directly.
+	RoleARN string `json:"roleARN,omitempty"`
+	// ExternalID is the external ID required to assume the role, if any.
+	ExternalID string `json:"externalID,omitempty"`
+	// RoleSessionName is the session name of the assumed role. If empty, a
+	// unique name is generated.
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+	// PartSize is the number of bytes buffered and sent in each part of a
+	// multipart upload. Objects smaller than PartSize are uploaded in a
+	// single request. If zero, parts of 5MiB are used, which is the
+	// minimum S3 allows.
+	PartSize int64 `json:"partSize,omitempty"`
+	// Concurrency is the number of parts of an object uploaded in parallel.
+	// If zero, 5 parts are uploaded in parallel.
+	Concurrency int `json:"concurrency,omitempty"`
+	// ServerSideEncryption is the server-side encryption of written objects,
+	// either "AES256" or "aws:kms". If empty, the bucket's default encryption
+	// is used.
+	ServerSideEncryption string `json:"serverSideEncryption,omitempty"`
+	// SSEKMSKeyID is the AWS KMS key used to encrypt written objects with
+	// "aws:kms" encryption. If empty, the AWS managed key is used.
+	SSEKMSKeyID string `json:"sseKMSKeyID,omitempty"`
+}
+
+// SetOptions configures the S3 file system for the pipeline. The options are
+// carried to remote workers as pipeline options, so they must be set before
+// the pipeline is executed.
+func SetOptions(opts Options) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to encode S3 options"))
+	}
+	runtime.GlobalOptions.Set(optionsKey, string(data))
+}
+
+// options returns the options of the pipeline.
+func options() (Options, error) {
+	var opts Options
+	if data := runtime.GlobalOptions.Get(optionsKey); data != "" {
+		if err := json.Unmarshal([]byte(data), &opts); err != nil {
+			return Options{}, errors.Wrap(err, "failed to decode S3 options")
+		}
+	}
+	switch opts.ServerSideEncryption {
+	case "", string(types.ServerSideEncryptionAes256), string(types.ServerSideEncryptionAwsKms):
+	default:
+		return Options{}, errors.Errorf("invalid S3 server-side encryption %q, want AES256 or aws:kms", opts.ServerSideEncryption)
+	}
+	return opts, nil
+}
+
+// client is the subset of an s3.Client used by the file system.
+type client interface {
+	manager.UploadAPIClient
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+// newClient creates an S3 client. It is a variable so tests can substitute a
+// fake service.
+var newClient = func(ctx context.Context, opts Options) (client, error) {
+	var loadOpts []func(*config.LoadOptions) error
+	if opts.Region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(opts.Region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if opts.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), opts.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			if opts.ExternalID != "" {
+				o.ExternalID = aws.String(opts.ExternalID)
+			}
+			if opts.RoleSessionName != "" {
+				o.RoleSessionName = opts.RoleSessionName
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if opts.Endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(opts.Endpoint)
+		}
+		o.UsePathStyle = opts.UsePathStyle
+	}), nil
+}
+
+type fs struct {
+	client client
+	opts   Options
+}
+
+// New creates a new S3 filesystem, using the default credential chain of the
+// AWS SDK: environment variables, shared configuration files and the
+// instance or task role. The file system is configured with the options
+// given to SetOptions.
+//
+// Objects are written with multipart uploads, with several parts in flight
+// at once. The file system implements filesystem.RangeReader.
+func New(ctx context.Context) filesystem.Interface {
+	opts, err := options()
+	if err != nil {
+		panic(err)
+	}
+	c, err := newClient(ctx, opts)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to create S3 client"))
+	}
+	return &fs{client: c, opts: opts}
+}
+
+func (f *fs) Close() error {
+	return nil
+}
+
+// parseObject splits an s3://bucket/key path into its bucket and key.
+func parseObject(path string) (bucket, key string, err error) {
+	rest := strings.TrimPrefix(path, "s3://")
+	if rest == path {
+		return "", "", errors.Errorf("invalid S3 path %q: missing s3:// scheme", path)
+	}
+	parts := strings.SplitN(rest, "/", 2)
+	if parts[0] == "" || len(parts) < 2 || parts[1] == "" {
+		return "", "", errors.Errorf("invalid S3 path %q: want s3://bucket/key", path)
+	}
+	return parts[0], parts[1], nil
+}
+
+func (f *fs) List(ctx context.Context, glob string) ([]string, error) {
+	bucket, key, err := parseObject(glob)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	if index := strings.IndexAny(key, "*?["); index >= 0 {
+		// We handle globs by listing all keys with the literal prefix of
+		// the pattern and matching them here.
+		p := s3.NewListObjectsV2Paginator(f.client, &s3.ListObjectsV2Input{
+			Bucket: aws.String(bucket),
+			Prefix: aws.String(key[:index]),
+		})
+		for p.HasMorePages() {
+			page, err := p.NextPage(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to list %v", glob)
+			}
+			for _, obj := range page.Contents {
+				name := aws.ToString(obj.Key)
+				match, err := filepath.Match(key, name)
+				if err != nil {
+					return nil, err
+				}
+				if match {
+					candidates = append(candidates, name)
+				}
+			}
+		}
+	} else {
+		// Single object.
+		candidates = []string{key}
+	}
+
+	var ret []string
+	for _, obj := range candidates {
+		ret = append(ret, fmt.Sprintf("s3://%v/%v", bucket, obj))
+	}
+	return ret, nil
+}
+
+func (f *fs) OpenRead(ctx context.Context, filename string) (io.ReadCloser, error) {
+	return f.OpenReadRange(ctx, filename, 0, -1)
+}
+
+// OpenReadRange opens an object for reading length bytes from the given
+// offset. A negative length reads to the end of the object.
+func (f *fs) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	bucket, key, err := parseObject(filename)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		// S3 rejects empty ranges.
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	in := &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	switch {
+	case length > 0:
+		in.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		in.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+	out, err := f.client.GetObject(ctx, in)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %v", filename)
+	}
+	return out.Body, nil
+}
+
+// OpenWrite opens an object for writing. The object is uploaded in parts of
+// the configured size as it is written, and is created when the writer is
+// closed. If the upload fails, no object is created.
+func (f *fs) OpenWrite(ctx context.Context, filename string) (io.WriteCloser, error) {
+	bucket, key, err := parseObject(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	uploader := manager.NewUploader(f.client, func(u *manager.Uploader) {
+		if f.opts.PartSize > 0 {
+			u.PartSize = f.opts.PartSize
+		}
+		if f.opts.Concurrency > 0 {
+			u.Concurrency = f.opts.Concurrency
+		}
+	})
+	in := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if f.opts.ServerSideEncryption != "" {
+		in.ServerSideEncryption = types.ServerSideEncryption(f.opts.ServerSideEncryption)
+	}
+	if f.opts.SSEKMSKeyID != "" {
+		in.SSEKMSKeyId = aws.String(f.opts.SSEKMSKeyID)
+	}
+
+	pr, pw := io.Pipe()
+	in.Body = pr
+	w := &writer{pw: pw, done: make(chan error, 1)}
+	go func() {
+		_, err := uploader.Upload(ctx, in)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to upload %v", filename)
+		}
+		// Fail any further writes, so the writer does not block on an
+		// abandoned upload.
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+// writer streams written bytes to an upload.
+type writer struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close completes the upload and returns its error, if any.
+func (w *writer) Close() error {
+	w.pw.Close()
+	return <-w.done
+}
+
+func (f *fs) Size(ctx context.Context, filename string) (int64, error) {
+	bucket, key, err := parseObject(filename)
+	if err != nil {
+		return -1, err
+	}
+
+	out, err := f.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return -1, errors.Wrapf(err, "failed to get size of %v", filename)
+	}
+	return out.ContentLength, nil
+}
@@ -0,0 +1,393 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/fakes"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeClient is an in-memory S3 service storing the objects of one bucket.
+type fakeClient struct {
+	pageSize int
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int32][]byte
+	puts    []*s3.PutObjectInput
+	creates []*s3.CreateMultipartUploadInput
+	pages   int
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{pageSize: 1000, objects: map[string][]byte{}, uploads: map[string]map[int32][]byte{}}
+}
+
+func (c *fakeClient) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.puts = append(c.puts, in)
+	c.objects[aws.ToString(in.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (c *fakeClient) CreateMultipartUpload(_ context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.creates = append(c.creates, in)
+	id := strconv.Itoa(len(c.creates))
+	c.uploads[id] = map[int32][]byte{}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+}
+
+func (c *fakeClient) UploadPart(_ context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	data, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.uploads[aws.ToString(in.UploadId)][in.PartNumber] = data
+	return &s3.UploadPartOutput{ETag: aws.String(strconv.Itoa(int(in.PartNumber)))}, nil
+}
+
+func (c *fakeClient) CompleteMultipartUpload(_ context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	parts := c.uploads[aws.ToString(in.UploadId)]
+	var data []byte
+	for _, p := range in.MultipartUpload.Parts {
+		data = append(data, parts[p.PartNumber]...)
+	}
+	c.objects[aws.ToString(in.Key)] = data
+	delete(c.uploads, aws.ToString(in.UploadId))
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (c *fakeClient) AbortMultipartUpload(_ context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.uploads, aws.ToString(in.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// ListObjectsV2 lists keys in order, in pages of pageSize keys. The
+// continuation token is the last key of the previous page.
+func (c *fakeClient) ListObjectsV2(_ context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages++
+	var keys []string
+	for k := range c.objects {
+		if strings.HasPrefix(k, aws.ToString(in.Prefix)) && k > aws.ToString(in.ContinuationToken) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectsV2Output{}
+	if len(keys) > c.pageSize {
+		keys = keys[:c.pageSize]
+		out.IsTruncated = true
+		out.NextContinuationToken = aws.String(keys[len(keys)-1])
+	}
+	for _, k := range keys {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(k), Size: int64(len(c.objects[k]))})
+	}
+	return out, nil
+}
+
+func (c *fakeClient) GetObject(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	if r := aws.ToString(in.Range); r != "" {
+		var start, end int
+		if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err == nil {
+			if end >= len(data) {
+				end = len(data) - 1
+			}
+			data = data[start : end+1]
+		} else if _, err := fmt.Sscanf(r, "bytes=%d-", &start); err == nil {
+			data = data[start:]
+		} else {
+			return nil, fmt.Errorf("invalid range %q", r)
+		}
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(data)), ContentLength: int64(len(data))}, nil
+}
+
+func (c *fakeClient) HeadObject(_ context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{ContentLength: int64(len(data))}, nil
+}
+
+// newClient returns the fake client, substituting for created clients.
+func (c *fakeClient) newClient(context.Context, Options) (client, error) {
+	return c, nil
+}
+
+func TestParseObject(t *testing.T) {
+	tests := []struct {
+		path        string
+		bucket, key string
+		ok          bool
+	}{
+		{"s3://bucket/key", "bucket", "key", true},
+		{"s3://bucket/dir/key.txt", "bucket", "dir/key.txt", true},
+		{"s3://bucket/", "", "", false},
+		{"s3://bucket", "", "", false},
+		{"gs://bucket/key", "", "", false},
+	}
+	for _, test := range tests {
+		bucket, key, err := parseObject(test.path)
+		if (err == nil) != test.ok || bucket != test.bucket || key != test.key {
+			t.Errorf("parseObject(%q) = (%q, %q, %v), want (%q, %q, ok=%v)", test.path, bucket, key, err, test.bucket, test.key, test.ok)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	c := newFakeClient()
+	c.pageSize = 2
+	for _, k := range []string{"dir/a.txt", "dir/b.txt", "dir/c.csv", "dir/d.txt", "other/e.txt"} {
+		c.objects[k] = []byte(k)
+	}
+	fakes.Override(t, &newClient, c.newClient)
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	got, err := fs.List(ctx, "s3://bucket/dir/*.txt")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := "[s3://bucket/dir/a.txt s3://bucket/dir/b.txt s3://bucket/dir/d.txt]"; fmt.Sprint(got) != want {
+		t.Errorf("List(dir/*.txt) = %v, want %v", got, want)
+	}
+	if c.pages != 2 {
+		t.Errorf("List(dir/*.txt) listed %v pages, want 2", c.pages)
+	}
+
+	got, err = fs.List(ctx, "s3://bucket/other/e.txt")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := "[s3://bucket/other/e.txt]"; fmt.Sprint(got) != want {
+		t.Errorf("List(other/e.txt) = %v, want %v", got, want)
+	}
+}
+
+func TestReadRange(t *testing.T) {
+	c := newFakeClient()
+	c.objects["key"] = []byte("0123456789")
+	fakes.Override(t, &newClient, c.newClient)
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{3, -1, "3456789"},
+		{3, 4, "3456"},
+		{8, 10, "89"},
+		{5, 0, ""},
+	}
+	for _, test := range tests {
+		r, err := fs.(filesystem.RangeReader).OpenReadRange(ctx, "s3://bucket/key", test.offset, test.length)
+		if err != nil {
+			t.Fatalf("OpenReadRange(%v, %v) failed: %v", test.offset, test.length, err)
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("OpenReadRange(%v, %v) read failed: %v", test.offset, test.length, err)
+		}
+		if string(data) != test.want {
+			t.Errorf("OpenReadRange(%v, %v) read %q, want %q", test.offset, test.length, data, test.want)
+		}
+	}
+
+	size, err := fs.Size(ctx, "s3://bucket/key")
+	if err != nil || size != 10 {
+		t.Errorf("Size(key) = (%v, %v), want (10, nil)", size, err)
+	}
+	if _, err := fs.Size(ctx, "s3://bucket/missing"); err == nil {
+		t.Error("Size(missing) succeeded, want error")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	c := newFakeClient()
+	fakes.Override(t, &newClient, c.newClient)
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	if err := filesystem.Write(ctx, fs, "s3://bucket/small", []byte("hello")); err != nil {
+		t.Fatalf("Write(small) failed: %v", err)
+	}
+	if got := string(c.objects["small"]); got != "hello" {
+		t.Errorf("Write(small) stored %q, want hello", got)
+	}
+	if len(c.puts) != 1 || len(c.creates) != 0 {
+		t.Errorf("Write(small) made %v puts and %v multipart uploads, want a single put", len(c.puts), len(c.creates))
+	}
+}
+
+func TestWrite_Multipart(t *testing.T) {
+	c := newFakeClient()
+	fakes.Override(t, &newClient, c.newClient)
+	defer SetOptions(Options{})
+	SetOptions(Options{PartSize: 5 << 20, ServerSideEncryption: "aws:kms", SSEKMSKeyID: "key-id"})
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	data := bytes.Repeat([]byte("0123456789"), 11<<20/10)
+	w, err := fs.OpenWrite(ctx, "s3://bucket/large")
+	if err != nil {
+		t.Fatalf("OpenWrite failed: %v", err)
+	}
+	// Write in small pieces, so parts are assembled from several writes.
+	for i := 0; i < len(data); i += 64 << 10 {
+		end := i + 64<<10
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := w.Write(data[i:end]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if !bytes.Equal(c.objects["large"], data) {
+		t.Errorf("stored %v bytes, want %v bytes", len(c.objects["large"]), len(data))
+	}
+	if len(c.creates) != 1 {
+		t.Fatalf("made %v multipart uploads, want 1", len(c.creates))
+	}
+	in := c.creates[0]
+	if in.ServerSideEncryption != types.ServerSideEncryptionAwsKms || aws.ToString(in.SSEKMSKeyId) != "key-id" {
+		t.Errorf("multipart upload encryption = (%v, %v), want (aws:kms, key-id)", in.ServerSideEncryption, aws.ToString(in.SSEKMSKeyId))
+	}
+}
+
+// TestServer tests the file system with the AWS client, against a fake S3
+// service.
+func TestServer(t *testing.T) {
+	srv := fakes.NewS3Server(t)
+	srv.Put("bucket", "in/a.txt", []byte("0123456789"))
+	srv.Put("bucket", "in/b.csv", []byte("b"))
+	defer SetOptions(Options{})
+	SetOptions(Options{Region: "us-east-1", Endpoint: srv.URL, UsePathStyle: true, PartSize: 5 << 20})
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	data := bytes.Repeat([]byte("0123456789"), 11<<20/10)
+	if err := filesystem.Write(ctx, fs, "s3://bucket/in/large.txt", data); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got, _ := srv.Object("bucket", "in/large.txt"); !bytes.Equal(got, data) {
+		t.Errorf("stored %v bytes, want %v bytes", len(got), len(data))
+	}
+
+	got, err := fs.List(ctx, "s3://bucket/in/*.txt")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := "[s3://bucket/in/a.txt s3://bucket/in/large.txt]"; fmt.Sprint(got) != want {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+	if size, err := fs.Size(ctx, "s3://bucket/in/large.txt"); err != nil || size != int64(len(data)) {
+		t.Errorf("Size() = %v, %v, want %v", size, err, len(data))
+	}
+	r, err := fs.(filesystem.RangeReader).OpenReadRange(ctx, "s3://bucket/in/a.txt", 3, 4)
+	if err != nil {
+		t.Fatalf("OpenReadRange failed: %v", err)
+	}
+	defer r.Close()
+	if got, err := ioutil.ReadAll(r); err != nil || string(got) != "3456" {
+		t.Errorf("OpenReadRange(3, 4) read %q, %v, want 3456", got, err)
+	}
+}
+
+// failingClient fails every upload.
+type failingClient struct {
+	*fakeClient
+}
+
+func (c failingClient) PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return nil, errors.New("access denied")
+}
+
+func TestWrite_Failure(t *testing.T) {
+	orig := newClient
+	newClient = func(context.Context, Options) (client, error) { return failingClient{newFakeClient()}, nil }
+	defer func() { newClient = orig }()
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	if err := filesystem.Write(ctx, fs, "s3://bucket/key", []byte("hello")); err == nil {
+		t.Error("Write succeeded, want error for failed upload")
+	}
+}
+
+func TestOptions_InvalidEncryption(t *testing.T) {
+	defer SetOptions(Options{})
+	SetOptions(Options{ServerSideEncryption: "aes"})
+	if _, err := options(); err == nil {
+		t.Error("options() succeeded, want error for invalid encryption")
+	}
+}
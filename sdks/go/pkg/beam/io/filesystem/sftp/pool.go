@@ -0,0 +1,144 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sftp
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	pkgsftp "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// idleTimeout is how long an unused connection is kept open. Servers of drop
+// zones commonly close idle sessions after a few minutes.
+var idleTimeout = time.Minute
+
+// conn is an SFTP session over an SSH connection.
+type conn struct {
+	server   server
+	client   *pkgsftp.Client
+	closer   io.Closer
+	lastUsed time.Time
+}
+
+func (c *conn) close() {
+	c.client.Close()
+	c.closer.Close()
+}
+
+// dial opens an SFTP session to the address. It is a variable so tests can
+// substitute an in-process server.
+var dial = func(ctx context.Context, addr string, cfg *ssh.ClientConfig) (*conn, error) {
+	d := net.Dialer{Timeout: cfg.Timeout}
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	sc, chans, reqs, err := ssh.NewClientConn(nc, addr, cfg)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	sshClient := ssh.NewClient(sc, chans, reqs)
+	client, err := pkgsftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+	return &conn{client: client, closer: sshClient}, nil
+}
+
+// pool holds idle connections per server, and limits the number of
+// connections open to each server at once.
+type pool struct {
+	mu    sync.Mutex
+	idle  map[server][]*conn
+	slots map[server]chan struct{}
+}
+
+// connections is the connection pool of the worker.
+var connections = &pool{
+	idle:  make(map[server][]*conn),
+	slots: make(map[server]chan struct{}),
+}
+
+// get returns an idle connection to the server, or opens a new one. It
+// blocks while max connections to the server are in use.
+func (p *pool) get(ctx context.Context, s server, max int, open func() (*conn, error)) (*conn, error) {
+	p.mu.Lock()
+	slots, ok := p.slots[s]
+	if !ok {
+		slots = make(chan struct{}, max)
+		p.slots[s] = slots
+	}
+	p.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	for idle := p.idle[s]; len(idle) > 0; idle = p.idle[s] {
+		c := idle[len(idle)-1]
+		p.idle[s] = idle[:len(idle)-1]
+		if time.Since(c.lastUsed) < idleTimeout {
+			p.mu.Unlock()
+			return c, nil
+		}
+		c.close()
+	}
+	p.mu.Unlock()
+
+	c, err := open()
+	if err != nil {
+		<-slots
+		return nil, err
+	}
+	c.server = s
+	return c, nil
+}
+
+// put returns a connection to the pool, given the error of its last use.
+// Connections that failed with other than a file error are closed, since
+// they may be broken.
+func (p *pool) put(c *conn, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil || isFileError(err) {
+		c.lastUsed = time.Now()
+		p.idle[c.server] = append(p.idle[c.server], c)
+	} else {
+		c.close()
+	}
+	<-p.slots[c.server]
+}
+
+// isFileError returns whether the error was reported by the server for a
+// file, rather than by the connection.
+func isFileError(err error) bool {
+	if os.IsNotExist(err) || os.IsPermission(err) {
+		return true
+	}
+	_, ok := err.(*pkgsftp.StatusError)
+	return ok
+}
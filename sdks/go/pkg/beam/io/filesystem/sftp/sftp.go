@@ -0,0 +1,407 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sftp contains an SFTP implementation of the Beam file system.
+// Files are named by paths of the form
+//
+//	sftp://[<user>@]<host>[:<port>]/<path>
+//
+// where the path is relative to the login directory of the user, unless it
+// starts with a second slash. SSH connections are pooled per server, and
+// are reused across reads and writes of a worker.
+//
+// Experimental.
+package sftp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
+	pkgsftp "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func init() {
+	filesystem.Register("sftp", New)
+}
+
+// optionsKey is the pipeline option holding the encoded Options.
+const optionsKey = "sftp_filesystem_options"
+
+// Options configures the SFTP file system. Each server must be reachable
+// with the same credentials.
+type Options struct {
+	// User is the user to log in as, if the path names none. If empty, the
+	// USER environment variable of the worker is used.
+	User string `json:"user,omitempty"`
+	// Password authenticates the user with a password. It is carried in the
+	// pipeline options, so prefer keys.
+	Password string `json:"password,omitempty"`
+	// PrivateKeyFile is the path of a PEM encoded private key on the
+	// workers, such as "/etc/beam/id_rsa".
+	PrivateKeyFile string `json:"privateKeyFile,omitempty"`
+	// PrivateKey is a PEM encoded private key, used instead of
+	// PrivateKeyFile when the key cannot be provisioned on the workers.
+	PrivateKey string `json:"privateKey,omitempty"`
+	// Passphrase decrypts the private key, if it is encrypted.
+	Passphrase string `json:"passphrase,omitempty"`
+	// HostKey is the public key the servers must present, in the
+	// authorized_keys format, such as "ssh-ed25519 AAAA...". If empty, host
+	// keys are verified against KnownHostsFile.
+	HostKey string `json:"hostKey,omitempty"`
+	// KnownHostsFile is the path of a known_hosts file on the workers. If
+	// empty, ~/.ssh/known_hosts is used.
+	KnownHostsFile string `json:"knownHostsFile,omitempty"`
+	// InsecureIgnoreHostKey accepts any host key. It should only be used for
+	// testing.
+	InsecureIgnoreHostKey bool `json:"insecureIgnoreHostKey,omitempty"`
+	// MaxConnections is the maximum number of connections open to each
+	// server at once. Each open reader or writer holds a connection. If
+	// zero, 4 connections are used.
+	MaxConnections int `json:"maxConnections,omitempty"`
+	// Timeout is the timeout of establishing a connection. If zero, 30s is
+	// used.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// SetOptions configures the SFTP file system for the pipeline. The options
+// are carried to remote workers as pipeline options, so they must be set
+// before the pipeline is executed.
+func SetOptions(opts Options) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to encode SFTP options"))
+	}
+	runtime.GlobalOptions.Set(optionsKey, string(data))
+}
+
+// options returns the options of the pipeline.
+func options() (Options, error) {
+	var opts Options
+	if data := runtime.GlobalOptions.Get(optionsKey); data != "" {
+		if err := json.Unmarshal([]byte(data), &opts); err != nil {
+			return Options{}, errors.Wrap(err, "failed to decode SFTP options")
+		}
+	}
+	if opts.User == "" {
+		opts.User = os.Getenv("USER")
+	}
+	if opts.MaxConnections <= 0 {
+		opts.MaxConnections = 4
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	return opts, nil
+}
+
+// sshConfig returns the SSH configuration of the options for the user.
+func sshConfig(opts Options, user string) (*ssh.ClientConfig, error) {
+	cfg := &ssh.ClientConfig{User: user, Timeout: opts.Timeout}
+
+	key := []byte(opts.PrivateKey)
+	if len(key) == 0 && opts.PrivateKeyFile != "" {
+		var err error
+		if key, err = ioutil.ReadFile(opts.PrivateKeyFile); err != nil {
+			return nil, errors.Wrap(err, "failed to read SSH private key")
+		}
+	}
+	if len(key) > 0 {
+		var signer ssh.Signer
+		var err error
+		if opts.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(opts.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid SSH private key")
+		}
+		cfg.Auth = append(cfg.Auth, ssh.PublicKeys(signer))
+	}
+	if opts.Password != "" {
+		cfg.Auth = append(cfg.Auth, ssh.Password(opts.Password))
+	}
+
+	switch {
+	case opts.HostKey != "":
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(opts.HostKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid SSH host key")
+		}
+		cfg.HostKeyCallback = ssh.FixedHostKey(pub)
+	case opts.InsecureIgnoreHostKey:
+		cfg.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		file := opts.KnownHostsFile
+		if file == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to find known_hosts file")
+			}
+			file = filepath.Join(home, ".ssh", "known_hosts")
+		}
+		cb, err := knownhosts.New(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read known hosts %v", file)
+		}
+		cfg.HostKeyCallback = cb
+	}
+	return cfg, nil
+}
+
+// server identifies an SFTP server and the user logged in to it.
+type server struct {
+	user, addr string
+}
+
+func (s server) String() string {
+	return s.user + "@" + s.addr
+}
+
+// parsePath splits an sftp:// path into its server and file name.
+func parsePath(p string, defaultUser string) (server, string, error) {
+	u, err := url.Parse(p)
+	if err != nil {
+		return server{}, "", errors.Wrapf(err, "invalid SFTP path %q", p)
+	}
+	if u.Scheme != "sftp" || u.Host == "" || u.Path == "" || u.Path == "/" {
+		return server{}, "", errors.Errorf("invalid SFTP path %q: want sftp://host/path", p)
+	}
+	s := server{user: defaultUser, addr: u.Host}
+	if u.User != nil {
+		s.user = u.User.Username()
+	}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+	// The first slash separates the host from the path.
+	return s, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+type fs struct {
+	opts Options
+}
+
+// New creates a new SFTP filesystem, configured with the options given to
+// SetOptions. The file system implements filesystem.RangeReader.
+func New(ctx context.Context) filesystem.Interface {
+	opts, err := options()
+	if err != nil {
+		panic(err)
+	}
+	return &fs{opts: opts}
+}
+
+func (f *fs) Close() error {
+	return nil
+}
+
+// acquire returns a pooled connection to the server of the path, and the
+// name of the file on the server.
+func (f *fs) acquire(ctx context.Context, p string) (*conn, string, error) {
+	s, name, err := parsePath(p, f.opts.User)
+	if err != nil {
+		return nil, "", err
+	}
+	c, err := connections.get(ctx, s, f.opts.MaxConnections, func() (*conn, error) {
+		cfg, err := sshConfig(f.opts, s.user)
+		if err != nil {
+			return nil, err
+		}
+		return dial(ctx, s.addr, cfg)
+	})
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to connect to %v", s)
+	}
+	return c, name, nil
+}
+
+func (f *fs) List(ctx context.Context, glob string) ([]string, error) {
+	c, name, err := f.acquire(ctx, glob)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := c.client.Glob(name)
+	connections.put(c, err)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list %v", glob)
+	}
+
+	prefix := strings.TrimSuffix(glob, name)
+	var ret []string
+	for _, m := range matches {
+		ret = append(ret, prefix+m)
+	}
+	return ret, nil
+}
+
+func (f *fs) OpenRead(ctx context.Context, filename string) (io.ReadCloser, error) {
+	return f.OpenReadRange(ctx, filename, 0, -1)
+}
+
+// OpenReadRange opens a file for reading length bytes from the given offset.
+// A negative length reads to the end of the file. The reader holds a pooled
+// connection until it is closed.
+func (f *fs) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	c, name, err := f.acquire(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	file, err := c.client.Open(name)
+	if err == nil && offset > 0 {
+		if _, err = file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+		}
+	}
+	if err != nil {
+		connections.put(c, err)
+		return nil, errors.Wrapf(err, "failed to open %v", filename)
+	}
+	var r io.Reader = file
+	if length >= 0 {
+		r = io.LimitReader(file, length)
+	}
+	return &reader{Reader: r, file: file, conn: c}, nil
+}
+
+// OpenWrite opens a file for writing, creating its directory if needed. The
+// writer holds a pooled connection until it is closed.
+func (f *fs) OpenWrite(ctx context.Context, filename string) (io.WriteCloser, error) {
+	c, name, err := f.acquire(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	err = mkdirAll(c.client, path.Dir(name))
+	var file *pkgsftp.File
+	if err == nil {
+		file, err = c.client.Create(name)
+	}
+	if err != nil {
+		connections.put(c, err)
+		return nil, errors.Wrapf(err, "failed to create %v", filename)
+	}
+	return &writer{file: file, conn: c}, nil
+}
+
+// mkdirAll creates a directory and any missing parents.
+func mkdirAll(c *pkgsftp.Client, dir string) error {
+	if dir == "." || dir == "/" {
+		return nil
+	}
+	info, err := c.Stat(dir)
+	if err == nil {
+		if !info.IsDir() {
+			return errors.Errorf("%v is not a directory", dir)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	if err := mkdirAll(c, path.Dir(dir)); err != nil {
+		return err
+	}
+	if err := c.Mkdir(dir); err != nil {
+		// The directory may have been created concurrently.
+		if info, serr := c.Stat(dir); serr != nil || !info.IsDir() {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fs) Size(ctx context.Context, filename string) (int64, error) {
+	c, name, err := f.acquire(ctx, filename)
+	if err != nil {
+		return -1, err
+	}
+	info, err := c.client.Stat(name)
+	connections.put(c, err)
+	if err != nil {
+		return -1, errors.Wrapf(err, "failed to get size of %v", filename)
+	}
+	return info.Size(), nil
+}
+
+// reader reads a file, and returns its connection to the pool on close.
+type reader struct {
+	io.Reader
+	file *pkgsftp.File
+	conn *conn
+	err  error
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+func (r *reader) Close() error {
+	if r.conn == nil {
+		return nil
+	}
+	err := r.file.Close()
+	if r.err == nil {
+		r.err = err
+	}
+	connections.put(r.conn, r.err)
+	r.conn = nil
+	return err
+}
+
+// writer writes a file, and returns its connection to the pool on close.
+type writer struct {
+	file *pkgsftp.File
+	conn *conn
+	err  error
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (w *writer) Close() error {
+	if w.conn == nil {
+		return nil
+	}
+	err := w.file.Close()
+	if w.err == nil {
+		w.err = err
+	}
+	connections.put(w.conn, w.err)
+	w.conn = nil
+	return w.err
+}
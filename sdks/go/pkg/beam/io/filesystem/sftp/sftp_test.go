@@ -0,0 +1,184 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sftp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
+	pkgsftp "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// pipeConn is one end of an in-process connection.
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// withServer substitutes in-process servers of the local file system for
+// dialed connections, and returns a function restoring the original. The
+// number of dials is counted in dials.
+func withServer(dials *int) func() {
+	orig := dial
+	var mu sync.Mutex
+	dial = func(context.Context, string, *ssh.ClientConfig) (*conn, error) {
+		mu.Lock()
+		*dials++
+		mu.Unlock()
+		cr, sw := io.Pipe()
+		sr, cw := io.Pipe()
+		srv, err := pkgsftp.NewServer(pipeConn{sr, sw})
+		if err != nil {
+			return nil, err
+		}
+		go srv.Serve()
+		client, err := pkgsftp.NewClientPipe(cr, cw)
+		if err != nil {
+			return nil, err
+		}
+		return &conn{client: client, closer: srv}, nil
+	}
+	SetOptions(Options{InsecureIgnoreHostKey: true, MaxConnections: 1})
+	return func() {
+		dial = orig
+		SetOptions(Options{})
+		connections = &pool{idle: make(map[server][]*conn), slots: make(map[server]chan struct{})}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path   string
+		server server
+		name   string
+		ok     bool
+	}{
+		{"sftp://host/in/file.csv", server{"beam", "host:22"}, "in/file.csv", true},
+		{"sftp://partner@host:2222//srv/in/file.csv", server{"partner", "host:2222"}, "/srv/in/file.csv", true},
+		{"sftp://host/", server{}, "", false},
+		{"sftp:///file", server{}, "", false},
+		{"s3://bucket/key", server{}, "", false},
+	}
+	for _, test := range tests {
+		s, name, err := parsePath(test.path, "beam")
+		if (err == nil) != test.ok || s != test.server || name != test.name {
+			t.Errorf("parsePath(%q) = (%v, %q, %v), want (%v, %q, ok=%v)", test.path, s, name, err, test.server, test.name, test.ok)
+		}
+	}
+}
+
+func TestFileSystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var dials int
+	defer withServer(&dials)()
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	root := "sftp://host/" + dir
+	for _, name := range []string{"in/a.csv", "in/b.csv", "in/c.txt"} {
+		if err := filesystem.Write(ctx, fs, root+"/"+name, []byte("0123456789")); err != nil {
+			t.Fatalf("Write(%v) failed: %v", name, err)
+		}
+	}
+
+	got, err := fs.List(ctx, root+"/in/*.csv")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := fmt.Sprintf("[%v/in/a.csv %v/in/b.csv]", root, root); fmt.Sprint(got) != want {
+		t.Errorf("List(in/*.csv) = %v, want %v", got, want)
+	}
+
+	r, err := fs.(filesystem.RangeReader).OpenReadRange(ctx, root+"/in/a.csv", 3, 4)
+	if err != nil {
+		t.Fatalf("OpenReadRange failed: %v", err)
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "3456" {
+		t.Errorf("OpenReadRange(3, 4) read (%q, %v), want 3456", data, err)
+	}
+
+	size, err := fs.Size(ctx, root+"/in/c.txt")
+	if err != nil || size != 10 {
+		t.Errorf("Size(in/c.txt) = (%v, %v), want (10, nil)", size, err)
+	}
+	if _, err := fs.Size(ctx, root+"/in/missing"); err == nil {
+		t.Error("Size(in/missing) succeeded, want error")
+	}
+
+	if dials != 1 {
+		t.Errorf("dialed %v connections, want 1 reused connection", dials)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "in", "b.csv")); err != nil || string(data) != "0123456789" {
+		t.Errorf("server stored (%q, %v), want 0123456789", data, err)
+	}
+}
+
+// TestPool tests that open readers hold their connection, so no more than
+// MaxConnections are open to a server at once.
+func TestPool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var dials int
+	defer withServer(&dials)()
+
+	ctx := context.Background()
+	fs := New(ctx)
+	defer fs.Close()
+
+	filename := "sftp://host/" + dir + "/file"
+	r, err := fs.OpenRead(ctx, filename)
+	if err != nil {
+		t.Fatalf("OpenRead failed: %v", err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := fs.Size(timeout, filename); err == nil || !strings.Contains(err.Error(), "deadline") {
+		t.Errorf("Size with a held connection = %v, want deadline exceeded", err)
+	}
+
+	r.Close()
+	if _, err := fs.Size(ctx, filename); err != nil {
+		t.Errorf("Size after close failed: %v", err)
+	}
+	if dials != 1 {
+		t.Errorf("dialed %v connections, want 1", dials)
+	}
+}
@@ -0,0 +1,391 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpcio contains a transform for reading the messages of a
+// server-streaming gRPC method as an unbounded source.
+//
+// Experimental.
+package grpcio
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/rtrackers/cursor"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*readFn)(nil)).Elem())
+	beam.RegisterFunction(noPosition)
+}
+
+// reconnectBackoff is the delay before the first reconnect after a stream
+// fails, which doubles for each further consecutive failure up to a minute.
+// It is a variable so tests can shorten it.
+var reconnectBackoff = time.Second
+
+// PositionFn returns the resume token and the timestamp of a serialized
+// response message. A server resumes a stream after the message with the
+// resume token, and an empty token keeps the token of the previous message.
+// A zero timestamp timestamps the message with the time it is received.
+type PositionFn func(msg []byte) (token []byte, ts time.Time, err error)
+
+// noPosition is the PositionFn of streams that cannot be resumed.
+func noPosition([]byte) ([]byte, time.Time, error) {
+	return nil, time.Time{}, nil
+}
+
+// Read calls the server-streaming method on the gRPC server at target with
+// the given serialized request message, and returns a PCollection<[]byte> of
+// the serialized response messages. The method is named by its full name,
+// such as "/acme.events.v1.EventBus/Subscribe". Messages are not decoded, so
+// the stubs of the service are only needed to decode them downstream:
+//
+//	req, _ := proto.Marshal(&pb.SubscribeRequest{Topic: "orders"})
+//	msgs := grpcio.Read(s, "events.acme.com:443", "/acme.events.v1.EventBus/Subscribe", req,
+//		grpcio.Position(eventPosition))
+//
+// When the stream fails with a transient error, Read reconnects with
+// exponential backoff. If the messages carry resume tokens, as extracted by
+// the Position option, the resume token of the last message read is sent to
+// the server in the ResumeHeader metadata of the new call. Runners checkpoint
+// the read by splitting its restriction, and the residual resumes in the
+// same way. Without resume tokens, a reconnected or resumed stream restarts
+// from the request.
+//
+// Messages are timestamped with the timestamps returned by the Position
+// option, or with the time they are received. The SDK does not yet estimate
+// watermarks for splittable DoFns, so the output watermark is determined by
+// the runner.
+//
+// Read runs until the server ends the stream or the pipeline is cancelled,
+// unless the read is bounded with MaxNumRecords or MaxReadTime.
+func Read(s beam.Scope, target, method string, request []byte, opts ...readOption) beam.PCollection {
+	s = s.Scope("grpcio.Read")
+
+	fn := &readFn{
+		Target:        target,
+		Method:        method,
+		Request:       request,
+		Metadata:      map[string]string{},
+		ResumeHeader:  "resume-token-bin",
+		Position:      beam.EncodedFunc{Fn: reflectx.MakeFunc(noPosition)},
+		MaxReconnects: 10,
+		MaxNumRecords: -1,
+	}
+	for _, opt := range opts {
+		opt(fn)
+	}
+	return beam.ParDo(s, fn, beam.Impulse(s))
+}
+
+type readOption func(*readFn)
+
+// Insecure is a Read option that connects to the server without transport
+// security. By default, the connection uses TLS with the system's root
+// certificates.
+func Insecure() readOption {
+	return func(fn *readFn) {
+		fn.Insecure = true
+	}
+}
+
+// Metadata is a Read option that sets a metadata header of the call, such as
+// an authorization header. Metadata is stored in the pipeline graph.
+func Metadata(key, value string) readOption {
+	return func(fn *readFn) {
+		fn.Metadata[key] = value
+	}
+}
+
+// Position is a Read option that sets the function extracting the resume
+// token and the timestamp of each message. The function must be registered
+// with beam.RegisterFunction to run on remote workers.
+func Position(fn PositionFn) readOption {
+	return func(r *readFn) {
+		r.Position = beam.EncodedFunc{Fn: reflectx.MakeFunc((func([]byte) ([]byte, time.Time, error))(fn))}
+	}
+}
+
+// ResumeHeader is a Read option that sets the metadata header carrying the
+// resume token of a reconnected call. Headers with a "-bin" suffix carry
+// binary tokens.
+//
+// Default: resume-token-bin
+func ResumeHeader(key string) readOption {
+	return func(fn *readFn) {
+		fn.ResumeHeader = key
+	}
+}
+
+// MaxReconnects is a Read option that sets the maximum number of consecutive
+// reconnects without receiving a message, before the read fails.
+//
+// Default: 10
+func MaxReconnects(n int) readOption {
+	return func(fn *readFn) {
+		fn.MaxReconnects = n
+	}
+}
+
+// MaxNumRecords is a Read option that specifies the maximum number of messages
+// to read. Setting this causes the Read to execute as a bounded transform.
+// Useful for tests and demo applications.
+func MaxNumRecords(n int64) readOption {
+	return func(fn *readFn) {
+		fn.MaxNumRecords = n
+	}
+}
+
+// MaxReadTime is a Read option that specifies the maximum duration of time
+// each restriction is read for. Setting this causes the Read to execute as a
+// bounded transform. Useful for tests and demo applications.
+func MaxReadTime(d time.Duration) readOption {
+	return func(fn *readFn) {
+		fn.MaxReadTime = d
+	}
+}
+
+// rawCodec passes serialized messages through unchanged. It is named "proto"
+// so servers accept its calls as protobuf calls.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch b := v.(type) {
+	case []byte:
+		return b, nil
+	case *[]byte:
+		return *b, nil
+	default:
+		return nil, errors.Errorf("grpcio: cannot marshal %T", v)
+	}
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return errors.Errorf("grpcio: cannot unmarshal into %T", v)
+	}
+	*b = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// String implements the deprecated grpc.Codec interface, for servers.
+func (rawCodec) String() string {
+	return "proto"
+}
+
+// isTransient returns whether a stream that failed with the error should be
+// reconnected. Errors other than gRPC statuses are not transient.
+func isTransient(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch st.Code() {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unknown, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// readFn is a splittable DoFn that reads the messages of a stream.
+type readFn struct {
+	Target        string            `json:"target"`
+	Method        string            `json:"method"`
+	Request       []byte            `json:"request"`
+	Insecure      bool              `json:"insecure"`
+	Metadata      map[string]string `json:"metadata"`
+	ResumeHeader  string            `json:"resumeHeader"`
+	Position      beam.EncodedFunc  `json:"position"`
+	MaxReconnects int               `json:"maxReconnects"`
+	MaxNumRecords int64             `json:"maxNumRecords"`
+	MaxReadTime   time.Duration     `json:"maxReadTime"`
+
+	conn     *grpc.ClientConn
+	position reflectx.Func1x3
+}
+
+// CreateInitialRestriction creates a restriction at the start of the stream.
+func (fn *readFn) CreateInitialRestriction(_ []byte) cursor.Restriction {
+	return cursor.Restriction{Limit: fn.MaxNumRecords}
+}
+
+// SplitRestriction returns the restriction unchanged, as a stream is read
+// sequentially.
+func (fn *readFn) SplitRestriction(_ []byte, rest cursor.Restriction) []cursor.Restriction {
+	return []cursor.Restriction{rest}
+}
+
+// RestrictionSize returns the number of messages in a bounded restriction,
+// and one for unbounded restrictions.
+func (fn *readFn) RestrictionSize(_ []byte, rest cursor.Restriction) float64 {
+	if rest.IsBounded() {
+		return float64(rest.Limit)
+	}
+	return 1
+}
+
+// CreateTracker creates a stream tracker wrapped in an sdf.LockRTracker for
+// the restriction.
+func (fn *readFn) CreateTracker(rest cursor.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(cursor.NewTracker(rest))
+}
+
+// Setup creates the connection to the server.
+func (fn *readFn) Setup() error {
+	creds := grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	if fn.Insecure {
+		creds = grpc.WithInsecure()
+	}
+	conn, err := grpc.Dial(fn.Target, creds)
+	if err != nil {
+		return errors.Wrapf(err, "grpcio: failed to connect to %v", fn.Target)
+	}
+	fn.conn = conn
+	fn.position = reflectx.ToFunc1x3(fn.Position.Fn)
+	return nil
+}
+
+// call starts a call of the method, resuming after the given token.
+func (fn *readFn) call(ctx context.Context, token []byte) (grpc.ClientStream, error) {
+	md := metadata.New(fn.Metadata)
+	if len(token) > 0 {
+		md.Set(fn.ResumeHeader, string(token))
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	stream, err := fn.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fn.Method, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(fn.Request); err != nil {
+		return nil, err
+	}
+	return stream, stream.CloseSend()
+}
+
+// ProcessElement emits each message claimed from the restriction. It returns
+// when the restriction is exhausted or checkpointed, when the server ends the
+// stream, or when the maximum read time elapses.
+func (fn *readFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, _ []byte, emit func(beam.EventTime, []byte)) error {
+	rest := rt.GetRestriction().(cursor.Restriction)
+	if rest.IsBounded() && rest.Limit == 0 {
+		rt.TryClaim(nil)
+		return nil
+	}
+	if fn.MaxReadTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fn.MaxReadTime)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Ends the call when processing stops.
+
+	token := rest.After
+	backoff := reconnectBackoff
+	failures := 0
+	for {
+		err := fn.readStream(ctx, rt, token, emit, func(t []byte) {
+			token = t
+			failures, backoff = 0, reconnectBackoff
+		})
+		switch {
+		case err == errStopped:
+			return nil
+		case err == io.EOF, ctx.Err() == context.DeadlineExceeded:
+			// Finish claiming the restriction so the read is considered done.
+			rt.TryClaim(nil)
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case !isTransient(err) || failures >= fn.MaxReconnects:
+			return errors.Wrapf(err, "grpcio: failed to read %v from %v", fn.Method, fn.Target)
+		}
+		failures++
+		log.Warnf(ctx, "grpcio: reconnecting to %v in %v: %v", fn.Target, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			continue // Handled by the next iteration.
+		}
+		if backoff *= 2; backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+// errStopped reports that the tracker stopped claiming messages.
+var errStopped = errors.New("restriction stopped")
+
+// readStream reads messages from one call, after the given token, until the
+// call fails or the tracker stops claiming messages. It reports the resume
+// token of each emitted message with claimed.
+func (fn *readFn) readStream(ctx context.Context, rt *sdf.LockRTracker, token []byte, emit func(beam.EventTime, []byte), claimed func([]byte)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := fn.call(ctx, token)
+	if err != nil {
+		return err
+	}
+	for {
+		var msg []byte
+		if err := stream.RecvMsg(&msg); err != nil {
+			return err
+		}
+		t, ts, err := fn.position.Call1x3(msg)
+		if err, _ := err.(error); err != nil {
+			return errors.Wrap(err, "grpcio: failed to extract message position")
+		}
+		tok := append([]byte{}, t.([]byte)...)
+		if !rt.TryClaim(tok) {
+			return errStopped
+		}
+		et := ts.(time.Time)
+		if et.IsZero() {
+			et = time.Now()
+		}
+		emit(mtime.FromTime(et), msg)
+		if len(tok) > 0 {
+			token = tok
+		}
+		claimed(token)
+	}
+}
+
+// Teardown closes the connection.
+func (fn *readFn) Teardown() {
+	if fn.conn != nil {
+		fn.conn.Close()
+	}
+}
@@ -0,0 +1,244 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcio
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/rtrackers/cursor"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	beam.RegisterFunction(testPosition)
+}
+
+const testMethod = "/test.Events/Subscribe"
+
+// testPosition returns the index of a message "m<i>" as its resume token and
+// its timestamp in seconds.
+func testPosition(msg []byte) ([]byte, time.Time, error) {
+	i, err := strconv.Atoi(strings.TrimPrefix(string(msg), "m"))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return []byte(strconv.Itoa(i)), time.Unix(int64(i), 0), nil
+}
+
+// fakeServer streams the messages "m0" to "m<n-1>" to calls of testMethod,
+// resuming after the message in the resume-token-bin header. A negative n
+// streams messages until the call is cancelled.
+type fakeServer struct {
+	n int
+	// drop is the number of messages sent before each call fails with
+	// Unavailable, if positive.
+	drop int
+	// code fails every call, if set.
+	code codes.Code
+
+	mu     sync.Mutex
+	calls  int
+	tokens []string
+}
+
+func (f *fakeServer) handle(_ interface{}, stream grpc.ServerStream) error {
+	if m, _ := grpc.MethodFromServerStream(stream); m != testMethod {
+		return status.Errorf(codes.Unimplemented, "unknown method %v", m)
+	}
+	var req []byte
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+	if string(req) != "req" {
+		return status.Errorf(codes.InvalidArgument, "unexpected request %q", req)
+	}
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer secret" {
+		return status.Error(codes.Unauthenticated, "missing authorization")
+	}
+	next := 0
+	token := ""
+	if ts := md.Get("resume-token-bin"); len(ts) > 0 {
+		token = ts[0]
+		i, err := strconv.Atoi(token)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "bad resume token %q", token)
+		}
+		next = i + 1
+	}
+	f.mu.Lock()
+	f.calls++
+	f.tokens = append(f.tokens, token)
+	f.mu.Unlock()
+	if f.code != codes.OK {
+		return status.Error(f.code, "failed")
+	}
+	for sent := 0; f.n < 0 || next < f.n; sent++ {
+		if f.drop > 0 && sent == f.drop {
+			return status.Error(codes.Unavailable, "dropped")
+		}
+		if err := stream.SendMsg([]byte(fmt.Sprintf("m%v", next))); err != nil {
+			return err
+		}
+		next++
+	}
+	return nil
+}
+
+// serve starts the fake server on a local port, and returns its address and a
+// function stopping it.
+func serve(t *testing.T, f *fakeServer) (string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer(grpc.CustomCodec(rawCodec{}), grpc.UnknownServiceHandler(f.handle))
+	go srv.Serve(lis)
+	return lis.Addr().String(), srv.Stop
+}
+
+// withFastRetries shortens the reconnect backoff, and returns a function
+// restoring it.
+func withFastRetries() func() {
+	orig := reconnectBackoff
+	reconnectBackoff = time.Millisecond
+	return func() { reconnectBackoff = orig }
+}
+
+func read(s beam.Scope, addr string, opts ...readOption) beam.PCollection {
+	opts = append([]readOption{Insecure(), Metadata("authorization", "Bearer secret")}, opts...)
+	return Read(s, addr, testMethod, []byte("req"), opts...)
+}
+
+func TestRead(t *testing.T) {
+	f := &fakeServer{n: 3}
+	addr, stop := serve(t, f)
+	defer stop()
+
+	p, s := beam.NewPipelineWithRoot()
+	msgs := read(s, addr, Position(testPosition))
+	passert.Equals(s, msgs, []byte("m0"), []byte("m1"), []byte("m2"))
+	ptest.RunAndValidate(t, p)
+}
+
+// TestRead_Reconnect tests that dropped calls are resumed after the last
+// message read.
+func TestRead_Reconnect(t *testing.T) {
+	defer withFastRetries()()
+	f := &fakeServer{n: 5, drop: 2}
+	addr, stop := serve(t, f)
+	defer stop()
+
+	p, s := beam.NewPipelineWithRoot()
+	msgs := read(s, addr, Position(testPosition))
+	passert.Equals(s, msgs, []byte("m0"), []byte("m1"), []byte("m2"), []byte("m3"), []byte("m4"))
+	ptest.RunAndValidate(t, p)
+
+	if want := "[ 1 3]"; fmt.Sprint(f.tokens) != want {
+		t.Errorf("server received resume tokens %q, want %v", f.tokens, want)
+	}
+}
+
+func TestRead_MaxNumRecords(t *testing.T) {
+	f := &fakeServer{n: -1}
+	addr, stop := serve(t, f)
+	defer stop()
+
+	p, s := beam.NewPipelineWithRoot()
+	msgs := read(s, addr, MaxNumRecords(3))
+	passert.Equals(s, msgs, []byte("m0"), []byte("m1"), []byte("m2"))
+	ptest.RunAndValidate(t, p)
+}
+
+func TestRead_Failure(t *testing.T) {
+	defer withFastRetries()()
+	tests := []struct {
+		name      string
+		code      codes.Code
+		wantCalls int
+	}{
+		{name: "permanent", code: codes.PermissionDenied, wantCalls: 1},
+		{name: "transient", code: codes.Unavailable, wantCalls: 3},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			f := &fakeServer{n: 1, code: test.code}
+			addr, stop := serve(t, f)
+			defer stop()
+
+			p, s := beam.NewPipelineWithRoot()
+			read(s, addr, MaxReconnects(2))
+			if err := ptest.Run(p); err == nil {
+				t.Error("pipeline succeeded, want error for failed call")
+			}
+			if f.calls != test.wantCalls {
+				t.Errorf("server received %v calls, want %v", f.calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+// TestRead_Resume tests that reading a checkpointed restriction resumes after
+// its resume token.
+func TestRead_Resume(t *testing.T) {
+	f := &fakeServer{n: -1}
+	addr, stop := serve(t, f)
+	defer stop()
+
+	rf := &readFn{
+		Target:        addr,
+		Method:        testMethod,
+		Request:       []byte("req"),
+		Insecure:      true,
+		Metadata:      map[string]string{"authorization": "Bearer secret"},
+		ResumeHeader:  "resume-token-bin",
+		MaxReconnects: 1,
+	}
+	Position(testPosition)(rf)
+	if err := rf.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer rf.Teardown()
+	rt := sdf.NewLockRTracker(cursor.NewTracker(cursor.Restriction{After: []byte("4"), Limit: 2}))
+	var got []string
+	err := rf.ProcessElement(context.Background(), rt, nil, func(et beam.EventTime, b []byte) {
+		got = append(got, fmt.Sprintf("%v@%v", string(b), et.Milliseconds()/1000))
+	})
+	if err != nil {
+		t.Fatalf("ProcessElement failed: %v", err)
+	}
+	if want := "[m5@5 m6@6]"; fmt.Sprint(got) != want {
+		t.Errorf("ProcessElement emitted %v, want %v", got, want)
+	}
+	if !rt.IsDone() {
+		t.Errorf("restriction is not done after its limit")
+	}
+}
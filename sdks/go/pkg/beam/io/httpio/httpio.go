@@ -0,0 +1,370 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpio contains a transform for sending elements to HTTP endpoints,
+// such as webhooks and ingestion APIs.
+//
+// Experimental.
+package httpio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*writeFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*FailedRequest)(nil)).Elem())
+}
+
+// retryBackoff is the delay before the first retry of a failed request,
+// which doubles for each further retry. It is a variable so tests can shorten
+// it.
+var retryBackoff = time.Second
+
+// FailedRequest is a request that could not be sent, either because the
+// endpoint rejected it or because it still failed after the maximum number of
+// retries.
+type FailedRequest struct {
+	// Body is the body of the request.
+	Body []byte
+	// StatusCode is the status code of the last response, or 0 if no
+	// response was received.
+	StatusCode int
+	// Error describes the failure.
+	Error string
+}
+
+// Write sends the elements of the given PCollection to the endpoint at url,
+// in batches of up to BatchSize elements, and returns a PCollection of the
+// FailedRequests that could not be sent.
+//
+// Elements of type []byte are sent as they are, so they can be serialized in
+// any format by the pipeline. Elements of other types are encoded as JSON.
+// The body of a request with one element is the encoded element, and the
+// body of a request with several elements is a JSON array of them, or the
+// elements separated by newlines with the NewlineDelimited option.
+//
+// Requests are retried with exponential backoff on network errors, timeouts,
+// throttling and server errors, honoring the Retry-After header of the
+// response. Requests rejected with other status codes, or still failing after
+// MaxRetries retries, are emitted as FailedRequests rather than failing the
+// pipeline, so they can be written to a dead-letter sink. Since a request may
+// be retried after the endpoint received it, the endpoint should tolerate
+// duplicates.
+//
+// Example:
+//
+//	failed := httpio.Write(s, "https://hooks.example.com/events", events,
+//		httpio.BatchSize(50), httpio.BearerToken(token), httpio.Concurrency(8))
+//	textio.Write(s, "gs://bucket/dead-letter.json", beam.ParDo(s, toJSON, failed))
+func Write(s beam.Scope, url string, col beam.PCollection, opts ...writeOption) beam.PCollection {
+	s = s.Scope("httpio.Write")
+
+	fn := &writeFn{
+		URL:         url,
+		Method:      http.MethodPost,
+		Headers:     map[string]string{},
+		BatchSize:   100,
+		Concurrency: 4,
+		MaxRetries:  5,
+		Timeout:     30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(fn)
+	}
+	if fn.BatchSize < 1 {
+		panic(errors.Errorf("httpio.Write requires a positive batch size, got %v", fn.BatchSize))
+	}
+	if fn.Concurrency < 1 {
+		panic(errors.Errorf("httpio.Write requires a positive concurrency, got %v", fn.Concurrency))
+	}
+	if _, ok := fn.Headers["Content-Type"]; !ok {
+		fn.Headers["Content-Type"] = defaultContentType(col.Type().Type(), fn.NewlineDelimited)
+	}
+	return beam.ParDo(s, fn, col)
+}
+
+// defaultContentType returns the content type of requests with elements of
+// type t.
+func defaultContentType(t reflect.Type, newlineDelimited bool) string {
+	switch {
+	case t == reflectx.ByteSlice && newlineDelimited:
+		return "text/plain"
+	case t == reflectx.ByteSlice:
+		return "application/octet-stream"
+	case newlineDelimited:
+		return "application/x-ndjson"
+	default:
+		return "application/json"
+	}
+}
+
+type writeOption func(*writeFn)
+
+// Method is a Write option that sets the HTTP method of requests.
+//
+// Default: POST
+func Method(method string) writeOption {
+	return func(fn *writeFn) {
+		fn.Method = method
+	}
+}
+
+// Header is a Write option that sets a header of every request, such as an
+// API key. Headers are stored in the pipeline graph.
+func Header(key, value string) writeOption {
+	return func(fn *writeFn) {
+		fn.Headers[http.CanonicalHeaderKey(key)] = value
+	}
+}
+
+// BearerToken is a Write option that authenticates requests with the given
+// OAuth bearer token. The token is stored in the pipeline graph.
+func BearerToken(token string) writeOption {
+	return Header("Authorization", "Bearer "+token)
+}
+
+// ContentType is a Write option that sets the content type of requests.
+//
+// Default: application/json, or application/x-ndjson for newline-delimited
+// batches, or application/octet-stream for []byte elements
+func ContentType(contentType string) writeOption {
+	return Header("Content-Type", contentType)
+}
+
+// NewlineDelimited is a Write option that separates the elements of a batch
+// with newlines, rather than sending them as a JSON array.
+func NewlineDelimited() writeOption {
+	return func(fn *writeFn) {
+		fn.NewlineDelimited = true
+	}
+}
+
+// BatchSize is a Write option that sets the maximum number of elements sent
+// in one request.
+//
+// Default: 100
+func BatchSize(n int) writeOption {
+	return func(fn *writeFn) {
+		fn.BatchSize = n
+	}
+}
+
+// Concurrency is a Write option that sets the maximum number of requests in
+// flight at once from each worker thread.
+//
+// Default: 4
+func Concurrency(n int) writeOption {
+	return func(fn *writeFn) {
+		fn.Concurrency = n
+	}
+}
+
+// MaxRetries is a Write option that sets the maximum number of times a
+// request is retried, before it is emitted as a FailedRequest.
+//
+// Default: 5
+func MaxRetries(n int) writeOption {
+	return func(fn *writeFn) {
+		fn.MaxRetries = n
+	}
+}
+
+// Timeout is a Write option that sets the timeout of each request.
+//
+// Default: 30s
+func Timeout(d time.Duration) writeOption {
+	return func(fn *writeFn) {
+		fn.Timeout = d
+	}
+}
+
+// writeFn sends elements in batches, with several batches in flight at once.
+type writeFn struct {
+	URL              string            `json:"url"`
+	Method           string            `json:"method"`
+	Headers          map[string]string `json:"headers"`
+	NewlineDelimited bool              `json:"newlineDelimited"`
+	BatchSize        int               `json:"batchSize"`
+	Concurrency      int               `json:"concurrency"`
+	MaxRetries       int               `json:"maxRetries"`
+	Timeout          time.Duration     `json:"timeout"`
+
+	client *http.Client
+	batch  [][]byte
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	failed []FailedRequest
+}
+
+// Setup creates the HTTP client.
+func (fn *writeFn) Setup() {
+	fn.client = &http.Client{Timeout: fn.Timeout}
+	fn.sem = make(chan struct{}, fn.Concurrency)
+}
+
+func (fn *writeFn) ProcessElement(ctx context.Context, elem beam.X, _ func(FailedRequest)) error {
+	data, ok := elem.([]byte)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(elem); err != nil {
+			return errors.WithContextf(err, "encoding element %+v", elem)
+		}
+	}
+	fn.batch = append(fn.batch, data)
+	if len(fn.batch) >= fn.BatchSize {
+		return fn.flush(ctx)
+	}
+	return nil
+}
+
+// FinishBundle sends the remaining elements of the bundle, waits for all
+// requests to complete, and emits the failed requests.
+func (fn *writeFn) FinishBundle(ctx context.Context, emit func(FailedRequest)) error {
+	err := fn.flush(ctx)
+	fn.wg.Wait()
+	if err != nil {
+		return err
+	}
+	for _, f := range fn.failed {
+		emit(f)
+	}
+	fn.failed = nil
+	return ctx.Err()
+}
+
+// flush starts sending the batch, once fewer than Concurrency requests are in
+// flight.
+func (fn *writeFn) flush(ctx context.Context) error {
+	if len(fn.batch) == 0 {
+		return nil
+	}
+	body := fn.body()
+	n := len(fn.batch)
+	fn.batch = nil
+
+	select {
+	case fn.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	fn.wg.Add(1)
+	go func() {
+		defer func() {
+			<-fn.sem
+			fn.wg.Done()
+		}()
+		if status, err := fn.send(ctx, body); err != nil {
+			log.Errorf(ctx, "httpio: failed to send %v elements to %v: %v", n, fn.URL, err)
+			fn.mu.Lock()
+			fn.failed = append(fn.failed, FailedRequest{Body: body, StatusCode: status, Error: err.Error()})
+			fn.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// body returns the request body of the batch.
+func (fn *writeFn) body() []byte {
+	if len(fn.batch) == 1 {
+		return fn.batch[0]
+	}
+	sep := []byte(",")
+	if fn.NewlineDelimited {
+		sep = []byte("\n")
+	}
+	body := bytes.Join(fn.batch, sep)
+	if !fn.NewlineDelimited {
+		body = append(append([]byte("["), body...), ']')
+	}
+	return body
+}
+
+// send sends a request with the body, retrying transient failures. On
+// failure, it returns the status code of the last response, if any.
+func (fn *writeFn) send(ctx context.Context, body []byte) (int, error) {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		status, retry, wait, err := fn.do(ctx, body)
+		if err == nil {
+			return 0, nil
+		}
+		if !retry || attempt >= fn.MaxRetries || ctx.Err() != nil {
+			return status, err
+		}
+		if wait < backoff {
+			wait = backoff
+		}
+		log.Warnf(ctx, "httpio: retrying request to %v in %v: %v", fn.URL, wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return status, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// do sends one request. On failure, it returns the status code of the
+// response, whether the request should be retried, and the delay requested
+// by the server.
+func (fn *writeFn) do(ctx context.Context, body []byte) (int, bool, time.Duration, error) {
+	req, err := http.NewRequest(fn.Method, fn.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, 0, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range fn.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := fn.client.Do(req)
+	if err != nil {
+		return 0, true, 0, err // Network errors and timeouts are transient.
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 == 2 {
+		return 0, false, 0, nil
+	}
+
+	err = fmt.Errorf("%v: %s", resp.Status, bytes.TrimSpace(msg))
+	var wait time.Duration
+	if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil {
+		wait = time.Duration(s) * time.Second
+	}
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return resp.StatusCode, true, wait, err
+	default:
+		return resp.StatusCode, false, 0, err
+	}
+}
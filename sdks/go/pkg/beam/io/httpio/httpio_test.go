@@ -0,0 +1,159 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpio
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/fakes"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+type event struct {
+	ID   int    `json:"id"`
+	Kind string `json:"kind"`
+}
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*event)(nil)).Elem())
+}
+
+// bodies returns the bodies of the requests served by the server.
+func bodies(srv *fakes.HTTPRecorder) []string {
+	var ret []string
+	for _, r := range srv.Requests() {
+		ret = append(ret, string(r.Body))
+	}
+	return ret
+}
+
+// failNext scripts the next responses of the server to be errors with the
+// given statuses.
+func failNext(srv *fakes.HTTPRecorder, statuses ...int) {
+	for _, status := range statuses {
+		srv.Enqueue(fakes.Response{Status: status, Body: []byte("rejected")})
+	}
+}
+
+func withFastRetries() func() {
+	orig := retryBackoff
+	retryBackoff = time.Millisecond
+	return func() { retryBackoff = orig }
+}
+
+func TestWrite(t *testing.T) {
+	srv := fakes.NewHTTPRecorder(t, nil)
+
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, event{1, "a"}, event{2, "b"}, event{3, "c"})
+	failed := Write(s, srv.URL, col, BatchSize(2), BearerToken("secret"), Header("x-source", "beam"))
+	passert.Empty(s, failed)
+	ptest.RunAndValidate(t, p)
+
+	got := bodies(srv)
+	sort.Strings(got)
+	if want := `[[{"id":1,"kind":"a"},{"id":2,"kind":"b"}] {"id":3,"kind":"c"}]`; fmt.Sprint(got) != want {
+		t.Errorf("request bodies = %v, want %v", got, want)
+	}
+	for _, r := range srv.Requests() {
+		if r.Method != http.MethodPost {
+			t.Errorf("request method = %v, want POST", r.Method)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization header = %q, want Bearer secret", got)
+		}
+		if got := r.Header.Get("X-Source"); got != "beam" {
+			t.Errorf("X-Source header = %q, want beam", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type header = %q, want application/json", got)
+		}
+	}
+}
+
+func TestWrite_Bytes(t *testing.T) {
+	srv := fakes.NewHTTPRecorder(t, nil)
+
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, []byte("a,1"), []byte("b,2"))
+	Write(s, srv.URL, col, NewlineDelimited(), ContentType("text/csv"), Method(http.MethodPut))
+	ptest.RunAndValidate(t, p)
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("sent %v requests, want 1", len(reqs))
+	}
+	if got, want := string(reqs[0].Body), "a,1\nb,2"; got != want && got != "b,2\na,1" {
+		t.Errorf("request body = %q, want %q", got, want)
+	}
+	if r := reqs[0]; r.Method != http.MethodPut || r.Header.Get("Content-Type") != "text/csv" {
+		t.Errorf("request = %v with Content-Type %q, want PUT with text/csv", r.Method, r.Header.Get("Content-Type"))
+	}
+}
+
+func TestWrite_Retry(t *testing.T) {
+	defer withFastRetries()()
+	srv := fakes.NewHTTPRecorder(t, nil)
+	failNext(srv, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+	p, s := beam.NewPipelineWithRoot()
+	failed := Write(s, srv.URL, beam.Create(s, event{1, "a"}))
+	passert.Empty(s, failed)
+	ptest.RunAndValidate(t, p)
+
+	if len(srv.Requests()) != 3 {
+		t.Errorf("sent %v requests, want 3", len(srv.Requests()))
+	}
+}
+
+func TestWrite_DeadLetter(t *testing.T) {
+	defer withFastRetries()()
+	srv := fakes.NewHTTPRecorder(t, nil)
+	failNext(srv, http.StatusBadRequest)
+
+	p, s := beam.NewPipelineWithRoot()
+	failed := Write(s, srv.URL, beam.Create(s, event{1, "a"}), BatchSize(1), MaxRetries(1), Concurrency(1))
+	passert.Equals(s, beam.ParDo(s, func(f FailedRequest) string {
+		return fmt.Sprintf("%v %s", f.StatusCode, f.Body)
+	}, failed), `400 {"id":1,"kind":"a"}`)
+	ptest.RunAndValidate(t, p)
+
+	if len(srv.Requests()) != 1 {
+		t.Errorf("sent %v requests, want 1 for a permanent failure", len(srv.Requests()))
+	}
+}
+
+func TestWrite_RetriesExhausted(t *testing.T) {
+	defer withFastRetries()()
+	srv := fakes.NewHTTPRecorder(t, nil)
+	failNext(srv, http.StatusBadGateway, http.StatusBadGateway)
+
+	p, s := beam.NewPipelineWithRoot()
+	failed := Write(s, srv.URL, beam.Create(s, event{1, "a"}), MaxRetries(1))
+	passert.Count(s, failed, "failed", 1)
+	ptest.RunAndValidate(t, p)
+
+	if len(srv.Requests()) != 2 {
+		t.Errorf("sent %v requests, want 2", len(srv.Requests()))
+	}
+}
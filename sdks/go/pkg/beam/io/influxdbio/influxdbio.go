@@ -0,0 +1,310 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package influxdbio contains a transform for writing time-series points to
+// InfluxDB with the line protocol.
+//
+// Experimental.
+package influxdbio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*writeFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*PartialWrite)(nil)).Elem())
+}
+
+// retryBackoff is the delay before the first retry of a failed write, which
+// doubles for each further retry. It is a variable so tests can shorten it.
+var retryBackoff = time.Second
+
+// PartialWrite is a batch of points of which the server rejected some, and
+// wrote the rest.
+type PartialWrite struct {
+	// Lines is the line protocol of the batch.
+	Lines string
+	// Error is the error reported by the server, which describes the
+	// rejected points.
+	Error string
+}
+
+// partialWriteError is the error of a write the server rejected some points
+// of.
+type partialWriteError struct {
+	msg string
+}
+
+func (e *partialWriteError) Error() string {
+	return e.msg
+}
+
+// Write writes the elements of the given PCollection<t> as points to the
+// bucket on the InfluxDB server at serverURL, where t is a struct type, and
+// returns a PCollection of the PartialWrites of batches the server rejected
+// some points of. Points are written with the /api/v2/write endpoint, which
+// is also supported by InfluxDB 1.8 with a bucket of the form
+// "database/retention-policy".
+//
+// Exported fields are mapped to points by their `influx` struct tag:
+//
+//	type CPU struct {
+//		Host  string    `influx:"host,tag"`
+//		Usage float64   `influx:"usage_percent"`
+//		Cores int       // A field named "cores".
+//		Time  time.Time `influx:",timestamp"`
+//		Debug string    `influx:"-"`
+//	}
+//
+// Tags must be strings, and empty tags are omitted. Fields may be numbers,
+// booleans, strings or pointers to them, and nil pointers are omitted. Points
+// are written at the time of the timestamp field if there is one and it is
+// set, and otherwise at the event time of the element. Elements at the
+// minimum event time are written at the time the server receives them.
+//
+// Points are written in batches of up to BatchSize points. Batches are retried
+// with exponential backoff when the server is unavailable or throttling. When
+// the server reports a partial write, it has written the valid points of the
+// batch, and retrying would only be rejected again, so the batch is emitted
+// as a PartialWrite instead, to be reported or written to a dead-letter sink.
+//
+// Example:
+//
+//	rejected := influxdbio.Write(s, "http://localhost:8086", "metrics", cpu,
+//		influxdbio.Org("acme"), influxdbio.Token(token), influxdbio.Measurement("cpu"))
+//	textio.Write(s, "gs://bucket/rejected.json", beam.ParDo(s, toJSON, rejected))
+func Write(s beam.Scope, serverURL, bucket string, col beam.PCollection, opts ...writeOption) beam.PCollection {
+	s = s.Scope("influxdbio.Write")
+
+	t := col.Type().Type()
+	if _, err := newPointType(t); err != nil {
+		panic(err)
+	}
+	fn := &writeFn{
+		URL:         strings.TrimSuffix(serverURL, "/"),
+		Bucket:      bucket,
+		Measurement: strings.ToLower(t.Name()),
+		BatchSize:   5000,
+		MaxRetries:  5,
+		Type:        beam.EncodedType{T: t},
+	}
+	for _, opt := range opts {
+		opt(fn)
+	}
+	if fn.Measurement == "" {
+		panic(errors.Errorf("influxdbio.Write requires a measurement for unnamed type %v", t))
+	}
+	if fn.BatchSize < 1 {
+		panic(errors.Errorf("influxdbio.Write requires a positive batch size, got %v", fn.BatchSize))
+	}
+	return beam.ParDo(s, fn, col)
+}
+
+type writeOption func(*writeFn)
+
+// Org is a Write option that sets the organization owning the bucket. It is
+// required by InfluxDB 2.x, and ignored by InfluxDB 1.8.
+func Org(org string) writeOption {
+	return func(fn *writeFn) {
+		fn.Org = org
+	}
+}
+
+// Token is a Write option that sets the API token used to authenticate. For
+// InfluxDB 1.8 with authentication enabled, the token is "username:password".
+// The token is stored in the pipeline graph.
+func Token(token string) writeOption {
+	return func(fn *writeFn) {
+		fn.Token = token
+	}
+}
+
+// Measurement is a Write option that sets the measurement of the points.
+//
+// Default: the lower-cased name of the element type
+func Measurement(name string) writeOption {
+	return func(fn *writeFn) {
+		fn.Measurement = name
+	}
+}
+
+// BatchSize is a Write option that sets the maximum number of points written
+// in one request.
+//
+// Default: 5000
+func BatchSize(n int) writeOption {
+	return func(fn *writeFn) {
+		fn.BatchSize = n
+	}
+}
+
+// MaxRetries is a Write option that sets the maximum number of times a failed
+// batch is retried before the bundle fails.
+//
+// Default: 5
+func MaxRetries(n int) writeOption {
+	return func(fn *writeFn) {
+		fn.MaxRetries = n
+	}
+}
+
+// writeFn writes points in batches.
+type writeFn struct {
+	URL         string `json:"url"`
+	Bucket      string `json:"bucket"`
+	Org         string `json:"org"`
+	Token       string `json:"token"`
+	Measurement string `json:"measurement"`
+	BatchSize   int    `json:"batchSize"`
+	MaxRetries  int    `json:"maxRetries"`
+	// Type is the encoded point type.
+	Type beam.EncodedType `json:"type"`
+
+	pt          *pointType
+	measurement string
+	endpoint    string
+	client      *http.Client
+	buf         []byte
+	n           int // Number of points in buf.
+}
+
+// Setup maps the point type and builds the write endpoint.
+func (fn *writeFn) Setup() error {
+	pt, err := newPointType(fn.Type.T)
+	if err != nil {
+		return err
+	}
+	fn.pt = pt
+	fn.measurement = escape(fn.Measurement, measurementEscaper)
+
+	q := url.Values{}
+	q.Set("bucket", fn.Bucket)
+	q.Set("precision", "ns")
+	if fn.Org != "" {
+		q.Set("org", fn.Org)
+	}
+	fn.endpoint = fn.URL + "/api/v2/write?" + q.Encode()
+	fn.client = &http.Client{Timeout: time.Minute}
+	return nil
+}
+
+func (fn *writeFn) ProcessElement(ctx context.Context, et beam.EventTime, elem beam.X, emit func(PartialWrite)) error {
+	var ts *int64
+	if et != mtime.MinTimestamp {
+		n := et.Milliseconds() * int64(time.Millisecond)
+		ts = &n
+	}
+	buf, err := fn.pt.appendPoint(fn.buf, fn.measurement, reflect.ValueOf(elem), ts)
+	if err != nil {
+		return errors.WithContextf(err, "encoding point %+v", elem)
+	}
+	fn.buf = buf
+	fn.n++
+	if fn.n >= fn.BatchSize {
+		return fn.flush(ctx, emit)
+	}
+	return nil
+}
+
+// FinishBundle writes the remaining points of the bundle.
+func (fn *writeFn) FinishBundle(ctx context.Context, emit func(PartialWrite)) error {
+	return fn.flush(ctx, emit)
+}
+
+func (fn *writeFn) flush(ctx context.Context, emit func(PartialWrite)) error {
+	if fn.n == 0 {
+		return nil
+	}
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, wait, err := fn.write(ctx)
+		if err == nil {
+			break
+		}
+		if pe, ok := err.(*partialWriteError); ok {
+			log.Errorf(ctx, "influxdbio: server rejected some of %v points written to %v: %v", fn.n, fn.Bucket, pe)
+			emit(PartialWrite{Lines: string(fn.buf), Error: pe.Error()})
+			break
+		}
+		if !retry || attempt >= fn.MaxRetries {
+			return errors.Wrapf(err, "influxdbio: failed to write %v points to %v", fn.n, fn.Bucket)
+		}
+		if wait < backoff {
+			wait = backoff
+		}
+		log.Warnf(ctx, "influxdbio: retrying write of %v points in %v: %v", fn.n, wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+	fn.buf, fn.n = fn.buf[:0], 0
+	return nil
+}
+
+// write sends the buffered points. On failure, it returns whether the write
+// should be retried, and the delay requested by the server.
+func (fn *writeFn) write(ctx context.Context) (bool, time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, fn.endpoint, bytes.NewReader(fn.buf))
+	if err != nil {
+		return false, 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if fn.Token != "" {
+		req.Header.Set("Authorization", "Token "+fn.Token)
+	}
+	resp, err := fn.client.Do(req)
+	if err != nil {
+		return true, 0, err // Network errors are transient.
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 == 2 {
+		return false, 0, nil
+	}
+
+	err = fmt.Errorf("%v: %s", resp.Status, bytes.TrimSpace(body))
+	var wait time.Duration
+	if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil {
+		wait = time.Duration(s) * time.Second
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, wait, err
+	case resp.StatusCode == http.StatusBadRequest && bytes.Contains(body, []byte("partial write")):
+		return false, 0, &partialWriteError{err.Error()}
+	default:
+		return false, 0, err
+	}
+}
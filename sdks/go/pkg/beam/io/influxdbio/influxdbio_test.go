@@ -0,0 +1,149 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdbio
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+type reading struct {
+	Sensor string `influx:"sensor,tag"`
+	Value  float64
+	Time   time.Time `influx:",timestamp"`
+}
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*reading)(nil)).Elem())
+}
+
+// fakeServer is an InfluxDB write endpoint, which replies to requests with
+// the given statuses and then with 204 No Content.
+type fakeServer struct {
+	statuses []int
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, string(body))
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		w.WriteHeader(status)
+		w.Write([]byte(`{"code":"invalid","message":"partial write: field type conflict"}`))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func withFastRetries() func() {
+	orig := retryBackoff
+	retryBackoff = time.Millisecond
+	return func() { retryBackoff = orig }
+}
+
+func TestWrite(t *testing.T) {
+	fake := &fakeServer{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, reading{"a", 1, time.Unix(1, 0)}, reading{"b", 2, time.Unix(2, 0)}, reading{"c", 3, time.Unix(3, 0)})
+	Write(s, srv.URL, "metrics", col, Org("acme"), Token("secret"), BatchSize(2))
+	ptest.RunAndValidate(t, p)
+
+	if got, want := fake.bodies, []string{"reading,sensor=a value=1 1000000000\nreading,sensor=b value=2 2000000000\n", "reading,sensor=c value=3 3000000000\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+	r := fake.requests[0]
+	if got, want := r.URL.Path, "/api/v2/write"; got != want {
+		t.Errorf("path = %v, want %v", got, want)
+	}
+	if got, want := r.URL.RawQuery, "bucket=metrics&org=acme&precision=ns"; got != want {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+	if got, want := r.Header.Get("Authorization"), "Token secret"; got != want {
+		t.Errorf("Authorization = %v, want %v", got, want)
+	}
+}
+
+func TestWrite_Retry(t *testing.T) {
+	defer withFastRetries()()
+	fake := &fakeServer{statuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	p, s := beam.NewPipelineWithRoot()
+	rejected := Write(s, srv.URL, "metrics", beam.Create(s, reading{Sensor: "a", Value: 1}))
+	passert.Empty(s, rejected)
+	ptest.RunAndValidate(t, p)
+
+	if got := len(fake.requests); got != 3 {
+		t.Errorf("made %v requests, want 3", got)
+	}
+}
+
+func TestWrite_PartialWrite(t *testing.T) {
+	defer withFastRetries()()
+	fake := &fakeServer{statuses: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, reading{"a", 1, time.Unix(1, 0)}, reading{"b", 2, time.Unix(2, 0)})
+	rejected := Write(s, srv.URL, "metrics", col)
+	passert.Equals(s, rejected, PartialWrite{
+		Lines: "reading,sensor=a value=1 1000000000\nreading,sensor=b value=2 2000000000\n",
+		Error: `400 Bad Request: {"code":"invalid","message":"partial write: field type conflict"}`,
+	})
+	ptest.RunAndValidate(t, p)
+
+	if got := len(fake.requests); got != 1 {
+		t.Errorf("made %v requests, want the partial write not to be retried", got)
+	}
+}
+
+func TestWrite_Failure(t *testing.T) {
+	defer withFastRetries()()
+	fake := &fakeServer{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	p, s := beam.NewPipelineWithRoot()
+	Write(s, srv.URL, "metrics", beam.Create(s, reading{Sensor: "a", Value: 1}), MaxRetries(1))
+	if err := ptest.Run(p); err == nil {
+		t.Error("pipeline succeeded, want error after exhausting retries")
+	}
+	if got := len(fake.requests); got != 2 {
+		t.Errorf("made %v requests, want 2", got)
+	}
+}
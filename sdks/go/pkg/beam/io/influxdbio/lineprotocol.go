@@ -0,0 +1,178 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdbio
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// pointType describes how a struct type is mapped to points.
+type pointType struct {
+	tags      []column // Sorted by name, as recommended for write performance.
+	fields    []column
+	timestamp int // Index of the timestamp field, or -1.
+}
+
+// column is a struct field mapped to a tag or field of a point.
+type column struct {
+	key   string // Escaped key.
+	index int
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// newPointType maps the exported fields of the struct type t to the tags,
+// fields and timestamp of points.
+func newPointType(t reflect.Type) (*pointType, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("influxdbio: points must be structs, got %v", t)
+	}
+	pt := &pointType{timestamp: -1}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("influx")
+		if tag == "-" {
+			continue
+		}
+		name, kind := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, kind = tag[:i], tag[i+1:]
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		switch kind {
+		case "tag":
+			if f.Type.Kind() != reflect.String {
+				return nil, errors.Errorf("influxdbio: tag %v of %v must be a string, got %v", name, t, f.Type)
+			}
+			pt.tags = append(pt.tags, column{key: escape(name, keyEscaper), index: i})
+		case "timestamp":
+			if f.Type != timeType {
+				return nil, errors.Errorf("influxdbio: timestamp of %v must be a time.Time, got %v", t, f.Type)
+			}
+			pt.timestamp = i
+		case "", "field":
+			pt.fields = append(pt.fields, column{key: escape(name, keyEscaper), index: i})
+		default:
+			return nil, errors.Errorf("influxdbio: invalid influx tag %q on %v.%v", tag, t, f.Name)
+		}
+	}
+	if len(pt.fields) == 0 {
+		return nil, errors.Errorf("influxdbio: %v has no fields", t)
+	}
+	sort.Slice(pt.tags, func(i, j int) bool { return pt.tags[i].key < pt.tags[j].key })
+	return pt, nil
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+func escape(s string, r *strings.Replacer) string {
+	return r.Replace(s)
+}
+
+// appendPoint appends the line protocol encoding of the struct v to buf, with
+// the given measurement. If v has no timestamp field, the point is written at
+// the given time in nanoseconds, unless it is nil.
+func (pt *pointType) appendPoint(buf []byte, measurement string, v reflect.Value, ts *int64) ([]byte, error) {
+	buf = append(buf, measurement...)
+	for _, c := range pt.tags {
+		s := v.Field(c.index).String()
+		if s == "" {
+			continue // Empty tag values are not allowed.
+		}
+		buf = append(buf, ',')
+		buf = append(buf, c.key...)
+		buf = append(buf, '=')
+		buf = append(buf, escape(s, keyEscaper)...)
+	}
+
+	sep := byte(' ')
+	for _, c := range pt.fields {
+		f := v.Field(c.index)
+		if f.Kind() == reflect.Ptr {
+			if f.IsNil() {
+				continue // Omit unset fields.
+			}
+			f = f.Elem()
+		}
+		buf = append(buf, sep)
+		sep = ','
+		buf = append(buf, c.key...)
+		buf = append(buf, '=')
+		var err error
+		if buf, err = appendFieldValue(buf, f); err != nil {
+			return nil, errors.WithContextf(err, "encoding field %v", c.key)
+		}
+	}
+	if sep == ' ' {
+		return nil, errors.New("influxdbio: point has no set fields")
+	}
+
+	if pt.timestamp >= 0 {
+		t := v.Field(pt.timestamp).Interface().(time.Time)
+		if !t.IsZero() {
+			n := t.UnixNano()
+			ts = &n
+		}
+	}
+	if ts != nil {
+		buf = append(buf, ' ')
+		buf = strconv.AppendInt(buf, *ts, 10)
+	}
+	return append(buf, '\n'), nil
+}
+
+func appendFieldValue(buf []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, errors.Errorf("unsupported float value %v", f)
+		}
+		return strconv.AppendFloat(buf, f, 'g', -1, 64), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return append(strconv.AppendInt(buf, v.Int(), 10), 'i'), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := v.Uint()
+		if u > math.MaxInt64 {
+			return nil, errors.Errorf("unsigned value %v overflows a 64-bit integer", u)
+		}
+		return append(strconv.AppendUint(buf, u, 10), 'i'), nil
+	case reflect.Bool:
+		return strconv.AppendBool(buf, v.Bool()), nil
+	case reflect.String:
+		buf = append(buf, '"')
+		buf = append(buf, escape(v.String(), stringEscaper)...)
+		return append(buf, '"'), nil
+	default:
+		return nil, errors.Errorf("unsupported field type %v", v.Type())
+	}
+}
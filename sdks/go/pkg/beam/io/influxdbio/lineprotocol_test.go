@@ -0,0 +1,106 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdbio
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type cpu struct {
+	Host   string  `influx:"host,tag"`
+	Region string  `influx:"region name,tag"`
+	Usage  float64 `influx:"usage_percent"`
+	Cores  int
+	Idle   *bool
+	Note   string
+	Time   time.Time `influx:",timestamp"`
+	Debug  string    `influx:"-"`
+	secret string
+}
+
+func TestAppendPoint(t *testing.T) {
+	idle := true
+	n := int64(42)
+	tests := []struct {
+		name string
+		in   cpu
+		ts   *int64
+		want string
+	}{
+		{
+			name: "full",
+			in:   cpu{Host: "a,b", Region: "us west", Usage: 0.5, Cores: 4, Idle: &idle, Note: `say "hi"`, Time: time.Unix(1, 5)},
+			want: `cpu,host=a\,b,region\ name=us\ west usage_percent=0.5,cores=4i,idle=true,note="say \"hi\"" 1000000005` + "\n",
+		},
+		{
+			name: "event time",
+			in:   cpu{Host: "a", Usage: 1},
+			ts:   &n,
+			want: "cpu,host=a usage_percent=1,cores=0i,note=\"\" 42\n",
+		},
+		{
+			name: "server time",
+			in:   cpu{Usage: 2e-9},
+			want: "cpu usage_percent=2e-09,cores=0i,note=\"\"\n",
+		},
+	}
+	pt, err := newPointType(reflect.TypeOf(cpu{}))
+	if err != nil {
+		t.Fatalf("newPointType failed: %v", err)
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got, err := pt.appendPoint(nil, "cpu", reflect.ValueOf(test.in), test.ts)
+			if err != nil {
+				t.Fatalf("appendPoint failed: %v", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("appendPoint = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewPointType_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		t    interface{}
+	}{
+		{"not a struct", 1},
+		{"no fields", struct {
+			Host string `influx:"host,tag"`
+		}{}},
+		{"numeric tag", struct {
+			ID int `influx:"id,tag"`
+			V  int
+		}{}},
+		{"bad timestamp", struct {
+			T int64 `influx:",timestamp"`
+			V int
+		}{}},
+		{"bad kind", struct {
+			V int `influx:"v,label"`
+		}{}},
+	}
+	for _, test := range tests {
+		if _, err := newPointType(reflect.TypeOf(test.t)); err == nil {
+			t.Errorf("newPointType(%v) succeeded, want error", test.name)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pulsario contains transforms for reading from and writing to Apache
+// Pulsar (https://pulsar.apache.org/) topics.
+//
+// Read reads each partition of a topic with a splittable DoFn whose
+// restriction is a cursor into the partition, so runners can checkpoint a read
+// at the last emitted message and resume it after that message. Write
+// produces messages with batching, routing keyed messages to partitions by the
+// hash of their key.
+//
+// Experimental.
+package pulsario
+
+import (
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// newClient creates a Pulsar client for the service at the given URL. It is a
+// variable so tests can substitute an in-memory client.
+var newClient = func(url string) (pulsar.Client, error) {
+	return pulsar.NewClient(pulsar.ClientOptions{URL: url})
+}
+
+// deserializeMessageID decodes a message ID serialized in a restriction. It
+// is a variable so tests can substitute their own message IDs.
+var deserializeMessageID = pulsar.DeserializeMessageID
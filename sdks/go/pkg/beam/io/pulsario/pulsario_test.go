@@ -0,0 +1,299 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pulsario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/rtrackers/cursor"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/fakes"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// fakeID is a message ID identified by its index in a partition.
+type fakeID int
+
+func (id fakeID) Serialize() []byte   { return []byte(strconv.Itoa(int(id))) }
+func (id fakeID) LedgerID() int64     { return 0 }
+func (id fakeID) EntryID() int64      { return int64(id) }
+func (id fakeID) BatchIdx() int32     { return 0 }
+func (id fakeID) PartitionIdx() int32 { return 0 }
+
+func deserializeFakeID(data []byte) (pulsar.MessageID, error) {
+	i, err := strconv.Atoi(string(data))
+	return fakeID(i), err
+}
+
+type fakeMessage struct {
+	pulsar.Message
+	id      fakeID
+	payload []byte
+}
+
+func (m *fakeMessage) ID() pulsar.MessageID   { return m.id }
+func (m *fakeMessage) Payload() []byte        { return m.payload }
+func (m *fakeMessage) EventTime() time.Time   { return time.Time{} }
+func (m *fakeMessage) PublishTime() time.Time { return time.Unix(int64(m.id), 0) }
+
+// fakeClient is an in-memory Pulsar service, whose partitioned topics are
+// the topics of a log.
+type fakeClient struct {
+	log *fakes.Log
+}
+
+// partition returns the topic and the partition of the log a Pulsar topic
+// name refers to. Names without a partition refer to the first partition.
+func partition(name string) (string, int) {
+	if i := strings.LastIndex(name, "-partition-"); i >= 0 {
+		if p, err := strconv.Atoi(name[i+len("-partition-"):]); err == nil {
+			return name[:i], p
+		}
+	}
+	return name, 0
+}
+
+func (c *fakeClient) CreateProducer(opts pulsar.ProducerOptions) (pulsar.Producer, error) {
+	return &fakeProducer{log: c.log, topic: opts.Topic}, nil
+}
+
+func (c *fakeClient) Subscribe(pulsar.ConsumerOptions) (pulsar.Consumer, error) {
+	return nil, errors.New("unsupported")
+}
+
+// CreateReader creates a reader of a partition. Readers starting at the
+// latest message are positioned at the end of the partition, or at its last
+// message if they read it inclusively, like those of the client.
+func (c *fakeClient) CreateReader(opts pulsar.ReaderOptions) (pulsar.Reader, error) {
+	topic, p := partition(opts.Topic)
+	next := int64(0)
+	if id, ok := opts.StartMessageID.(fakeID); ok {
+		next = int64(id) + 1
+	} else if opts.StartMessageID == pulsar.LatestMessageID() {
+		records, err := c.log.Records(topic, p)
+		if err != nil {
+			return nil, err
+		}
+		next = int64(len(records))
+		if opts.StartMessageIDInclusive && next > 0 {
+			next--
+		}
+	}
+	return &fakeReader{log: c.log, topic: topic, partition: p, next: next}, nil
+}
+
+func (c *fakeClient) TopicPartitions(topic string) ([]string, error) {
+	n, err := c.log.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+	var ps []string
+	for i := 0; i < n; i++ {
+		ps = append(ps, fmt.Sprintf("%v-partition-%v", topic, i))
+	}
+	return ps, nil
+}
+
+func (c *fakeClient) Close() {}
+
+type fakeReader struct {
+	pulsar.Reader
+	log       *fakes.Log
+	topic     string
+	partition int
+	next      int64
+}
+
+func (r *fakeReader) Next(ctx context.Context) (pulsar.Message, error) {
+	records, err := r.log.Fetch(ctx, r.topic, r.partition, r.next, 1)
+	if err != nil {
+		return nil, err
+	}
+	msg := &fakeMessage{id: fakeID(r.next), payload: records[0].Value}
+	r.next++
+	return msg, nil
+}
+
+func (r *fakeReader) HasNext() bool {
+	records, err := r.log.Records(r.topic, r.partition)
+	return err == nil && int64(len(records)) > r.next
+}
+
+func (r *fakeReader) Close() {}
+
+type fakeProducer struct {
+	pulsar.Producer
+	log   *fakes.Log
+	topic string
+}
+
+func (p *fakeProducer) SendAsync(_ context.Context, msg *pulsar.ProducerMessage, callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	offset, err := p.log.Produce(p.topic, -1, []byte(msg.Key), msg.Payload)
+	if err != nil {
+		callback(nil, msg, err)
+		return
+	}
+	callback(fakeID(offset), msg, nil)
+}
+
+func (p *fakeProducer) Flush() error { return nil }
+func (p *fakeProducer) Close()       {}
+
+// newFakeClient returns a client of a log with a topic of the given
+// partitions, holding the payloads.
+func newFakeClient(t *testing.T, topic string, partitions ...[]string) *fakeClient {
+	log := fakes.NewLog()
+	if err := log.CreateTopic(topic, len(partitions)); err != nil {
+		t.Fatal(err)
+	}
+	for i, payloads := range partitions {
+		for _, payload := range payloads {
+			if _, err := log.Produce(topic, i, nil, []byte(payload)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return &fakeClient{log: log}
+}
+
+// install substitutes the fake client for created clients, and its message
+// IDs for deserialized ones, for the duration of the test.
+func (c *fakeClient) install(t *testing.T) {
+	fakes.Override(t, &newClient, func(string) (pulsar.Client, error) { return c, nil })
+	fakes.Override(t, &deserializeMessageID, deserializeFakeID)
+}
+
+func TestRead(t *testing.T) {
+	c := newFakeClient(t, "topic", []string{"a0", "a1", "a2"}, []string{"b0", "b1", "b2"})
+	c.install(t)
+
+	p, s := beam.NewPipelineWithRoot()
+	payloads := Read(s, "pulsar://fake", "topic", MaxNumRecords(2))
+	passert.Equals(s, payloads, []byte("a0"), []byte("a1"), []byte("b0"), []byte("b1"))
+	ptest.RunAndValidate(t, p)
+}
+
+// TestRead_Resume tests that reading a checkpointed restriction resumes after
+// its cursor.
+func TestRead_Resume(t *testing.T) {
+	c := newFakeClient(t, "p", []string{"a", "b", "c"})
+	c.install(t)
+
+	fn := &readFn{URL: "pulsar://fake", StartPosition: Earliest, MaxReadTime: 50 * time.Millisecond}
+	if err := fn.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	rt := sdf.NewLockRTracker(cursor.NewTracker(cursor.Restriction{After: fakeID(0).Serialize(), Limit: -1}))
+	var got []string
+	err := fn.ProcessElement(context.Background(), rt, "p", nil, func(_ beam.EventTime, b []byte) {
+		got = append(got, string(b))
+	})
+	if err != nil {
+		t.Fatalf("ProcessElement failed: %v", err)
+	}
+	if want := "[b c]"; fmt.Sprint(got) != want {
+		t.Errorf("ProcessElement emitted %v, want %v", got, want)
+	}
+	if !rt.IsDone() {
+		t.Errorf("restriction is not done after the maximum read time")
+	}
+}
+
+// TestRead_Latest tests that partitions read from the latest position are
+// read after their last message when the read started, however late their
+// restrictions are read.
+func TestRead_Latest(t *testing.T) {
+	c := newFakeClient(t, "topic", []string{"a0", "a1"}, []string{})
+	c.install(t)
+
+	pfn := &partitionsFn{URL: "pulsar://fake", Topic: "topic", StartPosition: Latest}
+	starts := make(map[string][]byte)
+	err := pfn.ProcessElement(context.Background(), nil, func(p string, after []byte) {
+		starts[p] = after
+	})
+	if err != nil {
+		t.Fatalf("partitionsFn failed: %v", err)
+	}
+	// Messages published after the read started, but before the restrictions
+	// are read.
+	for i, payload := range []string{"a2", "b0"} {
+		if _, err := c.log.Produce("topic", i, nil, []byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fn := &readFn{URL: "pulsar://fake", MaxNumRecords: -1, MaxReadTime: 50 * time.Millisecond}
+	if err := fn.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	for p, want := range map[string]string{"topic-partition-0": "[a2]", "topic-partition-1": "[b0]"} {
+		rt := fn.CreateTracker(fn.CreateInitialRestriction(p, starts[p]))
+		var got []string
+		err := fn.ProcessElement(context.Background(), rt, p, starts[p], func(_ beam.EventTime, b []byte) {
+			got = append(got, string(b))
+		})
+		if err != nil {
+			t.Fatalf("ProcessElement failed: %v", err)
+		}
+		if fmt.Sprint(got) != want {
+			t.Errorf("ProcessElement(%v) emitted %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	c := newFakeClient(t, "topic", nil)
+	c.install(t)
+
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(b []byte) (string, []byte) { return string(b[:1]), b }, beam.Create(s, []byte("k1"), []byte("k2")))
+	Write(s, "pulsar://fake", "topic", keyed)
+	ptest.RunAndValidate(t, p)
+
+	records, err := c.log.Records("topic", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, r := range records {
+		got[string(r.Value)] = string(r.Key)
+	}
+	if want := "map[k1:k k2:k]"; fmt.Sprint(got) != want {
+		t.Errorf("produced %v, want %v", got, want)
+	}
+}
+
+func TestWrite_Failure(t *testing.T) {
+	c := newFakeClient(t, "topic", nil)
+	c.log.FailNext(math.MaxInt32, errors.New("send failed"))
+	c.install(t)
+
+	p, s := beam.NewPipelineWithRoot()
+	Write(s, "pulsar://fake", "topic", beam.Create(s, []byte("a")))
+	if err := ptest.Run(p); err == nil {
+		t.Error("pipeline succeeded, want error for failed send")
+	}
+}
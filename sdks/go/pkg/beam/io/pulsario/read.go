@@ -0,0 +1,289 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pulsario
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/rtrackers/cursor"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*partitionsFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*readFn)(nil)).Elem())
+}
+
+// StartPosition determines where reading begins in partitions without a
+// checkpointed cursor.
+type StartPosition string
+
+const (
+	// Earliest reads partitions from the oldest available message.
+	Earliest StartPosition = "earliest"
+	// Latest reads partitions from messages published after the read starts.
+	// The last message of each partition is looked up when the read starts,
+	// and the partition is read from the message after it.
+	Latest StartPosition = "latest"
+)
+
+// Read reads messages from every partition of the given topic on the Pulsar
+// service at url, and returns a PCollection<[]byte> of message payloads. Each
+// element is timestamped with the event time of the message, or with the
+// publish time if the producer did not set one.
+//
+// Each partition is read with its own restriction, which is a cursor after the
+// last message claimed. Runners checkpoint the read by splitting the
+// restriction, and the residual resumes after the last emitted message.
+// Non-partitioned topics are read as a single partition.
+//
+// Read runs until the pipeline is cancelled, unless the read is bounded with
+// MaxNumRecords or MaxReadTime.
+//
+// Example:
+//
+//	url := "pulsar://localhost:6650"
+//	payloads := pulsario.Read(s, url, "persistent://public/default/events",
+//		pulsario.StartFrom(pulsario.Latest))
+func Read(s beam.Scope, url, topic string, opts ...readOption) beam.PCollection {
+	s = s.Scope("pulsario.Read")
+
+	fn := &readFn{
+		URL:           url,
+		StartPosition: Earliest,
+		MaxNumRecords: -1,
+	}
+	for _, opt := range opts {
+		opt(fn)
+	}
+
+	imp := beam.Impulse(s)
+	partitions := beam.ParDo(s, &partitionsFn{URL: url, Topic: topic, StartPosition: fn.StartPosition}, imp)
+	return beam.ParDo(s, fn, partitions)
+}
+
+type readOption func(*readFn)
+
+// StartFrom is a Read option that sets where partitions are read from when
+// the read starts.
+//
+// Default: pulsario.Earliest
+func StartFrom(pos StartPosition) readOption {
+	return func(fn *readFn) {
+		fn.StartPosition = pos
+	}
+}
+
+// MaxNumRecords is a Read option that specifies the maximum number of messages
+// to read from each partition. Setting this causes the Read to execute as a
+// bounded transform. Useful for tests and demo applications.
+func MaxNumRecords(n int64) readOption {
+	return func(fn *readFn) {
+		fn.MaxNumRecords = n
+	}
+}
+
+// MaxReadTime is a Read option that specifies the maximum duration of time
+// each restriction is read for. Setting this causes the Read to execute as a
+// bounded transform. Useful for tests and demo applications.
+func MaxReadTime(d time.Duration) readOption {
+	return func(fn *readFn) {
+		fn.MaxReadTime = d
+	}
+}
+
+// partitionsFn emits the name of each partition of a topic, with the
+// serialized ID of the message its read starts after, if any.
+type partitionsFn struct {
+	URL           string        `json:"url"`
+	Topic         string        `json:"topic"`
+	StartPosition StartPosition `json:"startPosition"`
+}
+
+func (fn *partitionsFn) ProcessElement(ctx context.Context, _ []byte, emit func(string, []byte)) error {
+	client, err := newClient(fn.URL)
+	if err != nil {
+		return errors.Wrap(err, "pulsario: failed to create client")
+	}
+	defer client.Close()
+
+	partitions, err := client.TopicPartitions(fn.Topic)
+	if err != nil {
+		return errors.Wrapf(err, "pulsario: failed to list partitions of %v", fn.Topic)
+	}
+	log.Infof(ctx, "pulsario: reading %v partitions of %v", len(partitions), fn.Topic)
+	for _, p := range partitions {
+		var after []byte
+		switch fn.StartPosition {
+		case Earliest:
+		case Latest:
+			if after, err = lastMessageID(ctx, client, p); err != nil {
+				return errors.Wrapf(err, "pulsario: failed to look up the last message of %v", p)
+			}
+		default:
+			return errors.Errorf("pulsario: invalid start position %q", fn.StartPosition)
+		}
+		emit(p, after)
+	}
+	return nil
+}
+
+// lastMessageID returns the serialized ID of the last message of a partition,
+// or nil if it has none. The reader starting at the latest message
+// inclusively is positioned at the last message by the client.
+func lastMessageID(ctx context.Context, client pulsar.Client, partition string) ([]byte, error) {
+	reader, err := client.CreateReader(pulsar.ReaderOptions{
+		Topic:                   partition,
+		StartMessageID:          pulsar.LatestMessageID(),
+		StartMessageIDInclusive: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if !reader.HasNext() {
+		return nil, nil
+	}
+	msg, err := reader.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return msg.ID().Serialize(), nil
+}
+
+// readFn is a splittable DoFn that reads messages from a topic partition,
+// after the message its initial restriction starts after, if any.
+type readFn struct {
+	URL           string        `json:"url"`
+	MaxNumRecords int64         `json:"maxNumRecords"`
+	MaxReadTime   time.Duration `json:"maxReadTime"`
+	// StartPosition is resolved to the initial cursors of the partitions by
+	// partitionsFn, so workers do not need it.
+	StartPosition StartPosition `json:"-"`
+
+	client pulsar.Client
+}
+
+// CreateInitialRestriction creates a cursor restriction after the message the
+// partition is read after, or at its oldest message.
+func (fn *readFn) CreateInitialRestriction(_ string, after []byte) cursor.Restriction {
+	return cursor.Restriction{After: after, Limit: fn.MaxNumRecords}
+}
+
+// SplitRestriction returns the restriction unchanged, as a partition is read
+// sequentially.
+func (fn *readFn) SplitRestriction(_ string, _ []byte, rest cursor.Restriction) []cursor.Restriction {
+	return []cursor.Restriction{rest}
+}
+
+// RestrictionSize returns the number of messages in a bounded restriction,
+// and one for unbounded restrictions.
+func (fn *readFn) RestrictionSize(_ string, _ []byte, rest cursor.Restriction) float64 {
+	if rest.IsBounded() {
+		return float64(rest.Limit)
+	}
+	return 1
+}
+
+// CreateTracker creates a cursor tracker wrapped in an sdf.LockRTracker for
+// the restriction.
+func (fn *readFn) CreateTracker(rest cursor.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(cursor.NewTracker(rest))
+}
+
+// Setup creates the Pulsar client.
+func (fn *readFn) Setup() error {
+	client, err := newClient(fn.URL)
+	if err != nil {
+		return errors.Wrap(err, "pulsario: failed to create client")
+	}
+	fn.client = client
+	return nil
+}
+
+// startID returns the message ID a restriction starts after, and whether that
+// message should be read inclusively.
+func (fn *readFn) startID(rest cursor.Restriction) (pulsar.MessageID, bool, error) {
+	if len(rest.After) > 0 {
+		id, err := deserializeMessageID(rest.After)
+		return id, false, err
+	}
+	return pulsar.EarliestMessageID(), true, nil
+}
+
+// ProcessElement emits the payload of each message claimed from the
+// restriction. It returns when the restriction is exhausted or checkpointed,
+// or when the maximum read time elapses.
+func (fn *readFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, partition string, _ []byte, emit func(beam.EventTime, []byte)) error {
+	rest := rt.GetRestriction().(cursor.Restriction)
+	if rest.IsBounded() && rest.Limit == 0 {
+		rt.TryClaim(nil)
+		return nil
+	}
+	start, inclusive, err := fn.startID(rest)
+	if err != nil {
+		return errors.Wrapf(err, "pulsario: invalid cursor for %v", partition)
+	}
+	reader, err := fn.client.CreateReader(pulsar.ReaderOptions{
+		Topic:                   partition,
+		StartMessageID:          start,
+		StartMessageIDInclusive: inclusive,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "pulsario: failed to create reader for %v", partition)
+	}
+	defer reader.Close()
+
+	if fn.MaxReadTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fn.MaxReadTime)
+		defer cancel()
+	}
+	for {
+		msg, err := reader.Next(ctx)
+		if err == context.DeadlineExceeded {
+			// Finish claiming the restriction so the read is considered done.
+			rt.TryClaim(nil)
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "pulsario: failed to read from %v", partition)
+		}
+		if !rt.TryClaim(msg.ID().Serialize()) {
+			return nil
+		}
+		ts := msg.EventTime()
+		if ts.IsZero() {
+			ts = msg.PublishTime()
+		}
+		emit(mtime.FromTime(ts), msg.Payload())
+	}
+}
+
+// Teardown closes the Pulsar client.
+func (fn *readFn) Teardown() {
+	if fn.client != nil {
+		fn.client.Close()
+	}
+}
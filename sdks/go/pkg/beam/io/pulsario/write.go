@@ -0,0 +1,225 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pulsario
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*writeFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*writeKVFn)(nil)).Elem())
+}
+
+// Write produces the elements of the given PCollection to the topic on the
+// Pulsar service at url. The PCollection must be a PCollection<[]byte> of
+// message payloads, or a PCollection<KV<string,[]byte>> of message keys and
+// payloads. Keyed messages are routed to partitions by the hash of their key,
+// so all messages with the same key are written to the same partition in
+// order. Unkeyed messages are distributed across partitions round-robin.
+//
+// Messages are batched by the producer, and a bundle only succeeds once every
+// message it produced has been persisted by the broker.
+//
+// Example:
+//
+//	url := "pulsar://localhost:6650"
+//	pulsario.Write(s, url, "persistent://public/default/events", keyed,
+//		pulsario.BatchingMaxMessages(500), pulsario.BatchingMaxPublishDelay(50*time.Millisecond))
+func Write(s beam.Scope, url, topic string, col beam.PCollection, opts ...writeOption) {
+	s = s.Scope("pulsario.Write")
+
+	cfg := writeConfig{URL: url, Topic: topic}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	t := col.Type()
+	switch {
+	case typex.IsKV(t) && t.Components()[0].Type() == reflectx.String && t.Components()[1].Type() == reflectx.ByteSlice:
+		beam.ParDo0(s, &writeKVFn{writeFn{writeConfig: cfg}}, col)
+	case t.Type() == reflectx.ByteSlice:
+		beam.ParDo0(s, &writeFn{writeConfig: cfg}, col)
+	default:
+		panic(errors.Errorf("pulsario.Write requires a PCollection of []byte or KV<string,[]byte>, got %v", t))
+	}
+}
+
+type writeOption func(*writeConfig)
+
+// BatchingMaxMessages is a Write option that sets the maximum number of
+// messages in a batch.
+//
+// Default: the Pulsar client default of 1000
+func BatchingMaxMessages(n uint) writeOption {
+	return func(cfg *writeConfig) {
+		cfg.BatchingMaxMessages = n
+	}
+}
+
+// BatchingMaxSize is a Write option that sets the maximum number of bytes in
+// a batch.
+//
+// Default: the Pulsar client default of 128KB
+func BatchingMaxSize(n uint) writeOption {
+	return func(cfg *writeConfig) {
+		cfg.BatchingMaxSize = n
+	}
+}
+
+// BatchingMaxPublishDelay is a Write option that sets how long messages are
+// held to form a batch before being sent.
+//
+// Default: the Pulsar client default of 10ms
+func BatchingMaxPublishDelay(d time.Duration) writeOption {
+	return func(cfg *writeConfig) {
+		cfg.BatchingMaxPublishDelay = d
+	}
+}
+
+// DisableBatching is a Write option that sends each message individually.
+func DisableBatching() writeOption {
+	return func(cfg *writeConfig) {
+		cfg.DisableBatching = true
+	}
+}
+
+// Murmur3KeyHashing is a Write option that routes keyed messages with the
+// Murmur3 hash of their key, which matches the routing of the Pulsar C++ and
+// Python clients. By default keys are hashed with the Java String.hashCode
+// function, which matches the Pulsar Java client.
+func Murmur3KeyHashing() writeOption {
+	return func(cfg *writeConfig) {
+		cfg.Murmur3KeyHashing = true
+	}
+}
+
+// writeConfig holds the producer configuration shared by the write DoFns.
+type writeConfig struct {
+	URL                     string        `json:"url"`
+	Topic                   string        `json:"topic"`
+	BatchingMaxMessages     uint          `json:"batchingMaxMessages"`
+	BatchingMaxSize         uint          `json:"batchingMaxSize"`
+	BatchingMaxPublishDelay time.Duration `json:"batchingMaxPublishDelay"`
+	DisableBatching         bool          `json:"disableBatching"`
+	Murmur3KeyHashing       bool          `json:"murmur3KeyHashing"`
+}
+
+func (cfg writeConfig) producerOptions() pulsar.ProducerOptions {
+	opts := pulsar.ProducerOptions{
+		Topic:                   cfg.Topic,
+		BatchingMaxMessages:     cfg.BatchingMaxMessages,
+		BatchingMaxSize:         cfg.BatchingMaxSize,
+		BatchingMaxPublishDelay: cfg.BatchingMaxPublishDelay,
+		DisableBatching:         cfg.DisableBatching,
+	}
+	if cfg.Murmur3KeyHashing {
+		opts.HashingScheme = pulsar.Murmur3_32Hash
+	}
+	return opts
+}
+
+// writeFn produces unkeyed messages to a topic.
+type writeFn struct {
+	writeConfig
+
+	client   pulsar.Client
+	producer pulsar.Producer
+
+	mu     sync.Mutex
+	sent   int
+	failed int
+	err    error // First error reported by the producer in the bundle.
+}
+
+// Setup creates the Pulsar client and producer.
+func (fn *writeFn) Setup() error {
+	client, err := newClient(fn.URL)
+	if err != nil {
+		return errors.Wrap(err, "pulsario: failed to create client")
+	}
+	producer, err := client.CreateProducer(fn.producerOptions())
+	if err != nil {
+		client.Close()
+		return errors.Wrapf(err, "pulsario: failed to create producer for %v", fn.Topic)
+	}
+	fn.client, fn.producer = client, producer
+	return nil
+}
+
+func (fn *writeFn) ProcessElement(ctx context.Context, payload []byte) {
+	fn.send(ctx, &pulsar.ProducerMessage{Payload: payload})
+}
+
+// send asynchronously produces a message, recording any failure for the
+// bundle.
+func (fn *writeFn) send(ctx context.Context, msg *pulsar.ProducerMessage) {
+	fn.producer.SendAsync(ctx, msg, func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+		fn.mu.Lock()
+		defer fn.mu.Unlock()
+		fn.sent++
+		if err != nil {
+			fn.failed++
+			if fn.err == nil {
+				fn.err = err
+			}
+		}
+	})
+}
+
+// FinishBundle flushes the producer, and fails the bundle if any of its
+// messages were not persisted.
+func (fn *writeFn) FinishBundle() error {
+	if err := fn.producer.Flush(); err != nil {
+		return errors.Wrapf(err, "pulsario: failed to flush producer for %v", fn.Topic)
+	}
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	err, failed, sent := fn.err, fn.failed, fn.sent
+	fn.err, fn.failed, fn.sent = nil, 0, 0
+	if err != nil {
+		return errors.Wrapf(err, "pulsario: failed to produce %v of %v messages to %v", failed, sent, fn.Topic)
+	}
+	return nil
+}
+
+// Teardown closes the producer and client.
+func (fn *writeFn) Teardown() {
+	if fn.producer != nil {
+		fn.producer.Close()
+	}
+	if fn.client != nil {
+		fn.client.Close()
+	}
+}
+
+// writeKVFn produces keyed messages to a topic.
+type writeKVFn struct {
+	writeFn
+}
+
+func (fn *writeKVFn) ProcessElement(ctx context.Context, key string, payload []byte) {
+	fn.send(ctx, &pulsar.ProducerMessage{Key: key, Payload: payload})
+}
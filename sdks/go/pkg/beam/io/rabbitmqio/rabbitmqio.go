@@ -0,0 +1,70 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rabbitmqio contains transforms for consuming from and publishing to
+// RabbitMQ (https://www.rabbitmq.com/) using the AMQP 0-9-1 protocol.
+//
+// Read consumes messages from a queue with manual acknowledgements. Messages
+// are acknowledged by bundle finalization callbacks, once the runner has
+// committed the bundle that emitted them, so a failed bundle leaves its
+// messages unacknowledged and the broker redelivers them. Write publishes messages to an exchange with publisher confirms
+// enabled, and fails the bundle if the broker does not confirm every message.
+//
+// Experimental.
+package rabbitmqio
+
+import (
+	"github.com/streadway/amqp"
+)
+
+// channel is the subset of *amqp.Channel used by the transforms in this
+// package.
+type channel interface {
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Ack(tag uint64, multiple bool) error
+	Cancel(consumer string, noWait bool) error
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	NotifyReturn(c chan amqp.Return) chan amqp.Return
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+// connection is the subset of *amqp.Connection used by the transforms in
+// this package.
+type connection interface {
+	Channel() (channel, error)
+	Close() error
+}
+
+// dial opens a connection to the broker at the given AMQP URI. It is a
+// variable so tests can substitute an in-memory broker.
+var dial = func(uri string) (connection, error) {
+	conn, err := amqp.Dial(uri)
+	if err != nil {
+		return nil, err
+	}
+	return &amqpConnection{conn}, nil
+}
+
+// amqpConnection adapts *amqp.Connection to the connection interface.
+type amqpConnection struct {
+	*amqp.Connection
+}
+
+func (c *amqpConnection) Channel() (channel, error) {
+	return c.Connection.Channel()
+}
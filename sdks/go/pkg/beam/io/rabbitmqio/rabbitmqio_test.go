@@ -0,0 +1,342 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rabbitmqio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"github.com/streadway/amqp"
+)
+
+// fakeBroker is an in-memory broker with a single queue, which records
+// acknowledgements, closed channels and publishings.
+type fakeBroker struct {
+	mu         sync.Mutex
+	queue      [][]byte
+	acked      []uint64
+	closed     int
+	published  []amqp.Publishing
+	keys       []string
+	nack       bool
+	unroutable bool
+}
+
+func (b *fakeBroker) dial(string) (connection, error) {
+	return &fakeConnection{b: b}, nil
+}
+
+type fakeConnection struct {
+	b *fakeBroker
+}
+
+func (c *fakeConnection) Channel() (channel, error) {
+	return &fakeChannel{b: c.b}, nil
+}
+
+func (c *fakeConnection) Close() error {
+	return nil
+}
+
+// fakeChannel delivers the queue to a consumer no more than the prefetch
+// count of unacknowledged messages at a time.
+type fakeChannel struct {
+	b          *fakeBroker
+	prefetch   int
+	deliveries chan amqp.Delivery
+	sent       int
+	tag        uint64
+	confirms   chan amqp.Confirmation
+	returns    chan amqp.Return
+}
+
+func (c *fakeChannel) Qos(prefetchCount, _ int, _ bool) error {
+	c.prefetch = prefetchCount
+	return nil
+}
+
+func (c *fakeChannel) Consume(string, string, bool, bool, bool, bool, amqp.Table) (<-chan amqp.Delivery, error) {
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+	c.deliveries = make(chan amqp.Delivery, len(c.b.queue))
+	c.deliver(0)
+	return c.deliveries, nil
+}
+
+// deliver sends queued messages until the prefetch count of messages after
+// the acknowledged tag are unacknowledged.
+func (c *fakeChannel) deliver(acked uint64) {
+	for c.sent < len(c.b.queue) && (c.prefetch == 0 || c.sent-int(acked) < c.prefetch) {
+		c.deliveries <- amqp.Delivery{DeliveryTag: uint64(c.sent + 1), Body: c.b.queue[c.sent]}
+		c.sent++
+	}
+}
+
+func (c *fakeChannel) Ack(tag uint64, _ bool) error {
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+	c.b.acked = append(c.b.acked, tag)
+	c.deliver(tag)
+	return nil
+}
+
+func (c *fakeChannel) Cancel(string, bool) error {
+	return nil
+}
+
+func (c *fakeChannel) Confirm(bool) error {
+	return nil
+}
+
+func (c *fakeChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	c.confirms = confirm
+	return confirm
+}
+
+func (c *fakeChannel) NotifyReturn(r chan amqp.Return) chan amqp.Return {
+	c.returns = r
+	return r
+}
+
+func (c *fakeChannel) Publish(exchange, key string, mandatory, _ bool, msg amqp.Publishing) error {
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+	if c.b.unroutable {
+		if mandatory {
+			c.returns <- amqp.Return{ReplyCode: amqp.NoRoute, ReplyText: "NO_ROUTE", Exchange: exchange, RoutingKey: key}
+		}
+	} else {
+		c.b.published = append(c.b.published, msg)
+		c.b.keys = append(c.b.keys, key)
+	}
+	c.tag++
+	c.confirms <- amqp.Confirmation{DeliveryTag: c.tag, Ack: !c.b.nack}
+	return nil
+}
+
+func (c *fakeChannel) Close() error {
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+	c.b.closed++
+	return nil
+}
+
+// withFakeBroker substitutes the fake broker for dialing, and returns a
+// function restoring the original dialer.
+func withFakeBroker(b *fakeBroker) func() {
+	orig := dial
+	dial = b.dial
+	return func() { dial = orig }
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		prefetch int
+		want     []uint64
+	}{
+		{prefetch: 100, want: []uint64{3}},
+		// The prefetch count is raised to MaxNumRecords.
+		{prefetch: 2, want: []uint64{3}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(fmt.Sprintf("prefetch=%v", test.prefetch), func(t *testing.T) {
+			b := &fakeBroker{queue: [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}}
+			defer withFakeBroker(b)()
+
+			p, s := beam.NewPipelineWithRoot()
+			bodies := Read(s, "amqp://fake", "q", MaxNumRecords(3), PrefetchCount(test.prefetch))
+			passert.Equals(s, bodies, []byte("a"), []byte("b"), []byte("c"))
+			ptest.RunAndValidate(t, p)
+
+			if got, want := fmt.Sprint(b.acked), fmt.Sprint(test.want); got != want {
+				t.Errorf("acknowledged delivery tags = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRead_MaxReadTime(t *testing.T) {
+	b := &fakeBroker{queue: [][]byte{[]byte("a")}}
+	defer withFakeBroker(b)()
+
+	p, s := beam.NewPipelineWithRoot()
+	bodies := Read(s, "amqp://fake", "q", MaxReadTime(100*time.Millisecond))
+	passert.Equals(s, bodies, []byte("a"))
+	ptest.RunAndValidate(t, p)
+
+	if got, want := fmt.Sprint(b.acked), "[1]"; got != want {
+		t.Errorf("acknowledged delivery tags = %v, want %v", got, want)
+	}
+}
+
+// fakeFinalization records bundle finalization callbacks.
+type fakeFinalization struct {
+	callbacks []func() error
+}
+
+func (f *fakeFinalization) RegisterCallback(_ time.Duration, cb func() error) {
+	f.callbacks = append(f.callbacks, cb)
+}
+
+// TestReadFn_Finalization tests that messages are acknowledged, and the
+// bundle's channel closed, only once the bundle is finalized.
+func TestReadFn_Finalization(t *testing.T) {
+	b := &fakeBroker{queue: [][]byte{[]byte("a"), []byte("b"), []byte("c")}}
+	defer withFakeBroker(b)()
+
+	fn := &readFn{URI: "amqp://fake", Queue: "q", PrefetchCount: 10}
+	if err := fn.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	bf := &fakeFinalization{}
+	var bodies []string
+	emit := func(_ beam.EventTime, body []byte) { bodies = append(bodies, string(body)) }
+	rt := fn.CreateTracker(offsetrange.Restriction{Start: 0, End: 2})
+	if err := fn.ProcessElement(context.Background(), bf, rt, nil, emit); err != nil {
+		t.Fatalf("ProcessElement failed: %v", err)
+	}
+	if err := fn.FinishBundle(context.Background(), bf, emit); err != nil {
+		t.Fatalf("FinishBundle failed: %v", err)
+	}
+	if got, want := fmt.Sprint(bodies), "[a b]"; got != want {
+		t.Errorf("emitted %v, want %v", got, want)
+	}
+	if len(b.acked) != 0 || b.closed != 0 {
+		t.Fatalf("acknowledged %v and closed %v channels before finalization, want none", b.acked, b.closed)
+	}
+
+	for _, cb := range bf.callbacks {
+		if err := cb(); err != nil {
+			t.Fatalf("finalization callback failed: %v", err)
+		}
+	}
+	if got, want := fmt.Sprint(b.acked), "[2]"; got != want {
+		t.Errorf("acknowledged delivery tags = %v, want %v", got, want)
+	}
+	if got, want := b.closed, 1; got != want {
+		t.Errorf("closed %v channels, want %v", got, want)
+	}
+	if err := fn.Teardown(); err != nil {
+		t.Fatalf("Teardown failed: %v", err)
+	}
+	if got, want := b.closed, 1; got != want {
+		t.Errorf("closed %v channels after Teardown, want %v", got, want)
+	}
+}
+
+// TestRead_Failure tests that messages of a failed bundle are not
+// acknowledged.
+func TestRead_Failure(t *testing.T) {
+	b := &fakeBroker{queue: [][]byte{[]byte("a"), []byte("b")}}
+	defer withFakeBroker(b)()
+
+	p, s := beam.NewPipelineWithRoot()
+	bodies := Read(s, "amqp://fake", "q", MaxNumRecords(2))
+	beam.ParDo0(s, func([]byte) error { return errors.New("downstream failure") }, bodies)
+	if err := ptest.Run(p); err == nil {
+		t.Fatal("pipeline succeeded, want downstream failure")
+	}
+	if len(b.acked) != 0 {
+		t.Errorf("acknowledged delivery tags %v of a failed bundle, want none", b.acked)
+	}
+	if got, want := b.closed, 1; got != want {
+		t.Errorf("closed %v channels, want %v", got, want)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	b := &fakeBroker{}
+	defer withFakeBroker(b)()
+
+	p, s := beam.NewPipelineWithRoot()
+	bodies := beam.Create(s, []byte("a"), []byte("b"), []byte("c"))
+	Write(s, "amqp://fake", "x", bodies, RoutingKey("rk"), MaxInFlight(2), ContentType("text/plain"))
+	ptest.RunAndValidate(t, p)
+
+	if got, want := len(b.published), 3; got != want {
+		t.Fatalf("published %v messages, want %v", got, want)
+	}
+	for i, msg := range b.published {
+		if got, want := b.keys[i], "rk"; got != want {
+			t.Errorf("message %v routing key = %v, want %v", i, got, want)
+		}
+		if got, want := msg.ContentType, "text/plain"; got != want {
+			t.Errorf("message %v content type = %v, want %v", i, got, want)
+		}
+		if got, want := msg.DeliveryMode, amqp.Persistent; got != want {
+			t.Errorf("message %v delivery mode = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestWrite_Nack(t *testing.T) {
+	b := &fakeBroker{nack: true}
+	defer withFakeBroker(b)()
+
+	p, s := beam.NewPipelineWithRoot()
+	bodies := beam.Create(s, []byte("a"))
+	Write(s, "amqp://fake", "x", bodies)
+	if err := ptest.Run(p); err == nil {
+		t.Error("pipeline succeeded, want error for rejected message")
+	}
+}
+
+func TestWrite_Unroutable(t *testing.T) {
+	tests := []struct {
+		mandatory bool
+		fail      bool
+	}{
+		{mandatory: false, fail: false},
+		{mandatory: true, fail: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(fmt.Sprintf("mandatory=%v", test.mandatory), func(t *testing.T) {
+			b := &fakeBroker{unroutable: true}
+			defer withFakeBroker(b)()
+
+			var opts []writeOption
+			if test.mandatory {
+				opts = append(opts, Mandatory())
+			}
+			p, s := beam.NewPipelineWithRoot()
+			bodies := beam.Create(s, []byte("a"), []byte("b"))
+			Write(s, "amqp://fake", "x", bodies, opts...)
+			if err := ptest.Run(p); (err != nil) != test.fail {
+				t.Errorf("pipeline error = %v, want failure %v for unroutable messages", err, test.fail)
+			}
+		})
+	}
+}
+
+func TestWrite_BadType(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Write of PCollection<int> succeeded, want panic")
+		}
+	}()
+	_, s := beam.NewPipelineWithRoot()
+	Write(s, "amqp://fake", "x", beam.Create(s, 1, 2, 3))
+}
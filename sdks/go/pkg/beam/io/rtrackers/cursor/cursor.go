@@ -0,0 +1,159 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cursor defines a restriction and restriction tracker for reading a
+// stream of messages after a cursor, such as a message ID or a resume token,
+// which can only be compared by the service serving the stream. As cursors
+// can't be split between, the tracker splits by checkpointing: the primary
+// restriction is reduced to the messages claimed so far, and the residual
+// restriction begins after the last claimed message.
+package cursor
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+)
+
+func init() {
+	runtime.RegisterType(reflect.TypeOf((*Tracker)(nil)))
+	runtime.RegisterType(reflect.TypeOf((*Restriction)(nil)).Elem())
+	runtime.RegisterFunction(restEnc)
+	runtime.RegisterFunction(restDec)
+	coder.RegisterCoder(reflect.TypeOf((*Restriction)(nil)).Elem(), restEnc, restDec)
+}
+
+func restEnc(in Restriction) ([]byte, error) {
+	return json.Marshal(in)
+}
+
+func restDec(in []byte) (Restriction, error) {
+	var rest Restriction
+	err := json.Unmarshal(in, &rest)
+	return rest, err
+}
+
+// Restriction is a cursor into a stream, representing up to Limit messages
+// following the message with the cursor After. An empty After begins at the
+// start of the stream, and a negative Limit represents all messages that will
+// ever be sent.
+type Restriction struct {
+	After []byte
+	Limit int64
+}
+
+// IsBounded returns whether the restriction covers a finite number of
+// messages.
+func (r Restriction) IsBounded() bool {
+	return r.Limit >= 0
+}
+
+// Tracker tracks a cursor restriction by counting the messages claimed from
+// it and recording the cursor of the last claimed message.
+type Tracker struct {
+	rest    Restriction
+	claimed int64  // Number of messages claimed.
+	last    []byte // Cursor of the last claimed message.
+	stopped bool   // Tracks whether TryClaim has indicated to stop processing.
+	err     error
+}
+
+// NewTracker is a constructor for a Tracker given a cursor restriction.
+func NewTracker(rest Restriction) *Tracker {
+	return &Tracker{rest: rest}
+}
+
+// TryClaim accepts the []byte cursor of the next message in the stream, and
+// successfully claims it if the restriction has not reached its limit.
+// Messages without a cursor are claimed with an empty cursor, and keep the
+// cursor of the last message that had one. A nil position claims the
+// remainder of the restriction, which signals that processing is done without
+// claiming any further messages.
+func (t *Tracker) TryClaim(pos interface{}) bool {
+	if t.stopped {
+		t.err = errors.New("cannot claim work after restriction tracker returns false")
+		return false
+	}
+	if pos == nil {
+		t.rest.Limit = t.claimed
+		t.stopped = true
+		return false
+	}
+	cursor, ok := pos.([]byte)
+	if !ok {
+		t.stopped = true
+		t.err = errors.New("position claimed must be a []byte cursor")
+		return false
+	}
+	if t.rest.IsBounded() && t.claimed >= t.rest.Limit {
+		t.stopped = true
+		return false
+	}
+	t.claimed++
+	if len(cursor) > 0 {
+		t.last = cursor
+	}
+	return true
+}
+
+// GetError returns the error that caused the tracker to stop, if there is one.
+func (t *Tracker) GetError() error {
+	return t.err
+}
+
+// TrySplit checkpoints the restriction after the last claimed message,
+// regardless of the fraction given. The residual restriction is nil if no
+// messages remain in a bounded restriction.
+func (t *Tracker) TrySplit(_ float64) (primary, residual interface{}, err error) {
+	if t.stopped || t.IsDone() {
+		return t.rest, nil, nil
+	}
+	res := Restriction{After: t.rest.After, Limit: -1}
+	if t.last != nil {
+		res.After = t.last
+	}
+	if t.rest.IsBounded() {
+		res.Limit = t.rest.Limit - t.claimed
+	}
+	t.rest.Limit = t.claimed
+	return t.rest, res, nil
+}
+
+// GetProgress reports the number of claimed messages as done. For bounded
+// restrictions the unclaimed messages are remaining, and unbounded
+// restrictions always report one message remaining.
+func (t *Tracker) GetProgress() (done, remaining float64) {
+	done = float64(t.claimed)
+	if t.rest.IsBounded() {
+		remaining = float64(t.rest.Limit - t.claimed)
+	} else {
+		remaining = 1
+	}
+	return
+}
+
+// IsDone returns true if the restriction is bounded and all of its messages
+// have been claimed.
+func (t *Tracker) IsDone() bool {
+	return t.err == nil && t.rest.IsBounded() && t.claimed >= t.rest.Limit
+}
+
+// GetRestriction returns a copy of the tracker's underlying Restriction.
+func (t *Tracker) GetRestriction() interface{} {
+	return t.rest
+}
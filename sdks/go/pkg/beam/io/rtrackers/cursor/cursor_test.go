@@ -0,0 +1,142 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cursor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func cursor(i int) []byte {
+	return []byte{byte(i)}
+}
+
+// TestTracker_TryClaim tests that claims succeed until the restriction's limit
+// is reached.
+func TestTracker_TryClaim(t *testing.T) {
+	tracker := NewTracker(Restriction{Limit: 2})
+	for i := 0; i < 2; i++ {
+		if !tracker.TryClaim(cursor(i)) {
+			t.Fatalf("TryClaim(%v) failed, want success", i)
+		}
+	}
+	if tracker.TryClaim(cursor(2)) {
+		t.Errorf("TryClaim(2) succeeded past limit, want failure")
+	}
+	if !tracker.IsDone() {
+		t.Errorf("tracker is not done after claiming the limit")
+	}
+	if err := tracker.GetError(); err != nil {
+		t.Errorf("tracker failed: %v", err)
+	}
+}
+
+// TestTracker_TryClaimNil tests that claiming nil ends an unbounded
+// restriction.
+func TestTracker_TryClaimNil(t *testing.T) {
+	tracker := NewTracker(Restriction{Limit: -1})
+	tracker.TryClaim(cursor(0))
+	if tracker.TryClaim(nil) {
+		t.Errorf("TryClaim(nil) succeeded, want failure")
+	}
+	if !tracker.IsDone() {
+		t.Errorf("tracker is not done after claiming nil")
+	}
+	if got, want := tracker.GetRestriction(), (Restriction{Limit: 1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("restriction after claiming nil = %v, want %v", got, want)
+	}
+}
+
+// TestTracker_TrySplit tests that splits checkpoint the restriction after the
+// last claimed message with a cursor.
+func TestTracker_TrySplit(t *testing.T) {
+	tests := []struct {
+		name         string
+		rest         Restriction
+		claims       [][]byte
+		wantPrimary  Restriction
+		wantResidual Restriction
+	}{
+		{
+			name:         "unbounded",
+			rest:         Restriction{Limit: -1},
+			claims:       [][]byte{cursor(0), cursor(1), cursor(2)},
+			wantPrimary:  Restriction{Limit: 3},
+			wantResidual: Restriction{After: cursor(2), Limit: -1},
+		},
+		{
+			name:         "bounded",
+			rest:         Restriction{After: cursor(0), Limit: 5},
+			claims:       [][]byte{cursor(1), cursor(2)},
+			wantPrimary:  Restriction{After: cursor(0), Limit: 2},
+			wantResidual: Restriction{After: cursor(2), Limit: 3},
+		},
+		{
+			name:         "uncursored",
+			rest:         Restriction{Limit: -1},
+			claims:       [][]byte{cursor(0), {}},
+			wantPrimary:  Restriction{Limit: 2},
+			wantResidual: Restriction{After: cursor(0), Limit: -1},
+		},
+		{
+			name:         "unclaimed",
+			rest:         Restriction{After: cursor(4), Limit: -1},
+			wantPrimary:  Restriction{After: cursor(4), Limit: 0},
+			wantResidual: Restriction{After: cursor(4), Limit: -1},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			tracker := NewTracker(test.rest)
+			for _, c := range test.claims {
+				tracker.TryClaim(c)
+			}
+			p, r, err := tracker.TrySplit(0.5)
+			if err != nil {
+				t.Fatalf("TrySplit failed: %v", err)
+			}
+			if !reflect.DeepEqual(p, test.wantPrimary) {
+				t.Errorf("primary = %v, want %v", p, test.wantPrimary)
+			}
+			if !reflect.DeepEqual(r, test.wantResidual) {
+				t.Errorf("residual = %v, want %v", r, test.wantResidual)
+			}
+			if tracker.TryClaim(cursor(9)) {
+				t.Errorf("TryClaim succeeded after split, want failure")
+			}
+			if !tracker.IsDone() {
+				t.Errorf("tracker is not done after split")
+			}
+		})
+	}
+}
+
+// TestRestrictionCoder tests that restrictions survive encoding.
+func TestRestrictionCoder(t *testing.T) {
+	want := Restriction{After: []byte{1, 2, 3}, Limit: 7}
+	enc, err := restEnc(want)
+	if err != nil {
+		t.Fatalf("restEnc(%v) failed: %v", want, err)
+	}
+	got, err := restDec(enc)
+	if err != nil {
+		t.Fatalf("restDec failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("restDec(restEnc(%v)) = %v", want, got)
+	}
+}
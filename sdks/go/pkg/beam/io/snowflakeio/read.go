@@ -0,0 +1,231 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snowflakeio
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*partition)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*partitionFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*readFn)(nil)).Elem())
+}
+
+// rowColumn is the column numbering the rows of the result, which the result
+// is partitioned by.
+const rowColumn = "BEAM_SNOWFLAKEIO_ROW"
+
+// defaultPartitionSize is the number of rows in each partition of a result.
+const defaultPartitionSize = 100000
+
+// Read reads all rows from the given table, and returns a PCollection<t> of
+// the rows mapped to the struct type t. Columns without a matching field are
+// ignored.
+//
+// The table is queried once, and the persisted result of the query is split
+// into partitions of consecutive rows. The partitions are reshuffled, and
+// each is read with RESULT_SCAN by the worker it lands on, so large results
+// are read in parallel. Query results persist for 24 hours, which bounds how
+// long a read can take.
+//
+// Example:
+//
+//	dsn := "user:password@account/db/public?warehouse=wh"
+//	orders := snowflakeio.Read(s, dsn, "orders", reflect.TypeOf(Order{}))
+func Read(s beam.Scope, dsn, table string, t reflect.Type, opts ...readOption) beam.PCollection {
+	s = s.Scope("snowflakeio.Read")
+	return query(s, dsn, fmt.Sprintf("SELECT * FROM %v", table), t, opts)
+}
+
+// Query executes a query, and returns a PCollection<t> of the result rows
+// mapped to the struct type t, in the same manner as Read.
+func Query(s beam.Scope, dsn, q string, t reflect.Type, opts ...readOption) beam.PCollection {
+	s = s.Scope("snowflakeio.Query")
+	return query(s, dsn, q, t, opts)
+}
+
+func query(s beam.Scope, dsn, q string, t reflect.Type, opts []readOption) beam.PCollection {
+	if _, err := fields(t); err != nil {
+		panic(err)
+	}
+	pfn := &partitionFn{DSN: dsn, Query: q, PartitionSize: defaultPartitionSize}
+	for _, opt := range opts {
+		opt(pfn)
+	}
+	if pfn.PartitionSize < 1 {
+		panic(errors.Errorf("snowflakeio: partition size must be positive, got %v", pfn.PartitionSize))
+	}
+	imp := beam.Impulse(s)
+	partitions := beam.ParDo(s, pfn, imp)
+	return beam.ParDo(s, &readFn{DSN: dsn, Type: beam.EncodedType{T: t}}, beam.Reshuffle(s, partitions), beam.TypeDefinition{Var: beam.XType, T: t})
+}
+
+type readOption func(*partitionFn)
+
+// PartitionSize is a Read option that sets the number of rows of the result
+// read by each partition.
+//
+// Default: 100000
+func PartitionSize(n int64) readOption {
+	return func(fn *partitionFn) {
+		fn.PartitionSize = n
+	}
+}
+
+// partition is a range of rows of a persisted query result.
+type partition struct {
+	QueryID string
+	// First and Last are the inclusive bounds of the row numbers.
+	First, Last int64
+}
+
+// partitionFn executes a query, numbering its rows, and emits partitions of
+// its result.
+type partitionFn struct {
+	DSN           string `json:"dsn"`
+	Query         string `json:"query"`
+	PartitionSize int64  `json:"partitionSize"`
+
+	db *sql.DB
+}
+
+// Setup opens the database.
+func (fn *partitionFn) Setup() error {
+	db, err := sql.Open(driverName, fn.DSN)
+	if err != nil {
+		return errors.Wrap(err, "snowflakeio: failed to open database")
+	}
+	fn.db = db
+	return nil
+}
+
+func (fn *partitionFn) ProcessElement(ctx context.Context, _ []byte, emit func(partition)) error {
+	// LAST_QUERY_ID is scoped to the session, so the query and the lookup of
+	// its result run on the same connection.
+	conn, err := fn.db.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "snowflakeio: failed to connect")
+	}
+	defer conn.Close()
+	q := fmt.Sprintf("SELECT q.*, SEQ8() AS %v FROM (%v) q", rowColumn, fn.Query)
+	if _, err := conn.ExecContext(ctx, q); err != nil {
+		return errors.Wrapf(err, "snowflakeio: failed to run query: %v", fn.Query)
+	}
+	var id string
+	var first, last sql.NullInt64
+	bounds := fmt.Sprintf("SELECT LAST_QUERY_ID(), MIN(%v), MAX(%[1]v) FROM TABLE(RESULT_SCAN(LAST_QUERY_ID()))", rowColumn)
+	if err := conn.QueryRowContext(ctx, bounds).Scan(&id, &first, &last); err != nil {
+		return errors.Wrapf(err, "snowflakeio: failed to read result of query: %v", fn.Query)
+	}
+	if !first.Valid {
+		log.Infof(ctx, "snowflakeio: query %v returned no rows", id)
+		return nil
+	}
+	// Row numbers may have gaps, so partitions can have fewer rows.
+	var n int
+	for i := first.Int64; i <= last.Int64; i += fn.PartitionSize {
+		end := i + fn.PartitionSize - 1
+		if end > last.Int64 {
+			end = last.Int64
+		}
+		emit(partition{QueryID: id, First: i, Last: end})
+		n++
+	}
+	log.Infof(ctx, "snowflakeio: split result of query %v into %v partitions", id, n)
+	return nil
+}
+
+// Teardown closes the database.
+func (fn *partitionFn) Teardown() {
+	if fn.db != nil {
+		fn.db.Close()
+	}
+}
+
+// readFn emits the rows of partitions of query results.
+type readFn struct {
+	DSN string `json:"dsn"`
+	// Type is the encoded row type.
+	Type beam.EncodedType `json:"type"`
+
+	db *sql.DB
+}
+
+// Setup opens the database.
+func (fn *readFn) Setup() error {
+	db, err := sql.Open(driverName, fn.DSN)
+	if err != nil {
+		return errors.Wrap(err, "snowflakeio: failed to open database")
+	}
+	fn.db = db
+	return nil
+}
+
+func (fn *readFn) ProcessElement(ctx context.Context, p partition, emit func(beam.X)) error {
+	q := fmt.Sprintf("SELECT * FROM TABLE(RESULT_SCAN(%v)) WHERE %v BETWEEN %v AND %v", quoteString(p.QueryID), rowColumn, p.First, p.Last)
+	rows, err := fn.db.QueryContext(ctx, q)
+	if err != nil {
+		return errors.Wrapf(err, "snowflakeio: failed to read rows %v to %v of query %v", p.First, p.Last, p.QueryID)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return errors.Wrap(err, "snowflakeio: failed to read result columns")
+	}
+	indices, err := mapColumns(columns, fn.Type.T)
+	if err != nil {
+		return err
+	}
+
+	var discard interface{}
+	var n int
+	for rows.Next() {
+		row := reflect.New(fn.Type.T).Elem()
+		dests := make([]interface{}, len(indices))
+		for i, index := range indices {
+			if index < 0 {
+				dests[i] = &discard
+			} else {
+				dests[i] = row.Field(index).Addr().Interface()
+			}
+		}
+		if err := rows.Scan(dests...); err != nil {
+			return errors.Wrapf(err, "snowflakeio: failed to scan row into %v", fn.Type.T)
+		}
+		emit(row.Interface())
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "snowflakeio: failed to read rows")
+	}
+	log.Debugf(ctx, "snowflakeio: read %v rows of query %v", n, p.QueryID)
+	return nil
+}
+
+// Teardown closes the database.
+func (fn *readFn) Teardown() {
+	if fn.db != nil {
+		fn.db.Close()
+	}
+}
@@ -0,0 +1,116 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snowflakeio contains transforms for reading from and bulk loading
+// into Snowflake tables with the Go Snowflake driver.
+//
+// Rows are mapped to and from Go structs. Each exported field is mapped to the
+// column named by its `column` struct tag, or by the field name if it has no
+// tag. Fields tagged `column:"-"` are ignored. As unquoted Snowflake
+// identifiers are case-insensitive, so is the mapping.
+//
+// The dsn is a gosnowflake connection string, such as
+// "user:password@account/database/schema?warehouse=wh".
+//
+// Experimental.
+package snowflakeio
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	_ "github.com/snowflakedb/gosnowflake" // Registers the snowflake driver.
+)
+
+// driverName is the database/sql driver used to connect to Snowflake. It is a
+// variable so tests can substitute a fake driver.
+var driverName = "snowflake"
+
+// field is a struct field mapped to a column.
+type field struct {
+	column string
+	index  int
+}
+
+// fields returns the mapped fields of the struct type t, in declaration order.
+func fields(t reflect.Type) ([]field, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("snowflakeio: rows must be structs, got %v", t)
+	}
+	var fs []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Tag.Get("column")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs = append(fs, field{column: name, index: i})
+	}
+	if len(fs) == 0 {
+		return nil, errors.Errorf("snowflakeio: %v has no exported fields", t)
+	}
+	return fs, nil
+}
+
+// normalize returns the form of a column name used to match it to a field.
+func normalize(name string) string {
+	return strings.Replace(strings.ToUpper(name), "_", "", -1)
+}
+
+// mapColumns returns the index of the struct field of t mapped to each of the
+// given result columns, or -1 for columns that are not mapped.
+func mapColumns(columns []string, t reflect.Type) ([]int, error) {
+	fs, err := fields(t)
+	if err != nil {
+		return nil, err
+	}
+	exact := make(map[string]int)
+	loose := make(map[string]int)
+	for _, f := range fs {
+		exact[strings.ToUpper(f.column)] = f.index
+		loose[normalize(f.column)] = f.index
+	}
+	indices := make([]int, len(columns))
+	var mapped int
+	for i, c := range columns {
+		index, ok := exact[strings.ToUpper(c)]
+		if !ok {
+			index, ok = loose[normalize(c)]
+		}
+		if !ok {
+			index = -1
+		} else {
+			mapped++
+		}
+		indices[i] = index
+	}
+	if mapped == 0 {
+		return nil, errors.Errorf("snowflakeio: no columns of %v match fields of %v", columns, t)
+	}
+	return indices, nil
+}
+
+// quoteString quotes a SQL string literal.
+func quoteString(s string) string {
+	return fmt.Sprintf("'%v'", strings.Replace(s, "'", "''", -1))
+}
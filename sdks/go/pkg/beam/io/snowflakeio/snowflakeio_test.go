@@ -0,0 +1,266 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snowflakeio
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+type order struct {
+	ID       int64 `column:"ORDER_ID"`
+	Customer string
+	Total    *float64
+	Internal string `column:"-"`
+}
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*order)(nil)).Elem())
+	sql.Register("snowflakeio-fake", &fakeDriver{})
+}
+
+// fakeWarehouse is a Snowflake account, which serves query results and
+// records the statements it executes and the contents of uploaded files.
+type fakeWarehouse struct {
+	columns []string
+	rows    [][]driver.Value
+
+	mu         sync.Mutex
+	statements []string
+	uploads    []string
+}
+
+var warehouse *fakeWarehouse
+
+// withFakeWarehouse substitutes the fake warehouse for the Snowflake driver,
+// and returns a function restoring the original.
+func withFakeWarehouse(w *fakeWarehouse) func() {
+	orig := driverName
+	driverName, warehouse = "snowflakeio-fake", w
+	return func() { driverName, warehouse = orig, nil }
+}
+
+type fakeDriver struct{}
+
+func (*fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (*fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{query}, nil }
+func (*fakeConn) Close() error                              { return nil }
+func (*fakeConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+type fakeStmt struct {
+	query string
+}
+
+func (*fakeStmt) Close() error  { return nil }
+func (*fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	warehouse.mu.Lock()
+	defer warehouse.mu.Unlock()
+	warehouse.statements = append(warehouse.statements, s.query)
+	return driver.RowsAffected(0), nil
+}
+
+var putFile = regexp.MustCompile(`^PUT 'file://([^']+)'`)
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	warehouse.mu.Lock()
+	defer warehouse.mu.Unlock()
+	warehouse.statements = append(warehouse.statements, s.query)
+	if m := putFile.FindStringSubmatch(s.query); m != nil {
+		f, err := os.Open(m[1])
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(gz)
+		if err != nil {
+			return nil, err
+		}
+		warehouse.uploads = append(warehouse.uploads, string(b))
+		return &fakeRows{}, nil
+	}
+	if strings.Contains(s.query, "LAST_QUERY_ID()") {
+		if len(warehouse.rows) == 0 {
+			return &fakeRows{columns: []string{"ID", "FIRST", "LAST"}, rows: [][]driver.Value{{fakeQueryID, nil, nil}}}, nil
+		}
+		return &fakeRows{columns: []string{"ID", "FIRST", "LAST"}, rows: [][]driver.Value{{fakeQueryID, int64(0), int64(len(warehouse.rows) - 1)}}}, nil
+	}
+	if m := resultScan.FindStringSubmatch(s.query); m != nil {
+		first, _ := strconv.Atoi(m[1])
+		last, _ := strconv.Atoi(m[2])
+		rows := &fakeRows{columns: append(append([]string(nil), warehouse.columns...), rowColumn)}
+		for i := first; i <= last && i < len(warehouse.rows); i++ {
+			rows.rows = append(rows.rows, append(append([]driver.Value(nil), warehouse.rows[i]...), int64(i)))
+		}
+		return rows, nil
+	}
+	return &fakeRows{}, nil
+}
+
+const fakeQueryID = "01-fake"
+
+// resultScan matches reads of partitions of the fake query result, where row
+// numbers are the indices of the rows.
+var resultScan = regexp.MustCompile(`^SELECT \* FROM TABLE\(RESULT_SCAN\('` + fakeQueryID + `'\)\) WHERE ` + rowColumn + ` BETWEEN (\d+) AND (\d+)$`)
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestRead(t *testing.T) {
+	w := &fakeWarehouse{
+		columns: []string{"ORDER_ID", "CUSTOMER", "TOTAL", "REGION"},
+		rows: [][]driver.Value{
+			{int64(1), "alice", 9.5, "us"},
+			{int64(2), "bob", nil, "eu"},
+		},
+	}
+	defer withFakeWarehouse(w)()
+
+	p, s := beam.NewPipelineWithRoot()
+	orders := Read(s, "fake", "orders", reflect.TypeOf(order{}))
+	total := 9.5
+	passert.Equals(s, orders, order{ID: 1, Customer: "alice", Total: &total}, order{ID: 2, Customer: "bob"})
+	ptest.RunAndValidate(t, p)
+
+	if got, want := w.statements[0], "SELECT q.*, SEQ8() AS "+rowColumn+" FROM (SELECT * FROM orders) q"; got != want {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+}
+
+// TestRead_Partitions tests that results are read in partitions of the
+// partition size.
+func TestRead_Partitions(t *testing.T) {
+	w := &fakeWarehouse{
+		columns: []string{"ORDER_ID", "CUSTOMER"},
+		rows: [][]driver.Value{
+			{int64(1), "alice"},
+			{int64(2), "bob"},
+			{int64(3), "carol"},
+		},
+	}
+	defer withFakeWarehouse(w)()
+
+	p, s := beam.NewPipelineWithRoot()
+	orders := Query(s, "fake", "SELECT ORDER_ID, CUSTOMER FROM orders", reflect.TypeOf(order{}), PartitionSize(2))
+	passert.Equals(s, orders, order{ID: 1, Customer: "alice"}, order{ID: 2, Customer: "bob"}, order{ID: 3, Customer: "carol"})
+	ptest.RunAndValidate(t, p)
+
+	var reads []string
+	for _, stmt := range w.statements {
+		if resultScan.MatchString(stmt) {
+			reads = append(reads, stmt[strings.Index(stmt, "BETWEEN"):])
+		}
+	}
+	sort.Strings(reads)
+	if got, want := reads, []string{"BETWEEN 0 AND 1", "BETWEEN 2 AND 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read partitions %v, want %v", got, want)
+	}
+}
+
+func TestRead_Empty(t *testing.T) {
+	w := &fakeWarehouse{columns: []string{"ORDER_ID"}}
+	defer withFakeWarehouse(w)()
+
+	p, s := beam.NewPipelineWithRoot()
+	orders := Read(s, "fake", "orders", reflect.TypeOf(order{}))
+	passert.Empty(s, orders)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestWrite(t *testing.T) {
+	w := &fakeWarehouse{}
+	defer withFakeWarehouse(w)()
+
+	p, s := beam.NewPipelineWithRoot()
+	total := 1.5
+	Write(s, "fake", "orders", beam.Create(s, order{ID: 1, Customer: `a "quote"`, Total: &total}, order{ID: 2}))
+	ptest.RunAndValidate(t, p)
+
+	if got, want := w.uploads, []string{"1,\"a \"\"quote\"\"\",1.5\n2,\"\",\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("uploaded %q, want %q", got, want)
+	}
+	if len(w.statements) != 2 {
+		t.Fatalf("executed %v, want PUT and COPY statements", w.statements)
+	}
+	put, cp := w.statements[0], w.statements[1]
+	stage := regexp.MustCompile(` @%orders/beam/[0-9a-f-]+/ `).FindString(put)
+	if stage == "" {
+		t.Errorf("PUT %v is not to the table stage", put)
+	}
+	if !strings.HasPrefix(cp, "COPY INTO orders (ORDER_ID, Customer, Total) FROM"+stage) || !strings.HasSuffix(cp, "PURGE = TRUE") {
+		t.Errorf("COPY = %v, want load from %v with purge", cp, stage)
+	}
+}
+
+func TestAppendRow(t *testing.T) {
+	type row struct {
+		S  string
+		B  bool
+		U  uint8
+		F  float32
+		T  time.Time
+		Bs []byte
+		M  map[string]int
+		P  *string
+	}
+	in := row{S: "x,y", B: true, U: 7, F: 0.25, T: time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC), Bs: []byte{0xab}, M: map[string]int{"k": 1}}
+	got, err := appendRow(nil, reflect.ValueOf(in))
+	if err != nil {
+		t.Fatalf("appendRow failed: %v", err)
+	}
+	want := `"x,y",true,7,0.25,"2021-03-04T05:06:07.000000008Z","ab","{""k"":1}",` + "\n"
+	if string(got) != want {
+		t.Errorf("appendRow = %q, want %q", got, want)
+	}
+}
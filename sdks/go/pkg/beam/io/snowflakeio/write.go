@@ -0,0 +1,353 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snowflakeio
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	"github.com/google/uuid"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*stageFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*copyFn)(nil)).Elem())
+}
+
+// copyFileLimit is the maximum number of files loaded by one COPY statement.
+const copyFileLimit = 1000
+
+// Write loads the elements of the given PCollection<t> into the table, where
+// t is a struct type mapped to the columns of the table.
+//
+// Each bundle of rows is written to a gzipped CSV file on a stage, and once
+// every bundle is staged the files are loaded with COPY INTO. By default
+// files are uploaded with PUT to the table's internal stage, and purged once
+// loaded. Snowflake records which files have been loaded into a table, so
+// retried loads do not duplicate rows.
+//
+// Example:
+//
+//	dsn := "user:password@account/db/public?warehouse=wh"
+//	snowflakeio.Write(s, dsn, "orders", orders,
+//		snowflakeio.ExternalStage("orders_stage", "gs://my-bucket/snowflake/orders"))
+func Write(s beam.Scope, dsn, table string, col beam.PCollection, opts ...writeOption) {
+	s = s.Scope("snowflakeio.Write")
+
+	fs, err := fields(col.Type().Type())
+	if err != nil {
+		panic(err)
+	}
+	columns := make([]string, len(fs))
+	for i, f := range fs {
+		columns[i] = f.column
+	}
+	cfg := stageConfig{
+		DSN:    dsn,
+		Stage:  "@%" + table,
+		Prefix: "beam/" + uuid.New().String(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if !strings.HasPrefix(cfg.Stage, "@") {
+		cfg.Stage = "@" + cfg.Stage
+	}
+
+	files := beam.ParDo(s, &stageFn{stageConfig: cfg}, col)
+	grouped := beam.GroupByKey(s, beam.AddFixedKey(s, files))
+	beam.ParDo0(s, &copyFn{stageConfig: cfg, Table: table, Columns: columns}, grouped)
+}
+
+type writeOption func(*stageConfig)
+
+// Stage is a Write option that stages files in the given named internal
+// stage, rather than in the table's stage.
+func Stage(name string) writeOption {
+	return func(cfg *stageConfig) {
+		cfg.Stage = name
+		cfg.Location = ""
+	}
+}
+
+// ExternalStage is a Write option that stages files in the given external
+// stage. The location is the path of the stage URL in a registered Beam file
+// system, such as "gs://bucket/path" for a stage with the URL
+// "gcs://bucket/path". Files are written directly to the location by the
+// workers, and are not purged after they are loaded.
+func ExternalStage(name, location string) writeOption {
+	return func(cfg *stageConfig) {
+		cfg.Stage = name
+		cfg.Location = strings.TrimSuffix(location, "/")
+	}
+}
+
+// stageConfig holds the configuration shared by the write DoFns.
+type stageConfig struct {
+	DSN string `json:"dsn"`
+	// Stage is the stage files are loaded from.
+	Stage string `json:"stage"`
+	// Location is the file system path of an external stage, or empty for an
+	// internal stage.
+	Location string `json:"location"`
+	// Prefix is the path within the stage of the files written by the
+	// pipeline.
+	Prefix string `json:"prefix"`
+}
+
+// stageFn writes each bundle of rows to a file on the stage, and emits the
+// name of the file.
+type stageFn struct {
+	stageConfig
+
+	db   *sql.DB
+	name string         // Name of the current file, relative to the prefix.
+	path string         // Local path of the current file, for internal stages.
+	file io.WriteCloser // Current file.
+	gz   *gzip.Writer
+	buf  []byte
+	rows int
+}
+
+// Setup opens the database for uploading to internal stages.
+func (fn *stageFn) Setup() error {
+	if fn.Location != "" {
+		return nil
+	}
+	db, err := sql.Open(driverName, fn.DSN)
+	if err != nil {
+		return errors.Wrap(err, "snowflakeio: failed to open database")
+	}
+	fn.db = db
+	return nil
+}
+
+func (fn *stageFn) ProcessElement(ctx context.Context, row beam.X, _ func(string)) error {
+	if fn.gz == nil {
+		if err := fn.open(ctx); err != nil {
+			return err
+		}
+	}
+	var err error
+	if fn.buf, err = appendRow(fn.buf[:0], reflect.ValueOf(row)); err != nil {
+		return err
+	}
+	if _, err := fn.gz.Write(fn.buf); err != nil {
+		return errors.Wrapf(err, "snowflakeio: failed to write to %v", fn.name)
+	}
+	fn.rows++
+	return nil
+}
+
+// open creates a new file for the bundle.
+func (fn *stageFn) open(ctx context.Context) error {
+	fn.name = uuid.New().String() + ".csv.gz"
+	if fn.Location == "" {
+		f, err := ioutil.TempFile("", "snowflakeio-*.csv.gz")
+		if err != nil {
+			return errors.Wrap(err, "snowflakeio: failed to create local file")
+		}
+		fn.path, fn.file = f.Name(), f
+		fn.name = filepath.Base(f.Name())
+	} else {
+		path := fn.Location + "/" + fn.Prefix + "/" + fn.name
+		fs, err := filesystem.New(ctx, path)
+		if err != nil {
+			return err
+		}
+		defer fs.Close()
+		w, err := fs.OpenWrite(ctx, path)
+		if err != nil {
+			return errors.Wrapf(err, "snowflakeio: failed to create %v", path)
+		}
+		fn.file = w
+	}
+	fn.gz = gzip.NewWriter(fn.file)
+	return nil
+}
+
+// FinishBundle completes the bundle's file, uploading it to internal stages,
+// and emits its name.
+func (fn *stageFn) FinishBundle(ctx context.Context, emit func(string)) error {
+	if fn.gz == nil {
+		return nil
+	}
+	gz, file, path, rows := fn.gz, fn.file, fn.path, fn.rows
+	fn.gz, fn.file, fn.path, fn.rows = nil, nil, "", 0
+	if path != "" {
+		defer os.Remove(path)
+	}
+	if err := gz.Close(); err != nil {
+		file.Close()
+		return errors.Wrapf(err, "snowflakeio: failed to write %v", fn.name)
+	}
+	if err := file.Close(); err != nil {
+		return errors.Wrapf(err, "snowflakeio: failed to write %v", fn.name)
+	}
+	if path != "" {
+		put := fmt.Sprintf("PUT %v %v/%v/ AUTO_COMPRESS = FALSE SOURCE_COMPRESSION = GZIP OVERWRITE = TRUE",
+			quoteString("file://"+filepath.ToSlash(path)), fn.Stage, fn.Prefix)
+		res, err := fn.db.QueryContext(ctx, put)
+		if err != nil {
+			return errors.Wrapf(err, "snowflakeio: failed to upload %v to %v", fn.name, fn.Stage)
+		}
+		res.Close()
+	}
+	log.Debugf(ctx, "snowflakeio: staged %v rows in %v", rows, fn.name)
+	emit(fn.name)
+	return nil
+}
+
+// Teardown removes any unfinished local file and closes the database.
+func (fn *stageFn) Teardown() {
+	if fn.file != nil {
+		fn.file.Close()
+	}
+	if fn.path != "" {
+		os.Remove(fn.path)
+	}
+	if fn.db != nil {
+		fn.db.Close()
+	}
+}
+
+// copyFn loads the staged files into the table.
+type copyFn struct {
+	stageConfig
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+}
+
+func (fn *copyFn) ProcessElement(ctx context.Context, _ int, files func(*string) bool) error {
+	var names []string
+	var name string
+	for files(&name) {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	db, err := sql.Open(driverName, fn.DSN)
+	if err != nil {
+		return errors.Wrap(err, "snowflakeio: failed to open database")
+	}
+	defer db.Close()
+	for start := 0; start < len(names); start += copyFileLimit {
+		end := start + copyFileLimit
+		if end > len(names) {
+			end = len(names)
+		}
+		if _, err := db.ExecContext(ctx, fn.copyStatement(names[start:end])); err != nil {
+			return errors.Wrapf(err, "snowflakeio: failed to load %v files into %v", end-start, fn.Table)
+		}
+	}
+	log.Infof(ctx, "snowflakeio: loaded %v files into %v", len(names), fn.Table)
+	return nil
+}
+
+func (fn *copyFn) copyStatement(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = quoteString(n)
+	}
+	stmt := fmt.Sprintf("COPY INTO %v (%v) FROM %v/%v/ FILES = (%v) FILE_FORMAT = (TYPE = CSV FIELD_OPTIONALLY_ENCLOSED_BY = '\"' COMPRESSION = GZIP)",
+		fn.Table, strings.Join(fn.Columns, ", "), fn.Stage, fn.Prefix, strings.Join(quoted, ", "))
+	if fn.Location == "" {
+		stmt += " PURGE = TRUE"
+	}
+	return stmt
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// appendRow appends the CSV encoding of the mapped fields of the struct v to
+// buf. Strings are always quoted, so that empty strings are distinguished from
+// nulls, which are empty unquoted values.
+func appendRow(buf []byte, v reflect.Value) ([]byte, error) {
+	fs, err := fields(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range fs {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if buf, err = appendValue(buf, v.Field(f.index)); err != nil {
+			return nil, errors.WithContextf(err, "encoding column %v", f.column)
+		}
+	}
+	return append(buf, '\n'), nil
+}
+
+func appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return buf, nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		return appendQuoted(buf, v.Interface().(time.Time).Format(time.RFC3339Nano)), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return appendQuoted(buf, v.String()), nil
+	case reflect.Bool:
+		return strconv.AppendBool(buf, v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(buf, v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.AppendUint(buf, v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.AppendFloat(buf, v.Float(), 'g', -1, 64), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendQuoted(buf, hex.EncodeToString(v.Bytes())), nil
+		}
+	}
+	// Semi-structured values are loaded from JSON into VARIANT, OBJECT and
+	// ARRAY columns.
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return appendQuoted(buf, string(b)), nil
+}
+
+func appendQuoted(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	buf = append(buf, strings.Replace(s, `"`, `""`, -1)...)
+	return append(buf, '"')
+}
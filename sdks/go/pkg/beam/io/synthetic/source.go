@@ -24,13 +24,16 @@ package synthetic
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
 	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
 	"github.com/apache/beam/sdks/go/pkg/beam/io/rtrackers/offsetrange"
 )
@@ -92,8 +95,12 @@ type sourceFn struct {
 }
 
 // CreateInitialRestriction creates an offset range restriction representing
-// the number of elements to emit.
+// the number of elements to emit. Unbounded sources have a restriction of all
+// non-negative offsets, which is only finished by checkpointing.
 func (fn *sourceFn) CreateInitialRestriction(config SourceConfig) offsetrange.Restriction {
+	if config.Unbounded {
+		return offsetrange.Restriction{Start: 0, End: math.MaxInt64}
+	}
 	return offsetrange.Restriction{
 		Start: 0,
 		End:   int64(config.NumElements),
@@ -103,9 +110,23 @@ func (fn *sourceFn) CreateInitialRestriction(config SourceConfig) offsetrange.Re
 // SplitRestriction splits restrictions equally according to the number of
 // initial splits specified in SourceConfig. Each restriction output by this
 // method will contain at least one element, so the number of splits will not
-// exceed the number of elements.
+// exceed the number of elements. Each split of an unbounded source emits at
+// the target rate.
 func (fn *sourceFn) SplitRestriction(config SourceConfig, rest offsetrange.Restriction) (splits []offsetrange.Restriction) {
-	return rest.EvenSplits(int64(config.InitialSplits))
+	if !config.Unbounded {
+		return rest.EvenSplits(int64(config.InitialSplits))
+	}
+	// EvenSplits would overflow on the size of unbounded restrictions.
+	num := int64(config.InitialSplits)
+	size := (rest.End - rest.Start) / num
+	for i := int64(0); i < num; i++ {
+		split := offsetrange.Restriction{Start: rest.Start + i*size, End: rest.Start + (i+1)*size}
+		if i == num-1 {
+			split.End = rest.End
+		}
+		splits = append(splits, split)
+	}
+	return splits
 }
 
 // RestrictionSize outputs the size of the restriction as the number of elements
@@ -127,20 +148,41 @@ func (fn *sourceFn) Setup() {
 
 // ProcessElement creates a number of random elements based on the restriction
 // tracker received. Each element is a random byte slice key and value, in the
-// form of KV<[]byte, []byte>.
-func (fn *sourceFn) ProcessElement(rt *sdf.LockRTracker, config SourceConfig, emit func([]byte, []byte)) error {
+// form of KV<[]byte, []byte>. Elements of unbounded sources are timestamped
+// with the time they are emitted, and otherwise with the timestamp of the
+// config. Elements are emitted at the target rate of the config, if any.
+func (fn *sourceFn) ProcessElement(ctx context.Context, ts beam.EventTime, rt *sdf.LockRTracker, config SourceConfig, emit func(beam.EventTime, []byte, []byte)) error {
 	generator := rand.New(rand.NewSource(0))
-	for i := rt.GetRestriction().(offsetrange.Restriction).Start; rt.TryClaim(i) == true; i++ {
+	start := rt.GetRestriction().(offsetrange.Restriction).Start
+	began := time.Now()
+	for i := start; rt.TryClaim(i) == true; i++ {
+		if config.RecordsPerSecond > 0 {
+			due := began.Add(time.Duration(float64(i-start) / config.RecordsPerSecond * float64(time.Second)))
+			select {
+			case <-time.After(time.Until(due)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
 		key := make([]byte, config.KeySize)
 		val := make([]byte, config.ValueSize)
 		generator.Seed(i)
 		randomSample := generator.Float64()
-		if randomSample < config.HotKeyFraction {
+		switch {
+		case config.NumZipfKeys > 0:
+			// The rank of the key is drawn from a Zipf distribution, and the
+			// key is generated from its rank, so low ranks are hot keys.
+			rank := rand.NewZipf(generator, config.ZipfExponent, 1, uint64(config.NumZipfKeys-1)).Uint64()
+			generator.Seed(int64(rank))
+			if _, err := generator.Read(key); err != nil {
+				return err
+			}
+		case randomSample < config.HotKeyFraction:
 			generator.Seed(i % int64(config.NumHotKeys))
 			if _, err := generator.Read(key); err != nil {
 				return err
 			}
-		} else {
+		default:
 			if _, err := fn.rng.Read(key); err != nil {
 				return err
 			}
@@ -148,7 +190,10 @@ func (fn *sourceFn) ProcessElement(rt *sdf.LockRTracker, config SourceConfig, em
 		if _, err := fn.rng.Read(val); err != nil {
 			return err
 		}
-		emit(key, val)
+		if config.Unbounded {
+			ts = mtime.Now()
+		}
+		emit(ts, key, val)
 	}
 	return nil
 }
@@ -182,6 +227,7 @@ func DefaultSourceConfig() *SourceConfigBuilder {
 			ValueSize:      8, // 0 is invalid (drops elements).
 			NumHotKeys:     0,
 			HotKeyFraction: 0,
+			ZipfExponent:   1.5,
 		},
 	}
 }
@@ -249,6 +295,51 @@ func (b *SourceConfigBuilder) HotKeyFraction(val float64) *SourceConfigBuilder {
 	return b
 }
 
+// NumZipfKeys determines the number of distinct keys for the source to
+// generate, when keys are drawn from a Zipf distribution, so that a few keys
+// are hot and most keys are rare, as in many real workloads. Keys are drawn
+// uniformly at random when it is 0.
+//
+// Valid values are in the range of [0, ...] and the default value is 0. It
+// can't be set together with HotKeyFraction.
+func (b *SourceConfigBuilder) NumZipfKeys(val int) *SourceConfigBuilder {
+	b.cfg.NumZipfKeys = val
+	return b
+}
+
+// ZipfExponent determines the skew of the Zipf distribution keys are drawn
+// from when NumZipfKeys is set. The frequency of the key of rank k is
+// proportional to 1/k^ZipfExponent, so larger exponents concentrate the
+// elements on fewer keys.
+//
+// Valid values are floating point numbers greater than 1 and the default value
+// is 1.5.
+func (b *SourceConfigBuilder) ZipfExponent(val float64) *SourceConfigBuilder {
+	b.cfg.ZipfExponent = val
+	return b
+}
+
+// Unbounded determines whether the source emits elements until the pipeline is
+// cancelled, as a stream, instead of emitting NumElements elements. Elements
+// of unbounded sources are timestamped with the time they are emitted.
+//
+// The default value is false. Unbounded sources should have a target rate set
+// by RecordsPerSecond.
+func (b *SourceConfigBuilder) Unbounded(val bool) *SourceConfigBuilder {
+	b.cfg.Unbounded = val
+	return b
+}
+
+// RecordsPerSecond determines the target rate at which each initial split of
+// the source emits elements. The source emits elements as fast as it can when
+// it is 0.
+//
+// Valid values are in the range of [0, ...] and the default value is 0.
+func (b *SourceConfigBuilder) RecordsPerSecond(val float64) *SourceConfigBuilder {
+	b.cfg.RecordsPerSecond = val
+	return b
+}
+
 // Build constructs the SourceConfig initialized by this builder. It also
 // performs error checking on the fields, and panics if any have been set to
 // invalid values.
@@ -271,6 +362,18 @@ func (b *SourceConfigBuilder) Build() SourceConfig {
 	if b.cfg.HotKeyFraction < 0 || b.cfg.HotKeyFraction > 1 {
 		panic(fmt.Sprintf("SourceConfig.HotKeyFraction must be a floating point number from 0 and 1. Got: %v", b.cfg.NumHotKeys))
 	}
+	if b.cfg.NumZipfKeys < 0 {
+		panic(fmt.Sprintf("SourceConfig.NumZipfKeys must be >= 0. Got: %v", b.cfg.NumZipfKeys))
+	}
+	if b.cfg.NumZipfKeys > 0 && b.cfg.ZipfExponent <= 1 {
+		panic(fmt.Sprintf("SourceConfig.ZipfExponent must be > 1. Got: %v", b.cfg.ZipfExponent))
+	}
+	if b.cfg.NumZipfKeys > 0 && b.cfg.HotKeyFraction > 0 {
+		panic("SourceConfig.NumZipfKeys and SourceConfig.HotKeyFraction can't both be set")
+	}
+	if b.cfg.RecordsPerSecond < 0 {
+		panic(fmt.Sprintf("SourceConfig.RecordsPerSecond must be >= 0. Got: %v", b.cfg.RecordsPerSecond))
+	}
 	return b.cfg
 }
 
@@ -305,4 +408,9 @@ type SourceConfig struct {
 	ValueSize      int     `json:"value_size"`
 	NumHotKeys     int     `json:"num_hot_keys"`
 	HotKeyFraction float64 `json:"hot_key_fraction"`
+
+	NumZipfKeys      int     `json:"num_zipf_keys"`
+	ZipfExponent     float64 `json:"zipf_exponent"`
+	Unbounded        bool    `json:"unbounded"`
+	RecordsPerSecond float64 `json:"records_per_second"`
 }
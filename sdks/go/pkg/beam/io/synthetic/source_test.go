@@ -16,9 +16,14 @@
 package synthetic
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 )
 
 // TestSourceConfig_NumElements tests that setting the number of produced
@@ -209,6 +214,72 @@ func TestSourceConfigBuilder_NumHotKeys(t *testing.T) {
 	}
 }
 
+// TestSourceConfig_NumZipfKeys tests that keys drawn from a Zipf distribution
+// are skewed towards a few hot keys.
+func TestSourceConfig_NumZipfKeys(t *testing.T) {
+	dfn := sourceFn{}
+	cfg := DefaultSourceConfig().NumElements(1000).NumZipfKeys(100).ZipfExponent(2).Build()
+
+	keys, _, err := simulateSourceFn(t, &dfn, cfg)
+	if err != nil {
+		t.Fatalf("Failure processing sourceFn: %v", err)
+	}
+	m := make(map[string]int)
+	hottest := 0
+	for _, key := range keys {
+		encoded := hex.EncodeToString(key)
+		m[encoded]++
+		if m[encoded] > hottest {
+			hottest = m[encoded]
+		}
+	}
+	if len(m) > 100 {
+		t.Errorf("SourceFn emitted too many distinct keys: got: %v, want: <= 100", len(m))
+	}
+	// The hottest key has about 1/zeta(2) ~ 61% of the elements.
+	if hottest < 500 {
+		t.Errorf("SourceFn emitted hottest key %v times, want >= 500 of 1000", hottest)
+	}
+}
+
+// TestSourceConfig_Unbounded tests that unbounded sources emit timestamped
+// elements at the target rate until their restriction is checkpointed.
+func TestSourceConfig_Unbounded(t *testing.T) {
+	dfn := sourceFn{}
+	cfg := DefaultSourceConfig().Unbounded(true).RecordsPerSecond(100).InitialSplits(2).Build()
+
+	rest := dfn.CreateInitialRestriction(cfg)
+	splits := dfn.SplitRestriction(cfg, rest)
+	if len(splits) != 2 || splits[0].Start != rest.Start || splits[0].End != splits[1].Start || splits[1].End != rest.End {
+		t.Fatalf("SplitRestriction(%v) = %v, want 2 contiguous splits", rest, splits)
+	}
+
+	dfn.Setup()
+	rt := dfn.CreateTracker(splits[0])
+	count := 0
+	start := mtime.Now()
+	emitFn := func(ts beam.EventTime, key []byte, val []byte) {
+		if ts < start {
+			t.Errorf("SourceFn emitted element at %v, want >= %v", ts, start)
+		}
+		count++
+		if count == 10 {
+			rt.TrySplit(0)
+		}
+	}
+	began := time.Now()
+	if err := dfn.ProcessElement(context.Background(), mtime.ZeroTimestamp, rt, cfg, emitFn); err != nil {
+		t.Fatalf("Failure processing sourceFn: %v", err)
+	}
+	if count != 10 {
+		t.Errorf("SourceFn emitted %v elements before its checkpoint, want 10", count)
+	}
+	// 10 elements at 100 per second take at least 90ms.
+	if elapsed := time.Since(began); elapsed < 90*time.Millisecond {
+		t.Errorf("SourceFn emitted 10 elements in %v, want >= 90ms at 100 elements per second", elapsed)
+	}
+}
+
 // simulateSourceFn calls CreateInitialRestriction, SplitRestriction,
 // CreateTracker, and ProcessElement on the given sourceFn with the given
 // SourceConfig, and outputs the resulting output elements. This method isn't
@@ -218,7 +289,7 @@ func TestSourceConfigBuilder_NumHotKeys(t *testing.T) {
 func simulateSourceFn(t *testing.T, dfn *sourceFn, cfg SourceConfig) (keys [][]byte, vals [][]byte, err error) {
 	t.Helper()
 
-	emitFn := func(key []byte, val []byte) {
+	emitFn := func(_ beam.EventTime, key []byte, val []byte) {
 		keys = append(keys, key)
 		vals = append(vals, key)
 	}
@@ -228,7 +299,7 @@ func simulateSourceFn(t *testing.T, dfn *sourceFn, cfg SourceConfig) (keys [][]b
 	dfn.Setup()
 	for _, split := range splits {
 		rt := dfn.CreateTracker(split)
-		if err := dfn.ProcessElement(rt, cfg, emitFn); err != nil {
+		if err := dfn.ProcessElement(context.Background(), mtime.ZeroTimestamp, rt, cfg, emitFn); err != nil {
 			return nil, nil, err
 		}
 	}
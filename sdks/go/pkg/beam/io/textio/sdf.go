@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
@@ -149,6 +150,7 @@ func (fn *readSdfFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, f
 		return err
 	}
 	defer fd.Close()
+	metrics.AddLineageSource(ctx, filesystem.LineageFQN(filename))
 
 	rd := bufio.NewReader(fd)
 
@@ -25,6 +25,7 @@ import (
 	"strings"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/io/filesystem"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 )
@@ -93,6 +94,7 @@ func readFn(ctx context.Context, filename string, emit func(string)) error {
 		return err
 	}
 	defer fd.Close()
+	metrics.AddLineageSource(ctx, filesystem.LineageFQN(filename))
 
 	rd := bufio.NewReader(fd)
 	for {
@@ -147,6 +149,7 @@ func (w *writeFileFn) ProcessElement(ctx context.Context, _ int, lines func(*str
 	if err != nil {
 		return err
 	}
+	metrics.AddLineageSink(ctx, filesystem.LineageFQN(w.Filename))
 	buf := bufio.NewWriterSize(fd, 1<<20) // use 1MB buffer
 
 	log.Infof(ctx, "Writing to %v", w.Filename)
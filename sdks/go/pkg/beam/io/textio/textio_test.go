@@ -17,9 +17,12 @@
 package textio
 
 import (
+	"context"
 	"testing"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	_ "github.com/apache/beam/sdks/go/pkg/beam/io/filesystem/local"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestRead(t *testing.T) {
@@ -30,7 +33,7 @@ func TestRead(t *testing.T) {
 		receivedLines = append(receivedLines, line)
 	}
 
-	err := readFn(nil, f, getLines)
+	err := readFn(context.Background(), f, getLines)
 	if err != nil {
 		t.Fatalf("failed with %v", err)
 	}
@@ -40,3 +43,16 @@ func TestRead(t *testing.T) {
 	}
 
 }
+
+func TestRead_Lineage(t *testing.T) {
+	f := "../../../../data/textio_test.txt"
+
+	ctx := metrics.SetPTransformID(metrics.SetBundleID(context.Background(), "bundle"), "read")
+	if err := readFn(ctx, f, func(string) {}); err != nil {
+		t.Fatalf("failed with %v", err)
+	}
+	got := metrics.LineageResults(metrics.ResultsFromStore(metrics.GetStore(ctx)), metrics.LineageSources)
+	if want := []string{"filesystem:localhost.`../../../../data/textio_test.txt`"}; !cmp.Equal(got, want) {
+		t.Errorf("lineage sources = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,408 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package websocketio contains a transform for reading the messages of a
+// WebSocket subscription as an unbounded source.
+//
+// Experimental.
+package websocketio
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/rtrackers/cursor"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	"github.com/gorilla/websocket"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*readFn)(nil)).Elem())
+	beam.RegisterFunction(unnumbered)
+}
+
+// reconnectBackoff is the delay before the first reconnect after a connection
+// fails, which doubles for each further consecutive failure up to a minute.
+// It is a variable so tests can shorten it.
+var reconnectBackoff = time.Second
+
+// SequenceFn returns the sequence number of a message. Sequence numbers are
+// positive and increase through the subscription, but may have gaps. A zero
+// sequence number numbers the message after the last message read.
+type SequenceFn func(msg []byte) (int64, error)
+
+// unnumbered is the SequenceFn of subscriptions without sequence numbers.
+func unnumbered([]byte) (int64, error) {
+	return 0, nil
+}
+
+// Read connects to the WebSocket server at url, sends the Subscribe messages,
+// and returns a PCollection<[]byte> of the messages the server sends. Text
+// and binary messages are both emitted as their payloads, and each message is
+// timestamped with the time it is received.
+//
+// When the connection fails, Read reconnects with exponential backoff and
+// subscribes again. Each message read is numbered with a sequence number,
+// which runners checkpoint by splitting the restriction of the read. Messages
+// are numbered by the Sequence option if the server numbers them, and are
+// otherwise numbered in the order they are read. With server sequence numbers,
+// messages replayed by the server after a reconnect are dropped, and the
+// ResumeParam option tells the server where to resume.
+//
+// Read runs until the server closes the connection normally or the pipeline
+// is cancelled, unless the read is bounded with MaxNumRecords or MaxReadTime.
+//
+// Example:
+//
+//	trades := websocketio.Read(s, "wss://feed.example.com/v1/stream",
+//		websocketio.Subscribe([]byte(`{"type":"subscribe","channel":"trades"}`)),
+//		websocketio.Sequence(tradeSequence),
+//		websocketio.ResumeParam("from"))
+func Read(s beam.Scope, url string, opts ...readOption) beam.PCollection {
+	s = s.Scope("websocketio.Read")
+
+	fn := &readFn{
+		URL:           url,
+		Header:        map[string]string{},
+		Sequence:      beam.EncodedFunc{Fn: reflectx.MakeFunc(unnumbered)},
+		PingInterval:  30 * time.Second,
+		MaxReconnects: 10,
+		MaxNumRecords: -1,
+	}
+	for _, opt := range opts {
+		opt(fn)
+	}
+	return beam.ParDo(s, fn, beam.Impulse(s))
+}
+
+type readOption func(*readFn)
+
+// Header is a Read option that sets a header of the opening handshake, such
+// as an authorization header. Headers are stored in the pipeline graph.
+func Header(key, value string) readOption {
+	return func(fn *readFn) {
+		fn.Header[key] = value
+	}
+}
+
+// Subscribe is a Read option that adds a text message sent after each
+// connection is opened, such as a subscription request. Messages are sent in
+// the order they are added.
+func Subscribe(msg []byte) readOption {
+	return func(fn *readFn) {
+		fn.Subscriptions = append(fn.Subscriptions, msg)
+	}
+}
+
+// Sequence is a Read option that sets the function extracting the sequence
+// number of each message. The function must be registered with
+// beam.RegisterFunction to run on remote workers.
+func Sequence(fn SequenceFn) readOption {
+	return func(r *readFn) {
+		r.Sequence = beam.EncodedFunc{Fn: reflectx.MakeFunc((func([]byte) (int64, error))(fn))}
+	}
+}
+
+// ResumeParam is a Read option that sets the query parameter of the url
+// carrying the sequence number of the last message read, when reconnecting or
+// resuming a checkpointed read. By default the url is unchanged.
+func ResumeParam(name string) readOption {
+	return func(fn *readFn) {
+		fn.ResumeParam = name
+	}
+}
+
+// PingInterval is a Read option that sets the interval between the pings that
+// keep the connection alive. The connection is reopened if no message or pong
+// is received for two intervals. A non-positive interval disables pings.
+//
+// Default: 30s
+func PingInterval(d time.Duration) readOption {
+	return func(fn *readFn) {
+		fn.PingInterval = d
+	}
+}
+
+// MaxReconnects is a Read option that sets the maximum number of consecutive
+// reconnects without receiving a message, before the read fails.
+//
+// Default: 10
+func MaxReconnects(n int) readOption {
+	return func(fn *readFn) {
+		fn.MaxReconnects = n
+	}
+}
+
+// MaxNumRecords is a Read option that specifies the maximum number of messages
+// to read. Setting this causes the Read to execute as a bounded transform.
+// Useful for tests and demo applications.
+func MaxNumRecords(n int64) readOption {
+	return func(fn *readFn) {
+		fn.MaxNumRecords = n
+	}
+}
+
+// MaxReadTime is a Read option that specifies the maximum duration of time
+// each restriction is read for. Setting this causes the Read to execute as a
+// bounded transform. Useful for tests and demo applications.
+func MaxReadTime(d time.Duration) readOption {
+	return func(fn *readFn) {
+		fn.MaxReadTime = d
+	}
+}
+
+// readFn is a splittable DoFn that reads the messages of a subscription.
+type readFn struct {
+	URL           string            `json:"url"`
+	Header        map[string]string `json:"header"`
+	Subscriptions [][]byte          `json:"subscriptions"`
+	Sequence      beam.EncodedFunc  `json:"sequence"`
+	ResumeParam   string            `json:"resumeParam"`
+	PingInterval  time.Duration     `json:"pingInterval"`
+	MaxReconnects int               `json:"maxReconnects"`
+	MaxNumRecords int64             `json:"maxNumRecords"`
+	MaxReadTime   time.Duration     `json:"maxReadTime"`
+
+	sequence reflectx.Func1x2
+}
+
+// CreateInitialRestriction creates a restriction at the start of the
+// subscription.
+func (fn *readFn) CreateInitialRestriction(_ []byte) cursor.Restriction {
+	return cursor.Restriction{Limit: fn.MaxNumRecords}
+}
+
+// SplitRestriction returns the restriction unchanged, as a subscription is
+// read sequentially.
+func (fn *readFn) SplitRestriction(_ []byte, rest cursor.Restriction) []cursor.Restriction {
+	return []cursor.Restriction{rest}
+}
+
+// RestrictionSize returns the number of messages in a bounded restriction,
+// and one for unbounded restrictions.
+func (fn *readFn) RestrictionSize(_ []byte, rest cursor.Restriction) float64 {
+	if rest.IsBounded() {
+		return float64(rest.Limit)
+	}
+	return 1
+}
+
+// CreateTracker creates a subscription tracker wrapped in an
+// sdf.LockRTracker for the restriction.
+func (fn *readFn) CreateTracker(rest cursor.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(cursor.NewTracker(rest))
+}
+
+// Setup prepares the sequence function.
+func (fn *readFn) Setup() {
+	fn.sequence = reflectx.ToFunc1x2(fn.Sequence.Fn)
+}
+
+// ProcessElement emits each message claimed from the restriction. It returns
+// when the restriction is exhausted or checkpointed, when the server closes
+// the connection normally, or when the maximum read time elapses.
+func (fn *readFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, _ []byte, emit func(beam.EventTime, []byte)) error {
+	rest := rt.GetRestriction().(cursor.Restriction)
+	if rest.IsBounded() && rest.Limit == 0 {
+		rt.TryClaim(nil)
+		return nil
+	}
+	if fn.MaxReadTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fn.MaxReadTime)
+		defer cancel()
+	}
+
+	last := decodeSeq(rest.After)
+	backoff := reconnectBackoff
+	failures := 0
+	for {
+		err := fn.readConn(ctx, rt, last, emit, func(seq int64) {
+			last = seq
+			failures, backoff = 0, reconnectBackoff
+		})
+		switch {
+		case err == errStopped:
+			return nil
+		case websocket.IsCloseError(err, websocket.CloseNormalClosure), ctx.Err() == context.DeadlineExceeded:
+			// Finish claiming the restriction so the read is considered done.
+			rt.TryClaim(nil)
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case !isTransient(err) || failures >= fn.MaxReconnects:
+			return errors.Wrapf(err, "websocketio: failed to read from %v", fn.URL)
+		}
+		failures++
+		log.Warnf(ctx, "websocketio: reconnecting to %v in %v: %v", fn.URL, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			continue // Handled by the next iteration.
+		}
+		if backoff *= 2; backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+// errStopped reports that the tracker stopped claiming messages.
+var errStopped = errors.New("restriction stopped")
+
+// permanentError wraps an error that is not fixed by reconnecting.
+type permanentError struct {
+	error
+}
+
+// isTransient returns whether a connection that failed with the error should
+// be reopened.
+func isTransient(err error) bool {
+	_, ok := err.(permanentError)
+	return !ok
+}
+
+// dial opens a connection and subscribes, resuming after the given sequence
+// number.
+func (fn *readFn) dial(ctx context.Context, after int64) (*websocket.Conn, error) {
+	u := fn.URL
+	if fn.ResumeParam != "" && after > 0 {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return nil, permanentError{errors.Wrap(err, "invalid url")}
+		}
+		q := parsed.Query()
+		q.Set(fn.ResumeParam, strconv.FormatInt(after, 10))
+		parsed.RawQuery = q.Encode()
+		u = parsed.String()
+	}
+	header := http.Header{}
+	for k, v := range fn.Header {
+		header.Set(k, v)
+	}
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u, header)
+	if err != nil {
+		if resp != nil {
+			return nil, errors.Wrapf(err, "handshake failed with %v", resp.Status)
+		}
+		return nil, err
+	}
+	for _, msg := range fn.Subscriptions {
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// readConn reads messages from one connection, after the given sequence
+// number, until the connection fails or the tracker stops claiming messages.
+// It reports the sequence number of each emitted message with claimed.
+func (fn *readFn) readConn(ctx context.Context, rt *sdf.LockRTracker, last int64, emit func(beam.EventTime, []byte), claimed func(int64)) error {
+	conn, err := fn.dial(ctx, last)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// Unblocks reads when processing stops.
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+	if fn.PingInterval > 0 {
+		fn.keepAlive(conn, done)
+	}
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if fn.PingInterval > 0 {
+			conn.SetReadDeadline(time.Now().Add(2 * fn.PingInterval))
+		}
+		seq, serr := fn.sequence.Call1x2(msg)
+		if err, _ := serr.(error); err != nil {
+			return permanentError{errors.Wrap(err, "failed to extract message sequence number")}
+		}
+		n := seq.(int64)
+		if n == 0 {
+			n = last + 1
+		}
+		if n <= last {
+			continue // Replayed by the server after resuming.
+		}
+		if !rt.TryClaim(encodeSeq(n)) {
+			return errStopped
+		}
+		emit(mtime.Now(), msg)
+		last = n
+		claimed(n)
+	}
+}
+
+// encodeSeq encodes a sequence number as a restriction cursor.
+func encodeSeq(n int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n))
+	return b
+}
+
+// decodeSeq decodes the sequence number of a restriction cursor, which is
+// zero before the first message.
+func decodeSeq(b []byte) int64 {
+	if len(b) < 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// keepAlive pings the server until done is closed, and extends the read
+// deadline of the connection with each pong.
+func (fn *readFn) keepAlive(conn *websocket.Conn, done <-chan struct{}) {
+	wait := 2 * fn.PingInterval
+	conn.SetReadDeadline(time.Now().Add(wait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wait))
+	})
+	go func() {
+		ticker := time.NewTicker(fn.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(fn.PingInterval)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,248 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package websocketio
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/rtrackers/cursor"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"github.com/gorilla/websocket"
+)
+
+func init() {
+	beam.RegisterFunction(testSequence)
+	beam.RegisterFunction(badSequence)
+}
+
+// testSequence returns the sequence number a message is made of.
+func testSequence(msg []byte) (int64, error) {
+	return strconv.ParseInt(string(msg), 10, 64)
+}
+
+func badSequence([]byte) (int64, error) {
+	return 0, fmt.Errorf("no sequence number")
+}
+
+// fakeServer sends the sequence numbers 1 to n as messages to subscribers,
+// starting after the "from" query parameter, and then closes the connection
+// normally. A negative n sends messages until the subscriber disconnects.
+type fakeServer struct {
+	n int
+	// drop is the number of messages sent before the first connection is
+	// dropped, if positive.
+	drop int
+	// replay ignores the "from" query parameter.
+	replay bool
+
+	mu         sync.Mutex
+	handshakes int
+	froms      []string // The from parameters of accepted handshakes.
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.handshakes++
+	f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		http.Error(w, "missing authorization", http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	from := r.URL.Query().Get("from")
+	f.froms = append(f.froms, from)
+	first := len(f.froms) == 1
+	f.mu.Unlock()
+
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != `{"subscribe":"seq"}` {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "bad subscription"), time.Now().Add(time.Second))
+		return
+	}
+	next := 1
+	if i, err := strconv.Atoi(from); err == nil && !f.replay {
+		next = i + 1
+	}
+	for sent := 0; f.n < 0 || next <= f.n; sent++ {
+		if first && f.drop > 0 && sent == f.drop {
+			return // Drops the connection without a close message.
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(strconv.Itoa(next))); err != nil {
+			return
+		}
+		next++
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.ReadMessage() // Waits for the client to close.
+}
+
+// serve starts the fake server, and returns its url and a function stopping
+// it.
+func serve(f *fakeServer) (string, func()) {
+	srv := httptest.NewServer(f)
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), srv.Close
+}
+
+// withFastRetries shortens the reconnect backoff, and returns a function
+// restoring it.
+func withFastRetries() func() {
+	orig := reconnectBackoff
+	reconnectBackoff = time.Millisecond
+	return func() { reconnectBackoff = orig }
+}
+
+func read(s beam.Scope, url string, opts ...readOption) beam.PCollection {
+	opts = append([]readOption{Header("Authorization", "Bearer secret"), Subscribe([]byte(`{"subscribe":"seq"}`))}, opts...)
+	return Read(s, url, opts...)
+}
+
+func TestRead(t *testing.T) {
+	url, stop := serve(&fakeServer{n: 3})
+	defer stop()
+
+	p, s := beam.NewPipelineWithRoot()
+	msgs := read(s, url, Sequence(testSequence))
+	passert.Equals(s, msgs, []byte("1"), []byte("2"), []byte("3"))
+	ptest.RunAndValidate(t, p)
+}
+
+// TestRead_Reconnect tests that dropped connections are resumed after the
+// last message read, and that replayed messages are dropped.
+func TestRead_Reconnect(t *testing.T) {
+	defer withFastRetries()()
+	tests := []struct {
+		name      string
+		replay    bool
+		opts      []readOption
+		wantFroms []string
+	}{
+		{name: "resume", opts: []readOption{Sequence(testSequence), ResumeParam("from")}, wantFroms: []string{"", "2"}},
+		{name: "replay", replay: true, opts: []readOption{Sequence(testSequence)}, wantFroms: []string{"", ""}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			f := &fakeServer{n: 4, drop: 2, replay: test.replay}
+			url, stop := serve(f)
+			defer stop()
+
+			p, s := beam.NewPipelineWithRoot()
+			msgs := read(s, url, test.opts...)
+			passert.Equals(s, msgs, []byte("1"), []byte("2"), []byte("3"), []byte("4"))
+			ptest.RunAndValidate(t, p)
+
+			if !reflect.DeepEqual(f.froms, test.wantFroms) {
+				t.Errorf("server received from parameters %q, want %q", f.froms, test.wantFroms)
+			}
+		})
+	}
+}
+
+// TestRead_Unnumbered tests that messages are numbered in the order they are
+// read without the Sequence option.
+func TestRead_Unnumbered(t *testing.T) {
+	url, stop := serve(&fakeServer{n: -1})
+	defer stop()
+
+	p, s := beam.NewPipelineWithRoot()
+	msgs := read(s, url, MaxNumRecords(3))
+	passert.Equals(s, msgs, []byte("1"), []byte("2"), []byte("3"))
+	ptest.RunAndValidate(t, p)
+}
+
+func TestRead_Failure(t *testing.T) {
+	defer withFastRetries()()
+	tests := []struct {
+		name           string
+		opts           []readOption
+		wantHandshakes int
+	}{
+		{
+			name:           "unauthorized",
+			opts:           []readOption{Header("Authorization", "Bearer wrong")},
+			wantHandshakes: 3,
+		},
+		{
+			name:           "sequence",
+			opts:           []readOption{Sequence(badSequence)},
+			wantHandshakes: 1,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			f := &fakeServer{n: 3}
+			url, stop := serve(f)
+			defer stop()
+
+			p, s := beam.NewPipelineWithRoot()
+			read(s, url, append(test.opts, MaxReconnects(2))...)
+			if err := ptest.Run(p); err == nil {
+				t.Error("pipeline succeeded, want error")
+			}
+			if f.handshakes != test.wantHandshakes {
+				t.Errorf("server received %v handshakes, want %v", f.handshakes, test.wantHandshakes)
+			}
+		})
+	}
+}
+
+// TestRead_Resume tests that reading a checkpointed restriction resumes after
+// its sequence number.
+func TestRead_Resume(t *testing.T) {
+	url, stop := serve(&fakeServer{n: -1})
+	defer stop()
+
+	fn := &readFn{
+		URL:           url,
+		Header:        map[string]string{"Authorization": "Bearer secret"},
+		Subscriptions: [][]byte{[]byte(`{"subscribe":"seq"}`)},
+		ResumeParam:   "from",
+		MaxReconnects: 1,
+	}
+	Sequence(testSequence)(fn)
+	fn.Setup()
+	rt := sdf.NewLockRTracker(cursor.NewTracker(cursor.Restriction{After: encodeSeq(4), Limit: 2}))
+	var got []string
+	err := fn.ProcessElement(context.Background(), rt, nil, func(_ beam.EventTime, b []byte) {
+		got = append(got, string(b))
+	})
+	if err != nil {
+		t.Fatalf("ProcessElement failed: %v", err)
+	}
+	if want := "[5 6]"; fmt.Sprint(got) != want {
+		t.Errorf("ProcessElement emitted %v, want %v", got, want)
+	}
+	if !rt.IsDone() {
+		t.Errorf("restriction is not done after its limit")
+	}
+}
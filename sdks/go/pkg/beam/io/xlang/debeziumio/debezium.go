@@ -0,0 +1,222 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package debeziumio contains cross-language functionality for reading the
+// change-data-capture streams of databases with Debezium (https://debezium.io/),
+// such as those of MySQL, PostgreSQL or SQL Server. These transforms only work
+// on runners that support cross-language transforms.
+//
+// # Setup
+//
+// Transforms specified here are cross-language transforms implemented in a
+// different SDK (listed below). During pipeline construction, the Go SDK will
+// need to connect to an expansion service containing information on these
+// transforms in their native SDK.
+//
+// To use an expansion service, it must be run as a separate process accessible
+// during pipeline construction. The address of that process must be passed to
+// the transforms in this package. If the address is empty, the expansion service
+// jar released for the version of the Beam SDK is downloaded, and started and
+// stopped as needed.
+//
+// Current supported SDKs, including expansion service modules and reference
+// documentation:
+// * Java
+//   - Vendored Module: beam-sdks-java-io-debezium-expansion-service
+//   - Run via Gradle: ./gradlew :sdks:java:io:debezium:expansion-service:runExpansionService
+//   - Reference Class: org.apache.beam.io.debezium.DebeziumIO
+package debeziumio
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*readPayload)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*decodeFn)(nil)).Elem())
+}
+
+// Connector is the Debezium connector of a kind of database.
+type Connector string
+
+const (
+	MySQL      Connector = "MySQL"
+	PostgreSQL Connector = "PostgreSQL"
+	SQLServer  Connector = "SQLServer"
+	Oracle     Connector = "Oracle"
+	DB2        Connector = "DB2"
+
+	readURN = "beam:external:java:debezium:read:v1"
+
+	// serviceGradleTarget is the Gradle target of the expansion service jar
+	// used if no expansion address is given.
+	serviceGradleTarget = "sdks:java:io:debezium:expansion-service:shadowJar"
+)
+
+// expansionAddr returns the given expansion address, or the address of the
+// released expansion service jar if it is empty.
+func expansionAddr(addr string) string {
+	if addr == "" {
+		return xlangx.AutoJava(serviceGradleTarget)
+	}
+	return addr
+}
+
+// Connection holds the settings for connecting to the database whose changes
+// are captured.
+type Connection struct {
+	Host               string
+	Port               int
+	Username, Password string
+}
+
+// Read is a cross-language PTransform which captures the changes of a
+// database with the given Debezium connector, and returns a PCollection of
+// strings, each the JSON of a change. The JSON objects hold the "metadata" of
+// the change, such as its database and table, and the row "before" and "after"
+// it. By default, this runs as an unbounded transform.
+//
+// Read requires the address for an expansion service for Debezium transforms,
+// the connector of the database, and the settings for connecting to it. The
+// connector is configured further by the properties of Debezium, such as the
+// tables to capture, with the ConnectionProperties option. The changes are
+// decoded to structs instead with the OutputType option.
+//
+// Example of Read decoding the changes of a MySQL database:
+//
+//	type Customer struct {
+//	    ID   int64  `json:"id"`
+//	    Name string `json:"name"`
+//	}
+//	type Change struct {
+//	    Before *Customer `json:"before"`
+//	    After  *Customer `json:"after"`
+//	}
+//
+//	conn := debeziumio.Connection{Host: "localhost", Port: 3306, Username: "debezium", Password: password}
+//	changes := debeziumio.Read(s, expansionAddr, debeziumio.MySQL, conn,
+//	    debeziumio.ConnectionProperties("database.server.name=shop", "table.include.list=shop.customers"),
+//	    debeziumio.OutputType(reflect.TypeOf(Change{})))
+func Read(s beam.Scope, addr string, connector Connector, conn Connection, opts ...readOption) beam.PCollection {
+	s = s.Scope("debeziumio.Read")
+
+	cfg := newReadConfig(connector, conn, opts)
+	pl := beam.CrossLanguagePayload(cfg.pl)
+	outT := beam.UnnamedOutput(typex.New(reflectx.String))
+	out := beam.CrossLanguage(s, readURN, pl, expansionAddr(addr), nil, outT)[graph.UnnamedOutputTag]
+	if cfg.outT == nil {
+		return out
+	}
+	return beam.ParDo(s, &decodeFn{Type: beam.EncodedType{T: cfg.outT}}, out, beam.TypeDefinition{Var: beam.YType, T: cfg.outT})
+}
+
+// newReadConfig returns the configuration of a read with the given options,
+// and panics if it is invalid.
+func newReadConfig(connector Connector, conn Connection, opts []readOption) *readConfig {
+	switch connector {
+	case MySQL, PostgreSQL, SQLServer, Oracle, DB2:
+	default:
+		panic(fmt.Sprintf("debeziumio.Read has unknown connector %q, want one of %q, %q, %q, %q or %q.",
+			connector, MySQL, PostgreSQL, SQLServer, Oracle, DB2))
+	}
+	if conn.Host == "" || conn.Port <= 0 {
+		panic(fmt.Sprintf("debeziumio.Read requires the host and port of the database, got %q and %v.", conn.Host, conn.Port))
+	}
+	cfg := &readConfig{
+		pl: &readPayload{
+			ConnectorClass: string(connector),
+			Username:       conn.Username,
+			Password:       conn.Password,
+			Host:           conn.Host,
+			Port:           strconv.Itoa(conn.Port),
+		},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+type readOption func(*readConfig)
+type readConfig struct {
+	pl *readPayload
+	// outT is the type of the decoded changes, or nil to return JSON.
+	outT reflect.Type
+}
+
+// ConnectionProperties is a Read option that adds properties of the Debezium
+// connector, in the "key=value" format, such as
+// "table.include.list=shop.customers". Each usage of this adds the given
+// properties to the existing ones.
+func ConnectionProperties(props ...string) readOption {
+	return func(cfg *readConfig) {
+		cfg.pl.ConnectionProperties = append(cfg.pl.ConnectionProperties, props...)
+	}
+}
+
+// MaxNumRecords is a Read option that specifies the maximum amount of changes
+// to be read. Setting this will cause the Read to execute as a bounded
+// transform. Useful for tests and demo applications.
+func MaxNumRecords(num int64) readOption {
+	return func(cfg *readConfig) {
+		cfg.pl.MaxNumberOfRecords = &num
+	}
+}
+
+// OutputType is a Read option that decodes the JSON of each change to the
+// given type, such as a struct with fields tagged by "metadata", "before" and
+// "after", instead of returning the JSON.
+func OutputType(t reflect.Type) readOption {
+	return func(cfg *readConfig) {
+		cfg.outT = t
+	}
+}
+
+// readPayload should produce a schema matching the expected cross-language
+// payload for Debezium reads. An example of this on the receiving end can be
+// found in the Java SDK class
+// org.apache.beam.io.debezium.DebeziumTransformRegistrar.ReadBuilder.Configuration.
+type readPayload struct {
+	ConnectorClass       string
+	Username             string
+	Password             string
+	Host                 string
+	Port                 string
+	MaxNumberOfRecords   *int64
+	ConnectionProperties []string
+}
+
+// decodeFn decodes the JSON of changes to the given type.
+type decodeFn struct {
+	Type beam.EncodedType `json:"type"`
+}
+
+func (fn *decodeFn) ProcessElement(change string) (beam.Y, error) {
+	v := reflect.New(fn.Type.T)
+	if err := json.Unmarshal([]byte(change), v.Interface()); err != nil {
+		return nil, errors.Wrapf(err, "decoding change %v as %v", change, fn.Type.T)
+	}
+	return v.Elem().Interface(), nil
+}
@@ -0,0 +1,107 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debeziumio
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewReadConfig(t *testing.T) {
+	conn := Connection{Host: "localhost", Port: 5432, Username: "user", Password: "pass"}
+	cfg := newReadConfig(PostgreSQL, conn, []readOption{
+		ConnectionProperties("database.dbname=shop"),
+		ConnectionProperties("table.include.list=public.customers"),
+		MaxNumRecords(10),
+	})
+
+	n := int64(10)
+	want := &readPayload{
+		ConnectorClass:       "PostgreSQL",
+		Username:             "user",
+		Password:             "pass",
+		Host:                 "localhost",
+		Port:                 "5432",
+		MaxNumberOfRecords:   &n,
+		ConnectionProperties: []string{"database.dbname=shop", "table.include.list=public.customers"},
+	}
+	if diff := cmp.Diff(want, cfg.pl); diff != "" {
+		t.Errorf("newReadConfig payload mismatch (-want +got):\n%v", diff)
+	}
+	if cfg.outT != nil {
+		t.Errorf("newReadConfig output type = %v, want none", cfg.outT)
+	}
+	// The payload must encode, with the optional fields unset.
+	beam.CrossLanguagePayload(newReadConfig(MySQL, conn, nil).pl)
+}
+
+func TestNewReadConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		connector Connector
+		conn      Connection
+	}{
+		{"UnknownConnector", "MongoDB", Connection{Host: "localhost", Port: 27017}},
+		{"NoHost", MySQL, Connection{Port: 3306}},
+		{"NoPort", MySQL, Connection{Host: "localhost"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("newReadConfig(%q, %+v) succeeded, want panic", test.connector, test.conn)
+				}
+			}()
+			newReadConfig(test.connector, test.conn, nil)
+		})
+	}
+}
+
+type customer struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type change struct {
+	Metadata struct {
+		Table string `json:"table"`
+	} `json:"metadata"`
+	Before *customer `json:"before"`
+	After  *customer `json:"after"`
+}
+
+func TestDecodeFn(t *testing.T) {
+	fn := &decodeFn{Type: beam.EncodedType{T: reflect.TypeOf(change{})}}
+	got, err := fn.ProcessElement(`{"metadata":{"connector":"mysql","table":"customers"},"before":null,"after":{"id":1,"name":"Ada"}}`)
+	if err != nil {
+		t.Fatalf("ProcessElement failed: %v", err)
+	}
+	want := change{After: &customer{ID: 1, Name: "Ada"}}
+	want.Metadata.Table = "customers"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("decoded change mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestDecodeFn_Invalid(t *testing.T) {
+	fn := &decodeFn{Type: beam.EncodedType{T: reflect.TypeOf(change{})}}
+	if got, err := fn.ProcessElement(`{"after":`); err == nil {
+		t.Errorf("ProcessElement = %v, want error for invalid JSON", got)
+	}
+}
@@ -0,0 +1,295 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jdbcio contains cross-language functionality for reading and writing
+// databases with JDBC, such as Oracle, SQL Server, MySQL or PostgreSQL. These
+// transforms only work on runners that support cross-language transforms.
+//
+// # Setup
+//
+// Transforms specified here are cross-language transforms implemented in a
+// different SDK (listed below). During pipeline construction, the Go SDK will
+// need to connect to an expansion service containing information on these
+// transforms in their native SDK.
+//
+// To use an expansion service, it must be run as a separate process accessible
+// during pipeline construction. The address of that process must be passed to
+// the transforms in this package. If the address is empty, the expansion service
+// jar released for the version of the Beam SDK is downloaded, and started and
+// stopped as needed.
+//
+// The JDBC driver of the database must be available to the expansion service
+// and the Java SDK harness, such as by adding its jar to the classpath of the
+// expansion service, which stages it with the transforms.
+//
+// Current supported SDKs, including expansion service modules and reference
+// documentation:
+// * Java
+//   - Vendored Module: beam-sdks-java-extensions-schemaio-expansion-service
+//   - Run via Gradle: ./gradlew :sdks:java:extensions:schemaio-expansion-service:runExpansionService
+//   - Reference Class: org.apache.beam.sdk.io.jdbc.JdbcIO
+package jdbcio
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*jdbcConfigSchema)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*config)(nil)).Elem())
+}
+
+const (
+	readURN  = "beam:transform:org.apache.beam:schemaio_jdbc_read:v1"
+	writeURN = "beam:transform:org.apache.beam:schemaio_jdbc_write:v1"
+
+	// serviceGradleTarget is the Gradle target of the expansion service jar
+	// used if no expansion address is given.
+	serviceGradleTarget = "sdks:java:extensions:schemaio-expansion-service:shadowJar"
+)
+
+// expansionAddr returns the given expansion address, or the address of the
+// released expansion service jar if it is empty.
+func expansionAddr(addr string) string {
+	if addr == "" {
+		return xlangx.AutoJava(serviceGradleTarget)
+	}
+	return addr
+}
+
+// Connection holds the settings for connecting to a database with JDBC.
+type Connection struct {
+	// DriverClassName is the Java class name of the JDBC driver, such as
+	// "oracle.jdbc.OracleDriver".
+	DriverClassName string
+	// URL is the JDBC URL of the database, such as
+	// "jdbc:sqlserver://host:1433;databaseName=db".
+	URL                string
+	Username, Password string
+}
+
+// Read is a cross-language PTransform which reads the rows of a database table
+// or query with JDBC, and returns a PCollection of the given struct type. The
+// fields of the struct must match the columns, in order, as the rows are
+// converted by their schema.
+//
+// Read requires the address for an expansion service for JDBC transforms, the
+// connection settings of the database, and the table to read. The table is
+// only read as a whole if no query is given with the Query option.
+//
+// Example of a partitioned read of a query:
+//
+//	type Order struct {
+//	    ID       int64   `beam:"id"`
+//	    Customer string  `beam:"customer"`
+//	    Total    float64 `beam:"total"`
+//	}
+//
+//	conn := jdbcio.Connection{
+//	    DriverClassName: "org.postgresql.Driver",
+//	    URL:             "jdbc:postgresql://localhost:5432/shop",
+//	    Username:        "user",
+//	    Password:        password,
+//	}
+//	orders := jdbcio.Read(s, expansionAddr, conn, "orders", reflect.TypeOf(Order{}),
+//	    jdbcio.Query("SELECT id, customer, total FROM orders"),
+//	    jdbcio.Partitions("id", 10))
+func Read(s beam.Scope, addr string, conn Connection, table string, outT reflect.Type, opts ...readOption) beam.PCollection {
+	s = s.Scope("jdbcio.Read")
+
+	if outT.Kind() != reflect.Struct {
+		panic("jdbcio.Read requires a struct type for the rows.")
+	}
+	cfg := newConfig(conn)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.ReadQuery == nil {
+		query := "SELECT * FROM " + table
+		cfg.ReadQuery = &query
+	}
+	if (cfg.PartitionColumn == nil) != (cfg.Partitions == nil) {
+		panic("jdbcio.Read requires both a partition column and a number of partitions.")
+	}
+
+	pl := beam.CrossLanguagePayload(jdbcConfigSchema{Location: table, Config: encodeConfig(cfg)})
+	outs := beam.CrossLanguage(s, readURN, pl, expansionAddr(addr), nil, beam.UnnamedOutput(typex.New(outT)))
+	return outs[graph.UnnamedOutputTag]
+}
+
+type readOption func(*config)
+
+// Query is a Read option that reads the rows of the given query, instead of
+// the whole table.
+func Query(query string) readOption {
+	return func(cfg *config) {
+		cfg.ReadQuery = &query
+	}
+}
+
+// Partitions is a Read option that splits reading into the given number of
+// queries, which read ranges of the given numeric column in parallel.
+func Partitions(column string, n int16) readOption {
+	return func(cfg *config) {
+		cfg.PartitionColumn = &column
+		cfg.Partitions = &n
+	}
+}
+
+// FetchSize is a Read option that sets the number of rows fetched from the
+// database at once.
+func FetchSize(n int16) readOption {
+	return func(cfg *config) {
+		cfg.FetchSize = &n
+	}
+}
+
+// OutputParallelization is a Read option that specifies whether the rows are
+// redistributed after reading, so that processing them is not limited to the
+// parallelism of the read.
+//
+// Default: true
+func OutputParallelization(enabled bool) readOption {
+	return func(cfg *config) {
+		cfg.OutputParallelization = &enabled
+	}
+}
+
+// ReadConnectionProperties is a Read option that sets properties of the
+// connection, such as "ssl=true", which are passed to the JDBC driver.
+func ReadConnectionProperties(props ...string) readOption {
+	return func(cfg *config) {
+		setConnectionProperties(cfg, props)
+	}
+}
+
+// ReadConnectionInitSQLs is a Read option that sets SQL statements run on each
+// new connection, such as to set the session time zone.
+func ReadConnectionInitSQLs(sqls ...string) readOption {
+	return func(cfg *config) {
+		cfg.ConnectionInitSQLs = &sqls
+	}
+}
+
+// Write is a cross-language PTransform which writes the elements of a
+// PCollection of structs to a database table with JDBC. The elements are
+// converted to rows by their schema, whose fields set the parameters of the
+// prepared statement in order.
+//
+// Write requires the address for an expansion service for JDBC transforms, the
+// connection settings of the database, and the table to write to. Unless a
+// statement is given with the Statement option, the fields are inserted into
+// the columns of the table in order.
+//
+// Example of Write with a statement:
+//
+//	jdbcio.Write(s, expansionAddr, conn, "orders", orders,
+//	    jdbcio.Statement("INSERT INTO orders (id, customer, total) VALUES (?, ?, ?)"))
+func Write(s beam.Scope, addr string, conn Connection, table string, col beam.PCollection, opts ...writeOption) {
+	s = s.Scope("jdbcio.Write")
+
+	cfg := newConfig(conn)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	pl := beam.CrossLanguagePayload(jdbcConfigSchema{Location: table, Config: encodeConfig(cfg)})
+	beam.CrossLanguage(s, writeURN, pl, expansionAddr(addr), beam.UnnamedInput(col), nil)
+}
+
+type writeOption func(*config)
+
+// Statement is a Write option that sets the prepared statement writing each
+// element, whose parameters are set by the fields of the element in order.
+func Statement(statement string) writeOption {
+	return func(cfg *config) {
+		cfg.WriteStatement = &statement
+	}
+}
+
+// WriteConnectionProperties is a Write option that sets properties of the
+// connection, which are passed to the JDBC driver.
+func WriteConnectionProperties(props ...string) writeOption {
+	return func(cfg *config) {
+		setConnectionProperties(cfg, props)
+	}
+}
+
+// WriteConnectionInitSQLs is a Write option that sets SQL statements run on
+// each new connection.
+func WriteConnectionInitSQLs(sqls ...string) writeOption {
+	return func(cfg *config) {
+		cfg.ConnectionInitSQLs = &sqls
+	}
+}
+
+// setConnectionProperties sets the properties of the connection, in the
+// "key=value;key=value" format of JDBC.
+func setConnectionProperties(cfg *config, props []string) {
+	p := strings.Join(props, ";")
+	cfg.ConnectionProperties = &p
+}
+
+func newConfig(conn Connection) *config {
+	return &config{
+		DriverClassName: conn.DriverClassName,
+		JDBCURL:         conn.URL,
+		Username:        conn.Username,
+		Password:        conn.Password,
+	}
+}
+
+// jdbcConfigSchema should produce a schema matching the expected
+// cross-language payload for schema IO transforms. An example of this on the
+// receiving end can be found in the Java SDK class
+// org.apache.beam.sdk.extensions.schemaio.expansion.ExternalSchemaIOTransformRegistrar.Configuration.
+type jdbcConfigSchema struct {
+	Location   string  `beam:"location"`
+	Config     []byte  `beam:"config"`
+	DataSchema *[]byte `beam:"dataSchema"`
+}
+
+// config is encoded as the Row of the configuration of the JDBC schema IO, and
+// must match the fields of the configuration schema of the Java SDK class
+// org.apache.beam.sdk.io.jdbc.JdbcSchemaIOProvider in order.
+type config struct {
+	DriverClassName       string    `beam:"driverClassName"`
+	JDBCURL               string    `beam:"jdbcUrl"`
+	Username              string    `beam:"username"`
+	Password              string    `beam:"password"`
+	ConnectionProperties  *string   `beam:"connectionProperties"`
+	ConnectionInitSQLs    *[]string `beam:"connectionInitSqls"`
+	ReadQuery             *string   `beam:"readQuery"`
+	WriteStatement        *string   `beam:"writeStatement"`
+	FetchSize             *int16    `beam:"fetchSize"`
+	OutputParallelization *bool     `beam:"outputParallelization"`
+	PartitionColumn       *string   `beam:"partitionColumn"`
+	Partitions            *int16    `beam:"partitions"`
+}
+
+// encodeConfig encodes the configuration as a Row, and panics if it cannot.
+func encodeConfig(cfg *config) []byte {
+	b, err := xlangx.EncodeRow(*cfg)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
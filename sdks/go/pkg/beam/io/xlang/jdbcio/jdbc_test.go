@@ -0,0 +1,99 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jdbcio
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEncodeConfig(t *testing.T) {
+	cfg := newConfig(Connection{
+		DriverClassName: "oracle.jdbc.OracleDriver",
+		URL:             "jdbc:oracle:thin:@localhost:1521/db",
+		Username:        "user",
+		Password:        "pass",
+	})
+	for _, opt := range []readOption{
+		Query("SELECT * FROM t"),
+		Partitions("id", 4),
+		FetchSize(500),
+		OutputParallelization(false),
+		ReadConnectionProperties("ssl=true", "loginTimeout=10"),
+		ReadConnectionInitSQLs("SET TIME ZONE 'UTC'"),
+	} {
+		opt(cfg)
+	}
+
+	dec, err := coder.RowDecoderForStruct(reflect.TypeOf(config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := dec(bytes.NewReader(encodeConfig(cfg)))
+	if err != nil {
+		t.Fatalf("decoding config failed: %v", err)
+	}
+	if diff := cmp.Diff(*cfg, got); diff != "" {
+		t.Errorf("decoded config mismatch (-want +got):\n%v", diff)
+	}
+	if got, want := *got.(config).ConnectionProperties, "ssl=true;loginTimeout=10"; got != want {
+		t.Errorf("connection properties = %q, want %q", got, want)
+	}
+	// The payload must encode, with the data schema unset.
+	beam.CrossLanguagePayload(jdbcConfigSchema{Location: "t", Config: encodeConfig(cfg)})
+}
+
+func TestWriteConfig(t *testing.T) {
+	cfg := newConfig(Connection{DriverClassName: "org.postgresql.Driver", URL: "jdbc:postgresql://localhost/db"})
+	Statement("INSERT INTO t VALUES (?, ?)")(cfg)
+	if cfg.WriteStatement == nil || *cfg.WriteStatement != "INSERT INTO t VALUES (?, ?)" {
+		t.Errorf("Statement did not set the write statement: %v", cfg.WriteStatement)
+	}
+	if cfg.ReadQuery != nil {
+		t.Errorf("Statement set read query %q, want none", *cfg.ReadQuery)
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	type row struct{ ID int64 }
+	tests := []struct {
+		name string
+		outT reflect.Type
+		opts []readOption
+	}{
+		{"NotStruct", reflect.TypeOf(int64(0)), nil},
+		{"PartitionColumnOnly", reflect.TypeOf(row{}), []readOption{func(cfg *config) {
+			column := "id"
+			cfg.PartitionColumn = &column
+		}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Read succeeded, want panic")
+				}
+			}()
+			_, s := beam.NewPipelineWithRoot()
+			Read(s, "localhost:0", Connection{}, "t", test.outT, test.opts...)
+		})
+	}
+}
@@ -26,7 +26,10 @@
 //
 // To use an expansion service, it must be run as a separate process accessible
 // during pipeline construction. The address of that process must be passed to
-// the transforms in this package.
+// the transforms in this package. If the address is empty, the expansion service
+// jar released for the version of the Beam SDK is downloaded, and started and
+// stopped as needed. Expansion addresses of xlangx, such as xlangx.Jar, start
+// other jars or containers likewise.
 //
 // The version of the expansion service should match the version of the Beam SDK
 // being used. For numbered releases of Beam, these expansions services are
@@ -45,10 +48,13 @@ package kafkaio
 // deserializers.
 
 import (
+	"fmt"
 	"reflect"
+	"regexp"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
 )
@@ -56,6 +62,8 @@ import (
 func init() {
 	beam.RegisterType(reflect.TypeOf((*readPayload)(nil)).Elem())
 	beam.RegisterType(reflect.TypeOf((*writePayload)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*Record)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*Header)(nil)).Elem())
 }
 
 type policy string
@@ -81,10 +89,26 @@ const (
 	// be found in Java's KafkaIO documentation.
 	LogAppendTime policy = "LogAppendTime"
 
-	readURN  = "beam:external:java:kafkaio:typedwithoutmetadata:v1"
-	writeURN = "beam:external:java:kafka:write:v1"
+	readURN             = "beam:external:java:kafkaio:typedwithoutmetadata:v1"
+	readWithMetadataURN = "beam:external:java:kafkaio:externalwithmetadata:v1"
+	writeURN            = "beam:external:java:kafka:write:v1"
+
+	groupIDConfig = "group.id"
+
+	// serviceGradleTarget is the Gradle target of the expansion service jar
+	// used if no expansion address is given.
+	serviceGradleTarget = "sdks:java:io:expansion-service:shadowJar"
 )
 
+// expansionAddr returns the given expansion address, or the address of the
+// released expansion service jar if it is empty.
+func expansionAddr(addr string) string {
+	if addr == "" {
+		return xlangx.AutoJava(serviceGradleTarget)
+	}
+	return addr
+}
+
 // Read is a cross-language PTransform which reads from Kafka and returns a
 // KV pair for each item in the specified Kafka topics. By default, this runs
 // as an unbounded transform and outputs keys and values as byte slices.
@@ -92,11 +116,15 @@ const (
 //
 // Read requires the address for an expansion service for Kafka Read transforms,
 // a comma-seperated list of bootstrap server addresses (see the Kafka property
-// "bootstrap.servers" for details), and at least one topic to read from.
+// "bootstrap.servers" for details), and at least one topic to read from, unless
+// the TopicPattern option is given.
+// An empty expansion address starts the released expansion service jar.
 //
 // Read also accepts optional parameters as readOptions. All optional parameters
 // are predefined in this package as functions that return readOption. To set
 // an optional parameter, call the function within Read's function signature.
+// Brokers requiring TLS or SASL are configured with ConsumerConfigs and the
+// properties of a Security.
 //
 // Example of Read with required and optional parameters:
 //
@@ -108,30 +136,73 @@ const (
 func Read(s beam.Scope, addr string, servers string, topics []string, opts ...readOption) beam.PCollection {
 	s = s.Scope("kafkaio.Read")
 
-	if len(topics) == 0 {
-		panic("kafkaio.Read requires at least one topic to read from.")
-	}
+	rcfg := newReadConfig("kafkaio.Read", servers, topics, opts)
+	pl := beam.CrossLanguagePayload(rcfg.pl)
+	outT := beam.UnnamedOutput(typex.NewKV(typex.New(rcfg.key), typex.New(rcfg.val)))
+	out := beam.CrossLanguage(s, readURN, pl, expansionAddr(addr), nil, outT)
+	return out[graph.UnnamedOutputTag]
+}
 
-	rpl := readPayload{
-		ConsumerConfig:    map[string]string{"bootstrap.servers": servers},
-		Topics:            topics,
-		KeyDeserializer:   ByteArrayDeserializer,
-		ValueDeserializer: ByteArrayDeserializer,
-		TimestampPolicy:   string(ProcessingTime),
-	}
-	rcfg := readConfig{
-		pl:  &rpl,
+// ReadWithMetadata is a cross-language PTransform which reads from Kafka like
+// Read, but returns a Record for each item, holding its topic, partition,
+// offset, timestamp and headers along with its key and value.
+//
+// Example of ReadWithMetadata, keeping the offset of each value:
+//
+//   records := kafkaio.ReadWithMetadata(s, expansionAddr, bootstrapServer, []string{topic})
+//   offsets := beam.ParDo(s, func(r kafkaio.Record) (int64, []byte) {
+//       return r.Offset, r.Value
+//   }, records)
+func ReadWithMetadata(s beam.Scope, addr string, servers string, topics []string, opts ...readOption) beam.PCollection {
+	s = s.Scope("kafkaio.ReadWithMetadata")
+
+	rcfg := newReadConfig("kafkaio.ReadWithMetadata", servers, topics, opts)
+	pl := beam.CrossLanguagePayload(rcfg.pl)
+	outT := beam.UnnamedOutput(typex.New(reflect.TypeOf((*Record)(nil)).Elem()))
+	out := beam.CrossLanguage(s, readWithMetadataURN, pl, expansionAddr(addr), nil, outT)
+	return out[graph.UnnamedOutputTag]
+}
+
+// newReadConfig returns the configuration of a read with the given options,
+// and panics if it is invalid.
+func newReadConfig(name, servers string, topics []string, opts []readOption) *readConfig {
+	rcfg := &readConfig{
+		pl: &readPayload{
+			ConsumerConfig:    map[string]string{"bootstrap.servers": servers},
+			Topics:            topics,
+			KeyDeserializer:   ByteArrayDeserializer,
+			ValueDeserializer: ByteArrayDeserializer,
+			TimestampPolicy:   string(ProcessingTime),
+		},
 		key: reflectx.ByteSlice,
 		val: reflectx.ByteSlice,
 	}
 	for _, opt := range opts {
-		opt(&rcfg)
+		opt(rcfg)
 	}
 
-	pl := beam.CrossLanguagePayload(rpl)
-	outT := beam.UnnamedOutput(typex.NewKV(typex.New(rcfg.key), typex.New(rcfg.val)))
-	out := beam.CrossLanguage(s, readURN, pl, addr, nil, outT)
-	return out[graph.UnnamedOutputTag]
+	pl := rcfg.pl
+	switch {
+	case len(pl.Topics) == 0 && pl.TopicPattern == nil:
+		panic(fmt.Sprintf("%v requires at least one topic or a topic pattern to read from.", name))
+	case len(pl.Topics) != 0 && pl.TopicPattern != nil:
+		panic(fmt.Sprintf("%v reads either topics or a topic pattern, not both.", name))
+	}
+	if pl.TopicPattern != nil {
+		if _, err := regexp.Compile(*pl.TopicPattern); err != nil {
+			panic(fmt.Sprintf("%v requires a valid topic pattern: %v", name, err))
+		}
+	}
+	switch policy(pl.TimestampPolicy) {
+	case ProcessingTime, CreateTime, LogAppendTime:
+	default:
+		panic(fmt.Sprintf("%v has unknown timestamp policy %q, want one of %q, %q or %q.",
+			name, pl.TimestampPolicy, ProcessingTime, CreateTime, LogAppendTime))
+	}
+	if pl.CommitOffsetInFinalize && pl.ConsumerConfig[groupIDConfig] == "" {
+		panic(fmt.Sprintf("%v commits offsets in finalize only for a consumer group, set one with GroupID.", name))
+	}
+	return rcfg
 }
 
 type readOption func(*readConfig)
@@ -155,6 +226,28 @@ func ConsumerConfigs(cfgs map[string]string) readOption {
 	}
 }
 
+// GroupID is a Read option that sets the consumer group of the transform,
+// which is the "group.id" property of the Consumer configuration. Committing
+// offsets with CommitOffsetInFinalize requires a consumer group.
+func GroupID(id string) readOption {
+	return func(cfg *readConfig) {
+		cfg.pl.ConsumerConfig[groupIDConfig] = id
+	}
+}
+
+// TopicPattern is a Read option that reads from all topics matching the given
+// regular expression, instead of a list of topics. Topics created while the
+// transform runs are read once they match. Read must not be given any topics
+// with this option.
+//
+// This requires an expansion service whose Kafka read supports topic
+// patterns.
+func TopicPattern(pattern string) readOption {
+	return func(cfg *readConfig) {
+		cfg.pl.TopicPattern = &pattern
+	}
+}
+
 // StartReadTimestamp is a Read option that specifies a start timestamp in
 // milliseconds epoch, so only records after that timestamp will be read.
 //
@@ -187,7 +280,7 @@ func MaxReadSecs(secs int64) readOption {
 }
 
 // CommitOffsetInFinalize is a Read option that specifies whether to commit
-// offsets when finalizing.
+// offsets when finalizing. This requires a consumer group, set by GroupID.
 //
 // Default: false
 func CommitOffsetInFinalize(enabled bool) readOption {
@@ -214,6 +307,7 @@ func TimestampPolicy(name policy) readOption {
 type readPayload struct {
 	ConsumerConfig         map[string]string
 	Topics                 []string
+	TopicPattern           *string
 	KeyDeserializer        string
 	ValueDeserializer      string
 	StartReadTime          *int64
@@ -230,6 +324,7 @@ type readPayload struct {
 // Write requires the address for an expansion service for Kafka Write
 // transforms, a comma-seperated list of bootstrap server addresses (see the
 // Kafka property "bootstrap.servers" for details), and a topic to write to.
+// An empty expansion address starts the released expansion service jar.
 //
 // Write also accepts optional parameters as writeOptions. All optional
 // parameters are predefined in this package as functions that return
@@ -257,7 +352,7 @@ func Write(s beam.Scope, addr, servers, topic string, col beam.PCollection, opts
 	}
 
 	pl := beam.CrossLanguagePayload(wpl)
-	beam.CrossLanguage(s, writeURN, pl, addr, beam.UnnamedInput(col), nil)
+	beam.CrossLanguage(s, writeURN, pl, expansionAddr(addr), beam.UnnamedInput(col), nil)
 }
 
 type writeOption func(*writePayload)
@@ -0,0 +1,128 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+import (
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewReadConfig(t *testing.T) {
+	rcfg := newReadConfig("Read", "server:9092", nil, []readOption{
+		TopicPattern("events-.*"),
+		GroupID("group"),
+		CommitOffsetInFinalize(true),
+		TimestampPolicy(LogAppendTime),
+		ConsumerConfigs(map[string]string{"fetch.min.bytes": "1024"}),
+	})
+
+	pattern := "events-.*"
+	want := &readPayload{
+		ConsumerConfig: map[string]string{
+			"bootstrap.servers": "server:9092",
+			"group.id":          "group",
+			"fetch.min.bytes":   "1024",
+		},
+		TopicPattern:           &pattern,
+		KeyDeserializer:        ByteArrayDeserializer,
+		ValueDeserializer:      ByteArrayDeserializer,
+		CommitOffsetInFinalize: true,
+		TimestampPolicy:        string(LogAppendTime),
+	}
+	if diff := cmp.Diff(want, rcfg.pl); diff != "" {
+		t.Errorf("newReadConfig payload mismatch (-want +got):\n%v", diff)
+	}
+	// The payload must encode, with the optional fields unset.
+	beam.CrossLanguagePayload(rcfg.pl)
+}
+
+func TestNewReadConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		topics []string
+		opts   []readOption
+	}{
+		{"NoTopics", nil, nil},
+		{"TopicsAndPattern", []string{"topic"}, []readOption{TopicPattern("t.*")}},
+		{"BadPattern", nil, []readOption{TopicPattern("t(")}},
+		{"BadPolicy", []string{"topic"}, []readOption{TimestampPolicy("EventTime")}},
+		{"CommitWithoutGroup", []string{"topic"}, []readOption{CommitOffsetInFinalize(true)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("newReadConfig succeeded, want panic")
+				}
+			}()
+			newReadConfig("Read", "server:9092", test.topics, test.opts)
+		})
+	}
+}
+
+func TestSecurityConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		sec  Security
+		want map[string]string
+	}{
+		{
+			"TLS",
+			Security{TruststoreLocation: "/certs/truststore.jks", TruststorePassword: "secret"},
+			map[string]string{
+				"security.protocol":       "SSL",
+				"ssl.truststore.location": "/certs/truststore.jks",
+				"ssl.truststore.password": "secret",
+			},
+		},
+		{
+			"SASLScram",
+			Security{SASLMechanism: SASLScramSHA512, Username: "user", Password: `pa"ss`},
+			map[string]string{
+				"security.protocol": "SASL_SSL",
+				"sasl.mechanism":    "SCRAM-SHA-512",
+				"sasl.jaas.config":  `org.apache.kafka.common.security.scram.ScramLoginModule required username="user" password="pa\"ss";`,
+			},
+		},
+		{
+			"SASLPlaintext",
+			Security{SASLMechanism: SASLPlain, Username: "user", Password: "pass", Plaintext: true},
+			map[string]string{
+				"security.protocol": "SASL_PLAINTEXT",
+				"sasl.mechanism":    "PLAIN",
+				"sasl.jaas.config":  `org.apache.kafka.common.security.plain.PlainLoginModule required username="user" password="pass";`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, test.sec.Config()); diff != "" {
+				t.Errorf("Config mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestSecurityConfig_BadMechanism(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Config succeeded, want panic")
+		}
+	}()
+	Security{SASLMechanism: "GSSAPI"}.Config()
+}
@@ -0,0 +1,38 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+// Record is a Kafka record with its metadata, as returned by ReadWithMetadata.
+// Its schema matches the rows of the Java SDK's Kafka read with metadata.
+type Record struct {
+	Topic     string `beam:"topic"`
+	Partition int32  `beam:"partition"`
+	Offset    int64  `beam:"offset"`
+	// Timestamp is the timestamp of the record in milliseconds since the
+	// epoch, whose kind is given by the timestamp type.
+	Timestamp         int64    `beam:"timestamp"`
+	TimestampTypeID   int32    `beam:"timestampTypeId"`
+	TimestampTypeName string   `beam:"timestampTypeName"`
+	Key               []byte   `beam:"key"`
+	Value             []byte   `beam:"value"`
+	Headers           []Header `beam:"headers"`
+}
+
+// Header is a header of a Kafka record.
+type Header struct {
+	Key   string `beam:"key"`
+	Value []byte `beam:"value"`
+}
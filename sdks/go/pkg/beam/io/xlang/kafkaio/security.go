@@ -0,0 +1,102 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SASL mechanisms supported by Security.
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// Security holds the settings for connecting to Kafka brokers over TLS and
+// authenticating with SASL. Its properties are added to the configuration of
+// reads or writes with ConsumerConfigs or ProducerConfigs:
+//
+//	sec := kafkaio.Security{
+//	    SASLMechanism: kafkaio.SASLScramSHA512,
+//	    Username:      "user",
+//	    Password:      password,
+//	}
+//	pcol := kafkaio.Read(s, expansionAddr, bootstrapServer, []string{topic},
+//	    kafkaio.ConsumerConfigs(sec.Config()))
+type Security struct {
+	// SASLMechanism is the mechanism to authenticate with, one of SASLPlain,
+	// SASLScramSHA256 or SASLScramSHA512. Clients do not authenticate if it
+	// is empty.
+	SASLMechanism string
+	// Username and Password are the credentials to authenticate with.
+	Username, Password string
+
+	// Plaintext disables TLS, which is used by default.
+	Plaintext bool
+	// TruststoreLocation and TruststorePassword are the truststore of the
+	// certificates of the brokers, if they are not trusted by default.
+	TruststoreLocation, TruststorePassword string
+	// KeystoreLocation, KeystorePassword and KeyPassword are the keystore and
+	// key of the client, for brokers authenticating clients with TLS.
+	KeystoreLocation, KeystorePassword, KeyPassword string
+}
+
+// Config returns the Kafka client properties of the settings. It panics if
+// the SASL mechanism is not supported.
+func (s Security) Config() map[string]string {
+	cfg := make(map[string]string)
+	protocol := "SSL"
+	if s.Plaintext {
+		protocol = "PLAINTEXT"
+	}
+	if s.SASLMechanism != "" {
+		var module string
+		switch s.SASLMechanism {
+		case SASLPlain:
+			module = "org.apache.kafka.common.security.plain.PlainLoginModule"
+		case SASLScramSHA256, SASLScramSHA512:
+			module = "org.apache.kafka.common.security.scram.ScramLoginModule"
+		default:
+			panic(fmt.Sprintf("kafkaio.Security has unsupported SASL mechanism %q, want one of %q, %q or %q.",
+				s.SASLMechanism, SASLPlain, SASLScramSHA256, SASLScramSHA512))
+		}
+		protocol = "SASL_" + protocol
+		cfg["sasl.mechanism"] = s.SASLMechanism
+		cfg["sasl.jaas.config"] = fmt.Sprintf("%v required username=%v password=%v;",
+			module, jaasQuote(s.Username), jaasQuote(s.Password))
+	}
+	cfg["security.protocol"] = protocol
+
+	set := func(k, v string) {
+		if v != "" {
+			cfg[k] = v
+		}
+	}
+	set("ssl.truststore.location", s.TruststoreLocation)
+	set("ssl.truststore.password", s.TruststorePassword)
+	set("ssl.keystore.location", s.KeystoreLocation)
+	set("ssl.keystore.password", s.KeystorePassword)
+	set("ssl.key.password", s.KeyPassword)
+	return cfg
+}
+
+// jaasQuote quotes a value of a JAAS configuration.
+func jaasQuote(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+}
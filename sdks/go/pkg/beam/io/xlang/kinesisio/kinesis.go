@@ -0,0 +1,435 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kinesisio contains cross-language functionality for reading and
+// writing Amazon Kinesis Data Streams (https://aws.amazon.com/kinesis/). These
+// transforms only work on runners that support cross-language transforms.
+//
+// # Setup
+//
+// Transforms specified here are cross-language transforms implemented in a
+// different SDK (listed below). During pipeline construction, the Go SDK will
+// need to connect to an expansion service containing information on these
+// transforms in their native SDK.
+//
+// To use an expansion service, it must be run as a separate process accessible
+// during pipeline construction. The address of that process must be passed to
+// the transforms in this package. If the address is empty, the expansion service
+// jar released for the version of the Beam SDK is downloaded, and started and
+// stopped as needed.
+//
+// Current supported SDKs, including expansion service modules and reference
+// documentation:
+// * Java
+//   - Vendored Module: beam-sdks-java-io-kinesis-expansion-service
+//   - Run via Gradle: ./gradlew :sdks:java:io:kinesis:expansion-service:runExpansionService
+//   - Reference Class: org.apache.beam.sdk.io.kinesis.KinesisIO
+package kinesisio
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*readPayload)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*writePayload)(nil)).Elem())
+}
+
+type position string
+type watermarkPolicy string
+
+const (
+	// Latest is an initial position that starts reading after the most recent
+	// records of the stream.
+	Latest position = "LATEST"
+	// TrimHorizon is an initial position that starts reading at the oldest
+	// records of the stream that have not been trimmed yet.
+	TrimHorizon position = "TRIM_HORIZON"
+	// atTimestamp is the initial position set by InitialTimestamp.
+	atTimestamp position = "AT_TIMESTAMP"
+
+	// ArrivalTime is a watermark policy based on the approximate arrival
+	// timestamps of the records in Kinesis. The watermark advances with
+	// processing time once no records arrive for the duration set by
+	// WatermarkIdleDurationThreshold.
+	ArrivalTime watermarkPolicy = "ARRIVAL_TIME"
+	// ProcessingTime is a watermark policy that assigns processing time as the
+	// watermark.
+	ProcessingTime watermarkPolicy = "PROCESSING_TIME"
+
+	readURN  = "beam:external:java:kinesis:read_data:v1"
+	writeURN = "beam:external:java:kinesis:write:v1"
+
+	// serviceGradleTarget is the Gradle target of the expansion service jar
+	// used if no expansion address is given.
+	serviceGradleTarget = "sdks:java:io:kinesis:expansion-service:shadowJar"
+
+	// The properties of the Kinesis Producer Library configuring aggregation.
+	aggregationEnabled  = "AggregationEnabled"
+	aggregationMaxCount = "AggregationMaxCount"
+	aggregationMaxSize  = "AggregationMaxSize"
+)
+
+// expansionAddr returns the given expansion address, or the address of the
+// released expansion service jar if it is empty.
+func expansionAddr(addr string) string {
+	if addr == "" {
+		return xlangx.AutoJava(serviceGradleTarget)
+	}
+	return addr
+}
+
+// Connection holds the settings for connecting to Kinesis.
+type Connection struct {
+	// AccessKey and SecretKey are the credentials of the AWS account.
+	AccessKey, SecretKey string
+	// Region is the AWS region of the stream, such as "us-east-1".
+	Region string
+	// ServiceEndpoint overrides the endpoint of Kinesis, such as to use a
+	// local emulator.
+	ServiceEndpoint string
+	// SkipCertificateVerification disables verifying the certificate of the
+	// endpoint. It should only be set for testing.
+	SkipCertificateVerification bool
+}
+
+// Read is a cross-language PTransform which reads the records of a Kinesis
+// stream and returns a PCollection of their data as byte slices. By default,
+// this runs as an unbounded transform starting at the latest records, whose
+// watermark is based on the arrival times of the records.
+//
+// Read requires the address for an expansion service for Kinesis transforms,
+// the name of the stream, and the settings for connecting to Kinesis. It also
+// accepts optional parameters as readOptions, such as EnhancedFanOut to
+// consume the stream with dedicated throughput.
+//
+// Example of Read with required and optional parameters:
+//
+//	conn := kinesisio.Connection{AccessKey: key, SecretKey: secret, Region: "us-east-1"}
+//	data := kinesisio.Read(s, expansionAddr, "stream", conn,
+//	    kinesisio.InitialPosition(kinesisio.TrimHorizon),
+//	    kinesisio.WatermarkIdleDurationThreshold(time.Minute))
+func Read(s beam.Scope, addr, stream string, conn Connection, opts ...readOption) beam.PCollection {
+	s = s.Scope("kinesisio.Read")
+
+	pl := newReadPayload(stream, conn, opts)
+	outT := beam.UnnamedOutput(typex.New(reflectx.ByteSlice))
+	out := beam.CrossLanguage(s, readURN, beam.CrossLanguagePayload(pl), expansionAddr(addr), nil, outT)
+	return out[graph.UnnamedOutputTag]
+}
+
+// newReadPayload returns the payload of a read with the given options, and
+// panics if it is invalid.
+func newReadPayload(stream string, conn Connection, opts []readOption) *readPayload {
+	checkConnection("kinesisio.Read", stream, conn)
+	pl := &readPayload{
+		StreamName:              stream,
+		AwsAccessKey:            conn.AccessKey,
+		AwsSecretKey:            conn.SecretKey,
+		Region:                  conn.Region,
+		ServiceEndpoint:         optional(conn.ServiceEndpoint),
+		VerifyCertificate:       verifyCertificate(conn),
+		InitialPositionInStream: string(Latest),
+	}
+	for _, opt := range opts {
+		opt(pl)
+	}
+
+	switch position(pl.InitialPositionInStream) {
+	case Latest, TrimHorizon, atTimestamp:
+	default:
+		panic(fmt.Sprintf("kinesisio.Read has unknown initial position %q, want %q, %q, or a timestamp set by InitialTimestamp.",
+			pl.InitialPositionInStream, Latest, TrimHorizon))
+	}
+	if pl.WatermarkPolicy != nil {
+		switch p := watermarkPolicy(*pl.WatermarkPolicy); p {
+		case ArrivalTime:
+		case ProcessingTime:
+			if pl.WatermarkIdleDurationThreshold != nil {
+				panic(fmt.Sprintf("kinesisio.Read uses an idle duration threshold only with the %q watermark policy.", ArrivalTime))
+			}
+		default:
+			panic(fmt.Sprintf("kinesisio.Read has unknown watermark policy %q, want %q or %q.", p, ArrivalTime, ProcessingTime))
+		}
+	}
+	return pl
+}
+
+// checkConnection panics if the stream or region of a transform is missing.
+func checkConnection(name, stream string, conn Connection) {
+	if stream == "" {
+		panic(fmt.Sprintf("%v requires the name of a stream.", name))
+	}
+	if conn.Region == "" {
+		panic(fmt.Sprintf("%v requires the AWS region of the stream.", name))
+	}
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func verifyCertificate(conn Connection) *bool {
+	if !conn.SkipCertificateVerification {
+		return nil
+	}
+	verify := false
+	return &verify
+}
+
+func millis(d time.Duration) *int64 {
+	ms := d.Milliseconds()
+	return &ms
+}
+
+type readOption func(*readPayload)
+
+// InitialPosition is a Read option that specifies where in the stream to start
+// reading. Must be one of the predefined constant positions in this package.
+//
+// Default: kinesisio.Latest
+func InitialPosition(p position) readOption {
+	return func(pl *readPayload) {
+		pl.InitialPositionInStream = string(p)
+		pl.InitialTimestampInStream = nil
+	}
+}
+
+// InitialTimestamp is a Read option that starts reading at the records that
+// arrived at or after the given time.
+func InitialTimestamp(ts time.Time) readOption {
+	return func(pl *readPayload) {
+		ms := ts.UnixNano() / int64(time.Millisecond)
+		pl.InitialPositionInStream = string(atTimestamp)
+		pl.InitialTimestampInStream = &ms
+	}
+}
+
+// EnhancedFanOut is a Read option that consumes the stream with the given
+// registered stream consumer, which has dedicated read throughput pushed to
+// it, rather than polling the shards. The consumer is identified by its ARN,
+// such as "arn:aws:kinesis:us-east-1:123456789012:stream/s/consumer/c:1".
+//
+// This requires an expansion service whose Kinesis read supports enhanced
+// fan-out.
+func EnhancedFanOut(consumerARN string) readOption {
+	return func(pl *readPayload) {
+		pl.ConsumerArn = &consumerARN
+	}
+}
+
+// WatermarkPolicy is a Read option that specifies how the watermark is
+// computed. Must be one of the predefined constant watermark policies in this
+// package.
+//
+// Default: kinesisio.ArrivalTime
+func WatermarkPolicy(p watermarkPolicy) readOption {
+	return func(pl *readPayload) {
+		s := string(p)
+		pl.WatermarkPolicy = &s
+	}
+}
+
+// WatermarkIdleDurationThreshold is a Read option that specifies how long no
+// records may arrive before the watermark of the ArrivalTime policy advances
+// with processing time.
+func WatermarkIdleDurationThreshold(d time.Duration) readOption {
+	return func(pl *readPayload) {
+		pl.WatermarkIdleDurationThreshold = millis(d)
+	}
+}
+
+// MaxNumRecords is a Read option that specifies the maximum amount of records
+// to be read. Setting this will cause the Read to execute as a bounded
+// transform. Useful for tests and demo applications.
+func MaxNumRecords(num int64) readOption {
+	return func(pl *readPayload) {
+		pl.MaxNumRecords = &num
+	}
+}
+
+// MaxReadTime is a Read option that specifies the maximum amount of time the
+// transform executes. Setting this will cause the Read to execute as a bounded
+// transform. Useful for tests and demo applications.
+func MaxReadTime(d time.Duration) readOption {
+	return func(pl *readPayload) {
+		pl.MaxReadTime = millis(d)
+	}
+}
+
+// RequestRecordsLimit is a Read option that specifies the maximum number of
+// records fetched from a shard by each request.
+func RequestRecordsLimit(n int32) readOption {
+	return func(pl *readPayload) {
+		pl.RequestRecordsLimit = &n
+	}
+}
+
+// UpToDateThreshold is a Read option that specifies how far behind the latest
+// records of a shard the reader may be to be considered up to date, such that
+// the shards are not read faster than records arrive.
+func UpToDateThreshold(d time.Duration) readOption {
+	return func(pl *readPayload) {
+		pl.UpToDateThreshold = millis(d)
+	}
+}
+
+// MaxCapacityPerShard is a Read option that specifies the maximum number of
+// records buffered per shard.
+func MaxCapacityPerShard(n int32) readOption {
+	return func(pl *readPayload) {
+		pl.MaxCapacityPerShard = &n
+	}
+}
+
+// FixedDelayRateLimit is a Read option that waits the given delay between the
+// requests to each shard, such as to share the read limits of a stream with
+// other consumers.
+func FixedDelayRateLimit(d time.Duration) readOption {
+	return func(pl *readPayload) {
+		pl.RateLimit = millis(d)
+	}
+}
+
+// readPayload should produce a schema matching the expected cross-language
+// payload for Kinesis reads. An example of this on the receiving end can be
+// found in the Java SDK class
+// org.apache.beam.sdk.io.kinesis.KinesisTransformRegistrar.ReadDataBuilder.Configuration.
+// Durations and timestamps are in milliseconds.
+type readPayload struct {
+	StreamName                     string
+	AwsAccessKey                   string
+	AwsSecretKey                   string
+	Region                         string
+	ServiceEndpoint                *string
+	VerifyCertificate              *bool
+	MaxNumRecords                  *int64
+	MaxReadTime                    *int64
+	InitialPositionInStream        string
+	InitialTimestampInStream       *int64
+	RequestRecordsLimit            *int32
+	UpToDateThreshold              *int64
+	MaxCapacityPerShard            *int32
+	WatermarkPolicy                *string
+	WatermarkIdleDurationThreshold *int64
+	RateLimit                      *int64
+	ConsumerArn                    *string
+}
+
+// Write is a cross-language PTransform which writes a PCollection of byte
+// slices to a Kinesis stream, as the data of its records. The records are
+// written with the Kinesis Producer Library, which aggregates them into fewer
+// Kinesis records unless disabled with ProducerAggregation.
+//
+// Write requires the address for an expansion service for Kinesis transforms,
+// the name of the stream, the settings for connecting to Kinesis, and the
+// partition key of the records, which determines their shard.
+//
+// Example of Write with required and optional parameters:
+//
+//	kinesisio.Write(s, expansionAddr, "stream", conn, "key", data,
+//	    kinesisio.ProducerAggregation(true, 100, 0))
+func Write(s beam.Scope, addr, stream string, conn Connection, partitionKey string, col beam.PCollection, opts ...writeOption) {
+	s = s.Scope("kinesisio.Write")
+
+	if t := col.Type().Type(); t != reflectx.ByteSlice {
+		panic(fmt.Sprintf("kinesisio.Write requires a PCollection of []byte, got %v.", t))
+	}
+	pl := newWritePayload(stream, conn, partitionKey, opts)
+	beam.CrossLanguage(s, writeURN, beam.CrossLanguagePayload(pl), expansionAddr(addr), beam.UnnamedInput(col), nil)
+}
+
+// newWritePayload returns the payload of a write with the given options, and
+// panics if it is invalid.
+func newWritePayload(stream string, conn Connection, partitionKey string, opts []writeOption) *writePayload {
+	checkConnection("kinesisio.Write", stream, conn)
+	if partitionKey == "" {
+		panic("kinesisio.Write requires a partition key.")
+	}
+	pl := &writePayload{
+		StreamName:         stream,
+		AwsAccessKey:       conn.AccessKey,
+		AwsSecretKey:       conn.SecretKey,
+		Region:             conn.Region,
+		ServiceEndpoint:    optional(conn.ServiceEndpoint),
+		VerifyCertificate:  verifyCertificate(conn),
+		PartitionKey:       partitionKey,
+		ProducerProperties: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(pl)
+	}
+	return pl
+}
+
+type writeOption func(*writePayload)
+
+// ProducerProperties is a Write option that adds properties of the Kinesis
+// Producer Library, such as "RecordMaxBufferedTime". Each usage of this adds
+// the given elements to the existing map without removing existing elements.
+func ProducerProperties(props map[string]string) writeOption {
+	return func(pl *writePayload) {
+		for k, v := range props {
+			pl.ProducerProperties[k] = v
+		}
+	}
+}
+
+// ProducerAggregation is a Write option that specifies whether the producer
+// aggregates several records into each Kinesis record, which increases the
+// throughput of a shard, and the maximum number of records and size in bytes
+// of an aggregated record. Limits of zero keep the defaults of the Kinesis
+// Producer Library. Consumers of aggregated records must deaggregate them,
+// which Read does.
+//
+// Default: true
+func ProducerAggregation(enabled bool, maxCount, maxBytes int64) writeOption {
+	return func(pl *writePayload) {
+		pl.ProducerProperties[aggregationEnabled] = strconv.FormatBool(enabled)
+		if maxCount > 0 {
+			pl.ProducerProperties[aggregationMaxCount] = strconv.FormatInt(maxCount, 10)
+		}
+		if maxBytes > 0 {
+			pl.ProducerProperties[aggregationMaxSize] = strconv.FormatInt(maxBytes, 10)
+		}
+	}
+}
+
+// writePayload should produce a schema matching the expected cross-language
+// payload for Kinesis writes. An example of this on the receiving end can be
+// found in the Java SDK class
+// org.apache.beam.sdk.io.kinesis.KinesisTransformRegistrar.WriteBuilder.Configuration.
+type writePayload struct {
+	StreamName         string
+	AwsAccessKey       string
+	AwsSecretKey       string
+	Region             string
+	ServiceEndpoint    *string
+	VerifyCertificate  *bool
+	PartitionKey       string
+	ProducerProperties map[string]string
+}
@@ -0,0 +1,138 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kinesisio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/google/go-cmp/cmp"
+)
+
+var conn = Connection{AccessKey: "key", SecretKey: "secret", Region: "us-east-1"}
+
+func TestNewReadPayload(t *testing.T) {
+	ts := time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC)
+	got := newReadPayload("stream", conn, []readOption{
+		InitialTimestamp(ts),
+		EnhancedFanOut("arn:consumer"),
+		WatermarkPolicy(ArrivalTime),
+		WatermarkIdleDurationThreshold(time.Minute),
+		MaxReadTime(10 * time.Second),
+		RequestRecordsLimit(500),
+	})
+
+	ms, idle, maxRead, limit := ts.UnixNano()/int64(time.Millisecond), int64(60000), int64(10000), int32(500)
+	policy, arn := "ARRIVAL_TIME", "arn:consumer"
+	want := &readPayload{
+		StreamName:                     "stream",
+		AwsAccessKey:                   "key",
+		AwsSecretKey:                   "secret",
+		Region:                         "us-east-1",
+		MaxReadTime:                    &maxRead,
+		InitialPositionInStream:        "AT_TIMESTAMP",
+		InitialTimestampInStream:       &ms,
+		RequestRecordsLimit:            &limit,
+		WatermarkPolicy:                &policy,
+		WatermarkIdleDurationThreshold: &idle,
+		ConsumerArn:                    &arn,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("newReadPayload mismatch (-want +got):\n%v", diff)
+	}
+	// The payload must encode, with the optional fields unset.
+	beam.CrossLanguagePayload(newReadPayload("stream", conn, nil))
+}
+
+func TestNewReadPayload_Connection(t *testing.T) {
+	c := conn
+	c.ServiceEndpoint = "http://localhost:4566"
+	c.SkipCertificateVerification = true
+	got := newReadPayload("stream", c, []readOption{InitialTimestamp(time.Now()), InitialPosition(TrimHorizon)})
+	if got.ServiceEndpoint == nil || *got.ServiceEndpoint != c.ServiceEndpoint {
+		t.Errorf("service endpoint = %v, want %q", got.ServiceEndpoint, c.ServiceEndpoint)
+	}
+	if got.VerifyCertificate == nil || *got.VerifyCertificate {
+		t.Errorf("verify certificate = %v, want false", got.VerifyCertificate)
+	}
+	// The last initial position wins, without a timestamp.
+	if got.InitialPositionInStream != string(TrimHorizon) || got.InitialTimestampInStream != nil {
+		t.Errorf("initial position = %q at %v, want %q", got.InitialPositionInStream, got.InitialTimestampInStream, TrimHorizon)
+	}
+}
+
+func TestNewReadPayload_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		conn   Connection
+		opts   []readOption
+	}{
+		{"NoStream", "", conn, nil},
+		{"NoRegion", "stream", Connection{AccessKey: "key", SecretKey: "secret"}, nil},
+		{"UnknownPosition", "stream", conn, []readOption{InitialPosition("EARLIEST")}},
+		{"UnknownWatermarkPolicy", "stream", conn, []readOption{WatermarkPolicy("EVENT_TIME")}},
+		{"IdleProcessingTime", "stream", conn, []readOption{WatermarkPolicy(ProcessingTime), WatermarkIdleDurationThreshold(time.Minute)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("newReadPayload succeeded, want panic")
+				}
+			}()
+			newReadPayload(test.stream, test.conn, test.opts)
+		})
+	}
+}
+
+func TestNewWritePayload(t *testing.T) {
+	got := newWritePayload("stream", conn, "key", []writeOption{
+		ProducerProperties(map[string]string{"RecordMaxBufferedTime": "100"}),
+		ProducerAggregation(true, 100, 0),
+	})
+	want := &writePayload{
+		StreamName:   "stream",
+		AwsAccessKey: "key",
+		AwsSecretKey: "secret",
+		Region:       "us-east-1",
+		PartitionKey: "key",
+		ProducerProperties: map[string]string{
+			"RecordMaxBufferedTime": "100",
+			"AggregationEnabled":    "true",
+			"AggregationMaxCount":   "100",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("newWritePayload mismatch (-want +got):\n%v", diff)
+	}
+	beam.CrossLanguagePayload(got)
+
+	disabled := newWritePayload("stream", conn, "key", []writeOption{ProducerAggregation(false, 0, 0)})
+	if diff := cmp.Diff(map[string]string{"AggregationEnabled": "false"}, disabled.ProducerProperties); diff != "" {
+		t.Errorf("producer properties without aggregation mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestNewWritePayload_NoPartitionKey(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("newWritePayload succeeded without a partition key, want panic")
+		}
+	}()
+	newWritePayload("stream", conn, "", nil)
+}
@@ -0,0 +1,66 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"sort"
+)
+
+type contextKey string
+
+const fieldsKey contextKey = "beam:log_fields"
+
+// Field is a structured key/value pair attached to log messages.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// WithField returns a context whose log messages carry the key/value field,
+// in addition to the fields of the parent context. The field replaces any
+// parent field with the same key. Loggers that support structured logging,
+// such as the worker harness, attach the fields to the log entries, so
+// they can be filtered on by the logging backend. Other loggers ignore them.
+//
+// Values are typically strings, numbers or booleans. Other values are
+// formatted with fmt.Sprint by loggers that need it.
+func WithField(ctx context.Context, key string, value interface{}) context.Context {
+	parent, _ := ctx.Value(fieldsKey).(map[string]interface{})
+	fields := make(map[string]interface{}, len(parent)+1)
+	for k, v := range parent {
+		fields[k] = v
+	}
+	fields[key] = value
+	return context.WithValue(ctx, fieldsKey, fields)
+}
+
+// Fields returns the fields of log messages with the given context, sorted
+// by key.
+func Fields(ctx context.Context) []Field {
+	fields, _ := ctx.Value(fieldsKey).(map[string]interface{})
+	if len(fields) == 0 {
+		return nil
+	}
+	ret := make([]Field, 0, len(fields))
+	for k, v := range fields {
+		ret = append(ret, Field{Key: k, Value: v})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Key < ret[j].Key
+	})
+	return ret
+}
@@ -70,7 +70,8 @@ func NewDistribution(namespace, name string) Distribution {
 }
 
 // Gauge is a metric that can have its new value set, and is aggregated by taking
-// the last reported value.
+// the last reported value. Values are reported with the time they were set,
+// which suits values sampled over time, such as queue depths or cache sizes.
 //
 // Gauge are safe to use in multiple bundles simultaneously, but
 // not generally threadsafe. Your DoFn needs to manage the thread
@@ -88,3 +89,83 @@ func (c Gauge) Set(ctx context.Context, v int64) {
 func NewGauge(namespace, name string) Gauge {
 	return Gauge{metrics.NewGauge(namespace, name)}
 }
+
+// StringSet is a metric that reports the set of distinct strings added to it,
+// such as the tables or files touched by a transform, and is aggregated by
+// taking the union. Sets are bounded to 1 MiB of strings, beyond which
+// further strings are dropped.
+//
+// StringSets are safe to use in multiple bundles simultaneously, but
+// not generally threadsafe. Your DoFn needs to manage the thread
+// safety of Beam metrics for any additional concurrency it uses.
+type StringSet struct {
+	*metrics.StringSet
+}
+
+// Add adds the given string to this set.
+func (c StringSet) Add(ctx context.Context, v string) {
+	c.StringSet.Add(ctx, v)
+}
+
+// NewStringSet returns the StringSet with the given namespace and name.
+func NewStringSet(namespace, name string) StringSet {
+	return StringSet{metrics.NewStringSet(namespace, name)}
+}
+
+// Histogram is a metric that counts the reported values in buckets, which
+// allows estimating percentiles of them, such as those of request latencies.
+//
+// Histograms are safe to use in multiple bundles simultaneously, but
+// not generally threadsafe. Your DoFn needs to manage the thread
+// safety of Beam metrics for any additional concurrency it uses.
+type Histogram struct {
+	*metrics.Histogram
+}
+
+// Update adds an observation to this histogram.
+func (c Histogram) Update(ctx context.Context, v int64) {
+	c.Histogram.Update(ctx, v)
+}
+
+// HistogramBuckets are the buckets of a Histogram.
+type HistogramBuckets = metrics.Buckets
+
+// LinearBuckets returns n buckets of the given width, the first starting at start.
+func LinearBuckets(start, width float64, n int) HistogramBuckets {
+	return metrics.LinearBuckets(start, width, n)
+}
+
+// ExponentialBuckets returns n buckets, the first starting at start > 0, and
+// whose bounds each grow by the given factor > 1.
+func ExponentialBuckets(start, factor float64, n int) HistogramBuckets {
+	return metrics.ExponentialBuckets(start, factor, n)
+}
+
+// NewHistogram returns the Histogram with the given namespace, name and
+// buckets. It panics if the buckets are invalid.
+func NewHistogram(namespace, name string, buckets HistogramBuckets) Histogram {
+	return Histogram{metrics.NewHistogram(namespace, name, buckets)}
+}
+
+// BoundedTrie is a metric that reports the set of paths added to it, such as
+// the directories and files, or the datasets and tables, touched by a
+// transform, and is aggregated by taking the union. Beyond 100 paths, the
+// largest subtrees are truncated into their prefix, which keeps the memory
+// of connectors touching many paths bounded.
+//
+// BoundedTries are safe to use in multiple bundles simultaneously, but
+// not generally threadsafe. Your DoFn needs to manage the thread
+// safety of Beam metrics for any additional concurrency it uses.
+type BoundedTrie struct {
+	*metrics.BoundedTrie
+}
+
+// Add adds the path with the given segments to this trie.
+func (c BoundedTrie) Add(ctx context.Context, segments ...string) {
+	c.BoundedTrie.Add(ctx, segments...)
+}
+
+// NewBoundedTrie returns the BoundedTrie with the given namespace and name.
+func NewBoundedTrie(namespace, name string) BoundedTrie {
+	return BoundedTrie{metrics.NewBoundedTrie(namespace, name)}
+}
@@ -97,6 +97,14 @@ const (
 	MonitoringInfoSpecs_DATA_CHANNEL_READ_INDEX MonitoringInfoSpecs_Enum = 18
 	MonitoringInfoSpecs_API_REQUEST_COUNT       MonitoringInfoSpecs_Enum = 19
 	MonitoringInfoSpecs_API_REQUEST_LATENCIES   MonitoringInfoSpecs_Enum = 20
+	// The resource usage of the SDK harness process, which applies to the
+	// whole worker rather than a ptransform, and is reported in
+	// HarnessMonitoringInfos.
+	MonitoringInfoSpecs_WORKER_PROCESS_CPU_MSECS     MonitoringInfoSpecs_Enum = 21
+	MonitoringInfoSpecs_WORKER_RESIDENT_MEMORY_BYTES MonitoringInfoSpecs_Enum = 22
+	MonitoringInfoSpecs_WORKER_HEAP_BYTES            MonitoringInfoSpecs_Enum = 23
+	MonitoringInfoSpecs_WORKER_GC_COUNT              MonitoringInfoSpecs_Enum = 24
+	MonitoringInfoSpecs_WORKER_GC_PAUSE_MSECS        MonitoringInfoSpecs_Enum = 25
 )
 
 // Enum value maps for MonitoringInfoSpecs_Enum.
@@ -123,29 +131,39 @@ var (
 		18: "DATA_CHANNEL_READ_INDEX",
 		19: "API_REQUEST_COUNT",
 		20: "API_REQUEST_LATENCIES",
+		21: "WORKER_PROCESS_CPU_MSECS",
+		22: "WORKER_RESIDENT_MEMORY_BYTES",
+		23: "WORKER_HEAP_BYTES",
+		24: "WORKER_GC_COUNT",
+		25: "WORKER_GC_PAUSE_MSECS",
 	}
 	MonitoringInfoSpecs_Enum_value = map[string]int32{
-		"USER_SUM_INT64":           0,
-		"USER_SUM_DOUBLE":          1,
-		"USER_DISTRIBUTION_INT64":  2,
-		"USER_DISTRIBUTION_DOUBLE": 3,
-		"USER_LATEST_INT64":        4,
-		"USER_LATEST_DOUBLE":       5,
-		"USER_TOP_N_INT64":         6,
-		"USER_TOP_N_DOUBLE":        7,
-		"USER_BOTTOM_N_INT64":      8,
-		"USER_BOTTOM_N_DOUBLE":     9,
-		"ELEMENT_COUNT":            10,
-		"SAMPLED_BYTE_SIZE":        11,
-		"START_BUNDLE_MSECS":       12,
-		"PROCESS_BUNDLE_MSECS":     13,
-		"FINISH_BUNDLE_MSECS":      14,
-		"TOTAL_MSECS":              15,
-		"WORK_REMAINING":           16,
-		"WORK_COMPLETED":           17,
-		"DATA_CHANNEL_READ_INDEX":  18,
-		"API_REQUEST_COUNT":        19,
-		"API_REQUEST_LATENCIES":    20,
+		"USER_SUM_INT64":               0,
+		"USER_SUM_DOUBLE":              1,
+		"USER_DISTRIBUTION_INT64":      2,
+		"USER_DISTRIBUTION_DOUBLE":     3,
+		"USER_LATEST_INT64":            4,
+		"USER_LATEST_DOUBLE":           5,
+		"USER_TOP_N_INT64":             6,
+		"USER_TOP_N_DOUBLE":            7,
+		"USER_BOTTOM_N_INT64":          8,
+		"USER_BOTTOM_N_DOUBLE":         9,
+		"ELEMENT_COUNT":                10,
+		"SAMPLED_BYTE_SIZE":            11,
+		"START_BUNDLE_MSECS":           12,
+		"PROCESS_BUNDLE_MSECS":         13,
+		"FINISH_BUNDLE_MSECS":          14,
+		"TOTAL_MSECS":                  15,
+		"WORK_REMAINING":               16,
+		"WORK_COMPLETED":               17,
+		"DATA_CHANNEL_READ_INDEX":      18,
+		"API_REQUEST_COUNT":            19,
+		"API_REQUEST_LATENCIES":        20,
+		"WORKER_PROCESS_CPU_MSECS":     21,
+		"WORKER_RESIDENT_MEMORY_BYTES": 22,
+		"WORKER_HEAP_BYTES":            23,
+		"WORKER_GC_COUNT":              24,
+		"WORKER_GC_PAUSE_MSECS":        25,
 	}
 )
 
@@ -200,6 +218,8 @@ const (
 	MonitoringInfo_BIGQUERY_TABLE      MonitoringInfo_MonitoringInfoLabels = 13
 	MonitoringInfo_BIGQUERY_VIEW       MonitoringInfo_MonitoringInfoLabels = 14
 	MonitoringInfo_BIGQUERY_QUERY_NAME MonitoringInfo_MonitoringInfoLabels = 15
+	MonitoringInfo_GCS_BUCKET          MonitoringInfo_MonitoringInfoLabels = 16
+	MonitoringInfo_GCS_PROJECT_ID      MonitoringInfo_MonitoringInfoLabels = 17
 )
 
 // Enum value maps for MonitoringInfo_MonitoringInfoLabels.
@@ -221,6 +241,8 @@ var (
 		13: "BIGQUERY_TABLE",
 		14: "BIGQUERY_VIEW",
 		15: "BIGQUERY_QUERY_NAME",
+		16: "GCS_BUCKET",
+		17: "GCS_PROJECT_ID",
 	}
 	MonitoringInfo_MonitoringInfoLabels_value = map[string]int32{
 		"TRANSFORM":           0,
@@ -239,6 +261,8 @@ var (
 		"BIGQUERY_TABLE":      13,
 		"BIGQUERY_VIEW":       14,
 		"BIGQUERY_QUERY_NAME": 15,
+		"GCS_BUCKET":          16,
+		"GCS_PROJECT_ID":      17,
 	}
 )
 
@@ -842,9 +866,9 @@ var file_metrics_proto_rawDesc = []byte{
 	0x6f, 0x6e, 0x73, 0x22, 0x34, 0x0a, 0x0a, 0x41, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f,
 	0x6e, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
 	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0xd1, 0x23, 0x0a, 0x13, 0x4d, 0x6f,
+	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x91, 0x2c, 0x0a, 0x13, 0x4d, 0x6f,
 	0x6e, 0x69, 0x74, 0x6f, 0x72, 0x69, 0x6e, 0x67, 0x49, 0x6e, 0x66, 0x6f, 0x53, 0x70, 0x65, 0x63,
-	0x73, 0x22, 0xb9, 0x23, 0x0a, 0x04, 0x45, 0x6e, 0x75, 0x6d, 0x12, 0xa7, 0x01, 0x0a, 0x0e, 0x55,
+	0x73, 0x22, 0xf9, 0x2b, 0x0a, 0x04, 0x45, 0x6e, 0x75, 0x6d, 0x12, 0xa7, 0x01, 0x0a, 0x0e, 0x55,
 	0x53, 0x45, 0x52, 0x5f, 0x53, 0x55, 0x4d, 0x5f, 0x49, 0x4e, 0x54, 0x36, 0x34, 0x10, 0x00, 0x1a,
 	0x92, 0x01, 0xd2, 0xa7, 0xa7, 0x96, 0x06, 0x8b, 0x01, 0x0a, 0x1d, 0x62, 0x65, 0x61, 0x6d, 0x3a,
 	0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x3a, 0x75, 0x73, 0x65, 0x72, 0x3a, 0x73, 0x75, 0x6d, 0x5f,
@@ -1127,10 +1151,78 @@ var file_metrics_proto_rawDesc = []byte{
 	0x72, 0x69, 0x74, 0x65, 0x20, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x2e, 0x22, 0x15,
 	0x0a, 0x05, 0x75, 0x6e, 0x69, 0x74, 0x73, 0x12, 0x0c, 0x4d, 0x69, 0x6c, 0x6c, 0x69, 0x73, 0x65,
 	0x63, 0x6f, 0x6e, 0x64, 0x73, 0x22, 0x16, 0x0a, 0x0e, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73,
+	0x5f, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x12, 0x04, 0x74, 0x72, 0x75, 0x65, 0x12, 0xd7, 0x01,
+	0x0a, 0x18, 0x57, 0x4f, 0x52, 0x4b, 0x45, 0x52, 0x5f, 0x50, 0x52, 0x4f, 0x43, 0x45, 0x53, 0x53,
+	0x5f, 0x43, 0x50, 0x55, 0x5f, 0x4d, 0x53, 0x45, 0x43, 0x53, 0x10, 0x15, 0x1a, 0xb8, 0x01, 0xd2,
+	0xa7, 0xa7, 0x96, 0x06, 0xb1, 0x01, 0x0a, 0x27, 0x62, 0x65, 0x61, 0x6d, 0x3a, 0x6d, 0x65, 0x74,
+	0x72, 0x69, 0x63, 0x3a, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x3a, 0x70, 0x72, 0x6f, 0x63, 0x65,
+	0x73, 0x73, 0x5f, 0x63, 0x70, 0x75, 0x5f, 0x6d, 0x73, 0x65, 0x63, 0x73, 0x3a, 0x76, 0x31, 0x12,
+	0x19, 0x62, 0x65, 0x61, 0x6d, 0x3a, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x3a, 0x73, 0x75,
+	0x6d, 0x5f, 0x69, 0x6e, 0x74, 0x36, 0x34, 0x3a, 0x76, 0x31, 0x22, 0x3c, 0x0a, 0x0b, 0x64, 0x65,
+	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x2d, 0x54, 0x68, 0x65, 0x20, 0x43,
+	0x50, 0x55, 0x20, 0x74, 0x69, 0x6d, 0x65, 0x20, 0x75, 0x73, 0x65, 0x64, 0x20, 0x62, 0x79, 0x20,
+	0x74, 0x68, 0x65, 0x20, 0x53, 0x44, 0x4b, 0x20, 0x68, 0x61, 0x72, 0x6e, 0x65, 0x73, 0x73, 0x20,
+	0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x2e, 0x22, 0x15, 0x0a, 0x05, 0x75, 0x6e, 0x69, 0x74,
+	0x73, 0x12, 0x0c, 0x4d, 0x69, 0x6c, 0x6c, 0x69, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x22,
+	0x16, 0x0a, 0x0e, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x5f, 0x6d, 0x65, 0x74, 0x72, 0x69,
+	0x63, 0x12, 0x04, 0x74, 0x72, 0x75, 0x65, 0x12, 0xdd, 0x01, 0x0a, 0x1c, 0x57, 0x4f, 0x52, 0x4b,
+	0x45, 0x52, 0x5f, 0x52, 0x45, 0x53, 0x49, 0x44, 0x45, 0x4e, 0x54, 0x5f, 0x4d, 0x45, 0x4d, 0x4f,
+	0x52, 0x59, 0x5f, 0x42, 0x59, 0x54, 0x45, 0x53, 0x10, 0x16, 0x1a, 0xba, 0x01, 0xd2, 0xa7, 0xa7,
+	0x96, 0x06, 0xb3, 0x01, 0x0a, 0x2b, 0x62, 0x65, 0x61, 0x6d, 0x3a, 0x6d, 0x65, 0x74, 0x72, 0x69,
+	0x63, 0x3a, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x3a, 0x72, 0x65, 0x73, 0x69, 0x64, 0x65, 0x6e,
+	0x74, 0x5f, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x3a, 0x76,
+	0x31, 0x12, 0x1c, 0x62, 0x65, 0x61, 0x6d, 0x3a, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x3a,
+	0x6c, 0x61, 0x74, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x6e, 0x74, 0x36, 0x34, 0x3a, 0x76, 0x31, 0x22,
+	0x3e, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x2f,
+	0x54, 0x68, 0x65, 0x20, 0x72, 0x65, 0x73, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x20, 0x6d, 0x65, 0x6d,
+	0x6f, 0x72, 0x79, 0x20, 0x6f, 0x66, 0x20, 0x74, 0x68, 0x65, 0x20, 0x53, 0x44, 0x4b, 0x20, 0x68,
+	0x61, 0x72, 0x6e, 0x65, 0x73, 0x73, 0x20, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x2e, 0x22,
+	0x0e, 0x0a, 0x05, 0x75, 0x6e, 0x69, 0x74, 0x73, 0x12, 0x05, 0x42, 0x79, 0x74, 0x65, 0x73, 0x22,
+	0x16, 0x0a, 0x0e, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x5f, 0x6d, 0x65, 0x74, 0x72, 0x69,
+	0x63, 0x12, 0x04, 0x74, 0x72, 0x75, 0x65, 0x12, 0xd8, 0x01, 0x0a, 0x11, 0x57, 0x4f, 0x52, 0x4b,
+	0x45, 0x52, 0x5f, 0x48, 0x45, 0x41, 0x50, 0x5f, 0x42, 0x59, 0x54, 0x45, 0x53, 0x10, 0x17, 0x1a,
+	0xc0, 0x01, 0xd2, 0xa7, 0xa7, 0x96, 0x06, 0xb9, 0x01, 0x0a, 0x20, 0x62, 0x65, 0x61, 0x6d, 0x3a,
+	0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x3a, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x3a, 0x68, 0x65,
+	0x61, 0x70, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x3a, 0x76, 0x31, 0x12, 0x1c, 0x62, 0x65, 0x61,
+	0x6d, 0x3a, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x3a, 0x6c, 0x61, 0x74, 0x65, 0x73, 0x74,
+	0x5f, 0x69, 0x6e, 0x74, 0x36, 0x34, 0x3a, 0x76, 0x31, 0x22, 0x4f, 0x0a, 0x0b, 0x64, 0x65, 0x73,
+	0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x40, 0x54, 0x68, 0x65, 0x20, 0x6d, 0x65,
+	0x6d, 0x6f, 0x72, 0x79, 0x20, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x64, 0x20, 0x74,
+	0x6f, 0x20, 0x68, 0x65, 0x61, 0x70, 0x20, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x20, 0x6f,
+	0x66, 0x20, 0x74, 0x68, 0x65, 0x20, 0x53, 0x44, 0x4b, 0x20, 0x68, 0x61, 0x72, 0x6e, 0x65, 0x73,
+	0x73, 0x20, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x2e, 0x22, 0x0e, 0x0a, 0x05, 0x75, 0x6e,
+	0x69, 0x74, 0x73, 0x12, 0x05, 0x42, 0x79, 0x74, 0x65, 0x73, 0x22, 0x16, 0x0a, 0x0e, 0x70, 0x72,
+	0x6f, 0x63, 0x65, 0x73, 0x73, 0x5f, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x12, 0x04, 0x74, 0x72,
+	0x75, 0x65, 0x12, 0xbe, 0x01, 0x0a, 0x0f, 0x57, 0x4f, 0x52, 0x4b, 0x45, 0x52, 0x5f, 0x47, 0x43,
+	0x5f, 0x43, 0x4f, 0x55, 0x4e, 0x54, 0x10, 0x18, 0x1a, 0xa8, 0x01, 0xd2, 0xa7, 0xa7, 0x96, 0x06,
+	0xa1, 0x01, 0x0a, 0x1e, 0x62, 0x65, 0x61, 0x6d, 0x3a, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x3a,
+	0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x3a, 0x67, 0x63, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x3a,
+	0x76, 0x31, 0x12, 0x19, 0x62, 0x65, 0x61, 0x6d, 0x3a, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73,
+	0x3a, 0x73, 0x75, 0x6d, 0x5f, 0x69, 0x6e, 0x74, 0x36, 0x34, 0x3a, 0x76, 0x31, 0x22, 0x4c, 0x0a,
+	0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3d, 0x54, 0x68,
+	0x65, 0x20, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x20, 0x6f, 0x66, 0x20, 0x67, 0x61, 0x72, 0x62,
+	0x61, 0x67, 0x65, 0x20, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x20,
+	0x6f, 0x66, 0x20, 0x74, 0x68, 0x65, 0x20, 0x53, 0x44, 0x4b, 0x20, 0x68, 0x61, 0x72, 0x6e, 0x65,
+	0x73, 0x73, 0x20, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x2e, 0x22, 0x16, 0x0a, 0x0e, 0x70,
+	0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x5f, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x12, 0x04, 0x74,
+	0x72, 0x75, 0x65, 0x12, 0xe7, 0x01, 0x0a, 0x15, 0x57, 0x4f, 0x52, 0x4b, 0x45, 0x52, 0x5f, 0x47,
+	0x43, 0x5f, 0x50, 0x41, 0x55, 0x53, 0x45, 0x5f, 0x4d, 0x53, 0x45, 0x43, 0x53, 0x10, 0x19, 0x1a,
+	0xcb, 0x01, 0xd2, 0xa7, 0xa7, 0x96, 0x06, 0xc4, 0x01, 0x0a, 0x24, 0x62, 0x65, 0x61, 0x6d, 0x3a,
+	0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x3a, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x3a, 0x67, 0x63,
+	0x5f, 0x70, 0x61, 0x75, 0x73, 0x65, 0x5f, 0x6d, 0x73, 0x65, 0x63, 0x73, 0x3a, 0x76, 0x31, 0x12,
+	0x19, 0x62, 0x65, 0x61, 0x6d, 0x3a, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x3a, 0x73, 0x75,
+	0x6d, 0x5f, 0x69, 0x6e, 0x74, 0x36, 0x34, 0x3a, 0x76, 0x31, 0x22, 0x52, 0x0a, 0x0b, 0x64, 0x65,
+	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x43, 0x54, 0x68, 0x65, 0x20, 0x74,
+	0x69, 0x6d, 0x65, 0x20, 0x74, 0x68, 0x65, 0x20, 0x53, 0x44, 0x4b, 0x20, 0x68, 0x61, 0x72, 0x6e,
+	0x65, 0x73, 0x73, 0x20, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x20, 0x77, 0x61, 0x73, 0x20,
+	0x70, 0x61, 0x75, 0x73, 0x65, 0x64, 0x20, 0x62, 0x79, 0x20, 0x67, 0x61, 0x72, 0x62, 0x61, 0x67,
+	0x65, 0x20, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x2e, 0x22, 0x15,
+	0x0a, 0x05, 0x75, 0x6e, 0x69, 0x74, 0x73, 0x12, 0x0c, 0x4d, 0x69, 0x6c, 0x6c, 0x69, 0x73, 0x65,
+	0x63, 0x6f, 0x6e, 0x64, 0x73, 0x22, 0x16, 0x0a, 0x0e, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73,
 	0x5f, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x12, 0x04, 0x74, 0x72, 0x75, 0x65, 0x22, 0x2e, 0x0a,
 	0x18, 0x4d, 0x6f, 0x6e, 0x69, 0x74, 0x6f, 0x72, 0x69, 0x6e, 0x67, 0x49, 0x6e, 0x66, 0x6f, 0x4c,
 	0x61, 0x62, 0x65, 0x6c, 0x50, 0x72, 0x6f, 0x70, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
-	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x95, 0x07,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0xe5, 0x07,
 	0x0a, 0x0e, 0x4d, 0x6f, 0x6e, 0x69, 0x74, 0x6f, 0x72, 0x69, 0x6e, 0x67, 0x49, 0x6e, 0x66, 0x6f,
 	0x12, 0x10, 0x0a, 0x03, 0x75, 0x72, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x75,
 	0x72, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
@@ -1148,7 +1240,7 @@ var file_metrics_proto_rawDesc = []byte{
 	0x6d, 0x65, 0x1a, 0x39, 0x0a, 0x0b, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72,
 	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
 	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xf5, 0x04,
+	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xc5, 0x05,
 	0x0a, 0x14, 0x4d, 0x6f, 0x6e, 0x69, 0x74, 0x6f, 0x72, 0x69, 0x6e, 0x67, 0x49, 0x6e, 0x66, 0x6f,
 	0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x12, 0x21, 0x0a, 0x09, 0x54, 0x52, 0x41, 0x4e, 0x53, 0x46,
 	0x4f, 0x52, 0x4d, 0x10, 0x00, 0x1a, 0x12, 0xa2, 0xd4, 0xe0, 0xe5, 0x03, 0x0c, 0x0a, 0x0a, 0x50,
@@ -1188,7 +1280,12 @@ var file_metrics_proto_rawDesc = []byte{
 	0x34, 0x0a, 0x13, 0x42, 0x49, 0x47, 0x51, 0x55, 0x45, 0x52, 0x59, 0x5f, 0x51, 0x55, 0x45, 0x52,
 	0x59, 0x5f, 0x4e, 0x41, 0x4d, 0x45, 0x10, 0x0f, 0x1a, 0x1b, 0xa2, 0xd4, 0xe0, 0xe5, 0x03, 0x15,
 	0x0a, 0x13, 0x42, 0x49, 0x47, 0x51, 0x55, 0x45, 0x52, 0x59, 0x5f, 0x51, 0x55, 0x45, 0x52, 0x59,
-	0x5f, 0x4e, 0x41, 0x4d, 0x45, 0x22, 0xbc, 0x05, 0x0a, 0x16, 0x4d, 0x6f, 0x6e, 0x69, 0x74, 0x6f,
+	0x5f, 0x4e, 0x41, 0x4d, 0x45, 0x12, 0x22, 0x0a, 0x0a, 0x47, 0x43, 0x53, 0x5f, 0x42, 0x55, 0x43,
+	0x4b, 0x45, 0x54, 0x10, 0x10, 0x1a, 0x12, 0xa2, 0xd4, 0xe0, 0xe5, 0x03, 0x0c, 0x0a, 0x0a, 0x47,
+	0x43, 0x53, 0x5f, 0x42, 0x55, 0x43, 0x4b, 0x45, 0x54, 0x12, 0x2a, 0x0a, 0x0e, 0x47, 0x43, 0x53,
+	0x5f, 0x50, 0x52, 0x4f, 0x4a, 0x45, 0x43, 0x54, 0x5f, 0x49, 0x44, 0x10, 0x11, 0x1a, 0x16, 0xa2,
+	0xd4, 0xe0, 0xe5, 0x03, 0x10, 0x0a, 0x0e, 0x47, 0x43, 0x53, 0x5f, 0x50, 0x52, 0x4f, 0x4a, 0x45,
+	0x43, 0x54, 0x5f, 0x49, 0x44, 0x22, 0xbc, 0x05, 0x0a, 0x16, 0x4d, 0x6f, 0x6e, 0x69, 0x74, 0x6f,
 	0x72, 0x69, 0x6e, 0x67, 0x49, 0x6e, 0x66, 0x6f, 0x54, 0x79, 0x70, 0x65, 0x55, 0x72, 0x6e, 0x73,
 	0x22, 0xa1, 0x05, 0x0a, 0x04, 0x45, 0x6e, 0x75, 0x6d, 0x12, 0x33, 0x0a, 0x0e, 0x53, 0x55, 0x4d,
 	0x5f, 0x49, 0x4e, 0x54, 0x36, 0x34, 0x5f, 0x54, 0x59, 0x50, 0x45, 0x10, 0x00, 0x1a, 0x1f, 0xa2,
@@ -27,6 +27,7 @@ import (
 
 	"sync/atomic"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/artifact"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 )
@@ -55,7 +56,7 @@ var (
 
 	// EnvironmentType is the environment type to run the user code.
 	EnvironmentType = flag.String("environment_type", "DOCKER",
-		"Environment Type. Possible options are DOCKER, and LOOPBACK.")
+		"Environment Type. Possible options are DOCKER, PROCESS, EXTERNAL and LOOPBACK.")
 
 	// EnvironmentConfig is the environment configuration for running the user code.
 	EnvironmentConfig = flag.String("environment_config",
@@ -65,7 +66,15 @@ var (
 			"For PROCESS: json of the form {\"os\": \"<OS>\", "+
 			"\"arch\": \"<ARCHITECTURE>\", \"command\": \"<process to execute>\", "+
 			"\"env\":{\"<Environment variables 1>\": \"<ENV_VAL>\"} }. "+
-			"All fields in the json are optional except command.")
+			"All fields in the json are optional except command.\n"+
+			"For EXTERNAL: the worker pool endpoint, or json of the form "+
+			"{\"url\": \"<worker pool endpoint>\", \"params\": {\"<param>\": \"<value>\"} }.")
+
+	// EnvironmentProbeTimeout is how long to wait for the worker pool of the
+	// EXTERNAL environment to be healthy before submitting jobs.
+	EnvironmentProbeTimeout = flag.Duration("environment_probe_timeout", 0,
+		"How long to wait for the EXTERNAL environment's worker pool to be healthy before submitting the job. "+
+			"The worker pool must be reachable from the launcher. Zero skips the check (optional).")
 
 	// SdkHarnessContainerImageOverrides contains patterns for overriding
 	// container image names in a pipeline.
@@ -78,9 +87,45 @@ var (
 	// Experiments toggle experimental features in the runner.
 	Experiments = flag.String("experiments", "", "Comma-separated list of experiments (optional).")
 
+	// MessageLevel is the minimum severity of the job messages and LOOPBACK
+	// worker logs printed while waiting for jobs.
+	MessageLevel = flag.String("job_message_level", "INFO", "Minimum severity of job messages and LOOPBACK worker logs printed while waiting for jobs: DEBUG, INFO, WARN or ERROR (optional).")
+
+	// FilesToStage are the local files staged as artifacts for workers.
+	FilesToStage = flag.String("files_to_stage", "", "Comma-separated list of local files staged for workers, available with artifact.StagedFile (optional).")
+
+	// WorkerMemoryLimit is the heap size at which workers throttle reading
+	// data and starting bundles.
+	WorkerMemoryLimit = flag.Int64("worker_memory_limit", 0, "Heap size in bytes at which workers throttle reading data and starting bundles, instead of running out of memory. Zero disables throttling (optional).")
+
+	// WorkerShutdownGracePeriod is how long workers drain their bundles once
+	// terminated.
+	WorkerShutdownGracePeriod = flag.Duration("worker_shutdown_grace_period", 0, "How long workers keep processing their bundles once sent SIGTERM, without accepting new ones, before exiting. Zero exits immediately (optional).")
+
+	// StuckBundleTimeout is how long workers process a bundle before
+	// reporting it as stuck.
+	StuckBundleTimeout = flag.Duration("stuck_bundle_timeout", 0, "How long workers process a bundle before logging the goroutine stacks, and the transform and element being processed, to diagnose hangs. Zero disables the reports (optional).")
+
+	// OTLPEndpoint is the OpenTelemetry collector receiving the traces of
+	// workers.
+	OTLPEndpoint = flag.String("otlp_endpoint", "", "OTLP/HTTP endpoint of the OpenTelemetry collector receiving traces of the bundles, transforms and data and state operations of workers, such as http://localhost:4318 (optional).")
+
+	// OTLPMetricsEndpoint is the OpenTelemetry collector receiving the user
+	// metrics of workers.
+	OTLPMetricsEndpoint = flag.String("otlp_metrics_endpoint", "", "OTLP/HTTP endpoint of the OpenTelemetry collector receiving the user metrics of the bundles committed by workers, such as http://localhost:4318 (optional).")
+
+	// PrometheusAddress is the address on which workers serve their metrics
+	// to Prometheus.
+	PrometheusAddress = flag.String("prometheus_address", "", "Address, such as :9464, on which workers serve their harness and user metrics in the Prometheus text format at /metrics (optional).")
+
 	// Async determines whether to wait for job completion.
 	Async = flag.Bool("async", false, "Do not wait for job completion.")
 
+	// ValidateOnly determines whether to only validate the pipeline. The
+	// pipeline is built, translated to the model pipeline and checked against
+	// the runner, but not run.
+	ValidateOnly = flag.Bool("validate_only", false, "Build, translate and validate the pipeline for the runner without running it.")
+
 	// Strict mode applies additional validation to user pipelines before
 	// executing them and fails early if the pipelines don't pass.
 	Strict = flag.Bool("beam_strict", false, "Apply additional validation to pipelines.")
@@ -102,6 +147,36 @@ func GetEndpoint() (string, error) {
 	return *Endpoint, nil
 }
 
+// StageFiles stages the specified files to stage as artifacts of the
+// pipeline. Convenience function for runners staging artifacts.
+func StageFiles() error {
+	if *FilesToStage == "" {
+		return nil
+	}
+	for _, f := range strings.Split(*FilesToStage, ",") {
+		if err := artifact.StageFile(strings.TrimSpace(f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMessageLevel returns the severity of the specified message level.
+func GetMessageLevel() (log.Severity, error) {
+	switch strings.ToUpper(*MessageLevel) {
+	case "DEBUG":
+		return log.SevDebug, nil
+	case "INFO":
+		return log.SevInfo, nil
+	case "WARN", "WARNING":
+		return log.SevWarn, nil
+	case "ERROR":
+		return log.SevError, nil
+	default:
+		return log.SevUnspecified, errors.Errorf("invalid job message level %q. Use --job_message_level=(DEBUG|INFO|WARN|ERROR)", *MessageLevel)
+	}
+}
+
 var unique int32
 
 // GetJobName returns the specified job name or, if not present, a fresh
@@ -138,9 +213,12 @@ func IsLoopback() bool {
 
 // GetEnvironmentConfig returns the specified configuration for specified SDK Harness,
 // if not present, the default development container for the current user.
+// Process and external environments have no default configuration, since
+// the command and the worker pool of the SDK harness depend on the hosts of
+// the runner.
 // Convenience function.
 func GetEnvironmentConfig(ctx context.Context) string {
-	if *EnvironmentConfig == "" {
+	if env := strings.ToLower(*EnvironmentType); *EnvironmentConfig == "" && env != "process" && env != "external" {
 		*EnvironmentConfig = os.ExpandEnv("apache/beam_go_sdk:latest")
 		log.Infof(ctx, "No environment config specified. Using default config: '%v'", *EnvironmentConfig)
 	}
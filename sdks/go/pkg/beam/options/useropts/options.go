@@ -0,0 +1,222 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package useropts registers user-defined pipeline options. The options of a
+// pipeline are declared as the fields of a struct, with the name of each
+// option in its `flag` tag and its help text in its `help` tag. The fields
+// are bound to command line flags, whose defaults are the current values of
+// the fields:
+//
+//	type Options struct {
+//		Input  string `flag:"input" help:"Files to read."`
+//		Shards int    `flag:"shards" help:"Number of output files."`
+//	}
+//
+//	var opts = &Options{Shards: 10}
+//
+//	func init() {
+//		useropts.Register("wordcount", opts)
+//	}
+//
+// If the struct implements Validator, the options are validated by
+// beam.Init. The options are then serialized into the pipeline options, so
+// DoFns read the same values on remote workers, where the flags of the
+// launcher aren't set:
+//
+//	func (fn *formatFn) Setup() error {
+//		var o Options
+//		return useropts.Get("wordcount", &o)
+//	}
+//
+// Experimental.
+package useropts
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+// Validator is implemented by options structs that validate their values.
+type Validator interface {
+	// Validate returns an error if the options are invalid.
+	Validate() error
+}
+
+var (
+	mu         sync.Mutex
+	registered = make(map[string]interface{})
+)
+
+func init() {
+	runtime.RegisterInit(export)
+}
+
+// Register registers the options struct opts points to under the given name,
+// and defines a command line flag for each field with a `flag` tag, which
+// sets the field. Supported field types are string, bool, int, int64,
+// float64, time.Duration and []string, set by a comma-separated flag. It
+// panics if the name is already registered or the struct is invalid, and
+// must be called before beam.Init, typically in an init function.
+func Register(name string, opts interface{}) {
+	if err := register(flag.CommandLine, name, opts); err != nil {
+		panic(err)
+	}
+}
+
+func register(fs *flag.FlagSet, name string, opts interface{}) error {
+	if runtime.Initialized() {
+		return errors.Errorf("options %v registered after beam.Init. Register options during init() instead", name)
+	}
+	t := reflect.TypeOf(opts)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("options %v must be a pointer to a struct, got %v", name, t)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registered[name]; ok {
+		return errors.Errorf("options %v already registered", name)
+	}
+
+	v := reflect.ValueOf(opts).Elem()
+	for i := 0; i < t.Elem().NumField(); i++ {
+		f := t.Elem().Field(i)
+		flagName, help := f.Tag.Get("flag"), f.Tag.Get("help")
+		if flagName == "" {
+			continue
+		}
+		if f.PkgPath != "" {
+			return errors.Errorf("option %v of %v is the unexported field %v", flagName, name, f.Name)
+		}
+		switch p := v.Field(i).Addr().Interface().(type) {
+		case *string:
+			fs.StringVar(p, flagName, *p, help)
+		case *bool:
+			fs.BoolVar(p, flagName, *p, help)
+		case *int:
+			fs.IntVar(p, flagName, *p, help)
+		case *int64:
+			fs.Int64Var(p, flagName, *p, help)
+		case *float64:
+			fs.Float64Var(p, flagName, *p, help)
+		case *time.Duration:
+			fs.DurationVar(p, flagName, *p, help)
+		case *[]string:
+			fs.Var((*stringList)(p), flagName, help)
+		default:
+			return errors.Errorf("option %v of %v has unsupported type %v, want string, bool, int, int64, float64, time.Duration or []string", flagName, name, f.Type)
+		}
+	}
+	registered[name] = opts
+	return nil
+}
+
+// stringList is a flag.Value of a comma-separated list of strings.
+type stringList []string
+
+func (s *stringList) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = nil
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*s = append(*s, v)
+		}
+	}
+	return nil
+}
+
+// Get sets the struct opts points to to the options registered under the
+// given name. On remote workers, these are the values of the launcher. The
+// struct must have the type of the registered struct.
+func Get(name string, opts interface{}) error {
+	mu.Lock()
+	reg, ok := registered[name]
+	mu.Unlock()
+	if !ok {
+		return errors.Errorf("options %v not registered", name)
+	}
+	if got, want := reflect.TypeOf(opts), reflect.TypeOf(reg); got != want {
+		return errors.Errorf("options %v have type %v, got %v", name, want, got)
+	}
+
+	data := runtime.GlobalOptions.Get(optionsKey(name))
+	if data == "" {
+		// Not exported yet, so the registered struct holds the values.
+		reflect.ValueOf(opts).Elem().Set(reflect.ValueOf(reg).Elem())
+		return nil
+	}
+	if err := json.Unmarshal([]byte(data), opts); err != nil {
+		return errors.Wrapf(err, "failed to decode options %v", name)
+	}
+	return nil
+}
+
+// export validates the registered options and serializes them into the
+// pipeline options, unless running as a worker, whose options are imported
+// from the pipeline instead.
+func export() {
+	if isWorker() {
+		return
+	}
+	if err := exportOptions(); err != nil {
+		log.Exit(context.Background(), err)
+	}
+}
+
+func exportOptions() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for name, opts := range registered {
+		if v, ok := opts.(Validator); ok {
+			if err := v.Validate(); err != nil {
+				return errors.Wrapf(err, "invalid options %v", name)
+			}
+		}
+		data, err := json.Marshal(opts)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode options %v", name)
+		}
+		runtime.GlobalOptions.Set(optionsKey(name), string(data))
+	}
+	return nil
+}
+
+// isWorker returns whether the binary runs as a worker harness, as set by
+// the --worker flag of the harness.
+func isWorker() bool {
+	f := flag.Lookup("worker")
+	return f != nil && f.Value.String() == "true"
+}
+
+func optionsKey(name string) string {
+	return fmt.Sprintf("user_options:%v", name)
+}
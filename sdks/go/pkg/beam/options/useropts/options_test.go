@@ -0,0 +1,123 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package useropts
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+type testOptions struct {
+	Input   string        `flag:"input" help:"Files to read."`
+	Shards  int           `flag:"shards"`
+	Dry     bool          `flag:"dry"`
+	Timeout time.Duration `flag:"timeout"`
+	Labels  []string      `flag:"labels"`
+	Note    string
+}
+
+func (o *testOptions) Validate() error {
+	if o.Shards <= 0 {
+		return errors.Errorf("shards (%d) must be positive", o.Shards)
+	}
+	return nil
+}
+
+func resetRegistered(t *testing.T) {
+	old := registered
+	registered = make(map[string]interface{})
+	t.Cleanup(func() { registered = old })
+}
+
+func TestRegister(t *testing.T) {
+	resetRegistered(t)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts := &testOptions{Input: "in.txt", Shards: 10, Note: "note"}
+	if err := register(fs, "test", opts); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if err := fs.Parse([]string{"--shards=3", "--dry", "--timeout=1m", "--labels=a, b"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := &testOptions{Input: "in.txt", Shards: 3, Dry: true, Timeout: time.Minute, Labels: []string{"a", "b"}, Note: "note"}
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("parsed options = %+v, want %+v", opts, want)
+	}
+
+	if err := register(flag.NewFlagSet("test", flag.ContinueOnError), "test", &testOptions{}); err == nil {
+		t.Error("register(duplicate) succeeded, want error")
+	}
+	if err := register(flag.NewFlagSet("test", flag.ContinueOnError), "value", testOptions{}); err == nil {
+		t.Error("register(struct value) succeeded, want error")
+	}
+	type badOptions struct {
+		Ratio float32 `flag:"ratio"`
+	}
+	if err := register(flag.NewFlagSet("test", flag.ContinueOnError), "bad", &badOptions{}); err == nil {
+		t.Error("register(float32 option) succeeded, want error")
+	}
+}
+
+func TestGet(t *testing.T) {
+	resetRegistered(t)
+
+	opts := &testOptions{Input: "in.txt", Shards: 3, Labels: []string{"a"}}
+	if err := register(flag.NewFlagSet("test", flag.ContinueOnError), "test", opts); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	defer runtime.GlobalOptions.Set(optionsKey("test"), "")
+
+	// Before export, the registered values are returned.
+	var got testOptions
+	if err := Get("test", &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !reflect.DeepEqual(&got, opts) {
+		t.Errorf("Get() = %+v, want %+v", got, opts)
+	}
+
+	if err := exportOptions(); err != nil {
+		t.Fatalf("exportOptions failed: %v", err)
+	}
+	// Workers read the exported values, not the flags of their binary.
+	opts.Input = "changed.txt"
+	got = testOptions{}
+	if err := Get("test", &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if want := (testOptions{Input: "in.txt", Shards: 3, Labels: []string{"a"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() = %+v, want %+v", got, want)
+	}
+
+	if err := Get("unknown", &got); err == nil {
+		t.Error("Get(unknown) succeeded, want error")
+	}
+	var other struct{ Input string }
+	if err := Get("test", &other); err == nil {
+		t.Error("Get(other type) succeeded, want error")
+	}
+
+	opts.Shards = 0
+	if err := exportOptions(); err == nil {
+		t.Error("exportOptions(invalid) succeeded, want error")
+	}
+}
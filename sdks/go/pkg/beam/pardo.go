@@ -66,6 +66,12 @@ func TryParDo(s Scope, dofn interface{}, col PCollection, opts ...Option) ([]PCo
 	if err != nil {
 		return nil, addParDoCtx(err, s)
 	}
+	if fn.IsStateful() {
+		edge.StateCoders, err = inferStateCoders(fn)
+		if err != nil {
+			return nil, addParDoCtx(err, s)
+		}
+	}
 
 	var ret []PCollection
 	for _, out := range edge.Output {
@@ -76,6 +82,23 @@ func TryParDo(s Scope, dofn interface{}, col PCollection, opts ...Option) ([]PCo
 	return ret, nil
 }
 
+// inferStateCoders infers the coders of the state cells of a DoFn from the
+// types they declare.
+func inferStateCoders(fn *graph.DoFn) (map[string]*coder.Coder, error) {
+	coders := make(map[string]*coder.Coder)
+	for _, c := range fn.StateCells() {
+		if c.Type == nil {
+			return nil, errors.Errorf("state %v of DoFn %v has no Type", c.ID, fn.Name())
+		}
+		sc, err := inferCoder(typex.New(c.Type))
+		if err != nil {
+			return nil, errors.WithContextf(err, "inferring coder of state %v", c.ID)
+		}
+		coders[c.ID] = sc
+	}
+	return coders, nil
+}
+
 // ParDoN inserts a ParDo with any number of outputs into the pipeline.
 func ParDoN(s Scope, dofn interface{}, col PCollection, opts ...Option) []PCollection {
 	return MustN(TryParDo(s, dofn, col, opts...))
@@ -22,7 +22,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/state"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
 	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
 )
 
@@ -72,6 +75,37 @@ func TestFormatParDoError(t *testing.T) {
 	}
 }
 
+type statefulFn struct {
+	Count  state.Value
+	Buffer state.Bag
+}
+
+func (fn *statefulFn) ProcessElement(state.Provider, string, int) {}
+
+func TestParDoStateCoders(t *testing.T) {
+	p, s := NewPipelineWithRoot()
+	keyed := ParDo(s, func([]byte) (string, int) { return "", 0 }, Impulse(s))
+	ParDo0(s, &statefulFn{Count: state.Value{ID: "count", Type: reflectx.Int}, Buffer: state.Bag{ID: "buffer", Type: reflectx.String}}, keyed)
+	edges, _, err := p.Build()
+	if err != nil {
+		t.Fatalf("Pipeline couldn't build: %v", err)
+	}
+	coders := edges[len(edges)-1].StateCoders
+	if got, want := len(coders), 2; got != want {
+		t.Fatalf("state coders = %v, want %v", coders, want)
+	}
+	if c := coders["count"]; c == nil || c.T.Type() != reflectx.Int {
+		t.Errorf("coder of count = %v, want a coder of int", c)
+	}
+	if c := coders["buffer"]; c == nil || c.Kind != coder.String {
+		t.Errorf("coder of buffer = %v, want a string coder", c)
+	}
+
+	if _, err := TryParDo(s, &statefulFn{Count: state.Value{ID: "count"}, Buffer: state.Bag{ID: "buffer", Type: reflectx.String}}, keyed); err == nil {
+		t.Error("TryParDo() succeeded, want an error for state without a Type")
+	}
+}
+
 func TestAnnotations(t *testing.T) {
 	m := make(map[string][]byte)
 	m["privacy_property"] = []byte("differential_privacy")
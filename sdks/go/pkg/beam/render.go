@@ -0,0 +1,228 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beam
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// GraphFormat is a format for rendering pipeline graphs.
+type GraphFormat string
+
+const (
+	// DOT renders the graph in the Graphviz DOT language.
+	DOT GraphFormat = "dot"
+	// Mermaid renders the graph as a Mermaid flowchart.
+	Mermaid GraphFormat = "mermaid"
+)
+
+// RenderGraph writes the constructed graph of the pipeline to w in the given
+// format, without executing it. Transforms are drawn inside the composite
+// transforms that contain them, and PCollections are annotated with their
+// element types, coders and windowing strategies.
+func RenderGraph(p *Pipeline, format GraphFormat, w io.Writer) error {
+	switch format {
+	case DOT, Mermaid:
+	default:
+		return errors.Errorf("unknown graph format %q, want %q or %q", format, DOT, Mermaid)
+	}
+	edges, nodes, err := p.Build()
+	if err != nil {
+		return errors.WithContext(err, "rendering pipeline graph")
+	}
+
+	// Group the transforms by scope, so composites are drawn around the
+	// transforms and composites they contain.
+	var root *graph.Scope
+	scoped := map[*graph.Scope][]*graph.MultiEdge{}
+	children := map[*graph.Scope][]*graph.Scope{}
+	seen := map[*graph.Scope]bool{}
+	for _, edge := range edges {
+		scoped[edge.Scope()] = append(scoped[edge.Scope()], edge)
+		for s := edge.Scope(); !seen[s]; s = s.Parent {
+			seen[s] = true
+			if s.Parent == nil {
+				root = s
+				break
+			}
+			children[s.Parent] = append(children[s.Parent], s)
+		}
+	}
+
+	r := &graphRenderer{format: format}
+	r.begin()
+	for _, n := range nodes {
+		r.pcollection(n)
+	}
+	var scope func(s *graph.Scope)
+	scope = func(s *graph.Scope) {
+		if s != root {
+			r.beginScope(s)
+		}
+		for _, edge := range scoped[s] {
+			r.transform(edge)
+		}
+		subs := children[s]
+		sort.Slice(subs, func(i, j int) bool { return subs[i].ID() < subs[j].ID() })
+		for _, sub := range subs {
+			scope(sub)
+		}
+		if s != root {
+			r.endScope()
+		}
+	}
+	if root != nil {
+		scope(root)
+	}
+	for _, edge := range edges {
+		for _, in := range edge.Input {
+			r.link(fmt.Sprintf("n%d", in.From.ID()), fmt.Sprintf("e%d", edge.ID()))
+		}
+		for _, out := range edge.Output {
+			r.link(fmt.Sprintf("e%d", edge.ID()), fmt.Sprintf("n%d", out.To.ID()))
+		}
+	}
+	r.end()
+	_, err = w.Write(r.buf.Bytes())
+	return err
+}
+
+// renderGraphFile renders the graph of the pipeline to the file, in the
+// format given by its extension.
+func renderGraphFile(p *Pipeline, filename string) error {
+	format := DOT
+	if ext := path.Ext(filename); ext == ".mmd" || ext == ".mermaid" {
+		format = Mermaid
+	}
+	var buf bytes.Buffer
+	if err := RenderGraph(p, format, &buf); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write pipeline graph to %v", filename)
+	}
+	return nil
+}
+
+// graphRenderer renders the elements of a graph in a format.
+type graphRenderer struct {
+	format GraphFormat
+	buf    bytes.Buffer
+	depth  int
+}
+
+func (r *graphRenderer) line(format string, args ...interface{}) {
+	r.buf.WriteString(strings.Repeat("  ", r.depth))
+	fmt.Fprintf(&r.buf, format, args...)
+	r.buf.WriteByte('\n')
+}
+
+// label quotes the lines of a label.
+func (r *graphRenderer) label(lines ...string) string {
+	if r.format == DOT {
+		for i, l := range lines {
+			lines[i] = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(l)
+		}
+		return `"` + strings.Join(lines, `\n`) + `"`
+	}
+	for i, l := range lines {
+		lines[i] = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(l)
+	}
+	return `"` + strings.Join(lines, "<br>") + `"`
+}
+
+func (r *graphRenderer) begin() {
+	if r.format == DOT {
+		r.line("digraph pipeline {")
+		r.depth++
+		r.line(`node [fontname="Ubuntu" fontsize="11"];`)
+		return
+	}
+	r.line("flowchart TD")
+	r.depth++
+}
+
+func (r *graphRenderer) end() {
+	r.depth--
+	if r.format == DOT {
+		r.line("}")
+	}
+}
+
+func (r *graphRenderer) beginScope(s *graph.Scope) {
+	if r.format == DOT {
+		r.line(`subgraph "cluster_s%d" {`, s.ID())
+		r.depth++
+		r.line("label=%v;", r.label(s.Label))
+		return
+	}
+	r.line("subgraph s%d[%v]", s.ID(), r.label(s.Label))
+	r.depth++
+}
+
+func (r *graphRenderer) endScope() {
+	r.depth--
+	if r.format == DOT {
+		r.line("}")
+		return
+	}
+	r.line("end")
+}
+
+func (r *graphRenderer) transform(edge *graph.MultiEdge) {
+	lines := []string{string(edge.Op)}
+	if name := path.Base(edge.Name()); name != string(edge.Op) {
+		lines = append(lines, name)
+	}
+	if r.format == DOT {
+		r.line(`"e%d" [shape="box" label=%v];`, edge.ID(), r.label(lines...))
+		return
+	}
+	r.line("e%d[%v]", edge.ID(), r.label(lines...))
+}
+
+func (r *graphRenderer) pcollection(n *graph.Node) {
+	lines := []string{
+		fmt.Sprintf("n%d: %v", n.ID(), n.Type()),
+		fmt.Sprintf("coder: %v", n.Coder),
+		fmt.Sprintf("window: %v", n.WindowingStrategy()),
+	}
+	if !n.Bounded() {
+		lines = append(lines, "unbounded")
+	}
+	if r.format == DOT {
+		r.line(`"n%d" [shape="ellipse" label=%v];`, n.ID(), r.label(lines...))
+		return
+	}
+	r.line("n%d([%v])", n.ID(), r.label(lines...))
+}
+
+func (r *graphRenderer) link(from, to string) {
+	if r.format == DOT {
+		r.line(`"%v" -> "%v";`, from, to)
+		return
+	}
+	r.line("%v --> %v", from, to)
+}
@@ -0,0 +1,65 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beam
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderGraph(t *testing.T) {
+	p, s := NewPipelineWithRoot()
+	col := Create(s.Scope("inputs"), "a", "b")
+	ParDo(s, strings.ToUpper, col)
+
+	tests := []struct {
+		format GraphFormat
+		want   []string
+	}{
+		{DOT, []string{
+			"digraph pipeline {",
+			`subgraph "cluster_s`,
+			`label="inputs";`,
+			`[shape="box" label="ParDo\nstrings.ToUpper"];`,
+			`label="n2: string\ncoder: string\nwindow: GLO"];`,
+			`"n2" -> "e`,
+		}},
+		{Mermaid, []string{
+			"flowchart TD",
+			`["inputs"]`,
+			`["ParDo<br>strings.ToUpper"]`,
+			`(["n2: string<br>coder: string<br>window: GLO"])`,
+			"n2 --> e",
+			"end",
+		}},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := RenderGraph(p, test.format, &buf); err != nil {
+			t.Fatalf("RenderGraph(%v) failed: %v", test.format, err)
+		}
+		for _, want := range test.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("RenderGraph(%v) = %v, want it to contain %v", test.format, buf.String(), want)
+			}
+		}
+	}
+
+	if err := RenderGraph(p, "svg", &bytes.Buffer{}); err == nil {
+		t.Error("RenderGraph(svg) succeeded, want error for unknown format")
+	}
+}
@@ -17,6 +17,7 @@ package beam
 
 import (
 	"context"
+	"flag"
 	"fmt"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
@@ -28,6 +29,8 @@ import (
 
 var (
 	runners = make(map[string]func(ctx context.Context, p *Pipeline) (PipelineResult, error))
+
+	renderOutput = flag.String("render_output", "", "File to render the pipeline graph to before running it, as a Mermaid flowchart for files ending in .mmd or .mermaid and as DOT otherwise (optional).")
 )
 
 // RegisterRunner associates the name with the supplied runner, making it available
@@ -41,11 +44,17 @@ func RegisterRunner(name string, fn func(ctx context.Context, p *Pipeline) (Pipe
 
 // Run executes the pipeline using the selected registred runner. It is customary
 // to define a "runner" with no default as a flag to let users control runner
-// selection.
+// selection. If the --render_output flag is set, the graph of the pipeline is
+// rendered to its file first, as by RenderGraph.
 func Run(ctx context.Context, runner string, p *Pipeline) (PipelineResult, error) {
 	fn, ok := runners[runner]
 	if !ok {
 		log.Exitf(ctx, "Runner %v not registered. Forgot to _ import it?", runner)
 	}
+	if *renderOutput != "" {
+		if err := renderGraphFile(p, *renderOutput); err != nil {
+			return nil, err
+		}
+	}
 	return fn(ctx, p)
 }
@@ -24,17 +24,20 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"regexp"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/pipelinex"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/hooks"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
 	"github.com/apache/beam/sdks/go/pkg/beam/options/gcpopts"
 	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
 	"github.com/apache/beam/sdks/go/pkg/beam/runners/dataflow/dataflowlib"
@@ -65,13 +68,24 @@ var (
 	workerJar            = flag.String("dataflow_worker_jar", "", "Dataflow worker jar (optional)")
 	workerRegion         = flag.String("worker_region", "", "Dataflow worker region (optional)")
 	workerZone           = flag.String("worker_zone", "", "Dataflow worker zone (optional)")
+	sdkContainerImage    = flag.String("sdk_container_image", "", "SDK container image of the workers, superseding --worker_harness_container_image (optional).")
+
+	// Service options
+	streamingEngine = flag.Bool("enable_streaming_engine", false, "Run streaming jobs on Streaming Engine (optional).")
+	prime           = flag.Bool("enable_prime", false, "Run the job on Dataflow Prime (optional).")
+	serviceOptions  = flag.String("dataflow_service_options", "", "Comma-separated list of Dataflow service options (optional).")
+	flexRSGoal      = flag.String("flexrs_goal", "", "Flexible Resource Scheduling goal of batch jobs: COST_OPTIMIZED or SPEED_OPTIMIZED (optional).")
 
 	executeAsync   = flag.Bool("execute_async", false, "Asynchronous execution. Submit the job and return immediately.")
 	dryRun         = flag.Bool("dry_run", false, "Dry run. Just print the job, but don't submit it.")
 	teardownPolicy = flag.String("teardown_policy", "", "Job teardown policy (internal only).")
 
+	update               = flag.Bool("update", false, "Replace the running job with the same --job_name by this pipeline, keeping its state (optional).")
+	transformNameMapping = flag.String("transform_name_mapping", "", "JSON-formatted map[string]string from transform names of the running job to their names in this pipeline, for --update (optional).")
+
 	// SDK options
 	cpuProfiling     = flag.String("cpu_profiling", "", "Job records CPU profiles to this GCS location (optional)")
+	profileThreshold = flag.Duration("cpu_profiling_threshold", 0, "Job only records CPU and heap profiles of bundles running longer than this to the --cpu_profiling location, instead of profiling every bundle (optional)")
 	sessionRecording = flag.String("session_recording", "", "Job records session transcripts")
 )
 
@@ -85,8 +99,51 @@ func init() {
 
 var unique int32
 
+// Drainer is implemented by the results of pipelines run on Dataflow, to
+// drain their jobs. A drained job stops reading from its sources, finishes
+// processing the data it has read, and then stops. This is mainly useful for
+// streaming jobs run with --execute_async:
+//
+//	pr, err := beam.Run(ctx, "dataflow", p)
+//	...
+//	err = pr.(dataflow.Drainer).Drain(ctx)
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
+
+// Result is implemented by the results of pipelines run on Dataflow. Besides
+// the job ID and metrics, it polls the state of the job, waits for the job to
+// finish and cancels or drains it. It is mainly useful for jobs run with
+// --execute_async:
+//
+//	pr, err := beam.Run(ctx, "dataflow", p)
+//	...
+//	res := pr.(dataflow.Result)
+//	if state, err := res.WaitUntilFinish(ctx, time.Hour); err == nil && state == "JOB_STATE_RUNNING" {
+//		err = res.Cancel(ctx)
+//	}
+//
+// States are names of Dataflow job states, such as JOB_STATE_DONE. The
+// metrics of an async result are only available once it has been waited for
+// until the job finished.
+type Result interface {
+	beam.PipelineResult
+	Drainer
+
+	// State returns the current state of the job.
+	State(ctx context.Context) (string, error)
+	// WaitUntilFinish waits until the job finishes, or until the timeout
+	// passes if it is positive, and returns the last state of the job. It
+	// returns an error if the job failed.
+	WaitUntilFinish(ctx context.Context, timeout time.Duration) (string, error)
+	// Cancel requests the job to be cancelled, without waiting for it to stop.
+	Cancel(ctx context.Context) error
+}
+
 // Execute runs the given pipeline on Google Cloud Dataflow. It uses the
-// default application credentials to submit the job.
+// default application credentials to submit the job. With --update, the job
+// replaces the running job with the same --job_name, and transforms renamed
+// since are mapped to their old names with --transform_name_mapping.
 func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
 	// (1) Gather job options
 
@@ -108,8 +165,25 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 		}
 	}
 
+	var nameMapping map[string]string
+	if *transformNameMapping != "" {
+		if !*update {
+			return nil, errors.New("--transform_name_mapping requires --update")
+		}
+		if err := json.Unmarshal([]byte(*transformNameMapping), &nameMapping); err != nil {
+			return nil, errors.Wrapf(err, "error reading --transform_name_mapping flag as JSON")
+		}
+	}
+	if *update && *jobopts.JobName == "" {
+		return nil, errors.New("no job to update specified. Use --job_name=<name of the running job>")
+	}
+
 	if *cpuProfiling != "" {
-		perf.EnableProfCaptureHook("gcs_profile_writer", *cpuProfiling)
+		if *profileThreshold > 0 {
+			perf.EnableSlowBundleProfiling(*profileThreshold, "gcs_profile_writer", *cpuProfiling)
+		} else {
+			perf.EnableProfCaptureHook("gcs_profile_writer", *cpuProfiling)
+		}
 	}
 
 	if *sessionRecording != "" {
@@ -126,6 +200,16 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 			return nil, errors.New("invalid autoscaling algorithm. Use --autoscaling_algorithm=(NONE|THROUGHPUT_BASED)")
 		}
 	}
+	if *sdkContainerImage != "" && *image != "" && *sdkContainerImage != *image {
+		return nil, errors.New("conflicting container images. Use either --sdk_container_image or --worker_harness_container_image")
+	}
+	if err := validateImageOverrides(jobopts.SdkHarnessContainerImageOverrides); err != nil {
+		return nil, err
+	}
+	dfServiceOptions, err := getServiceOptions()
+	if err != nil {
+		return nil, err
+	}
 
 	hooks.SerializeHooksToOptions()
 
@@ -155,29 +239,34 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 	}
 
 	opts := &dataflowlib.JobOptions{
-		Name:                jobopts.GetJobName(),
-		Experiments:         experiments,
-		Options:             beam.PipelineOptions.Export(),
-		Project:             project,
-		Region:              region,
-		Zone:                *zone,
-		Network:             *network,
-		Subnetwork:          *subnetwork,
-		NoUsePublicIPs:      *noUsePublicIPs,
-		NumWorkers:          *numWorkers,
-		MaxNumWorkers:       *maxNumWorkers,
-		DiskSizeGb:          *diskSizeGb,
-		Algorithm:           *autoscalingAlgorithm,
-		MachineType:         *machineType,
-		Labels:              jobLabels,
-		ServiceAccountEmail: *serviceAccountEmail,
-		TempLocation:        *tempLocation,
-		Worker:              *jobopts.WorkerBinary,
-		WorkerJar:           *workerJar,
-		WorkerRegion:        *workerRegion,
-		WorkerZone:          *workerZone,
-		TeardownPolicy:      *teardownPolicy,
-		ContainerImage:      getContainerImage(ctx),
+		Name:                 jobopts.GetJobName(),
+		Experiments:          experiments,
+		Options:              beam.PipelineOptions.Export(),
+		Project:              project,
+		Region:               region,
+		Zone:                 *zone,
+		Network:              *network,
+		Subnetwork:           *subnetwork,
+		NoUsePublicIPs:       *noUsePublicIPs,
+		NumWorkers:           *numWorkers,
+		MaxNumWorkers:        *maxNumWorkers,
+		DiskSizeGb:           *diskSizeGb,
+		Algorithm:            *autoscalingAlgorithm,
+		MachineType:          *machineType,
+		Labels:               jobLabels,
+		ServiceAccountEmail:  *serviceAccountEmail,
+		TempLocation:         *tempLocation,
+		Worker:               *jobopts.WorkerBinary,
+		WorkerJar:            *workerJar,
+		WorkerRegion:         *workerRegion,
+		WorkerZone:           *workerZone,
+		TeardownPolicy:       *teardownPolicy,
+		StreamingEngine:      *streamingEngine,
+		ServiceOptions:       dfServiceOptions,
+		FlexRSGoal:           *flexRSGoal,
+		Update:               *update,
+		TransformNameMapping: nameMapping,
+		ContainerImage:       getContainerImage(ctx),
 	}
 	if opts.TempLocation == "" {
 		opts.TempLocation = gcsx.Join(*stagingLocation, "tmp")
@@ -191,6 +280,7 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 	workerURL := gcsx.Join(*stagingLocation, id, "worker")
 	jarURL := gcsx.Join(*stagingLocation, id, "dataflow-worker.jar")
 	xlangURL := gcsx.Join(*stagingLocation, id, "xlang")
+	filesURL := gcsx.Join(*stagingLocation, id, "files")
 
 	edges, _, err := p.Build()
 	if err != nil {
@@ -201,6 +291,45 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 		return nil, errors.WithContext(err, "resolving cross-language artifacts")
 	}
 	opts.ArtifactURLs = artifactURLs
+
+	if *dryRun || *jobopts.ValidateOnly {
+		log.Info(ctx, "Dry-run: not submitting job!")
+
+		model, err := marshalModel(ctx, edges)
+		if err != nil {
+			return nil, err
+		}
+		log.Info(ctx, proto.MarshalTextString(model))
+		job, err := dataflowlib.Translate(ctx, model, opts, workerURL, jarURL, modelURL)
+		if err != nil {
+			return nil, err
+		}
+		dataflowlib.PrintJob(ctx, job)
+		return nil, nil
+	}
+
+	if err := jobopts.StageFiles(); err != nil {
+		return nil, err
+	}
+	model, err := marshalModel(ctx, edges)
+	if err != nil {
+		return nil, err
+	}
+	fileURLs, err := dataflowlib.StageFileDependencies(ctx, model, opts.Project, filesURL)
+	if err != nil {
+		return nil, errors.WithContext(err, "staging files")
+	}
+	opts.ArtifactURLs = append(opts.ArtifactURLs, fileURLs...)
+
+	return dataflowlib.Execute(ctx, model, opts, workerURL, jarURL, modelURL, *endpoint, *executeAsync)
+}
+
+// marshalModel returns the model pipeline of the edges, running in the Go
+// environment with the container image overrides applied.
+func marshalModel(ctx context.Context, edges []*graph.MultiEdge) (*pipepb.Pipeline, error) {
+	if err := graphx.CheckStateless(edges); err != nil {
+		return nil, err
+	}
 	environment, err := graphx.CreateEnvironment(ctx, jobopts.GetEnvironmentUrn(ctx), getContainerImage)
 	if err != nil {
 		return nil, errors.WithContext(err, "creating environment for model pipeline")
@@ -209,25 +338,53 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 	if err != nil {
 		return nil, errors.WithContext(err, "generating model pipeline")
 	}
-	err = pipelinex.ApplySdkImageOverrides(model, jobopts.GetSdkImageOverrides())
-	if err != nil {
+	if err := pipelinex.ApplySdkImageOverrides(model, jobopts.GetSdkImageOverrides()); err != nil {
 		return nil, errors.WithContext(err, "applying container image overrides")
 	}
+	return model, nil
+}
 
-	if *dryRun {
-		log.Info(ctx, "Dry-run: not submitting job!")
-
-		log.Info(ctx, proto.MarshalTextString(model))
-		job, err := dataflowlib.Translate(ctx, model, opts, workerURL, jarURL, modelURL)
-		if err != nil {
-			return nil, err
+// getServiceOptions returns the Dataflow service options of the job, which
+// include enable_prime with --enable_prime.
+func getServiceOptions() ([]string, error) {
+	var ret []string
+	seen := make(map[string]bool)
+	add := func(o string) {
+		if !seen[o] {
+			seen[o] = true
+			ret = append(ret, o)
+		}
+	}
+	if *serviceOptions != "" {
+		for _, o := range strings.Split(*serviceOptions, ",") {
+			o = strings.TrimSpace(o)
+			if o == "" {
+				return nil, errors.Errorf("invalid --dataflow_service_options %q: empty service option", *serviceOptions)
+			}
+			add(o)
 		}
-		dataflowlib.PrintJob(ctx, job)
-		return nil, nil
 	}
+	if *prime {
+		add("enable_prime")
+	}
+	return ret, nil
+}
 
-	return dataflowlib.Execute(ctx, model, opts, workerURL, jarURL, modelURL, *endpoint, *executeAsync)
+// validateImageOverrides checks that the container image overrides are
+// pairs of a valid regular expression and a replacement.
+func validateImageOverrides(overrides []string) error {
+	for _, o := range overrides {
+		splits := strings.SplitN(o, ",", 2)
+		if len(splits) != 2 {
+			return errors.Errorf("invalid --sdk_harness_container_image_override %q. Use --sdk_harness_container_image_override=<regexp>,<image>", o)
+		}
+		if _, err := regexp.Compile(splits[0]); err != nil {
+			return errors.Wrapf(err, "invalid --sdk_harness_container_image_override %q", o)
+		}
+	}
+	return nil
 }
+
 func gcsRecorderHook(opts []string) perf.CaptureHook {
 	bucket, prefix, err := gcsx.ParseObject(opts[0])
 	if err != nil {
@@ -246,6 +403,9 @@ func gcsRecorderHook(opts []string) perf.CaptureHook {
 func getContainerImage(ctx context.Context) string {
 	urn := jobopts.GetEnvironmentUrn(ctx)
 	if urn == "" || urn == "beam:env:docker:v1" {
+		if *sdkContainerImage != "" {
+			return *sdkContainerImage
+		}
 		if *image != "" {
 			return *image
 		}
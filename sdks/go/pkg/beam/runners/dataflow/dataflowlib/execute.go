@@ -21,6 +21,7 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/protox"
@@ -98,6 +99,14 @@ func Execute(ctx context.Context, raw *pipepb.Pipeline, opts *JobOptions, worker
 	if err != nil {
 		return presult, err
 	}
+	if opts.Update {
+		running, err := GetRunningJobByName(ctx, client, opts.Project, opts.Region, opts.Name)
+		if err != nil {
+			return presult, err
+		}
+		log.Infof(ctx, "Updating job: %v", running.Id)
+		job.ReplaceJobId = running.Id
+	}
 	upd, err := Submit(ctx, client, opts.Project, opts.Region, job)
 	if err != nil {
 		return presult, err
@@ -109,22 +118,15 @@ func Execute(ctx context.Context, raw *pipepb.Pipeline, opts *JobOptions, worker
 	log.Infof(ctx, "Logs: https://console.cloud.google.com/logs/viewer?project=%v&resource=dataflow_step%%2Fjob_id%%2F%v", opts.Project, upd.Id)
 
 	presult.jobID = upd.Id
+	presult.client, presult.project, presult.region, presult.p = client, opts.Project, opts.Region, p
 
 	if async {
 		return presult, nil
 	}
 
 	// (4) Wait for completion.
-	err = WaitForCompletion(ctx, client, opts.Project, opts.Region, upd.Id)
-
-	res, presultErr := newDataflowPipelineResult(ctx, client, p, opts.Project, opts.Region, upd.Id)
-	if presultErr != nil {
-		if err != nil {
-			return presult, errors.Wrap(err, presultErr.Error())
-		}
-		return presult, presultErr
-	}
-	return res, err
+	_, err = presult.WaitUntilFinish(ctx, 0)
+	return presult, err
 }
 
 // PrintJob logs the Dataflow job.
@@ -136,23 +138,83 @@ func PrintJob(ctx context.Context, job *df.Job) {
 	log.Info(ctx, string(str))
 }
 
+// dataflowPipelineResult is the result of a pipeline submitted as a Dataflow
+// job. The metrics are those of the job when it was last waited for.
 type dataflowPipelineResult struct {
 	jobID   string
 	metrics *metrics.Results
+
+	client          *df.Service
+	project, region string
+	p               *pipepb.Pipeline
 }
 
-func newDataflowPipelineResult(ctx context.Context, client *df.Service, p *pipepb.Pipeline, project, region, jobID string) (*dataflowPipelineResult, error) {
-	res, err := GetMetrics(ctx, client, project, region, jobID)
+func (pr *dataflowPipelineResult) updateMetrics(ctx context.Context) error {
+	res, err := GetMetrics(ctx, pr.client, pr.project, pr.region, pr.jobID)
 	if err != nil {
-		return &dataflowPipelineResult{jobID, nil}, errors.Wrap(err, "failed to get metrics")
+		return errors.Wrap(err, "failed to get metrics")
 	}
-	return &dataflowPipelineResult{jobID, FromMetricUpdates(res.Metrics, p)}, nil
+	pr.metrics = FromMetricUpdates(res.Metrics, pr.p)
+	return nil
 }
 
-func (pr dataflowPipelineResult) Metrics() metrics.Results {
+// Metrics returns the metrics of the job when it was last waited for, which
+// are empty if it never was.
+func (pr *dataflowPipelineResult) Metrics() metrics.Results {
+	if pr.metrics == nil {
+		return metrics.Results{}
+	}
 	return *pr.metrics
 }
 
-func (pr dataflowPipelineResult) JobID() string {
+func (pr *dataflowPipelineResult) JobID() string {
 	return pr.jobID
 }
+
+func (pr *dataflowPipelineResult) submitted() error {
+	if pr.client == nil {
+		return errors.New("job was not submitted")
+	}
+	return nil
+}
+
+// State returns the current state of the job, such as JOB_STATE_RUNNING.
+func (pr *dataflowPipelineResult) State(ctx context.Context) (string, error) {
+	if err := pr.submitted(); err != nil {
+		return "", err
+	}
+	return GetJobState(ctx, pr.client, pr.project, pr.region, pr.jobID)
+}
+
+// WaitUntilFinish waits until the job reaches a terminal state, or until the
+// timeout passes if it is positive, and returns its last state, as
+// WaitUntilFinish does. The metrics of the result are updated once the job
+// has finished.
+func (pr *dataflowPipelineResult) WaitUntilFinish(ctx context.Context, timeout time.Duration) (string, error) {
+	if err := pr.submitted(); err != nil {
+		return "", err
+	}
+	state, err := WaitUntilFinish(ctx, pr.client, pr.project, pr.region, pr.jobID, timeout)
+	if IsTerminal(state) {
+		if merr := pr.updateMetrics(ctx); merr != nil && err == nil {
+			err = merr
+		}
+	}
+	return state, err
+}
+
+// Cancel requests the job to be cancelled, as Cancel does.
+func (pr *dataflowPipelineResult) Cancel(ctx context.Context) error {
+	if err := pr.submitted(); err != nil {
+		return err
+	}
+	return Cancel(ctx, pr.client, pr.project, pr.region, pr.jobID)
+}
+
+// Drain requests the job to be drained, as Drain does.
+func (pr *dataflowPipelineResult) Drain(ctx context.Context) error {
+	if err := pr.submitted(); err != nil {
+		return err
+	}
+	return Drain(ctx, pr.client, pr.project, pr.region, pr.jobID)
+}
@@ -63,6 +63,16 @@ type JobOptions struct {
 	Algorithm     string
 	MaxNumWorkers int64
 
+	// StreamingEngine runs streaming jobs on Streaming Engine, which moves
+	// their state and shuffles from the workers into the service.
+	StreamingEngine bool
+	// ServiceOptions are the Dataflow service options to enable, such as
+	// enable_prime.
+	ServiceOptions []string
+	// FlexRSGoal is the Flexible Resource Scheduling goal of batch jobs,
+	// either COST_OPTIMIZED or SPEED_OPTIMIZED.
+	FlexRSGoal string
+
 	TempLocation string
 
 	// Worker is the worker binary override.
@@ -70,6 +80,13 @@ type JobOptions struct {
 	// WorkerJar is a custom worker jar.
 	WorkerJar string
 
+	// Update replaces the running job with the same name by the submitted
+	// job, which takes over its state.
+	Update bool
+	// TransformNameMapping maps the names of transforms of the replaced job
+	// to their names in the submitted job, for transforms that are renamed.
+	TransformNameMapping map[string]string
+
 	// -- Internal use only. Not supported in public Dataflow. --
 
 	TeardownPolicy string
@@ -147,6 +164,16 @@ func Translate(ctx context.Context, p *pipepb.Pipeline, opts *JobOptions, worker
 	if err := validateWorkerSettings(ctx, opts); err != nil {
 		return nil, err
 	}
+	if err := validateServiceSettings(opts, streaming); err != nil {
+		return nil, err
+	}
+	if opts.StreamingEngine {
+		experiments = append(experiments, "enable_streaming_engine", "enable_windmill_service")
+	}
+	flexRSGoal := ""
+	if opts.FlexRSGoal != "" {
+		flexRSGoal = "FLEXRS_" + opts.FlexRSGoal
+	}
 
 	job := &df.Job{
 		ProjectId: opts.Project,
@@ -187,13 +214,16 @@ func Translate(ctx context.Context, p *pipepb.Pipeline, opts *JobOptions, worker
 				Subnetwork:                  opts.Subnetwork,
 				Zone:                        opts.Zone,
 			}},
-			WorkerRegion:      opts.WorkerRegion,
-			WorkerZone:        opts.WorkerZone,
-			TempStoragePrefix: opts.TempLocation,
-			Experiments:       experiments,
+			WorkerRegion:               opts.WorkerRegion,
+			WorkerZone:                 opts.WorkerZone,
+			TempStoragePrefix:          opts.TempLocation,
+			Experiments:                experiments,
+			ServiceOptions:             opts.ServiceOptions,
+			FlexResourceSchedulingGoal: flexRSGoal,
 		},
-		Labels: opts.Labels,
-		Steps:  steps,
+		Labels:               opts.Labels,
+		Steps:                steps,
+		TransformNameMapping: opts.TransformNameMapping,
 	}
 
 	workerPool := job.Environment.WorkerPools[0]
@@ -223,26 +253,92 @@ func Submit(ctx context.Context, client *df.Service, project, region string, job
 	return client.Projects.Locations.Jobs.Create(project, region, job).Do()
 }
 
+// GetRunningJobByName returns the running job with the given name, which is
+// unique among the active jobs of a project and region.
+func GetRunningJobByName(ctx context.Context, client *df.Service, project, region, name string) (*df.Job, error) {
+	var job *df.Job
+	err := client.Projects.Locations.Jobs.List(project, region).Filter("ACTIVE").Pages(ctx, func(resp *df.ListJobsResponse) error {
+		for _, j := range resp.Jobs {
+			if j.Name == name {
+				job = j
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list jobs")
+	}
+	if job == nil {
+		return nil, errors.Errorf("no running job named %v to update", name)
+	}
+	return job, nil
+}
+
+// Drain requests the job to be drained. A drained job stops reading from its
+// sources, finishes processing the data it has read, and then stops, so no
+// data is lost. Drain does not wait for the job to stop.
+func Drain(ctx context.Context, client *df.Service, project, region, jobID string) error {
+	return requestState(ctx, client, project, region, jobID, "JOB_STATE_DRAINED")
+}
+
+// Cancel requests the job to be cancelled. A cancelled job stops immediately,
+// and the data it is processing is lost. Cancel does not wait for the job to
+// stop.
+func Cancel(ctx context.Context, client *df.Service, project, region, jobID string) error {
+	return requestState(ctx, client, project, region, jobID, "JOB_STATE_CANCELLED")
+}
+
+func requestState(ctx context.Context, client *df.Service, project, region, jobID, state string) error {
+	_, err := client.Projects.Locations.Jobs.Update(project, region, jobID, &df.Job{RequestedState: state}).Context(ctx).Do()
+	if err != nil {
+		return errors.Wrapf(err, "failed to request state %v of job %v", state, jobID)
+	}
+	return nil
+}
+
+// pollInterval is the interval between polls of the state of a job.
+var pollInterval = 30 * time.Second
+
 // WaitForCompletion monitors the given job until completion. It logs any messages
 // and state changes received.
 func WaitForCompletion(ctx context.Context, client *df.Service, project, region, jobID string) error {
+	_, err := WaitUntilFinish(ctx, client, project, region, jobID, 0)
+	return err
+}
+
+// WaitUntilFinish monitors the given job until it reaches a terminal state,
+// or until the timeout passes if it is positive, and returns its last state.
+// It logs the states of the job and returns an error if the job failed.
+func WaitUntilFinish(ctx context.Context, client *df.Service, project, region, jobID string, timeout time.Duration) (string, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
 	for {
-		j, err := client.Projects.Locations.Jobs.Get(project, region, jobID).Do()
+		j, err := client.Projects.Locations.Jobs.Get(project, region, jobID).Context(ctx).Do()
 		if err != nil {
-			return errors.Wrap(err, "failed to get job")
+			return "", errors.Wrap(err, "failed to get job")
 		}
 
 		switch j.CurrentState {
 		case "JOB_STATE_DONE":
 			log.Info(ctx, "Job succeeded!")
-			return nil
+			return j.CurrentState, nil
 
 		case "JOB_STATE_CANCELLED":
 			log.Info(ctx, "Job cancelled")
-			return nil
+			return j.CurrentState, nil
+
+		case "JOB_STATE_DRAINED":
+			log.Info(ctx, "Job drained")
+			return j.CurrentState, nil
+
+		case "JOB_STATE_UPDATED":
+			log.Infof(ctx, "Job updated, replaced by job %v", j.ReplacedByJobId)
+			return j.CurrentState, nil
 
 		case "JOB_STATE_FAILED":
-			return errors.Errorf("job %s failed", jobID)
+			return j.CurrentState, errors.Errorf("job %s failed", jobID)
 
 		case "JOB_STATE_RUNNING":
 			log.Info(ctx, "Job still running ...")
@@ -251,7 +347,41 @@ func WaitForCompletion(ctx context.Context, client *df.Service, project, region,
 			log.Infof(ctx, "Job state: %v ...", j.CurrentState)
 		}
 
-		time.Sleep(30 * time.Second)
+		wait := pollInterval
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return j.CurrentState, nil
+			}
+			if remaining < wait {
+				wait = remaining
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return j.CurrentState, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// GetJobState returns the current state of the job.
+func GetJobState(ctx context.Context, client *df.Service, project, region, jobID string) (string, error) {
+	j, err := client.Projects.Locations.Jobs.Get(project, region, jobID).Context(ctx).Do()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get job")
+	}
+	return j.CurrentState, nil
+}
+
+// IsTerminal returns whether the job state is terminal, in which case the
+// job has stopped and its state no longer changes.
+func IsTerminal(state string) bool {
+	switch state {
+	case "JOB_STATE_DONE", "JOB_STATE_FAILED", "JOB_STATE_CANCELLED", "JOB_STATE_UPDATED", "JOB_STATE_DRAINED":
+		return true
+	default:
+		return false
 	}
 }
 
@@ -295,6 +425,8 @@ func printOptions(opts *JobOptions, images []string) []*displayData {
 
 	addIfNonEmpty("name", opts.Name)
 	addIfNonEmpty("experiments", strings.Join(opts.Experiments, ","))
+	addIfNonEmpty("dataflow_service_options", strings.Join(opts.ServiceOptions, ","))
+	addIfNonEmpty("flexrs_goal", opts.FlexRSGoal)
 	addIfNonEmpty("project", opts.Project)
 	addIfNonEmpty("region", opts.Region)
 	addIfNonEmpty("zone", opts.Zone)
@@ -360,3 +492,20 @@ func validateWorkerSettings(ctx context.Context, opts *JobOptions) error {
 	}
 	return nil
 }
+
+func validateServiceSettings(opts *JobOptions, streaming bool) error {
+	switch opts.FlexRSGoal {
+	case "", "COST_OPTIMIZED", "SPEED_OPTIMIZED":
+	default:
+		return errors.Errorf("invalid flexrs_goal %q; use COST_OPTIMIZED or SPEED_OPTIMIZED", opts.FlexRSGoal)
+	}
+	if opts.FlexRSGoal != "" && streaming {
+		return errors.New("flexrs_goal is only supported by batch jobs")
+	}
+	for _, o := range opts.ServiceOptions {
+		if o == "" {
+			return errors.New("dataflow_service_options cannot contain empty options")
+		}
+	}
+	return nil
+}
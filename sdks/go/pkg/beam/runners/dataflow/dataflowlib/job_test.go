@@ -17,8 +17,18 @@ package dataflowlib
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	df "google.golang.org/api/dataflow/v1b3"
+	"google.golang.org/api/option"
 )
 
 func TestValidateWorkerSettings(t *testing.T) {
@@ -157,3 +167,163 @@ func TestValidateWorkerSettings(t *testing.T) {
 		})
 	}
 }
+
+func TestTranslate_TransformNameMapping(t *testing.T) {
+	mapping := map[string]string{"oldName": "newName"}
+	opts := &JobOptions{
+		Name:                 "job",
+		Experiments:          []string{"use_portable_job_submission"},
+		Update:               true,
+		TransformNameMapping: mapping,
+	}
+	job, err := Translate(context.Background(), &pipepb.Pipeline{}, opts, "worker", "jar", "model")
+	if err != nil {
+		t.Fatalf("Translate failed: %v", err)
+	}
+	if !reflect.DeepEqual(job.TransformNameMapping, mapping) {
+		t.Errorf("job transform name mapping = %v, want %v", job.TransformNameMapping, mapping)
+	}
+}
+
+func TestTranslate_ServiceSettings(t *testing.T) {
+	opts := &JobOptions{
+		Name:            "job",
+		Experiments:     []string{"use_portable_job_submission"},
+		StreamingEngine: true,
+		ServiceOptions:  []string{"enable_prime"},
+		FlexRSGoal:      "COST_OPTIMIZED",
+	}
+	job, err := Translate(context.Background(), &pipepb.Pipeline{}, opts, "worker", "jar", "model")
+	if err != nil {
+		t.Fatalf("Translate failed: %v", err)
+	}
+	env := job.Environment
+	if want := []string{"enable_prime"}; !reflect.DeepEqual(env.ServiceOptions, want) {
+		t.Errorf("service options = %v, want %v", env.ServiceOptions, want)
+	}
+	if got, want := env.FlexResourceSchedulingGoal, "FLEXRS_COST_OPTIMIZED"; got != want {
+		t.Errorf("FlexRS goal = %v, want %v", got, want)
+	}
+	experiments := strings.Join(env.Experiments, ",")
+	if !strings.Contains(experiments, "enable_streaming_engine") || !strings.Contains(experiments, "enable_windmill_service") {
+		t.Errorf("experiments = %v, want Streaming Engine experiments", experiments)
+	}
+}
+
+func TestTranslate_InvalidServiceSettings(t *testing.T) {
+	streaming := &pipepb.Pipeline{
+		Components: &pipepb.Components{
+			Pcollections: map[string]*pipepb.PCollection{
+				"p": {IsBounded: pipepb.IsBounded_UNBOUNDED},
+			},
+		},
+	}
+	tests := []struct {
+		name string
+		p    *pipepb.Pipeline
+		opts JobOptions
+	}{
+		{"invalid_flexrs_goal", &pipepb.Pipeline{}, JobOptions{FlexRSGoal: "CHEAP"}},
+		{"streaming_flexrs", streaming, JobOptions{FlexRSGoal: "COST_OPTIMIZED"}},
+		{"empty_service_option", &pipepb.Pipeline{}, JobOptions{ServiceOptions: []string{""}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.Experiments = []string{"use_portable_job_submission"}
+			if _, err := Translate(context.Background(), test.p, &test.opts, "worker", "jar", "model"); err == nil {
+				t.Error("Translate succeeded, want error")
+			}
+		})
+	}
+}
+
+// fakeJobServer serves the Dataflow job states in order, repeating the last
+// one, and records the requested states of the job.
+type fakeJobServer struct {
+	mu        sync.Mutex
+	states    []string
+	requested []string
+}
+
+func (f *fakeJobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/metrics"):
+		json.NewEncoder(w).Encode(&df.JobMetrics{})
+	case r.Method == http.MethodPut:
+		var j df.Job
+		json.NewDecoder(r.Body).Decode(&j)
+		f.requested = append(f.requested, j.RequestedState)
+		json.NewEncoder(w).Encode(&j)
+	default:
+		state := f.states[0]
+		if len(f.states) > 1 {
+			f.states = f.states[1:]
+		}
+		json.NewEncoder(w).Encode(&df.Job{Id: "job", CurrentState: state})
+	}
+}
+
+func newFakeJobClient(t *testing.T, f *fakeJobServer) *df.Service {
+	t.Helper()
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	client, err := df.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestWaitUntilFinish(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		states  []string
+		timeout time.Duration
+		want    string
+		wantErr bool
+	}{
+		{"done", []string{"JOB_STATE_PENDING", "JOB_STATE_RUNNING", "JOB_STATE_DONE"}, 0, "JOB_STATE_DONE", false},
+		{"failed", []string{"JOB_STATE_RUNNING", "JOB_STATE_FAILED"}, 0, "JOB_STATE_FAILED", true},
+		{"cancelled", []string{"JOB_STATE_CANCELLED"}, 0, "JOB_STATE_CANCELLED", false},
+		{"timeout", []string{"JOB_STATE_RUNNING"}, 10 * time.Millisecond, "JOB_STATE_RUNNING", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeJobClient(t, &fakeJobServer{states: test.states})
+			pr := &dataflowPipelineResult{jobID: "job", client: client, project: "project", region: "region"}
+			state, err := pr.WaitUntilFinish(ctx, test.timeout)
+			if (err != nil) != test.wantErr {
+				t.Errorf("WaitUntilFinish() error = %v, want error %v", err, test.wantErr)
+			}
+			if state != test.want {
+				t.Errorf("WaitUntilFinish() = %v, want %v", state, test.want)
+			}
+		})
+	}
+}
+
+func TestDataflowPipelineResult_Cancel(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeJobServer{states: []string{"JOB_STATE_RUNNING"}}
+	pr := &dataflowPipelineResult{jobID: "job", client: newFakeJobClient(t, f), project: "project", region: "region"}
+
+	if state, err := pr.State(ctx); err != nil || state != "JOB_STATE_RUNNING" {
+		t.Errorf("State() = %v, %v, want JOB_STATE_RUNNING", state, err)
+	}
+	if err := pr.Cancel(ctx); err != nil {
+		t.Fatalf("Cancel() failed: %v", err)
+	}
+	if want := []string{"JOB_STATE_CANCELLED"}; !reflect.DeepEqual(f.requested, want) {
+		t.Errorf("requested states = %v, want %v", f.requested, want)
+	}
+	if err := (&dataflowPipelineResult{}).Cancel(ctx); err == nil {
+		t.Error("Cancel() of an unsubmitted job succeeded, want error")
+	}
+}
@@ -26,8 +26,9 @@ import (
 
 func TestFromMetricUpdates_Counters(t *testing.T) {
 	want := metrics.CounterResult{
-		Attempted: 15,
-		Committed: 15,
+		Attempted:    15,
+		Committed:    15,
+		HasCommitted: true,
 		Key: metrics.StepKey{
 			Step:      "main.customDoFn",
 			Name:      "customCounter",
@@ -69,6 +70,7 @@ func TestFromMetricUpdates_Distributions(t *testing.T) {
 			Min:   -12,
 			Max:   30,
 		},
+		HasCommitted: true,
 		Key: metrics.StepKey{
 			Step:      "main.customDoFn",
 			Name:      "customDist",
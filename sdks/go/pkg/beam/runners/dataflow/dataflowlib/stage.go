@@ -20,12 +20,18 @@ import (
 	"context"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"cloud.google.com/go/storage"
+	"github.com/apache/beam/sdks/go/pkg/beam/artifact"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/protox"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
 	"github.com/apache/beam/sdks/go/pkg/beam/util/gcsx"
+	"github.com/golang/protobuf/proto"
 )
 
 // StageModel uploads the pipeline model to GCS as a unique object.
@@ -81,3 +87,33 @@ func ResolveXLangArtifacts(ctx context.Context, edges []*graph.MultiEdge, projec
 	}
 	return urls, nil
 }
+
+// StageFileDependencies uploads the local files the environments of the
+// pipeline depend on, such as the files staged with artifact.StageFile, to
+// the given GCS URL, and replaces their paths in the environments by their
+// GCS URLs. It returns the URLs of the staged files.
+func StageFileDependencies(ctx context.Context, p *pipepb.Pipeline, project, url string) ([]string, error) {
+	var urls []string
+	for _, env := range p.GetComponents().GetEnvironments() {
+		for _, dep := range env.GetDependencies() {
+			if dep.GetTypeUrn() != artifact.URNFileArtifact {
+				continue
+			}
+			var payload pipepb.ArtifactFilePayload
+			if err := proto.Unmarshal(dep.GetTypePayload(), &payload); err != nil {
+				return nil, errors.Wrap(err, "failed to parse artifact file payload")
+			}
+			if strings.HasPrefix(payload.GetPath(), "gs://") {
+				continue
+			}
+			remote := gcsx.Join(url, filepath.Base(payload.GetPath()))
+			if err := StageFile(ctx, project, remote, payload.GetPath()); err != nil {
+				return nil, errors.WithContextf(err, "staging file to %v", remote)
+			}
+			payload.Path = remote
+			dep.TypePayload = protox.MustEncode(&payload)
+			urls = append(urls, remote)
+		}
+	}
+	return urls, nil
+}
@@ -0,0 +1,225 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flextemplate builds and launches Dataflow Flex Templates of Go
+// pipelines, so they can be launched with parameters by users who don't build
+// them, from the Cloud Console, gcloud or the Dataflow API.
+//
+// The parameters of a template are declared as the fields of a struct, with
+// the name of each parameter in its `flag` tag. The fields of the struct are
+// bound to flags, the launched pipeline reads the parameter values from its
+// flags, and the metadata of the template describes the same parameters:
+//
+//	type Options struct {
+//		Input  string `flag:"input" help:"Files to read." regex:"^gs://.+"`
+//		Output string `flag:"output" help:"Prefix of the files to write."`
+//		Shards int    `flag:"shards" help:"Number of output files." optional:"true"`
+//	}
+//
+//	var opts = Options{Shards: 10}
+//
+//	func init() {
+//		if err := flextemplate.BindFlags(&opts); err != nil {
+//			panic(err)
+//		}
+//	}
+//
+// The pipeline binary is built into a container image, for example with the
+// Dockerfile returned by Dockerfile, and the template is the container spec
+// written by Build, which names the image and holds the metadata:
+//
+//	spec, err := flextemplate.NewContainerSpec("gcr.io/project/wordcount", "wordcount", "Counts words.", &opts)
+//	...
+//	err = flextemplate.Build(ctx, "project", "gs://bucket/templates/wordcount.json", spec)
+//
+// The same is done by the flextemplate command, from a metadata file.
+//
+// Experimental.
+package flextemplate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/apache/beam/sdks/go/pkg/beam/core"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/util/gcsx"
+	df "google.golang.org/api/dataflow/v1b3"
+)
+
+// Parameters returns the metadata of the template parameters declared by the
+// fields of the struct opts points to. Fields without a `flag` tag are not
+// parameters. The `help`, `label`, `regex` and `optional` tags set the help
+// text, label, validation regex and optionality of a parameter.
+func Parameters(opts interface{}) ([]*df.ParameterMetadata, error) {
+	fields, err := parameterFields(opts)
+	if err != nil {
+		return nil, err
+	}
+	var params []*df.ParameterMetadata
+	for _, f := range fields {
+		name := f.Tag.Get("flag")
+		label := f.Tag.Get("label")
+		if label == "" {
+			label = name
+		}
+		param := &df.ParameterMetadata{
+			Name:     name,
+			Label:    label,
+			HelpText: f.Tag.Get("help"),
+		}
+		if opt, ok := f.Tag.Lookup("optional"); ok {
+			if param.IsOptional, err = strconv.ParseBool(opt); err != nil {
+				return nil, errors.Wrapf(err, "invalid optional tag of field %v", f.Name)
+			}
+		}
+		if regex := f.Tag.Get("regex"); regex != "" {
+			param.Regexes = []string{regex}
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// BindFlags defines a command line flag for each template parameter declared
+// by the fields of the struct opts points to, which sets the field. The
+// current values of the fields are the defaults of the flags.
+func BindFlags(opts interface{}) error {
+	return bindFlags(flag.CommandLine, opts)
+}
+
+func bindFlags(fs *flag.FlagSet, opts interface{}) error {
+	fields, err := parameterFields(opts)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(opts).Elem()
+	for _, f := range fields {
+		name, help := f.Tag.Get("flag"), f.Tag.Get("help")
+		p := v.FieldByIndex(f.Index).Addr().Interface()
+		switch p := p.(type) {
+		case *string:
+			fs.StringVar(p, name, *p, help)
+		case *bool:
+			fs.BoolVar(p, name, *p, help)
+		case *int:
+			fs.IntVar(p, name, *p, help)
+		case *int64:
+			fs.Int64Var(p, name, *p, help)
+		case *float64:
+			fs.Float64Var(p, name, *p, help)
+		case *time.Duration:
+			fs.DurationVar(p, name, *p, help)
+		default:
+			return errors.Errorf("parameter %v has unsupported type %v, want string, bool, int, int64, float64 or time.Duration", name, f.Type)
+		}
+	}
+	return nil
+}
+
+// parameterFields returns the fields with a `flag` tag of the struct opts
+// points to.
+func parameterFields(opts interface{}) ([]reflect.StructField, error) {
+	t := reflect.TypeOf(opts)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, errors.Errorf("template parameters must be declared by a pointer to a struct, got %v", t)
+	}
+	t = t.Elem()
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Tag.Get("flag") != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// NewContainerSpec returns the container spec of a template launching the
+// pipeline in the given image, with the parameters declared by opts.
+func NewContainerSpec(image, name, description string, opts interface{}) (*df.ContainerSpec, error) {
+	params, err := Parameters(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &df.ContainerSpec{
+		Image: image,
+		Metadata: &df.TemplateMetadata{
+			Name:        name,
+			Description: description,
+			Parameters:  params,
+		},
+		SdkInfo: &df.SDKInfo{Language: "GO", Version: core.SdkVersion},
+	}, nil
+}
+
+// Build writes the container spec to GCS at the given URL, where it is the
+// template launched by Launch.
+func Build(ctx context.Context, project, templateURL string, spec *df.ContainerSpec) error {
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode container spec")
+	}
+	bucket, obj, err := gcsx.ParseObject(templateURL)
+	if err != nil {
+		return errors.Wrapf(err, "invalid template location %v", templateURL)
+	}
+	client, err := gcsx.NewClient(ctx, storage.ScopeReadWrite)
+	if err != nil {
+		return err
+	}
+	if _, err := gcsx.Upload(ctx, client, project, bucket, obj, bytes.NewReader(data)); err != nil {
+		return errors.Wrapf(err, "failed to write template to %v", templateURL)
+	}
+	return nil
+}
+
+// launcherImage is the base image of Go Flex Template containers, which
+// launches the pipeline binary named by the FLEX_TEMPLATE_GO_BINARY
+// environment variable with the parameters as flags.
+const launcherImage = "gcr.io/dataflow-templates-base/go-template-launcher-base"
+
+// Dockerfile returns a Dockerfile for the image of a template, which copies the
+// pipeline binary at the given path of the build context into the image.
+// The binary must be built for linux/amd64.
+func Dockerfile(binary string) string {
+	return fmt.Sprintf(`FROM %v
+COPY %v /template/pipeline
+ENV FLEX_TEMPLATE_GO_BINARY=/template/pipeline
+`, launcherImage, binary)
+}
+
+// Launch launches a job from the template at the given URL, passing it the
+// parameters. It returns the launched job, without waiting for it.
+func Launch(ctx context.Context, client *df.Service, project, region, templateURL, jobName string, params map[string]string) (*df.Job, error) {
+	req := &df.LaunchFlexTemplateRequest{
+		LaunchParameter: &df.LaunchFlexTemplateParameter{
+			ContainerSpecGcsPath: templateURL,
+			JobName:              jobName,
+			Parameters:           params,
+		},
+	}
+	resp, err := client.Projects.Locations.FlexTemplates.Launch(project, region, req).Context(ctx).Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to launch template %v", templateURL)
+	}
+	return resp.Job, nil
+}
@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flextemplate
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	df "google.golang.org/api/dataflow/v1b3"
+)
+
+type options struct {
+	Input   string        `flag:"input" help:"Files to read." regex:"^gs://.+"`
+	Shards  int           `flag:"shards" label:"Shards" optional:"true"`
+	Timeout time.Duration `flag:"timeout" optional:"true"`
+	Ignored string
+}
+
+func TestParameters(t *testing.T) {
+	params, err := Parameters(&options{})
+	if err != nil {
+		t.Fatalf("Parameters failed: %v", err)
+	}
+	want := []*df.ParameterMetadata{
+		{Name: "input", Label: "input", HelpText: "Files to read.", Regexes: []string{"^gs://.+"}},
+		{Name: "shards", Label: "Shards", IsOptional: true},
+		{Name: "timeout", Label: "timeout", IsOptional: true},
+	}
+	if diff := cmp.Diff(want, params); diff != "" {
+		t.Errorf("Parameters() differ (-want +got):\n%v", diff)
+	}
+
+	if _, err := Parameters(options{}); err == nil {
+		t.Error("Parameters(struct) succeeded, want error for non-pointer")
+	}
+}
+
+func TestBindFlags(t *testing.T) {
+	opts := options{Shards: 10}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := bindFlags(fs, &opts); err != nil {
+		t.Fatalf("bindFlags failed: %v", err)
+	}
+	if err := fs.Parse([]string{"--input=gs://bucket/file", "--timeout=1m"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := options{Input: "gs://bucket/file", Shards: 10, Timeout: time.Minute}
+	if opts != want {
+		t.Errorf("bound options = %+v, want %+v", opts, want)
+	}
+
+	bad := struct {
+		Values []string `flag:"values"`
+	}{}
+	if err := bindFlags(flag.NewFlagSet("test", flag.ContinueOnError), &bad); err == nil {
+		t.Error("bindFlags succeeded, want error for unsupported field type")
+	}
+}
@@ -15,21 +15,62 @@
 
 // Package direct contains the direct runner for running single-bundle
 // pipelines in the current process. Useful for testing.
+//
+// In streaming mode, which is enabled with --direct_streaming or by unbounded
+// PCollections in the pipeline, the direct runner simulates the watermark of
+// the pipeline. Splittable DoFns are read as sources whose restrictions are
+// checkpointed after --direct_checkpoint_interval, so unbounded restrictions
+// are read in turns, and the watermark advances with the event times they
+// emit. Groupings emit each window with the default trigger, once the
+// watermark passes its end, rather than waiting for all input.
+//
+// TestStreams are replayed in streaming mode: their elements are emitted with
+// their timestamps, and their watermark and processing time advancements
+// advance the clock of the pipeline, so streaming behavior can be tested
+// deterministically.
+//
+// In batch mode, the stages following groupings and reshuffles are replicated
+// --direct_parallelism times, and their input is processed concurrently by the
+// replicas. Grouped elements with the same key are processed by the same
+// replica, and each replica has its own copy of the DoFns of the stage. Stages
+// that read side inputs are not replicated.
+//
+// Stateful DoFns keep their state and timers in memory, for each key and
+// window of their input. Their timers fire as the watermark and processing
+// time of the clock pass them in streaming mode, and at the end of their
+// input otherwise. Stages with stateful DoFns are not replicated.
+//
+// The pipeline executes as a single bundle, whose finalization callbacks run
+// once it completes. Since its output is processed in memory as it's emitted,
+// the callbacks registered so far also run each time a splittable DoFn is
+// checkpointed in streaming mode, so that sources acknowledging their input in
+// finalization callbacks keep reading.
 package direct
 
 import (
 	"context"
+	"flag"
 	"path"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
 	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
 	"github.com/apache/beam/sdks/go/pkg/beam/runners/vet"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	streaming          = flag.Bool("direct_streaming", false, "Execute the pipeline in streaming mode with the direct runner (optional).")
+	checkpointInterval = flag.Duration("direct_checkpoint_interval", time.Second, "Duration splittable DoFns read a restriction before it is checkpointed, in streaming mode with the direct runner (optional).")
+	parallelism        = flag.Int("direct_parallelism", 1, "Number of replicas of each stage after a grouping or reshuffle that process its input concurrently, in batch mode with the direct runner (optional).")
 )
 
 func init() {
@@ -60,27 +101,87 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid pipeline")
 	}
-	plan, err := Compile(edges)
+	var c *clock
+	if *streaming || !bounded(edges) {
+		log.Info(ctx, "Executing pipeline in streaming mode.")
+		c = newClock()
+	}
+	plan, err := compile(edges, c)
 	if err != nil {
 		return nil, errors.Wrap(err, "translation failed")
 	}
 	log.Info(ctx, plan)
+	if *jobopts.ValidateOnly {
+		// Pipelines run by other runners are translated to model pipelines,
+		// so the translation is validated too.
+		if _, err := graphx.Marshal(edges, &graphx.Options{}); err != nil {
+			return nil, errors.WithContext(err, "generating model pipeline")
+		}
+		log.Info(ctx, "Validate-only: pipeline is valid, not running it.")
+		return &directPipelineResult{}, nil
+	}
 
 	if err = plan.Execute(ctx, "", exec.DataContext{}); err != nil {
 		plan.Down(ctx) // ignore any teardown errors
 		return nil, err
 	}
+	if err = plan.Finalize(); err != nil {
+		plan.Down(ctx) // ignore any teardown errors
+		return nil, err
+	}
 	if err = plan.Down(ctx); err != nil {
 		return nil, err
 	}
-	// TODO(lostluck) 2020/01/24: What's the right way to expose the
-	// metrics store for the direct runner?
 	metrics.DumpToLogFromStore(ctx, plan.Store())
-	return nil, nil
+	return &directPipelineResult{metrics: metrics.ResultsFromStore(plan.Store())}, nil
+}
+
+// directPipelineResult is the result of a pipeline executed by the direct
+// runner, with the metrics of its execution.
+type directPipelineResult struct {
+	metrics metrics.Results
+}
+
+func (pr *directPipelineResult) Metrics() metrics.Results {
+	return pr.metrics
+}
+
+// JobID returns the empty string, as the direct runner does not create jobs.
+func (pr *directPipelineResult) JobID() string {
+	return ""
 }
 
 // Compile translates a pipeline to a multi-bundle execution plan.
 func Compile(edges []*graph.MultiEdge) (*exec.Plan, error) {
+	return compile(edges, nil)
+}
+
+// bounded returns whether every PCollection of the pipeline is bounded.
+func bounded(edges []*graph.MultiEdge) bool {
+	for _, edge := range edges {
+		for _, out := range edge.Output {
+			if !out.To.Bounded() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// stepName returns the name of the step of the edge for its metrics, which is
+// the path of the edge within its composite scopes, as named by the other
+// runners, such as "CountWords/main.extractFn".
+func stepName(edge *graph.MultiEdge) string {
+	name := path.Base(edge.Name())
+	for s := edge.Scope(); s != nil && s.Parent != nil; s = s.Parent {
+		name = s.Label + "/" + name
+	}
+	return name
+}
+
+// compile translates a pipeline to an execution plan, which runs in streaming
+// mode with the given clock if it is not nil.
+func compile(edges []*graph.MultiEdge, c *clock) (*exec.Plan, error) {
 	// (1) Preprocess graph structure to allow insertion of Multiplex,
 	// Flatten and Discard.
 
@@ -109,6 +210,10 @@ func Compile(edges []*graph.MultiEdge) (*exec.Plan, error) {
 		nodes: make(map[int]exec.Node),
 		links: make(map[linkID]exec.Node),
 		idgen: &exec.GenID{},
+		clock: c,
+	}
+	if c == nil {
+		b.parallelism = *parallelism
 	}
 
 	var roots []exec.Unit
@@ -124,12 +229,40 @@ func Compile(edges []*graph.MultiEdge) (*exec.Plan, error) {
 			u := &Impulse{UID: b.idgen.New(), Value: edge.Value, Out: out}
 			roots = append(roots, u)
 
+		case graph.External:
+			if edge.Payload == nil || edge.Payload.URN != testStreamURN {
+				return nil, errors.Errorf("unexpected external transform: %v", edge)
+			}
+			if b.clock == nil {
+				return nil, errors.Errorf("TestStream requires streaming mode: %v", edge)
+			}
+			var pyld pipepb.TestStreamPayload
+			if err := proto.Unmarshal(edge.Payload.Data, &pyld); err != nil {
+				return nil, errors.Wrapf(err, "invalid TestStream payload: %v", edge)
+			}
+			out, err := b.makeNode(edge.Output[0].To.ID())
+			if err != nil {
+				return nil, err
+			}
+
+			u := &TestStream{UID: b.idgen.New(), Payload: &pyld, Coder: edge.Output[0].To.Coder, Out: out, clock: b.clock}
+			roots = append(roots, u)
+
 		default:
 			// skip non-roots
 		}
 	}
 
-	return exec.NewPlan("plan", append(roots, b.units...))
+	plan, err := exec.NewPlan("plan", append(roots, b.units...))
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range b.units {
+		if n, ok := u.(*streamingSDF); ok {
+			n.finalize = plan.Finalize
+		}
+	}
+	return plan, nil
 }
 
 // linkID represents an incoming data link to an Edge.
@@ -149,6 +282,12 @@ type builder struct {
 
 	units []exec.Unit // result
 	idgen *exec.GenID
+	clock *clock // nil in batch mode
+
+	parallelism int               // replicas of each stage, if > 1
+	region      map[int]bool      // edgeIDs of the replicated stage, if a replica
+	parent      *builder          // builder of the links outside of the stage
+	exits       map[linkID]*merge // linkID -> merge of the replicas (shared)
 }
 
 func (b *builder) makeNodes(out []*graph.Outbound) ([]exec.Node, error) {
@@ -223,26 +362,49 @@ func (b *builder) makeLink(id linkID) (exec.Node, error) {
 	// which exact link triggers the Node generation. The link caching is only needed
 	// to process ParDo side inputs and CoGBK.
 
+	if b.region != nil && !b.region[id.to] {
+		return b.exit(id)
+	}
+
 	edge := b.edges[id.to]
 
-	out, err := b.makeNodes(edge.Output)
-	if err != nil {
+	var out []exec.Node
+	if p, ok, err := b.maybeParallel(edge); err != nil {
+		return nil, err
+	} else if ok {
+		out = []exec.Node{p}
+	} else if out, err = b.makeNodes(edge.Output); err != nil {
 		return nil, err
 	}
 
 	var u exec.Node
 	switch edge.Op {
 	case graph.ParDo:
+		fn := edge.DoFn
+		if b.region != nil {
+			var err error
+			if fn, err = cloneDoFn(fn); err != nil {
+				return nil, err
+			}
+		}
 		pardo := &exec.ParDo{
 			UID:     b.idgen.New(),
-			Fn:      edge.DoFn,
+			Fn:      fn,
 			Inbound: edge.Input,
 			Out:     out,
-			PID:     path.Base(edge.DoFn.Name()),
+			PID:     stepName(edge),
 		}
 		u = pardo
 		if edge.DoFn.IsSplittable() {
 			u = &exec.SdfFallback{PDo: pardo}
+			if b.clock != nil {
+				u = b.makeStreamingSDF(pardo)
+			}
+		} else if edge.DoFn.IsStateful() {
+			var err error
+			if u, err = b.makeStateful(edge, pardo); err != nil {
+				return nil, err
+			}
 		}
 		if len(edge.Input) == 1 {
 			break
@@ -269,17 +431,28 @@ func (b *builder) makeLink(id linkID) (exec.Node, error) {
 
 	case graph.Combine:
 		usesKey := typex.IsKV(edge.Input[0].Type)
+		fn := edge.CombineFn
+		if b.region != nil {
+			var err error
+			if fn, err = cloneCombineFn(fn); err != nil {
+				return nil, err
+			}
+		}
 
 		u = &exec.Combine{
 			UID:     b.idgen.New(),
-			Fn:      edge.CombineFn,
+			Fn:      fn,
 			UsesKey: usesKey,
 			Out:     out[0],
-			PID:     path.Base(edge.CombineFn.Name()),
+			PID:     stepName(edge),
 		}
 
 	case graph.CoGBK:
-		u = &CoGBK{UID: b.idgen.New(), Edge: edge, Out: out[0]}
+		gbk := &CoGBK{UID: b.idgen.New(), Edge: edge, Out: out[0]}
+		if b.clock != nil {
+			b.clock.register(edge.ID(), gbk)
+		}
+		u = gbk
 		b.units = append(b.units, u)
 
 		// CoGBK needs injection of each incoming index. If > 1 incoming,
@@ -300,8 +473,9 @@ func (b *builder) makeLink(id linkID) (exec.Node, error) {
 		return b.links[id], nil
 
 	case graph.Reshuffle:
-		// Reshuffle is a no-op in the direct runner, as there's only a single bundle
-		// on a single worker. Hoist the next node up in the cache.
+		// Reshuffle is a no-op in the direct runner, as there's only a single bundle,
+		// except that the next stage may be replicated. Hoist the next node up in the
+		// cache.
 		b.links[id] = out[0]
 		return b.links[id], nil
 
@@ -323,3 +497,39 @@ func (b *builder) makeLink(id linkID) (exec.Node, error) {
 	b.units = append(b.units, u)
 	return u, nil
 }
+
+// makeStateful wraps a stateful ParDo, keeping its state and timers for each
+// key and window of its input.
+func (b *builder) makeStateful(edge *graph.MultiEdge, pardo *exec.ParDo) (*statefulParDo, error) {
+	in := edge.Input[0].From
+	if !typex.IsKV(in.Type()) && !typex.IsCoGBK(in.Type()) {
+		return nil, errors.Errorf("stateful DoFn %v requires a KV input, got %v", edge.DoFn.Name(), in.Type())
+	}
+	state := make(map[string]*exec.StateCoder)
+	for id, c := range edge.StateCoders {
+		state[id] = exec.NewStateCoder(c)
+	}
+	n := &statefulParDo{StatefulParDo: &exec.StatefulParDo{
+		ParDo:  pardo,
+		Key:    exec.MakeElementEncoder(in.Coder.Components[0]),
+		Window: exec.MakeWindowEncoder(in.WindowingStrategy().Fn.Coder()),
+		State:  state,
+	}}
+	if b.clock != nil {
+		n.ProcessingTime = b.clock.processingTime
+		b.clock.register(edge.ID(), n)
+	}
+	return n, nil
+}
+
+// makeStreamingSDF wraps a splittable ParDo for streaming mode, observing the
+// timestamps of its output.
+func (b *builder) makeStreamingSDF(pardo *exec.ParDo) *streamingSDF {
+	n := &streamingSDF{SdfFallback: &exec.SdfFallback{PDo: pardo}, clock: b.clock, checkpoint: *checkpointInterval}
+	for i, out := range pardo.Out {
+		o := &observer{UID: b.idgen.New(), SDF: n, Out: out}
+		b.units = append(b.units, o)
+		pardo.Out[i] = o
+	}
+	return n
+}
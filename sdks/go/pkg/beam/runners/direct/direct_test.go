@@ -0,0 +1,591 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/teststream"
+)
+
+// countRest is the range of positions [Start, End).
+type countRest struct {
+	Start, End int64
+}
+
+// countTracker tracks a countRest, and checkpoints after the last claimed
+// position.
+type countTracker struct {
+	rest    countRest
+	claimed int64
+	stopped bool
+}
+
+func (t *countTracker) TryClaim(pos interface{}) bool {
+	if t.stopped {
+		return false
+	}
+	p := pos.(int64)
+	if p >= t.rest.End {
+		t.stopped = true
+		return false
+	}
+	t.claimed = p
+	return true
+}
+
+func (t *countTracker) GetError() error { return nil }
+
+func (t *countTracker) TrySplit(float64) (interface{}, interface{}, error) {
+	if t.stopped || t.IsDone() {
+		return t.rest, nil, nil
+	}
+	residual := countRest{Start: t.claimed + 1, End: t.rest.End}
+	t.rest.End = t.claimed + 1
+	return t.rest, residual, nil
+}
+
+func (t *countTracker) GetProgress() (float64, float64) {
+	return float64(t.claimed + 1 - t.rest.Start), float64(t.rest.End - t.claimed - 1)
+}
+
+func (t *countTracker) IsDone() bool {
+	return t.stopped || t.claimed >= t.rest.End-1
+}
+
+func (t *countTracker) GetRestriction() interface{} {
+	return t.rest
+}
+
+// emitted counts the elements emitted by countFn.
+var emitted int64
+
+// countFn emits the position of each element of its restriction, timestamped
+// with the position in seconds.
+type countFn struct {
+	N int64
+}
+
+func (fn *countFn) CreateInitialRestriction(_ []byte) countRest {
+	return countRest{Start: 0, End: fn.N}
+}
+
+func (fn *countFn) SplitRestriction(_ []byte, rest countRest) []countRest {
+	return []countRest{rest}
+}
+
+func (fn *countFn) RestrictionSize(_ []byte, rest countRest) float64 {
+	return float64(rest.End - rest.Start)
+}
+
+func (fn *countFn) CreateTracker(rest countRest) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(&countTracker{rest: rest, claimed: rest.Start - 1})
+}
+
+func (fn *countFn) ProcessElement(rt *sdf.LockRTracker, _ []byte, emit func(beam.EventTime, int64)) {
+	for i := rt.GetRestriction().(countRest).Start; rt.TryClaim(i); i++ {
+		atomic.AddInt64(&emitted, 1)
+		emit(mtime.FromMilliseconds(i*1000), i)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// withStreaming enables streaming mode, and returns a function restoring the
+// batch mode.
+func withStreaming(checkpoint time.Duration) func() {
+	origStreaming, origCheckpoint := *streaming, *checkpointInterval
+	*streaming, *checkpointInterval = true, checkpoint
+	return func() { *streaming, *checkpointInterval = origStreaming, origCheckpoint }
+}
+
+// TestStreaming tests that windows are emitted as the watermark passes them,
+// before their source is done.
+func TestStreaming(t *testing.T) {
+	defer withStreaming(5 * time.Millisecond)()
+	atomic.StoreInt64(&emitted, 0)
+
+	var mu sync.Mutex
+	var firedAt []int64 // Elements emitted by the source when each window fired.
+
+	p, s := beam.NewPipelineWithRoot()
+	nums := beam.ParDo(s, &countFn{N: 40}, beam.Impulse(s))
+	windowed := beam.WindowInto(s, window.NewFixedWindows(4*time.Second), nums)
+	keyed := beam.ParDo(s, func(i int64) (string, int64) { return "k", i }, windowed)
+	sums := beam.ParDo(s, func(_ string, iter func(*int64) bool) int {
+		mu.Lock()
+		firedAt = append(firedAt, atomic.LoadInt64(&emitted))
+		mu.Unlock()
+		var sum, i int64
+		for iter(&i) {
+			sum += i
+		}
+		return int(sum)
+	}, beam.GroupByKey(s, keyed))
+	passert.Sum(s, beam.WindowInto(s, window.NewGlobalWindows(), sums), "sums", 10, 780)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if len(firedAt) != 10 {
+		t.Fatalf("fired %v windows, want 10", len(firedAt))
+	}
+	if firedAt[0] >= 40 {
+		t.Errorf("first window fired after all 40 elements were emitted, want it fired before")
+	}
+}
+
+// echoed counts the elements emitted by echoFn.
+var echoed int64
+
+// echoFn is a splittable DoFn emitting each of its elements once.
+type echoFn struct{}
+
+func (fn *echoFn) CreateInitialRestriction(_ int64) countRest {
+	return countRest{Start: 0, End: 1}
+}
+
+func (fn *echoFn) SplitRestriction(_ int64, rest countRest) []countRest {
+	return []countRest{rest}
+}
+
+func (fn *echoFn) RestrictionSize(_ int64, rest countRest) float64 {
+	return float64(rest.End - rest.Start)
+}
+
+func (fn *echoFn) CreateTracker(rest countRest) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(&countTracker{rest: rest, claimed: rest.Start - 1})
+}
+
+func (fn *echoFn) ProcessElement(et beam.EventTime, rt *sdf.LockRTracker, i int64, emit func(beam.EventTime, int64)) {
+	if rt.TryClaim(int64(0)) {
+		atomic.AddInt64(&echoed, 1)
+		emit(et, i)
+	}
+}
+
+// TestStreaming_ChainedSDFs tests that windows downstream of two chained
+// splittable DoFns are emitted as the watermark passes them, before the
+// second is done.
+func TestStreaming_ChainedSDFs(t *testing.T) {
+	defer withStreaming(5 * time.Millisecond)()
+	atomic.StoreInt64(&echoed, 0)
+
+	var mu sync.Mutex
+	var firedAt []int64 // Elements emitted by echoFn when each window fired.
+
+	p, s := beam.NewPipelineWithRoot()
+	nums := beam.ParDo(s, &echoFn{}, beam.ParDo(s, &countFn{N: 40}, beam.Impulse(s)))
+	windowed := beam.WindowInto(s, window.NewFixedWindows(4*time.Second), nums)
+	keyed := beam.ParDo(s, func(i int64) (string, int64) { return "k", i }, windowed)
+	sums := beam.ParDo(s, func(_ string, iter func(*int64) bool) int {
+		mu.Lock()
+		firedAt = append(firedAt, atomic.LoadInt64(&echoed))
+		mu.Unlock()
+		var sum, i int64
+		for iter(&i) {
+			sum += i
+		}
+		return int(sum)
+	}, beam.GroupByKey(s, keyed))
+	passert.Sum(s, beam.WindowInto(s, window.NewGlobalWindows(), sums), "sums", 10, 780)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if len(firedAt) != 10 {
+		t.Fatalf("fired %v windows, want 10", len(firedAt))
+	}
+	if firedAt[0] >= 40 {
+		t.Errorf("first window fired after all 40 elements were echoed, want it fired before")
+	}
+}
+
+// TestStreaming_Batch tests that the grouping of a batch pipeline is
+// unchanged in streaming mode.
+func TestStreaming_Batch(t *testing.T) {
+	defer withStreaming(time.Millisecond)()
+
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(i int) (int, int) { return i % 2, i }, beam.Create(s, 1, 2, 3, 4, 5))
+	counts := beam.ParDo(s, func(k int, iter func(*int) bool) int {
+		var n, i int
+		for iter(&i) {
+			n++
+		}
+		return k*10 + n
+	}, beam.GroupByKey(s, keyed))
+	passert.Equals(s, counts, 2, 13)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+}
+
+// finalizingFn is a countFn that registers a bundle finalization callback
+// each time it reads its restriction, and records how many callbacks ran
+// before each read.
+type finalizingFn struct {
+	countFn
+
+	mu        sync.Mutex
+	finalized int
+	reads     []int
+}
+
+func (fn *finalizingFn) ProcessElement(bf beam.BundleFinalization, rt *sdf.LockRTracker, b []byte, emit func(beam.EventTime, int64)) {
+	fn.mu.Lock()
+	fn.reads = append(fn.reads, fn.finalized)
+	fn.mu.Unlock()
+	fn.countFn.ProcessElement(rt, b, emit)
+	bf.RegisterCallback(time.Hour, func() error {
+		fn.mu.Lock()
+		defer fn.mu.Unlock()
+		fn.finalized++
+		return nil
+	})
+}
+
+// TestBundleFinalization tests that finalization callbacks run once the
+// pipeline completes.
+func TestBundleFinalization(t *testing.T) {
+	fn := &finalizingFn{countFn: countFn{N: 3}}
+	p, s := beam.NewPipelineWithRoot()
+	passert.Count(s, beam.ParDo(s, fn, beam.Impulse(s)), "nums", 3)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if fn.finalized != len(fn.reads) {
+		t.Errorf("%v callbacks ran, want all %v", fn.finalized, len(fn.reads))
+	}
+}
+
+// TestBundleFinalization_Streaming tests that the finalization callbacks
+// registered by a splittable DoFn run each time it is checkpointed in
+// streaming mode.
+func TestBundleFinalization_Streaming(t *testing.T) {
+	defer withStreaming(5 * time.Millisecond)()
+
+	fn := &finalizingFn{countFn: countFn{N: 40}}
+	p, s := beam.NewPipelineWithRoot()
+	passert.Count(s, beam.ParDo(s, fn, beam.Impulse(s)), "nums", 40)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if len(fn.reads) < 2 {
+		t.Fatalf("read the restriction %v times, want it checkpointed", len(fn.reads))
+	}
+	for i, n := range fn.reads {
+		if n != i {
+			t.Errorf("%v callbacks ran before read %v, want %v", n, i, i)
+		}
+	}
+	if fn.finalized != len(fn.reads) {
+		t.Errorf("%v callbacks ran, want all %v", fn.finalized, len(fn.reads))
+	}
+}
+
+// TestTestStream tests that a TestStream fires windows as its watermark
+// advances, and that its late elements are emitted as further panes.
+func TestTestStream(t *testing.T) {
+	cfg := teststream.NewConfig()
+	cfg.AddElements(1000, "a", "b")
+	cfg.AdvanceWatermark(5000)
+	cfg.AddElements(6000, "c")
+	cfg.AdvanceWatermark(11000)
+	cfg.AddElements(2000, "d")
+
+	var mu sync.Mutex
+	var panes []string
+
+	p, s := beam.NewPipelineWithRoot()
+	elms := teststream.Create(s, cfg)
+	windowed := beam.WindowInto(s, window.NewFixedWindows(5*time.Second), elms)
+	keyed := beam.ParDo(s, func(e string) (string, string) { return "k", e }, windowed)
+	beam.ParDo0(s, func(w beam.Window, _ string, iter func(*string) bool) {
+		var pane, e string
+		for iter(&e) {
+			pane += e
+		}
+		mu.Lock()
+		panes = append(panes, fmt.Sprintf("%v:%v", w.MaxTimestamp(), pane))
+		mu.Unlock()
+	}, beam.GroupByKey(s, keyed))
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if got, want := fmt.Sprint(panes), "[4999:ab 9999:c 4999:d]"; got != want {
+		t.Errorf("fired panes %v, want %v", got, want)
+	}
+}
+
+// TestTestStream_Batch tests that a TestStream is rejected in batch mode.
+func TestTestStream_Batch(t *testing.T) {
+	cfg := teststream.NewConfig()
+	cfg.AddElements(1000, "a")
+
+	p, s := beam.NewPipelineWithRoot()
+	teststream.Create(s, cfg)
+	edges, _, err := p.Build()
+	if err != nil {
+		t.Fatalf("invalid pipeline: %v", err)
+	}
+	if _, err := Compile(edges); err == nil {
+		t.Error("Compile succeeded, want error for TestStream in batch mode")
+	}
+}
+
+// withParallelism sets the parallelism of stages, and returns a function
+// restoring the original.
+func withParallelism(n int) func() {
+	orig := *parallelism
+	*parallelism = n
+	return func() { *parallelism = orig }
+}
+
+// inFlight and maxInFlight count the concurrent calls of slowly.
+var inFlight, maxInFlight int64
+
+// slowly sleeps, counting the concurrent calls.
+func slowly() {
+	n := atomic.AddInt64(&inFlight, 1)
+	defer atomic.AddInt64(&inFlight, -1)
+	for {
+		max := atomic.LoadInt64(&maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+}
+
+// sumFn sums the values of each key, and counts the calls of its replica.
+type sumFn struct {
+	Calls int
+}
+
+func (fn *sumFn) ProcessElement(k int, iter func(*int) bool) int {
+	slowly()
+	fn.Calls++
+	var sum, i int
+	for iter(&i) {
+		sum += i
+	}
+	return sum
+}
+
+// TestParallel tests that the stages after a grouping are replicated, and
+// process their input concurrently.
+func TestParallel(t *testing.T) {
+	defer withParallelism(4)()
+	atomic.StoreInt64(&maxInFlight, 0)
+
+	var nums []int
+	for i := 1; i <= 100; i++ {
+		nums = append(nums, i)
+	}
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(i int) (int, int) { return i % 10, i }, beam.CreateList(s, nums))
+	sums := beam.ParDo(s, &sumFn{}, beam.GroupByKey(s, keyed))
+	passert.Sum(s, sums, "sums", 10, 5050)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if max := atomic.LoadInt64(&maxInFlight); max < 2 {
+		t.Errorf("at most %v groups were processed concurrently, want more than 1", max)
+	}
+}
+
+// countsFn counts the elements of each key in a map, which is shared by
+// replicas if they are not deep copies.
+type countsFn struct {
+	Counts map[int]int
+}
+
+var (
+	countsMu   sync.Mutex
+	countsMaps = make(map[uintptr]bool)
+)
+
+func (fn *countsFn) Setup() {
+	countsMu.Lock()
+	defer countsMu.Unlock()
+	countsMaps[reflect.ValueOf(fn.Counts).Pointer()] = true
+}
+
+func (fn *countsFn) ProcessElement(k int, iter func(*int) bool) int {
+	var i int
+	for iter(&i) {
+		fn.Counts[k]++
+	}
+	return fn.Counts[k]
+}
+
+// TestParallel_DeepCopy tests that replicas do not share the maps of their
+// DoFns.
+func TestParallel_DeepCopy(t *testing.T) {
+	defer withParallelism(4)()
+	countsMaps = make(map[uintptr]bool)
+
+	var nums []int
+	for i := 1; i <= 100; i++ {
+		nums = append(nums, i)
+	}
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(i int) (int, int) { return i % 10, i }, beam.CreateList(s, nums))
+	counts := beam.ParDo(s, &countsFn{Counts: map[int]int{}}, beam.GroupByKey(s, keyed))
+	passert.Sum(s, counts, "counts", 10, 100)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if got, want := len(countsMaps), 4; got != want {
+		t.Errorf("replicas used %v distinct maps, want %v", got, want)
+	}
+}
+
+// TestParallel_Reshuffle tests that the stage after a reshuffle is replicated.
+func TestParallel_Reshuffle(t *testing.T) {
+	defer withParallelism(4)()
+	atomic.StoreInt64(&maxInFlight, 0)
+
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(i int) (int, int) { return i, i }, beam.Create(s, 1, 2, 3, 4, 5, 6, 7, 8))
+	out := beam.ParDo(s, func(k, v int) int {
+		slowly()
+		return v
+	}, beam.Reshuffle(s, keyed))
+	passert.Sum(s, out, "out", 8, 36)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if max := atomic.LoadInt64(&maxInFlight); max < 2 {
+		t.Errorf("at most %v elements were processed concurrently, want more than 1", max)
+	}
+}
+
+// TestParallel_SideInput tests that stages reading side inputs are executed
+// serially, with all of their side input.
+func TestParallel_SideInput(t *testing.T) {
+	defer withParallelism(4)()
+
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(i int) (int, int) { return i % 2, i }, beam.Create(s, 1, 2, 3, 4, 5))
+	side := beam.Create(s, 10, 20)
+	out := beam.ParDo(s, func(k int, iter func(*int) bool, side []int) int {
+		return k + len(side)*10
+	}, beam.GroupByKey(s, keyed), beam.SideInput{Input: side})
+	passert.Equals(s, out, 20, 21)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+}
+
+// TestMetrics tests that the metrics of replicated stages are merged in the
+// result of the pipeline.
+func TestMetrics(t *testing.T) {
+	defer withParallelism(4)()
+
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(i int) (int, int) { return i, i }, beam.Create(s, 1, 2, 3, 4, 5, 6, 7, 8))
+	beam.ParDo0(s, func(ctx context.Context, k, v int) {
+		beam.NewCounter("direct", "elements").Inc(ctx, 1)
+		beam.NewDistribution("direct", "values").Update(ctx, int64(v))
+		beam.NewGauge("direct", "last").Set(ctx, 1)
+	}, beam.Reshuffle(s, keyed))
+
+	pr, err := Execute(context.Background(), p)
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	res := pr.Metrics().AllMetrics()
+	counters := res.Counters()
+	if len(counters) != 1 || counters[0].Key.Name != "elements" || counters[0].Result() != 8 {
+		t.Errorf("counters = %+v, want one elements counter of 8", counters)
+	}
+	dists := res.Distributions()
+	if want := (metrics.DistributionValue{Count: 8, Sum: 36, Min: 1, Max: 8}); len(dists) != 1 || dists[0].Result() != want {
+		t.Errorf("distributions = %+v, want one values distribution of %+v", dists, want)
+	}
+	if gauges := res.Gauges(); len(gauges) != 1 || gauges[0].Result().Value != 1 {
+		t.Errorf("gauges = %+v, want one last gauge of 1", gauges)
+	}
+}
+
+// TestMetrics_Query tests that the metrics of transforms within composites
+// are keyed by the path of their step, which composite steps select.
+func TestMetrics_Query(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, 1, 2, 3)
+	inner := s.Scope("Outer").Scope("Inner")
+	beam.ParDo0(inner, func(ctx context.Context, v int) {
+		beam.NewCounter("direct", "inner").Inc(ctx, 1)
+	}, col)
+	beam.ParDo0(s, func(ctx context.Context, v int) {
+		beam.NewCounter("direct", "outer").Inc(ctx, 1)
+	}, col)
+
+	pr, err := Execute(context.Background(), p)
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	counters := pr.Metrics().Query(metrics.Filter{Steps: []string{"Outer"}}).Counters()
+	if len(counters) != 1 || counters[0].Key.Name != "inner" || counters[0].Result() != 3 {
+		t.Fatalf("counters of Outer = %+v, want one inner counter of 3", counters)
+	}
+	if step := counters[0].Key.Step; !strings.HasPrefix(step, "Outer/Inner/") {
+		t.Errorf("step of inner counter = %q, want a step within Outer/Inner", step)
+	}
+	if counters := pr.Metrics().Query(metrics.Filter{Name: "outer"}).Counters(); len(counters) != 1 || counters[0].Result() != 3 {
+		t.Errorf("outer counters = %+v, want one counter of 3", counters)
+	}
+}
+
+// TestValidateOnly tests that pipelines are compiled but not run in
+// validate-only mode.
+func TestValidateOnly(t *testing.T) {
+	*jobopts.ValidateOnly = true
+	defer func() { *jobopts.ValidateOnly = false }()
+
+	var calls int32
+	p, s := beam.NewPipelineWithRoot()
+	beam.ParDo0(s, func(int) { atomic.AddInt32(&calls, 1) }, beam.Create(s, 1, 2, 3))
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("DoFn called %v times, want 0 in validate-only mode", calls)
+	}
+}
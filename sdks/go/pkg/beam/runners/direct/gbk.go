@@ -20,8 +20,10 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"sync"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
@@ -34,15 +36,21 @@ type group struct {
 }
 
 // CoGBK buffers all input and continues on FinishBundle. Use with small single-bundle data only.
+// In streaming mode, it also emits the groups of windows that end before the watermark as it
+// advances, and groups of elements arriving after their window has ended are emitted as further
+// panes.
 type CoGBK struct {
 	UID  exec.UnitID
 	Edge *graph.MultiEdge
 	Out  exec.Node
 
-	enc  exec.ElementEncoder // key encoder for coder-equality
-	wEnc exec.WindowEncoder  // window encoder for windowing
-	m    map[string]*group
-	wins []typex.Window
+	enc      exec.ElementEncoder // key encoder for coder-equality
+	wEnc     exec.WindowEncoder  // window encoder for windowing
+	m        map[string]*group
+	wins     []typex.Window
+	finished bool // FinishBundle called?
+
+	mu sync.Mutex // guards input, which replicas of stages may emit concurrently
 }
 
 func (n *CoGBK) ID() exec.UnitID {
@@ -57,10 +65,14 @@ func (n *CoGBK) Up(ctx context.Context) error {
 }
 
 func (n *CoGBK) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	n.finished = false
 	return n.Out.StartBundle(ctx, id, data)
 }
 
 func (n *CoGBK) ProcessElement(ctx context.Context, elm *exec.FullValue, _ ...exec.ReStream) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
 	index := elm.Elm.(int)
 	value := elm.Elm2.(*exec.FullValue)
 
@@ -102,6 +114,24 @@ func (n *CoGBK) getGroup(m map[string]*group, key string, value *exec.FullValue,
 }
 
 func (n *CoGBK) FinishBundle(ctx context.Context) error {
+	if err := n.emit(ctx, mtime.MaxTimestamp); err != nil {
+		return err
+	}
+	n.finished = true
+	return n.Out.FinishBundle(ctx)
+}
+
+// AdvanceWatermark emits the groups of windows that end before the watermark.
+func (n *CoGBK) AdvanceWatermark(ctx context.Context, wm mtime.Time) error {
+	if n.finished {
+		return nil
+	}
+	return n.emit(ctx, wm)
+}
+
+// emit emits the groups of windows with a maximum timestamp before wm, or all
+// groups if wm is the maximum timestamp.
+func (n *CoGBK) emit(ctx context.Context, wm mtime.Time) error {
 	winKind := n.Edge.Input[0].From.WindowingStrategy().Fn.Kind
 	if winKind == window.Sessions {
 		mergeMap, mergeErr := n.mergeWindows()
@@ -113,6 +143,9 @@ func (n *CoGBK) FinishBundle(ctx context.Context) error {
 		}
 	}
 	for key, g := range n.m {
+		if wm != mtime.MaxTimestamp && g.key.Windows[0].MaxTimestamp() >= wm {
+			continue
+		}
 		values := make([]exec.ReStream, len(g.values))
 		for i, list := range g.values {
 			values[i] = &exec.FixedReStream{Buf: list}
@@ -122,7 +155,12 @@ func (n *CoGBK) FinishBundle(ctx context.Context) error {
 		}
 		delete(n.m, key)
 	}
-	return n.Out.FinishBundle(ctx)
+	// Only the windows of groups not yet emitted may merge with later input.
+	n.wins = n.wins[:0]
+	for _, g := range n.m {
+		n.wins = append(n.wins, g.key.Windows...)
+	}
+	return nil
 }
 
 func (n *CoGBK) mergeWindows() (map[typex.Window]int, error) {
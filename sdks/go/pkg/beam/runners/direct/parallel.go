@@ -0,0 +1,356 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/jsonx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// work is an element dispatched to a replica.
+type work struct {
+	ctx    context.Context
+	elm    exec.FullValue
+	values []exec.ReStream
+}
+
+// parallel dispatches its input to replicas of the next stage, which process
+// it concurrently. If the input is keyed, elements with the same key are
+// processed by the same replica, in order. Otherwise they are dispatched round
+// robin.
+type parallel struct {
+	UID exec.UnitID
+	Key *coder.Coder // nil if not keyed
+	Out []exec.Node
+
+	enc  exec.ElementEncoder
+	work []chan work
+	next int
+	wg   sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+func (n *parallel) ID() exec.UnitID {
+	return n.UID
+}
+
+func (n *parallel) Up(ctx context.Context) error {
+	if n.Key != nil {
+		n.enc = exec.MakeElementEncoder(n.Key)
+	}
+	return nil
+}
+
+func (n *parallel) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	n.err = nil
+	n.work = make([]chan work, len(n.Out))
+	for i, out := range n.Out {
+		if err := out.StartBundle(ctx, id, data); err != nil {
+			return err
+		}
+		n.work[i] = make(chan work, 64)
+		n.wg.Add(1)
+		go n.run(out, n.work[i])
+	}
+	return nil
+}
+
+// run processes the work of a replica, until its channel is closed. Work
+// after the first error of any replica is dropped.
+func (n *parallel) run(out exec.Node, ch <-chan work) {
+	defer n.wg.Done()
+	for w := range ch {
+		if n.failed() != nil {
+			continue
+		}
+		if err := out.ProcessElement(w.ctx, &w.elm, w.values...); err != nil {
+			n.mu.Lock()
+			if n.err == nil {
+				n.err = err
+			}
+			n.mu.Unlock()
+		}
+	}
+}
+
+func (n *parallel) failed() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.err
+}
+
+func (n *parallel) ProcessElement(ctx context.Context, elm *exec.FullValue, values ...exec.ReStream) error {
+	if err := n.failed(); err != nil {
+		return err
+	}
+	i, err := n.replica(elm)
+	if err != nil {
+		return err
+	}
+	// Emitters may reuse the value, so it is copied.
+	n.work[i] <- work{ctx: ctx, elm: *elm, values: values}
+	return nil
+}
+
+// replica returns the index of the replica processing the element.
+func (n *parallel) replica(elm *exec.FullValue) (int, error) {
+	if n.enc == nil {
+		n.next = (n.next + 1) % len(n.Out)
+		return n.next, nil
+	}
+	var buf bytes.Buffer
+	if err := n.enc.Encode(&exec.FullValue{Elm: elm.Elm}, &buf); err != nil {
+		return 0, errors.WithContextf(err, "encoding key %v for parallel", elm.Elm)
+	}
+	h := fnv.New32a()
+	h.Write(buf.Bytes())
+	return int(h.Sum32() % uint32(len(n.Out))), nil
+}
+
+func (n *parallel) FinishBundle(ctx context.Context) error {
+	for _, ch := range n.work {
+		close(ch)
+	}
+	n.wg.Wait()
+	if n.err != nil {
+		return n.err
+	}
+	for _, out := range n.Out {
+		if err := out.FinishBundle(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *parallel) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *parallel) String() string {
+	var ids []exec.UnitID
+	for _, out := range n.Out {
+		ids = append(ids, out.ID())
+	}
+	return fmt.Sprintf("parallel[%v]. Out:%v", len(n.Out), ids)
+}
+
+// merge serializes the output of the replicas of a stage to the next stage.
+// It forwards the first StartBundle and the last FinishBundle of the N
+// replicas.
+type merge struct {
+	UID exec.UnitID
+	N   int
+	Out exec.Node
+
+	mu                sync.Mutex
+	started, finished int
+}
+
+func (n *merge) ID() exec.UnitID {
+	return n.UID
+}
+
+func (n *merge) Up(ctx context.Context) error {
+	return nil
+}
+
+func (n *merge) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.started++
+	if n.started > 1 {
+		return nil
+	}
+	return n.Out.StartBundle(ctx, id, data)
+}
+
+func (n *merge) ProcessElement(ctx context.Context, elm *exec.FullValue, values ...exec.ReStream) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.Out.ProcessElement(ctx, elm, values...)
+}
+
+func (n *merge) FinishBundle(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.finished++
+	if n.finished < n.N {
+		return nil
+	}
+	n.started, n.finished = 0, 0
+	return n.Out.FinishBundle(ctx)
+}
+
+func (n *merge) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *merge) String() string {
+	return fmt.Sprintf("merge[%v]. Out:%v", n.N, n.Out.ID())
+}
+
+// stage returns the edges downstream of node from, up to the next groupings,
+// if they can be replicated. They cannot if any of them reads side input or
+// input from outside of the stage, as the replicas would only see part of it,
+// or keeps state, which the replicas would not share.
+func (b *builder) stage(from int) (map[int]bool, bool) {
+	edges := make(map[int]bool)
+	nodes := map[int]bool{from: true}
+	queue := []int{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, l := range b.succ[id] {
+			edge := b.edges[l.to]
+			if edge.Op == graph.CoGBK || edges[edge.ID()] {
+				continue
+			}
+			edges[edge.ID()] = true
+			for _, out := range edge.Output {
+				nodes[out.To.ID()] = true
+				queue = append(queue, out.To.ID())
+			}
+		}
+	}
+	for id := range edges {
+		edge := b.edges[id]
+		if edge.Op == graph.ParDo && (len(edge.Input) > 1 || edge.DoFn.IsStateful()) {
+			return nil, false
+		}
+		for _, in := range edge.Input {
+			if !nodes[in.From.ID()] {
+				return nil, false
+			}
+		}
+	}
+	return edges, true
+}
+
+// maybeParallel replicates the stage reading the output of a top-level
+// grouping or reshuffle in batch mode, if parallelism is enabled.
+func (b *builder) maybeParallel(edge *graph.MultiEdge) (exec.Node, bool, error) {
+	if b.parallelism < 2 || b.region != nil || b.clock != nil {
+		return nil, false, nil
+	}
+	if edge.Op != graph.CoGBK && edge.Op != graph.Reshuffle {
+		return nil, false, nil
+	}
+	return b.makeParallel(edge)
+}
+
+// makeParallel creates replicas of the stage reading the output of a
+// grouping or reshuffle, if it can be replicated, and returns the node
+// dispatching to them.
+func (b *builder) makeParallel(edge *graph.MultiEdge) (exec.Node, bool, error) {
+	from := edge.Output[0].To.ID()
+	stage, ok := b.stage(from)
+	if !ok {
+		return nil, false, nil
+	}
+
+	p := &parallel{UID: b.idgen.New()}
+	if edge.Op == graph.CoGBK {
+		p.Key = edge.Input[0].From.Coder.Components[0]
+	}
+	exits := make(map[linkID]*merge)
+	for i := 0; i < b.parallelism; i++ {
+		r := &builder{
+			prev:   b.prev,
+			succ:   b.succ,
+			edges:  b.edges,
+			nodes:  make(map[int]exec.Node),
+			links:  make(map[linkID]exec.Node),
+			idgen:  b.idgen,
+			region: stage,
+			parent: b,
+			exits:  exits,
+		}
+		out, err := r.makeNode(from)
+		if err != nil {
+			return nil, false, err
+		}
+		p.Out = append(p.Out, out)
+		b.units = append(b.units, r.units...)
+	}
+	b.units = append(b.units, p)
+	return p, true, nil
+}
+
+// exit returns the node merging the output of the replicas of a stage to a
+// link outside of it.
+func (b *builder) exit(id linkID) (exec.Node, error) {
+	if m, ok := b.exits[id]; ok {
+		return m, nil
+	}
+	out, err := b.parent.makeLink(id)
+	if err != nil {
+		return nil, err
+	}
+	m := &merge{UID: b.idgen.New(), N: b.parent.parallelism, Out: out}
+	b.exits[id] = m
+	b.units = append(b.units, m)
+	return m, nil
+}
+
+// cloneFn returns a copy of the struct receiver of a function, so the
+// replicas of a stage do not share the fields it updates. The receiver is
+// copied by encoding and decoding it, as functions are serialized for other
+// runners, so the copies share no slices, maps or pointers either, and only
+// have the exported fields. Setup is called on each copy.
+func cloneFn(fn *graph.Fn) (interface{}, bool, error) {
+	v := reflect.ValueOf(fn.Recv)
+	if fn.Recv == nil || v.Kind() != reflect.Ptr {
+		return nil, false, nil
+	}
+	data, err := jsonx.Marshal(fn.Recv)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to marshal receiver %v", fn.Recv)
+	}
+	c := reflect.New(v.Elem().Type())
+	if err := jsonx.Unmarshal(c.Interface(), data); err != nil {
+		return nil, false, errors.Wrapf(err, "failed to unmarshal receiver %v", fn.Recv)
+	}
+	return c.Interface(), true, nil
+}
+
+func cloneDoFn(fn *graph.DoFn) (*graph.DoFn, error) {
+	recv, ok, err := cloneFn((*graph.Fn)(fn))
+	if err != nil || !ok {
+		return fn, err
+	}
+	return graph.NewDoFn(recv)
+}
+
+func cloneCombineFn(fn *graph.CombineFn) (*graph.CombineFn, error) {
+	recv, ok, err := cloneFn((*graph.Fn)(fn))
+	if err != nil || !ok {
+		return fn, err
+	}
+	return graph.NewCombineFn(recv)
+}
@@ -0,0 +1,168 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+)
+
+// streamingSDF executes a splittable DoFn as a source in streaming mode. The
+// restriction of each input element is read as it arrives, until it is
+// checkpointed, and the residuals are read in turns once its input is
+// complete, so unbounded restrictions do not block the others. The watermark
+// is held at the earliest event time that a residual has emitted up to, and
+// released once every restriction is done. Splittable DoFns downstream of
+// others so only hold the watermark for the residuals of their own reads,
+// rather than for everything their upstream emitted.
+type streamingSDF struct {
+	*exec.SdfFallback
+	clock      *clock
+	checkpoint time.Duration
+	// finalize runs the bundle finalization callbacks registered so far.
+	finalize func() error
+
+	work    []*restriction
+	emitted mtime.Time // Latest timestamp emitted by the current restriction.
+}
+
+// restriction is an unfinished restriction of an input element.
+type restriction struct {
+	elm    exec.FullValue
+	values []exec.ReStream
+	rest   interface{}
+	wm     mtime.Time // Latest timestamp emitted by the restriction.
+}
+
+func (n *streamingSDF) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	n.work = nil
+	return n.SdfFallback.StartBundle(ctx, id, data)
+}
+
+// ProcessElement reads the initial restrictions of an element until they
+// are checkpointed, and queues their residuals.
+func (n *streamingSDF) ProcessElement(ctx context.Context, elm *exec.FullValue, values ...exec.ReStream) error {
+	rests, err := n.InitialRestrictions(elm)
+	if err != nil {
+		return err
+	}
+	for _, rest := range rests {
+		// Until it emits, a residual holds the watermark where it was when
+		// its element arrived.
+		r := &restriction{elm: *elm, values: values, rest: rest, wm: n.clock.watermark()}
+		if err := n.read(ctx, r); err != nil {
+			return err
+		}
+	}
+	return n.hold(ctx)
+}
+
+// FinishBundle reads the queued residuals until they are all done.
+func (n *streamingSDF) FinishBundle(ctx context.Context) error {
+	for len(n.work) > 0 {
+		r := n.work[0]
+		n.work = n.work[1:]
+		if err := n.read(ctx, r); err != nil {
+			return err
+		}
+		if err := n.hold(ctx); err != nil {
+			return err
+		}
+	}
+	return n.SdfFallback.FinishBundle(ctx)
+}
+
+// read reads a restriction until it is checkpointed, and queues its
+// residual, if any.
+func (n *streamingSDF) read(ctx context.Context, r *restriction) error {
+	n.emitted = mtime.MinTimestamp
+	residual, err := n.ProcessRestriction(&r.elm, r.rest, r.values, n.checkpoint)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if n.finalize != nil {
+		if err := n.finalize(); err != nil {
+			return err
+		}
+	}
+	if residual != nil {
+		r.rest = residual
+		r.wm = mtime.Max(r.wm, n.emitted)
+		n.work = append(n.work, r)
+	}
+	return nil
+}
+
+// hold holds the watermark at the earliest event time of the residuals, or
+// releases it if there are none.
+func (n *streamingSDF) hold(ctx context.Context) error {
+	if len(n.work) == 0 {
+		return n.clock.release(ctx, n.ID())
+	}
+	hold := mtime.MaxTimestamp
+	for _, r := range n.work {
+		hold = mtime.Min(hold, r.wm)
+	}
+	return n.clock.hold(ctx, n.ID(), hold)
+}
+
+func (n *streamingSDF) String() string {
+	return fmt.Sprintf("SDF.Streaming[%v] UID:%v Out:%v", path.Base(n.PDo.Fn.Name()), n.ID(), exec.IDs(n.PDo.Out...))
+}
+
+// observer records the latest timestamp emitted by a streaming SDF.
+type observer struct {
+	UID exec.UnitID
+	SDF *streamingSDF
+	Out exec.Node
+}
+
+func (n *observer) ID() exec.UnitID {
+	return n.UID
+}
+
+func (n *observer) Up(ctx context.Context) error {
+	return nil
+}
+
+func (n *observer) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	return n.Out.StartBundle(ctx, id, data)
+}
+
+func (n *observer) ProcessElement(ctx context.Context, elm *exec.FullValue, values ...exec.ReStream) error {
+	n.SDF.emitted = mtime.Max(n.SDF.emitted, elm.Timestamp)
+	return n.Out.ProcessElement(ctx, elm, values...)
+}
+
+func (n *observer) FinishBundle(ctx context.Context) error {
+	return n.Out.FinishBundle(ctx)
+}
+
+func (n *observer) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *observer) String() string {
+	return fmt.Sprintf("Observer[%v]. Out:%v", n.SDF.ID(), n.Out.ID())
+}
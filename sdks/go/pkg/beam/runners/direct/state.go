@@ -0,0 +1,66 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"context"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+)
+
+// statefulParDo executes a stateful DoFn, keeping its state and timers in
+// memory. Event time timers fire as the watermark passes them, and processing
+// time timers as processing time passes them. The remaining timers fire at
+// the end of the bundle, when the input is done, and the timers they set are
+// dropped.
+type statefulParDo struct {
+	*exec.StatefulParDo
+
+	finished bool // FinishBundle called?
+}
+
+func (n *statefulParDo) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	n.finished = false
+	return n.StatefulParDo.StartBundle(ctx, id, data)
+}
+
+// AdvanceWatermark fires the event time timers before the watermark.
+func (n *statefulParDo) AdvanceWatermark(ctx context.Context, wm mtime.Time) error {
+	if n.finished {
+		return nil
+	}
+	return n.StatefulParDo.AdvanceWatermark(ctx, wm)
+}
+
+// AdvanceProcessingTime fires the processing time timers up to the processing
+// time.
+func (n *statefulParDo) AdvanceProcessingTime(ctx context.Context, pt mtime.Time) error {
+	if n.finished {
+		return nil
+	}
+	return n.StatefulParDo.AdvanceProcessingTime(ctx, pt)
+}
+
+// FinishBundle fires the remaining timers, as the input is done, before
+// finishing the bundle.
+func (n *statefulParDo) FinishBundle(ctx context.Context) error {
+	if err := n.FireAll(ctx); err != nil {
+		return err
+	}
+	n.finished = true
+	return n.StatefulParDo.FinishBundle(ctx)
+}
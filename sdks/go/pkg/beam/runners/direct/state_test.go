@@ -0,0 +1,286 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/state"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/timers"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/teststream"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*runningCountFn)(nil)).Elem())
+}
+
+// runningCountFn emits the running count of the values of each key.
+type runningCountFn struct {
+	Count state.Value
+}
+
+func (fn *runningCountFn) ProcessElement(p state.Provider, _, _ int) (int, error) {
+	v, ok, err := fn.Count.Read(p)
+	if err != nil {
+		return 0, err
+	}
+	n := 1
+	if ok {
+		n += v.(int)
+	}
+	return n, fn.Count.Write(p, n)
+}
+
+// TestStateful tests that the state of each key is kept across its elements,
+// and that the stage of a stateful DoFn is not replicated.
+func TestStateful(t *testing.T) {
+	defer withParallelism(4)()
+
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(i int) (int, int) { return i % 2, i }, beam.Create(s, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10))
+	counts := beam.ParDo(s, &runningCountFn{Count: state.Value{ID: "count", Type: reflectx.Int}}, beam.Reshuffle(s, keyed))
+	// Grouping the counts ends the stage before passert, whose side inputs
+	// would keep it from being replicated otherwise.
+	grouped := beam.GroupByKey(s, beam.AddFixedKey(s, counts))
+	ungrouped := beam.ParDo(s, func(_ int, iter func(*int) bool, emit func(int)) {
+		var n int
+		for iter(&n) {
+			emit(n)
+		}
+	}, grouped)
+	passert.Equals(s, ungrouped, 1, 2, 3, 4, 5, 1, 2, 3, 4, 5)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+}
+
+// TestStateful_WrongType tests that writing a value of another type than the
+// type of the state fails.
+func TestStateful_WrongType(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(i int) (int, int) { return i % 2, i }, beam.Create(s, 1, 2, 3))
+	beam.ParDo(s, &runningCountFn{Count: state.Value{ID: "count", Type: reflectx.String}}, keyed)
+
+	if _, err := Execute(context.Background(), p); err == nil || !strings.Contains(err.Error(), "state count of type string") {
+		t.Fatalf("pipeline error = %v, want the int written to string state to fail", err)
+	}
+}
+
+// TestStateful_ValidateOnly tests that stateful pipelines are valid in
+// validate-only mode.
+func TestStateful_ValidateOnly(t *testing.T) {
+	*jobopts.ValidateOnly = true
+	defer func() { *jobopts.ValidateOnly = false }()
+
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(i int) (int, int) { return i % 2, i }, beam.Create(s, 1, 2, 3))
+	beam.ParDo(s, &runningCountFn{Count: state.Value{ID: "count", Type: reflectx.Int}}, keyed)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+}
+
+// eventLog records the elements and timers processed by the DoFns of a test.
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(e string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+func (l *eventLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fmt.Sprint(l.events)
+}
+
+// bufferFn buffers the values of each key and window until a timer fires,
+// and then emits them, timestamped with the timer. Only OnTimer emits.
+type bufferFn struct {
+	Buffer     state.Bag
+	Flush      timers.EventTime
+	FlushAfter timers.ProcessingTime
+
+	log *eventLog
+}
+
+func (fn *bufferFn) ProcessElement(w beam.Window, sp state.Provider, tp timers.Provider, _, v string, _ func(string)) error {
+	fn.log.add(v)
+	if fn.FlushAfter.Family != "" {
+		fn.FlushAfter.SetAfter(tp, time.Minute)
+	} else {
+		fn.Flush.Set(tp, w.MaxTimestamp())
+	}
+	return fn.Buffer.Add(sp, v)
+}
+
+func (fn *bufferFn) OnTimer(ts beam.EventTime, sp state.Provider, _, family string, emit func(string)) error {
+	values, err := fn.Buffer.Read(sp)
+	if err != nil {
+		return err
+	}
+	var buf []string
+	for _, v := range values {
+		buf = append(buf, v.(string))
+	}
+	sort.Strings(buf)
+	pane := fmt.Sprintf("%v:%v", ts, strings.Join(buf, ""))
+	fn.log.add(pane)
+	emit(pane)
+	return fn.Buffer.Clear(sp)
+}
+
+// TestTimers tests that event time timers fire in batch mode once the input
+// is done.
+func TestTimers(t *testing.T) {
+	log := &eventLog{}
+
+	p, s := beam.NewPipelineWithRoot()
+	elms := beam.ParDo(s, func(e string) (beam.EventTime, string, string) {
+		return beam.EventTime(strings.Index("abcd", e) * 3000), "k", e
+	}, beam.Create(s, "a", "b", "c", "d"))
+	windowed := beam.WindowInto(s, window.NewFixedWindows(5*time.Second), elms)
+	panes := beam.ParDo(s, &bufferFn{Buffer: state.Bag{ID: "buffer", Type: reflectx.String}, Flush: timers.EventTime{Family: "flush"}, log: log}, windowed)
+	passert.Equals(s, beam.WindowInto(s, window.NewGlobalWindows(), panes), "4999:ab", "9999:cd")
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+}
+
+// TestTimers_Streaming tests that event time timers fire as the watermark
+// passes them in streaming mode.
+func TestTimers_Streaming(t *testing.T) {
+	cfg := teststream.NewConfig()
+	cfg.AddElements(1000, "a", "b")
+	cfg.AdvanceWatermark(5000)
+	cfg.AddElements(6000, "c")
+	cfg.AdvanceWatermark(11000)
+	log := &eventLog{}
+
+	p, s := beam.NewPipelineWithRoot()
+	elms := teststream.Create(s, cfg)
+	windowed := beam.WindowInto(s, window.NewFixedWindows(5*time.Second), elms)
+	keyed := beam.ParDo(s, func(e string) (string, string) { return "k", e }, windowed)
+	beam.ParDo(s, &bufferFn{Buffer: state.Bag{ID: "buffer", Type: reflectx.String}, Flush: timers.EventTime{Family: "flush"}, log: log}, keyed)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if got, want := log.String(), "[a b 4999:ab c 9999:c]"; got != want {
+		t.Errorf("processed %v, want %v", got, want)
+	}
+}
+
+// TestTimers_ProcessingTime tests that processing time timers fire as a
+// TestStream advances processing time, and at the end of the input.
+func TestTimers_ProcessingTime(t *testing.T) {
+	cfg := teststream.NewConfig()
+	cfg.AddElements(1000, "a")
+	cfg.AdvanceProcessingTime(30000)
+	cfg.AddElements(2000, "b")
+	cfg.AdvanceProcessingTime(60000)
+	cfg.AddElements(3000, "c")
+	log := &eventLog{}
+
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(e string) (string, string) { return "k", e }, teststream.Create(s, cfg))
+	beam.ParDo(s, &bufferFn{Buffer: state.Bag{ID: "buffer", Type: reflectx.String}, FlushAfter: timers.ProcessingTime{Family: "flush"}, log: log}, keyed)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if got, want := log.String(), "[a b 2000:ab c 3000:c]"; got != want {
+		t.Errorf("processed %v, want %v", got, want)
+	}
+}
+
+// loopFn sets a timer that sets itself again for a second later each time it
+// fires.
+type loopFn struct {
+	Loop timers.EventTime
+
+	log *eventLog
+}
+
+func (fn *loopFn) ProcessElement(ts beam.EventTime, tp timers.Provider, _, _ string) {
+	fn.Loop.Set(tp, ts)
+}
+
+func (fn *loopFn) OnTimer(ts beam.EventTime, tp timers.Provider, _, _ string) {
+	fn.log.add(fmt.Sprint(ts))
+	fn.Loop.Set(tp, ts.Add(time.Second))
+}
+
+// TestTimers_Looping tests that timers setting themselves again fire once
+// at the end of the input in batch mode, rather than forever.
+func TestTimers_Looping(t *testing.T) {
+	log := &eventLog{}
+
+	p, s := beam.NewPipelineWithRoot()
+	elms := beam.ParDo(s, func(e string) (beam.EventTime, string, string) {
+		return beam.EventTime(1000), "k", e
+	}, beam.Create(s, "a"))
+	beam.ParDo0(s, &loopFn{Loop: timers.EventTime{Family: "loop"}, log: log}, elms)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if got, want := log.String(), "[1000]"; got != want {
+		t.Errorf("fired %v, want %v", got, want)
+	}
+}
+
+// TestTimers_LoopingStreaming tests that timers setting themselves again fire
+// once per watermark advancement that passes them in streaming mode, and once
+// more at the end of the input.
+func TestTimers_LoopingStreaming(t *testing.T) {
+	cfg := teststream.NewConfig()
+	cfg.AddElements(1000, "a")
+	cfg.AdvanceWatermark(1500)
+	cfg.AdvanceWatermark(1600)
+	cfg.AdvanceWatermark(10000)
+	log := &eventLog{}
+
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, func(e string) (string, string) { return "k", e }, teststream.Create(s, cfg))
+	beam.ParDo0(s, &loopFn{Loop: timers.EventTime{Family: "loop"}, log: log}, keyed)
+
+	if _, err := Execute(context.Background(), p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if got, want := log.String(), "[1000 2000 3000]"; got != want {
+		t.Errorf("fired %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"google.golang.org/grpc"
+)
+
+// testStreamURN is the URN of the TestStream primitive.
+const testStreamURN = "beam:transform:teststream:v1"
+
+// TestStream replays the events of a TestStream in streaming mode. Elements
+// are emitted with their timestamps, the watermark is held at the watermark
+// of the stream, and processing time advances by the advancements of the
+// stream. If the payload has an endpoint, the events are read from the
+// TestStreamService at its url instead.
+type TestStream struct {
+	UID     exec.UnitID
+	Payload *pipepb.TestStreamPayload
+	Coder   *coder.Coder
+	Out     exec.Node
+
+	clock *clock
+	dec   exec.ElementDecoder
+}
+
+func (n *TestStream) ID() exec.UnitID {
+	return n.UID
+}
+
+func (n *TestStream) Up(ctx context.Context) error {
+	n.dec = exec.MakeElementDecoder(n.Coder)
+	return nil
+}
+
+func (n *TestStream) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	if err := n.clock.hold(ctx, n.UID, mtime.MinTimestamp); err != nil {
+		return err
+	}
+	return n.Out.StartBundle(ctx, id, data)
+}
+
+func (n *TestStream) Process(ctx context.Context) error {
+	if url := n.Payload.GetEndpoint().GetUrl(); url != "" {
+		return n.replayService(ctx, url)
+	}
+	for _, e := range n.Payload.GetEvents() {
+		if err := n.replay(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayService replays the events read from a TestStreamService.
+func (n *TestStream) replayService(ctx context.Context, url string) error {
+	conn, err := grpc.DialContext(ctx, url, grpc.WithInsecure())
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to TestStreamService at %v", url)
+	}
+	defer conn.Close()
+	events, err := pipepb.NewTestStreamServiceClient(conn).Events(ctx, &pipepb.EventsRequest{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to read events from TestStreamService at %v", url)
+	}
+	for {
+		e, err := events.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read events from TestStreamService at %v", url)
+		}
+		if err := n.replay(ctx, e); err != nil {
+			return err
+		}
+	}
+}
+
+func (n *TestStream) replay(ctx context.Context, e *pipepb.TestStreamPayload_Event) error {
+	switch ev := e.GetEvent().(type) {
+	case *pipepb.TestStreamPayload_Event_ElementEvent:
+		for _, te := range ev.ElementEvent.GetElements() {
+			elm, err := n.dec.Decode(bytes.NewReader(te.GetEncodedElement()))
+			if err != nil {
+				return errors.WithContext(err, "decoding TestStream element")
+			}
+			elm.Timestamp = mtime.FromMilliseconds(te.GetTimestamp())
+			elm.Windows = window.SingleGlobalWindow
+			if err := n.Out.ProcessElement(ctx, elm); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *pipepb.TestStreamPayload_Event_WatermarkEvent:
+		return n.clock.hold(ctx, n.UID, mtime.FromMilliseconds(ev.WatermarkEvent.GetNewWatermark()))
+	case *pipepb.TestStreamPayload_Event_ProcessingTimeEvent:
+		return n.clock.advanceProcessingTime(ctx, ev.ProcessingTimeEvent.GetAdvanceDuration())
+	default:
+		return errors.Errorf("unexpected TestStream event: %v", e)
+	}
+}
+
+func (n *TestStream) FinishBundle(ctx context.Context) error {
+	if err := n.clock.release(ctx, n.UID); err != nil {
+		return err
+	}
+	return n.Out.FinishBundle(ctx)
+}
+
+func (n *TestStream) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *TestStream) String() string {
+	return fmt.Sprintf("TestStream[%v]. Out:%v", len(n.Payload.GetEvents()), n.Out.ID())
+}
@@ -0,0 +1,143 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package direct
+
+import (
+	"context"
+	"sort"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+)
+
+// watermarkNode is a node that acts on the advancement of the watermark, such
+// as a CoGBK firing the windows that have ended.
+type watermarkNode interface {
+	exec.Node
+	// AdvanceWatermark is called with the new watermark whenever it advances.
+	AdvanceWatermark(ctx context.Context, wm mtime.Time) error
+}
+
+// processingTimeNode is a node that acts on the advancement of processing
+// time.
+type processingTimeNode interface {
+	exec.Node
+	// AdvanceProcessingTime is called with the new processing time whenever it
+	// is advanced by a TestStream.
+	AdvanceProcessingTime(ctx context.Context, pt mtime.Time) error
+}
+
+// clock simulates the event time watermark of a pipeline in streaming mode.
+// Sources hold the watermark at the event time they have read up to, and the
+// watermark advances to the earliest hold. Without holds, the watermark stays
+// where it is until the end of the bundle.
+//
+// As the direct runner is single-threaded, the watermark is global rather
+// than per PCollection, and it is up to sources to hold it until they are
+// done.
+//
+// The clock also holds the processing time of the pipeline, which starts at
+// the wall time the pipeline is compiled, and only advances when a TestStream
+// advances it, so tests control it deterministically.
+type clock struct {
+	wm    mtime.Time
+	pt    mtime.Time
+	holds map[exec.UnitID]mtime.Time
+	nodes []clockNode
+}
+
+type clockNode struct {
+	order int // Edge ID, which orders nodes topologically.
+	node  exec.Node
+}
+
+func newClock() *clock {
+	return &clock{wm: mtime.MinTimestamp, pt: mtime.Now(), holds: make(map[exec.UnitID]mtime.Time)}
+}
+
+// register adds a watermarkNode or processingTimeNode to notify of clock
+// advancements. Nodes are notified in the order of their edge IDs, so
+// downstream nodes are notified after the nodes emitting to them.
+func (c *clock) register(order int, n exec.Node) {
+	c.nodes = append(c.nodes, clockNode{order: order, node: n})
+	sort.SliceStable(c.nodes, func(i, j int) bool { return c.nodes[i].order < c.nodes[j].order })
+}
+
+// hold sets the watermark hold of a source, and advances the watermark if
+// every hold allows it.
+func (c *clock) hold(ctx context.Context, id exec.UnitID, t mtime.Time) error {
+	c.holds[id] = t
+	return c.advance(ctx)
+}
+
+// release removes the watermark hold of a source that is done.
+func (c *clock) release(ctx context.Context, id exec.UnitID) error {
+	delete(c.holds, id)
+	return c.advance(ctx)
+}
+
+func (c *clock) advance(ctx context.Context) error {
+	if len(c.holds) == 0 {
+		return nil
+	}
+	wm := mtime.MaxTimestamp
+	for _, t := range c.holds {
+		wm = mtime.Min(wm, t)
+	}
+	if wm <= c.wm {
+		return nil
+	}
+	c.wm = wm
+	for _, n := range c.nodes {
+		if wn, ok := n.node.(watermarkNode); ok {
+			if err := wn.AdvanceWatermark(ctx, wm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// advanceProcessingTime advances the processing time by the given number of
+// milliseconds.
+func (c *clock) advanceProcessingTime(ctx context.Context, millis int64) error {
+	if millis <= 0 {
+		return nil
+	}
+	if millis >= int64(mtime.MaxTimestamp-c.pt) {
+		c.pt = mtime.MaxTimestamp
+	} else {
+		c.pt += mtime.Time(millis)
+	}
+	for _, n := range c.nodes {
+		if pn, ok := n.node.(processingTimeNode); ok {
+			if err := pn.AdvanceProcessingTime(ctx, c.pt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// watermark returns the event time watermark of the pipeline.
+func (c *clock) watermark() mtime.Time {
+	return c.wm
+}
+
+// processingTime returns the processing time of the pipeline.
+func (c *clock) processingTime() mtime.Time {
+	return c.pt
+}
@@ -14,13 +14,33 @@
 // limitations under the License.
 
 // Package flink contains the Flink runner.
+//
+// Without --endpoint, the runner manages a job server itself: it downloads
+// the Flink job server jar matching the SDK version and --flink_version,
+// starts it locally, submits the pipeline to it and stops it once Execute
+// returns. The job server runs pipelines on the Flink cluster at
+// --flink_master, or on an embedded cluster by default. Pipelines run
+// asynchronously on an embedded cluster stop with the job server.
+//
+//	go run ./main.go --runner=flink --environment_type=LOOPBACK
 package flink
 
 import (
 	"context"
+	"flag"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
 	"github.com/apache/beam/sdks/go/pkg/beam/runners/universal"
+	"github.com/apache/beam/sdks/go/pkg/beam/runners/universal/jobserver"
+)
+
+var (
+	flinkMaster    = flag.String("flink_master", "[auto]", "Address of the Flink cluster of the managed job server, or [auto] or [local] for an embedded cluster (optional).")
+	flinkVersion   = flag.String("flink_version", "1.13", "Flink version of the managed job server (optional).")
+	jobServerJar   = flag.String("flink_job_server_jar", "", "Path or URL of the jar of the managed job server, instead of the released jar (optional).")
+	jobServerImage = flag.String("flink_job_server_image", "", "Docker image of the managed job server, which is run as a container instead of a jar (optional).")
 )
 
 func init() {
@@ -29,7 +49,19 @@ func init() {
 }
 
 // Execute runs the given pipeline on Flink. Convenience wrapper over the
-// universal runner.
+// universal runner, which submits to the job server at --endpoint or to a
+// managed job server otherwise.
 func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
-	return universal.Execute(ctx, p)
+	if *jobopts.Endpoint != "" {
+		return universal.Execute(ctx, p)
+	}
+	if *jobopts.Async && (*flinkMaster == "[auto]" || *flinkMaster == "[local]") {
+		log.Warn(ctx, "The job runs on the embedded Flink cluster of the managed job server, and stops with the job server once Execute returns.")
+	}
+	return jobserver.Execute(ctx, p, jobserver.Options{
+		Target: "runners:flink:" + *flinkVersion + ":job-server",
+		Jar:    *jobServerJar,
+		Image:  *jobServerImage,
+		Args:   []string{"--flink-master=" + *flinkMaster},
+	}, universal.Config{})
 }
@@ -0,0 +1,460 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prism
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/protox"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/go/pkg/beam/util/grpcx"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	urnDataSource         = "beam:runner:source:v1"
+	urnDataSink           = "beam:runner:sink:v1"
+	urnEnvExternal        = "beam:env:external:v1"
+	urnWindowedValueCoder = "beam:coder:windowed_value:v1"
+)
+
+// execute runs the pipeline on a harness started in its LOOPBACK environment.
+// The metrics of each bundle are passed to the given function, along with
+// whether the bundle succeeded, so its metrics are committed.
+func execute(ctx context.Context, jobID string, p *pipepb.Pipeline, metrics func(mons []*pipepb.MonitoringInfo, committed bool)) error {
+	if err := validate(p); err != nil {
+		return err
+	}
+	_, pool, err := loopbackEnvironment(p.GetComponents())
+	if err != nil {
+		return err
+	}
+	cc, err := grpcx.Dial(ctx, pool, time.Minute)
+	if err != nil {
+		return errors.WithContextf(err, "connecting to worker pool %v", pool)
+	}
+	defer cc.Close()
+	client := fnpb.NewBeamFnExternalWorkerPoolClient(cc)
+
+	w, err := newWorker(jobID + "-worker")
+	if err != nil {
+		return err
+	}
+	endpoint := &pipepb.ApiServiceDescriptor{Url: w.endpoint()}
+	resp, err := client.StartWorker(ctx, &fnpb.StartWorkerRequest{
+		WorkerId:        w.id,
+		ControlEndpoint: endpoint,
+		LoggingEndpoint: endpoint,
+	})
+	if err == nil && resp.GetError() != "" {
+		err = errors.New(resp.GetError())
+	}
+	if err != nil {
+		w.stop()
+		return errors.Wrapf(err, "failed to start worker %v", w.id)
+	}
+	defer func() {
+		// Ending the streams first lets the harness shut down cleanly.
+		w.stop()
+		client.StopWorker(context.Background(), &fnpb.StopWorkerRequest{WorkerId: w.id})
+	}()
+
+	e := &executor{
+		w:       w,
+		comps:   proto.Clone(p.GetComponents()).(*pipepb.Components), // Modified by lifting.
+		data:    map[string][]byte{},
+		metrics: metrics,
+	}
+	return e.run(ctx, p.GetRootTransformIds())
+}
+
+// validate checks that the pipeline runs in a LOOPBACK environment and only
+// has transforms that prism executes.
+func validate(p *pipepb.Pipeline) error {
+	env, _, err := loopbackEnvironment(p.GetComponents())
+	if err != nil {
+		return err
+	}
+	e := &executor{comps: proto.Clone(p.GetComponents()).(*pipepb.Components)}
+	leaves, err := e.leaves(p.GetRootTransformIds())
+	if err != nil {
+		return err
+	}
+	for _, id := range leaves {
+		t := e.comps.GetTransforms()[id]
+		if tenv := t.GetEnvironmentId(); tenv != "" && tenv != env {
+			return errors.Errorf("transform %v runs in environment %v, but prism only runs environment %v", t.GetUniqueName(), tenv, env)
+		}
+		switch urn := t.GetSpec().GetUrn(); {
+		case executedByHarness(t), urn == graphx.URNImpulse, urn == graphx.URNFlatten, urn == graphx.URNGBK:
+		default:
+			return errors.Errorf("transform %v has unsupported URN %v", t.GetUniqueName(), urn)
+		}
+	}
+	return nil
+}
+
+// loopbackEnvironment returns the ID of the environment of the pipeline, and
+// the endpoint of its external worker pool.
+func loopbackEnvironment(comps *pipepb.Components) (string, string, error) {
+	var ids []string
+	for id := range comps.GetEnvironments() {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		env := comps.GetEnvironments()[id]
+		if urn := env.GetUrn(); urn != urnEnvExternal {
+			return "", "", errors.Errorf("environment %v has URN %v, but prism only supports the LOOPBACK environment", id, urn)
+		}
+		var payload pipepb.ExternalPayload
+		if err := proto.Unmarshal(env.GetPayload(), &payload); err != nil {
+			return "", "", errors.Wrapf(err, "invalid payload for environment %v", id)
+		}
+		return id, payload.GetEndpoint().GetUrl(), nil
+	}
+	return "", "", errors.New("pipeline has no environment")
+}
+
+// executor runs a pipeline one stage at a time. Impulses, flattens and
+// groupings are executed by the runner, and every other transform by the
+// harness, in a bundle for each stage of fused transforms. The encoded
+// elements of every PCollection are kept in memory.
+type executor struct {
+	w       *worker
+	comps   *pipepb.Components
+	coders  *graphx.CoderUnmarshaller
+	data    map[string][]byte // PCollection ID -> encoded windowed values
+	metrics func(mons []*pipepb.MonitoringInfo, committed bool)
+
+	descCoders map[string]*pipepb.Coder // pipeline coders and windowed value coders
+	stages     int
+}
+
+// run executes the leaf transforms under the given transforms, after lifting
+// combines and fusing the leaves into stages.
+func (e *executor) run(ctx context.Context, roots []string) error {
+	leaves, err := e.leaves(roots)
+	if err != nil {
+		return err
+	}
+	e.coders = graphx.NewCoderUnmarshaller(e.comps.GetCoders())
+	e.descCoders = map[string]*pipepb.Coder{}
+	for id, c := range e.comps.GetCoders() {
+		e.descCoders[id] = c
+	}
+
+	stages, err := fuse(e.comps, leaves)
+	if err != nil {
+		return err
+	}
+	for _, st := range stages {
+		if err := e.execute(ctx, st); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leaves returns the leaf transforms under the given transforms. Liftable
+// combines are replaced by their lifted transforms.
+func (e *executor) leaves(ids []string) ([]string, error) {
+	var leaves []string
+	for _, id := range ids {
+		t := e.comps.GetTransforms()[id]
+		if t.GetSpec().GetUrn() == graphx.URNCombinePerKey {
+			lifted, ok, err := liftCombine(e.comps, id)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to lift combine %v", t.GetUniqueName())
+			}
+			if ok {
+				leaves = append(leaves, lifted...)
+				continue
+			}
+		}
+		if len(t.GetSubtransforms()) == 0 {
+			leaves = append(leaves, id)
+			continue
+		}
+		subs, err := e.leaves(t.GetSubtransforms())
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, subs...)
+	}
+	return leaves, nil
+}
+
+func (e *executor) execute(ctx context.Context, st *stage) error {
+	if st.fused {
+		if err := e.process(ctx, st); err != nil {
+			return errors.Wrapf(err, "failed to execute transforms %v", st.transforms)
+		}
+		return nil
+	}
+
+	t := e.comps.GetTransforms()[st.transforms[0]]
+	var err error
+	switch urn := t.GetSpec().GetUrn(); urn {
+	case graphx.URNImpulse:
+		err = e.impulse(t)
+	case graphx.URNFlatten:
+		err = e.flatten(t)
+	case graphx.URNGBK:
+		err = e.groupByKey(t)
+	default:
+		err = errors.Errorf("unsupported transform URN %v", urn)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to execute transform %v", t.GetUniqueName())
+	}
+	return nil
+}
+
+// process processes the main input of a fused stage in a bundle on the
+// harness. The bundle reads the input from a data source transform and
+// writes each output of the stage to a data sink transform. Side inputs are
+// served over the state API.
+func (e *executor) process(ctx context.Context, st *stage) error {
+	e.stages++
+	desc := &fnpb.ProcessBundleDescriptor{
+		Id:                        fmt.Sprintf("stage%03d", e.stages),
+		Transforms:                map[string]*pipepb.PTransform{},
+		Pcollections:              map[string]*pipepb.PCollection{},
+		WindowingStrategies:       e.comps.GetWindowingStrategies(),
+		Coders:                    e.descCoders,
+		StateApiServiceDescriptor: &pipepb.ApiServiceDescriptor{Url: e.w.endpoint()},
+	}
+	external := map[string]bool{}
+	for _, pid := range st.inputs {
+		external[pid] = true
+	}
+
+	inputs := map[string][]byte{}
+	sides := map[sideID]*sideInput{}
+	for _, id := range st.transforms {
+		t := e.comps.GetTransforms()[id]
+		desc.Transforms[id] = t
+		sideInputs, err := sideInputs(t)
+		if err != nil {
+			return err
+		}
+		for local, pid := range t.GetInputs() {
+			desc.Pcollections[pid] = e.comps.GetPcollections()[pid]
+			switch {
+			case sideInputs[local]:
+				side, err := e.sideInput(pid)
+				if err != nil {
+					return err
+				}
+				sides[sideID{id, local}] = side
+			case external[pid]:
+				source := fmt.Sprintf("%v_source", pid)
+				port, err := e.port(pid)
+				if err != nil {
+					return err
+				}
+				desc.Transforms[source] = &pipepb.PTransform{
+					UniqueName: source,
+					Spec:       &pipepb.FunctionSpec{Urn: urnDataSource, Payload: port},
+					Outputs:    map[string]string{"i0": pid},
+				}
+				inputs[source] = e.data[pid]
+			}
+		}
+		for _, pid := range t.GetOutputs() {
+			desc.Pcollections[pid] = e.comps.GetPcollections()[pid]
+		}
+	}
+	sinks := map[string]string{} // data sink transform ID -> PCollection ID
+	for _, pid := range st.outputs {
+		sink := fmt.Sprintf("%v_sink", pid)
+		port, err := e.port(pid)
+		if err != nil {
+			return err
+		}
+		desc.Transforms[sink] = &pipepb.PTransform{
+			UniqueName: sink,
+			Spec:       &pipepb.FunctionSpec{Urn: urnDataSink, Payload: port},
+			Inputs:     map[string]string{"i0": pid},
+		}
+		sinks[sink] = pid
+	}
+
+	outputs, mons, err := e.w.process(ctx, desc, inputs, sides)
+	if err != nil {
+		// The metrics of failed bundles are attempted, but not committed.
+		e.metrics(mons, false)
+		return err
+	}
+	e.metrics(mons, true)
+	for sink, pid := range sinks {
+		e.data[pid] = outputs[sink]
+	}
+	return nil
+}
+
+// port returns the encoded data port for the PCollection, which the harness
+// uses to decode or encode its elements.
+func (e *executor) port(pid string) ([]byte, error) {
+	cid, err := e.windowedCoderID(pid)
+	if err != nil {
+		return nil, err
+	}
+	return protox.MustEncode(&fnpb.RemoteGrpcPort{
+		ApiServiceDescriptor: &pipepb.ApiServiceDescriptor{Url: e.w.endpoint()},
+		CoderId:              cid,
+	}), nil
+}
+
+// windowedCoderID returns the ID of the windowed value coder of the
+// PCollection in the coders of bundle descriptors.
+func (e *executor) windowedCoderID(pid string) (string, error) {
+	col, ok := e.comps.GetPcollections()[pid]
+	if !ok {
+		return "", errors.Errorf("pcollection %v not found", pid)
+	}
+	ws, ok := e.comps.GetWindowingStrategies()[col.GetWindowingStrategyId()]
+	if !ok {
+		return "", errors.Errorf("windowing strategy %v of pcollection %v not found", col.GetWindowingStrategyId(), pid)
+	}
+	id := fmt.Sprintf("%v_windowed_%v", col.GetCoderId(), ws.GetWindowCoderId())
+	if _, ok := e.descCoders[id]; !ok {
+		e.descCoders[id] = &pipepb.Coder{
+			Spec:              &pipepb.FunctionSpec{Urn: urnWindowedValueCoder},
+			ComponentCoderIds: []string{col.GetCoderId(), ws.GetWindowCoderId()},
+		}
+	}
+	return id, nil
+}
+
+// windowedCoder returns the windowed value coder of the PCollection.
+func (e *executor) windowedCoder(pid string) (*coder.Coder, error) {
+	col, ok := e.comps.GetPcollections()[pid]
+	if !ok {
+		return nil, errors.Errorf("pcollection %v not found", pid)
+	}
+	c, err := e.coders.Coder(col.GetCoderId())
+	if err != nil {
+		return nil, err
+	}
+	wc, err := e.coders.WindowCoder(e.windowingStrategy(pid).GetWindowCoderId())
+	if err != nil {
+		return nil, err
+	}
+	return coder.NewW(c, wc), nil
+}
+
+func (e *executor) windowingStrategy(pid string) *pipepb.WindowingStrategy {
+	col := e.comps.GetPcollections()[pid]
+	return e.comps.GetWindowingStrategies()[col.GetWindowingStrategyId()]
+}
+
+// sideInput indexes the elements of a KV PCollection by window and key, to
+// serve them as a multimap side input.
+func (e *executor) sideInput(pid string) (*sideInput, error) {
+	c, err := e.windowedCoder(pid)
+	if err != nil {
+		return nil, err
+	}
+	kv := coder.SkipW(c)
+	if !coder.IsKV(kv) {
+		return nil, errors.Errorf("side input %v has a non-KV coder %v", pid, kv)
+	}
+	values, err := decodeAll(c, e.data[pid])
+	if err != nil {
+		return nil, err
+	}
+	wEnc := exec.MakeWindowEncoder(c.Window)
+	kEnc := exec.MakeElementEncoder(kv.Components[0])
+	vEnc := exec.MakeElementEncoder(kv.Components[1])
+
+	side := &sideInput{global: c.Window.Kind == coder.GlobalWindow, values: map[string]map[string][]byte{}}
+	for _, v := range values {
+		k, err := exec.EncodeElement(kEnc, v.Elm)
+		if err != nil {
+			return nil, err
+		}
+		val, err := exec.EncodeElement(vEnc, v.Elm2)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range v.Windows {
+			win, err := exec.EncodeWindow(wEnc, w)
+			if err != nil {
+				return nil, err
+			}
+			side.add(win, k, val)
+		}
+	}
+	return side, nil
+}
+
+// sideID identifies a side input by its transform and local input name.
+type sideID struct {
+	transform, input string
+}
+
+// sideInput holds the encoded values of a multimap side input by encoded
+// window and key. Values in the global window are served for any window, so
+// main inputs in other windows can read them.
+type sideInput struct {
+	global bool
+	values map[string]map[string][]byte
+}
+
+func (s *sideInput) add(w, k, v []byte) {
+	if s.global {
+		w = nil
+	}
+	m, ok := s.values[string(w)]
+	if !ok {
+		m = map[string][]byte{}
+		s.values[string(w)] = m
+	}
+	m[string(k)] = append(m[string(k)], v...)
+}
+
+func (s *sideInput) get(w, k []byte) []byte {
+	if s.global {
+		w = nil
+	}
+	return s.values[string(w)][string(k)]
+}
+
+// decodeAll decodes a sequence of encoded values.
+func decodeAll(c *coder.Coder, data []byte) ([]*exec.FullValue, error) {
+	dec := exec.MakeElementDecoder(c)
+	r := bytes.NewReader(data)
+	var values []*exec.FullValue
+	for r.Len() > 0 {
+		v, err := dec.Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
@@ -0,0 +1,166 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prism
+
+import (
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+// stage is a set of leaf transforms executed together. A fused stage is
+// processed by the harness in one bundle, starting from the main input of its
+// first transform. Any other stage is a single transform executed by the
+// runner.
+type stage struct {
+	transforms []string
+	fused      bool
+	inputs     []string // PCollections consumed from other stages
+	outputs    []string // PCollections consumed by other stages
+}
+
+// fuse groups the leaf transforms into stages, in an order in which every
+// stage runs after the stages producing its inputs. A transform executed by
+// the harness joins the stage producing its main input, so chains of them
+// are processed in a single bundle without passing their elements through
+// the runner. Transforms with side inputs start new stages, as their side
+// inputs must be complete before their main inputs are processed.
+func fuse(comps *pipepb.Components, leaves []string) ([]*stage, error) {
+	order, err := topological(comps, leaves)
+	if err != nil {
+		return nil, err
+	}
+	producers := map[string]string{}   // PCollection ID -> leaf transform ID
+	consumers := map[string][]string{} // PCollection ID -> leaf transform IDs
+	for _, id := range order {
+		t := comps.GetTransforms()[id]
+		for _, pid := range t.GetOutputs() {
+			producers[pid] = id
+		}
+		for _, pid := range t.GetInputs() {
+			consumers[pid] = append(consumers[pid], id)
+		}
+	}
+
+	var stages []*stage
+	stageOf := map[string]*stage{}
+	for _, id := range order {
+		t := comps.GetTransforms()[id]
+		if !executedByHarness(t) {
+			st := &stage{transforms: []string{id}}
+			stages = append(stages, st)
+			stageOf[id] = st
+			continue
+		}
+		sides, err := sideInputs(t)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid transform %v", t.GetUniqueName())
+		}
+		if len(sides) == 0 && len(t.GetInputs()) == 1 {
+			for _, pid := range t.GetInputs() {
+				if st := stageOf[producers[pid]]; st != nil && st.fused {
+					st.transforms = append(st.transforms, id)
+					stageOf[id] = st
+				}
+			}
+			if stageOf[id] != nil {
+				continue
+			}
+		}
+		st := &stage{transforms: []string{id}, fused: true}
+		stages = append(stages, st)
+		stageOf[id] = st
+	}
+
+	for _, st := range stages {
+		seen := map[string]bool{}
+		for _, id := range st.transforms {
+			t := comps.GetTransforms()[id]
+			for _, pid := range t.GetInputs() {
+				if stageOf[producers[pid]] != st && !seen[pid] {
+					st.inputs = append(st.inputs, pid)
+					seen[pid] = true
+				}
+			}
+			for _, pid := range t.GetOutputs() {
+				for _, c := range consumers[pid] {
+					if stageOf[c] != st {
+						st.outputs = append(st.outputs, pid)
+						break
+					}
+				}
+			}
+		}
+	}
+	return stages, nil
+}
+
+// topological orders the leaf transforms so every transform comes after the
+// producers of its inputs. Transforms are otherwise kept in the given order.
+func topological(comps *pipepb.Components, leaves []string) ([]string, error) {
+	produced := map[string]bool{}
+	var order []string
+	for len(leaves) > 0 {
+		var blocked []string
+		for _, id := range leaves {
+			ready := true
+			for _, pid := range comps.GetTransforms()[id].GetInputs() {
+				ready = ready && produced[pid]
+			}
+			if !ready {
+				blocked = append(blocked, id)
+				continue
+			}
+			order = append(order, id)
+			for _, pid := range comps.GetTransforms()[id].GetOutputs() {
+				produced[pid] = true
+			}
+		}
+		if len(blocked) == len(leaves) {
+			return nil, errors.Errorf("inputs of transforms %v are never produced", blocked)
+		}
+		leaves = blocked
+	}
+	return order, nil
+}
+
+// executedByHarness returns whether the transform is executed by the harness
+// rather than by the runner.
+func executedByHarness(t *pipepb.PTransform) bool {
+	switch t.GetSpec().GetUrn() {
+	case graphx.URNParDo, graphx.URNWindow, urnCombinePrecombine, urnCombineMerge, urnCombineExtract:
+		return true
+	default:
+		return false
+	}
+}
+
+// sideInputs returns the local names of the side inputs of the transform.
+func sideInputs(t *pipepb.PTransform) (map[string]bool, error) {
+	sides := map[string]bool{}
+	if t.GetSpec().GetUrn() != graphx.URNParDo {
+		return sides, nil
+	}
+	var pardo pipepb.ParDoPayload
+	if err := proto.Unmarshal(t.GetSpec().GetPayload(), &pardo); err != nil {
+		return nil, errors.Wrap(err, "invalid ParDo payload")
+	}
+	for local := range pardo.GetSideInputs() {
+		sides[local] = true
+	}
+	return sides, nil
+}
@@ -0,0 +1,81 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prism
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/go/pkg/beam/transforms/stats"
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestFuse tests that combines are lifted and chains of transforms executed
+// by the harness are fused around the runner executed transforms.
+func TestFuse(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	squares := beam.ParDo(s, square, beam.Create(s, 1, 2, 3))
+	beam.ParDo(s, addSide, squares, beam.SideInput{Input: squares})
+	beam.ParDo(s, formatKV, stats.SumPerKey(s, beam.ParDo(s, keyByParity, squares)))
+
+	edges, _, err := p.Build()
+	if err != nil {
+		t.Fatalf("failed to build pipeline: %v", err)
+	}
+	pipeline, err := graphx.Marshal(edges, &graphx.Options{Environment: &pipepb.Environment{Urn: urnEnvExternal}})
+	if err != nil {
+		t.Fatalf("failed to marshal pipeline: %v", err)
+	}
+	e := &executor{comps: pipeline.GetComponents()}
+	leaves, err := e.leaves(pipeline.GetRootTransformIds())
+	if err != nil {
+		t.Fatalf("failed to lift combines: %v", err)
+	}
+	stages, err := fuse(e.comps, leaves)
+	if err != nil {
+		t.Fatalf("failed to fuse transforms: %v", err)
+	}
+
+	// The order of independent transforms and stages depends on the order
+	// of the marshalled transforms, so stages are compared as sorted lists.
+	var got []string
+	for _, st := range stages {
+		var urns []string
+		for _, id := range st.transforms {
+			urns = append(urns, e.comps.GetTransforms()[id].GetSpec().GetUrn())
+		}
+		sort.Strings(urns)
+		got = append(got, strings.Join(urns, ","))
+	}
+	sort.Strings(got)
+	want := []string{
+		graphx.URNImpulse,
+		graphx.URNGBK,
+		graphx.URNParDo, // addSide
+		// Create, square, keyByParity, the keying of the side input and the
+		// precombine.
+		strings.Join([]string{urnCombinePrecombine, graphx.URNParDo, graphx.URNParDo, graphx.URNParDo, graphx.URNParDo}, ","),
+		strings.Join([]string{urnCombineExtract, urnCombineMerge, graphx.URNParDo}, ","),
+	}
+	sort.Strings(want)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("fuse() stages differ (-want +got):\n%v", diff)
+	}
+}
@@ -0,0 +1,125 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prism
+
+import (
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	urnCombinePrecombine = "beam:transform:combine_per_key_precombine:v1"
+	urnCombineMerge      = "beam:transform:combine_per_key_merge_accumulators:v1"
+	urnCombineExtract    = "beam:transform:combine_per_key_extract_outputs:v1"
+
+	urnKVCoder       = "beam:coder:kv:v1"
+	urnIterableCoder = "beam:coder:iterable:v1"
+)
+
+// liftCombine replaces the CombinePerKey composite with a precombine of the
+// values of each key into accumulators before the grouping, and a merge of
+// the grouped accumulators and extraction of the outputs after it. This is
+// the combiner lifting of production runners: precombining in the fused
+// stage before the grouping shrinks the data grouped by the runner to one
+// accumulator per key and bundle. It returns the IDs of the lifted
+// transforms in order, and false if the composite is not liftable, in which
+// case it executes as a grouping followed by a combine.
+func liftCombine(comps *pipepb.Components, id string) ([]string, bool, error) {
+	t := comps.GetTransforms()[id]
+	if len(t.GetSubtransforms()) != 2 {
+		return nil, false, nil
+	}
+	gbk := comps.GetTransforms()[t.GetSubtransforms()[0]]
+	combine := comps.GetTransforms()[t.GetSubtransforms()[1]]
+	if gbk.GetSpec().GetUrn() != graphx.URNGBK || len(gbk.GetInputs()) != 1 || len(combine.GetOutputs()) != 1 {
+		return nil, false, nil
+	}
+	var payload pipepb.CombinePayload
+	if err := proto.Unmarshal(t.GetSpec().GetPayload(), &payload); err != nil {
+		return nil, false, errors.Wrap(err, "invalid combine payload")
+	}
+	var in, out string
+	for _, pid := range gbk.GetInputs() {
+		in = pid
+	}
+	for _, pid := range combine.GetOutputs() {
+		out = pid
+	}
+	col := comps.GetPcollections()[in]
+	ws := comps.GetWindowingStrategies()[col.GetWindowingStrategyId()]
+	if ws.GetMergeStatus() == pipepb.MergeStatus_NEEDS_MERGE {
+		// Accumulators of merging windows would have to be merged as their
+		// windows are, which the grouping does not do.
+		return nil, false, nil
+	}
+	kv := comps.GetCoders()[col.GetCoderId()]
+	if kv.GetSpec().GetUrn() != urnKVCoder || len(kv.GetComponentCoderIds()) != 2 {
+		return nil, false, errors.Errorf("input %v has a non-KV coder %v", in, col.GetCoderId())
+	}
+	key, acc := kv.GetComponentCoderIds()[0], payload.GetAccumulatorCoderId()
+
+	accsCoder := id + "_accumulators"
+	comps.Coders[id+"_keyed_accumulator"] = &pipepb.Coder{
+		Spec:              &pipepb.FunctionSpec{Urn: urnKVCoder},
+		ComponentCoderIds: []string{key, acc},
+	}
+	comps.Coders[accsCoder] = &pipepb.Coder{
+		Spec:              &pipepb.FunctionSpec{Urn: urnIterableCoder},
+		ComponentCoderIds: []string{acc},
+	}
+	comps.Coders[id+"_keyed_accumulators"] = &pipepb.Coder{
+		Spec:              &pipepb.FunctionSpec{Urn: urnKVCoder},
+		ComponentCoderIds: []string{key, accsCoder},
+	}
+	pcoll := func(pid, cid string) string {
+		comps.Pcollections[pid] = &pipepb.PCollection{
+			UniqueName:          pid,
+			CoderId:             cid,
+			IsBounded:           col.GetIsBounded(),
+			WindowingStrategyId: col.GetWindowingStrategyId(),
+		}
+		return pid
+	}
+	precombined := pcoll(id+"_precombined", id+"_keyed_accumulator")
+	grouped := pcoll(id+"_grouped", id+"_keyed_accumulators")
+	merged := pcoll(id+"_merged", id+"_keyed_accumulator")
+
+	spec := func(urn string) *pipepb.FunctionSpec {
+		return &pipepb.FunctionSpec{Urn: urn, Payload: t.GetSpec().GetPayload()}
+	}
+	lifted := []struct {
+		id, name string
+		t        *pipepb.PTransform
+	}{
+		{"_precombine", "/Precombine", &pipepb.PTransform{Spec: spec(urnCombinePrecombine), Inputs: map[string]string{"i0": in}, Outputs: map[string]string{"i0": precombined}}},
+		{"_group", "/Group", &pipepb.PTransform{Spec: &pipepb.FunctionSpec{Urn: graphx.URNGBK}, Inputs: map[string]string{"i0": precombined}, Outputs: map[string]string{"i0": grouped}}},
+		{"_merge", "/Merge", &pipepb.PTransform{Spec: spec(urnCombineMerge), Inputs: map[string]string{"i0": grouped}, Outputs: map[string]string{"i0": merged}}},
+		{"_extract", "/Extract", &pipepb.PTransform{Spec: spec(urnCombineExtract), Inputs: map[string]string{"i0": merged}, Outputs: map[string]string{"i0": out}}},
+	}
+	var ids []string
+	for _, l := range lifted {
+		l.t.UniqueName = t.GetUniqueName() + l.name
+		if l.t.GetSpec().GetUrn() != graphx.URNGBK {
+			l.t.EnvironmentId = combine.GetEnvironmentId()
+		}
+		comps.Transforms[id+l.id] = l.t
+		ids = append(ids, id+l.id)
+	}
+	t.Subtransforms = ids
+	return ids, true, nil
+}
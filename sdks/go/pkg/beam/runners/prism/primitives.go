@@ -0,0 +1,239 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prism
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+)
+
+// impulse produces a single empty byte slice in the global window.
+func (e *executor) impulse(t *pipepb.PTransform) error {
+	out := onlyValue(t.GetOutputs())
+	c, err := e.windowedCoder(out)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	v := &exec.FullValue{Elm: []byte{}, Timestamp: mtime.MinTimestamp, Windows: window.SingleGlobalWindow}
+	if err := exec.MakeElementEncoder(c).Encode(v, &buf); err != nil {
+		return err
+	}
+	e.data[out] = buf.Bytes()
+	return nil
+}
+
+// flatten re-encodes the elements of all inputs with the coder of the output.
+func (e *executor) flatten(t *pipepb.PTransform) error {
+	out := onlyValue(t.GetOutputs())
+	c, err := e.windowedCoder(out)
+	if err != nil {
+		return err
+	}
+	enc := exec.MakeElementEncoder(c)
+	var buf bytes.Buffer
+	for _, in := range sortedValues(t.GetInputs()) {
+		ic, err := e.windowedCoder(in)
+		if err != nil {
+			return err
+		}
+		values, err := decodeAll(ic, e.data[in])
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			if err := enc.Encode(v, &buf); err != nil {
+				return err
+			}
+		}
+	}
+	e.data[out] = buf.Bytes()
+	return nil
+}
+
+// group holds the encoded values of a key in a window.
+type group struct {
+	key    []byte
+	window typex.Window
+	min    typex.EventTime // earliest timestamp of the values
+	max    typex.EventTime // latest timestamp of the values
+	values [][]byte
+}
+
+func (g *group) add(t typex.EventTime, value []byte) {
+	if len(g.values) == 0 || t < g.min {
+		g.min = t
+	}
+	if len(g.values) == 0 || t > g.max {
+		g.max = t
+	}
+	g.values = append(g.values, value)
+}
+
+// groupByKey groups the values of each key and window. The windows of each
+// key are merged first, if the windowing strategy requires it.
+func (e *executor) groupByKey(t *pipepb.PTransform) error {
+	in, out := onlyValue(t.GetInputs()), onlyValue(t.GetOutputs())
+	ic, err := e.windowedCoder(in)
+	if err != nil {
+		return err
+	}
+	kv := coder.SkipW(ic)
+	if !coder.IsKV(kv) {
+		return errors.Errorf("input %v has a non-KV coder %v", in, kv)
+	}
+	oc, err := e.windowedCoder(out)
+	if err != nil {
+		return err
+	}
+	values, err := decodeAll(ic, e.data[in])
+	if err != nil {
+		return err
+	}
+	kEnc := exec.MakeElementEncoder(kv.Components[0])
+	vEnc := exec.MakeElementEncoder(kv.Components[1])
+	wEnc := exec.MakeWindowEncoder(ic.Window)
+
+	var keys []string               // in order of appearance
+	groups := map[string][]*group{} // key -> groups in order of appearance
+	index := map[[2]string]*group{} // key, window -> group
+	for _, v := range values {
+		k, err := exec.EncodeElement(kEnc, v.Elm)
+		if err != nil {
+			return err
+		}
+		val, err := exec.EncodeElement(vEnc, v.Elm2)
+		if err != nil {
+			return err
+		}
+		for _, w := range v.Windows {
+			win, err := exec.EncodeWindow(wEnc, w)
+			if err != nil {
+				return err
+			}
+			id := [2]string{string(k), string(win)}
+			g, ok := index[id]
+			if !ok {
+				if _, ok := groups[string(k)]; !ok {
+					keys = append(keys, string(k))
+				}
+				g = &group{key: k, window: w}
+				index[id] = g
+				groups[string(k)] = append(groups[string(k)], g)
+			}
+			g.add(v.Timestamp, val)
+		}
+	}
+
+	ws := e.windowingStrategy(in)
+	var buf bytes.Buffer
+	oEnc := exec.MakeWindowEncoder(oc.Window)
+	for _, k := range keys {
+		gs := groups[k]
+		if ws.GetMergeStatus() == pipepb.MergeStatus_NEEDS_MERGE {
+			if gs, err = mergeWindows(gs); err != nil {
+				return err
+			}
+		}
+		for _, g := range gs {
+			var ts typex.EventTime
+			switch ws.GetOutputTime() {
+			case pipepb.OutputTime_EARLIEST_IN_PANE:
+				ts = g.min
+			case pipepb.OutputTime_LATEST_IN_PANE:
+				ts = g.max
+			default:
+				ts = g.window.MaxTimestamp()
+			}
+			if err := exec.EncodeWindowedValueHeader(oEnc, []typex.Window{g.window}, ts, &buf); err != nil {
+				return err
+			}
+			buf.Write(g.key)
+			if err := coder.EncodeInt32(int32(len(g.values)), &buf); err != nil {
+				return err
+			}
+			for _, v := range g.values {
+				buf.Write(v)
+			}
+		}
+	}
+	e.data[out] = buf.Bytes()
+	return nil
+}
+
+// mergeWindows merges the groups of a key with overlapping interval windows,
+// as for sessions.
+func mergeWindows(gs []*group) ([]*group, error) {
+	for _, g := range gs {
+		if _, ok := g.window.(window.IntervalWindow); !ok {
+			return nil, errors.Errorf("cannot merge window %v", g.window)
+		}
+	}
+	sort.SliceStable(gs, func(i, j int) bool {
+		return gs[i].window.(window.IntervalWindow).Start < gs[j].window.(window.IntervalWindow).Start
+	})
+	merged := []*group{gs[0]}
+	for _, g := range gs[1:] {
+		last := merged[len(merged)-1]
+		lw, w := last.window.(window.IntervalWindow), g.window.(window.IntervalWindow)
+		if w.Start >= lw.End {
+			merged = append(merged, g)
+			continue
+		}
+		if w.End > lw.End {
+			lw.End = w.End
+		}
+		merged[len(merged)-1] = &group{
+			key:    last.key,
+			window: lw,
+			min:    mtime.Min(last.min, g.min),
+			max:    mtime.Max(last.max, g.max),
+			values: append(last.values, g.values...),
+		}
+	}
+	return merged, nil
+}
+
+// onlyValue returns the single value of a map of inputs or outputs.
+func onlyValue(m map[string]string) string {
+	for _, v := range m {
+		return v
+	}
+	return ""
+}
+
+// sortedValues returns the values of a map of inputs or outputs, ordered by
+// their local names.
+func sortedValues(m map[string]string) []string {
+	var locals []string
+	for local := range m {
+		locals = append(locals, local)
+	}
+	sort.Strings(locals)
+	values := make([]string, len(locals))
+	for i, local := range locals {
+		values[i] = m[local]
+	}
+	return values
+}
@@ -0,0 +1,110 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prism contains a portable runner that executes pipelines in
+// process, without Docker or a Java job server. It serves the job management
+// API, starts the SDK harness in LOOPBACK mode, and drives it over the real
+// Fn API control, data, state and logging channels, so pipelines run much as
+// they would on a production portable runner.
+//
+// Impulses, flattens and groupings are executed by the runner. Every other
+// transform is processed by the harness, with chains of them fused into
+// stages processed in a single bundle, as production runners do. Combines
+// are lifted, so values are combined into one accumulator per key and bundle
+// before they are grouped, except in merging windows. The elements of
+// PCollections between stages are kept in memory, so the runner is meant for
+// testing and jobs that fit in memory. Side inputs are served over the state
+// API, with global side inputs readable from any window. Other user state,
+// timers and cross-language transforms are not supported.
+//
+// By default, Execute starts a job service for the duration of the pipeline.
+// To share one across pipelines, start it with StartServer or the prism
+// command, and pass its address with --endpoint. Running pipelines with
+// --async requires such a shared job service.
+package prism
+
+import (
+	"context"
+	"os"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
+	"github.com/apache/beam/sdks/go/pkg/beam/runners/universal"
+	"github.com/apache/beam/sdks/go/pkg/beam/runners/universal/runnerlib"
+)
+
+func init() {
+	beam.RegisterRunner("prism", Execute)
+}
+
+// Execute runs the pipeline on a prism job service, with the SDK harness in
+// this process. If no --endpoint is given, it starts a job service for the
+// duration of the call.
+func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
+	if *jobopts.ValidateOnly {
+		if err := validatePipeline(ctx, p); err != nil {
+			return nil, err
+		}
+		return runnerlib.ValidatedPipelineResult(), nil
+	}
+	if *jobopts.Endpoint == "" {
+		s, err := StartServer(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer s.Stop()
+		*jobopts.Endpoint = s.Endpoint()
+		defer func() { *jobopts.Endpoint = "" }()
+	}
+	env := *jobopts.EnvironmentType
+	*jobopts.EnvironmentType = "LOOPBACK"
+	defer func() { *jobopts.EnvironmentType = env }()
+	if *jobopts.WorkerBinary == "" {
+		// The harness runs in this process, so the job service needs no worker
+		// binary. Naming this one avoids building one to stage.
+		*jobopts.WorkerBinary = os.Args[0]
+		defer func() { *jobopts.WorkerBinary = "" }()
+	}
+
+	return universal.Execute(ctx, p)
+}
+
+// validatePipeline translates the pipeline to a model pipeline, as Execute
+// does, and checks that prism executes it.
+func validatePipeline(ctx context.Context, p *beam.Pipeline) error {
+	edges, _, err := p.Build()
+	if err != nil {
+		return err
+	}
+	if err := graphx.CheckStateless(edges); err != nil {
+		return err
+	}
+	env, err := graphx.CreateEnvironment(ctx, urnEnvExternal, func(context.Context) string { return "" })
+	if err != nil {
+		return errors.WithContextf(err, "generating model pipeline")
+	}
+	pipeline, err := graphx.Marshal(edges, &graphx.Options{Environment: env})
+	if err != nil {
+		return errors.WithContextf(err, "generating model pipeline")
+	}
+	if err := validate(pipeline); err != nil {
+		return err
+	}
+	log.Info(ctx, "Validate-only: pipeline is valid, not running it.")
+	return nil
+}
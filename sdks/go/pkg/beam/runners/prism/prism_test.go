@@ -0,0 +1,265 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prism
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/teststream"
+	"github.com/apache/beam/sdks/go/pkg/beam/transforms/stats"
+)
+
+func init() {
+	beam.RegisterFunction(square)
+	beam.RegisterFunction(addSide)
+	beam.RegisterFunction(keyByParity)
+	beam.RegisterFunction(countValues)
+	beam.RegisterFunction(formatKV)
+	beam.RegisterFunction(timestamped)
+	beam.RegisterFunction(failOnTwo)
+	beam.RegisterType(reflect.TypeOf((*countFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*finalizeFn)(nil)).Elem())
+}
+
+func TestMain(m *testing.M) {
+	ptest.MainWithDefault(m, "prism")
+}
+
+func square(x int) int {
+	return x * x
+}
+
+func addSide(x int, side []int) int {
+	for _, s := range side {
+		x += s
+	}
+	return x
+}
+
+func keyByParity(x int) (string, int) {
+	if x%2 == 0 {
+		return "even", x
+	}
+	return "odd", x
+}
+
+func countValues(k string, values func(*int) bool) (string, int) {
+	var n, v int
+	for values(&v) {
+		n++
+	}
+	return k, n
+}
+
+func formatKV(k string, v int) string {
+	return fmt.Sprintf("%v:%v", k, v)
+}
+
+func timestamped(x int) (beam.EventTime, string, int) {
+	return mtime.FromMilliseconds(int64(x) * 1000), "k", x
+}
+
+func failOnTwo(x int) (int, error) {
+	if x == 2 {
+		return 0, errors.New("two")
+	}
+	return x, nil
+}
+
+// countFn counts its elements in a user counter.
+type countFn struct{}
+
+func (fn *countFn) ProcessElement(ctx context.Context, x int) int {
+	beam.NewCounter("prism", "elements").Inc(ctx, 1)
+	return x
+}
+
+// finalized counts the elements whose finalization callbacks ran, since the
+// harness runs them in this process.
+var finalized int64
+
+// finalizeFn registers a finalization callback for each element.
+type finalizeFn struct{}
+
+func (fn *finalizeFn) ProcessElement(bf beam.BundleFinalization, x int) {
+	bf.RegisterCallback(time.Hour, func() error {
+		atomic.AddInt64(&finalized, 1)
+		return nil
+	})
+}
+
+func TestParDo(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, square, beam.Create(s, 1, 2, 3))
+	passert.Equals(s, col, 1, 4, 9)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestSideInput(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	side := beam.Create(s, 10, 20)
+	col := beam.ParDo(s, addSide, beam.Create(s, 1, 2), beam.SideInput{Input: side})
+	passert.Equals(s, col, 31, 32)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestGroupByKey(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, keyByParity, beam.Create(s, 1, 2, 3, 4, 5))
+	counts := beam.ParDo(s, countValues, beam.GroupByKey(s, keyed))
+	passert.Equals(s, beam.ParDo(s, formatKV, counts), "even:2", "odd:3")
+	ptest.RunAndValidate(t, p)
+}
+
+func TestCombinePerKey(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, keyByParity, beam.Create(s, 1, 2, 3, 4, 5))
+	passert.Equals(s, beam.ParDo(s, formatKV, stats.SumPerKey(s, keyed)), "even:6", "odd:9")
+	ptest.RunAndValidate(t, p)
+}
+
+func TestFlatten(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Flatten(s, beam.Create(s, 1, 2), beam.Create(s, 3))
+	passert.Sum(s, col, "flattened", 3, 6)
+	ptest.RunAndValidate(t, p)
+}
+
+// TestSessions tests that groupings merge session windows.
+func TestSessions(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, timestamped, beam.Create(s, 1, 2, 3, 10, 11))
+	windowed := beam.WindowInto(s, window.NewSessions(5*time.Second), keyed)
+	counts := beam.ParDo(s, countValues, beam.GroupByKey(s, windowed))
+	// The harness reads side inputs in the window of the main input, so the
+	// counts are asserted in the global window.
+	counts = beam.WindowInto(s, window.NewGlobalWindows(), counts)
+	passert.Equals(s, beam.DropKey(s, counts), 3, 2)
+	ptest.RunAndValidate(t, p)
+}
+
+// TestCombinePerKey_Large tests lifted combines of values spread over many
+// data chunks.
+func TestCombinePerKey_Large(t *testing.T) {
+	var values []int
+	for i := 0; i < 200000; i++ {
+		values = append(values, i%10)
+	}
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, keyByParity, beam.CreateList(s, values))
+	passert.Equals(s, beam.ParDo(s, formatKV, stats.SumPerKey(s, keyed)), "even:400000", "odd:500000")
+	ptest.RunAndValidate(t, p)
+}
+
+// TestCombinePerKey_Sessions tests that combines in merging windows, which
+// are not lifted, merge session windows.
+func TestCombinePerKey_Sessions(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	keyed := beam.ParDo(s, timestamped, beam.Create(s, 1, 2, 3, 10, 11))
+	windowed := beam.WindowInto(s, window.NewSessions(5*time.Second), keyed)
+	sums := beam.WindowInto(s, window.NewGlobalWindows(), stats.SumPerKey(s, windowed))
+	passert.Equals(s, beam.DropKey(s, sums), 6, 21)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestMetrics(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	beam.ParDo(s, &countFn{}, beam.Create(s, 1, 2, 3))
+	res, err := beam.Run(context.Background(), "prism", p)
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	counters := res.Metrics().AllMetrics().Counters()
+	if len(counters) != 1 || counters[0].Key.Name != "elements" || counters[0].Result() != 3 {
+		t.Errorf("counters = %+v, want one elements counter of 3", counters)
+	}
+}
+
+// TestBundleFinalization tests that the harness is asked to finalize the
+// bundles whose DoFns registered callbacks.
+func TestBundleFinalization(t *testing.T) {
+	atomic.StoreInt64(&finalized, 0)
+	p, s := beam.NewPipelineWithRoot()
+	beam.ParDo0(s, &finalizeFn{}, beam.Create(s, 1, 2, 3))
+	if err := ptest.Run(p); err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if got := atomic.LoadInt64(&finalized); got != 3 {
+		t.Errorf("finalized %v elements, want 3", got)
+	}
+}
+
+func TestFailure(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	beam.ParDo(s, failOnTwo, beam.Create(s, 1, 2, 3))
+	if err := ptest.Run(p); err == nil {
+		t.Error("pipeline succeeded, want error from the failing DoFn")
+	}
+}
+
+// TestFailure_Metrics tests that the metrics of failed bundles are attempted,
+// but not committed.
+func TestFailure_Metrics(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	beam.ParDo(s, failOnTwo, beam.ParDo(s, &countFn{}, beam.Create(s, 1, 2, 3)))
+	res, err := beam.Run(context.Background(), "prism", p)
+	if err == nil {
+		t.Fatal("pipeline succeeded, want error from the failing DoFn")
+	}
+	if res == nil {
+		t.Fatalf("pipeline failed without a result: %v", err)
+	}
+	counters := res.Metrics().AllMetrics().Counters()
+	if len(counters) != 1 || counters[0].Attempted == 0 || counters[0].HasCommitted {
+		t.Errorf("counters = %+v, want one attempted elements counter without a committed value", counters)
+	}
+}
+
+// TestValidateOnly tests that pipelines are validated but not run in
+// validate-only mode.
+func TestValidateOnly(t *testing.T) {
+	*jobopts.ValidateOnly = true
+	defer func() { *jobopts.ValidateOnly = false }()
+
+	p, s := beam.NewPipelineWithRoot()
+	beam.ParDo(s, failOnTwo, beam.Create(s, 1, 2, 3))
+	pr, err := Execute(context.Background(), p)
+	if err != nil {
+		t.Errorf("validation failed: %v", err)
+	} else if pr == nil || pr.JobID() != "" {
+		t.Errorf("Execute() = %v, want an empty result", pr)
+	}
+
+	p, s = beam.NewPipelineWithRoot()
+	cfg := teststream.NewConfig()
+	cfg.AddElements(1000, "a")
+	teststream.Create(s, cfg)
+	if err := ptest.Run(p); err == nil {
+		t.Error("validation succeeded, want error for unsupported TestStream")
+	}
+}
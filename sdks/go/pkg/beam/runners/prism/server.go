@@ -0,0 +1,356 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prism
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/ptypes"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"google.golang.org/grpc"
+)
+
+// Server is a job service that executes jobs in this process. It also serves
+// as the artifact staging service of its jobs, but discards the artifacts,
+// because the SDK harness runs in this process too.
+type Server struct {
+	jobpb.UnimplementedJobServiceServer
+	jobpb.UnimplementedArtifactStagingServiceServer
+
+	lis    net.Listener
+	server *grpc.Server
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	next int
+}
+
+// StartServer starts a job service at the given port of localhost. If the
+// port is 0, a free port is chosen.
+func StartServer(ctx context.Context, port int) (*Server, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return nil, err
+	}
+	log.Infof(ctx, "starting prism job service at %v", lis.Addr())
+	s := &Server{lis: lis, server: grpc.NewServer(), jobs: map[string]*job{}}
+	jobpb.RegisterJobServiceServer(s.server, s)
+	jobpb.RegisterArtifactStagingServiceServer(s.server, s)
+	go s.server.Serve(lis)
+	return s, nil
+}
+
+// Endpoint returns the address of the job service.
+func (s *Server) Endpoint() string {
+	return s.lis.Addr().String()
+}
+
+// Stop cancels all jobs and stops the job service.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		j.stop()
+	}
+	s.mu.Unlock()
+	s.server.Stop()
+}
+
+// Prepare registers a job, with the same ID as its preparation.
+func (s *Server) Prepare(ctx context.Context, req *jobpb.PrepareJobRequest) (*jobpb.PrepareJobResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	id := fmt.Sprintf("job-%03d", s.next)
+	j := &job{
+		id:       id,
+		name:     req.GetJobName(),
+		pipeline: req.GetPipeline(),
+		options:  req.GetPipelineOptions(),
+		cancel:   func() {},
+		state:    jobpb.JobState_STOPPED,
+	}
+	j.cond = sync.NewCond(&j.mu)
+	s.jobs[id] = j
+	return &jobpb.PrepareJobResponse{
+		PreparationId:           id,
+		ArtifactStagingEndpoint: &pipepb.ApiServiceDescriptor{Url: s.Endpoint()},
+		StagingSessionToken:     id,
+	}, nil
+}
+
+// ReverseArtifactRetrievalService accepts the staging session of a job
+// without requesting any artifacts.
+func (s *Server) ReverseArtifactRetrievalService(stream jobpb.ArtifactStagingService_ReverseArtifactRetrievalServiceServer) error {
+	resp, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	_, err = s.lookup(resp.GetStagingToken())
+	return err
+}
+
+// Run starts executing a prepared job.
+func (s *Server) Run(ctx context.Context, req *jobpb.RunJobRequest) (*jobpb.RunJobResponse, error) {
+	j, err := s.lookup(req.GetPreparationId())
+	if err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	if j.state != jobpb.JobState_STOPPED {
+		j.mu.Unlock()
+		return nil, errors.Errorf("job %v has already been run", j.id)
+	}
+	ctx, j.cancel = context.WithCancel(context.Background())
+	j.mu.Unlock()
+
+	j.setState(jobpb.JobState_RUNNING)
+	go func() {
+		if err := execute(ctx, j.id, j.pipeline, j.addMetrics); err != nil {
+			j.message(jobpb.JobMessage_JOB_MESSAGE_ERROR, err.Error())
+			j.setState(jobpb.JobState_FAILED)
+			return
+		}
+		j.setState(jobpb.JobState_DONE)
+	}()
+	return &jobpb.RunJobResponse{JobId: j.id}, nil
+}
+
+// GetJobs returns all jobs of the job service.
+func (s *Server) GetJobs(ctx context.Context, req *jobpb.GetJobsRequest) (*jobpb.GetJobsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var infos []*jobpb.JobInfo
+	for _, j := range s.jobs {
+		infos = append(infos, &jobpb.JobInfo{
+			JobId:           j.id,
+			JobName:         j.name,
+			PipelineOptions: j.options,
+			State:           j.currentState(),
+		})
+	}
+	sort.Slice(infos, func(i, k int) bool { return infos[i].GetJobId() < infos[k].GetJobId() })
+	return &jobpb.GetJobsResponse{JobInfo: infos}, nil
+}
+
+// GetState returns the current state of a job.
+func (s *Server) GetState(ctx context.Context, req *jobpb.GetJobStateRequest) (*jobpb.JobStateEvent, error) {
+	j, err := s.lookup(req.GetJobId())
+	if err != nil {
+		return nil, err
+	}
+	return &jobpb.JobStateEvent{State: j.currentState(), Timestamp: ptypes.TimestampNow()}, nil
+}
+
+// GetPipeline returns the pipeline of a job.
+func (s *Server) GetPipeline(ctx context.Context, req *jobpb.GetJobPipelineRequest) (*jobpb.GetJobPipelineResponse, error) {
+	j, err := s.lookup(req.GetJobId())
+	if err != nil {
+		return nil, err
+	}
+	return &jobpb.GetJobPipelineResponse{Pipeline: j.pipeline}, nil
+}
+
+// Cancel cancels a job, unless it has already finished.
+func (s *Server) Cancel(ctx context.Context, req *jobpb.CancelJobRequest) (*jobpb.CancelJobResponse, error) {
+	j, err := s.lookup(req.GetJobId())
+	if err != nil {
+		return nil, err
+	}
+	j.setState(jobpb.JobState_CANCELLED)
+	j.stop()
+	return &jobpb.CancelJobResponse{State: j.currentState()}, nil
+}
+
+// GetStateStream streams the state changes of a job, until it finishes.
+func (s *Server) GetStateStream(req *jobpb.GetJobStateRequest, stream jobpb.JobService_GetStateStreamServer) error {
+	j, err := s.lookup(req.GetJobId())
+	if err != nil {
+		return err
+	}
+	return j.updates(stream.Context(), func(msg *jobpb.JobMessagesResponse) error {
+		if state := msg.GetStateResponse(); state != nil {
+			return stream.Send(state)
+		}
+		return nil
+	})
+}
+
+// GetMessageStream streams the messages and state changes of a job, until it
+// finishes.
+func (s *Server) GetMessageStream(req *jobpb.JobMessagesRequest, stream jobpb.JobService_GetMessageStreamServer) error {
+	j, err := s.lookup(req.GetJobId())
+	if err != nil {
+		return err
+	}
+	return j.updates(stream.Context(), stream.Send)
+}
+
+// GetJobMetrics returns the metrics of a job. The attempted metrics are those
+// of all bundles, including failed ones, while the committed metrics are those
+// of the bundles that succeeded.
+func (s *Server) GetJobMetrics(ctx context.Context, req *jobpb.GetJobMetricsRequest) (*jobpb.GetJobMetricsResponse, error) {
+	j, err := s.lookup(req.GetJobId())
+	if err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return &jobpb.GetJobMetricsResponse{
+		Metrics: &jobpb.MetricResults{Attempted: j.attempted, Committed: j.committed},
+	}, nil
+}
+
+// DescribePipelineOptions returns no options, since the runner has none.
+func (s *Server) DescribePipelineOptions(ctx context.Context, req *jobpb.DescribePipelineOptionsRequest) (*jobpb.DescribePipelineOptionsResponse, error) {
+	return &jobpb.DescribePipelineOptionsResponse{}, nil
+}
+
+func (s *Server) lookup(id string) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, errors.Errorf("unknown job %v", id)
+	}
+	return j, nil
+}
+
+// job is a job of the job service. Its messages and state changes are kept,
+// so they can be streamed from the start to any number of clients.
+type job struct {
+	id       string
+	name     string
+	pipeline *pipepb.Pipeline
+	options  *structpb.Struct
+
+	mu     sync.Mutex
+	cond   *sync.Cond // signalled on new messages
+	cancel context.CancelFunc
+	state  jobpb.JobState_Enum
+	msgs   []*jobpb.JobMessagesResponse
+	// attempted are the metrics of all bundles, and committed those
+	// of the bundles that succeeded.
+	attempted, committed []*pipepb.MonitoringInfo
+}
+
+// stop cancels the execution of the job.
+func (j *job) stop() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.cancel()
+}
+
+func (j *job) currentState() jobpb.JobState_Enum {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// setState changes the state of the job, unless it has already finished.
+func (j *job) setState(state jobpb.JobState_Enum) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if isTerminal(j.state) {
+		return
+	}
+	j.state = state
+	j.msgs = append(j.msgs, &jobpb.JobMessagesResponse{
+		Response: &jobpb.JobMessagesResponse_StateResponse{
+			StateResponse: &jobpb.JobStateEvent{State: state, Timestamp: ptypes.TimestampNow()},
+		},
+	})
+	j.cond.Broadcast()
+}
+
+func (j *job) message(importance jobpb.JobMessage_MessageImportance, text string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.msgs = append(j.msgs, &jobpb.JobMessagesResponse{
+		Response: &jobpb.JobMessagesResponse_MessageResponse{
+			MessageResponse: &jobpb.JobMessage{
+				MessageId:   fmt.Sprintf("%v-%v", j.id, len(j.msgs)),
+				Time:        time.Now().Format(time.RFC3339),
+				Importance:  importance,
+				MessageText: text,
+			},
+		},
+	})
+	j.cond.Broadcast()
+}
+
+// addMetrics records the user metrics of a bundle, which are committed if the
+// bundle succeeded. The other metrics reported by the harness describe the
+// data channels of the bundle, which are an artifact of how the runner stages
+// transforms.
+func (j *job) addMetrics(mons []*pipepb.MonitoringInfo, committed bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, mon := range mons {
+		if strings.HasPrefix(mon.GetUrn(), "beam:metric:user:") {
+			j.attempted = append(j.attempted, mon)
+			if committed {
+				j.committed = append(j.committed, mon)
+			}
+		}
+	}
+}
+
+// updates passes all messages of the job to the given function, waiting for
+// new ones until the job finishes or the context is done.
+func (j *job) updates(ctx context.Context, send func(*jobpb.JobMessagesResponse) error) error {
+	go func() {
+		<-ctx.Done()
+		j.mu.Lock()
+		j.cond.Broadcast()
+		j.mu.Unlock()
+	}()
+	for i := 0; ; {
+		j.mu.Lock()
+		for i == len(j.msgs) && !isTerminal(j.state) && ctx.Err() == nil {
+			j.cond.Wait()
+		}
+		msgs, done := j.msgs[i:], isTerminal(j.state)
+		j.mu.Unlock()
+
+		for _, msg := range msgs {
+			if err := send(msg); err != nil {
+				return err
+			}
+		}
+		i += len(msgs)
+		if done || ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func isTerminal(state jobpb.JobState_Enum) bool {
+	switch state {
+	case jobpb.JobState_DONE, jobpb.JobState_FAILED, jobpb.JobState_CANCELLED, jobpb.JobState_UPDATED, jobpb.JobState_DRAINED:
+		return true
+	}
+	return false
+}
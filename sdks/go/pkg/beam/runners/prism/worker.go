@@ -0,0 +1,438 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prism
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"google.golang.org/grpc"
+)
+
+// chunkSize is the maximum number of bytes of input sent in one data message.
+const chunkSize = 1 << 20
+
+// worker serves the Fn API to a single SDK harness. The control, data, state
+// and logging services share one server, so one endpoint is handed to the
+// harness for all of them.
+type worker struct {
+	fnpb.UnimplementedBeamFnControlServer
+	fnpb.UnimplementedBeamFnDataServer
+	fnpb.UnimplementedBeamFnStateServer
+	fnpb.UnimplementedBeamFnLoggingServer
+
+	id     string
+	lis    net.Listener
+	server *grpc.Server
+
+	instructions chan *fnpb.InstructionRequest
+	data         chan *fnpb.Elements
+	stopped      chan struct{} // closed by stop
+	lost         chan struct{} // closed when the control stream breaks
+	lostOnce     sync.Once
+
+	mu      sync.Mutex
+	descs   map[string]*fnpb.ProcessBundleDescriptor
+	bundles map[string]*bundle
+	next    int
+}
+
+// newWorker starts the Fn API services for a worker with the given ID, on a
+// free port of localhost.
+func newWorker(id string) (*worker, error) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen for worker %v", id)
+	}
+	w := &worker{
+		id:           id,
+		lis:          lis,
+		server:       grpc.NewServer(),
+		instructions: make(chan *fnpb.InstructionRequest),
+		data:         make(chan *fnpb.Elements, 10),
+		stopped:      make(chan struct{}),
+		lost:         make(chan struct{}),
+		descs:        map[string]*fnpb.ProcessBundleDescriptor{},
+		bundles:      map[string]*bundle{},
+	}
+	fnpb.RegisterBeamFnControlServer(w.server, w)
+	fnpb.RegisterBeamFnDataServer(w.server, w)
+	fnpb.RegisterBeamFnStateServer(w.server, w)
+	fnpb.RegisterBeamFnLoggingServer(w.server, w)
+	go w.server.Serve(lis)
+	return w, nil
+}
+
+// endpoint returns the address of the Fn API services.
+func (w *worker) endpoint() string {
+	return w.lis.Addr().String()
+}
+
+// stop ends the streams of the harness, which makes it exit, and shuts down
+// the services.
+func (w *worker) stop() {
+	close(w.stopped)
+	w.server.GracefulStop()
+}
+
+// Control sends instructions to the harness, and routes its responses to the
+// bundles awaiting them.
+func (w *worker) Control(stream fnpb.BeamFnControl_ControlServer) error {
+	go func() {
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				w.lostOnce.Do(func() { close(w.lost) })
+				return
+			}
+			if b, ok := w.bundle(resp.GetInstructionId()); ok {
+				b.resp <- resp
+			}
+		}
+	}()
+	for {
+		select {
+		case req := <-w.instructions:
+			if err := stream.Send(req); err != nil {
+				return err
+			}
+		case <-w.stopped:
+			return nil
+		}
+	}
+}
+
+// GetProcessBundleDescriptor returns a descriptor registered by process.
+func (w *worker) GetProcessBundleDescriptor(ctx context.Context, req *fnpb.GetProcessBundleDescriptorRequest) (*fnpb.ProcessBundleDescriptor, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	desc, ok := w.descs[req.GetProcessBundleDescriptorId()]
+	if !ok {
+		return nil, errors.Errorf("unknown process bundle descriptor %v", req.GetProcessBundleDescriptorId())
+	}
+	return desc, nil
+}
+
+// Data sends input elements to the harness, and collects the output
+// elements of each bundle.
+func (w *worker) Data(stream fnpb.BeamFnData_DataServer) error {
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			for _, d := range msg.GetData() {
+				if b, ok := w.bundle(d.GetInstructionId()); ok {
+					b.receive(d)
+				}
+			}
+		}
+	}()
+	for {
+		select {
+		case msg := <-w.data:
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		case <-w.stopped:
+			return nil
+		}
+	}
+}
+
+// State serves side inputs to the harness. No other state is supported.
+func (w *worker) State(stream fnpb.BeamFnState_StateServer) error {
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				errc <- err
+				return
+			}
+			resp := &fnpb.StateResponse{Id: req.GetId()}
+			if data, err := w.state(req); err != nil {
+				resp.Error = err.Error()
+			} else {
+				resp.Response = &fnpb.StateResponse_Get{Get: &fnpb.StateGetResponse{Data: data}}
+			}
+			if err := stream.Send(resp); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-w.stopped:
+		return nil
+	}
+}
+
+func (w *worker) state(req *fnpb.StateRequest) ([]byte, error) {
+	key := req.GetStateKey().GetMultimapSideInput()
+	if req.GetGet() == nil || key == nil {
+		return nil, errors.Errorf("unsupported state request: %v", req)
+	}
+	b, ok := w.bundle(req.GetInstructionId())
+	if !ok {
+		return nil, errors.Errorf("state request for unknown bundle %v", req.GetInstructionId())
+	}
+	side, ok := b.sides[sideID{key.GetTransformId(), key.GetSideInputId()}]
+	if !ok {
+		return nil, errors.Errorf("unknown side input %v of transform %v", key.GetSideInputId(), key.GetTransformId())
+	}
+	return side.get(key.GetWindow(), key.GetKey()), nil
+}
+
+// Logging prints the log entries of the harness to stderr. Debug entries are
+// dropped. The entries are not logged through the log package, because a
+// harness running in this process redirects that package to this service.
+func (w *worker) Logging(stream fnpb.BeamFnLogging_LoggingServer) error {
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			list, err := stream.Recv()
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				errc <- err
+				return
+			}
+			for _, e := range list.GetLogEntries() {
+				if e.GetSeverity() < fnpb.LogEntry_Severity_INFO {
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "%v %v: %v\n", e.GetSeverity(), e.GetLogLocation(), e.GetMessage())
+			}
+		}
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-w.stopped:
+		return nil
+	}
+}
+
+func (w *worker) bundle(id string) (*bundle, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b, ok := w.bundles[id]
+	return b, ok
+}
+
+// process runs a bundle of the given descriptor on the harness. It sends the
+// encoded input of each data source transform, and returns the encoded output
+// of each data sink transform along with the metrics of the bundle. The
+// metrics reported by a failed bundle are returned along with its error.
+func (w *worker) process(ctx context.Context, desc *fnpb.ProcessBundleDescriptor, inputs map[string][]byte, sides map[sideID]*sideInput) (map[string][]byte, []*pipepb.MonitoringInfo, error) {
+	var sinks []string
+	for id, t := range desc.GetTransforms() {
+		if t.GetSpec().GetUrn() == urnDataSink {
+			sinks = append(sinks, id)
+		}
+	}
+
+	w.mu.Lock()
+	w.next++
+	id := fmt.Sprintf("%v-inst%03d", w.id, w.next)
+	b := newBundle(sinks, sides)
+	w.descs[desc.GetId()] = desc
+	w.bundles[id] = b
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.bundles, id)
+		w.mu.Unlock()
+	}()
+
+	req := &fnpb.InstructionRequest{
+		InstructionId: id,
+		Request: &fnpb.InstructionRequest_ProcessBundle{
+			ProcessBundle: &fnpb.ProcessBundleRequest{ProcessBundleDescriptorId: desc.GetId()},
+		},
+	}
+	if err := w.sendInstruction(ctx, req); err != nil {
+		return nil, nil, err
+	}
+	for tid, data := range inputs {
+		for {
+			n := len(data)
+			if n > chunkSize {
+				n = chunkSize
+			}
+			if n > 0 {
+				msg := &fnpb.Elements{Data: []*fnpb.Elements_Data{{InstructionId: id, TransformId: tid, Data: data[:n]}}}
+				if err := w.sendData(ctx, msg); err != nil {
+					return nil, nil, err
+				}
+			}
+			data = data[n:]
+			if len(data) == 0 {
+				break
+			}
+		}
+		// The end of the input is marked by an empty message.
+		msg := &fnpb.Elements{Data: []*fnpb.Elements_Data{{InstructionId: id, TransformId: tid, IsLast: true}}}
+		if err := w.sendData(ctx, msg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var resp *fnpb.InstructionResponse
+	select {
+	case resp = <-b.resp:
+	case <-w.lost:
+		return nil, nil, errors.Errorf("worker %v disconnected during bundle %v", w.id, id)
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	if resp.GetError() != "" {
+		return nil, resp.GetProcessBundle().GetMonitoringInfos(), errors.Errorf("bundle %v failed: %v", id, resp.GetError())
+	}
+	select {
+	case <-b.done:
+	case <-w.lost:
+		return nil, nil, errors.Errorf("worker %v disconnected during bundle %v", w.id, id)
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	// The outputs are committed once received, since they're kept in memory.
+	if resp.GetProcessBundle().GetRequiresFinalization() {
+		if err := w.finalize(ctx, id); err != nil {
+			return nil, resp.GetProcessBundle().GetMonitoringInfos(), err
+		}
+	}
+	return b.outputs, resp.GetProcessBundle().GetMonitoringInfos(), nil
+}
+
+// finalize asks the harness to finalize the committed bundle with the given
+// instruction ID, which runs the finalization callbacks of its DoFns.
+func (w *worker) finalize(ctx context.Context, ref string) error {
+	w.mu.Lock()
+	w.next++
+	id := fmt.Sprintf("%v-inst%03d", w.id, w.next)
+	b := newBundle(nil, nil)
+	w.bundles[id] = b
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.bundles, id)
+		w.mu.Unlock()
+	}()
+
+	req := &fnpb.InstructionRequest{
+		InstructionId: id,
+		Request: &fnpb.InstructionRequest_FinalizeBundle{
+			FinalizeBundle: &fnpb.FinalizeBundleRequest{InstructionId: ref},
+		},
+	}
+	if err := w.sendInstruction(ctx, req); err != nil {
+		return err
+	}
+	select {
+	case resp := <-b.resp:
+		if resp.GetError() != "" {
+			return errors.Errorf("finalizing bundle %v failed: %v", ref, resp.GetError())
+		}
+		return nil
+	case <-w.lost:
+		return errors.Errorf("worker %v disconnected while finalizing bundle %v", w.id, ref)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sendInstruction sends an instruction to the harness, once it is connected.
+func (w *worker) sendInstruction(ctx context.Context, req *fnpb.InstructionRequest) error {
+	select {
+	case w.instructions <- req:
+		return nil
+	case <-w.lost:
+		return errors.Errorf("worker %v disconnected", w.id)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sendData sends elements to the harness, once it is connected.
+func (w *worker) sendData(ctx context.Context, msg *fnpb.Elements) error {
+	select {
+	case w.data <- msg:
+		return nil
+	case <-w.lost:
+		return errors.Errorf("worker %v disconnected", w.id)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// bundle tracks a bundle being processed by the harness.
+type bundle struct {
+	resp  chan *fnpb.InstructionResponse
+	sides map[sideID]*sideInput
+
+	mu      sync.Mutex
+	outputs map[string][]byte // data sink transform ID -> encoded elements
+	pending map[string]bool   // data sink transforms yet to finish
+	done    chan struct{}     // closed when all data sinks have finished
+}
+
+func newBundle(sinks []string, sides map[sideID]*sideInput) *bundle {
+	b := &bundle{
+		resp:    make(chan *fnpb.InstructionResponse, 1),
+		sides:   sides,
+		outputs: map[string][]byte{},
+		pending: map[string]bool{},
+		done:    make(chan struct{}),
+	}
+	for _, id := range sinks {
+		b.pending[id] = true
+	}
+	if len(sinks) == 0 {
+		close(b.done)
+	}
+	return b
+}
+
+func (b *bundle) receive(d *fnpb.Elements_Data) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.pending[d.GetTransformId()] {
+		return
+	}
+	b.outputs[d.GetTransformId()] = append(b.outputs[d.GetTransformId()], d.GetData()...)
+	if d.GetIsLast() {
+		delete(b.pending, d.GetTransformId())
+		if len(b.pending) == 0 {
+			close(b.done)
+		}
+	}
+}
@@ -14,13 +14,44 @@
 // limitations under the License.
 
 // Package samza contains the Samza runner.
+//
+// Without --endpoint, the runner manages a job server itself, as the Flink
+// and Spark runners do: it downloads the Samza job server jar matching the
+// SDK version, starts it locally, submits the pipeline to it and stops it
+// once Execute returns.
+//
+// The Samza flags map to the pipeline options of the Samza runner:
+//
+//	--samza_execution_environment   samzaExecutionEnvironment (LOCAL, STANDALONE or YARN)
+//	--samza_config_file             configFilePath
+//	--samza_config_override         configOverride
+//	--samza_max_source_parallelism  maxSourceParallelism
+//
+// Workers started by a LOCAL job run on the host of the job server, so they
+// can use the LOOPBACK environment. STANDALONE and YARN jobs run their
+// workers on the hosts of the Samza cluster, so they need the DOCKER or
+// PROCESS environment, with the worker binary staged as an artifact.
 package samza
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
 	"github.com/apache/beam/sdks/go/pkg/beam/runners/universal"
+	"github.com/apache/beam/sdks/go/pkg/beam/runners/universal/jobserver"
+)
+
+var (
+	executionEnvironment = flag.String("samza_execution_environment", "LOCAL", "Samza execution environment: LOCAL, STANDALONE or YARN (optional).")
+	configFile           = flag.String("samza_config_file", "", "Path of a Samza config file, read by the job server (optional).")
+	configOverride       = flag.String("samza_config_override", "", "JSON-formatted map[string]string of Samza configs overriding the config file (optional).")
+	maxSourceParallelism = flag.Int("samza_max_source_parallelism", 0, "Maximum parallelism of sources (optional).")
+	jobServerJar         = flag.String("samza_job_server_jar", "", "Path or URL of the jar of the managed job server, instead of the released jar (optional).")
+	jobServerImage       = flag.String("samza_job_server_image", "", "Docker image of the managed job server, which is run as a container instead of a jar (optional).")
 )
 
 func init() {
@@ -29,7 +60,55 @@ func init() {
 }
 
 // Execute runs the given pipeline on Samza. Convenience wrapper over the
-// universal runner.
+// universal runner, which submits to the job server at --endpoint or to a
+// managed job server otherwise.
 func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
-	return universal.Execute(ctx, p)
+	opts, err := runnerOptions()
+	if err != nil {
+		return nil, err
+	}
+	cfg := universal.Config{RunnerOptions: opts}
+	if *jobopts.Endpoint != "" {
+		return universal.ExecuteWith(ctx, p, cfg)
+	}
+	return jobserver.Execute(ctx, p, jobserver.Options{
+		Target: "runners:samza:job-server",
+		Jar:    *jobServerJar,
+		Image:  *jobServerImage,
+	}, cfg)
+}
+
+// runnerOptions returns the pipeline options of the Samza runner set by the
+// flags.
+func runnerOptions() (map[string]interface{}, error) {
+	switch *executionEnvironment {
+	case "LOCAL":
+	case "STANDALONE", "YARN":
+		if jobopts.IsLoopback() {
+			return nil, errors.Errorf("%v Samza jobs cannot use the LOOPBACK environment. Use --environment_type=(DOCKER|PROCESS)", *executionEnvironment)
+		}
+	default:
+		return nil, errors.Errorf("invalid Samza execution environment %q. Use --samza_execution_environment=(LOCAL|STANDALONE|YARN)", *executionEnvironment)
+	}
+	if *maxSourceParallelism < 0 {
+		return nil, errors.Errorf("samza_max_source_parallelism (%d) cannot be negative", *maxSourceParallelism)
+	}
+
+	opts := map[string]interface{}{
+		"samza_execution_environment": *executionEnvironment,
+	}
+	if *configFile != "" {
+		opts["config_file_path"] = *configFile
+	}
+	if *configOverride != "" {
+		var override map[string]string
+		if err := json.Unmarshal([]byte(*configOverride), &override); err != nil {
+			return nil, errors.Wrapf(err, "error reading --samza_config_override flag as JSON")
+		}
+		opts["config_override"] = override
+	}
+	if *maxSourceParallelism > 0 {
+		opts["max_source_parallelism"] = *maxSourceParallelism
+	}
+	return opts, nil
 }
@@ -0,0 +1,57 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package samza
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
+)
+
+func TestRunnerOptions(t *testing.T) {
+	defer func(env, override string, parallelism int) {
+		*executionEnvironment, *configOverride, *maxSourceParallelism = env, override, parallelism
+	}(*executionEnvironment, *configOverride, *maxSourceParallelism)
+
+	*executionEnvironment = "YARN"
+	*configOverride = `{"job.name": "wordcount"}`
+	*maxSourceParallelism = 4
+	got, err := runnerOptions()
+	if err != nil {
+		t.Fatalf("runnerOptions failed: %v", err)
+	}
+	want := map[string]interface{}{
+		"samza_execution_environment": "YARN",
+		"config_override":             map[string]string{"job.name": "wordcount"},
+		"max_source_parallelism":      4,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("runnerOptions() = %v, want %v", got, want)
+	}
+
+	*executionEnvironment = "MESOS"
+	if _, err := runnerOptions(); err == nil {
+		t.Error("runnerOptions() with an invalid execution environment succeeded, want error")
+	}
+
+	defer func(env string) { *jobopts.EnvironmentType = env }(*jobopts.EnvironmentType)
+	*jobopts.EnvironmentType = "LOOPBACK"
+	*executionEnvironment = "STANDALONE"
+	if _, err := runnerOptions(); err == nil {
+		t.Error("runnerOptions() of a STANDALONE LOOPBACK job succeeded, want error")
+	}
+}
@@ -14,13 +14,37 @@
 // limitations under the License.
 
 // Package spark contains the Spark runner.
+//
+// Without --endpoint, the runner manages a job server itself: it downloads
+// the Spark job server jar matching the SDK version and --spark_version,
+// starts it locally, submits the pipeline to it and stops it once Execute
+// returns. The job server runs pipelines on the Spark cluster at
+// --spark_master_url in client deploy mode, with the driver in the job
+// server, and stages the artifacts of jobs in --spark_artifacts_dir, which
+// must be readable by the executors of the cluster, such as an HDFS path.
+//
+//	go run ./main.go --runner=spark --spark_master_url=spark://host:7077 \
+//		--spark_artifacts_dir=hdfs:///tmp/beam-artifacts
 package spark
 
 import (
 	"context"
+	"flag"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
 	"github.com/apache/beam/sdks/go/pkg/beam/runners/universal"
+	"github.com/apache/beam/sdks/go/pkg/beam/runners/universal/jobserver"
+)
+
+var (
+	sparkMasterURL = flag.String("spark_master_url", "local[4]", "URL of the Spark master of the managed job server, or local[N] for an embedded cluster (optional).")
+	sparkVersion   = flag.String("spark_version", "3", "Major Spark version of the managed job server, 2 or 3 (optional).")
+	artifactsDir   = flag.String("spark_artifacts_dir", "", "Directory the managed job server stages job artifacts in, readable by the Spark executors (optional).")
+	jobServerJar   = flag.String("spark_job_server_jar", "", "Path or URL of the jar of the managed job server, instead of the released jar (optional).")
+	jobServerImage = flag.String("spark_job_server_image", "", "Docker image of the managed job server, which is run as a container instead of a jar (optional).")
 )
 
 func init() {
@@ -29,7 +53,26 @@ func init() {
 }
 
 // Execute runs the given pipeline on Spark. Convenience wrapper over the
-// universal runner.
+// universal runner, which submits to the job server at --endpoint or to a
+// managed job server otherwise.
 func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
-	return universal.Execute(ctx, p)
+	if *jobopts.Endpoint != "" {
+		return universal.Execute(ctx, p)
+	}
+	if *sparkVersion != "2" && *sparkVersion != "3" {
+		return nil, errors.Errorf("invalid Spark version %q. Use --spark_version=(2|3)", *sparkVersion)
+	}
+	if *jobopts.Async {
+		log.Warn(ctx, "The Spark driver of the job runs in the managed job server, so the job stops with the job server once Execute returns.")
+	}
+	args := []string{"--spark-master-url=" + *sparkMasterURL}
+	if *artifactsDir != "" {
+		args = append(args, "--artifacts-dir="+*artifactsDir)
+	}
+	return jobserver.Execute(ctx, p, jobserver.Options{
+		Target: "runners:spark:" + *sparkVersion + ":job-server",
+		Jar:    *jobServerJar,
+		Image:  *jobServerImage,
+		Args:   args,
+	}, universal.Config{})
 }
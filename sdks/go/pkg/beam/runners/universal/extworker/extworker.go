@@ -21,12 +21,16 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/harness"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
 	"github.com/apache/beam/sdks/go/pkg/beam/util/grpcx"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // StartLoopback initializes a Loopback ExternalWorkerService, at the given port.
@@ -39,7 +43,7 @@ func StartLoopback(ctx context.Context, port int) (*Loopback, error) {
 	log.Infof(ctx, "starting Loopback server at %v", lis.Addr())
 	grpcServer := grpc.NewServer()
 	root, cancel := context.WithCancel(ctx)
-	s := &Loopback{lis: lis, root: root, rootCancel: cancel, workers: map[string]context.CancelFunc{},
+	s := &Loopback{lis: lis, root: root, rootCancel: cancel, workers: map[string]*worker{},
 		grpcServer: grpcServer}
 	fnpb.RegisterBeamFnExternalWorkerPoolServer(grpcServer, s)
 	go grpcServer.Serve(lis)
@@ -53,7 +57,7 @@ type Loopback struct {
 	rootCancel context.CancelFunc
 
 	mu      sync.Mutex
-	workers map[string]context.CancelFunc
+	workers map[string]*worker
 
 	grpcServer *grpc.Server
 }
@@ -78,20 +82,43 @@ func (s *Loopback) StartWorker(ctx context.Context, req *fnpb.StartWorkerRequest
 		return &fnpb.StartWorkerResponse{Error: "[BEAM-10610] Secure endpoints not supported."}, nil
 	}
 
-	ctx = grpcx.WriteWorkerID(s.root, req.GetWorkerId())
-	ctx, s.workers[req.GetWorkerId()] = context.WithCancel(ctx)
+	// Each worker has its own connections to the runner, so several workers
+	// can run concurrently for runners that request parallel workers.
+	ctx, cancel := context.WithCancel(grpcx.WriteWorkerID(s.root, req.GetWorkerId()))
+	w := &worker{cancel: cancel}
+	s.workers[req.GetWorkerId()] = w
 
-	go harness.Main(ctx, req.GetLoggingEndpoint().GetUrl(), req.GetControlEndpoint().GetUrl())
+	go s.run(ctx, req.GetWorkerId(), w, req.GetLoggingEndpoint().GetUrl(), req.GetControlEndpoint().GetUrl())
 	return &fnpb.StartWorkerResponse{}, nil
 }
 
+// worker is a worker harness running in the Loopback process.
+type worker struct {
+	cancel context.CancelFunc
+}
+
+// run runs the worker harness until it exits, and then drops the worker, so
+// that the runner can start a worker with the same ID again.
+func (s *Loopback) run(ctx context.Context, id string, w *worker, loggingEndpoint, controlEndpoint string) {
+	if err := harness.Main(ctx, loggingEndpoint, controlEndpoint); err != nil && ctx.Err() == nil {
+		log.Errorf(s.root, "worker %v failed: %v", id, err)
+	}
+	w.cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.workers[id] == w {
+		delete(s.workers, id)
+	}
+}
+
 // StopWorker terminates a worker harness, implementing BeamFnExternalWorkerPoolServer.StopWorker.
 func (s *Loopback) StopWorker(ctx context.Context, req *fnpb.StopWorkerRequest) (*fnpb.StopWorkerResponse, error) {
 	log.Infof(ctx, "stopping worker %v", req.GetWorkerId())
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if cancelfn, ok := s.workers[req.GetWorkerId()]; ok {
-		cancelfn()
+	if w, ok := s.workers[req.GetWorkerId()]; ok {
+		w.cancel()
 		delete(s.workers, req.GetWorkerId())
 		return &fnpb.StopWorkerResponse{}, nil
 	}
@@ -107,7 +134,10 @@ func (s *Loopback) Stop(ctx context.Context) error {
 	defer s.mu.Unlock()
 
 	log.Infof(ctx, "stopping Loopback, and %d workers", len(s.workers))
-	s.workers = map[string]context.CancelFunc{}
+	for _, w := range s.workers {
+		w.cancel()
+	}
+	s.workers = map[string]*worker{}
 	s.lis.Close()
 	s.rootCancel()
 	s.grpcServer.GracefulStop()
@@ -118,3 +148,32 @@ func (s *Loopback) Stop(ctx context.Context) error {
 func (s *Loopback) EnvironmentConfig(context.Context) string {
 	return fmt.Sprintf("localhost:%d", s.lis.Addr().(*net.TCPAddr).Port)
 }
+
+// Probe checks that a worker pool serves the ExternalWorkerService at the
+// given endpoint, waiting up to the given timeout for it to come up. It is
+// meant to check pre-provisioned worker pools before submitting jobs that
+// use them.
+func Probe(ctx context.Context, endpoint string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := grpcx.Dial(ctx, endpoint, timeout)
+	if err != nil {
+		return errors.Wrapf(err, "worker pool at %v is unreachable", endpoint)
+	}
+	defer conn.Close()
+
+	// Stopping a worker that doesn't exist has no effect on the pool, but
+	// fails if the endpoint isn't a worker pool. The error in the response
+	// doesn't matter.
+	client := fnpb.NewBeamFnExternalWorkerPoolClient(conn)
+	if _, err := client.StopWorker(ctx, &fnpb.StopWorkerRequest{WorkerId: probeWorkerID}); err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return errors.Errorf("endpoint %v is not a worker pool", endpoint)
+		}
+		return errors.Wrapf(err, "worker pool at %v is unhealthy", endpoint)
+	}
+	return nil
+}
+
+const probeWorkerID = "beam-go-probe"
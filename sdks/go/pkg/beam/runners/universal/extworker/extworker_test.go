@@ -17,10 +17,13 @@ package extworker
 
 import (
 	"context"
+	"net"
 	"testing"
+	"time"
 
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
 	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"google.golang.org/grpc"
 )
 
 func TestLoopback(t *testing.T) {
@@ -139,3 +142,38 @@ func TestLoopback(t *testing.T) {
 		t.Fatalf("error stopping server: err: %v", err)
 	}
 }
+
+func TestProbe(t *testing.T) {
+	ctx := context.Background()
+	server, err := StartLoopback(ctx, 0)
+	if err != nil {
+		t.Fatalf("Unable to start server: %v", err)
+	}
+	defer server.Stop(ctx)
+
+	if err := Probe(ctx, server.EnvironmentConfig(ctx), 10*time.Second); err != nil {
+		t.Errorf("Probe(worker pool) failed: %v", err)
+	}
+
+	// A gRPC server that isn't a worker pool.
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	other := grpc.NewServer()
+	go other.Serve(lis)
+	defer other.Stop()
+	if err := Probe(ctx, lis.Addr().String(), 10*time.Second); err == nil {
+		t.Error("Probe(other server) succeeded, want error")
+	}
+
+	// Nothing listens on the closed listener's port.
+	lis, err = net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	lis.Close()
+	if err := Probe(ctx, lis.Addr().String(), 100*time.Millisecond); err == nil {
+		t.Error("Probe(unreachable) succeeded, want error")
+	}
+}
@@ -0,0 +1,275 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jobserver downloads and runs the job servers of portable Beam
+// runners, such as Flink and Spark, so that pipelines can be submitted to
+// them without setting up a job server by hand.
+package jobserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/go/pkg/beam/runners/universal"
+)
+
+const mavenRepository = "https://repo.maven.apache.org/maven2"
+
+// cacheDir is the directory jars are downloaded into.
+var cacheDir = filepath.Join(os.Getenv("HOME"), ".apache_beam", "cache", "jars")
+
+// startTimeout is how long a job server can take to start serving.
+var startTimeout = 2 * time.Minute
+
+// JarURL returns the Maven Central URL of the jar built by the given Gradle
+// target of Beam, such as "runners:flink:1.13:job-server", at the version of
+// the SDK. Jars are only released for released versions of the SDK, so
+// development versions must use locally built jars instead.
+func JarURL(target string) (string, error) {
+	return jarURL(target, core.SdkVersion)
+}
+
+func jarURL(target, version string) (string, error) {
+	if strings.Contains(version, "dev") || strings.Contains(version, "SNAPSHOT") {
+		return "", errors.Errorf("no released jar of %v for development version %v of the SDK; build it with ./gradlew :%v:shadowJar and use the built jar", target, version, target)
+	}
+	artifact := "beam-" + strings.ReplaceAll(target, ":", "-")
+	return fmt.Sprintf("%v/org/apache/beam/%v/%v/%v-%v.jar", mavenRepository, artifact, version, artifact, version), nil
+}
+
+// Download returns the path of a local copy of the jar at the given URL,
+// downloading it into the local cache of jars unless it is cached already.
+// Local paths are returned as is.
+func Download(ctx context.Context, url string) (string, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		if _, err := os.Stat(url); err != nil {
+			return "", errors.Wrapf(err, "jar %v not found", url)
+		}
+		return url, nil
+	}
+	filename := filepath.Join(cacheDir, url[strings.LastIndex(url, "/")+1:])
+	if _, err := os.Stat(filename); err == nil {
+		return filename, nil
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", errors.Wrap(err, "failed to create jar cache")
+	}
+
+	log.Infof(ctx, "Downloading %v to %v", url, filename)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download %v", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to download %v: %v", url, resp.Status)
+	}
+
+	// Download to a temporary file first, so that interrupted downloads are
+	// not mistaken for cached jars.
+	tmp, err := ioutil.TempFile(cacheDir, "download-*")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create jar file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return "", errors.Wrapf(err, "failed to download %v", url)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to write jar file")
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return "", errors.Wrap(err, "failed to write jar file")
+	}
+	return filename, nil
+}
+
+// Options configure a job server.
+type Options struct {
+	// Target is the Gradle target of the released job server jar, such as
+	// "runners:flink:1.13:job-server".
+	Target string
+	// Jar is the path or URL of a job server jar used instead of the
+	// released jar.
+	Jar string
+	// Image is the Docker image of a job server run as a container instead
+	// of a jar.
+	Image string
+	// Args are additional arguments of the job server.
+	Args []string
+}
+
+// Start starts the job server configured by the options. The jar of the job
+// server is downloaded unless cached.
+func Start(ctx context.Context, opts Options) (*Server, error) {
+	if opts.Image != "" {
+		return StartContainer(ctx, opts.Image, opts.Args...)
+	}
+	url := opts.Jar
+	if url == "" {
+		var err error
+		if url, err = JarURL(opts.Target); err != nil {
+			return nil, err
+		}
+	}
+	jar, err := Download(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return StartJar(ctx, jar, opts.Args...)
+}
+
+// Execute executes the pipeline on the universal runner configured by cfg,
+// submitting it to a job server started with the given options unless the
+// pipeline is only validated. The job server is stopped once Execute returns.
+func Execute(ctx context.Context, p *beam.Pipeline, opts Options, cfg universal.Config) (beam.PipelineResult, error) {
+	var srv *Server
+	defer func() {
+		if srv != nil {
+			srv.Stop(ctx)
+		}
+	}()
+	cfg.Endpoint = func() (string, error) {
+		var err error
+		if srv, err = Start(ctx, opts); err != nil {
+			return "", errors.WithContextf(err, "starting job server of %v", opts.Target)
+		}
+		return srv.Endpoint, nil
+	}
+	return universal.ExecuteWith(ctx, p, cfg)
+}
+
+// Server is a running job server.
+type Server struct {
+	// Endpoint is the endpoint of the job service.
+	Endpoint string
+
+	cmd       *exec.Cmd
+	done      chan error
+	container string
+}
+
+// StartJar starts the job server of the given jar with the given additional
+// arguments, and waits until it serves jobs. The job server serves the job
+// service on a free local port, and its other services on arbitrary ports.
+func StartJar(ctx context.Context, jar string, args ...string) (*Server, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs := append([]string{"-jar", jar}, serverArgs(port)...)
+	return start(ctx, exec.Command("java", append(cmdArgs, args...)...), port)
+}
+
+// StartContainer starts the job server of the given Docker image with the
+// given additional arguments, and waits until it serves jobs. The container
+// uses the network of the host, which is only supported by Docker on Linux.
+func StartContainer(ctx context.Context, image string, args ...string) (*Server, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("beam-job-server-%d", port)
+	cmdArgs := append([]string{"run", "--rm", "--network=host", "--name=" + name, image}, serverArgs(port)...)
+	s, err := start(ctx, exec.Command("docker", append(cmdArgs, args...)...), port)
+	if err != nil {
+		return nil, err
+	}
+	s.container = name
+	return s, nil
+}
+
+func serverArgs(port int) []string {
+	return []string{fmt.Sprintf("--job-port=%d", port), "--artifact-port=0", "--expansion-port=0"}
+}
+
+func start(ctx context.Context, cmd *exec.Cmd, port int) (*Server, error) {
+	log.Infof(ctx, "Starting job server: %v", strings.Join(cmd.Args, " "))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "failed to start job server %v", cmd.Path)
+	}
+	s := &Server{Endpoint: fmt.Sprintf("localhost:%d", port), cmd: cmd, done: make(chan error, 1)}
+	go func() {
+		s.done <- cmd.Wait()
+	}()
+
+	deadline := time.Now().Add(startTimeout)
+	for {
+		conn, err := net.DialTimeout("tcp", s.Endpoint, time.Second)
+		if err == nil {
+			conn.Close()
+			log.Infof(ctx, "Job server serving at %v", s.Endpoint)
+			return s, nil
+		}
+		if time.Now().After(deadline) {
+			s.Stop(ctx)
+			return nil, errors.Errorf("job server did not start serving at %v within %v", s.Endpoint, startTimeout)
+		}
+		select {
+		case err := <-s.done:
+			return nil, errors.Errorf("job server exited before serving: %v", err)
+		case <-ctx.Done():
+			s.Stop(ctx)
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Stop stops the job server and waits for it to exit.
+func (s *Server) Stop(ctx context.Context) {
+	log.Infof(ctx, "Stopping job server at %v", s.Endpoint)
+	if s.container != "" {
+		// Killing the Docker client does not stop the container.
+		if out, err := exec.Command("docker", "kill", s.container).CombinedOutput(); err != nil {
+			log.Warnf(ctx, "Failed to stop job server container %v: %v\n%s", s.container, err, out)
+		}
+	}
+	if err := s.cmd.Process.Kill(); err != nil {
+		log.Warnf(ctx, "Failed to stop job server: %v", err)
+		return
+	}
+	<-s.done
+}
+
+// freePort returns a local port that is free at the time of the call.
+func freePort() (int, error) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to find a free port")
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,83 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobserver
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+)
+
+func TestJarURL(t *testing.T) {
+	got, err := jarURL("runners:flink:1.13:job-server", "2.32.0")
+	if err != nil {
+		t.Fatalf("jarURL failed: %v", err)
+	}
+	want := "https://repo.maven.apache.org/maven2/org/apache/beam/beam-runners-flink-1.13-job-server/2.32.0/beam-runners-flink-1.13-job-server-2.32.0.jar"
+	if got != want {
+		t.Errorf("jarURL = %v, want %v", got, want)
+	}
+	if _, err := jarURL("runners:flink:1.13:job-server", "2.33.0.dev"); err == nil {
+		t.Error("jarURL of a development version succeeded, want error")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	defer func(dir string) { cacheDir = dir }(cacheDir)
+	cacheDir = t.TempDir()
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/job-server.jar" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "jar")
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		filename, err := Download(ctx, server.URL+"/job-server.jar")
+		if err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+		if b, err := ioutil.ReadFile(filename); err != nil || string(b) != "jar" {
+			t.Errorf("downloaded jar = %q, %v, want \"jar\"", b, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("jar downloaded %v times, want once and cached", requests)
+	}
+	if _, err := Download(ctx, server.URL+"/missing.jar"); err == nil {
+		t.Error("Download of a missing jar succeeded, want error")
+	}
+}
+
+func TestStart_Exits(t *testing.T) {
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := start(context.Background(), exec.Command("sh", "-c", "exit 1"), port); err == nil {
+		t.Error("start of an exiting job server succeeded, want error")
+	}
+}
@@ -0,0 +1,169 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+)
+
+const urnTestStream = "beam:transform:teststream:v1"
+
+// Job services declare their capabilities by describing pipeline options with
+// these names, whose default values are comma-separated lists of the URNs of
+// the primitive transforms and the pipeline requirements they support. The
+// group of the options names the runner.
+const (
+	supportedTransformsOption   = "beam_supported_transforms"
+	supportedRequirementsOption = "beam_supported_requirements"
+)
+
+// Capabilities describe the known limitations of a runner.
+type Capabilities struct {
+	// Runner is the name of the runner.
+	Runner string
+	// SupportedTransforms are the URNs of the primitive transforms the
+	// runner executes, if it declares them. Other transforms are
+	// unsupported.
+	SupportedTransforms map[string]bool
+	// SupportedRequirements are the pipeline requirements the runner
+	// supports, if it declares them. Other requirements are unsupported.
+	SupportedRequirements map[string]bool
+	// UnsupportedTransforms are the URNs of the primitive transforms the
+	// runner does not execute.
+	UnsupportedTransforms map[string]bool
+	// UnsupportedRequirements are the pipeline requirements the runner does
+	// not support.
+	UnsupportedRequirements map[string]bool
+}
+
+// supportsTransform returns whether the runner executes the primitive
+// transform with the URN.
+func (c *Capabilities) supportsTransform(urn string) bool {
+	if c.SupportedTransforms != nil && !c.SupportedTransforms[urn] {
+		return false
+	}
+	return !c.UnsupportedTransforms[urn]
+}
+
+// supportsRequirement returns whether the runner supports the requirement.
+func (c *Capabilities) supportsRequirement(req string) bool {
+	if c.SupportedRequirements != nil && !c.SupportedRequirements[req] {
+		return false
+	}
+	return !c.UnsupportedRequirements[req]
+}
+
+// runnerCapabilities are the known limitations of runners that do not
+// declare their capabilities, keyed by the group of their pipeline options.
+var runnerCapabilities = map[string]*Capabilities{
+	"SparkPipelineOptions": {
+		Runner:                "Spark",
+		UnsupportedTransforms: map[string]bool{urnTestStream: true},
+	},
+	"SamzaPipelineOptions": {
+		Runner:                "Samza",
+		UnsupportedTransforms: map[string]bool{urnTestStream: true},
+	},
+}
+
+// DetectCapabilities returns the capabilities the job service declares with
+// its pipeline options. If it declares none, it identifies the runner by the
+// groups of the options, and returns its known limitations. It returns nil
+// for unknown runners, whose capabilities are not checked.
+func DetectCapabilities(ctx context.Context, client jobpb.JobServiceClient) *Capabilities {
+	resp, err := client.DescribePipelineOptions(ctx, &jobpb.DescribePipelineOptionsRequest{})
+	if err != nil {
+		log.Debugf(ctx, "Failed to describe the pipeline options of the runner, not checking its capabilities: %v", err)
+		return nil
+	}
+	if caps := declaredCapabilities(resp.GetOptions()); caps != nil {
+		return caps
+	}
+	for _, opt := range resp.GetOptions() {
+		if caps, ok := runnerCapabilities[opt.GetGroup()]; ok {
+			return caps
+		}
+	}
+	return nil
+}
+
+// declaredCapabilities returns the capabilities declared by the options, or
+// nil if none are.
+func declaredCapabilities(opts []*jobpb.PipelineOptionDescriptor) *Capabilities {
+	var caps *Capabilities
+	for _, opt := range opts {
+		name := opt.GetName()
+		if name != supportedTransformsOption && name != supportedRequirementsOption {
+			continue
+		}
+		if caps == nil {
+			caps = &Capabilities{Runner: strings.TrimSuffix(opt.GetGroup(), "PipelineOptions")}
+			if caps.Runner == "" {
+				caps.Runner = "portable"
+			}
+		}
+		urns := make(map[string]bool)
+		for _, urn := range strings.Split(opt.GetDefaultValue(), ",") {
+			if urn = strings.TrimSpace(urn); urn != "" {
+				urns[urn] = true
+			}
+		}
+		if name == supportedTransformsOption {
+			caps.SupportedTransforms = urns
+		} else {
+			caps.SupportedRequirements = urns
+		}
+	}
+	return caps
+}
+
+// CheckCapabilities checks that the runner with the given capabilities
+// supports the pipeline. Composite transforms are always supported, as
+// runners execute the subtransforms of composites they do not know, so only
+// the primitive transforms are checked. The error lists all unsupported
+// transforms and requirements. The pipeline is not adapted to avoid them.
+func CheckCapabilities(p *pipepb.Pipeline, caps *Capabilities) error {
+	if caps == nil {
+		return nil
+	}
+	var unsupported []string
+	for _, t := range p.GetComponents().GetTransforms() {
+		if len(t.GetSubtransforms()) > 0 {
+			continue
+		}
+		if urn := t.GetSpec().GetUrn(); urn != "" && !caps.supportsTransform(urn) {
+			unsupported = append(unsupported, fmt.Sprintf("transform %q (%v)", t.GetUniqueName(), urn))
+		}
+	}
+	for _, req := range p.GetRequirements() {
+		if !caps.supportsRequirement(req) {
+			unsupported = append(unsupported, fmt.Sprintf("requirement %v", req))
+		}
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+	sort.Strings(unsupported)
+	return errors.Errorf("the %v runner does not support the pipeline: unsupported %v", caps.Runner, strings.Join(unsupported, ", "))
+}
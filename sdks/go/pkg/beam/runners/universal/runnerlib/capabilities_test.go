@@ -0,0 +1,123 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"google.golang.org/grpc"
+)
+
+// fakeOptionsService describes pipeline options of the given group, and the
+// given declared options.
+type fakeOptionsService struct {
+	jobpb.JobServiceClient
+	group    string
+	declared map[string]string
+}
+
+func (f *fakeOptionsService) DescribePipelineOptions(ctx context.Context, req *jobpb.DescribePipelineOptionsRequest, opts ...grpc.CallOption) (*jobpb.DescribePipelineOptionsResponse, error) {
+	resp := &jobpb.DescribePipelineOptionsResponse{
+		Options: []*jobpb.PipelineOptionDescriptor{{Name: "option", Group: f.group}},
+	}
+	for name, value := range f.declared {
+		resp.Options = append(resp.Options, &jobpb.PipelineOptionDescriptor{Name: name, DefaultValue: value, Group: f.group})
+	}
+	return resp, nil
+}
+
+func TestCheckCapabilities(t *testing.T) {
+	p := &pipepb.Pipeline{
+		Components: &pipepb.Components{
+			Transforms: map[string]*pipepb.PTransform{
+				"c": {UniqueName: "Composite", Spec: &pipepb.FunctionSpec{Urn: urnTestStream}, Subtransforms: []string{"t"}},
+				"t": {UniqueName: "Composite/TestStream", Spec: &pipepb.FunctionSpec{Urn: urnTestStream}},
+				"i": {UniqueName: "Impulse", Spec: &pipepb.FunctionSpec{Urn: "beam:transform:impulse:v1"}},
+			},
+		},
+	}
+	ctx := context.Background()
+
+	for _, group := range []string{"UnknownPipelineOptions"} {
+		if err := CheckCapabilities(p, DetectCapabilities(ctx, &fakeOptionsService{group: group})); err != nil {
+			t.Errorf("CheckCapabilities() for %v failed: %v", group, err)
+		}
+	}
+
+	err := CheckCapabilities(p, DetectCapabilities(ctx, &fakeOptionsService{group: "SparkPipelineOptions"}))
+	if err == nil {
+		t.Fatal("CheckCapabilities() for Spark succeeded, want error for the TestStream")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "Spark") || !strings.Contains(msg, `"Composite/TestStream"`) || strings.Contains(msg, `"Composite" `) {
+		t.Errorf("CheckCapabilities() error = %v, want the Spark runner and only the primitive TestStream", msg)
+	}
+}
+
+func TestCheckCapabilities_Declared(t *testing.T) {
+	p := &pipepb.Pipeline{
+		Components: &pipepb.Components{
+			Transforms: map[string]*pipepb.PTransform{
+				"c": {UniqueName: "Composite", Spec: &pipepb.FunctionSpec{Urn: "beam:transform:combine_per_key:v1"}, Subtransforms: []string{"g"}},
+				"g": {UniqueName: "Composite/GBK", Spec: &pipepb.FunctionSpec{Urn: "beam:transform:group_by_key:v1"}},
+				"i": {UniqueName: "Impulse", Spec: &pipepb.FunctionSpec{Urn: "beam:transform:impulse:v1"}},
+			},
+		},
+		Requirements: []string{"beam:requirement:pardo:splittable_dofn:v1"},
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		declared map[string]string
+		want     []string // Substrings of the error, if any.
+	}{
+		{
+			declared: map[string]string{
+				supportedTransformsOption:   "beam:transform:impulse:v1, beam:transform:group_by_key:v1",
+				supportedRequirementsOption: "beam:requirement:pardo:splittable_dofn:v1",
+			},
+		},
+		{
+			declared: map[string]string{supportedTransformsOption: "beam:transform:impulse:v1"},
+			want:     []string{"the Test runner", `transform "Composite/GBK" (beam:transform:group_by_key:v1)`},
+		},
+		{
+			declared: map[string]string{supportedRequirementsOption: ""},
+			want:     []string{"requirement beam:requirement:pardo:splittable_dofn:v1"},
+		},
+	}
+	for _, test := range tests {
+		err := CheckCapabilities(p, DetectCapabilities(ctx, &fakeOptionsService{group: "TestPipelineOptions", declared: test.declared}))
+		if len(test.want) == 0 {
+			if err != nil {
+				t.Errorf("CheckCapabilities() with %v failed: %v", test.declared, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("CheckCapabilities() with %v succeeded, want error", test.declared)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("CheckCapabilities() with %v = %v, want error containing %v", test.declared, err, want)
+			}
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/metricsx"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
@@ -27,6 +28,7 @@ import (
 	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
 	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
 	"github.com/apache/beam/sdks/go/pkg/beam/util/grpcx"
+	"github.com/golang/protobuf/proto"
 )
 
 // Execute executes a pipeline on the universal runner serving the given endpoint.
@@ -42,6 +44,10 @@ func Execute(ctx context.Context, p *pipepb.Pipeline, endpoint string, opt *JobO
 	defer cc.Close()
 	client := jobpb.NewJobServiceClient(cc)
 
+	if err := CheckCapabilities(p, DetectCapabilities(ctx, client)); err != nil {
+		return presult, err
+	}
+
 	prepID, artifactEndpoint, st, err := Prepare(ctx, client, p, opt)
 	if err != nil {
 		return presult, err
@@ -92,9 +98,9 @@ func Execute(ctx context.Context, p *pipepb.Pipeline, endpoint string, opt *JobO
 	if async {
 		return presult, nil
 	}
-	err = WaitForCompletion(ctx, client, jobID)
+	err = WaitForCompletion(ctx, client, jobID, opt.MessageLevel)
 
-	res, presultErr := newUniversalPipelineResult(ctx, jobID, client)
+	res, presultErr := newUniversalPipelineResult(ctx, jobID, client, p)
 	if presultErr != nil {
 		if err != nil {
 			return presult, errors.Wrap(err, presultErr.Error())
@@ -109,7 +115,13 @@ type universalPipelineResult struct {
 	metrics *metrics.Results
 }
 
-func newUniversalPipelineResult(ctx context.Context, jobID string, client jobpb.JobServiceClient) (*universalPipelineResult, error) {
+// ValidatedPipelineResult returns the result of a pipeline that was only
+// validated, which has no job ID and no metrics.
+func ValidatedPipelineResult() beam.PipelineResult {
+	return &universalPipelineResult{}
+}
+
+func newUniversalPipelineResult(ctx context.Context, jobID string, client jobpb.JobServiceClient, p *pipepb.Pipeline) (*universalPipelineResult, error) {
 	request := &jobpb.GetJobMetricsRequest{JobId: jobID}
 	response, err := client.GetJobMetrics(ctx, request)
 	if err != nil {
@@ -117,11 +129,49 @@ func newUniversalPipelineResult(ctx context.Context, jobID string, client jobpb.
 	}
 
 	monitoredStates := response.GetMetrics()
-	metrics := metricsx.FromMonitoringInfos(monitoredStates.Attempted, monitoredStates.Committed)
+	names := stepNames(p)
+	metrics := metricsx.FromMonitoringInfos(withStepNames(monitoredStates.Attempted, names), withStepNames(monitoredStates.Committed, names))
 	return &universalPipelineResult{jobID, metrics}, nil
 }
 
+// stepNames returns the unique names of the transforms of the pipeline by
+// their id. The unique names of normalized pipelines are the paths of the
+// transforms within their composites, which name the steps of metrics.
+func stepNames(p *pipepb.Pipeline) map[string]string {
+	names := make(map[string]string)
+	for id, t := range p.GetComponents().GetTransforms() {
+		if name := t.GetUniqueName(); name != "" {
+			names[id] = name
+		}
+	}
+	return names
+}
+
+// withStepNames returns the monitoring infos with the transform ids of their
+// PTRANSFORM label replaced by the step names, so the metrics are keyed by
+// step as on the other runners. Ids of transforms not in the pipeline, such
+// as those the runner added, are kept.
+func withStepNames(infos []*pipepb.MonitoringInfo, names map[string]string) []*pipepb.MonitoringInfo {
+	out := make([]*pipepb.MonitoringInfo, 0, len(infos))
+	for _, info := range infos {
+		name, ok := names[info.GetLabels()["PTRANSFORM"]]
+		if !ok {
+			out = append(out, info)
+			continue
+		}
+		info = proto.Clone(info).(*pipepb.MonitoringInfo)
+		info.Labels["PTRANSFORM"] = name
+		out = append(out, info)
+	}
+	return out
+}
+
+// Metrics returns the metrics of the job once it completed, which are empty
+// if they couldn't be retrieved.
 func (pr universalPipelineResult) Metrics() metrics.Results {
+	if pr.metrics == nil {
+		return metrics.Results{}
+	}
 	return *pr.metrics
 }
 
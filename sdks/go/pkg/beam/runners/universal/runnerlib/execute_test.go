@@ -0,0 +1,58 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"testing"
+
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestWithStepNames(t *testing.T) {
+	p := &pipepb.Pipeline{
+		Components: &pipepb.Components{
+			Transforms: map[string]*pipepb.PTransform{
+				"s1": {UniqueName: "CountWords", Subtransforms: []string{"e2"}},
+				"e2": {UniqueName: "CountWords/main.extractFn"},
+			},
+		},
+	}
+	user := &pipepb.MonitoringInfo{
+		Urn:    "beam:metric:user:sum_int64:v1",
+		Labels: map[string]string{"PTRANSFORM": "e2", "NAMESPACE": "ns", "NAME": "count"},
+	}
+	runner := &pipepb.MonitoringInfo{
+		Urn:    "beam:metric:user:sum_int64:v1",
+		Labels: map[string]string{"PTRANSFORM": "fused1", "NAMESPACE": "ns", "NAME": "count"},
+	}
+	got := withStepNames([]*pipepb.MonitoringInfo{user, runner}, stepNames(p))
+
+	want := []*pipepb.MonitoringInfo{
+		{
+			Urn:    "beam:metric:user:sum_int64:v1",
+			Labels: map[string]string{"PTRANSFORM": "CountWords/main.extractFn", "NAMESPACE": "ns", "NAME": "count"},
+		},
+		runner,
+	}
+	if d := cmp.Diff(want, got, protocmp.Transform()); d != "" {
+		t.Errorf("withStepNames() diff (-want, +got):\n%v", d)
+	}
+	if got, want := user.GetLabels()["PTRANSFORM"], "e2"; got != want {
+		t.Errorf("withStepNames() modified the original labels: PTRANSFORM = %q, want %q", got, want)
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"strings"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
@@ -48,6 +50,14 @@ type JobOptions struct {
 	RetainDocker bool
 
 	Parallelism int
+
+	// MessageLevel is the minimum severity of the job messages printed
+	// while waiting for the job.
+	MessageLevel log.Severity
+
+	// RunnerOptions are additional pipeline options of the runner, keyed by
+	// their snake case names.
+	RunnerOptions map[string]interface{}
 }
 
 // Prepare prepares a job to the given job service. It returns the preparation id
@@ -66,6 +76,19 @@ func Prepare(ctx context.Context, client jobpb.JobServiceClient, p *pipepb.Pipel
 	if err != nil {
 		return "", "", "", errors.WithContext(err, "producing pipeline options")
 	}
+	if len(opt.RunnerOptions) > 0 {
+		extra := make(map[string]interface{})
+		for k, v := range opt.RunnerOptions {
+			extra[fmt.Sprintf("beam:option:%v:v1", k)] = v
+		}
+		runnerOptions, err := provision.OptionsToProto(extra)
+		if err != nil {
+			return "", "", "", errors.WithContext(err, "producing runner pipeline options")
+		}
+		for k, v := range runnerOptions.GetFields() {
+			options.Fields[k] = v
+		}
+	}
 	req := &jobpb.PrepareJobRequest{
 		Pipeline:        p,
 		PipelineOptions: options,
@@ -92,9 +115,14 @@ func Submit(ctx context.Context, client jobpb.JobServiceClient, id, token string
 	return resp.GetJobId(), nil
 }
 
-// WaitForCompletion monitors the given job until completion. It logs any messages
-// and state changes received.
-func WaitForCompletion(ctx context.Context, client jobpb.JobServiceClient, jobID string) error {
+// messageOut is where job messages are printed. They are printed directly
+// rather than logged, as the log messages of the launcher are sent to the
+// runner once it runs LOOPBACK workers.
+var messageOut io.Writer = os.Stdout
+
+// WaitForCompletion monitors the given job until completion. It prints the
+// state changes and the messages of at least the given severity received.
+func WaitForCompletion(ctx context.Context, client jobpb.JobServiceClient, jobID string, level log.Severity) error {
 	stream, err := client.GetMessageStream(ctx, &jobpb.JobMessagesRequest{JobId: jobID})
 	if err != nil {
 		return errors.Wrap(err, "failed to get job stream")
@@ -113,7 +141,7 @@ func WaitForCompletion(ctx context.Context, client jobpb.JobServiceClient, jobID
 		case msg.GetStateResponse() != nil:
 			resp := msg.GetStateResponse()
 
-			log.Infof(ctx, "Job state: %v", resp.GetState().String())
+			fmt.Fprintf(messageOut, "Job state: %v\n", resp.GetState().String())
 
 			switch resp.State {
 			case jobpb.JobState_DONE, jobpb.JobState_CANCELLED:
@@ -124,9 +152,10 @@ func WaitForCompletion(ctx context.Context, client jobpb.JobServiceClient, jobID
 
 		case msg.GetMessageResponse() != nil:
 			resp := msg.GetMessageResponse()
-
-			text := fmt.Sprintf("%v (%v): %v", resp.GetTime(), resp.GetMessageId(), resp.GetMessageText())
-			log.Output(ctx, messageSeverity(resp.GetImportance()), 1, text)
+			if messageSeverity(resp.GetImportance()) < level {
+				continue
+			}
+			fmt.Fprintf(messageOut, "%v %v (%v): %v\n", importanceName(resp.GetImportance()), resp.GetTime(), resp.GetMessageId(), resp.GetMessageText())
 
 		default:
 			return errors.Errorf("unexpected job update: %v", proto.MarshalTextString(msg))
@@ -134,6 +163,11 @@ func WaitForCompletion(ctx context.Context, client jobpb.JobServiceClient, jobID
 	}
 }
 
+// importanceName returns the short name of the importance, such as WARNING.
+func importanceName(importance jobpb.JobMessage_MessageImportance) string {
+	return strings.TrimPrefix(importance.String(), "JOB_MESSAGE_")
+}
+
 func messageSeverity(importance jobpb.JobMessage_MessageImportance) log.Severity {
 	switch importance {
 	case jobpb.JobMessage_JOB_MESSAGE_ERROR:
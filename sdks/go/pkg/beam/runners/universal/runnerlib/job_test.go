@@ -0,0 +1,95 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runnerlib
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
+	"google.golang.org/grpc"
+)
+
+// fakeJobService streams the given job messages.
+type fakeJobService struct {
+	jobpb.JobServiceClient
+	msgs []*jobpb.JobMessagesResponse
+}
+
+func (f *fakeJobService) GetMessageStream(ctx context.Context, req *jobpb.JobMessagesRequest, opts ...grpc.CallOption) (jobpb.JobService_GetMessageStreamClient, error) {
+	return &fakeMessageStream{msgs: f.msgs}, nil
+}
+
+type fakeMessageStream struct {
+	grpc.ClientStream
+	msgs []*jobpb.JobMessagesResponse
+}
+
+func (f *fakeMessageStream) Recv() (*jobpb.JobMessagesResponse, error) {
+	if len(f.msgs) == 0 {
+		return nil, io.EOF
+	}
+	msg := f.msgs[0]
+	f.msgs = f.msgs[1:]
+	return msg, nil
+}
+
+func message(importance jobpb.JobMessage_MessageImportance, text string) *jobpb.JobMessagesResponse {
+	return &jobpb.JobMessagesResponse{
+		Response: &jobpb.JobMessagesResponse_MessageResponse{
+			MessageResponse: &jobpb.JobMessage{Importance: importance, MessageText: text},
+		},
+	}
+}
+
+func state(s jobpb.JobState_Enum) *jobpb.JobMessagesResponse {
+	return &jobpb.JobMessagesResponse{
+		Response: &jobpb.JobMessagesResponse_StateResponse{
+			StateResponse: &jobpb.JobStateEvent{State: s},
+		},
+	}
+}
+
+func TestWaitForCompletion(t *testing.T) {
+	var out bytes.Buffer
+	defer func(w io.Writer) { messageOut = w }(messageOut)
+	messageOut = &out
+
+	client := &fakeJobService{msgs: []*jobpb.JobMessagesResponse{
+		state(jobpb.JobState_RUNNING),
+		message(jobpb.JobMessage_JOB_MESSAGE_DEBUG, "debug details"),
+		message(jobpb.JobMessage_JOB_MESSAGE_WARNING, "slow stage"),
+		message(jobpb.JobMessage_JOB_MESSAGE_ERROR, "user code failed"),
+		state(jobpb.JobState_FAILED),
+	}}
+	if err := WaitForCompletion(context.Background(), client, "job", log.SevWarn); err == nil {
+		t.Error("WaitForCompletion of a failed job succeeded, want error")
+	}
+
+	got := out.String()
+	for _, want := range []string{"Job state: RUNNING", "WARNING", "slow stage", "ERROR", "user code failed", "Job state: FAILED"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("printed messages = %q, want %q", got, want)
+		}
+	}
+	if strings.Contains(got, "debug details") {
+		t.Errorf("printed messages = %q, want no messages below WARN", got)
+	}
+}
@@ -33,6 +33,9 @@ import (
 
 // Stage stages the worker binary and any additional files to the given
 // artifact staging endpoint. It returns the retrieval token if successful.
+// The additional files are the file dependencies of the environments of the
+// pipeline, such as those of cross-language transforms, which the job service
+// requests. Only the worker binary is staged via the legacy API.
 func Stage(ctx context.Context, id, endpoint, binary, st string) (retrievalToken string, err error) {
 	ctx = grpcx.WriteWorkerID(ctx, id)
 	cc, err := grpcx.Dial(ctx, endpoint, 2*time.Minute)
@@ -41,11 +44,18 @@ func Stage(ctx context.Context, id, endpoint, binary, st string) (retrievalToken
 	}
 	defer cc.Close()
 
-	if err := StageViaPortableApi(ctx, cc, binary, st); err == nil {
+	portableErr := StageViaPortableApi(ctx, cc, binary, st)
+	if portableErr == nil {
 		return "", nil
 	}
 
-	return StageViaLegacyApi(ctx, cc, binary, st)
+	token, err := StageViaLegacyApi(ctx, cc, binary, st)
+	if err != nil {
+		// Either API may be the one the job service supports, so both errors
+		// are reported.
+		return "", errors.WithContextf(err, "staging via legacy API after staging via portable API failed: %v", portableErr)
+	}
+	return token, nil
 }
 
 func StageViaPortableApi(ctx context.Context, cc *grpc.ClientConn, binary, st string) error {
@@ -23,12 +23,14 @@ import (
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/harness"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
 
 	// Importing to get the side effect of the remote execution hook. See init().
 	_ "github.com/apache/beam/sdks/go/pkg/beam/core/runtime/harness/init"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
 	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
 	"github.com/apache/beam/sdks/go/pkg/beam/runners/universal/extworker"
 	"github.com/apache/beam/sdks/go/pkg/beam/runners/universal/runnerlib"
@@ -45,6 +47,23 @@ func init() {
 
 // Execute executes the pipeline on a universal beam runner.
 func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error) {
+	return ExecuteWith(ctx, p, Config{})
+}
+
+// Config configures runners built on the universal runner.
+type Config struct {
+	// Endpoint returns the job service endpoint, for runners that manage
+	// their own job services. It is not called to validate pipelines, and
+	// defaults to --endpoint.
+	Endpoint func() (string, error)
+	// RunnerOptions are additional pipeline options of the runner, keyed by
+	// their snake case names, such as "config_file_path".
+	RunnerOptions map[string]interface{}
+}
+
+// ExecuteWith executes the pipeline on a universal beam runner configured
+// by the given config.
+func ExecuteWith(ctx context.Context, p *beam.Pipeline, cfg Config) (beam.PipelineResult, error) {
 	if !beam.Initialized() {
 		panic(fmt.Sprint("Beam has not been initialized. Call beam.Init() before pipeline construction."))
 	}
@@ -57,19 +76,24 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 		log.Info(ctx, "Strict mode validation passed.")
 	}
 
-	endpoint, err := jobopts.GetEndpoint()
+	edges, _, err := p.Build()
 	if err != nil {
 		return nil, err
 	}
-
-	edges, _, err := p.Build()
-	if err != nil {
+	if err := graphx.CheckStateless(edges); err != nil {
 		return nil, err
 	}
 	envUrn := jobopts.GetEnvironmentUrn(ctx)
 	getEnvCfg := jobopts.GetEnvironmentConfig
+	level, err := jobopts.GetMessageLevel()
+	if err != nil {
+		return nil, err
+	}
 
-	if jobopts.IsLoopback() {
+	if jobopts.IsLoopback() && !*jobopts.ValidateOnly {
+		// LOOPBACK workers run in this process, so their logs are printed here
+		// too.
+		ctx = harness.WithLocalLogging(ctx, level)
 		// TODO(BEAM-10610): Allow user configuration of this port, rather than kernel selected.
 		srv, err := extworker.StartLoopback(ctx, 0)
 		if err != nil {
@@ -79,8 +103,18 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 		getEnvCfg = srv.EnvironmentConfig
 	}
 
-	// Fetch all dependencies for cross-language transforms
-	xlangx.ResolveArtifacts(ctx, edges, nil)
+	if err := jobopts.StageFiles(); err != nil {
+		return nil, err
+	}
+
+	// Fetch all dependencies for cross-language transforms, which are staged
+	// with the worker binary as those of their environments. Validation only
+	// needs their environments, so nothing is fetched for it.
+	if !*jobopts.ValidateOnly {
+		if _, err := xlangx.ResolveArtifactsWithConfig(ctx, edges, xlangx.ResolveConfig{}); err != nil {
+			return nil, errors.WithContext(err, "resolving cross-language artifacts")
+		}
+	}
 
 	environment, err := graphx.CreateEnvironment(ctx, envUrn, getEnvCfg)
 	if err != nil {
@@ -92,14 +126,52 @@ func Execute(ctx context.Context, p *beam.Pipeline) (beam.PipelineResult, error)
 	}
 
 	log.Info(ctx, proto.MarshalTextString(pipeline))
+	if *jobopts.ValidateOnly {
+		log.Info(ctx, "Validate-only: pipeline is valid, not running it.")
+		return runnerlib.ValidatedPipelineResult(), nil
+	}
+	if envUrn == "beam:env:external:v1" && !jobopts.IsLoopback() {
+		if err := probeExternalEnvironment(ctx, environment); err != nil {
+			return nil, err
+		}
+	}
+
+	getEndpoint := cfg.Endpoint
+	if getEndpoint == nil {
+		getEndpoint = jobopts.GetEndpoint
+	}
+	endpoint, err := getEndpoint()
+	if err != nil {
+		return nil, err
+	}
 
 	opt := &runnerlib.JobOptions{
-		Name:         jobopts.GetJobName(),
-		Experiments:  jobopts.GetExperiments(),
-		Worker:       *jobopts.WorkerBinary,
-		RetainDocker: *jobopts.RetainDockerContainers,
-		Parallelism:  *jobopts.Parallelism,
+		Name:          jobopts.GetJobName(),
+		Experiments:   jobopts.GetExperiments(),
+		Worker:        *jobopts.WorkerBinary,
+		RetainDocker:  *jobopts.RetainDockerContainers,
+		Parallelism:   *jobopts.Parallelism,
+		MessageLevel:  level,
+		RunnerOptions: cfg.RunnerOptions,
 	}
 	presult, err := runnerlib.Execute(ctx, pipeline, endpoint, opt, *jobopts.Async)
 	return presult, err
 }
+
+// probeExternalEnvironment checks the worker pool of an EXTERNAL environment,
+// if configured to.
+func probeExternalEnvironment(ctx context.Context, env *pipepb.Environment) error {
+	var payload pipepb.ExternalPayload
+	if err := proto.Unmarshal(env.GetPayload(), &payload); err != nil {
+		return errors.Wrap(err, "invalid EXTERNAL environment")
+	}
+	endpoint := payload.GetEndpoint().GetUrl()
+	if endpoint == "" {
+		return errors.New("EXTERNAL environments need the worker pool endpoint. Use --environment_config=<endpoint>")
+	}
+	if *jobopts.EnvironmentProbeTimeout <= 0 {
+		return nil
+	}
+	log.Infof(ctx, "Checking worker pool at %v", endpoint)
+	return extworker.Probe(ctx, endpoint, *jobopts.EnvironmentProbeTimeout)
+}
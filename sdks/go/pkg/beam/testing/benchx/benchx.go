@@ -0,0 +1,340 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package benchx benchmarks coders and fragments of execution plans over
+// generated data, with standard Go benchmarks. The benchmarks report their
+// allocations as well as their time, so that regressions of the encoding and
+// execution hot paths are visible in the output of go test -bench:
+//
+//	func BenchmarkCoder(b *testing.B) {
+//		c := coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewVarInt()})
+//		benchx.Coder(b, c, benchx.Elements(c, 1000))
+//	}
+//
+//	func BenchmarkParDo(b *testing.B) {
+//		benchx.ParDo(b, benchx.Values(benchx.Generate(reflectx.Int, 1000)), &splitFn{}, formatFn)
+//	}
+package benchx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// seed is the seed of the generated data, which is the same for every run,
+// so that the results of runs are comparable.
+const seed = 42
+
+// maxLen is the maximum length of generated strings, byte slices and slices.
+const maxLen = 16
+
+// Generate returns n values of the given type, generated deterministically.
+// It supports booleans, numbers, strings, and slices, arrays, maps, pointers
+// and structs of them. Unexported struct fields are left zero.
+func Generate(t reflect.Type, n int) []interface{} {
+	r := rand.New(rand.NewSource(seed))
+	ret := make([]interface{}, n)
+	for i := range ret {
+		ret[i] = generate(r, t).Interface()
+	}
+	return ret
+}
+
+func generate(r *rand.Rand, t reflect.Type) reflect.Value {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		v.SetBool(r.Intn(2) == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(r.Int63() >> uint(64-t.Bits()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(r.Uint64() >> uint(64-t.Bits()))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(r.NormFloat64())
+	case reflect.String:
+		b := make([]byte, r.Intn(maxLen+1))
+		for i := range b {
+			b[i] = byte('a' + r.Intn(26))
+		}
+		v.SetString(string(b))
+	case reflect.Slice:
+		n := r.Intn(maxLen + 1)
+		v.Set(reflect.MakeSlice(t, n, n))
+		for i := 0; i < n; i++ {
+			v.Index(i).Set(generate(r, t.Elem()))
+		}
+	case reflect.Array:
+		for i := 0; i < t.Len(); i++ {
+			v.Index(i).Set(generate(r, t.Elem()))
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(t))
+		for i := r.Intn(maxLen + 1); i > 0; i-- {
+			v.SetMapIndex(generate(r, t.Key()), generate(r, t.Elem()))
+		}
+	case reflect.Ptr:
+		v.Set(reflect.New(t.Elem()))
+		v.Elem().Set(generate(r, t.Elem()))
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath == "" {
+				v.Field(i).Set(generate(r, t.Field(i).Type))
+			}
+		}
+	default:
+		panic(fmt.Sprintf("benchx: can't generate values of type %v", t))
+	}
+	return v
+}
+
+// Values returns the given values as elements, at the zero timestamp in the
+// global window.
+func Values(values []interface{}) []exec.FullValue {
+	ret := make([]exec.FullValue, len(values))
+	for i, v := range values {
+		ret[i] = exec.FullValue{Elm: v, Timestamp: mtime.ZeroTimestamp, Windows: window.SingleGlobalWindow}
+	}
+	return ret
+}
+
+// KVs returns the pairs of the given keys and values as elements.
+func KVs(keys, values []interface{}) []exec.FullValue {
+	if len(keys) != len(values) {
+		panic(fmt.Sprintf("benchx: %d keys and %d values don't pair up", len(keys), len(values)))
+	}
+	ret := Values(keys)
+	for i, v := range values {
+		ret[i].Elm2 = v
+	}
+	return ret
+}
+
+// Elements returns n elements generated for the given coder. The elements of
+// KV coders have generated keys and values, and the elements of windowed value
+// coders are in the global window.
+func Elements(c *coder.Coder, n int) []exec.FullValue {
+	switch c.Kind {
+	case coder.WindowedValue:
+		return Elements(c.Components[0], n)
+	case coder.KV:
+		return KVs(Generate(c.Components[0].T.Type(), n), Generate(c.Components[1].T.Type(), n))
+	default:
+		return Values(Generate(c.T.Type(), n))
+	}
+}
+
+// Coder benchmarks the encoding and the decoding of the given elements with
+// the coder, as the Encode and Decode sub-benchmarks. The throughput is
+// reported from the average size of the encoded elements.
+func Coder(b *testing.B, c *coder.Coder, elms []exec.FullValue) {
+	if len(elms) == 0 {
+		b.Fatal("benchx: no elements to encode")
+	}
+	enc := exec.MakeElementEncoder(c)
+	dec := exec.MakeElementDecoder(c)
+
+	// Each element is encoded separately, so that decoding can be started
+	// from any of them.
+	encoded := make([][]byte, len(elms))
+	var size int
+	for i := range elms {
+		var buf bytes.Buffer
+		if err := enc.Encode(&elms[i], &buf); err != nil {
+			b.Fatalf("benchx: failed to encode %v with %v: %v", elms[i], c, err)
+		}
+		encoded[i] = buf.Bytes()
+		size += buf.Len()
+	}
+	avg := int64(size / len(elms))
+
+	b.Run("Encode", func(b *testing.B) {
+		var buf bytes.Buffer
+		b.ReportAllocs()
+		b.SetBytes(avg)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			if err := enc.Encode(&elms[i%len(elms)], &buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Decode", func(b *testing.B) {
+		var r bytes.Reader
+		var fv exec.FullValue
+		b.ReportAllocs()
+		b.SetBytes(avg)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r.Reset(encoded[i%len(encoded)])
+			if err := dec.DecodeTo(&r, &fv); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// ParDo benchmarks an execution plan fragment that processes the given
+// elements with a chain of the given DoFns, each of which consumes the first
+// output of the one before. The other outputs are discarded. The type of the
+// input is that of the first element, which is a KV if it has a value.
+//
+// Each iteration processes one element, in a single bundle that spans the
+// whole benchmark, after a warm up bundle has brought the plan up.
+func ParDo(b *testing.B, elms []exec.FullValue, dofns ...interface{}) {
+	if len(elms) == 0 {
+		b.Fatal("benchx: no elements to process")
+	}
+	root, units, err := build(elms, dofns)
+	if err != nil {
+		b.Fatalf("benchx: failed to build plan: %v", err)
+	}
+	p, err := exec.NewPlan("benchx", units)
+	if err != nil {
+		b.Fatalf("benchx: failed to create plan: %v", err)
+	}
+	ctx := context.Background()
+	defer p.Down(ctx)
+
+	root.n = len(elms)
+	if err := p.Execute(ctx, "warmup", exec.DataContext{}); err != nil {
+		b.Fatalf("benchx: failed to execute plan: %v", err)
+	}
+
+	root.n = b.N
+	b.ReportAllocs()
+	b.ResetTimer()
+	if err := p.Execute(ctx, "bench", exec.DataContext{}); err != nil {
+		b.Fatalf("benchx: failed to execute plan: %v", err)
+	}
+}
+
+// build returns the units of a plan processing the elements with the chain
+// of DoFns, and their root.
+func build(elms []exec.FullValue, dofns []interface{}) (*root, []exec.Unit, error) {
+	if len(dofns) == 0 {
+		return nil, nil, errors.New("no DoFns")
+	}
+	g := graph.New()
+	t := typex.New(reflect.TypeOf(elms[0].Elm))
+	if elms[0].Elm2 != nil {
+		t = typex.NewKV(t, typex.New(reflect.TypeOf(elms[0].Elm2)))
+	}
+	in := g.NewNode(t, window.DefaultWindowingStrategy(), true)
+
+	uid := exec.UnitID(1)
+	r := &root{uid: uid, elms: elms}
+	units := []exec.Unit{r}
+	// link sets the node of the previous unit, which the next unit is
+	// the output of.
+	link := func(n exec.Node) { r.out = n }
+	for i, dofn := range dofns {
+		opt := graph.NumMainInputs(graph.MainSingle)
+		if typex.IsKV(in.Type()) {
+			opt = graph.NumMainInputs(graph.MainKv)
+		}
+		fn, err := graph.NewDoFn(dofn, opt)
+		if err != nil {
+			return nil, nil, errors.WithContextf(err, "DoFn %d", i)
+		}
+		if fn.IsSplittable() {
+			return nil, nil, errors.Errorf("splittable DoFn %v isn't supported", fn.Name())
+		}
+		edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{in}, nil, nil)
+		if err != nil {
+			return nil, nil, errors.WithContextf(err, "DoFn %d", i)
+		}
+		uid++
+		pardo := &exec.ParDo{UID: uid, Fn: fn, Inbound: edge.Input, PID: fmt.Sprintf("pardo%d", i)}
+		link(pardo)
+		units = append(units, pardo)
+
+		next := i+1 < len(dofns)
+		for j := range edge.Output {
+			if j == 0 && next {
+				// Filled in by the next DoFn.
+				pardo.Out = append(pardo.Out, nil)
+				continue
+			}
+			uid++
+			d := &exec.Discard{UID: uid}
+			pardo.Out = append(pardo.Out, d)
+			units = append(units, d)
+		}
+		if next {
+			if len(edge.Output) == 0 {
+				return nil, nil, errors.Errorf("DoFn %d has no output to chain", i)
+			}
+			in = edge.Output[0].To
+			link = func(n exec.Node) { pardo.Out[0] = n }
+		}
+	}
+	return r, units, nil
+}
+
+// root emits n of the elements, round robin, in each bundle.
+type root struct {
+	uid  exec.UnitID
+	elms []exec.FullValue
+	n    int
+	out  exec.Node
+}
+
+func (r *root) ID() exec.UnitID {
+	return r.uid
+}
+
+func (r *root) Up(ctx context.Context) error {
+	return nil
+}
+
+func (r *root) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	return r.out.StartBundle(ctx, id, data)
+}
+
+func (r *root) Process(ctx context.Context) error {
+	for i := 0; i < r.n; i++ {
+		// Copy the element, as downstream units may modify it.
+		elm := r.elms[i%len(r.elms)]
+		if err := r.out.ProcessElement(ctx, &elm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *root) FinishBundle(ctx context.Context) error {
+	return r.out.FinishBundle(ctx)
+}
+
+func (r *root) Down(ctx context.Context) error {
+	return nil
+}
+
+func (r *root) String() string {
+	return fmt.Sprintf("benchx.Root[%v] Out:%v", r.uid, r.out.ID())
+}
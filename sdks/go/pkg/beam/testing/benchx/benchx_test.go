@@ -0,0 +1,169 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchx
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/google/go-cmp/cmp"
+)
+
+func init() {
+	beam.RegisterFunction(splitFn)
+	beam.RegisterFunction(lengthFn)
+	beam.RegisterType(reflect.TypeOf((*countFn)(nil)).Elem())
+}
+
+type row struct {
+	Name   string
+	Count  int64
+	Scores []float64
+	hidden int
+}
+
+func splitFn(s string, emit func(string, int)) {
+	for _, w := range strings.Split(s, "a") {
+		emit(w, 1)
+	}
+}
+
+func lengthFn(k string, v int) int {
+	return len(k) + v
+}
+
+// calls counts the elements processed by countFn.
+var calls int
+
+type countFn struct{}
+
+func (*countFn) ProcessElement(v int) int {
+	calls++
+	return v
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []reflect.Type{
+		reflectx.Bool,
+		reflectx.Int8,
+		reflectx.Int64,
+		reflectx.Uint32,
+		reflectx.Float64,
+		reflectx.String,
+		reflectx.ByteSlice,
+		reflect.TypeOf(map[string]int{}),
+		reflect.TypeOf([3]int{}),
+		reflect.TypeOf(&row{}),
+		reflect.TypeOf(row{}),
+	}
+	for _, test := range tests {
+		got := Generate(test, 10)
+		if len(got) != 10 {
+			t.Fatalf("Generate(%v, 10) returned %d values, want 10", test, len(got))
+		}
+		for _, v := range got {
+			if reflect.TypeOf(v) != test {
+				t.Errorf("Generate(%v, 10) returned %v of type %T", test, v, v)
+			}
+		}
+		if d := cmp.Diff(got, Generate(test, 10), cmp.AllowUnexported(row{})); d != "" {
+			t.Errorf("Generate(%v, 10) isn't deterministic (-first +second):\n%v", test, d)
+		}
+	}
+}
+
+func TestElements(t *testing.T) {
+	c := coder.NewW(coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewVarInt()}), coder.NewGlobalWindow())
+	got := Elements(c, 5)
+	if len(got) != 5 {
+		t.Fatalf("Elements(%v, 5) returned %d elements, want 5", c, len(got))
+	}
+	for _, elm := range got {
+		if _, ok := elm.Elm.(string); !ok {
+			t.Errorf("Elements(%v, 5) returned key %v of type %T, want string", c, elm.Elm, elm.Elm)
+		}
+		if _, ok := elm.Elm2.(int64); !ok {
+			t.Errorf("Elements(%v, 5) returned value %v of type %T, want int64", c, elm.Elm2, elm.Elm2)
+		}
+		if d := cmp.Diff(window.SingleGlobalWindow, elm.Windows); d != "" {
+			t.Errorf("Elements(%v, 5) returned windows (-want +got):\n%v", c, d)
+		}
+	}
+}
+
+func TestParDo(t *testing.T) {
+	calls = 0
+	res := testing.Benchmark(func(b *testing.B) {
+		ParDo(b, Values(Generate(reflectx.String, 10)), splitFn, lengthFn, &countFn{})
+	})
+	if res.N == 0 {
+		t.Fatal("ParDo didn't run")
+	}
+	// Every string has at least one word, so countFn processes at least
+	// the warm up elements and one element per iteration.
+	if calls < 10+res.N {
+		t.Errorf("countFn processed %d elements, want at least %d", calls, 10+res.N)
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	elms := Values(Generate(reflectx.Int, 1))
+	if _, _, err := build(elms, nil); err == nil {
+		t.Error("build() with no DoFns succeeded, want error")
+	}
+	if _, _, err := build(elms, []interface{}{splitFn}); err == nil {
+		t.Error("build() for a DoFn of strings with ints succeeded, want error")
+	}
+}
+
+func BenchmarkCoder(b *testing.B) {
+	tests := []struct {
+		name  string
+		coder *coder.Coder
+	}{
+		{"bytes", coder.NewBytes()},
+		{"bool", coder.NewBool()},
+		{"varint", coder.NewVarInt()},
+		{"double", coder.NewDouble()},
+		{"string", coder.NewString()},
+		{"kv", coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewVarInt()})},
+		{"windowed", coder.NewW(coder.NewString(), coder.NewGlobalWindow())},
+		{"row", coder.NewR(typex.New(reflect.TypeOf(row{})))},
+	}
+	for _, test := range tests {
+		b.Run(test.name, func(b *testing.B) {
+			Coder(b, test.coder, Elements(test.coder, 1000))
+		})
+	}
+}
+
+func BenchmarkParDo(b *testing.B) {
+	b.Run("single", func(b *testing.B) {
+		ParDo(b, Values(Generate(reflectx.Int, 1000)), &countFn{})
+	})
+	b.Run("chain", func(b *testing.B) {
+		ParDo(b, Values(Generate(reflectx.String, 1000)), splitFn, lengthFn, &countFn{})
+	})
+	b.Run("kv", func(b *testing.B) {
+		ParDo(b, KVs(Generate(reflectx.String, 1000), Generate(reflectx.Int, 1000)), lengthFn)
+	})
+}
@@ -0,0 +1,507 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dofntester invokes DoFns directly in unit tests, with controlled
+// main and side inputs, event times and windows, and captures their outputs,
+// so the logic of a DoFn can be tested without running a pipeline per case.
+//
+// For example:
+//
+//	tester := dofntester.New(&splitFn{Sep: ","})
+//	res, err := tester.Process("a,b", dofntester.Value("c").At(mtime.FromMilliseconds(1000)))
+//	if err != nil { ... }
+//	words := res.Values(0) // ["a", "b", "c"]
+//
+// Each call to Process runs a bundle. The DoFn is set up on the first bundle,
+// and torn down by Teardown. Splittable DoFns aren't supported.
+//
+// The state of stateful DoFns is kept in memory across bundles, for each key
+// and window. Their timers fire when AdvanceWatermark or AdvanceProcessingTime
+// passes them, or when Drain ends the input:
+//
+//	tester := dofntester.New(&bufferFn{Buffer: state.Bag{ID: "buf", Type: reflectx.String}})
+//	tester.Process(dofntester.KV("k", "a"), dofntester.KV("k", "b"))
+//	res, err := tester.AdvanceWatermark(mtime.MaxTimestamp) // Fires the flush timer.
+package dofntester
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// Element is an element of a main or side input or of an output, with its
+// event time and windows. Elements are in the global window at the zero
+// timestamp, unless set otherwise.
+type Element struct {
+	// Key is the key of a KV element.
+	Key interface{}
+	// Value is the element, or the value of a KV element.
+	Value interface{}
+	// IsKV is whether the element is a KV.
+	IsKV bool
+
+	Timestamp mtime.Time
+	Windows   []beam.Window
+}
+
+// Value returns an element holding the given value.
+func Value(v interface{}) Element {
+	return Element{Value: v}
+}
+
+// KV returns a KV element holding the given key and value.
+func KV(k, v interface{}) Element {
+	return Element{Key: k, Value: v, IsKV: true}
+}
+
+// At returns the element with the given event time.
+func (e Element) At(ts mtime.Time) Element {
+	e.Timestamp = ts
+	return e
+}
+
+// In returns the element in the given windows.
+func (e Element) In(ws ...beam.Window) Element {
+	e.Windows = ws
+	return e
+}
+
+func (e Element) String() string {
+	if e.IsKV {
+		return fmt.Sprintf("KV<%v,%v> [@%v:%v]", e.Key, e.Value, e.Timestamp, e.Windows)
+	}
+	return fmt.Sprintf("%v [@%v:%v]", e.Value, e.Timestamp, e.Windows)
+}
+
+// toElement returns the given element, or the given value as an element.
+func toElement(v interface{}) Element {
+	if e, ok := v.(Element); ok {
+		return e
+	}
+	return Value(v)
+}
+
+func (e Element) fullValue() exec.FullValue {
+	fv := exec.FullValue{Elm: e.Value, Timestamp: e.Timestamp, Windows: e.Windows}
+	if e.IsKV {
+		fv.Elm, fv.Elm2 = e.Key, e.Value
+	}
+	if len(fv.Windows) == 0 {
+		fv.Windows = window.SingleGlobalWindow
+	}
+	return fv
+}
+
+// fullType returns the type of the element.
+func (e Element) fullType() (typex.FullType, error) {
+	v := reflect.TypeOf(e.Value)
+	if v == nil {
+		return nil, errors.Errorf("element %v has no type", e)
+	}
+	if !e.IsKV {
+		return typex.New(v), nil
+	}
+	k := reflect.TypeOf(e.Key)
+	if k == nil {
+		return nil, errors.Errorf("key of element %v has no type", e)
+	}
+	return typex.NewKV(typex.New(k), typex.New(v)), nil
+}
+
+// Result holds the outputs of a bundle.
+type Result struct {
+	// Outputs are the elements of each output of the DoFn, in the order of
+	// its outputs: the returned value, if any, and then its emitters.
+	Outputs [][]Element
+}
+
+// Values returns the values of the elements of the ith output, without their
+// keys, event times and windows.
+func (r *Result) Values(i int) []interface{} {
+	var vs []interface{}
+	for _, e := range r.Outputs[i] {
+		vs = append(vs, e.Value)
+	}
+	return vs
+}
+
+// Tester invokes a DoFn directly, one bundle at a time.
+type Tester struct {
+	dofn  interface{}
+	sides [][]Element
+
+	pardo    *exec.ParDo
+	stateful *exec.StatefulParDo // nil unless the DoFn is stateful
+	pt       mtime.Time          // Processing time of timers.
+	outputs  []*capture
+	err      error
+}
+
+// New returns a Tester of the DoFn, which is a function or a pointer to a
+// struct, as given to beam.ParDo.
+func New(dofn interface{}) *Tester {
+	return &Tester{dofn: dofn}
+}
+
+// WithSideInput adds the next side input of the DoFn, in the order of its
+// parameters, with the given values or Elements. It must be called before the
+// first bundle, and the side input must have at least one value.
+//
+// The DoFn reads the values in the window of the element it processes, and
+// the values in the global window.
+func (t *Tester) WithSideInput(values ...interface{}) *Tester {
+	var side []Element
+	for _, v := range values {
+		side = append(side, toElement(v))
+	}
+	t.sides = append(t.sides, side)
+	return t
+}
+
+// Process runs a bundle of the given main input values or Elements through the
+// DoFn, and returns its outputs. There must be at least one element, and all
+// elements must have the same type.
+func (t *Tester) Process(elements ...interface{}) (*Result, error) {
+	if len(elements) == 0 {
+		return nil, errors.New("no elements to process")
+	}
+	var main []Element
+	for _, v := range elements {
+		main = append(main, toElement(v))
+	}
+	ctx := context.Background()
+	if t.pardo == nil {
+		if err := t.setup(ctx, main[0]); err != nil {
+			return nil, err
+		}
+	}
+	return t.bundle(ctx, func() error {
+		for _, e := range main {
+			fv := e.fullValue()
+			if err := t.pardo.ProcessElement(ctx, &fv); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AdvanceWatermark runs a bundle firing the event time timers of a stateful
+// DoFn set before the given watermark, and returns their outputs. Timers set
+// as timers fire wait for the next advancement.
+func (t *Tester) AdvanceWatermark(wm mtime.Time) (*Result, error) {
+	if err := t.checkStateful(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	return t.bundle(ctx, func() error {
+		return t.stateful.AdvanceWatermark(ctx, wm)
+	})
+}
+
+// AdvanceProcessingTime advances the processing time by d, and runs a bundle
+// firing the processing time timers of a stateful DoFn up to it. Processing
+// time starts at the wall time the DoFn is set up, and only advances with
+// AdvanceProcessingTime.
+func (t *Tester) AdvanceProcessingTime(d time.Duration) (*Result, error) {
+	if err := t.checkStateful(); err != nil {
+		return nil, err
+	}
+	t.pt = t.pt.Add(d)
+	ctx := context.Background()
+	return t.bundle(ctx, func() error {
+		return t.stateful.AdvanceProcessingTime(ctx, t.pt)
+	})
+}
+
+// Drain runs a bundle firing the remaining timers of a stateful DoFn, as
+// when its input is done, and drops the timers they set.
+func (t *Tester) Drain() (*Result, error) {
+	if err := t.checkStateful(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	return t.bundle(ctx, func() error {
+		return t.stateful.FireAll(ctx)
+	})
+}
+
+func (t *Tester) checkStateful() error {
+	if t.pardo == nil {
+		return errors.New("no bundle has been processed")
+	}
+	if t.stateful == nil {
+		return errors.Errorf("DoFn %v has no timers", t.pardo.Fn.Name())
+	}
+	return nil
+}
+
+// bundle runs a bundle, processing its input with the given function, and
+// returns its outputs.
+func (t *Tester) bundle(ctx context.Context, process func() error) (*Result, error) {
+	if t.err != nil {
+		return nil, errors.Wrap(t.err, "DoFn failed in a previous bundle")
+	}
+	for _, o := range t.outputs {
+		o.elements = nil
+	}
+	if err := t.pardo.StartBundle(ctx, "bundle", exec.DataContext{}); err != nil {
+		return nil, t.fail(err)
+	}
+	if err := process(); err != nil {
+		return nil, t.fail(err)
+	}
+	if err := t.pardo.FinishBundle(ctx); err != nil {
+		return nil, t.fail(err)
+	}
+
+	res := &Result{}
+	for _, o := range t.outputs {
+		res.Outputs = append(res.Outputs, o.elements)
+	}
+	return res, nil
+}
+
+// Teardown tears down the DoFn, if it was set up.
+func (t *Tester) Teardown() error {
+	if t.pardo == nil {
+		return nil
+	}
+	return t.pardo.Down(context.Background())
+}
+
+// fail records the error of a bundle, after which bundles can't be run.
+func (t *Tester) fail(err error) error {
+	t.err = err
+	return err
+}
+
+// setup binds the DoFn to inputs of the types of the given main element and
+// of the side inputs, and sets it up.
+func (t *Tester) setup(ctx context.Context, main Element) error {
+	g := graph.New()
+	mt, err := main.fullType()
+	if err != nil {
+		return err
+	}
+	in := []*graph.Node{g.NewNode(mt, window.DefaultWindowingStrategy(), true)}
+	var sides []exec.SideInputAdapter
+	for i, side := range t.sides {
+		if len(side) == 0 {
+			return errors.Errorf("side input %d has no values", i)
+		}
+		st, err := side[0].fullType()
+		if err != nil {
+			return errors.WithContextf(err, "side input %d", i)
+		}
+		in = append(in, g.NewNode(st, window.DefaultWindowingStrategy(), true))
+		sides = append(sides, sideInput(side))
+	}
+
+	opt := graph.NumMainInputs(graph.MainSingle)
+	if main.IsKV {
+		opt = graph.NumMainInputs(graph.MainKv)
+	}
+	fn, err := graph.NewDoFn(t.dofn, opt)
+	if err != nil {
+		return err
+	}
+	if fn.IsSplittable() {
+		return errors.Errorf("splittable DoFn %v isn't supported", fn.Name())
+	}
+	edge, err := graph.NewParDo(g, g.Root(), fn, in, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	var out []exec.Node
+	t.outputs = nil
+	for i := range edge.Output {
+		c := &capture{uid: exec.UnitID(i + 2)}
+		t.outputs = append(t.outputs, c)
+		out = append(out, c)
+	}
+	t.pardo = &exec.ParDo{UID: 1, Fn: fn, Inbound: edge.Input, Side: sides, Out: out, PID: "dofntester"}
+	for _, o := range out {
+		if err := o.Up(ctx); err != nil {
+			return err
+		}
+	}
+	if fn.IsStateful() {
+		if err := t.setupStateful(main); err != nil {
+			return err
+		}
+		return t.stateful.Up(ctx)
+	}
+	return t.pardo.Up(ctx)
+}
+
+// setupStateful keeps the state of the DoFn in memory, encoded with the
+// coders of the types of the key of the given main element and of the state
+// cells.
+func (t *Tester) setupStateful(main Element) error {
+	fn := t.pardo.Fn
+	state := make(map[string]*exec.StateCoder)
+	for _, c := range fn.StateCells() {
+		if c.Type == nil {
+			return errors.Errorf("state %v of DoFn %v has no Type", c.ID, fn.Name())
+		}
+		state[c.ID] = &exec.StateCoder{T: c.Type, Enc: newEncoder(c.Type), Dec: newDecoder(c.Type)}
+	}
+	t.pt = mtime.Now()
+	t.stateful = &exec.StatefulParDo{
+		ParDo:          t.pardo,
+		Key:            newEncoder(reflect.TypeOf(main.Key)),
+		Window:         windowEncoder{},
+		State:          state,
+		ProcessingTime: func() mtime.Time { return t.pt },
+	}
+	return nil
+}
+
+// elementEncoder adapts an encoder of the beam package to encode the values
+// of exec.FullValues.
+type elementEncoder struct {
+	enc beam.ElementEncoder
+}
+
+func newEncoder(t reflect.Type) elementEncoder {
+	return elementEncoder{enc: beam.NewElementEncoder(t)}
+}
+
+func (e elementEncoder) Encode(fv *exec.FullValue, w io.Writer) error {
+	return e.enc.Encode(fv.Elm, w)
+}
+
+// elementDecoder adapts a decoder of the beam package to decode the values
+// of exec.FullValues.
+type elementDecoder struct {
+	dec beam.ElementDecoder
+}
+
+func newDecoder(t reflect.Type) elementDecoder {
+	return elementDecoder{dec: beam.NewElementDecoder(t)}
+}
+
+func (d elementDecoder) Decode(r io.Reader) (*exec.FullValue, error) {
+	fv := &exec.FullValue{}
+	if err := d.DecodeTo(r, fv); err != nil {
+		return nil, err
+	}
+	return fv, nil
+}
+
+func (d elementDecoder) DecodeTo(r io.Reader, fv *exec.FullValue) error {
+	v, err := d.dec.Decode(r)
+	if err != nil {
+		return err
+	}
+	fv.Elm = v
+	return nil
+}
+
+var (
+	globalWindowEnc   = exec.MakeWindowEncoder(coder.NewGlobalWindow())
+	intervalWindowEnc = exec.MakeWindowEncoder(coder.NewIntervalWindow())
+)
+
+// windowEncoder encodes the global and interval windows elements are in, to
+// key the state of stateful DoFns.
+type windowEncoder struct{}
+
+func (e windowEncoder) Encode(ws []typex.Window, w io.Writer) error {
+	for _, win := range ws {
+		if err := e.EncodeSingle(win, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (windowEncoder) EncodeSingle(win typex.Window, w io.Writer) error {
+	switch win.(type) {
+	case window.GlobalWindow:
+		return globalWindowEnc.EncodeSingle(win, w)
+	case window.IntervalWindow:
+		return intervalWindowEnc.EncodeSingle(win, w)
+	default:
+		return errors.Errorf("state of windows of type %T isn't supported", win)
+	}
+}
+
+// sideInput provides the values of a side input, in the window they are
+// read in, or in the global window.
+type sideInput []Element
+
+func (s sideInput) NewIterable(_ context.Context, _ exec.StateReader, w typex.Window) (exec.ReStream, error) {
+	var buf []exec.FullValue
+	for _, e := range s {
+		fv := e.fullValue()
+		for _, ew := range fv.Windows {
+			if ew.Equals(w) || ew.Equals(window.GlobalWindow{}) {
+				buf = append(buf, fv)
+				break
+			}
+		}
+	}
+	return &exec.FixedReStream{Buf: buf}, nil
+}
+
+// capture holds the elements of an output of the DoFn.
+type capture struct {
+	uid      exec.UnitID
+	elements []Element
+}
+
+func (c *capture) ID() exec.UnitID {
+	return c.uid
+}
+
+func (c *capture) Up(ctx context.Context) error {
+	return nil
+}
+
+func (c *capture) StartBundle(ctx context.Context, id string, data exec.DataContext) error {
+	return nil
+}
+
+func (c *capture) ProcessElement(ctx context.Context, elm *exec.FullValue, values ...exec.ReStream) error {
+	e := Element{Value: elm.Elm, Timestamp: elm.Timestamp, Windows: append([]beam.Window(nil), elm.Windows...)}
+	if elm.Elm2 != nil {
+		e.Key, e.Value, e.IsKV = elm.Elm, elm.Elm2, true
+	}
+	c.elements = append(c.elements, e)
+	return nil
+}
+
+func (c *capture) FinishBundle(ctx context.Context) error {
+	return nil
+}
+
+func (c *capture) Down(ctx context.Context) error {
+	return nil
+}
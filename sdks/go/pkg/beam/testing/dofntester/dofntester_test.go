@@ -0,0 +1,249 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dofntester
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/state"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/timers"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/google/go-cmp/cmp"
+)
+
+type splitFn struct {
+	Sep string
+
+	setup, teardown int
+}
+
+func (f *splitFn) Setup() {
+	f.setup++
+}
+
+func (f *splitFn) ProcessElement(s string, words, empty func(string)) {
+	if s == "" {
+		empty(s)
+		return
+	}
+	for _, w := range strings.Split(s, f.Sep) {
+		words(w)
+	}
+}
+
+func (f *splitFn) Teardown() {
+	f.teardown++
+}
+
+func TestProcess(t *testing.T) {
+	fn := &splitFn{Sep: ","}
+	tester := New(fn)
+	for i := 0; i < 2; i++ {
+		res, err := tester.Process("a,b", "", "c")
+		if err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+		if diff := cmp.Diff([]interface{}{"a", "b", "c"}, res.Values(0)); diff != "" {
+			t.Errorf("words (-want +got):\n%v", diff)
+		}
+		if diff := cmp.Diff([]interface{}{""}, res.Values(1)); diff != "" {
+			t.Errorf("empty (-want +got):\n%v", diff)
+		}
+	}
+	if err := tester.Teardown(); err != nil {
+		t.Fatalf("Teardown failed: %v", err)
+	}
+	if fn.setup != 1 || fn.teardown != 1 {
+		t.Errorf("DoFn set up %d times and torn down %d times, want once", fn.setup, fn.teardown)
+	}
+}
+
+func shiftFn(w beam.Window, ts beam.EventTime, k string, v int, emit func(beam.EventTime, string, int)) {
+	if ts < w.MaxTimestamp() {
+		ts = w.MaxTimestamp()
+	}
+	emit(ts, k, v+1)
+}
+
+func TestProcess_EventTimeAndWindows(t *testing.T) {
+	w := window.IntervalWindow{Start: 0, End: 1000}
+	res, err := New(shiftFn).Process(KV("a", 1).At(mtime.FromMilliseconds(10)).In(w))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	want := []Element{KV("a", 2).At(w.MaxTimestamp()).In(w)}
+	if diff := cmp.Diff(want, res.Outputs[0]); diff != "" {
+		t.Errorf("outputs (-want +got):\n%v", diff)
+	}
+}
+
+func sumFn(k string, sides func(*int) bool) (string, int) {
+	sum, v := 0, 0
+	for sides(&v) {
+		sum += v
+	}
+	return k, sum
+}
+
+func TestProcess_SideInput(t *testing.T) {
+	w := window.IntervalWindow{Start: 0, End: 1000}
+	tester := New(sumFn).WithSideInput(1, 2, Value(4).In(w), Value(8).In(window.IntervalWindow{Start: 1000, End: 2000}))
+	res, err := tester.Process("global", Value("window").In(w))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	want := []Element{KV("global", 3).In(window.GlobalWindow{}), KV("window", 7).In(w)}
+	if diff := cmp.Diff(want, res.Outputs[0]); diff != "" {
+		t.Errorf("outputs (-want +got):\n%v", diff)
+	}
+}
+
+func failFn(s string) error {
+	if s == "bad" {
+		return errors.New("bad element")
+	}
+	return nil
+}
+
+func TestProcess_Errors(t *testing.T) {
+	tester := New(failFn)
+	if _, err := tester.Process(); err == nil {
+		t.Errorf("Process() succeeded, want error")
+	}
+	if _, err := tester.Process("bad"); err == nil || !strings.Contains(err.Error(), "bad element") {
+		t.Errorf("Process(bad) = %v, want error from the DoFn", err)
+	}
+	if _, err := tester.Process("good"); err == nil {
+		t.Errorf("Process(good) after a failed bundle succeeded, want error")
+	}
+	if _, err := New(failFn).Process(1); err == nil {
+		t.Errorf("Process(1) succeeded, want type error")
+	}
+	if _, err := New(sumFn).WithSideInput().Process("a"); err == nil {
+		t.Errorf("Process with an empty side input succeeded, want error")
+	}
+}
+
+// bufferFn buffers the values of each key and window until a timer fires,
+// and then emits them.
+type bufferFn struct {
+	Buffer     state.Bag
+	Flush      timers.EventTime
+	FlushAfter timers.ProcessingTime
+}
+
+func (fn *bufferFn) ProcessElement(w beam.Window, sp state.Provider, tp timers.Provider, _, v string, _ func(string)) error {
+	if fn.FlushAfter.Family != "" {
+		fn.FlushAfter.SetAfter(tp, time.Minute)
+	} else {
+		fn.Flush.Set(tp, w.MaxTimestamp())
+	}
+	return fn.Buffer.Add(sp, v)
+}
+
+func (fn *bufferFn) OnTimer(sp state.Provider, k, _ string, emit func(string)) error {
+	values, err := fn.Buffer.Read(sp)
+	if err != nil {
+		return err
+	}
+	var buf []string
+	for _, v := range values {
+		buf = append(buf, v.(string))
+	}
+	sort.Strings(buf)
+	emit(k + ":" + strings.Join(buf, ""))
+	return fn.Buffer.Clear(sp)
+}
+
+func TestProcess_Stateful(t *testing.T) {
+	w1 := window.IntervalWindow{Start: 0, End: 1000}
+	w2 := window.IntervalWindow{Start: 1000, End: 2000}
+	tester := New(&bufferFn{Buffer: state.Bag{ID: "buffer", Type: reflectx.String}, Flush: timers.EventTime{Family: "flush"}})
+
+	res, err := tester.Process(KV("a", "x").In(w1), KV("b", "y").In(w1), KV("a", "z").In(w2))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if got := len(res.Outputs[0]); got != 0 {
+		t.Errorf("Process emitted %v elements before the timers fired, want none", got)
+	}
+	// State is kept across bundles.
+	if _, err := tester.Process(KV("a", "w").In(w1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	res, err = tester.AdvanceWatermark(1000)
+	if err != nil {
+		t.Fatalf("AdvanceWatermark failed: %v", err)
+	}
+	// The timer of a was set again after that of b.
+	want := []Element{Value("b:y").At(w1.MaxTimestamp()).In(w1), Value("a:wx").At(w1.MaxTimestamp()).In(w1)}
+	if diff := cmp.Diff(want, res.Outputs[0]); diff != "" {
+		t.Errorf("outputs of the first window (-want +got):\n%v", diff)
+	}
+	res, err = tester.Drain()
+	if err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	want = []Element{Value("a:z").At(w2.MaxTimestamp()).In(w2)}
+	if diff := cmp.Diff(want, res.Outputs[0]); diff != "" {
+		t.Errorf("outputs of the second window (-want +got):\n%v", diff)
+	}
+}
+
+func TestProcess_ProcessingTimeTimers(t *testing.T) {
+	tester := New(&bufferFn{Buffer: state.Bag{ID: "buffer", Type: reflectx.String}, FlushAfter: timers.ProcessingTime{Family: "flush"}})
+	if _, err := tester.Process(KV("a", "x").At(10), KV("a", "y").At(20)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	res, err := tester.AdvanceProcessingTime(30 * time.Second)
+	if err != nil {
+		t.Fatalf("AdvanceProcessingTime failed: %v", err)
+	}
+	if got := len(res.Outputs[0]); got != 0 {
+		t.Errorf("timer fired before its time, emitting %v", res.Values(0))
+	}
+	res, err = tester.AdvanceProcessingTime(30 * time.Second)
+	if err != nil {
+		t.Fatalf("AdvanceProcessingTime failed: %v", err)
+	}
+	if diff := cmp.Diff([]interface{}{"a:xy"}, res.Values(0)); diff != "" {
+		t.Errorf("outputs (-want +got):\n%v", diff)
+	}
+}
+
+func TestProcess_StatefulErrors(t *testing.T) {
+	tester := New(&bufferFn{Buffer: state.Bag{ID: "buffer", Type: reflectx.String}, Flush: timers.EventTime{Family: "flush"}})
+	if _, err := tester.AdvanceWatermark(0); err == nil {
+		t.Errorf("AdvanceWatermark before Process succeeded, want error")
+	}
+	if _, err := tester.Process("x"); err == nil || !strings.Contains(err.Error(), "without a KV main input") {
+		t.Errorf("Process(x) = %v, want error for the non-KV element", err)
+	}
+	if _, err := New(failFn).Process("good"); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if _, err := New(&bufferFn{Buffer: state.Bag{ID: "buffer"}}).Process(KV("a", "x")); err == nil || !strings.Contains(err.Error(), "has no Type") {
+		t.Errorf("Process with untyped state = %v, want error", err)
+	}
+}
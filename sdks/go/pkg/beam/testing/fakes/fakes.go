@@ -0,0 +1,62 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakes contains in-memory fakes of external services, for unit
+// testing IO connectors without the real services:
+//
+//   - Log is a partitioned log with consumer group offsets, like a Kafka
+//     cluster, for message queue connectors.
+//   - HTTPRecorder is an HTTP server recording the requests it serves, with
+//     scripted responses, for connectors of HTTP services.
+//   - GCSServer and S3Server serve the APIs of Google Cloud Storage and of
+//     Amazon S3 used by their clients, for the file systems.
+//
+// Each fake can be told to fail its next calls, so that the retries of
+// connectors can be tested, and records what it was sent, so that batching
+// can be tested.
+package fakes
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// setenv sets an environment variable for the duration of the test.
+func setenv(t testing.TB, key, value string) {
+	orig, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, orig)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// Override sets the variable ptr points to, such as a package variable
+// creating clients, to v for the duration of the test.
+func Override(t testing.TB, ptr, v interface{}) {
+	p := reflect.ValueOf(ptr).Elem()
+	orig := reflect.New(p.Type()).Elem()
+	orig.Set(p)
+	if v == nil {
+		p.Set(reflect.Zero(p.Type()))
+	} else {
+		p.Set(reflect.ValueOf(v))
+	}
+	t.Cleanup(func() { p.Set(orig) })
+}
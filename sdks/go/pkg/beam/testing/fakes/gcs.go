@@ -0,0 +1,391 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// GCSServer is a fake Google Cloud Storage service, which serves the JSON API
+// calls and the media downloads of the cloud.google.com/go/storage client
+// used by the file system: listing, reading object metadata and ranges of
+// objects, and multipart and resumable uploads. Buckets exist once they have
+// objects, and buckets named "b" or "upload" aren't supported.
+//
+// The server records the requests it serves, and its next responses can be
+// made to fail, as for an HTTPRecorder.
+type GCSServer struct {
+	*HTTPRecorder
+
+	objects *store
+
+	mu      sync.Mutex
+	uploads map[string]*gcsUpload
+	nextID  int
+}
+
+// gcsUpload is a resumable upload in progress.
+type gcsUpload struct {
+	bucket string
+	meta   gcsObject
+	data   []byte
+}
+
+// gcsObject is the JSON resource of an object.
+type gcsObject struct {
+	Kind           string            `json:"kind,omitempty"`
+	ID             string            `json:"id,omitempty"`
+	Bucket         string            `json:"bucket"`
+	Name           string            `json:"name"`
+	Size           string            `json:"size,omitempty"`
+	Generation     string            `json:"generation,omitempty"`
+	Metageneration string            `json:"metageneration,omitempty"`
+	ContentType    string            `json:"contentType,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Updated        string            `json:"updated,omitempty"`
+}
+
+// NewGCSServer starts a fake GCS service, which the file systems created
+// during the test connect to, as its host is set as the STORAGE_EMULATOR_HOST.
+// The server is closed once the test ends.
+func NewGCSServer(t testing.TB) *GCSServer {
+	s := &GCSServer{objects: newStore(), uploads: make(map[string]*gcsUpload)}
+	s.HTTPRecorder = NewHTTPRecorder(t, http.HandlerFunc(s.serve))
+	setenv(t, "STORAGE_EMULATOR_HOST", strings.TrimPrefix(s.URL, "http://"))
+	return s
+}
+
+// Put stores an object, replacing any object with the same name.
+func (s *GCSServer) Put(bucket, name string, data []byte) {
+	s.objects.Put(bucket, name, data)
+}
+
+// Object returns the content of an object, if it exists.
+func (s *GCSServer) Object(bucket, name string) ([]byte, bool) {
+	return s.objects.Object(bucket, name)
+}
+
+// Objects returns the sorted names of the objects of a bucket.
+func (s *GCSServer) Objects(bucket string) []string {
+	return s.objects.Objects(bucket)
+}
+
+func (s *GCSServer) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/storage/v1")
+	switch {
+	case strings.HasPrefix(path, "/upload/storage/v1/b/"):
+		s.serveUpload(w, r, strings.TrimPrefix(path, "/upload/storage/v1/b/"))
+	case strings.HasPrefix(path, "/b/"):
+		bucket, rest := splitPath(strings.TrimPrefix(path, "/b/"))
+		switch {
+		case rest == "o":
+			s.serveList(w, r, bucket)
+		case strings.HasPrefix(rest, "o/"):
+			s.serveObject(w, r, bucket, strings.TrimPrefix(rest, "o/"))
+		default:
+			gcsError(w, http.StatusNotImplemented, "unsupported call %v %v", r.Method, r.URL.Path)
+		}
+	default:
+		s.serveMedia(w, r)
+	}
+}
+
+// splitPath splits a path into its first segment and the rest.
+func splitPath(path string) (string, string) {
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func gcsError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": status, "message": fmt.Sprintf(format, args...)},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func resource(bucket, name string, o *object) gcsObject {
+	gen := strconv.FormatInt(o.generation, 10)
+	return gcsObject{
+		Kind:           "storage#object",
+		ID:             fmt.Sprintf("%v/%v/%v", bucket, name, gen),
+		Bucket:         bucket,
+		Name:           name,
+		Size:           strconv.Itoa(len(o.data)),
+		Generation:     gen,
+		Metageneration: "1",
+		ContentType:    o.contentType,
+		Metadata:       o.metadata,
+		Updated:        o.updated.Format(time.RFC3339Nano),
+	}
+}
+
+// lookup returns the object of a request, which must be of the generation
+// of the request, if any.
+func (s *GCSServer) lookup(r *http.Request, bucket, name string) *object {
+	o := s.objects.get(bucket, name)
+	if o == nil {
+		return nil
+	}
+	if gen := r.URL.Query().Get("generation"); gen != "" && gen != strconv.FormatInt(o.generation, 10) {
+		return nil
+	}
+	return o
+}
+
+func (s *GCSServer) serveList(w http.ResponseWriter, r *http.Request, bucket string) {
+	if r.Method != http.MethodGet {
+		gcsError(w, http.StatusMethodNotAllowed, "unsupported method %v", r.Method)
+		return
+	}
+	q := r.URL.Query()
+	max := 1000
+	if m, err := strconv.Atoi(q.Get("maxResults")); err == nil && m > 0 {
+		max = m
+	}
+	prefix, delim := q.Get("prefix"), q.Get("delimiter")
+
+	// Page tokens are the name of the last object of the previous page.
+	names := s.objects.list(bucket, prefix, q.Get("pageToken"))
+	var items []gcsObject
+	var prefixes []string
+	seen := make(map[string]bool)
+	var token, last string
+	for _, name := range names {
+		if len(items)+len(prefixes) == max {
+			token = last
+			break
+		}
+		last = name
+		if delim != "" {
+			if i := strings.Index(name[len(prefix):], delim); i >= 0 {
+				p := name[:len(prefix)+i+len(delim)]
+				if !seen[p] {
+					seen[p] = true
+					prefixes = append(prefixes, p)
+				}
+				continue
+			}
+		}
+		if o := s.objects.get(bucket, name); o != nil {
+			items = append(items, resource(bucket, name, o))
+		}
+	}
+	writeJSON(w, map[string]interface{}{
+		"kind":          "storage#objects",
+		"items":         items,
+		"prefixes":      prefixes,
+		"nextPageToken": token,
+	})
+}
+
+func (s *GCSServer) serveObject(w http.ResponseWriter, r *http.Request, bucket, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		o := s.lookup(r, bucket, name)
+		if o == nil {
+			gcsError(w, http.StatusNotFound, "no such object: %v/%v", bucket, name)
+			return
+		}
+		if r.URL.Query().Get("alt") == "media" {
+			serveContent(w, r, o)
+			return
+		}
+		writeJSON(w, resource(bucket, name, o))
+	case http.MethodDelete:
+		if !s.objects.delete(bucket, name) {
+			gcsError(w, http.StatusNotFound, "no such object: %v/%v", bucket, name)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		gcsError(w, http.StatusMethodNotAllowed, "unsupported method %v", r.Method)
+	}
+}
+
+// serveMedia serves the downloads of objects from /bucket/object.
+func (s *GCSServer) serveMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		gcsError(w, http.StatusMethodNotAllowed, "unsupported method %v", r.Method)
+		return
+	}
+	bucket, name := splitPath(strings.TrimPrefix(r.URL.Path, "/"))
+	o := s.lookup(r, bucket, name)
+	if o == nil {
+		gcsError(w, http.StatusNotFound, "no such object: %v/%v", bucket, name)
+		return
+	}
+	w.Header().Set("X-Goog-Generation", strconv.FormatInt(o.generation, 10))
+	w.Header().Set("X-Goog-Metageneration", "1")
+	serveContent(w, r, o)
+}
+
+func (s *GCSServer) serveUpload(w http.ResponseWriter, r *http.Request, path string) {
+	bucket, rest := splitPath(path)
+	if rest != "o" {
+		gcsError(w, http.StatusNotImplemented, "unsupported call %v %v", r.Method, r.URL.Path)
+		return
+	}
+	q := r.URL.Query()
+	if id := q.Get("upload_id"); id != "" {
+		s.serveResumable(w, r, id)
+		return
+	}
+
+	var meta gcsObject
+	var data []byte
+	switch q.Get("uploadType") {
+	case "media":
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			gcsError(w, http.StatusBadRequest, "failed to read media: %v", err)
+			return
+		}
+		meta.ContentType, data = r.Header.Get("Content-Type"), body
+	case "multipart":
+		var err error
+		if meta, data, err = readMultipart(r); err != nil {
+			gcsError(w, http.StatusBadRequest, "invalid multipart upload: %v", err)
+			return
+		}
+	case "resumable":
+		if err := json.NewDecoder(r.Body).Decode(&meta); err != nil {
+			gcsError(w, http.StatusBadRequest, "invalid object metadata: %v", err)
+			return
+		}
+		if meta.Name == "" {
+			meta.Name = q.Get("name")
+		}
+		s.mu.Lock()
+		s.nextID++
+		id := strconv.Itoa(s.nextID)
+		s.uploads[id] = &gcsUpload{bucket: bucket, meta: meta}
+		s.mu.Unlock()
+		w.Header().Set("Location", fmt.Sprintf("%v/upload/storage/v1/b/%v/o?uploadType=resumable&upload_id=%v", s.URL, bucket, id))
+		w.WriteHeader(http.StatusOK)
+		return
+	default:
+		gcsError(w, http.StatusBadRequest, "unsupported upload type %q", q.Get("uploadType"))
+		return
+	}
+	if meta.Name == "" {
+		meta.Name = q.Get("name")
+	}
+	s.finish(w, bucket, meta, data)
+}
+
+// serveResumable serves a chunk of a resumable upload.
+func (s *GCSServer) serveResumable(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	u, ok := s.uploads[id]
+	s.mu.Unlock()
+	if !ok {
+		gcsError(w, http.StatusNotFound, "no such upload: %v", id)
+		return
+	}
+	chunk, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		gcsError(w, http.StatusBadRequest, "failed to read chunk: %v", err)
+		return
+	}
+	// Content-Range is "bytes first-last/total" or "bytes */total", where
+	// the total is "*" until the last chunk.
+	cr := strings.TrimPrefix(r.Header.Get("Content-Range"), "bytes ")
+	i := strings.Index(cr, "/")
+	if i < 0 {
+		gcsError(w, http.StatusBadRequest, "invalid Content-Range %q", r.Header.Get("Content-Range"))
+		return
+	}
+	if span := cr[:i]; span != "*" {
+		first, err := strconv.Atoi(strings.SplitN(span, "-", 2)[0])
+		if err != nil || first != len(u.data) {
+			gcsError(w, http.StatusBadRequest, "chunk at %q doesn't follow the %d bytes received", span, len(u.data))
+			return
+		}
+		u.data = append(u.data, chunk...)
+	}
+	if total := cr[i+1:]; total == "*" || total != strconv.Itoa(len(u.data)) {
+		if len(u.data) > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(u.data)-1))
+		}
+		if r.Header.Get("X-GUploader-No-308") == "yes" {
+			// Clients ask for the status in a header, as 308 is also
+			// Permanent Redirect.
+			w.Header().Set("X-HTTP-Status-Code-Override", "308")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(308) // Resume Incomplete.
+		return
+	}
+	s.mu.Lock()
+	delete(s.uploads, id)
+	s.mu.Unlock()
+	s.finish(w, u.bucket, u.meta, u.data)
+}
+
+// finish stores an uploaded object and replies with its resource.
+func (s *GCSServer) finish(w http.ResponseWriter, bucket string, meta gcsObject, data []byte) {
+	if meta.Name == "" {
+		gcsError(w, http.StatusBadRequest, "missing object name")
+		return
+	}
+	o := s.objects.put(bucket, meta.Name, &object{data: data, contentType: meta.ContentType, metadata: meta.Metadata})
+	writeJSON(w, resource(bucket, meta.Name, o))
+}
+
+// readMultipart reads the metadata and the media of a multipart upload.
+func readMultipart(r *http.Request) (gcsObject, []byte, error) {
+	var meta gcsObject
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return meta, nil, err
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	part, err := mr.NextPart()
+	if err != nil {
+		return meta, nil, err
+	}
+	if err := json.NewDecoder(part).Decode(&meta); err != nil {
+		return meta, nil, err
+	}
+	if part, err = mr.NextPart(); err != nil {
+		return meta, nil, err
+	}
+	if meta.ContentType == "" {
+		meta.ContentType = part.Header.Get("Content-Type")
+	}
+	data, err := ioutil.ReadAll(part)
+	return meta, data, err
+}
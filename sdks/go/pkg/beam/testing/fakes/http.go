@@ -0,0 +1,121 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Request is a request served by an HTTPRecorder.
+type Request struct {
+	Method string
+	// Path is the path of the URL, including its query, if any.
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Response is a scripted response of an HTTPRecorder.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// HTTPRecorder is an HTTP server, which records the requests it serves. It
+// replies with the scripted responses first, in order, and then with its
+// handler. It is safe for concurrent use.
+type HTTPRecorder struct {
+	*httptest.Server
+
+	handler http.Handler
+
+	mu        sync.Mutex
+	requests  []Request
+	responses []Response
+}
+
+// NewHTTPRecorder starts an HTTPRecorder replying with the handler, or with
+// 200 OK if the handler is nil. The server is closed once the test ends.
+func NewHTTPRecorder(t testing.TB, handler http.Handler) *HTTPRecorder {
+	r := &HTTPRecorder{handler: handler}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *HTTPRecorder) serve(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	r.requests = append(r.requests, Request{
+		Method: req.Method,
+		Path:   req.URL.RequestURI(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+	var scripted *Response
+	if len(r.responses) > 0 {
+		scripted = &r.responses[0]
+		r.responses = r.responses[1:]
+	}
+	r.mu.Unlock()
+
+	if scripted == nil {
+		if r.handler == nil {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.handler.ServeHTTP(w, req)
+		return
+	}
+	for k, vs := range scripted.Header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(scripted.Status)
+	w.Write(scripted.Body)
+}
+
+// Enqueue scripts the next responses of the server.
+func (r *HTTPRecorder) Enqueue(responses ...Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses = append(r.responses, responses...)
+}
+
+// FailNext scripts the next n responses of the server to be errors with the
+// given status.
+func (r *HTTPRecorder) FailNext(n, status int) {
+	for i := 0; i < n; i++ {
+		r.Enqueue(Response{Status: status, Body: []byte(http.StatusText(status))})
+	}
+}
+
+// Requests returns the requests served so far, in the order they arrived.
+func (r *HTTPRecorder) Requests() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Request(nil), r.requests...)
+}
@@ -0,0 +1,65 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHTTPRecorder(t *testing.T) {
+	srv := NewHTTPRecorder(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte("echo " + string(body)))
+	}))
+	srv.FailNext(1, http.StatusServiceUnavailable)
+	srv.Enqueue(Response{Status: http.StatusCreated, Header: http.Header{"X-Fake": {"yes"}}, Body: []byte("scripted")})
+
+	tests := []struct {
+		status int
+		body   string
+	}{
+		{http.StatusServiceUnavailable, "Service Unavailable"},
+		{http.StatusCreated, "scripted"},
+		{http.StatusOK, "echo 2"},
+	}
+	for i, test := range tests {
+		resp, err := http.Post(srv.URL+"/path?q=1", "text/plain", strings.NewReader(string(rune('0'+i))))
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status || string(body) != test.body {
+			t.Errorf("request %d replied %v %q, want %v %q", i, resp.StatusCode, body, test.status, test.body)
+		}
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 3 {
+		t.Fatalf("recorded %d requests, want 3", len(reqs))
+	}
+	for i, r := range reqs {
+		if r.Method != http.MethodPost || r.Path != "/path?q=1" || string(r.Body) != string(rune('0'+i)) {
+			t.Errorf("request %d = %v %v %q, want POST /path?q=1 %q", i, r.Method, r.Path, r.Body, string(rune('0'+i)))
+		}
+		if got := r.Header.Get("Content-Type"); got != "text/plain" {
+			t.Errorf("request %d has Content-Type %q, want text/plain", i, got)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// Record is a record of a log.
+type Record struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Timestamp time.Time
+}
+
+// Log is an in-memory partitioned log, like a Kafka cluster. Records are
+// appended to the partitions of topics, at consecutive offsets from zero, and
+// consumer groups commit the offsets they have consumed up to. It is safe for
+// concurrent use.
+type Log struct {
+	mu      sync.Mutex
+	topics  map[string][][]Record
+	commits map[commitKey]int64
+	// arrived is closed and replaced whenever records are appended, to wake
+	// up blocked fetches.
+	arrived chan struct{}
+	// failures are the number of further calls to fail, and their error.
+	failures int
+	err      error
+}
+
+type commitKey struct {
+	group, topic string
+	partition    int
+}
+
+// NewLog returns an empty log.
+func NewLog() *Log {
+	return &Log{
+		topics:  make(map[string][][]Record),
+		commits: make(map[commitKey]int64),
+		arrived: make(chan struct{}),
+	}
+}
+
+// CreateTopic creates a topic with the given number of partitions.
+func (l *Log) CreateTopic(topic string, partitions int) error {
+	if partitions <= 0 {
+		return errors.Errorf("invalid number of partitions %d for topic %v, want at least 1", partitions, topic)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.topics[topic]; ok {
+		return errors.Errorf("topic %v already exists", topic)
+	}
+	l.topics[topic] = make([][]Record, partitions)
+	return nil
+}
+
+// Partitions returns the number of partitions of the topic.
+func (l *Log) Partitions(topic string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ps, ok := l.topics[topic]
+	if !ok {
+		return 0, errors.Errorf("unknown topic %v", topic)
+	}
+	return len(ps), nil
+}
+
+// FailNext makes the next n calls of Produce, Fetch and Commit fail with the
+// given error, without effect.
+func (l *Log) FailNext(n int, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failures, l.err = n, err
+}
+
+// fail returns the error of an injected failure, if any. The lock must be
+// held.
+func (l *Log) fail() error {
+	if l.failures == 0 {
+		return nil
+	}
+	l.failures--
+	return l.err
+}
+
+// Produce appends a record to a partition of the topic, and returns its
+// offset. If the partition is negative, records are partitioned by the hash
+// of their key, as Kafka producers do.
+func (l *Log) Produce(topic string, partition int, key, value []byte) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.fail(); err != nil {
+		return 0, err
+	}
+	ps, ok := l.topics[topic]
+	if !ok {
+		return 0, errors.Errorf("unknown topic %v", topic)
+	}
+	if partition < 0 {
+		h := fnv.New32a()
+		h.Write(key)
+		partition = int(h.Sum32() % uint32(len(ps)))
+	}
+	if partition >= len(ps) {
+		return 0, errors.Errorf("unknown partition %d of topic %v with %d partitions", partition, topic, len(ps))
+	}
+	offset := int64(len(ps[partition]))
+	ps[partition] = append(ps[partition], Record{
+		Topic:     topic,
+		Partition: partition,
+		Offset:    offset,
+		Key:       key,
+		Value:     value,
+		Timestamp: time.Now(),
+	})
+	close(l.arrived)
+	l.arrived = make(chan struct{})
+	return offset, nil
+}
+
+// Fetch returns up to max records of a partition, from the given offset. If
+// there are no records at the offset yet, it blocks until there are, or the
+// context is done. A non-positive max returns all available records.
+func (l *Log) Fetch(ctx context.Context, topic string, partition int, offset int64, max int) ([]Record, error) {
+	for {
+		l.mu.Lock()
+		if err := l.fail(); err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+		records, err := l.records(topic, partition)
+		if err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+		if offset < 0 || offset > int64(len(records)) {
+			l.mu.Unlock()
+			return nil, errors.Errorf("offset %d out of range of partition %d of topic %v", offset, partition, topic)
+		}
+		if offset < int64(len(records)) {
+			ret := records[offset:]
+			if max > 0 && len(ret) > max {
+				ret = ret[:max]
+			}
+			l.mu.Unlock()
+			return append([]Record(nil), ret...), nil
+		}
+		arrived := l.arrived
+		l.mu.Unlock()
+
+		select {
+		case <-arrived:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Records returns all records of a partition.
+func (l *Log) Records(topic string, partition int) ([]Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records, err := l.records(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Record(nil), records...), nil
+}
+
+// records returns the records of a partition. The lock must be held.
+func (l *Log) records(topic string, partition int) ([]Record, error) {
+	ps, ok := l.topics[topic]
+	if !ok {
+		return nil, errors.Errorf("unknown topic %v", topic)
+	}
+	if partition < 0 || partition >= len(ps) {
+		return nil, errors.Errorf("unknown partition %d of topic %v with %d partitions", partition, topic, len(ps))
+	}
+	return ps[partition], nil
+}
+
+// Commit commits the offset of the next record the consumer group consumes
+// from a partition.
+func (l *Log) Commit(group, topic string, partition int, offset int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.fail(); err != nil {
+		return err
+	}
+	records, err := l.records(topic, partition)
+	if err != nil {
+		return err
+	}
+	if offset < 0 || offset > int64(len(records)) {
+		return errors.Errorf("offset %d out of range of partition %d of topic %v", offset, partition, topic)
+	}
+	l.commits[commitKey{group, topic, partition}] = offset
+	return nil
+}
+
+// Committed returns the offset committed by the consumer group for a
+// partition, if any.
+func (l *Log) Committed(group, topic string, partition int) (int64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	offset, ok := l.commits[commitKey{group, topic, partition}]
+	return offset, ok
+}
@@ -0,0 +1,120 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	l := NewLog()
+	if err := l.CreateTopic("topic", 2); err != nil {
+		t.Fatalf("CreateTopic failed: %v", err)
+	}
+	if err := l.CreateTopic("topic", 2); err == nil {
+		t.Error("CreateTopic of an existing topic succeeded, want error")
+	}
+	for i, v := range []string{"a", "b", "c"} {
+		offset, err := l.Produce("topic", 1, nil, []byte(v))
+		if err != nil || offset != int64(i) {
+			t.Errorf("Produce(%v) = %v, %v, want %v", v, offset, err, i)
+		}
+	}
+	ctx := context.Background()
+	records, err := l.Fetch(ctx, "topic", 1, 1, 1)
+	if err != nil || len(records) != 1 || string(records[0].Value) != "b" || records[0].Offset != 1 {
+		t.Errorf("Fetch(1, 1) = %v, %v, want b at offset 1", records, err)
+	}
+	if records, err := l.Fetch(ctx, "topic", 1, 1, 0); err != nil || len(records) != 2 {
+		t.Errorf("Fetch(1, 0) = %v, %v, want 2 records", records, err)
+	}
+	if _, err := l.Produce("missing", 0, nil, nil); err == nil {
+		t.Error("Produce to a missing topic succeeded, want error")
+	}
+	if _, err := l.Fetch(ctx, "topic", 2, 0, 0); err == nil {
+		t.Error("Fetch of a missing partition succeeded, want error")
+	}
+
+	if _, ok := l.Committed("group", "topic", 1); ok {
+		t.Error("Committed() before a commit returned an offset")
+	}
+	if err := l.Commit("group", "topic", 1, 2); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if offset, ok := l.Committed("group", "topic", 1); !ok || offset != 2 {
+		t.Errorf("Committed() = %v, %v, want 2", offset, ok)
+	}
+	if err := l.Commit("group", "topic", 1, 4); err == nil {
+		t.Error("Commit past the end of the partition succeeded, want error")
+	}
+}
+
+func TestLog_KeyPartitioning(t *testing.T) {
+	l := NewLog()
+	l.CreateTopic("topic", 4)
+	l.Produce("topic", -1, []byte("key"), []byte("1"))
+	l.Produce("topic", -1, []byte("key"), []byte("2"))
+
+	for p := 0; p < 4; p++ {
+		records, _ := l.Records("topic", p)
+		if len(records) != 0 && len(records) != 2 {
+			t.Errorf("partition %d has %d records, want all records of the key in one partition", p, len(records))
+		}
+	}
+}
+
+func TestLog_FetchBlocks(t *testing.T) {
+	l := NewLog()
+	l.CreateTopic("topic", 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Fetch(ctx, "topic", 0, 0, 0); err != context.DeadlineExceeded {
+		t.Errorf("Fetch of an empty partition failed with %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.Produce("topic", 0, nil, []byte("late"))
+	}()
+	records, err := l.Fetch(context.Background(), "topic", 0, 0, 0)
+	if err != nil || len(records) != 1 || string(records[0].Value) != "late" {
+		t.Errorf("Fetch() = %v, %v, want the late record", records, err)
+	}
+}
+
+func TestLog_FailNext(t *testing.T) {
+	l := NewLog()
+	l.CreateTopic("topic", 1)
+	unavailable := errors.New("unavailable")
+	l.FailNext(2, unavailable)
+
+	if _, err := l.Produce("topic", 0, nil, []byte("a")); err != unavailable {
+		t.Errorf("Produce failed with %v, want %v", err, unavailable)
+	}
+	if err := l.Commit("group", "topic", 0, 0); err != unavailable {
+		t.Errorf("Commit failed with %v, want %v", err, unavailable)
+	}
+	if _, err := l.Produce("topic", 0, nil, []byte("a")); err != nil {
+		t.Errorf("Produce after the failures failed: %v", err)
+	}
+	if records, _ := l.Records("topic", 0); len(records) != 1 {
+		t.Errorf("partition has %d records, want 1 as failed calls have no effect", len(records))
+	}
+}
@@ -0,0 +1,292 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// S3Server is a fake Amazon S3 service, which serves the path-style REST
+// calls of the AWS SDK client used by the file system: listing objects,
+// reading objects and ranges of them, and single and multipart uploads.
+// Buckets exist once they have objects. Requests aren't authenticated.
+//
+// The server records the requests it serves, and its next responses can be
+// made to fail, as for an HTTPRecorder.
+type S3Server struct {
+	*HTTPRecorder
+
+	objects *store
+
+	mu      sync.Mutex
+	uploads map[string]*s3Upload
+	nextID  int
+}
+
+// s3Upload is a multipart upload in progress.
+type s3Upload struct {
+	bucket, key string
+	parts       map[int][]byte
+}
+
+// NewS3Server starts a fake S3 service. Clients connect to it with its URL
+// as their endpoint, using path-style addressing. Static credentials are set
+// in the environment of the test, so that clients don't look for real ones.
+// The server is closed once the test ends.
+func NewS3Server(t testing.TB) *S3Server {
+	s := &S3Server{objects: newStore(), uploads: make(map[string]*s3Upload)}
+	s.HTTPRecorder = NewHTTPRecorder(t, http.HandlerFunc(s.serve))
+	setenv(t, "AWS_ACCESS_KEY_ID", "fake")
+	setenv(t, "AWS_SECRET_ACCESS_KEY", "fake")
+	return s
+}
+
+// Put stores an object, replacing any object with the same key.
+func (s *S3Server) Put(bucket, key string, data []byte) {
+	s.objects.Put(bucket, key, data)
+}
+
+// Object returns the content of an object, if it exists.
+func (s *S3Server) Object(bucket, key string) ([]byte, bool) {
+	return s.objects.Object(bucket, key)
+}
+
+// Objects returns the sorted keys of the objects of a bucket.
+func (s *S3Server) Objects(bucket string) []string {
+	return s.objects.Objects(bucket)
+}
+
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string
+	Message string
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code, format string, args ...interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(s3Error{Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(v)
+}
+
+func (s *S3Server) serve(w http.ResponseWriter, r *http.Request) {
+	bucket, key := splitPath(strings.TrimPrefix(r.URL.Path, "/"))
+	if bucket == "" {
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "unsupported call %v %v", r.Method, r.URL.Path)
+		return
+	}
+	q := r.URL.Query()
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.serveList(w, r, bucket)
+	case key == "":
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "unsupported call %v %v", r.Method, r.URL.Path)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		o := s.objects.get(bucket, key)
+		if o == nil {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey", "no such key: %v/%v", bucket, key)
+			return
+		}
+		serveContent(w, r, o)
+	case r.Method == http.MethodPut && q.Get("uploadId") != "":
+		s.servePart(w, r, q.Get("uploadId"))
+	case r.Method == http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody", "failed to read object: %v", err)
+			return
+		}
+		o := s.objects.put(bucket, key, &object{data: data, contentType: r.Header.Get("Content-Type")})
+		w.Header().Set("ETag", o.etag())
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && hasParam(r, "uploads"):
+		s.mu.Lock()
+		s.nextID++
+		id := strconv.Itoa(s.nextID)
+		s.uploads[id] = &s3Upload{bucket: bucket, key: key, parts: make(map[int][]byte)}
+		s.mu.Unlock()
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: id})
+	case r.Method == http.MethodPost && q.Get("uploadId") != "":
+		s.serveComplete(w, r, q.Get("uploadId"))
+	case r.Method == http.MethodDelete && q.Get("uploadId") != "":
+		s.mu.Lock()
+		delete(s.uploads, q.Get("uploadId"))
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		s.objects.delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "unsupported call %v %v", r.Method, r.URL.Path)
+	}
+}
+
+// hasParam returns whether the query of the request has the parameter, even
+// without a value.
+func hasParam(r *http.Request, name string) bool {
+	_, ok := r.URL.Query()[name]
+	return ok
+}
+
+type s3Contents struct {
+	Key          string
+	Size         int
+	ETag         string
+	LastModified string
+}
+
+func (s *S3Server) serveList(w http.ResponseWriter, r *http.Request, bucket string) {
+	q := r.URL.Query()
+	if q.Get("list-type") != "2" {
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "only ListObjectsV2 is supported")
+		return
+	}
+	max := 1000
+	if m, err := strconv.Atoi(q.Get("max-keys")); err == nil && m > 0 {
+		max = m
+	}
+	// Continuation tokens are the key of the last object of the previous
+	// page.
+	after := q.Get("continuation-token")
+	if sa := q.Get("start-after"); sa > after {
+		after = sa
+	}
+	keys := s.objects.list(bucket, q.Get("prefix"), after)
+	truncated := len(keys) > max
+	if truncated {
+		keys = keys[:max]
+	}
+	var contents []s3Contents
+	for _, key := range keys {
+		if o := s.objects.get(bucket, key); o != nil {
+			contents = append(contents, s3Contents{
+				Key:          key,
+				Size:         len(o.data),
+				ETag:         o.etag(),
+				LastModified: o.updated.Format(time.RFC3339Nano),
+			})
+		}
+	}
+	res := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		KeyCount              int
+		MaxKeys               int
+		IsTruncated           bool
+		ContinuationToken     string `xml:",omitempty"`
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []s3Contents
+	}{
+		Name:              bucket,
+		Prefix:            q.Get("prefix"),
+		KeyCount:          len(contents),
+		MaxKeys:           max,
+		IsTruncated:       truncated,
+		ContinuationToken: q.Get("continuation-token"),
+		Contents:          contents,
+	}
+	if truncated {
+		res.NextContinuationToken = keys[len(keys)-1]
+	}
+	writeXML(w, res)
+}
+
+// servePart serves the upload of a part of a multipart upload.
+func (s *S3Server) servePart(w http.ResponseWriter, r *http.Request, id string) {
+	n, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || n < 1 {
+		writeS3Error(w, http.StatusBadRequest, "InvalidArgument", "invalid part number %q", r.URL.Query().Get("partNumber"))
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeS3Error(w, http.StatusBadRequest, "IncompleteBody", "failed to read part: %v", err)
+		return
+	}
+	s.mu.Lock()
+	u, ok := s.uploads[id]
+	if ok {
+		u.parts[n] = data
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload", "no such upload: %v", id)
+		return
+	}
+	w.Header().Set("ETag", (&object{data: data}).etag())
+	w.WriteHeader(http.StatusOK)
+}
+
+// serveComplete completes a multipart upload, with the parts listed in the
+// request.
+func (s *S3Server) serveComplete(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML", "invalid parts: %v", err)
+		return
+	}
+	s.mu.Lock()
+	u, ok := s.uploads[id]
+	delete(s.uploads, id)
+	s.mu.Unlock()
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload", "no such upload: %v", id)
+		return
+	}
+	sort.Slice(req.Parts, func(i, j int) bool { return req.Parts[i].PartNumber < req.Parts[j].PartNumber })
+	var data []byte
+	for _, p := range req.Parts {
+		part, ok := u.parts[p.PartNumber]
+		if !ok {
+			writeS3Error(w, http.StatusBadRequest, "InvalidPart", "no part %d of upload %v", p.PartNumber, id)
+			return
+		}
+		data = append(data, part...)
+	}
+	o := s.objects.put(u.bucket, u.key, &object{data: data})
+	writeXML(w, struct {
+		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+		Location string
+		Bucket   string
+		Key      string
+		ETag     string
+	}{Location: fmt.Sprintf("%v/%v/%v", s.URL, u.bucket, u.key), Bucket: u.bucket, Key: u.key, ETag: o.etag()})
+}
@@ -0,0 +1,188 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// object is an object of a fake object store.
+type object struct {
+	data        []byte
+	generation  int64
+	contentType string
+	metadata    map[string]string
+	updated     time.Time
+}
+
+func (o *object) etag() string {
+	sum := md5.Sum(o.data)
+	return fmt.Sprintf("%q", hex.EncodeToString(sum[:]))
+}
+
+// store holds the objects of the buckets of a fake object store. Buckets
+// exist once they have objects.
+type store struct {
+	mu         sync.Mutex
+	buckets    map[string]map[string]*object
+	generation int64
+}
+
+func newStore() *store {
+	return &store{buckets: make(map[string]map[string]*object)}
+}
+
+// Put stores an object, replacing any object with the same name.
+func (s *store) Put(bucket, name string, data []byte) {
+	s.put(bucket, name, &object{data: data})
+}
+
+func (s *store) put(bucket, name string, o *object) *object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string]*object)
+	}
+	s.generation++
+	o.generation = s.generation
+	o.updated = time.Now().UTC().Truncate(time.Millisecond)
+	s.buckets[bucket][name] = o
+	return o
+}
+
+// Object returns the content of an object, if it exists.
+func (s *store) Object(bucket, name string) ([]byte, bool) {
+	if o := s.get(bucket, name); o != nil {
+		return o.data, true
+	}
+	return nil, false
+}
+
+func (s *store) get(bucket, name string) *object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buckets[bucket][name]
+}
+
+func (s *store) delete(bucket, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buckets[bucket][name]; !ok {
+		return false
+	}
+	delete(s.buckets[bucket], name)
+	return true
+}
+
+// Objects returns the sorted names of the objects of a bucket.
+func (s *store) Objects(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for name := range s.buckets[bucket] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// list returns the sorted names of the objects of a bucket with the prefix,
+// which start after the given name.
+func (s *store) list(bucket, prefix, after string) []string {
+	var ret []string
+	for _, name := range s.Objects(bucket) {
+		if strings.HasPrefix(name, prefix) && name > after {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// parseRange parses the byte range of a Range header for content of the
+// given size. Returns false if the range can't be satisfied.
+func parseRange(header string, size int64) (start, end int64, ok bool) {
+	spec := strings.TrimPrefix(header, "bytes=")
+	if spec == header || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	if parts[0] == "" {
+		// A suffix of the content.
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, size > 0
+	}
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+	end = size - 1
+	if parts[1] != "" {
+		if end, err = strconv.ParseInt(parts[1], 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
+
+// serveContent writes the content of an object, or the byte range of it
+// requested by the Range header, if any.
+func serveContent(w http.ResponseWriter, r *http.Request, o *object) {
+	size := int64(len(o.data))
+	w.Header().Set("Last-Modified", o.updated.Format(http.TimeFormat))
+	w.Header().Set("ETag", o.etag())
+	if o.contentType != "" {
+		w.Header().Set("Content-Type", o.contentType)
+	}
+	status, data := http.StatusOK, o.data
+	if header := r.Header.Get("Range"); header != "" {
+		start, end, ok := parseRange(header, size)
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		status, data = http.StatusPartialContent, o.data[start:end+1]
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+}
@@ -0,0 +1,177 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package golden compares the output of pipeline tests against golden files,
+// for regression testing of large outputs. It also compares snapshots of the
+// portable protos of pipelines against golden files, to catch changes of their
+// structure or coders, by ComparePipeline.
+//
+// Outputs are compared line by line, after normalization, which sorts the
+// lines by default, as the order of elements isn't deterministic. Golden
+// files are rewritten with the output of the tests, instead of being compared
+// against, with the --update_golden flag:
+//
+//	go test ./mypipeline --update_golden
+//
+// For example:
+//
+//	func TestPipeline(t *testing.T) {
+//		p, s := beam.NewPipelineWithRoot()
+//		out := golden.Output(t, s, buildPipeline(s))
+//		ptest.RunAndValidate(t, p)
+//		golden.Compare(t, out, "testdata/pipeline.golden", golden.RedactTimestamps)
+//	}
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	_ "github.com/apache/beam/sdks/go/pkg/beam/io/filesystem/local"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/textio"
+	"github.com/google/go-cmp/cmp"
+)
+
+// Update is a flag that sets whether golden files are rewritten with the
+// output of tests, instead of being compared against.
+var Update = flag.Bool("update_golden", false, "Rewrite golden files with the output of tests, instead of comparing against them (optional).")
+
+func init() {
+	beam.RegisterFunction(formatFn)
+}
+
+// Normalizer normalizes the lines of an output and of its golden file before
+// they are compared, such as to remove details that vary across runs.
+type Normalizer func(lines []string) []string
+
+// SortLines is a Normalizer that sorts the lines.
+func SortLines(lines []string) []string {
+	ret := append([]string(nil), lines...)
+	sort.Strings(ret)
+	return ret
+}
+
+// Redact returns a Normalizer that replaces the matches of the regexp in each
+// line with the replacement, as by Regexp.ReplaceAllString.
+func Redact(re *regexp.Regexp, repl string) Normalizer {
+	return func(lines []string) []string {
+		var ret []string
+		for _, l := range lines {
+			ret = append(ret, re.ReplaceAllString(l, repl))
+		}
+		return ret
+	}
+}
+
+// timestampRE matches RFC 3339 timestamps, with optional fractional seconds
+// and time zone.
+var timestampRE = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?`)
+
+// RedactTimestamps is a Normalizer that replaces RFC 3339 timestamps with
+// "<timestamp>".
+var RedactTimestamps = Redact(timestampRE, "<timestamp>")
+
+// formatFn formats an element of an output as a line.
+func formatFn(v beam.X) string {
+	return fmt.Sprint(v)
+}
+
+// Output writes the elements of the PCollection, formatted by fmt.Sprint, as
+// lines of a temporary file, and returns its path, to be compared by Compare
+// once the pipeline has run. The file is local, so the pipeline must run on a
+// runner that executes it in the test process, such as the direct runner.
+func Output(t *testing.T, s beam.Scope, col beam.PCollection) string {
+	t.Helper()
+	s = s.Scope("golden.Output")
+
+	path := filepath.Join(t.TempDir(), "output.txt")
+	lines := col
+	if col.Type().Type() != reflectx.String {
+		lines = beam.ParDo(s, formatFn, col)
+	}
+	textio.Write(s, path, lines)
+	return path
+}
+
+// Compare compares the lines of the output file against the golden file,
+// after normalizing both by sorting their lines and by the given Normalizers,
+// in order, and fails the test if they differ. With --update_golden, it
+// rewrites the golden file with the normalized output instead.
+func Compare(t *testing.T, output, golden string, norms ...Normalizer) {
+	t.Helper()
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output %v: %v", output, err)
+	}
+	CompareLines(t, splitLines(data), golden, norms...)
+}
+
+// CompareLines compares the lines against the golden file, as by Compare.
+func CompareLines(t *testing.T, lines []string, golden string, norms ...Normalizer) {
+	t.Helper()
+	got := normalize(lines, norms)
+	if *Update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatalf("Failed to create directory of golden file %v: %v", golden, err)
+		}
+		var buf bytes.Buffer
+		for _, l := range got {
+			buf.WriteString(l)
+			buf.WriteString("\n")
+		}
+		if err := ioutil.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("Failed to update golden file %v: %v", golden, err)
+		}
+		return
+	}
+
+	data, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read golden file %v, which --update_golden creates: %v", golden, err)
+	}
+	want := normalize(splitLines(data), norms)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Output differs from golden file %v, which --update_golden rewrites (-want +got):\n%v", golden, diff)
+	}
+}
+
+func normalize(lines []string, norms []Normalizer) []string {
+	lines = SortLines(lines)
+	for _, n := range norms {
+		lines = n(lines)
+	}
+	if lines == nil {
+		lines = []string{}
+	}
+	return lines
+}
+
+func splitLines(data []byte) []string {
+	s := strings.TrimSuffix(string(data), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,104 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golden
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+func TestNormalizers(t *testing.T) {
+	lines := []string{"b at 2021-06-01T10:00:00.5Z", "a at 2021-06-01 10:00:00+02:00"}
+	if diff := cmp.Diff([]string{lines[1], lines[0]}, SortLines(lines)); diff != "" {
+		t.Errorf("SortLines(%v) (-want +got):\n%v", lines, diff)
+	}
+	want := []string{"b at <timestamp>", "a at <timestamp>"}
+	if diff := cmp.Diff(want, RedactTimestamps(lines)); diff != "" {
+		t.Errorf("RedactTimestamps(%v) (-want +got):\n%v", lines, diff)
+	}
+	want = []string{"b at X", "a at X"}
+	if diff := cmp.Diff(want, Redact(regexp.MustCompile(`\d.*`), "X")(lines)); diff != "" {
+		t.Errorf("Redact(%v) (-want +got):\n%v", lines, diff)
+	}
+}
+
+func formatAtFn(v int) string {
+	return fmt.Sprintf("at 2021-06-01T10:00:00Z: %d", v)
+}
+
+func init() {
+	beam.RegisterFunction(formatAtFn)
+}
+
+func TestCompare(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, formatAtFn, beam.Create(s, 3, 1, 2))
+	out := Output(t, s, col)
+	ptest.RunAndValidate(t, p)
+
+	Compare(t, out, filepath.Join("testdata", "numbers.golden"), RedactTimestamps)
+}
+
+func TestOutput_Formatted(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	out := Output(t, s, beam.Create(s, 2, 1))
+	ptest.RunAndValidate(t, p)
+
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if diff := cmp.Diff([]string{"1", "2"}, SortLines(splitLines(data))); diff != "" {
+		t.Errorf("output (-want +got):\n%v", diff)
+	}
+}
+
+func TestCompareLines_Update(t *testing.T) {
+	defer func(update bool) { *Update = update }(*Update)
+
+	golden := filepath.Join(t.TempDir(), "testdata", "update.golden")
+	*Update = true
+	CompareLines(t, []string{"b", "a"}, golden)
+	data, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read updated golden file: %v", err)
+	}
+	if got, want := string(data), "a\nb\n"; got != want {
+		t.Errorf("updated golden file = %q, want %q", got, want)
+	}
+
+	*Update = false
+	CompareLines(t, []string{"a", "b"}, golden)
+}
+
+func TestComparePipeline(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, formatAtFn, beam.Create(s, 3, 1, 2))
+	Output(t, s, col)
+
+	ComparePipeline(t, p, filepath.Join("testdata", "pipeline.golden"))
+}
@@ -0,0 +1,148 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golden
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+)
+
+// ComparePipeline compares the snapshot of the portable proto of the pipeline
+// against the golden file, as by CompareLines, and fails the test if they
+// differ, so changes to the structure or coders of a pipeline are caught.
+// With --update_golden, it rewrites the golden file instead.
+func ComparePipeline(t *testing.T, p *beam.Pipeline, golden string) {
+	t.Helper()
+	edges, _, err := p.Build()
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	pb, err := graphx.Marshal(edges, &graphx.Options{})
+	if err != nil {
+		t.Fatalf("Failed to marshal pipeline: %v", err)
+	}
+	CompareLines(t, Snapshot(pb), golden)
+}
+
+// Snapshot returns the lines of a diff-friendly form of the portable proto of
+// a pipeline, with a line for each transform, PCollection and requirement.
+// Transforms are named by their unique names, and PCollections by the output
+// of the transform producing them, instead of by their ids, which change with
+// unrelated changes of the pipeline. Coders and windowing strategies are
+// inlined by their URNs. Environments and payloads are left out, as they
+// depend on the binary and platform building the pipeline.
+func Snapshot(p *pipepb.Pipeline) []string {
+	comps := p.GetComponents()
+	names := pcollectionNames(comps)
+
+	var lines []string
+	for _, t := range comps.GetTransforms() {
+		urn := t.GetSpec().GetUrn()
+		// The inputs and outputs of composites are tagged by PCollection ids.
+		tagged := true
+		if len(t.GetSubtransforms()) > 0 {
+			urn, tagged = "composite", false
+		}
+		lines = append(lines, fmt.Sprintf("transform %q: %v inputs=%v outputs=%v",
+			t.GetUniqueName(), urn, formatPorts(t.GetInputs(), names, tagged), formatPorts(t.GetOutputs(), names, tagged)))
+	}
+	for id, c := range comps.GetPcollections() {
+		lines = append(lines, fmt.Sprintf("pcollection %v: coder=%v windowing=%v %v",
+			names[id], formatCoder(comps, c.GetCoderId()), formatWindowing(comps, c.GetWindowingStrategyId()), c.GetIsBounded()))
+	}
+	for _, r := range p.GetRequirements() {
+		lines = append(lines, fmt.Sprintf("requirement %v", r))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// pcollectionNames names the PCollections by the primitive transforms
+// producing them and their output tags.
+func pcollectionNames(comps *pipepb.Components) map[string]string {
+	names := make(map[string]string)
+	for _, t := range comps.GetTransforms() {
+		if len(t.GetSubtransforms()) > 0 {
+			continue
+		}
+		for tag, id := range t.GetOutputs() {
+			names[id] = fmt.Sprintf("%q.%v", t.GetUniqueName(), tag)
+		}
+	}
+	for id := range comps.GetPcollections() {
+		if _, ok := names[id]; !ok {
+			names[id] = fmt.Sprintf("%q", id)
+		}
+	}
+	return names
+}
+
+// formatPorts formats the inputs or outputs of a transform by the names of
+// their PCollections, with their tags, if tagged.
+func formatPorts(ports map[string]string, names map[string]string, tagged bool) string {
+	var ret []string
+	for tag, id := range ports {
+		if tagged {
+			ret = append(ret, fmt.Sprintf("%v:%v", tag, names[id]))
+		} else {
+			ret = append(ret, names[id])
+		}
+	}
+	sort.Strings(ret)
+	return "{" + strings.Join(ret, ", ") + "}"
+}
+
+// formatCoder formats a coder as its URN, followed by its component coders.
+func formatCoder(comps *pipepb.Components, id string) string {
+	c, ok := comps.GetCoders()[id]
+	if !ok {
+		return fmt.Sprintf("<missing coder %v>", id)
+	}
+	urn := c.GetSpec().GetUrn()
+	if len(c.GetComponentCoderIds()) == 0 {
+		return urn
+	}
+	var components []string
+	for _, cid := range c.GetComponentCoderIds() {
+		components = append(components, formatCoder(comps, cid))
+	}
+	return fmt.Sprintf("%v<%v>", urn, strings.Join(components, ", "))
+}
+
+// formatWindowing formats a windowing strategy as the URN of its window fn,
+// followed by the settings that affect the output of transforms.
+func formatWindowing(comps *pipepb.Components, id string) string {
+	ws, ok := comps.GetWindowingStrategies()[id]
+	if !ok {
+		return fmt.Sprintf("<missing windowing strategy %v>", id)
+	}
+	return fmt.Sprintf("%v{coder=%v, merge=%v, accumulation=%v, trigger=%v, allowed_lateness=%v}",
+		ws.GetWindowFn().GetUrn(), formatCoder(comps, ws.GetWindowCoderId()), ws.GetMergeStatus(),
+		ws.GetAccumulationMode(), formatTrigger(ws.GetTrigger()), ws.GetAllowedLateness())
+}
+
+// formatTrigger formats the kind of a trigger, such as "Default", which is the
+// suffix of the type of its oneof, such as Trigger_Default_.
+func formatTrigger(t *pipepb.Trigger) string {
+	kind := strings.TrimSuffix(fmt.Sprintf("%T", t.GetTrigger()), "_")
+	return kind[strings.LastIndex(kind, "_")+1:]
+}
@@ -0,0 +1,134 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package passert
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/funcx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*comparatorFn)(nil)).Elem())
+}
+
+// EqualsWithComparator verifies the given collection has the same values as
+// the given values, under the equality defined by the comparator, eq : T x T
+// -> bool, instead of coder equality. This allows comparing values such as
+// floats within a tolerance, protos, or structs by some of their fields. The
+// values can be provided as single PCollection.
+//
+// Each actual value is matched with an expected value it is equal to, and the
+// assertion fails with a diff of the values that couldn't be matched. The
+// comparator must be registered with beam.RegisterFunction to be used on
+// remote runners. Should only be used for small collections, because all
+// values are held in memory at the same time.
+func EqualsWithComparator(s beam.Scope, col beam.PCollection, eq interface{}, values ...interface{}) beam.PCollection {
+	s = s.Scope("passert.EqualsWithComparator")
+
+	t := beam.ValidateNonCompositeType(col)
+	funcx.MustSatisfy(eq, funcx.Replace(funcx.MakePredicate(beam.TType, beam.TType), beam.TType, t.Type()))
+
+	if len(values) == 0 {
+		return Empty(s, col)
+	}
+	other, ok := values[0].(beam.PCollection)
+	if !ok || len(values) != 1 {
+		other = beam.Create(s, values...)
+	}
+	fn := &comparatorFn{Eq: beam.EncodedFunc{Fn: reflectx.MakeFunc(eq)}}
+	beam.ParDo0(s, fn, beam.Impulse(s), beam.SideInput{Input: col}, beam.SideInput{Input: other})
+	return col
+}
+
+// comparatorFn matches the actual values with the expected ones under the
+// comparator, and fails if some can't be matched.
+type comparatorFn struct {
+	// Eq is the encoded comparator.
+	Eq beam.EncodedFunc `json:"eq"`
+
+	eq reflectx.Func2x1
+}
+
+func (f *comparatorFn) Setup() {
+	f.eq = reflectx.ToFunc2x1(f.Eq.Fn)
+}
+
+func (f *comparatorFn) ProcessElement(_ []byte, actual, expected func(*beam.T) bool) error {
+	var unexpected, missing []interface{}
+	missing = readAll(expected)
+	correct := 0
+	for _, a := range readAll(actual) {
+		i := f.match(a, missing)
+		if i < 0 {
+			unexpected = append(unexpected, a)
+			continue
+		}
+		missing = append(missing[:i], missing[i+1:]...)
+		correct++
+	}
+	if len(unexpected)+len(missing) == 0 {
+		return nil
+	}
+	return errors.New(diffString(correct, unexpected, missing))
+}
+
+// match returns the index of the first of the values that v is equal to,
+// or -1 if there's none.
+func (f *comparatorFn) match(v interface{}, values []interface{}) int {
+	for i, e := range values {
+		if f.eq.Call2x1(v, e).(bool) {
+			return i
+		}
+	}
+	return -1
+}
+
+func readAll(iter func(*beam.T) bool) []interface{} {
+	var out []interface{}
+	var v beam.T
+	for iter(&v) {
+		out = append(out, v)
+	}
+	return out
+}
+
+// diffString returns a failure message in the style of a cmp.Diff, listing
+// the missing values prefixed by "-" and the unexpected ones prefixed by "+".
+// Values are formatted with their field names.
+func diffString(correct int, unexpected, missing []interface{}) string {
+	lines := []string{
+		fmt.Sprintf("actual PCollection does not match expected values, %d correct entries (-want +got):", correct),
+	}
+	lines = append(lines, formatAll("-", missing)...)
+	lines = append(lines, formatAll("+", unexpected)...)
+	return strings.Join(lines, "\n")
+}
+
+func formatAll(prefix string, values []interface{}) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = fmt.Sprintf("\t%s %+v", prefix, v)
+	}
+	sort.Strings(out)
+	return out
+}
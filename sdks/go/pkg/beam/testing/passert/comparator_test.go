@@ -0,0 +1,68 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package passert
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+type point struct {
+	X, Y float64
+	// Label isn't compared.
+	Label string
+}
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*point)(nil)).Elem())
+	beam.RegisterFunction(pointsNear)
+}
+
+func pointsNear(a, b point) bool {
+	return math.Abs(a.X-b.X) < 0.01 && math.Abs(a.Y-b.Y) < 0.01
+}
+
+func TestEqualsWithComparator_Good(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, point{1, 2, "a"}, point{3, 4, "b"}, point{1, 2, "c"})
+
+	EqualsWithComparator(s, col, pointsNear, point{X: 3.001, Y: 4}, point{X: 1, Y: 2}, point{X: 1, Y: 2.001})
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("Pipeline failed: %v", err)
+	}
+}
+
+func TestEqualsWithComparator_Bad(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, point{1, 2, "a"}, point{3, 4, "b"}, point{1, 2, "c"})
+	want := beam.Create(s, point{X: 1, Y: 2}, point{X: 3, Y: 5}, point{X: 1, Y: 2})
+
+	EqualsWithComparator(s, col, pointsNear, want)
+	err := ptest.Run(p)
+	if err == nil {
+		t.Fatalf("Pipeline succeeded when it should have failed")
+	}
+	for _, want := range []string{"2 correct entries (-want +got)", "- {X:3 Y:5 Label:}", "+ {X:3 Y:4 Label:b}"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Pipeline failed with %v, want it to contain %q", err, want)
+		}
+	}
+}
@@ -69,37 +69,56 @@ const (
 // a full list of each unexpected or missing entry.
 // If all the entries are in place, returns nil.
 func failIfBadEntries(_ []byte, unexpected, correct, missing func(*beam.T) bool) error {
+	return checkEntries("actual PCollection does not match expected values", true, true, unexpected, correct, missing)
+}
+
+// checkEntries fails with the given message if there are any entries in the
+// 'unexpected' PCollection, when checked, or in the 'missing' PCollection,
+// when checked. The returned error message contains a full list of each
+// checked unexpected or missing entry.
+func checkEntries(msg string, checkUnexpected, checkMissing bool, unexpected, correct, missing func(*beam.T) bool) error {
 	goodCount := 0
 	var dummy beam.T
 	for correct(&dummy) {
 		goodCount++
 	}
 
-	unexpectedStrings := readToStrings(unexpected)
-	missingStrings := readToStrings(missing)
+	var unexpectedStrings, missingStrings []string
+	if checkUnexpected {
+		unexpectedStrings = readToStrings(unexpected)
+	}
+	if checkMissing {
+		missingStrings = readToStrings(missing)
+	}
 
 	if len(unexpectedStrings)+len(missingStrings) == 0 {
 		// Hooray! No out-of-place entries; the test passes.
 		return nil
 	}
 	outStrings := []string{
-		"actual PCollection does not match expected values",
+		msg,
 		partSeparator,
 		fmt.Sprintf("%d correct entries (present in both)", goodCount),
-		partSeparator,
-		fmt.Sprintf("%d unexpected entries (present in actual, missing in expected)", len(unexpectedStrings)),
 	}
-	for _, entry := range unexpectedStrings {
-		outStrings = append(outStrings, "+++", entry)
+	if checkUnexpected {
+		outStrings = append(
+			outStrings,
+			partSeparator,
+			fmt.Sprintf("%d unexpected entries (present in actual, missing in expected)", len(unexpectedStrings)),
+		)
+		for _, entry := range unexpectedStrings {
+			outStrings = append(outStrings, "+++", entry)
+		}
 	}
-
-	outStrings = append(
-		outStrings,
-		partSeparator,
-		fmt.Sprintf("%d missing entries (missing in actual, present in expected)", len(missingStrings)),
-	)
-	for _, entry := range missingStrings {
-		outStrings = append(outStrings, "---", entry)
+	if checkMissing {
+		outStrings = append(
+			outStrings,
+			partSeparator,
+			fmt.Sprintf("%d missing entries (missing in actual, present in expected)", len(missingStrings)),
+		)
+		for _, entry := range missingStrings {
+			outStrings = append(outStrings, "---", entry)
+		}
 	}
 	return errors.New(strings.Join(outStrings, "\n"))
 }
@@ -17,6 +17,7 @@ package passert
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"sort"
 	"strings"
@@ -143,6 +144,111 @@ func (f *boundsFn) ProcessElement(_ []byte, col func(*beam.T) bool) error {
 	return errors.New(strings.Join(errorStrings, "\n"))
 }
 
+// Tolerance is the tolerance of approximate assertions on numeric values. A
+// value is within the tolerance of an expected value if their difference is at
+// most the Absolute tolerance, or at most the Relative tolerance times the
+// magnitude of the expected value. NaNs are only within the tolerance of NaNs.
+type Tolerance struct {
+	Absolute float64 `json:"absolute,omitempty"`
+	Relative float64 `json:"relative,omitempty"`
+}
+
+// within returns whether v is within the tolerance of want.
+func (t Tolerance) within(v, want float64) bool {
+	if math.IsNaN(v) || math.IsNaN(want) {
+		return math.IsNaN(v) && math.IsNaN(want)
+	}
+	if v == want {
+		return true
+	}
+	delta := math.Abs(v - want)
+	return delta <= t.Absolute || delta <= t.Relative*math.Abs(want)
+}
+
+func (t Tolerance) String() string {
+	return fmt.Sprintf("abs=%v,rel=%v", t.Absolute, t.Relative)
+}
+
+// EqualsApprox checks that two PCollections of non-complex numeric types are
+// equal, with each observed element being within the tolerance of its
+// corresponding expected element. Both PCollections are loaded into memory,
+// sorted, and compared element by element. Panics if the PCollection types are
+// complex or non-numeric.
+func EqualsApprox(s beam.Scope, observed, expected beam.PCollection, tol Tolerance) {
+	for _, col := range []beam.PCollection{observed, expected} {
+		t := beam.ValidateNonCompositeType(col)
+		if err := validateNonComplexNumber(t.Type()); err != nil {
+			panic(fmt.Sprintf("EqualsApprox failed: %v", err))
+		}
+	}
+	s = s.Scope(fmt.Sprintf("passert.EqualsApprox[%v]", tol))
+	beam.ParDo0(s, &approxFn{Tolerance: tol}, beam.Impulse(s), beam.SideInput{Input: observed}, beam.SideInput{Input: expected})
+}
+
+type approxFn struct {
+	Tolerance Tolerance `json:"tolerance"`
+}
+
+func (f *approxFn) ProcessElement(_ []byte, observed, expected func(*beam.T) bool) error {
+	observedValues := readFloats(observed)
+	expectedValues := readFloats(expected)
+	if len(observedValues) != len(expectedValues) {
+		return errors.Errorf("PCollections of different lengths, got %v expected %v", len(observedValues), len(expectedValues))
+	}
+	sort.Float64s(observedValues)
+	sort.Float64s(expectedValues)
+	var outside []string
+	for i, v := range observedValues {
+		if !f.Tolerance.within(v, expectedValues[i]) {
+			outside = append(outside, fmt.Sprintf("%v != %v,", v, expectedValues[i]))
+		}
+	}
+	if len(outside) == 0 {
+		return nil
+	}
+	return errors.Errorf("values not within tolerance %v of expected: %v", f.Tolerance, outside)
+}
+
+// AllWithinTolerance checks that all the elements of a PCollection of
+// non-complex numeric types are within the tolerance of the given value.
+// Panics if the PCollection type is complex or non-numeric.
+func AllWithinTolerance(s beam.Scope, col beam.PCollection, want float64, tol Tolerance) {
+	t := beam.ValidateNonCompositeType(col)
+	if err := validateNonComplexNumber(t.Type()); err != nil {
+		panic(fmt.Sprintf("AllWithinTolerance failed: %v", err))
+	}
+	s = s.Scope(fmt.Sprintf("passert.AllWithinTolerance(%v[%v])", want, tol))
+	beam.ParDo0(s, &toleranceFn{Want: want, Tolerance: tol}, beam.Impulse(s), beam.SideInput{Input: col})
+}
+
+type toleranceFn struct {
+	Want      float64   `json:"want"`
+	Tolerance Tolerance `json:"tolerance"`
+}
+
+func (f *toleranceFn) ProcessElement(_ []byte, col func(*beam.T) bool) error {
+	var outside []float64
+	for _, v := range readFloats(col) {
+		if !f.Tolerance.within(v, f.Want) {
+			outside = append(outside, v)
+		}
+	}
+	if len(outside) == 0 {
+		return nil
+	}
+	sort.Float64s(outside)
+	return errors.Errorf("values not within tolerance %v of %v: %v", f.Tolerance, f.Want, outside)
+}
+
+func readFloats(iter func(*beam.T) bool) []float64 {
+	var out []float64
+	var input beam.T
+	for iter(&input) {
+		out = append(out, toFloat(input))
+	}
+	return out
+}
+
 func toFloat(input beam.T) float64 {
 	return reflect.ValueOf(input.(interface{})).Convert(reflectx.Float64).Interface().(float64)
 }
@@ -16,6 +16,7 @@
 package passert
 
 import (
+	"math"
 	"strings"
 	"testing"
 
@@ -321,3 +322,92 @@ func TestAllWithinBounds_BadInts(t *testing.T) {
 		}
 	}
 }
+
+func TestEqualsApprox_Good(t *testing.T) {
+	var tests = []struct {
+		name     string
+		observed []float64
+		expected []float64
+		tol      Tolerance
+	}{
+		{"absolute", []float64{1.1996, 4.60002, 3.79}, []float64{1.2, 4.6, 3.79}, Tolerance{Absolute: 0.001}},
+		{"relative", []float64{1001, 0.9995}, []float64{1000, 1}, Tolerance{Relative: 0.001}},
+		{"nan", []float64{math.NaN(), 2}, []float64{2, math.NaN()}, Tolerance{}},
+	}
+	for _, tc := range tests {
+		p, s := beam.NewPipelineWithRoot()
+		observed := beam.CreateList(s, tc.observed)
+		expected := beam.CreateList(s, tc.expected)
+		EqualsApprox(s, observed, expected, tc.tol)
+		if err := ptest.Run(p); err != nil {
+			t.Errorf("%v: Pipeline failed: %v", tc.name, err)
+		}
+	}
+}
+
+func TestEqualsApprox_Bad(t *testing.T) {
+	var tests = []struct {
+		name       string
+		observed   []float64
+		expected   []float64
+		tol        Tolerance
+		errorParts []string
+	}{
+		{
+			"length mismatch",
+			[]float64{1.2, 3.4},
+			[]float64{1.2, 3.4, 5.6},
+			Tolerance{Absolute: 0.001},
+			[]string{"PCollections of different lengths", "got 2", "expected 3"},
+		},
+		{
+			"outside relative",
+			[]float64{1002, 0.5},
+			[]float64{1000, 0.5},
+			Tolerance{Relative: 0.001},
+			[]string{"not within tolerance abs=0,rel=0.001", "1002 != 1000"},
+		},
+		{
+			"nan",
+			[]float64{math.NaN()},
+			[]float64{1},
+			Tolerance{Absolute: 1},
+			[]string{"NaN != 1"},
+		},
+	}
+	for _, tc := range tests {
+		p, s := beam.NewPipelineWithRoot()
+		observed := beam.CreateList(s, tc.observed)
+		expected := beam.CreateList(s, tc.expected)
+		EqualsApprox(s, observed, expected, tc.tol)
+		err := ptest.Run(p)
+		if err == nil {
+			t.Fatalf("%v: Pipeline succeeded but should have failed", tc.name)
+		}
+		for _, part := range tc.errorParts {
+			if !strings.Contains(err.Error(), part) {
+				t.Errorf("%v: error message %v does not contain %q", tc.name, err, part)
+			}
+		}
+	}
+}
+
+func TestAllWithinTolerance(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, 9.99, 10.0, 10.01)
+	AllWithinTolerance(s, col, 10, Tolerance{Absolute: 0.02})
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("Pipeline failed: %v", err)
+	}
+
+	p, s = beam.NewPipelineWithRoot()
+	col = beam.Create(s, 9, 10, 12)
+	AllWithinTolerance(s, col, 10, Tolerance{Relative: 0.1})
+	err := ptest.Run(p)
+	if err == nil {
+		t.Fatalf("Pipeline succeeded but should have failed")
+	}
+	if want := "values not within tolerance abs=0,rel=0.1 of 10: [12]"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error message %v does not contain %q", err, want)
+	}
+}
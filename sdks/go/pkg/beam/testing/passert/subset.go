@@ -0,0 +1,91 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package passert
+
+import (
+	"reflect"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*subsetFn)(nil)).Elem())
+}
+
+// Subset verifies that the values of the given collection are all among the
+// given values, under coder equality, which may hold values the collection
+// doesn't. Duplicates count, so a value may only be in the collection as many
+// times as it is in the given values. The values can be provided as single
+// PCollection.
+func Subset(s beam.Scope, col beam.PCollection, values ...interface{}) beam.PCollection {
+	subScope := s.Scope("passert.Subset")
+	if len(values) == 0 {
+		return Empty(subScope, col)
+	}
+	contains(subScope, col, collectionOf(subScope, values), true, false)
+	return col
+}
+
+// Superset verifies that the given collection holds all the given values,
+// under coder equality, in any order, and possibly other values too.
+// Duplicates count, so a value must be in the collection at least as many
+// times as it is in the given values. The values can be provided as single
+// PCollection.
+func Superset(s beam.Scope, col beam.PCollection, values ...interface{}) beam.PCollection {
+	subScope := s.Scope("passert.Superset")
+	if len(values) == 0 {
+		return col
+	}
+	contains(subScope, col, collectionOf(subScope, values), false, true)
+	return col
+}
+
+// EmptyInWindow asserts that col has no elements in the given window, which
+// must be a global or interval window.
+func EmptyInWindow(s beam.Scope, col beam.PCollection, w beam.Window) beam.PCollection {
+	Empty(s, InWindow(s, col, w))
+	return col
+}
+
+// collectionOf returns the values as a PCollection, unless they are a single
+// PCollection.
+func collectionOf(s beam.Scope, values []interface{}) beam.PCollection {
+	if other, ok := values[0].(beam.PCollection); ok && len(values) == 1 {
+		return other
+	}
+	return beam.Create(s, values...)
+}
+
+// contains verifies that the actual values have no unexpected entries, or no
+// missing entries, compared to the expected ones.
+func contains(s beam.Scope, actual, expected beam.PCollection, checkUnexpected, checkMissing bool) {
+	unexpected, correct, missing := Diff(s, actual, expected)
+	fn := &subsetFn{CheckUnexpected: checkUnexpected, CheckMissing: checkMissing}
+	beam.ParDo0(s, fn, beam.Impulse(s), beam.SideInput{Input: unexpected}, beam.SideInput{Input: correct}, beam.SideInput{Input: missing})
+}
+
+type subsetFn struct {
+	CheckUnexpected bool `json:"checkUnexpected,omitempty"`
+	CheckMissing    bool `json:"checkMissing,omitempty"`
+}
+
+func (f *subsetFn) ProcessElement(_ []byte, unexpected, correct, missing func(*beam.T) bool) error {
+	msg := "actual PCollection is not a superset of expected values"
+	if f.CheckUnexpected {
+		msg = "actual PCollection is not a subset of expected values"
+	}
+	return checkEntries(msg, f.CheckUnexpected, f.CheckMissing, unexpected, correct, missing)
+}
@@ -0,0 +1,115 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package passert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+func TestSubset(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, "a", "b", "b")
+	Subset(s, col, "a", "b", "b", "c")
+	Subset(s, col, beam.Create(s, "c", "b", "a", "b"))
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("Pipeline failed: %v", err)
+	}
+}
+
+func TestSuperset(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, "a", "b", "b", "c")
+	Superset(s, col, "b", "a")
+	Superset(s, col, "b", "b")
+	Superset(s, col)
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("Pipeline failed: %v", err)
+	}
+}
+
+func TestSubsetSuperset_Bad(t *testing.T) {
+	tests := []struct {
+		name       string
+		assert     func(s beam.Scope, col beam.PCollection, values ...interface{}) beam.PCollection
+		actual     []interface{}
+		expected   []interface{}
+		errorParts []string
+	}{
+		{
+			"subset with extra entry",
+			Subset,
+			[]interface{}{"a", "EXTRA"},
+			[]interface{}{"a", "b"},
+			[]string{"not a subset", "1 correct entries", "1 unexpected entries", "EXTRA"},
+		},
+		{
+			"subset with duplicate",
+			Subset,
+			[]interface{}{"a", "a"},
+			[]interface{}{"a", "b"},
+			[]string{"not a subset", "1 unexpected entries"},
+		},
+		{
+			"superset with missing entry",
+			Superset,
+			[]interface{}{"a", "c"},
+			[]interface{}{"a", "MISSING"},
+			[]string{"not a superset", "1 missing entries", "MISSING"},
+		},
+	}
+	for _, tc := range tests {
+		p, s := beam.NewPipelineWithRoot()
+		tc.assert(s, beam.Create(s, tc.actual...), tc.expected...)
+		err := ptest.Run(p)
+		if err == nil {
+			t.Fatalf("%v: Pipeline succeeded when it should have failed", tc.name)
+		}
+		for _, part := range tc.errorParts {
+			if !strings.Contains(err.Error(), part) {
+				t.Errorf("%v: error message %v does not contain %q", tc.name, err, part)
+			}
+		}
+	}
+}
+
+func TestEmptyInWindow(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, timestampSeconds, beam.Create(s, 1, 130))
+	windowed := beam.WindowInto(s, window.NewFixedWindows(time.Minute), col)
+	EmptyInWindow(s, windowed, minute(1))
+	Superset(s, InWindow(s, windowed, minute(2)), 130)
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("Pipeline failed: %v", err)
+	}
+
+	p, s = beam.NewPipelineWithRoot()
+	col = beam.ParDo(s, timestampSeconds, beam.Create(s, 1, 130))
+	windowed = beam.WindowInto(s, window.NewFixedWindows(time.Minute), col)
+	EmptyInWindow(s, windowed, minute(2))
+	err := ptest.Run(p)
+	if err == nil {
+		t.Fatalf("Pipeline succeeded when it should have failed")
+	}
+	if !strings.Contains(err.Error(), "PCollection contains 130, want empty collection") {
+		t.Errorf("Pipeline failed with %v, want it to report the element in the window", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package passert
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*inWindowFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*inWindowKVFn)(nil)).Elem())
+}
+
+// InWindow returns the elements of col that are in the given window,
+// re-windowed into the global window, so the contents of a single window can
+// be verified with any assertion. For example:
+//
+//	w := window.IntervalWindow{Start: 0, End: mtime.FromMilliseconds(60000)}
+//	passert.Equals(s, passert.InWindow(s, col, w), "a", "b")
+//
+// verifies that the first minute of col holds "a" and "b". Elements in several
+// windows, such as sliding windows, are in each of them. The window must be
+// a global or interval window.
+//
+// Pane information isn't propagated by the SDK, so the contents of a window
+// are those of all its panes.
+func InWindow(s beam.Scope, col beam.PCollection, w beam.Window) beam.PCollection {
+	s = s.Scope(fmt.Sprintf("passert.InWindow(%v)", w))
+
+	var match windowMatch
+	switch w := w.(type) {
+	case window.GlobalWindow:
+		match.Global = true
+	case window.IntervalWindow:
+		match.Start, match.End = w.Start, w.End
+	default:
+		panic(fmt.Sprintf("passert.InWindow: unsupported window %v of type %T", w, w))
+	}
+
+	var filtered beam.PCollection
+	switch {
+	case typex.IsKV(col.Type()):
+		filtered = beam.ParDo(s, &inWindowKVFn{Window: match}, col)
+	case typex.IsCoGBK(col.Type()):
+		panic(fmt.Sprintf("passert.InWindow: unsupported CoGBK collection %v", col))
+	default:
+		filtered = beam.ParDo(s, &inWindowFn{Window: match}, col)
+	}
+	return beam.WindowInto(s, window.NewGlobalWindows(), filtered)
+}
+
+// windowMatch is a serializable global or interval window.
+type windowMatch struct {
+	Global bool       `json:"global,omitempty"`
+	Start  mtime.Time `json:"start,omitempty"`
+	End    mtime.Time `json:"end,omitempty"`
+}
+
+func (m windowMatch) matches(w typex.Window) bool {
+	if m.Global {
+		return window.GlobalWindow{}.Equals(w)
+	}
+	return window.IntervalWindow{Start: m.Start, End: m.End}.Equals(w)
+}
+
+type inWindowFn struct {
+	Window windowMatch `json:"window"`
+}
+
+func (f *inWindowFn) ProcessElement(w beam.Window, x beam.X, emit func(beam.X)) {
+	if f.Window.matches(w) {
+		emit(x)
+	}
+}
+
+type inWindowKVFn struct {
+	Window windowMatch `json:"window"`
+}
+
+func (f *inWindowKVFn) ProcessElement(w beam.Window, x beam.X, y beam.Y, emit func(beam.X, beam.Y)) {
+	if f.Window.matches(w) {
+		emit(x, y)
+	}
+}
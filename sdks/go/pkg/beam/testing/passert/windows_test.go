@@ -0,0 +1,83 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package passert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+// timestampSeconds uses the value of the elements as their timestamp, in seconds.
+func timestampSeconds(v int) (beam.EventTime, int) {
+	return mtime.FromMilliseconds(int64(v) * 1000), v
+}
+
+func timestampSecondsKV(k string, v int) (beam.EventTime, string, int) {
+	return mtime.FromMilliseconds(int64(v) * 1000), k, v
+}
+
+func minute(i int64) window.IntervalWindow {
+	return window.IntervalWindow{Start: mtime.FromMilliseconds(i * 60000), End: mtime.FromMilliseconds((i + 1) * 60000)}
+}
+
+func TestInWindow(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, timestampSeconds, beam.Create(s, 1, 30, 59, 60, 100, 130))
+	windowed := beam.WindowInto(s, window.NewFixedWindows(time.Minute), col)
+
+	Equals(s, InWindow(s, windowed, minute(0)), 1, 30, 59)
+	Equals(s, InWindow(s, windowed, minute(1)), 60, 100)
+	Equals(s, InWindow(s, windowed, minute(2)), 130)
+	Empty(s, InWindow(s, windowed, minute(3)))
+	// The elements of the global window are none of the windowed ones.
+	Empty(s, InWindow(s, windowed, window.GlobalWindow{}))
+	Count(s, InWindow(s, col, window.GlobalWindow{}), "global", 6)
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("Pipeline failed: %v", err)
+	}
+}
+
+func TestInWindow_KV(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, timestampSecondsKV, beam.ParDo(s, func(v int) (string, int) { return "k", v }, beam.Create(s, 1, 70)))
+	windowed := beam.WindowInto(s, window.NewFixedWindows(time.Minute), col)
+
+	Count(s, InWindow(s, windowed, minute(1)), "minute 1", 1)
+	if err := ptest.Run(p); err != nil {
+		t.Errorf("Pipeline failed: %v", err)
+	}
+}
+
+func TestInWindow_Bad(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, timestampSeconds, beam.Create(s, 1, 70))
+	windowed := beam.WindowInto(s, window.NewFixedWindows(time.Minute), col)
+
+	Equals(s, InWindow(s, windowed, minute(0)), 1, 70)
+	err := ptest.Run(p)
+	if err == nil {
+		t.Fatalf("Pipeline succeeded when it should have failed")
+	}
+	if !strings.Contains(err.Error(), "1 missing entries") {
+		t.Errorf("Pipeline failed with %v, want it to report the missing entry", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptest
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+)
+
+// Runners is a flag that sets the runners that pipeline tests using RunMatrix
+// run on. Each runner must be imported by the test.
+//
+// For example:
+//
+//	go test ./... --runners=direct,flink --endpoint=localhost:8099
+var Runners = flag.String("runners", "", "Comma-separated list of runners to run pipeline tests using RunMatrix on, by default the runner of --runner (optional).")
+
+// Feature is a feature of the model that a pipeline test needs, and that
+// runners may not support.
+type Feature string
+
+const (
+	// TestStream is the TestStream primitive.
+	TestStream Feature = "TestStream"
+	// CrossLanguage is running transforms of other SDKs.
+	CrossLanguage Feature = "CrossLanguage"
+	// Splittable is splitting the restrictions of splittable DoFns at runtime.
+	Splittable Feature = "Splittable"
+	// Streaming is running unbounded pipelines.
+	Streaming Feature = "Streaming"
+)
+
+// unsupported are the features that runners don't support, by runner name.
+var unsupported = map[string][]Feature{
+	"direct":   {CrossLanguage, Splittable},
+	"prism":    {TestStream, CrossLanguage, Splittable, Streaming},
+	"portable": {TestStream},
+	"samza":    {TestStream},
+	"spark":    {TestStream, CrossLanguage},
+	"dataflow": {TestStream},
+}
+
+// RegisterUnsupported registers features that the runner doesn't support,
+// so pipeline tests that need them are skipped on it. It must be called
+// before the tests run, such as in TestMain.
+func RegisterUnsupported(runner string, features ...Feature) {
+	runner = runnerName(runner)
+	unsupported[runner] = append(unsupported[runner], features...)
+}
+
+// runnerName returns the short name of a runner, such as "flink" for
+// "FlinkRunner".
+func runnerName(runner string) string {
+	return strings.TrimSuffix(strings.ToLower(runner), "runner")
+}
+
+// MatrixRunners returns the runners that RunMatrix runs pipeline tests on.
+func MatrixRunners() []string {
+	var runners []string
+	for _, r := range strings.Split(*Runners, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			runners = append(runners, r)
+		}
+	}
+	if len(runners) > 0 {
+		return runners
+	}
+	if *Runner != "" {
+		return []string{*Runner}
+	}
+	return []string{defaultRunner}
+}
+
+// missing returns the features that the runner doesn't support.
+func missing(runner string, features []Feature) []Feature {
+	var ret []Feature
+	for _, f := range features {
+		for _, u := range unsupported[runnerName(runner)] {
+			if f == u {
+				ret = append(ret, f)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// RunMatrix runs a pipeline test on each runner of MatrixRunners, as a subtest
+// named after the runner. The pipeline is built anew for each runner by the
+// given function, and is expected to be verified through passert. Runners that
+// don't support all the given features skip the test.
+//
+// For example:
+//
+//	func TestReshuffle(t *testing.T) {
+//		ptest.RunMatrix(t, func(s beam.Scope) {
+//			col := beam.Reshuffle(s, beam.Create(s, 1, 2, 3))
+//			passert.Equals(s, col, 1, 2, 3)
+//		})
+//	}
+func RunMatrix(t *testing.T, build func(s beam.Scope), features ...Feature) {
+	t.Helper()
+	for _, runner := range MatrixRunners() {
+		runner := runner
+		t.Run(runner, func(t *testing.T) {
+			if m := missing(runner, features); len(m) > 0 {
+				t.Skipf("runner %v doesn't support %v", runner, m)
+			}
+			p, s := beam.NewPipelineWithRoot()
+			build(s)
+			if _, err := beam.Run(context.Background(), runner, p); err != nil {
+				t.Fatalf("Failed to execute job on %v: %v", runner, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptest
+
+import (
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatrixRunners(t *testing.T) {
+	defer func(runners, runner string) { *Runners, *Runner = runners, runner }(*Runners, *Runner)
+
+	tests := []struct {
+		runners, runner string
+		want            []string
+	}{
+		{"", "", []string{"direct"}},
+		{"", "flink", []string{"flink"}},
+		{"direct, flink,,dataflow", "flink", []string{"direct", "flink", "dataflow"}},
+	}
+	for _, test := range tests {
+		*Runners, *Runner = test.runners, test.runner
+		if diff := cmp.Diff(test.want, MatrixRunners()); diff != "" {
+			t.Errorf("MatrixRunners() with --runners=%q --runner=%q (-want +got):\n%v", test.runners, test.runner, diff)
+		}
+	}
+}
+
+func TestMissing(t *testing.T) {
+	RegisterUnsupported("TestRunner", Streaming)
+
+	tests := []struct {
+		runner   string
+		features []Feature
+		want     []Feature
+	}{
+		{"direct", nil, nil},
+		{"direct", []Feature{TestStream, CrossLanguage}, []Feature{CrossLanguage}},
+		{"DirectRunner", []Feature{TestStream, Streaming}, nil},
+		{"prism", []Feature{TestStream, Splittable}, []Feature{TestStream, Splittable}},
+		{"flink", []Feature{TestStream, CrossLanguage}, nil},
+		{"test", []Feature{TestStream, Streaming}, []Feature{Streaming}},
+	}
+	for _, test := range tests {
+		if diff := cmp.Diff(test.want, missing(test.runner, test.features)); diff != "" {
+			t.Errorf("missing(%v, %v) (-want +got):\n%v", test.runner, test.features, diff)
+		}
+	}
+}
+
+func TestRunMatrix(t *testing.T) {
+	built := 0
+	RunMatrix(t, func(s beam.Scope) {
+		built++
+		beam.Create(s, 1, 2, 3)
+	})
+	RunMatrix(t, func(s beam.Scope) {
+		t.Errorf("pipeline built for a runner without cross-language support")
+	}, CrossLanguage)
+	if built != 1 {
+		t.Errorf("pipeline built %d times, want once", built)
+	}
+}
@@ -0,0 +1,204 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// MetricAssertion is an expectation of the metrics of a pipeline run, which
+// is checked by RunAndAssertMetrics. The values of a metric are aggregated
+// across the steps that report it, unless the assertion is restricted to
+// some steps by InSteps.
+type MetricAssertion struct {
+	filter metrics.Filter
+	desc   string
+	check  func(metrics.QueryResults) error
+}
+
+// InSteps returns the assertion restricted to the metrics of the given steps,
+// which select metrics as the steps of a metrics.Filter do.
+func (a MetricAssertion) InSteps(steps ...string) MetricAssertion {
+	a.filter.Steps = steps
+	return a
+}
+
+func (a MetricAssertion) String() string {
+	if len(a.filter.Steps) == 0 {
+		return fmt.Sprintf("%v %v.%v", a.desc, a.filter.Namespace, a.filter.Name)
+	}
+	return fmt.Sprintf("%v %v.%v in %v", a.desc, a.filter.Namespace, a.filter.Name, a.filter.Steps)
+}
+
+// Check checks the assertion against the metrics of a run.
+func (a MetricAssertion) Check(res metrics.Results) error {
+	if err := a.check(res.Query(a.filter)); err != nil {
+		return errors.WithContextf(err, "checking %v", a)
+	}
+	return nil
+}
+
+// Counter asserts that the counter has the given value.
+func Counter(namespace, name string, want int64) MetricAssertion {
+	return counterAssertion(namespace, name, "counter", func(got int64) bool { return got == want }, fmt.Sprintf("%d", want))
+}
+
+// CounterAtLeast asserts that the counter has at least the given value, for
+// counters of work that runners may retry.
+func CounterAtLeast(namespace, name string, min int64) MetricAssertion {
+	return counterAssertion(namespace, name, "counter", func(got int64) bool { return got >= min }, fmt.Sprintf("at least %d", min))
+}
+
+func counterAssertion(namespace, name, desc string, ok func(int64) bool, want string) MetricAssertion {
+	return MetricAssertion{
+		filter: metrics.Filter{Namespace: namespace, Name: name},
+		desc:   desc,
+		check: func(qr metrics.QueryResults) error {
+			cs := qr.Counters()
+			if len(cs) == 0 {
+				return errors.New("no counter reported")
+			}
+			var got int64
+			for _, c := range cs {
+				got += c.Result()
+			}
+			if !ok(got) {
+				return errors.Errorf("got %d, want %v", got, want)
+			}
+			return nil
+		},
+	}
+}
+
+// Distribution asserts that the distribution has the given value.
+func Distribution(namespace, name string, want metrics.DistributionValue) MetricAssertion {
+	return MetricAssertion{
+		filter: metrics.Filter{Namespace: namespace, Name: name},
+		desc:   "distribution",
+		check: func(qr metrics.QueryResults) error {
+			ds := qr.Distributions()
+			if len(ds) == 0 {
+				return errors.New("no distribution reported")
+			}
+			got := ds[0].Result()
+			for _, d := range ds[1:] {
+				v := d.Result()
+				got.Count += v.Count
+				got.Sum += v.Sum
+				if v.Min < got.Min {
+					got.Min = v.Min
+				}
+				if v.Max > got.Max {
+					got.Max = v.Max
+				}
+			}
+			if got != want {
+				return errors.Errorf("got %+v, want %+v", got, want)
+			}
+			return nil
+		},
+	}
+}
+
+// Gauge asserts that the most recently set value of the gauge is the given
+// value.
+func Gauge(namespace, name string, want int64) MetricAssertion {
+	return MetricAssertion{
+		filter: metrics.Filter{Namespace: namespace, Name: name},
+		desc:   "gauge",
+		check: func(qr metrics.QueryResults) error {
+			gs := qr.Gauges()
+			if len(gs) == 0 {
+				return errors.New("no gauge reported")
+			}
+			got := gs[0].Result()
+			for _, g := range gs[1:] {
+				if v := g.Result(); v.Timestamp.After(got.Timestamp) {
+					got = v
+				}
+			}
+			if got.Value != want {
+				return errors.Errorf("got %d, want %d", got.Value, want)
+			}
+			return nil
+		},
+	}
+}
+
+// NoMetric asserts that no metric with the name is reported, such as the
+// counter of dead letters of a pipeline without failures.
+func NoMetric(namespace, name string) MetricAssertion {
+	return MetricAssertion{
+		filter: metrics.Filter{Namespace: namespace, Name: name},
+		desc:   "no metric",
+		check: func(qr metrics.QueryResults) error {
+			n := len(qr.Counters()) + len(qr.Distributions()) + len(qr.Gauges()) +
+				len(qr.StringSets()) + len(qr.Histograms()) + len(qr.BoundedTries())
+			for _, c := range qr.Counters() {
+				if c.Result() == 0 {
+					// Counters that were never incremented are as good as absent.
+					n--
+				}
+			}
+			if n != 0 {
+				return errors.Errorf("got %d metrics, want none", n)
+			}
+			return nil
+		},
+	}
+}
+
+// RunWithMetrics runs a pipeline for testing, and returns the metrics of the
+// run. It fails if the runner doesn't report metrics.
+func RunWithMetrics(p *beam.Pipeline) (metrics.Results, error) {
+	if *Runner == "" {
+		*Runner = defaultRunner
+	}
+	pr, err := beam.Run(context.Background(), *Runner, p)
+	if err != nil {
+		return metrics.Results{}, err
+	}
+	if pr == nil {
+		return metrics.Results{}, errors.Errorf("runner %v doesn't report metrics", *Runner)
+	}
+	return pr.Metrics(), nil
+}
+
+// RunAndAssertMetrics runs a pipeline for testing and checks the metrics of
+// the run against the assertions, failing the test if the pipeline fails or
+// any assertion doesn't hold. For example:
+//
+//	ptest.RunAndAssertMetrics(t, p,
+//		ptest.Counter("mypipeline", "deadLetters", 2),
+//		ptest.CounterAtLeast("mypipeline", "cacheHits", 10).InSteps("Lookup"))
+func RunAndAssertMetrics(t *testing.T, p *beam.Pipeline, assertions ...MetricAssertion) {
+	t.Helper()
+	res, err := RunWithMetrics(p)
+	if err != nil {
+		t.Fatalf("Failed to execute job: %v", err)
+	}
+	for _, a := range assertions {
+		if err := a.Check(res); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ptest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+)
+
+func init() {
+	beam.RegisterFunction(countEvensFn)
+}
+
+var (
+	evens   = beam.NewCounter("ptest", "evens")
+	lengths = beam.NewDistribution("ptest", "lengths")
+)
+
+func countEvensFn(ctx context.Context, v int) {
+	if v%2 == 0 {
+		evens.Inc(ctx, 1)
+	}
+	lengths.Update(ctx, int64(v))
+}
+
+func TestMetricAssertion_Check(t *testing.T) {
+	now := time.Now()
+	res := metrics.NewResults(
+		[]metrics.CounterResult{
+			{Attempted: 2, Key: metrics.StepKey{Step: "A/a", Namespace: "ns", Name: "c"}},
+			{Attempted: 3, Key: metrics.StepKey{Step: "B/b", Namespace: "ns", Name: "c"}},
+			{Attempted: 0, Key: metrics.StepKey{Step: "B/b", Namespace: "ns", Name: "zero"}},
+		},
+		[]metrics.DistributionResult{
+			{Attempted: metrics.DistributionValue{Count: 2, Sum: 5, Min: 1, Max: 4}, Key: metrics.StepKey{Step: "A/a", Namespace: "ns", Name: "d"}},
+			{Attempted: metrics.DistributionValue{Count: 1, Sum: 7, Min: 7, Max: 7}, Key: metrics.StepKey{Step: "B/b", Namespace: "ns", Name: "d"}},
+		},
+		[]metrics.GaugeResult{
+			{Attempted: metrics.GaugeValue{Value: 1, Timestamp: now}, Key: metrics.StepKey{Step: "A/a", Namespace: "ns", Name: "g"}},
+			{Attempted: metrics.GaugeValue{Value: 2, Timestamp: now.Add(time.Second)}, Key: metrics.StepKey{Step: "B/b", Namespace: "ns", Name: "g"}},
+		})
+
+	tests := []struct {
+		assertion MetricAssertion
+		err       string
+	}{
+		{Counter("ns", "c", 5), ""},
+		{Counter("ns", "c", 2).InSteps("A"), ""},
+		{Counter("ns", "c", 4), "got 5, want 4"},
+		{Counter("ns", "missing", 0), "no counter reported"},
+		{CounterAtLeast("ns", "c", 4), ""},
+		{CounterAtLeast("ns", "c", 6), "got 5, want at least 6"},
+		{Distribution("ns", "d", metrics.DistributionValue{Count: 3, Sum: 12, Min: 1, Max: 7}), ""},
+		{Distribution("ns", "d", metrics.DistributionValue{Count: 1, Sum: 7, Min: 7, Max: 7}).InSteps("b"), ""},
+		{Distribution("ns", "d", metrics.DistributionValue{Count: 3}), "want {Count:3"},
+		{Gauge("ns", "g", 2), ""},
+		{Gauge("ns", "g", 1).InSteps("A/a"), ""},
+		{Gauge("ns", "g", 1), "got 2, want 1"},
+		{NoMetric("ns", "zero"), ""},
+		{NoMetric("ns", "missing"), ""},
+		{NoMetric("ns", "d"), "got 2 metrics, want none"},
+	}
+	for _, test := range tests {
+		err := test.assertion.Check(*res)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%v.Check() failed: %v", test.assertion, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%v.Check() succeeded, want error containing %q", test.assertion, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%v.Check() failed with %v, want error containing %q", test.assertion, err, test.err)
+		}
+	}
+}
+
+func TestRunAndAssertMetrics(t *testing.T) {
+	p, s, col := Create([]interface{}{1, 2, 3, 4, 6})
+	beam.ParDo0(s, countEvensFn, col)
+
+	RunAndAssertMetrics(t, p,
+		Counter("ptest", "evens", 3),
+		Distribution("ptest", "lengths", metrics.DistributionValue{Count: 5, Sum: 16, Min: 1, Max: 6}),
+		NoMetric("ptest", "odds"))
+}
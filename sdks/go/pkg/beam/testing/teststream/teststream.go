@@ -18,7 +18,8 @@
 //
 // See https://beam.apache.org/blog/test-stream/ for more information.
 //
-// TestStream is supported on the Flink runner.
+// TestStream is supported on the Flink runner, and on the direct runner in
+// streaming mode.
 package teststream
 
 import (
@@ -106,25 +107,46 @@ func (c *Config) AdvanceProcessingTimeToInfinity() {
 // On the first call, a type will be inferred from the passed in elements, which must be of all the same type.
 // Type mismatches on this or subsequent calls will cause AddElements to return an error.
 func (c *Config) AddElements(timestamp int64, elements ...interface{}) error {
-	t := reflect.TypeOf(elements[0])
+	tes := make([]TimestampedElement, len(elements))
+	for i, e := range elements {
+		tes[i] = TimestampedElement{Element: e, Timestamp: timestamp}
+	}
+	return c.AddTimestampedElements(tes...)
+}
+
+// TimestampedElement is an element of a test stream with its event timestamp, in milliseconds.
+type TimestampedElement struct {
+	Element   interface{}
+	Timestamp int64
+}
+
+// AddTimestampedElements adds a number of elements to the stream, each at its own event timestamp, in
+// a single event. Must be called with at least one element.
+//
+// The element types are inferred and checked as with AddElements.
+func (c *Config) AddTimestampedElements(elements ...TimestampedElement) error {
+	if len(elements) == 0 {
+		return fmt.Errorf("no elements to add")
+	}
+	t := reflect.TypeOf(elements[0].Element)
 	if c.elmType == nil {
 		c.elmType = typex.New(t)
 	} else if c.elmType.Type() != t {
 		return fmt.Errorf("element type mismatch, previous additions were of type %v, tried to add type %v", c.elmType, t)
 	}
 	for i, ele := range elements {
-		if reflect.TypeOf(ele) != c.elmType.Type() {
-			return fmt.Errorf("element %d was type %T, previous additions were of type %v", i, ele, c.elmType)
+		if reflect.TypeOf(ele.Element) != c.elmType.Type() {
+			return fmt.Errorf("element %d was type %T, previous additions were of type %v", i, ele.Element, c.elmType)
 		}
 	}
 	newElements := []*pipepb.TestStreamPayload_TimestampedElement{}
 	enc := beam.NewElementEncoder(t)
 	for _, e := range elements {
 		var buf bytes.Buffer
-		if err := enc.Encode(e, &buf); err != nil {
-			return fmt.Errorf("encoding value %v failed, got %v", e, err)
+		if err := enc.Encode(e.Element, &buf); err != nil {
+			return fmt.Errorf("encoding value %v failed, got %v", e.Element, err)
 		}
-		newElements = append(newElements, &pipepb.TestStreamPayload_TimestampedElement{EncodedElement: buf.Bytes(), Timestamp: timestamp})
+		newElements = append(newElements, &pipepb.TestStreamPayload_TimestampedElement{EncodedElement: buf.Bytes(), Timestamp: e.Timestamp})
 	}
 	addElementsEvent := &pipepb.TestStreamPayload_Event_AddElements{Elements: newElements}
 	elementEvent := &pipepb.TestStreamPayload_Event_ElementEvent{ElementEvent: addElementsEvent}
@@ -114,3 +114,41 @@ func TestAddElements(t *testing.T) {
 		}
 	}
 }
+
+func TestAddTimestampedElements(t *testing.T) {
+	con := NewConfig()
+	err := con.AddTimestampedElements(
+		TimestampedElement{Element: "a", Timestamp: 100},
+		TimestampedElement{Element: "b", Timestamp: 200})
+	if err != nil {
+		t.Fatalf("AddTimestampedElements failed: %v", err)
+	}
+	if len(con.events) != 1 {
+		t.Fatalf("want only 1 event in config, got %v", len(con.events))
+	}
+	got := con.events[0].GetElementEvent().GetElements()
+	if len(got) != 2 {
+		t.Fatalf("want 2 elements in event, got %v", len(got))
+	}
+	for i, want := range []int64{100, 200} {
+		if ts := got[i].GetTimestamp(); ts != want {
+			t.Errorf("element %d has timestamp %v, want %v", i, ts, want)
+		}
+	}
+}
+
+func TestAddElements_Bad(t *testing.T) {
+	con := NewConfig()
+	if err := con.AddElements(100); err == nil {
+		t.Errorf("AddElements with no elements succeeded when it should have failed")
+	}
+	if err := con.AddElements(100, "a"); err != nil {
+		t.Fatalf("AddElements(a) failed: %v", err)
+	}
+	if err := con.AddElements(100, 1); err == nil {
+		t.Errorf("AddElements with a different type succeeded when it should have failed")
+	}
+	if err := con.AddTimestampedElements(TimestampedElement{Element: "b", Timestamp: 1}, TimestampedElement{Element: 2, Timestamp: 2}); err == nil {
+		t.Errorf("AddTimestampedElements with mixed types succeeded when it should have failed")
+	}
+}
@@ -0,0 +1,183 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package faultinject contains a transform that injects failures into a
+// pipeline, for testing how it behaves when bundles fail and are retried,
+// such as whether its dead-letter handling catches the failures, and whether
+// its sinks are idempotent.
+package faultinject
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*injectFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*injectKVFn)(nil)).Elem())
+}
+
+var (
+	panics     = beam.NewCounter("faultinject", "panics")
+	failures   = beam.NewCounter("faultinject", "errors")
+	delays     = beam.NewCounter("faultinject", "delays")
+	duplicates = beam.NewCounter("faultinject", "duplicates")
+)
+
+// Inject returns the elements of the PCollection, which may be KVs, with
+// faults injected into their processing with the probabilities of the given
+// options. For each element, Inject panics or returns an error, failing the
+// bundle, or delays or duplicates the element. The numbers of injected faults
+// are counted by counters in the "faultinject" namespace. For example:
+//
+//	orders = faultinject.Inject(s, orders, faultinject.Errors(0.01), faultinject.Duplicates(0.05), faultinject.Seed(42))
+//
+// Faults are drawn from a random number generator of each DoFn instance,
+// seeded by the Seed option, or randomly otherwise, so that retried bundles
+// don't fail at the same element again.
+func Inject(s beam.Scope, col beam.PCollection, opts ...option) beam.PCollection {
+	s = s.Scope("faultinject.Inject")
+
+	fn := injectFn{Seed: time.Now().UnixNano()}
+	for _, opt := range opts {
+		opt(&fn)
+	}
+	for name, p := range map[string]float64{"Panics": fn.Panic, "Errors": fn.Error, "Delays": fn.Delay, "Duplicates": fn.Duplicate} {
+		if p < 0 || p > 1 {
+			panic(fmt.Sprintf("faultinject.%v probability must be from 0 to 1. Got: %v", name, p))
+		}
+	}
+	if typex.IsKV(col.Type()) {
+		return beam.ParDo(s, &injectKVFn{injectFn: fn}, col)
+	}
+	return beam.ParDo(s, &fn, col)
+}
+
+type option func(*injectFn)
+
+// Seed seeds the random number generator that faults are drawn from.
+func Seed(seed int64) option {
+	return func(fn *injectFn) {
+		fn.Seed = seed
+	}
+}
+
+// Panics sets the probability of panicking while processing an element.
+func Panics(p float64) option {
+	return func(fn *injectFn) {
+		fn.Panic = p
+	}
+}
+
+// Errors sets the probability of returning an error while processing an
+// element.
+func Errors(p float64) option {
+	return func(fn *injectFn) {
+		fn.Error = p
+	}
+}
+
+// Delays sets the probability of delaying an element by the given duration,
+// to simulate slow or stuck processing.
+func Delays(p float64, d time.Duration) option {
+	return func(fn *injectFn) {
+		fn.Delay, fn.DelayDuration = p, d
+	}
+}
+
+// Duplicates sets the probability of emitting an element twice, to simulate
+// the duplicates of retried bundles.
+func Duplicates(p float64) option {
+	return func(fn *injectFn) {
+		fn.Duplicate = p
+	}
+}
+
+// injectFn injects faults into the processing of elements.
+type injectFn struct {
+	Seed          int64         `json:"seed"`
+	Panic         float64       `json:"panic"`
+	Error         float64       `json:"error"`
+	Delay         float64       `json:"delay"`
+	DelayDuration time.Duration `json:"delayDuration"`
+	Duplicate     float64       `json:"duplicate"`
+
+	rng *rand.Rand
+}
+
+// Setup seeds the random number generator.
+func (fn *injectFn) Setup() {
+	fn.rng = rand.New(rand.NewSource(fn.Seed))
+}
+
+// inject injects faults into the processing of an element, and returns the
+// number of times to emit it.
+func (fn *injectFn) inject(ctx context.Context, elm interface{}) (int, error) {
+	if fn.rng.Float64() < fn.Panic {
+		panics.Inc(ctx, 1)
+		panic(fmt.Sprintf("faultinject: injected panic at element %v", elm))
+	}
+	if fn.rng.Float64() < fn.Error {
+		failures.Inc(ctx, 1)
+		return 0, errors.Errorf("faultinject: injected error at element %v", elm)
+	}
+	if fn.rng.Float64() < fn.Delay {
+		delays.Inc(ctx, 1)
+		select {
+		case <-time.After(fn.DelayDuration):
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	if fn.rng.Float64() < fn.Duplicate {
+		duplicates.Inc(ctx, 1)
+		return 2, nil
+	}
+	return 1, nil
+}
+
+func (fn *injectFn) ProcessElement(ctx context.Context, elm beam.T, emit func(beam.T)) error {
+	n, err := fn.inject(ctx, elm)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		emit(elm)
+	}
+	return nil
+}
+
+// injectKVFn injects faults into the processing of KV elements.
+type injectKVFn struct {
+	injectFn
+}
+
+func (fn *injectKVFn) ProcessElement(ctx context.Context, k beam.X, v beam.Y, emit func(beam.X, beam.Y)) error {
+	n, err := fn.inject(ctx, k)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		emit(k, v)
+	}
+	return nil
+}
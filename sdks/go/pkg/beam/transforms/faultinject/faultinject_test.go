@@ -0,0 +1,104 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faultinject
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+func TestInject_Duplicates(t *testing.T) {
+	p, s, col := ptest.Create([]interface{}{1, 2, 3})
+	out := Inject(s, col, Duplicates(1), Seed(1))
+	passert.Equals(s, out, 1, 1, 2, 2, 3, 3)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestInject_DuplicatesKV(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, func(v int) (string, int) { return "k", v }, beam.Create(s, 1, 2))
+	out := Inject(s, col, Duplicates(1))
+	passert.Count(s, out, "out", 4)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestInject_None(t *testing.T) {
+	p, s, col := ptest.Create([]interface{}{1, 2, 3})
+	out := Inject(s, col, Errors(0), Panics(0), Delays(1, time.Millisecond))
+	passert.Equals(s, out, 1, 2, 3)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestInject_Failures(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  option
+	}{
+		{"Errors", Errors(1)},
+		{"Panics", Panics(1)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, s, col := ptest.Create([]interface{}{1, 2, 3})
+			Inject(s, col, test.opt)
+			if err := ptest.Run(p); err == nil {
+				t.Errorf("pipeline with %v succeeded, want failure", test.name)
+			}
+		})
+	}
+}
+
+func TestInject_Seed(t *testing.T) {
+	// Faults are deterministic for a seed.
+	draw := func() []int {
+		fn := &injectFn{Seed: 7, Duplicate: 0.5}
+		fn.Setup()
+		var ns []int
+		for i := 0; i < 20; i++ {
+			n, err := fn.inject(context.Background(), i)
+			if err != nil {
+				t.Fatalf("inject(%v) failed: %v", i, err)
+			}
+			ns = append(ns, n)
+		}
+		return ns
+	}
+	a, b := draw(), draw()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("faults differ with the same seed: %v and %v", a, b)
+		}
+	}
+}
+
+func TestInject_BadProbability(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Inject with Errors(2) didn't panic")
+		}
+	}()
+	_, s, col := ptest.Create([]interface{}{1})
+	Inject(s, col, Errors(2))
+}
@@ -0,0 +1,126 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package latency contains transforms that measure the end-to-end latency of
+// elements, the time from their creation to when they reach a point of the
+// pipeline, such as a sink, in a standard way across streaming pipelines.
+//
+// The creation time of an element is its event time, which many streaming
+// sources set to the time the element was published. Stamp sets the event
+// time of elements to the time they pass through it instead, and Measure
+// reports the latency of elements since their event time as a distribution
+// metric. For example:
+//
+//	events := latency.Stamp(s, pubsubio.Read(s, project, topic, nil))
+//	...
+//	latency.Measure(s, results, "results")
+//
+// The latency of elements output by aggregations, such as GroupByKey, is
+// measured from the event time of the outputs, which are timestamped at the
+// end of their windows in the default windowing.
+package latency
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*stampFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*stampKVFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*measureFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*measureKVFn)(nil)).Elem())
+}
+
+// Namespace is the namespace of the latency metrics reported by Measure.
+const Namespace = "latency"
+
+// now is the current time, as a variable so tests can fix it.
+var now = time.Now
+
+// Stamp returns the elements of the PCollection, which may be KVs, with their
+// event time set to the time they are processed by Stamp, as their creation
+// time for Measure. It is meant for sources that don't timestamp elements
+// with their creation time.
+//
+// Since it changes the event time of elements, Stamp should be applied before
+// elements are windowed.
+func Stamp(s beam.Scope, col beam.PCollection) beam.PCollection {
+	s = s.Scope("latency.Stamp")
+
+	if typex.IsKV(col.Type()) {
+		return beam.ParDo(s, &stampKVFn{}, col)
+	}
+	return beam.ParDo(s, &stampFn{}, col)
+}
+
+type stampFn struct{}
+
+func (fn *stampFn) ProcessElement(elm beam.T, emit func(beam.EventTime, beam.T)) {
+	emit(mtime.FromTime(now()), elm)
+}
+
+type stampKVFn struct{}
+
+func (fn *stampKVFn) ProcessElement(k beam.X, v beam.Y, emit func(beam.EventTime, beam.X, beam.Y)) {
+	emit(mtime.FromTime(now()), k, v)
+}
+
+// Measure reports the latency of the elements of the PCollection, which may be
+// KVs, since their event time as the distribution metric of the given name in
+// the latency Namespace, in milliseconds. It returns the PCollection, so that
+// it can measure latency in the middle of a pipeline as well as at its sinks.
+func Measure(s beam.Scope, col beam.PCollection, name string) beam.PCollection {
+	s = s.Scope("latency.Measure")
+
+	if typex.IsKV(col.Type()) {
+		return beam.ParDo(s, &measureKVFn{measureFn: measureFn{Name: name}}, col)
+	}
+	return beam.ParDo(s, &measureFn{Name: name}, col)
+}
+
+type measureFn struct {
+	Name string `json:"name"`
+
+	dist beam.Distribution
+}
+
+func (fn *measureFn) Setup() {
+	fn.dist = beam.NewDistribution(Namespace, fn.Name)
+}
+
+// record updates the latency distribution with the latency of an element.
+func (fn *measureFn) record(ctx context.Context, ts beam.EventTime) {
+	fn.dist.Update(ctx, mtime.FromTime(now()).Milliseconds()-ts.Milliseconds())
+}
+
+func (fn *measureFn) ProcessElement(ctx context.Context, ts beam.EventTime, elm beam.T) beam.T {
+	fn.record(ctx, ts)
+	return elm
+}
+
+type measureKVFn struct {
+	measureFn
+}
+
+func (fn *measureKVFn) ProcessElement(ctx context.Context, ts beam.EventTime, k beam.X, v beam.Y) (beam.X, beam.Y) {
+	fn.record(ctx, ts)
+	return k, v
+}
@@ -0,0 +1,97 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package latency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/go/pkg/beam/testing/ptest"
+)
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+func timestampFn(i int) (beam.EventTime, int) {
+	return mtime.FromMilliseconds(int64(i) * 1000), i
+}
+
+func keyFn(i int) (int, int) {
+	return i, i
+}
+
+func init() {
+	beam.RegisterFunction(timestampFn)
+	beam.RegisterFunction(keyFn)
+}
+
+// fixNow fixes the current time.
+func fixNow(t *testing.T, ts time.Time) {
+	old := now
+	now = func() time.Time { return ts }
+	t.Cleanup(func() { now = old })
+}
+
+func runDistribution(t *testing.T, p *beam.Pipeline, name string) metrics.DistributionValue {
+	t.Helper()
+	res, err := beam.Run(context.Background(), "direct", p)
+	if err != nil {
+		t.Fatalf("Failed to execute job: %v", err)
+	}
+	dists := res.Metrics().Query(metrics.Filter{Namespace: Namespace, Name: name}).Distributions()
+	if len(dists) != 1 {
+		t.Fatalf("got %d latency distributions named %v, want 1", len(dists), name)
+	}
+	return dists[0].Result()
+}
+
+func TestMeasure(t *testing.T) {
+	fixNow(t, time.Unix(10, 0))
+
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, timestampFn, beam.Create(s, 1, 2, 4))
+	out := Measure(s, col, "sink")
+	passert.Equals(s, out, 1, 2, 4)
+	Measure(s, beam.ParDo(s, keyFn, col), "kv")
+
+	got := runDistribution(t, p, "sink")
+	want := metrics.DistributionValue{Count: 3, Sum: 9000 + 8000 + 6000, Min: 6000, Max: 9000}
+	if got != want {
+		t.Errorf("latency distribution = %+v, want %+v", got, want)
+	}
+}
+
+func TestStamp(t *testing.T) {
+	fixNow(t, time.Unix(10, 0))
+
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, timestampFn, beam.Create(s, 1, 2))
+	col = Stamp(s, col)
+	Stamp(s, beam.ParDo(s, keyFn, col))
+	Measure(s, col, "stamped")
+
+	got := runDistribution(t, p, "stamped")
+	want := metrics.DistributionValue{Count: 2, Sum: 0, Min: 0, Max: 0}
+	if got != want {
+		t.Errorf("latency distribution = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,176 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sql contains a cross-language transform for querying PCollections
+// with SQL, such as to join or aggregate them. The queries are run by the Java
+// SDK's SqlTransform, so this only works on runners that support
+// cross-language transforms.
+//
+// The elements of the PCollections are rows, which must be structs whose
+// fields are supported by schemas. Each input PCollection is a table of the
+// query, named by its Input:
+//
+//	type Order struct {
+//	    Customer string  `beam:"customer"`
+//	    Total    float64 `beam:"total"`
+//	}
+//	type Spend struct {
+//	    Customer string  `beam:"customer"`
+//	    Total    float64 `beam:"total"`
+//	}
+//
+//	spend := sql.Transform(s,
+//	    "SELECT customer, SUM(total) AS total FROM orders GROUP BY customer",
+//	    sql.Input("orders", orders),
+//	    sql.OutputType(reflect.TypeOf(Spend{})))
+//
+// The expansion service of SqlTransform is started from the jar released for
+// the version of the Beam SDK, unless the address of another one is given with
+// ExpansionAddr. Its module is beam-sdks-java-extensions-sql-expansion-service,
+// which runs from source via Gradle with
+// ./gradlew :sdks:java:extensions:sql:expansion-service:runExpansionService
+package sql
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*payload)(nil)).Elem())
+}
+
+const (
+	urn = "beam:external:java:sql:v1"
+
+	// serviceGradleTarget is the Gradle target of the expansion service jar
+	// used if no expansion address is given.
+	serviceGradleTarget = "sdks:java:extensions:sql:expansion-service:shadowJar"
+)
+
+// The SQL dialects of queries.
+const (
+	// Calcite is the dialect of Apache Calcite, which is the default.
+	Calcite = "calcite"
+	// ZetaSQL is the dialect of ZetaSQL, as used by BigQuery.
+	ZetaSQL = "zetasql"
+)
+
+// Option is an option of Transform.
+type Option func(*options)
+
+type options struct {
+	inputs  map[string]beam.PCollection
+	outT    reflect.Type
+	dialect string
+	addr    string
+}
+
+// Input is an option that adds a PCollection as the table of the given name.
+// The only input of a query is also the table "PCOLLECTION".
+func Input(name string, col beam.PCollection) Option {
+	return func(o *options) {
+		o.inputs[name] = col
+	}
+}
+
+// OutputType is an option that sets the type of the rows returned by the
+// query, which must be a struct type. It may be omitted if the query has a
+// single input of that type.
+func OutputType(t reflect.Type) Option {
+	return func(o *options) {
+		o.outT = t
+	}
+}
+
+// Dialect is an option that sets the SQL dialect of the query, Calcite or
+// ZetaSQL.
+//
+// Default: sql.Calcite
+func Dialect(dialect string) Option {
+	return func(o *options) {
+		o.dialect = dialect
+	}
+}
+
+// ExpansionAddr is an option that sets the address of the expansion service
+// of SqlTransform.
+func ExpansionAddr(addr string) Option {
+	return func(o *options) {
+		o.addr = addr
+	}
+}
+
+// Transform is a cross-language PTransform which runs the given SQL query on
+// its inputs, and returns the PCollection of the rows of the result.
+//
+// Transform requires a query and at least one Input. The type of the results
+// is set by OutputType, unless the query has a single input of that type.
+func Transform(s beam.Scope, query string, opts ...Option) beam.PCollection {
+	s = s.Scope("sql.Transform")
+
+	o := &options{inputs: make(map[string]beam.PCollection), dialect: Calcite}
+	for _, opt := range opts {
+		opt(o)
+	}
+	outT := validate(o)
+	addr := o.addr
+	if addr == "" {
+		addr = xlangx.AutoJava(serviceGradleTarget)
+	}
+
+	pl := beam.CrossLanguagePayload(payload{Query: query, Dialect: o.dialect})
+	out := beam.CrossLanguage(s, urn, pl, addr, o.inputs, beam.UnnamedOutput(outT))
+	return out[graph.UnnamedOutputTag]
+}
+
+// validate returns the type of the output of a query with the given options,
+// and panics if they are invalid.
+func validate(o *options) beam.FullType {
+	if len(o.inputs) == 0 {
+		panic("sql.Transform requires at least one input table.")
+	}
+	switch o.dialect {
+	case Calcite, ZetaSQL:
+	default:
+		panic(fmt.Sprintf("sql.Transform has unknown dialect %q, want %q or %q.", o.dialect, Calcite, ZetaSQL))
+	}
+	if o.outT == nil {
+		if len(o.inputs) != 1 {
+			panic("sql.Transform requires an output type for queries of several inputs.")
+		}
+		for _, col := range o.inputs {
+			return col.Type()
+		}
+	}
+	if o.outT.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("sql.Transform requires a struct output type, got %v.", o.outT))
+	}
+	return typex.New(o.outT)
+}
+
+// payload should produce a schema matching the expected cross-language
+// payload for SQL transforms. An example of this on the receiving end can be
+// found in the Java SDK class
+// org.apache.beam.sdk.extensions.sql.expansion.ExternalSqlTransformRegistrar.Configuration.
+type payload struct {
+	Query   string `beam:"query"`
+	Dialect string `beam:"dialect"`
+}
@@ -0,0 +1,96 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+type order struct {
+	Customer string  `beam:"customer"`
+	Total    float64 `beam:"total"`
+}
+
+type spend struct {
+	Customer string  `beam:"customer"`
+	Total    float64 `beam:"total"`
+}
+
+func TestValidate(t *testing.T) {
+	_, s := beam.NewPipelineWithRoot()
+	orders := beam.Create(s, order{"a", 1}, order{"b", 2})
+
+	tests := []struct {
+		name string
+		opts []Option
+		want reflect.Type
+	}{
+		{"InputType", []Option{Input("orders", orders)}, reflect.TypeOf(order{})},
+		{"OutputType", []Option{Input("orders", orders), OutputType(reflect.TypeOf(spend{}))}, reflect.TypeOf(spend{})},
+		{"Join", []Option{
+			Input("a", orders), Input("b", orders), Dialect(ZetaSQL), OutputType(reflect.TypeOf(spend{})),
+		}, reflect.TypeOf(spend{})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := &options{inputs: make(map[string]beam.PCollection), dialect: Calcite}
+			for _, opt := range test.opts {
+				opt(o)
+			}
+			if got := validate(o); !typex.IsEqual(got, typex.New(test.want)) {
+				t.Errorf("validate = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidate_Invalid(t *testing.T) {
+	_, s := beam.NewPipelineWithRoot()
+	orders := beam.Create(s, order{"a", 1})
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"NoInputs", nil},
+		{"BadDialect", []Option{Input("orders", orders), Dialect("mysql")}},
+		{"NoOutputType", []Option{Input("a", orders), Input("b", orders)}},
+		{"NotStruct", []Option{Input("orders", orders), OutputType(reflect.TypeOf(0))}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("validate succeeded, want panic")
+				}
+			}()
+			o := &options{inputs: make(map[string]beam.PCollection), dialect: Calcite}
+			for _, opt := range test.opts {
+				opt(o)
+			}
+			validate(o)
+		})
+	}
+}
+
+func TestPayload(t *testing.T) {
+	// The payload must encode as a schema row.
+	beam.CrossLanguagePayload(payload{Query: "SELECT 1", Dialect: Calcite})
+}
@@ -28,13 +28,17 @@ import (
 // to provide a customized dialing behavior.
 var Dial = DefaultDial
 
+// dialOptions are the additional options of DefaultDial, set by a Hook.
+var dialOptions []grpc.DialOption
+
 // DefaultDial is a dialer that specifies an insecure blocking connection with a timeout.
 func DefaultDial(ctx context.Context, endpoint string, timeout time.Duration) (*grpc.ClientConn, error) {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cc, err := grpc.DialContext(ctx, endpoint, grpc.WithInsecure(), grpc.WithBlock(),
-		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(math.MaxInt32)))
+	opts := []grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock(),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(math.MaxInt32))}
+	cc, err := grpc.DialContext(ctx, endpoint, append(opts, dialOptions...)...)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to dial server at %v", endpoint)
 	}
@@ -31,6 +31,9 @@ import (
 type Hook struct {
 	// Dialer allows the runner to customize the gRPC dialing behavior.
 	Dialer func(context.Context, string, time.Duration) (*grpc.ClientConn, error)
+	// DialOptions are added to the options of the connections dialed by
+	// DefaultDial, such as keepalive or message size settings.
+	DialOptions []grpc.DialOption
 	// TODO(wcn): expose other hooks here.
 }
 
@@ -60,6 +63,7 @@ func RegisterHook(name string, c HookFactory) {
 				if grpcHook.Dialer != nil {
 					Dial = grpcHook.Dialer
 				}
+				dialOptions = append(dialOptions, grpcHook.DialOptions...)
 				return ctx, nil
 			},
 		}
@@ -17,6 +17,8 @@
 
 package syscallx
 
+import "time"
+
 // PhysicalMemorySize returns the total physical memory size.
 func PhysicalMemorySize() (uint64, error) {
 	return 0, ErrUnsupported
@@ -26,3 +28,13 @@ func PhysicalMemorySize() (uint64, error) {
 func FreeDiskSpace(path string) (uint64, error) {
 	return 0, ErrUnsupported
 }
+
+// ProcessCPUTime returns the user and system CPU time used by the process.
+func ProcessCPUTime() (time.Duration, error) {
+	return 0, ErrUnsupported
+}
+
+// ResidentMemorySize returns the resident set size of the process.
+func ResidentMemorySize() (uint64, error) {
+	return 0, ErrUnsupported
+}
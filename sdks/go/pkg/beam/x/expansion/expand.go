@@ -0,0 +1,315 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expansion
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/artifact"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx/schema"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/pipelinex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/protox"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/go/pkg/beam/options/jobopts"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// The placeholders stand for the inputs and outputs of the external
+	// transform while the Go transform is constructed. They are removed from
+	// the expansion.
+	urnInputPlaceholder  = "beam:go:transform:expansion_input:v1"
+	urnOutputPlaceholder = "beam:go:transform:expansion_output:v1"
+)
+
+// expand constructs the Go transform of the external transform of the
+// request, and returns its expansion. The transform is constructed in a
+// pipeline of its own, whose input placeholders produce PCollections of the
+// types of the external inputs, and whose output placeholders consume the
+// outputs. Once marshalled, the placeholders are replaced by the external
+// inputs and outputs, and the IDs of the other components are scoped to the
+// namespace of the request.
+func expand(ctx context.Context, req *jobpb.ExpansionRequest) (*jobpb.ExpansionResponse, error) {
+	ext := req.GetTransform()
+	urn := ext.GetSpec().GetUrn()
+	t, ok := lookup(urn)
+	if !ok {
+		return nil, errors.Errorf("no Go transform registered for URN %v", urn)
+	}
+	config, err := decodeConfig(ext.GetSpec().GetPayload(), t.config)
+	if err != nil {
+		return nil, errors.WithContextf(err, "expanding %v", urn)
+	}
+
+	p, s := beam.NewPipelineWithRoot()
+	inputs := make(map[string]beam.PCollection)
+	for _, tag := range sortedKeys(ext.GetInputs()) {
+		col, err := addInput(s, req.GetComponents(), ext.GetInputs()[tag])
+		if err != nil {
+			return nil, errors.WithContextf(err, "expanding input %v of %v", tag, urn)
+		}
+		inputs[tag] = col
+	}
+	outputs, err := t.apply(s.Scope(ext.GetUniqueName()), config, inputs)
+	if err != nil {
+		return nil, errors.WithContextf(err, "expanding %v", urn)
+	}
+	for tag, col := range outputs {
+		if !col.IsValid() {
+			return nil, errors.Errorf("expanding %v: invalid output %v", urn, tag)
+		}
+		beam.External(s, urnOutputPlaceholder, []byte(tag), []beam.PCollection{col}, nil, true)
+	}
+
+	edges, _, err := p.Build()
+	if err != nil {
+		return nil, errors.WithContextf(err, "expanding %v", urn)
+	}
+	if err := graphx.CheckStateless(edges); err != nil {
+		return nil, errors.WithContextf(err, "expanding %v", urn)
+	}
+	env, err := environment(ctx)
+	if err != nil {
+		return nil, errors.WithContextf(err, "expanding %v", urn)
+	}
+	pipe, err := graphx.Marshal(edges, &graphx.Options{Environment: env})
+	if err != nil {
+		return nil, errors.WithContextf(err, "expanding %v", urn)
+	}
+
+	comps := pipe.GetComponents()
+	external := make(map[string]string) // placeholder PCollection ID -> external PCollection ID
+	outs := make(map[string]string)
+	var root string
+	for _, id := range pipe.GetRootTransformIds() {
+		pt := comps.Transforms[id]
+		switch pt.GetSpec().GetUrn() {
+		case urnInputPlaceholder:
+			for _, pid := range pt.GetOutputs() {
+				external[pid] = string(pt.GetSpec().GetPayload())
+				delete(comps.Pcollections, pid)
+			}
+		case urnOutputPlaceholder:
+			for _, pid := range pt.GetInputs() {
+				outs[string(pt.GetSpec().GetPayload())] = pid
+			}
+		default:
+			root = id
+			continue
+		}
+		delete(comps.Transforms, id)
+	}
+	if root == "" {
+		return nil, errors.Errorf("expanding %v: the Go transform has no transforms", urn)
+	}
+	composite := comps.Transforms[root]
+	delete(comps.Transforms, root)
+
+	ns := &namespacer{namespace: req.GetNamespace(), external: external}
+	res, err := ns.components(comps)
+	if err != nil {
+		return nil, errors.WithContextf(err, "expanding %v", urn)
+	}
+	addExternalInputs(res, req.GetComponents(), ext.GetInputs())
+
+	expanded := &pipepb.PTransform{
+		UniqueName:    ext.GetUniqueName(),
+		Spec:          ext.GetSpec(),
+		Inputs:        ext.GetInputs(),
+		Outputs:       make(map[string]string),
+		Subtransforms: ns.ids(composite.GetSubtransforms()),
+		EnvironmentId: ns.id(composite.GetEnvironmentId()),
+		Annotations:   composite.GetAnnotations(),
+	}
+	for tag, pid := range outs {
+		expanded.Outputs[tag] = ns.pcollectionID(pid)
+	}
+	return &jobpb.ExpansionResponse{
+		Components:   res,
+		Transform:    expanded,
+		Requirements: pipe.GetRequirements(),
+	}, nil
+}
+
+// decodeConfig decodes the ExternalConfigurationPayload of an external
+// transform as a value of the config type, whose fields are set by the
+// schema names of the fields of the payload.
+func decodeConfig(payload []byte, t reflect.Type) (reflect.Value, error) {
+	config := reflect.New(t).Elem()
+	if len(payload) == 0 {
+		return config, nil
+	}
+	var ecp pipepb.ExternalConfigurationPayload
+	if err := proto.Unmarshal(payload, &ecp); err != nil {
+		return reflect.Value{}, errors.Wrap(err, "invalid configuration, want an ExternalConfigurationPayload")
+	}
+	rt, err := schema.ToType(ecp.GetSchema())
+	if err != nil {
+		return reflect.Value{}, errors.Wrap(err, "invalid configuration schema")
+	}
+	dec, err := coder.RowDecoderForStruct(rt)
+	if err != nil {
+		return reflect.Value{}, errors.Wrap(err, "invalid configuration schema")
+	}
+	v, err := dec(bytes.NewReader(ecp.GetPayload()))
+	if err != nil {
+		return reflect.Value{}, errors.Wrap(err, "invalid configuration row")
+	}
+	src := reflect.ValueOf(v)
+	if src.Type() == t {
+		return src, nil
+	}
+
+	fields := fieldsByName(t)
+	for i := 0; i < src.NumField(); i++ {
+		name := fieldName(src.Type().Field(i))
+		j, ok := fields[name]
+		if !ok {
+			return reflect.Value{}, errors.Errorf("unknown configuration field %v for %v", name, t)
+		}
+		if err := setField(config.Field(j), src.Field(i)); err != nil {
+			return reflect.Value{}, errors.WithContextf(err, "setting configuration field %v of %v", name, t)
+		}
+	}
+	return config, nil
+}
+
+// fieldsByName returns the indices of the exported fields of the struct type
+// by their schema names.
+func fieldsByName(t reflect.Type) map[string]int {
+	ret := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); sf.PkgPath == "" {
+			ret[fieldName(sf)] = i
+		}
+	}
+	return ret
+}
+
+// fieldName returns the schema name of the struct field, which is the name
+// of its beam tag, if any.
+func fieldName(sf reflect.StructField) string {
+	if tag := sf.Tag.Get("beam"); tag != "" {
+		if name := strings.Split(tag, ",")[0]; name != "" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// setField sets the config field to the decoded value, converting its type
+// if needed. Nullable values set the field to their value if not null.
+func setField(dst, src reflect.Value) error {
+	if src.Kind() == reflect.Ptr && dst.Kind() != reflect.Ptr {
+		if src.IsNil() {
+			return nil
+		}
+		src = src.Elem()
+	}
+	switch {
+	case src.Type().AssignableTo(dst.Type()):
+		dst.Set(src)
+	case src.Type().ConvertibleTo(dst.Type()):
+		dst.Set(src.Convert(dst.Type()))
+	default:
+		return errors.Errorf("cannot set field of type %v to a value of type %v", dst.Type(), src.Type())
+	}
+	return nil
+}
+
+// addInput adds a placeholder for the external input PCollection, which
+// produces a PCollection of the type of its coder.
+func addInput(s beam.Scope, comps *pipepb.Components, id string) (beam.PCollection, error) {
+	pcol, ok := comps.GetPcollections()[id]
+	if !ok {
+		return beam.PCollection{}, errors.Errorf("unknown PCollection %v", id)
+	}
+	if ws := comps.GetWindowingStrategies()[pcol.GetWindowingStrategyId()]; ws.GetWindowFn().GetUrn() != graphx.URNGlobalWindowsWindowFn {
+		return beam.PCollection{}, errors.Errorf("PCollection %v is windowed by %v, but only globally windowed inputs are supported", id, ws.GetWindowFn().GetUrn())
+	}
+	coders, err := graphx.UnmarshalCoders([]string{pcol.GetCoderId()}, comps.GetCoders())
+	if err != nil {
+		return beam.PCollection{}, errors.WithContextf(err, "decoding the coder of PCollection %v", id)
+	}
+	bounded := pcol.GetIsBounded() != pipepb.IsBounded_UNBOUNDED
+	out := beam.External(s, urnInputPlaceholder, []byte(id), nil, []beam.FullType{coders[0].T}, bounded)
+	return out[0], nil
+}
+
+// addExternalInputs adds the external input PCollections, and their coders
+// and windowing strategies, to the components of the expansion.
+func addExternalInputs(res, comps *pipepb.Components, inputs map[string]string) {
+	var coderIDs []string
+	for _, id := range inputs {
+		pcol := comps.GetPcollections()[id]
+		res.Pcollections[id] = pcol
+		coderIDs = append(coderIDs, pcol.GetCoderId())
+		if ws, ok := comps.GetWindowingStrategies()[pcol.GetWindowingStrategyId()]; ok {
+			res.WindowingStrategies[pcol.GetWindowingStrategyId()] = ws
+			coderIDs = append(coderIDs, ws.GetWindowCoderId())
+		}
+	}
+	for id, c := range pipelinex.TrimCoders(comps.GetCoders(), coderIDs...) {
+		res.Coders[id] = c
+	}
+}
+
+// environment returns the Go environment of the expanded transforms, built
+// from the environment options of the service. Its workers run the binary of
+// the service, or the --worker_binary, if set, which is staged as a file,
+// since the runners of other SDKs don't know the Go worker artifacts.
+func environment(ctx context.Context) (*pipepb.Environment, error) {
+	env, err := graphx.CreateEnvironment(ctx, jobopts.GetEnvironmentUrn(ctx), jobopts.GetEnvironmentConfig)
+	if err != nil {
+		return nil, err
+	}
+	binary := *jobopts.WorkerBinary
+	if binary == "" {
+		if binary, err = os.Executable(); err != nil {
+			return nil, errors.Wrap(err, "failed to find the worker binary")
+		}
+	}
+	for i, dep := range env.GetDependencies() {
+		if dep.GetTypeUrn() == graphx.URNArtifactGoWorker {
+			env.Dependencies[i] = &pipepb.ArtifactInformation{
+				TypeUrn:     artifact.URNFileArtifact,
+				TypePayload: protox.MustEncode(&pipepb.ArtifactFilePayload{Path: binary}),
+				RoleUrn:     dep.GetRoleUrn(),
+				RolePayload: dep.GetRolePayload(),
+			}
+		}
+	}
+	return env, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,165 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package expansion provides an expansion service for Go transforms, so that
+// pipelines of other SDKs can use them as cross-language transforms.
+//
+// Transforms are registered with a URN, and are expanded with the
+// configuration of the external transform of the calling pipeline, which is
+// an ExternalConfigurationPayload holding a schema and a row. A binary
+// serving Go transforms registers them, and their DoFns, before calling
+// beam.Init, since the same binary runs their bundles as the worker of the
+// Go environment:
+//
+//	func main() {
+//		expansion.Register("beam:transform:org.example:tokenize:v1", tokenize)
+//		beam.Init()
+//
+//		s, err := expansion.Start(ctx, *port)
+//		...
+//	}
+package expansion
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	// The binary runs as the worker of the environment it declares.
+	_ "github.com/apache/beam/sdks/go/pkg/beam/core/runtime/harness/init"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
+	"google.golang.org/grpc"
+)
+
+var (
+	scopeType  = reflect.TypeOf((*beam.Scope)(nil)).Elem()
+	pcolsType  = reflect.TypeOf((map[string]beam.PCollection)(nil))
+	registryMu sync.Mutex
+	registry   = make(map[string]*transform)
+)
+
+// transform is a registered Go transform.
+type transform struct {
+	fn     reflect.Value
+	config reflect.Type
+}
+
+// Register registers the Go transform to expand for external transforms with
+// the URN. The transform is a function of the form
+//
+//	func(s beam.Scope, config C, inputs map[string]beam.PCollection) map[string]beam.PCollection
+//
+// that may also return an error, where C is a struct type decoded from the
+// configuration of the external transform. The configuration fields are
+// matched to the fields of C by their schema names, which are their beam
+// tags or their names. The inputs and outputs are keyed by the tags of the
+// external transform. Register panics if the function has another form, or
+// if the URN is already registered.
+func Register(urn string, fn interface{}) {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		panic(fmt.Sprintf("invalid transform for %v: %v is not a function", urn, t))
+	}
+	if t.NumIn() != 3 || t.In(0) != scopeType || t.In(1).Kind() != reflect.Struct || t.In(2) != pcolsType {
+		panic(fmt.Sprintf("invalid transform for %v: %v, want func(beam.Scope, C, map[string]beam.PCollection) for a struct C", urn, t))
+	}
+	switch {
+	case t.NumOut() == 1 && t.Out(0) == pcolsType:
+	case t.NumOut() == 2 && t.Out(0) == pcolsType && t.Out(1) == reflectx.Error:
+	default:
+		panic(fmt.Sprintf("invalid transform for %v: %v, want it to return map[string]beam.PCollection and optionally an error", urn, t))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[urn]; ok {
+		panic(fmt.Sprintf("transform for %v already registered", urn))
+	}
+	registry[urn] = &transform{fn: reflect.ValueOf(fn), config: t.In(1)}
+}
+
+func lookup(urn string) (*transform, bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	t, ok := registry[urn]
+	return t, ok
+}
+
+// apply applies the transform to the inputs in the scope, recovering from
+// the panics of invalid pipeline construction.
+func (t *transform) apply(s beam.Scope, config reflect.Value, inputs map[string]beam.PCollection) (outputs map[string]beam.PCollection, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("transform panicked: %v", r)
+		}
+	}()
+	ret := t.fn.Call([]reflect.Value{reflect.ValueOf(s), config, reflect.ValueOf(inputs)})
+	if len(ret) == 2 && !ret[1].IsNil() {
+		return nil, ret[1].Interface().(error)
+	}
+	return ret[0].Interface().(map[string]beam.PCollection), nil
+}
+
+// Service is an expansion service of the registered Go transforms,
+// implementing jobpb.ExpansionServiceServer.
+type Service struct {
+	lis        net.Listener
+	grpcServer *grpc.Server
+}
+
+// Start starts an expansion service of the registered Go transforms at the
+// given port.
+func Start(ctx context.Context, port int) (*Service, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof(ctx, "starting expansion service at %v", lis.Addr())
+	s := &Service{lis: lis, grpcServer: grpc.NewServer()}
+	jobpb.RegisterExpansionServiceServer(s.grpcServer, s)
+	go s.grpcServer.Serve(lis)
+	return s, nil
+}
+
+// Expand expands an external transform with the registered Go transform of
+// its URN, implementing ExpansionServiceServer.Expand. Expansion failures are
+// reported in the error of the response, as for the expansion services of
+// other SDKs.
+func (s *Service) Expand(ctx context.Context, req *jobpb.ExpansionRequest) (*jobpb.ExpansionResponse, error) {
+	res, err := expand(ctx, req)
+	if err != nil {
+		log.Warnf(ctx, "failed to expand %v: %v", req.GetTransform().GetUniqueName(), err)
+		return &jobpb.ExpansionResponse{Error: err.Error()}, nil
+	}
+	return res, nil
+}
+
+// Endpoint returns the endpoint of the service.
+func (s *Service) Endpoint() string {
+	return s.lis.Addr().String()
+}
+
+// Stop stops the service.
+func (s *Service) Stop(ctx context.Context) {
+	log.Infof(ctx, "stopping expansion service at %v", s.lis.Addr())
+	s.grpcServer.GracefulStop()
+}
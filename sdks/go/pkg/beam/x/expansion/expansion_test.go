@@ -0,0 +1,215 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expansion
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/artifact"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
+	jobpb "github.com/apache/beam/sdks/go/pkg/beam/model/jobmanagement_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+)
+
+const urnSuffix = "beam:transform:org.apache.beam:go_suffix:v1"
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*suffixFn)(nil)).Elem())
+	Register(urnSuffix, suffix)
+}
+
+type suffixConfig struct {
+	Suffix string `beam:"suffix"`
+	Count  int
+}
+
+type suffixFn struct {
+	Suffix string
+}
+
+func (f *suffixFn) ProcessElement(s string) string {
+	return s + f.Suffix
+}
+
+func suffix(s beam.Scope, config suffixConfig, inputs map[string]beam.PCollection) map[string]beam.PCollection {
+	out := beam.ParDo(s, &suffixFn{Suffix: strings.Repeat(config.Suffix, config.Count)}, inputs["in"])
+	return map[string]beam.PCollection{"out": out}
+}
+
+// request returns an expansion request of the transform with the URN and
+// configuration, whose input is a globally windowed PCollection of strings.
+func request(t *testing.T, urn string, config interface{}) *jobpb.ExpansionRequest {
+	t.Helper()
+	payload, err := xlangx.EncodeStructPayload(config)
+	if err != nil {
+		t.Fatalf("EncodeStructPayload(%v) failed: %v", config, err)
+	}
+	return &jobpb.ExpansionRequest{
+		Components: &pipepb.Components{
+			Pcollections: map[string]*pipepb.PCollection{
+				"p0": {UniqueName: "p0", CoderId: "c0", IsBounded: pipepb.IsBounded_BOUNDED, WindowingStrategyId: "w0"},
+			},
+			WindowingStrategies: map[string]*pipepb.WindowingStrategy{
+				"w0": {WindowFn: &pipepb.FunctionSpec{Urn: graphx.URNGlobalWindowsWindowFn}, WindowCoderId: "c1"},
+			},
+			Coders: map[string]*pipepb.Coder{
+				"c0": {Spec: &pipepb.FunctionSpec{Urn: "beam:coder:string_utf8:v1"}},
+				"c1": {Spec: &pipepb.FunctionSpec{Urn: "beam:coder:global_window:v1"}},
+			},
+		},
+		Transform: &pipepb.PTransform{
+			UniqueName: "External/Suffix",
+			Spec:       &pipepb.FunctionSpec{Urn: urn, Payload: payload},
+			Inputs:     map[string]string{"in": "p0"},
+		},
+		Namespace: "ns",
+	}
+}
+
+func TestExpand(t *testing.T) {
+	config := struct {
+		Suffix string `beam:"suffix"`
+		Count  int64
+	}{Suffix: "!", Count: 2}
+	res, err := (&Service{}).Expand(context.Background(), request(t, urnSuffix, config))
+	if err != nil {
+		t.Fatalf("Expand() failed: %v", err)
+	}
+	if res.GetError() != "" {
+		t.Fatalf("Expand() failed: %v", res.GetError())
+	}
+
+	exp := res.GetTransform()
+	if got, want := exp.GetInputs(), map[string]string{"in": "p0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expanded inputs = %v, want %v", got, want)
+	}
+	out, ok := exp.GetOutputs()["out"]
+	if !ok || len(exp.GetOutputs()) != 1 {
+		t.Fatalf("expanded outputs = %v, want a single output out", exp.GetOutputs())
+	}
+	comps := res.GetComponents()
+	if _, ok := comps.GetPcollections()[out]; !ok || !strings.HasSuffix(out, "@ns") {
+		t.Errorf("expanded output %v is not a namespaced PCollection of %v", out, comps.GetPcollections())
+	}
+	if _, ok := comps.GetPcollections()["p0"]; !ok {
+		t.Errorf("expansion is missing the input PCollection p0: %v", comps.GetPcollections())
+	}
+	if len(exp.GetSubtransforms()) != 1 {
+		t.Fatalf("expanded subtransforms = %v, want a single ParDo", exp.GetSubtransforms())
+	}
+
+	id := exp.GetSubtransforms()[0]
+	pardo, ok := comps.GetTransforms()[id]
+	if !ok || !strings.HasSuffix(id, "@ns") {
+		t.Fatalf("subtransform %v is not a namespaced transform of %v", id, comps.GetTransforms())
+	}
+	if got, want := pardo.GetSpec().GetUrn(), graphx.URNParDo; got != want {
+		t.Errorf("subtransform URN = %v, want %v", got, want)
+	}
+	for _, in := range pardo.GetInputs() {
+		if in != "p0" {
+			t.Errorf("subtransform input = %v, want p0", in)
+		}
+	}
+	for _, pid := range pardo.GetOutputs() {
+		if pid != out {
+			t.Errorf("subtransform output = %v, want %v", pid, out)
+		}
+	}
+	for _, pcol := range comps.GetPcollections() {
+		if _, ok := comps.GetCoders()[pcol.GetCoderId()]; !ok {
+			t.Errorf("coder %v of PCollection %v is missing", pcol.GetCoderId(), pcol.GetUniqueName())
+		}
+		if _, ok := comps.GetWindowingStrategies()[pcol.GetWindowingStrategyId()]; !ok {
+			t.Errorf("windowing strategy %v of PCollection %v is missing", pcol.GetWindowingStrategyId(), pcol.GetUniqueName())
+		}
+	}
+
+	env, ok := comps.GetEnvironments()[pardo.GetEnvironmentId()]
+	if !ok || !strings.HasSuffix(pardo.GetEnvironmentId(), "@ns") {
+		t.Fatalf("environment %v is not a namespaced environment of %v", pardo.GetEnvironmentId(), comps.GetEnvironments())
+	}
+	if got, want := env.GetDependencies()[0].GetTypeUrn(), artifact.URNFileArtifact; got != want {
+		t.Errorf("worker dependency type = %v, want %v", got, want)
+	}
+}
+
+func TestExpand_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *jobpb.ExpansionRequest
+		want string
+	}{
+		{
+			name: "unregistered URN",
+			req:  request(t, "beam:transform:org.apache.beam:unknown:v1", suffixConfig{}),
+			want: "no Go transform registered",
+		},
+		{
+			name: "unknown configuration field",
+			req: request(t, urnSuffix, struct {
+				Prefix string
+			}{}),
+			want: "unknown configuration field Prefix",
+		},
+		{
+			name: "mistyped configuration field",
+			req: request(t, urnSuffix, struct {
+				Count string
+			}{}),
+			want: "cannot set field",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := (&Service{}).Expand(context.Background(), test.req)
+			if err != nil {
+				t.Fatalf("Expand() failed: %v", err)
+			}
+			if !strings.Contains(res.GetError(), test.want) {
+				t.Errorf("Expand() error = %q, want it to contain %q", res.GetError(), test.want)
+			}
+		})
+	}
+}
+
+func TestRegister_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   interface{}
+	}{
+		{"not a function", 1},
+		{"no config", func(beam.Scope, map[string]beam.PCollection) map[string]beam.PCollection { return nil }},
+		{"config not a struct", func(beam.Scope, string, map[string]beam.PCollection) map[string]beam.PCollection { return nil }},
+		{"no outputs", func(beam.Scope, suffixConfig, map[string]beam.PCollection) {}},
+		{"already registered", suffix},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("Register(%T) didn't panic", test.fn)
+				}
+			}()
+			Register(urnSuffix, test.fn)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expansion
+
+import (
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// namespacer scopes the IDs of the components of an expansion to the
+// namespace of the request, in the id@namespace form of the Go SDK, so that
+// they don't collide with the IDs of the calling pipeline. The placeholder
+// PCollections are replaced by the external PCollections they stand for.
+type namespacer struct {
+	namespace string
+	// external maps the IDs of the placeholder PCollections to the IDs of
+	// the external PCollections.
+	external map[string]string
+}
+
+func (n *namespacer) id(id string) string {
+	if id == "" {
+		return ""
+	}
+	return fmt.Sprintf("%v@%v", id, n.namespace)
+}
+
+func (n *namespacer) pcollectionID(id string) string {
+	if ext, ok := n.external[id]; ok {
+		return ext
+	}
+	return n.id(id)
+}
+
+func (n *namespacer) ids(ids []string) []string {
+	var ret []string
+	for _, id := range ids {
+		ret = append(ret, n.id(id))
+	}
+	return ret
+}
+
+func (n *namespacer) pcollectionIDs(m map[string]string) map[string]string {
+	ret := make(map[string]string, len(m))
+	for tag, id := range m {
+		ret[tag] = n.pcollectionID(id)
+	}
+	return ret
+}
+
+// components returns the components with their IDs, and the IDs they
+// refer to, scoped to the namespace.
+func (n *namespacer) components(c *pipepb.Components) (*pipepb.Components, error) {
+	ret := &pipepb.Components{
+		Transforms:          make(map[string]*pipepb.PTransform),
+		Pcollections:        make(map[string]*pipepb.PCollection),
+		WindowingStrategies: make(map[string]*pipepb.WindowingStrategy),
+		Coders:              make(map[string]*pipepb.Coder),
+		Environments:        make(map[string]*pipepb.Environment),
+	}
+	for id, t := range c.GetTransforms() {
+		spec, err := n.spec(t.GetSpec())
+		if err != nil {
+			return nil, errors.WithContextf(err, "namespacing transform %v", t.GetUniqueName())
+		}
+		t.Spec = spec
+		t.Inputs = n.pcollectionIDs(t.GetInputs())
+		t.Outputs = n.pcollectionIDs(t.GetOutputs())
+		t.Subtransforms = n.ids(t.GetSubtransforms())
+		t.EnvironmentId = n.id(t.GetEnvironmentId())
+		ret.Transforms[n.id(id)] = t
+	}
+	for id, pcol := range c.GetPcollections() {
+		pcol.CoderId = n.id(pcol.GetCoderId())
+		pcol.WindowingStrategyId = n.id(pcol.GetWindowingStrategyId())
+		ret.Pcollections[n.id(id)] = pcol
+	}
+	for id, ws := range c.GetWindowingStrategies() {
+		ws.WindowCoderId = n.id(ws.GetWindowCoderId())
+		ws.EnvironmentId = n.id(ws.GetEnvironmentId())
+		ret.WindowingStrategies[n.id(id)] = ws
+	}
+	for id, cdr := range c.GetCoders() {
+		cdr.ComponentCoderIds = n.ids(cdr.GetComponentCoderIds())
+		ret.Coders[n.id(id)] = cdr
+	}
+	for id, env := range c.GetEnvironments() {
+		ret.Environments[n.id(id)] = env
+	}
+	return ret, nil
+}
+
+// spec returns the spec with the coder IDs of its payload scoped to the
+// namespace, for the transforms whose payloads refer to coders.
+func (n *namespacer) spec(spec *pipepb.FunctionSpec) (*pipepb.FunctionSpec, error) {
+	switch spec.GetUrn() {
+	case graphx.URNParDo:
+		var payload pipepb.ParDoPayload
+		if err := proto.Unmarshal(spec.GetPayload(), &payload); err != nil {
+			return nil, err
+		}
+		payload.RestrictionCoderId = n.id(payload.GetRestrictionCoderId())
+		return n.encode(spec, &payload)
+	case graphx.URNCombinePerKey:
+		var payload pipepb.CombinePayload
+		if err := proto.Unmarshal(spec.GetPayload(), &payload); err != nil {
+			return nil, err
+		}
+		payload.AccumulatorCoderId = n.id(payload.GetAccumulatorCoderId())
+		return n.encode(spec, &payload)
+	}
+	return spec, nil
+}
+
+func (n *namespacer) encode(spec *pipepb.FunctionSpec, payload proto.Message) (*pipepb.FunctionSpec, error) {
+	data, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &pipepb.FunctionSpec{Urn: spec.GetUrn(), Payload: data}, nil
+}
@@ -0,0 +1,163 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpcopts adds flags to tune the gRPC channels of workers to the
+// control, data, state and logging services of the runner. Large elements can
+// exceed the default message sizes, and idle streams of streaming stages can be
+// reset by proxies that don't see any traffic, which keepalive pings prevent.
+//
+// Importing the package defines the flags. The flags set when launching the
+// pipeline are passed to its workers with a gRPC hook, so they aren't
+// compatible with other gRPC hooks.
+package grpcopts
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/go/pkg/beam/util/grpcx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+)
+
+var (
+	// KeepaliveTime is the interval of keepalive pings of idle channels.
+	KeepaliveTime = flag.Duration("grpc_keepalive_time", 0, "Interval of keepalive pings on idle worker gRPC channels, such as 30s (optional).")
+	// KeepaliveTimeout is the time to wait for acknowledgements of keepalive pings.
+	KeepaliveTimeout = flag.Duration("grpc_keepalive_timeout", 0, "Time to wait for acknowledgements of keepalive pings before closing worker gRPC channels (optional).")
+	// MaxMessageSize is the maximum size of messages sent and received.
+	MaxMessageSize = flag.Int("grpc_max_message_size", 0, "Maximum size in bytes of messages sent and received on worker gRPC channels (optional).")
+	// InitialWindowSize is the initial flow control window size of streams.
+	InitialWindowSize = flag.Int("grpc_initial_window_size", 0, "Initial flow control window size in bytes of worker gRPC streams (optional).")
+	// InitialConnWindowSize is the initial flow control window size of connections.
+	InitialConnWindowSize = flag.Int("grpc_initial_conn_window_size", 0, "Initial flow control window size in bytes of worker gRPC connections (optional).")
+	// Compression is the compressor of sent messages.
+	Compression = flag.String("grpc_compression", "", "Compressor of messages sent on worker gRPC channels. Only gzip is supported (optional).")
+)
+
+const hookName = "channel_options"
+
+func init() {
+	grpcx.RegisterHook(hookName, func(args []string) grpcx.Hook {
+		opts, err := dialOptions(args)
+		if err != nil {
+			// The arguments are validated when the hook is enabled.
+			panic(err)
+		}
+		return grpcx.Hook{DialOptions: opts}
+	})
+	runtime.RegisterInit(enable)
+}
+
+// enable enables the hook with the options set by flags, if any.
+func enable() {
+	var args []string
+	add := func(key string, v interface{}) {
+		args = append(args, fmt.Sprintf("%v=%v", key, v))
+	}
+	if *KeepaliveTime != 0 {
+		add("keepalive_time", *KeepaliveTime)
+	}
+	if *KeepaliveTimeout != 0 {
+		add("keepalive_timeout", *KeepaliveTimeout)
+	}
+	if *MaxMessageSize != 0 {
+		add("max_message_size", *MaxMessageSize)
+	}
+	if *InitialWindowSize != 0 {
+		add("initial_window_size", *InitialWindowSize)
+	}
+	if *InitialConnWindowSize != 0 {
+		add("initial_conn_window_size", *InitialConnWindowSize)
+	}
+	if *Compression != "" {
+		add("compression", *Compression)
+	}
+	if len(args) == 0 {
+		return
+	}
+	if _, err := dialOptions(args); err != nil {
+		log.Exit(context.Background(), err)
+	}
+	grpcx.EnableHook(hookName, args...)
+}
+
+// dialOptions returns the dial options of the key=value arguments of the hook.
+func dialOptions(args []string) ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+	var ka keepalive.ClientParameters
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid gRPC channel option %q, want key=value", arg)
+		}
+		key, v := kv[0], kv[1]
+		var err error
+		switch key {
+		case "keepalive_time":
+			ka.Time, err = time.ParseDuration(v)
+		case "keepalive_timeout":
+			ka.Timeout, err = time.ParseDuration(v)
+		case "max_message_size":
+			var n int
+			if n, err = positive(v); err == nil {
+				opts = append(opts, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(n), grpc.MaxCallSendMsgSize(n)))
+			}
+		case "initial_window_size":
+			var n int
+			if n, err = positive(v); err == nil {
+				opts = append(opts, grpc.WithInitialWindowSize(int32(n)))
+			}
+		case "initial_conn_window_size":
+			var n int
+			if n, err = positive(v); err == nil {
+				opts = append(opts, grpc.WithInitialConnWindowSize(int32(n)))
+			}
+		case "compression":
+			if v != gzip.Name {
+				return nil, errors.Errorf("unsupported gRPC compression %q, want %q", v, gzip.Name)
+			}
+			opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+		default:
+			return nil, errors.Errorf("unknown gRPC channel option %q", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid gRPC channel option %q", arg)
+		}
+	}
+	if ka != (keepalive.ClientParameters{}) {
+		opts = append(opts, grpc.WithKeepaliveParams(ka))
+	}
+	return opts, nil
+}
+
+func positive(v string) (int, error) {
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, errors.Errorf("%v is not positive", n)
+	}
+	return int(n), nil
+}
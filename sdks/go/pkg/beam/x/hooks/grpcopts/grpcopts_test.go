@@ -0,0 +1,59 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcopts
+
+import (
+	"testing"
+)
+
+func TestDialOptions(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{args: nil, want: 0},
+		{args: []string{"keepalive_time=30s"}, want: 1},
+		{args: []string{"keepalive_time=30s", "keepalive_timeout=10s"}, want: 1},
+		{args: []string{"max_message_size=104857600", "compression=gzip"}, want: 2},
+		{args: []string{"initial_window_size=1048576", "initial_conn_window_size=4194304"}, want: 2},
+	}
+	for _, test := range tests {
+		opts, err := dialOptions(test.args)
+		if err != nil {
+			t.Errorf("dialOptions(%v) failed: %v", test.args, err)
+			continue
+		}
+		if got := len(opts); got != test.want {
+			t.Errorf("len(dialOptions(%v)) = %v, want %v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestDialOptions_Bad(t *testing.T) {
+	tests := [][]string{
+		{"keepalive_time"},
+		{"keepalive_time=often"},
+		{"max_message_size=0"},
+		{"initial_window_size=4294967296"},
+		{"compression=snappy"},
+		{"unknown=1"},
+	}
+	for _, args := range tests {
+		if opts, err := dialOptions(args); err == nil {
+			t.Errorf("dialOptions(%v) = %v, want error", args, opts)
+		}
+	}
+}
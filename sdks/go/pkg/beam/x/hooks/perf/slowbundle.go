@@ -0,0 +1,176 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package perf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/hooks"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+)
+
+// maxSlowProfile is the longest CPU profile of a slow bundle, so bundles that
+// are stuck don't profile the worker forever.
+const maxSlowProfile = time.Minute
+
+func init() {
+	hooks.RegisterHook("slow_bundle_prof", func(opts []string) hooks.Hook {
+		if len(opts) == 0 {
+			return hooks.Hook{}
+		}
+		threshold, err := time.ParseDuration(opts[0])
+		if err != nil {
+			panic(fmt.Sprintf("invalid slow bundle profiling threshold %q: %v", opts[0], err))
+		}
+		p := &slowBundleProfiler{
+			threshold: threshold,
+			capture:   opts[1:],
+			bundles:   make(map[string]*slowBundle),
+		}
+		return hooks.Hook{
+			Req: func(ctx context.Context, req *fnpb.InstructionRequest) (context.Context, error) {
+				if req.GetProcessBundle() != nil {
+					p.startBundle(ctx, req)
+				}
+				return ctx, nil
+			},
+			Resp: func(ctx context.Context, req *fnpb.InstructionRequest, _ *fnpb.InstructionResponse) error {
+				if req.GetProcessBundle() != nil {
+					p.finishBundle(ctx, req.GetInstructionId())
+				}
+				return nil
+			},
+		}
+	})
+}
+
+// EnableSlowBundleProfiling profiles the bundles running longer than the
+// threshold, instead of every bundle like the profile capture hooks. Once a
+// bundle exceeds the threshold, a heap profile is captured, and the CPU is
+// profiled until the bundle finishes. The profiles are persisted with the
+// registered profile capture hook of the given name, as "prof" and "heap"
+// traces named by the stage and instruction of the bundle. Only one bundle
+// is CPU profiled at a time.
+func EnableSlowBundleProfiling(threshold time.Duration, name string, opts ...string) {
+	if _, exists := profCaptureHookRegistry[name]; !exists {
+		panic(fmt.Sprintf("EnableSlowBundleProfiling: %s not registered", name))
+	}
+	hooks.EnableHook("slow_bundle_prof", threshold.String(), hooks.Encode(name, opts))
+}
+
+// slowBundleProfiler profiles the bundles running longer than a threshold.
+type slowBundleProfiler struct {
+	threshold time.Duration
+	capture   []string // encoded profile capture hooks
+
+	mu        sync.Mutex
+	bundles   map[string]*slowBundle // by instruction
+	profiling string                 // instruction being CPU profiled, if any
+	stop      *time.Timer            // stops the CPU profile after maxSlowProfile
+	cpu       bytes.Buffer
+}
+
+// slowBundle is a bundle being processed.
+type slowBundle struct {
+	name  string // names the profiles of the bundle
+	timer *time.Timer
+}
+
+func (p *slowBundleProfiler) startBundle(ctx context.Context, req *fnpb.InstructionRequest) {
+	inst := req.GetInstructionId()
+	b := &slowBundle{name: fmt.Sprintf("%s_%s", req.GetProcessBundle().GetProcessBundleDescriptorId(), inst)}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b.timer = time.AfterFunc(p.threshold, func() {
+		p.profile(ctx, inst)
+	})
+	p.bundles[inst] = b
+}
+
+// profile captures the heap and starts to profile the CPU for a bundle that
+// exceeded the threshold.
+func (p *slowBundleProfiler) profile(ctx context.Context, inst string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b, ok := p.bundles[inst]
+	if !ok {
+		return // The bundle finished meanwhile.
+	}
+	log.Infof(ctx, "bundle %v exceeded %v, profiling it", inst, p.threshold)
+
+	var heap bytes.Buffer
+	if err := pprof.WriteHeapProfile(&heap); err != nil {
+		log.Warnf(ctx, "failed to capture heap profile of bundle %v: %v", inst, err)
+	} else {
+		go p.persist(ctx, "heap"+b.name, heap.Bytes())
+	}
+
+	if p.profiling != "" {
+		return
+	}
+	p.cpu.Reset()
+	if err := pprof.StartCPUProfile(&p.cpu); err != nil {
+		log.Warnf(ctx, "failed to start CPU profile of bundle %v: %v", inst, err)
+		return
+	}
+	p.profiling = inst
+	p.stop = time.AfterFunc(maxSlowProfile, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.stopCPUProfile(ctx, inst, b.name)
+	})
+}
+
+func (p *slowBundleProfiler) finishBundle(ctx context.Context, inst string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b, ok := p.bundles[inst]
+	if !ok {
+		return
+	}
+	delete(p.bundles, inst)
+	b.timer.Stop()
+	p.stopCPUProfile(ctx, inst, b.name)
+}
+
+// stopCPUProfile stops the CPU profile of the bundle, if it's being profiled,
+// and persists it. Requires p.mu to be held.
+func (p *slowBundleProfiler) stopCPUProfile(ctx context.Context, inst, name string) {
+	if p.profiling != inst {
+		return
+	}
+	pprof.StopCPUProfile()
+	p.stop.Stop()
+	p.profiling = ""
+	go p.persist(ctx, "prof"+name, append([]byte(nil), p.cpu.Bytes()...))
+}
+
+// persist writes the profile with the capture hooks.
+func (p *slowBundleProfiler) persist(ctx context.Context, spec string, data []byte) {
+	for _, h := range p.capture {
+		name, opts := hooks.Decode(h)
+		if err := profCaptureHookRegistry[name](opts)(ctx, spec, bytes.NewReader(data)); err != nil {
+			log.Warnf(ctx, "failed to persist profile %v: %v", spec, err)
+		}
+	}
+}
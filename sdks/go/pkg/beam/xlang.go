@@ -16,12 +16,21 @@
 package beam
 
 import (
+	"crypto/tls"
+	"reflect"
+
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx/schema"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 )
 
+func init() {
+	RegisterType(reflect.TypeOf((*convertRowFn)(nil)).Elem())
+}
+
 // xlang exposes an API to execute cross-language transforms within the Go SDK.
 // It is experimental and likely to change. It exposes convenient wrappers
 // around the core functions to pass in any combination of named/unnamed
@@ -56,6 +65,47 @@ func CrossLanguagePayload(pl interface{}) []byte {
 	return bytes
 }
 
+// CrossLanguageSchemaTransform executes the cross-language transform of the
+// SchemaTransformProvider with the given identifier, such as
+// "beam:schematransform:org.apache.beam:kafka_read:v1", which uses named inputs
+// and returns named outputs. The transform is configured by the fields of the
+// given struct, which is encoded as a Row of the schema of its type.
+func CrossLanguageSchemaTransform(
+	s Scope,
+	identifier string,
+	config interface{},
+	expansionAddr string,
+	namedInputs map[string]PCollection,
+	namedOutputTypes map[string]FullType,
+) map[string]PCollection {
+	payload, err := xlangx.EncodeSchemaTransformPayload(identifier, config)
+	if err != nil {
+		panic(err)
+	}
+	return CrossLanguage(s, xlangx.SchemaTransformURN, payload, expansionAddr, namedInputs, namedOutputTypes)
+}
+
+// CrossLanguageSchemaTransformWithUnconsumedOutputs executes the
+// cross-language transform of the SchemaTransformProvider with the given
+// identifier, like CrossLanguageSchemaTransform, but allows the expanded
+// transform to have outputs that are not requested, which are left
+// unconsumed. It suits writes whose results, such as the files or snapshots
+// they wrote, are not needed.
+func CrossLanguageSchemaTransformWithUnconsumedOutputs(
+	s Scope,
+	identifier string,
+	config interface{},
+	expansionAddr string,
+	namedInputs map[string]PCollection,
+	namedOutputTypes map[string]FullType,
+) map[string]PCollection {
+	payload, err := xlangx.EncodeSchemaTransformPayload(identifier, config)
+	if err != nil {
+		panic(err)
+	}
+	return crossLanguage(s, xlangx.SchemaTransformURN, payload, ExpansionEndpoint{Addr: expansionAddr}, namedInputs, namedOutputTypes, true)
+}
+
 // CrossLanguage executes a cross-language transform that uses named inputs and
 // returns named outputs.
 func CrossLanguage(
@@ -65,6 +115,50 @@ func CrossLanguage(
 	expansionAddr string,
 	namedInputs map[string]PCollection,
 	namedOutputTypes map[string]FullType,
+) map[string]PCollection {
+	return CrossLanguageWithEndpoint(s, urn, payload, ExpansionEndpoint{Addr: expansionAddr}, namedInputs, namedOutputTypes)
+}
+
+// ExpansionEndpoint is the expansion service of a cross-language transform,
+// with the configuration of the connections to it. It allows transforms to use
+// centrally hosted expansion services, which require secure and authorized
+// connections.
+type ExpansionEndpoint struct {
+	// Addr is the expansion address, like those of CrossLanguage.
+	Addr string
+	// TLS, if set, secures the connections to the expansion service, which
+	// are insecure otherwise. It is ignored for managed addresses, such as
+	// those returned by xlangx.AutoJava, whose services only listen locally.
+	TLS *tls.Config
+	// Headers are sent as gRPC metadata with the requests to the expansion
+	// service, such as an "authorization" header with a bearer token.
+	Headers map[string]string
+}
+
+// CrossLanguageWithEndpoint executes a cross-language transform that uses
+// named inputs and returns named outputs, like CrossLanguage, but expanded by
+// the service of the given endpoint. The TLS configuration and headers also
+// apply to retrieving the transform's artifacts when the pipeline is
+// submitted.
+func CrossLanguageWithEndpoint(
+	s Scope,
+	urn string,
+	payload []byte,
+	endpoint ExpansionEndpoint,
+	namedInputs map[string]PCollection,
+	namedOutputTypes map[string]FullType,
+) map[string]PCollection {
+	return crossLanguage(s, urn, payload, endpoint, namedInputs, namedOutputTypes, false)
+}
+
+func crossLanguage(
+	s Scope,
+	urn string,
+	payload []byte,
+	endpoint ExpansionEndpoint,
+	namedInputs map[string]PCollection,
+	namedOutputTypes map[string]FullType,
+	allowUnconsumedOutputs bool,
 ) map[string]PCollection {
 	if !s.IsValid() {
 		panic(errors.New("invalid scope"))
@@ -74,9 +168,12 @@ func CrossLanguage(
 	outputsMap, outboundLinks := graph.NamedOutboundLinks(s.real, namedOutputTypes)
 
 	ext := graph.ExternalTransform{
-		Urn:           urn,
-		Payload:       payload,
-		ExpansionAddr: expansionAddr,
+		Urn:                    urn,
+		Payload:                payload,
+		ExpansionAddr:          endpoint.Addr,
+		ExpansionTLS:           endpoint.TLS,
+		ExpansionHeaders:       endpoint.Headers,
+		AllowUnconsumedOutputs: allowUnconsumedOutputs,
 	}.WithNamedInputs(inputsMap).WithNamedOutputs(outputsMap)
 
 	namedOutputs, err := TryCrossLanguage(s, &ext, inboundLinks, outboundLinks)
@@ -87,7 +184,17 @@ func CrossLanguage(
 }
 
 // TryCrossLanguage coordinates the core functions required to execute the cross-language transform
+//
+// Inputs encoded as Rows must have types representable by schemas. Outputs of
+// Rows whose schemas decode to other types than the requested struct types are
+// converted to them by field names, as documented by xlangx.NewRowConverter.
 func TryCrossLanguage(s Scope, ext *graph.ExternalTransform, ins []*graph.Inbound, outs []*graph.Outbound) (map[string]*graph.Node, error) {
+	for tag, i := range ext.InputsMap {
+		if err := xlangx.CheckRows(ins[i].From.Coder); err != nil {
+			return nil, errors.WithContextf(err, "checking input %v of external transform %v", tag, ext.Urn)
+		}
+	}
+
 	// Adding an edge in the graph corresponding to the ExternalTransform
 	edge, isBoundedUpdater := graph.NewCrossLanguage(s.real, s.scope, ext, ins, outs)
 
@@ -105,7 +212,61 @@ func TryCrossLanguage(s Scope, ext *graph.ExternalTransform, ins []*graph.Inboun
 	// Using the expanded outputs, the graph's counterpart outputs are updated with bounded values
 	graphx.ResolveOutputIsBounded(edge, isBoundedUpdater)
 
-	return graphx.ExternalOutputs(edge), nil
+	return convertOutputs(s, edge)
+}
+
+// convertOutputs returns the outputs of an expanded external transform, with
+// the outputs of Rows converted to the requested struct types if their schemas
+// decode to other types. Such outputs are retyped to the Go types of their
+// schemas, which is what the SDK harness decodes them to, and converted by a
+// ParDo.
+func convertOutputs(s Scope, edge *graph.MultiEdge) (map[string]*graph.Node, error) {
+	outputs := graphx.ExternalOutputs(edge)
+	schemas, err := graphx.ExpandedOutputSchemas(edge)
+	if err != nil {
+		return nil, errors.WithContext(err, "converting outputs of external transform")
+	}
+	for tag, scm := range schemas {
+		out := edge.Output[edge.External.OutputsMap[tag]]
+		want := out.To.Type().Type()
+		if want.Kind() != reflect.Struct {
+			continue
+		}
+		got, err := schema.ToType(scm)
+		if err != nil {
+			return nil, errors.WithContextf(err, "decoding schema of output %v", tag)
+		}
+		if got == want {
+			continue
+		}
+		if _, err := xlangx.NewRowConverter(got, want); err != nil {
+			return nil, errors.WithContextf(err, "converting output %v of external transform %v", tag, edge.External.Urn)
+		}
+
+		graph.SetCrossLanguageOutputType(edge, edge.External.OutputsMap[tag], typex.New(got))
+		fn := &convertRowFn{From: EncodedType{got}, To: EncodedType{want}}
+		col := ParDo(s.Scope("ConvertRows"), fn, nodeToPCollection(out.To), TypeDefinition{Var: YType, T: want})
+		outputs[tag] = col.n
+	}
+	return outputs, nil
+}
+
+// convertRowFn converts the Rows of an external transform's output to the
+// requested struct type.
+type convertRowFn struct {
+	From EncodedType `json:"from"`
+	To   EncodedType `json:"to"`
+
+	convert func(interface{}) interface{}
+}
+
+func (fn *convertRowFn) Setup() (err error) {
+	fn.convert, err = xlangx.NewRowConverter(fn.From.T, fn.To.T)
+	return err
+}
+
+func (fn *convertRowFn) ProcessElement(row X) Y {
+	return fn.convert(row)
 }
 
 // Wrapper functions to handle beam <-> graph boundaries
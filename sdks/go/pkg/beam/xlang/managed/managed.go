@@ -0,0 +1,196 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package managed contains cross-language transforms for reading and writing
+// with the managed IOs of the Java SDK, such as Iceberg, Kafka and BigQuery.
+// Managed transforms are identified by portable URNs and configured by fields
+// rather than by the API of a particular implementation, so runners may
+// upgrade or replace their implementations without changes to the pipeline.
+// This only works on runners that support cross-language transforms.
+//
+// The configuration of a transform has the fields documented for the managed
+// IO, such as:
+//
+//	orders := managed.Read(s, managed.Iceberg, map[string]interface{}{
+//	    "table": "db.orders",
+//	    "catalog_name": "local",
+//	    "catalog_properties": map[string]string{
+//	        "type":      "hadoop",
+//	        "warehouse": "gs://bucket/warehouse",
+//	    },
+//	}, reflect.TypeOf(Order{}))
+//
+// The expansion service of the IO is started from the jar released for the
+// version of the Beam SDK, unless the address of another one is given with
+// ExpansionAddr. It must support managed transforms.
+package managed
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*managedConfig)(nil)).Elem())
+}
+
+// The IOs supported by managed transforms.
+const (
+	Iceberg  = "iceberg"
+	Kafka    = "kafka"
+	BigQuery = "bigquery"
+)
+
+const (
+	// identifier is the identifier of the SchemaTransformProvider of managed
+	// transforms, which constructs the transform of the IO.
+	identifier = "beam:transform:managed:v1"
+
+	// The tags of the rows read and written by managed transforms.
+	inputTag  = "input"
+	outputTag = "output"
+
+	ioGradleTarget  = "sdks:java:io:expansion-service:shadowJar"
+	gcpGradleTarget = "sdks:java:io:google-cloud-platform:expansion-service:shadowJar"
+)
+
+// managedIO is a managed IO transform, identified by its portable URN.
+type managedIO struct {
+	urn string
+	// gradleTarget is the Gradle target of the expansion service jar used if
+	// no expansion address is given.
+	gradleTarget string
+}
+
+var (
+	sources = map[string]managedIO{
+		Iceberg:  {"beam:schematransform:org.apache.beam:iceberg_read:v1", ioGradleTarget},
+		Kafka:    {"beam:schematransform:org.apache.beam:kafka_read:v1", ioGradleTarget},
+		BigQuery: {"beam:schematransform:org.apache.beam:bigquery_storage_read:v1", gcpGradleTarget},
+	}
+	sinks = map[string]managedIO{
+		Iceberg:  {"beam:schematransform:org.apache.beam:iceberg_write:v1", ioGradleTarget},
+		Kafka:    {"beam:schematransform:org.apache.beam:kafka_write:v1", ioGradleTarget},
+		BigQuery: {"beam:schematransform:org.apache.beam:bigquery_write:v1", gcpGradleTarget},
+	}
+)
+
+// Option is an option of Read and Write.
+type Option func(*options)
+
+type options struct {
+	addr      string
+	configURL string
+}
+
+// ExpansionAddr is an option that sets the address of the expansion service of
+// the IO.
+func ExpansionAddr(addr string) Option {
+	return func(o *options) {
+		o.addr = addr
+	}
+}
+
+// ConfigURL is an option that reads the configuration of the transform from
+// the YAML file at the given URL, such as a GCS path, instead of its config
+// argument, which must be nil.
+func ConfigURL(url string) Option {
+	return func(o *options) {
+		o.configURL = url
+	}
+}
+
+// Read is a cross-language PTransform which reads the rows of the given managed
+// source, such as managed.Iceberg, configured by the given fields. It returns a
+// PCollection of the given struct type, which the rows are converted to by the
+// names of their fields.
+func Read(s beam.Scope, source string, config map[string]interface{}, outT reflect.Type, opts ...Option) beam.PCollection {
+	s = s.Scope("managed.Read")
+
+	if outT.Kind() != reflect.Struct {
+		panic("managed.Read requires a struct type for the rows.")
+	}
+	io, ok := sources[source]
+	if !ok {
+		panic(fmt.Sprintf("managed.Read has unknown source %q, want one of %q, %q or %q.", source, Iceberg, Kafka, BigQuery))
+	}
+	cfg, addr, err := newConfig(io, config, opts)
+	if err != nil {
+		panic(errors.WithContext(err, "configuring managed.Read"))
+	}
+	outs := beam.CrossLanguageSchemaTransform(s, identifier, cfg, addr, nil, map[string]beam.FullType{outputTag: typex.New(outT)})
+	return outs[outputTag]
+}
+
+// Write is a cross-language PTransform which writes the rows of a PCollection
+// of structs to the given managed sink, such as managed.BigQuery, configured
+// by the given fields. The other outputs of the sink, such as the snapshots
+// written to Iceberg, are not returned.
+func Write(s beam.Scope, sink string, config map[string]interface{}, col beam.PCollection, opts ...Option) {
+	s = s.Scope("managed.Write")
+
+	if t := col.Type().Type(); t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("managed.Write requires a PCollection of structs, got %v.", t))
+	}
+	io, ok := sinks[sink]
+	if !ok {
+		panic(fmt.Sprintf("managed.Write has unknown sink %q, want one of %q, %q or %q.", sink, Iceberg, Kafka, BigQuery))
+	}
+	cfg, addr, err := newConfig(io, config, opts)
+	if err != nil {
+		panic(errors.WithContext(err, "configuring managed.Write"))
+	}
+	beam.CrossLanguageSchemaTransformWithUnconsumedOutputs(s, identifier, cfg, addr, map[string]beam.PCollection{inputTag: col}, nil)
+}
+
+// newConfig returns the configuration of the managed transform of the given IO,
+// and the address of its expansion service.
+func newConfig(io managedIO, config map[string]interface{}, opts []Option) (managedConfig, string, error) {
+	o := &options{addr: xlangx.AutoJava(io.gradleTarget)}
+	for _, opt := range opts {
+		opt(o)
+	}
+	cfg := managedConfig{TransformIdentifier: io.urn}
+	if o.configURL != "" {
+		if config != nil {
+			return cfg, "", errors.New("the configuration must be nil with a configuration URL")
+		}
+		cfg.ConfigURL = &o.configURL
+		return cfg, o.addr, nil
+	}
+	// Configurations are YAML, of which JSON is a subset.
+	b, err := json.Marshal(config)
+	if err != nil {
+		return cfg, "", errors.Wrap(err, "encoding the configuration")
+	}
+	yaml := string(b)
+	cfg.Config = &yaml
+	return cfg, o.addr, nil
+}
+
+// managedConfig is encoded as the Row of the configuration of managed
+// transforms, and must match the fields of the configuration of the Java SDK
+// class org.apache.beam.sdk.managed.ManagedSchemaTransformProvider.
+type managedConfig struct {
+	TransformIdentifier string  `beam:"transform_identifier"`
+	Config              *string `beam:"config"`
+	ConfigURL           *string `beam:"config_url"`
+}
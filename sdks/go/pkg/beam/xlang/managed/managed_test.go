@@ -0,0 +1,98 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managed
+
+import (
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewConfig(t *testing.T) {
+	cfg, addr, err := newConfig(sinks[BigQuery], map[string]interface{}{
+		"table":                "project:dataset.table",
+		"triggering_frequency": 60,
+	}, nil)
+	if err != nil {
+		t.Fatalf("newConfig failed: %v", err)
+	}
+	yaml := `{"table":"project:dataset.table","triggering_frequency":60}`
+	want := managedConfig{
+		TransformIdentifier: "beam:schematransform:org.apache.beam:bigquery_write:v1",
+		Config:              &yaml,
+	}
+	if diff := cmp.Diff(want, cfg); diff != "" {
+		t.Errorf("newConfig mismatch (-want +got):\n%v", diff)
+	}
+	if want := xlangx.AutoJava(gcpGradleTarget); addr != want {
+		t.Errorf("newConfig address = %q, want %q", addr, want)
+	}
+	// The configuration must encode, with the configuration URL unset.
+	if _, err := xlangx.EncodeSchemaTransformPayload(identifier, cfg); err != nil {
+		t.Errorf("encoding configuration failed: %v", err)
+	}
+}
+
+func TestNewConfig_Options(t *testing.T) {
+	cfg, addr, err := newConfig(sources[Iceberg], nil, []Option{
+		ConfigURL("gs://bucket/iceberg.yaml"),
+		ExpansionAddr("localhost:8097"),
+	})
+	if err != nil {
+		t.Fatalf("newConfig failed: %v", err)
+	}
+	url := "gs://bucket/iceberg.yaml"
+	want := managedConfig{
+		TransformIdentifier: "beam:schematransform:org.apache.beam:iceberg_read:v1",
+		ConfigURL:           &url,
+	}
+	if diff := cmp.Diff(want, cfg); diff != "" {
+		t.Errorf("newConfig mismatch (-want +got):\n%v", diff)
+	}
+	if addr != "localhost:8097" {
+		t.Errorf("newConfig address = %q, want %q", addr, "localhost:8097")
+	}
+}
+
+func TestNewConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		opts   []Option
+	}{
+		{"ConfigAndURL", map[string]interface{}{"topic": "t"}, []Option{ConfigURL("gs://bucket/kafka.yaml")}},
+		{"Unencodable", map[string]interface{}{"topic": make(chan int)}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if cfg, _, err := newConfig(sources[Kafka], test.config, test.opts); err == nil {
+				t.Errorf("newConfig = %+v, want error", cfg)
+			}
+		})
+	}
+}
+
+func TestIOs(t *testing.T) {
+	for _, name := range []string{Iceberg, Kafka, BigQuery} {
+		if _, ok := sources[name]; !ok {
+			t.Errorf("managed source %q missing", name)
+		}
+		if _, ok := sinks[name]; !ok {
+			t.Errorf("managed sink %q missing", name)
+		}
+	}
+}
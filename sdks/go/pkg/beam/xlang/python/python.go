@@ -0,0 +1,135 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package python contains a cross-language transform for calling Python
+// transforms from Go pipelines, such as ones of Python-only libraries. This
+// only works on runners that support cross-language transforms.
+//
+// The Python transform is constructed by its fully qualified name, with the
+// keyword arguments given by the fields of a struct:
+//
+//	type kwargs struct {
+//	    Model string `beam:"model_uri"`
+//	}
+//
+//	preds := python.Transform(s, "mylib.transforms.Predict",
+//	    kwargs{Model: "gs://bucket/model"}, rows, reflect.TypeOf(Prediction{}),
+//	    python.Requirements("requirements.txt"))
+//
+// The Python expansion service is started in a virtual environment with Beam
+// and the packages of the requirements installed, unless the address of a
+// running one is given with ExpansionAddr. The requirements are also staged for
+// the workers running the transform.
+package python
+
+import (
+	"reflect"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/xlangx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// urn is the URN of Python transforms constructed by their fully qualified
+// name, which the Python expansion service expands.
+const urn = "beam:transforms:python:fully_qualified_named"
+
+// Option is an option of Transform.
+type Option func(*options)
+
+type options struct {
+	addr         string
+	requirements string
+}
+
+// ExpansionAddr is an option that sets the address of a running Python
+// expansion service, which must allow expanding the transform by its name.
+func ExpansionAddr(addr string) Option {
+	return func(o *options) {
+		o.addr = addr
+	}
+}
+
+// Requirements is an option that installs the packages of the given Python
+// requirements file for the transform, both for its expansion and on the
+// workers running it.
+func Requirements(file string) Option {
+	return func(o *options) {
+		o.requirements = file
+	}
+}
+
+// Transform is a cross-language PTransform which applies the Python transform
+// of the given fully qualified name, constructed with the fields of the given
+// struct as keyword arguments, to its input. It returns the output of the
+// transform, whose elements are of the given type.
+//
+// The keyword arguments must be a struct whose fields are supported by schemas,
+// and are named by their "beam" tags, or nil for none. The input may be the
+// zero PCollection for Python transforms without inputs.
+func Transform(s beam.Scope, name string, kwargs interface{}, col beam.PCollection, outT reflect.Type, opts ...Option) beam.PCollection {
+	s = s.Scope("python.Transform")
+
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	addr := o.addr
+	if addr == "" {
+		addr = xlangx.Python(o.requirements)
+	}
+
+	pl, err := encodePayload(name, kwargs)
+	if err != nil {
+		panic(err)
+	}
+	var ins map[string]beam.PCollection
+	if col.IsValid() {
+		ins = beam.UnnamedInput(col)
+	}
+	out := beam.CrossLanguage(s, urn, pl, addr, ins, beam.UnnamedOutput(typex.New(outT)))
+	return out[graph.UnnamedOutputTag]
+}
+
+// encodePayload returns the payload constructing the Python transform of the
+// given name with the given keyword arguments. Its schema matches the fields
+// read by the Python SDK's
+// apache_beam.transforms.fully_qualified_named_transform.FullyQualifiedNamedTransform.
+func encodePayload(name string, kwargs interface{}) ([]byte, error) {
+	fields := []reflect.StructField{{
+		Name: "Constructor",
+		Type: reflect.TypeOf(""),
+		Tag:  `beam:"constructor"`,
+	}}
+	if kwargs != nil {
+		kt := reflect.TypeOf(kwargs)
+		if kt.Kind() != reflect.Struct {
+			return nil, errors.Errorf("python.Transform requires a struct of keyword arguments, got %v", kt)
+		}
+		fields = append(fields, reflect.StructField{
+			Name: "Kwargs",
+			Type: kt,
+			Tag:  `beam:"kwargs"`,
+		})
+	}
+	pl := reflect.New(reflect.StructOf(fields)).Elem()
+	pl.Field(0).SetString(name)
+	if kwargs != nil {
+		pl.Field(1).Set(reflect.ValueOf(kwargs))
+	}
+	return xlangx.EncodeStructPayload(pl.Interface())
+}
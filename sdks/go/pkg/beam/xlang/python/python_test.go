@@ -0,0 +1,76 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"testing"
+
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+type kwargs struct {
+	Model     string  `beam:"model_uri"`
+	Threshold float64 `beam:"threshold"`
+}
+
+func TestEncodePayload(t *testing.T) {
+	tests := []struct {
+		name   string
+		kwargs interface{}
+		want   []string
+	}{
+		{"NoKwargs", nil, []string{"constructor"}},
+		{"Kwargs", kwargs{Model: "gs://bucket/model", Threshold: 0.5}, []string{"constructor", "kwargs"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := encodePayload("mylib.Predict", test.kwargs)
+			if err != nil {
+				t.Fatalf("encodePayload failed: %v", err)
+			}
+			var pl pipepb.ExternalConfigurationPayload
+			if err := proto.Unmarshal(b, &pl); err != nil {
+				t.Fatal(err)
+			}
+			fields := pl.GetSchema().GetFields()
+			if len(fields) != len(test.want) {
+				t.Fatalf("payload fields = %v, want %v", fields, test.want)
+			}
+			for i, f := range fields {
+				if f.GetName() != test.want[i] {
+					t.Errorf("payload field %d = %q, want %q", i, f.GetName(), test.want[i])
+				}
+			}
+			if test.kwargs == nil {
+				return
+			}
+			var names []string
+			for _, f := range fields[1].GetType().GetRowType().GetSchema().GetFields() {
+				names = append(names, f.GetName())
+			}
+			if len(names) != 2 || names[0] != "model_uri" || names[1] != "threshold" {
+				t.Errorf("kwargs fields = %v, want [model_uri threshold]", names)
+			}
+		})
+	}
+}
+
+func TestEncodePayload_NotStruct(t *testing.T) {
+	if _, err := encodePayload("mylib.Predict", map[string]string{"model_uri": "m"}); err == nil {
+		t.Error("encodePayload of map succeeded, want error")
+	}
+}
@@ -0,0 +1,146 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beam
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx/schema"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+type xlangOrder struct {
+	UserID int64
+	Total  float64
+}
+
+// registeredOrder is registered, so Rows of its schema decode to it.
+type registeredOrder struct {
+	UserID int64
+	Total  float64
+}
+
+func init() {
+	RegisterType(reflect.TypeOf((*registeredOrder)(nil)).Elem())
+}
+
+// expandedRows returns an external transform expanded with a single output of
+// Rows of the given schema.
+func expandedRows(scm *pipepb.Schema) *graph.ExternalTransform {
+	payload, err := proto.Marshal(scm)
+	if err != nil {
+		panic(err)
+	}
+	return &graph.ExternalTransform{
+		Urn: "beam:transform:test:v1",
+		Expanded: &graph.ExpandedTransform{
+			Components: &pipepb.Components{
+				Pcollections: map[string]*pipepb.PCollection{"out": {CoderId: "row"}},
+				Coders: map[string]*pipepb.Coder{"row": {
+					Spec: &pipepb.FunctionSpec{Urn: "beam:coder:row:v1", Payload: payload},
+				}},
+			},
+			Transform: &pipepb.PTransform{Outputs: map[string]string{"o": "out"}},
+		},
+	}
+}
+
+func newCrossLanguageEdge(s Scope, ext *graph.ExternalTransform, outT reflect.Type) *graph.MultiEdge {
+	outputsMap, outs := graph.NamedOutboundLinks(s.real, map[string]typex.FullType{graph.UnnamedOutputTag: typex.New(outT)})
+	ext.OutputsMap = outputsMap
+	edge, _ := graph.NewCrossLanguage(s.real, s.scope, ext, nil, outs)
+	return edge
+}
+
+func TestConvertOutputs(t *testing.T) {
+	// The Java SDK names the fields differently, and orders them otherwise.
+	type javaOrder struct {
+		Total   float64 `beam:"total"`
+		User_id int32   `beam:"user_id"`
+	}
+	scm, err := schema.FromType(reflect.TypeOf(javaOrder{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scm.Id = "java-order"
+
+	p, s := NewPipelineWithRoot()
+	edge := newCrossLanguageEdge(s, expandedRows(scm), reflect.TypeOf(xlangOrder{}))
+	outs, err := convertOutputs(s, edge)
+	if err != nil {
+		t.Fatalf("convertOutputs failed: %v", err)
+	}
+
+	want := reflect.TypeOf(xlangOrder{})
+	if got := outs[graph.UnnamedOutputTag].Type().Type(); got != want {
+		t.Errorf("converted output type = %v, want %v", got, want)
+	}
+	synth, _ := schema.ToType(scm)
+	if got := edge.Output[0].To.Type().Type(); got != synth {
+		t.Errorf("external output type = %v, want the type of its schema %v", got, synth)
+	}
+	if _, _, err := p.Build(); err != nil {
+		t.Errorf("pipeline with converted outputs is invalid: %v", err)
+	}
+
+	// The inserted ParDo converts the rows.
+	fn := &convertRowFn{From: EncodedType{synth}, To: EncodedType{want}}
+	if err := fn.Setup(); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	row := reflect.New(synth).Elem()
+	row.Field(0).SetFloat(2.5)
+	row.Field(1).SetInt(7)
+	if got, want := fn.ProcessElement(row.Interface()), (xlangOrder{UserID: 7, Total: 2.5}); got != want {
+		t.Errorf("ProcessElement = %v, want %v", got, want)
+	}
+}
+
+func TestConvertOutputs_Registered(t *testing.T) {
+	scm, err := schema.FromType(reflect.TypeOf(registeredOrder{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, s := NewPipelineWithRoot()
+	edge := newCrossLanguageEdge(s, expandedRows(scm), reflect.TypeOf(registeredOrder{}))
+	want := edge.Output[0].To
+	outs, err := convertOutputs(s, edge)
+	if err != nil {
+		t.Fatalf("convertOutputs failed: %v", err)
+	}
+	if got := outs[graph.UnnamedOutputTag]; got != want {
+		t.Errorf("output = %v, want unconverted %v", got, want)
+	}
+}
+
+func TestConvertOutputs_Unconvertible(t *testing.T) {
+	type javaCustomer struct {
+		Name string `beam:"name"`
+	}
+	scm, err := schema.FromType(reflect.TypeOf(javaCustomer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, s := NewPipelineWithRoot()
+	edge := newCrossLanguageEdge(s, expandedRows(scm), reflect.TypeOf(xlangOrder{}))
+	if _, err := convertOutputs(s, edge); err == nil {
+		t.Error("convertOutputs succeeded, want error for output without the requested fields")
+	}
+}
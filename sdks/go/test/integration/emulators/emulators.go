@@ -0,0 +1,337 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package emulators starts the emulators of GCP services for integration
+// tests, and sets up and tears down their resources, so that tests of IO
+// connectors can run hermetically.
+//
+// The client libraries of the services connect to their emulators when the
+// environment variable of the service is set to the host of its emulator,
+// such as PUBSUB_EMULATOR_HOST. Start starts an emulator with the gcloud CLI
+// and sets the variable, unless it is set already, so tests can be pointed at
+// emulators run outside of them:
+//
+//	func TestPubSubRead(t *testing.T) {
+//		emu := emulators.Start(t, emulators.PubSub)
+//		topic := emu.PubSubTopic(t, "test-project", "input")
+//		...
+//	}
+package emulators
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/apache/beam/sdks/go/pkg/beam/util/pubsubx"
+	btapb "google.golang.org/genproto/googleapis/bigtable/admin/v2"
+	"google.golang.org/genproto/googleapis/longrunning"
+	dbpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// startTimeout is how long Start waits for an emulator to accept connections.
+const startTimeout = 2 * time.Minute
+
+// Service is a GCP service with an emulator.
+type Service struct {
+	// Name is the name of the emulator in the gcloud CLI, such as "pubsub".
+	Name string
+	// EnvVar is the environment variable that points the client libraries of
+	// the service at its emulator.
+	EnvVar string
+}
+
+var (
+	// PubSub is the Pub/Sub service.
+	PubSub = Service{Name: "pubsub", EnvVar: "PUBSUB_EMULATOR_HOST"}
+	// Bigtable is the Cloud Bigtable service.
+	Bigtable = Service{Name: "bigtable", EnvVar: "BIGTABLE_EMULATOR_HOST"}
+	// Spanner is the Cloud Spanner service.
+	Spanner = Service{Name: "spanner", EnvVar: "SPANNER_EMULATOR_HOST"}
+	// Firestore is the Firestore service in native mode.
+	Firestore = Service{Name: "firestore", EnvVar: "FIRESTORE_EMULATOR_HOST"}
+	// Datastore is the Firestore service in Datastore mode.
+	Datastore = Service{Name: "datastore", EnvVar: "DATASTORE_EMULATOR_HOST"}
+)
+
+// Emulator is an emulator of a GCP service used by a test.
+type Emulator struct {
+	// Service is the emulated service.
+	Service Service
+	// Host is the host and port of the emulator.
+	Host string
+}
+
+// Start returns the emulator of the service for the test. If the environment
+// variable of the service is set, it is the host of the emulator. Otherwise,
+// Start runs the emulator with "gcloud beta emulators <name> start" on a free
+// local port, and sets the environment variable until the test ends, when the
+// emulator is stopped. Skips the test if gcloud isn't installed.
+func Start(t *testing.T, svc Service) *Emulator {
+	t.Helper()
+	if host := os.Getenv(svc.EnvVar); host != "" {
+		return &Emulator{Service: svc, Host: host}
+	}
+	gcloud, err := exec.LookPath("gcloud")
+	if err != nil {
+		t.Skipf("%v isn't set and gcloud isn't installed to start the %v emulator", svc.EnvVar, svc.Name)
+	}
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("Failed to find a free port for the %v emulator: %v", svc.Name, err)
+	}
+	host := fmt.Sprintf("localhost:%d", port)
+
+	args := []string{"beta", "emulators", svc.Name, "start", "--host-port=" + host}
+	if svc == Datastore {
+		args = append(args, "--no-store-on-disk")
+	}
+	cmd := exec.Command(gcloud, args...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start the %v emulator: %v", svc.Name, err)
+	}
+	t.Cleanup(func() {
+		stop(cmd)
+		cmd.Wait()
+	})
+	if err := waitForHost(host, startTimeout); err != nil {
+		t.Fatalf("The %v emulator didn't start: %v", svc.Name, err)
+	}
+
+	setEnv(t, svc.EnvVar, host)
+	return &Emulator{Service: svc, Host: host}
+}
+
+// setEnv sets the environment variable until the test ends.
+func setEnv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// waitForHost waits until the host accepts connections.
+func waitForHost(host string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", host, time.Second)
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// check fails the test if the emulator isn't of the service.
+func (e *Emulator) check(t *testing.T, svc Service) {
+	t.Helper()
+	if e.Service != svc {
+		t.Fatalf("The %v emulator can't set up %v resources", e.Service.Name, svc.Name)
+	}
+}
+
+// dial connects to the gRPC API of the emulator, and closes the connection
+// when the test ends.
+func (e *Emulator) dial(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.Dial(e.Host, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("Failed to connect to the %v emulator at %v: %v", e.Service.Name, e.Host, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// PubSubTopic creates the topic in the Pub/Sub emulator, if it doesn't exist,
+// and deletes it when the test ends.
+func (e *Emulator) PubSubTopic(t *testing.T, project, topic string) *pubsub.Topic {
+	t.Helper()
+	e.check(t, PubSub)
+	ctx := context.Background()
+	client, err := pubsub.NewClient(ctx, project)
+	if err != nil {
+		t.Fatalf("Failed to create Pub/Sub client: %v", err)
+	}
+	ret, err := pubsubx.EnsureTopic(ctx, client, topic)
+	if err != nil {
+		t.Fatalf("Failed to create topic %v: %v", topic, err)
+	}
+	t.Cleanup(func() {
+		if err := ret.Delete(ctx); err != nil {
+			t.Errorf("Failed to delete topic %v: %v", topic, err)
+		}
+		client.Close()
+	})
+	return ret
+}
+
+// PubSubSubscription creates the subscription to the topic in the Pub/Sub
+// emulator, if it doesn't exist, and deletes it when the test ends.
+func (e *Emulator) PubSubSubscription(t *testing.T, project, topic, subscription string) *pubsub.Subscription {
+	t.Helper()
+	e.check(t, PubSub)
+	ctx := context.Background()
+	client, err := pubsub.NewClient(ctx, project)
+	if err != nil {
+		t.Fatalf("Failed to create Pub/Sub client: %v", err)
+	}
+	ret, err := pubsubx.EnsureSubscription(ctx, client, topic, subscription)
+	if err != nil {
+		t.Fatalf("Failed to create subscription %v: %v", subscription, err)
+	}
+	t.Cleanup(func() {
+		if err := ret.Delete(ctx); err != nil {
+			t.Errorf("Failed to delete subscription %v: %v", subscription, err)
+		}
+		client.Close()
+	})
+	return ret
+}
+
+// BigtableTable creates the table with the column families in the Bigtable
+// emulator, and deletes it when the test ends.
+func (e *Emulator) BigtableTable(t *testing.T, project, instance, table string, families ...string) {
+	t.Helper()
+	e.check(t, Bigtable)
+	ctx := context.Background()
+	client := btapb.NewBigtableTableAdminClient(e.dial(t))
+
+	parent := fmt.Sprintf("projects/%v/instances/%v", project, instance)
+	tbl := &btapb.Table{ColumnFamilies: make(map[string]*btapb.ColumnFamily)}
+	for _, f := range families {
+		tbl.ColumnFamilies[f] = &btapb.ColumnFamily{}
+	}
+	if _, err := client.CreateTable(ctx, &btapb.CreateTableRequest{Parent: parent, TableId: table, Table: tbl}); err != nil {
+		t.Fatalf("Failed to create table %v: %v", table, err)
+	}
+	t.Cleanup(func() {
+		if _, err := client.DeleteTable(ctx, &btapb.DeleteTableRequest{Name: parent + "/tables/" + table}); err != nil {
+			t.Errorf("Failed to delete table %v: %v", table, err)
+		}
+	})
+}
+
+// SpannerDatabase creates the instance, if it doesn't exist, and the database
+// with the DDL statements in the Spanner emulator, and drops the database when
+// the test ends.
+func (e *Emulator) SpannerDatabase(t *testing.T, project, instance, database string, ddl ...string) {
+	t.Helper()
+	e.check(t, Spanner)
+	ctx := context.Background()
+	conn := e.dial(t)
+	ops := longrunning.NewOperationsClient(conn)
+
+	op, err := instancepb.NewInstanceAdminClient(conn).CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     "projects/" + project,
+		InstanceId: instance,
+		Instance: &instancepb.Instance{
+			Config:    fmt.Sprintf("projects/%v/instanceConfigs/emulator-config", project),
+			NodeCount: 1,
+		},
+	})
+	if status.Code(err) != codes.AlreadyExists {
+		if err := wait(ctx, ops, op, err); err != nil {
+			t.Fatalf("Failed to create instance %v: %v", instance, err)
+		}
+	}
+
+	client := dbpb.NewDatabaseAdminClient(conn)
+	parent := fmt.Sprintf("projects/%v/instances/%v", project, instance)
+	op, err = client.CreateDatabase(ctx, &dbpb.CreateDatabaseRequest{
+		Parent:          parent,
+		CreateStatement: fmt.Sprintf("CREATE DATABASE `%v`", database),
+		ExtraStatements: ddl,
+	})
+	if err := wait(ctx, ops, op, err); err != nil {
+		t.Fatalf("Failed to create database %v: %v", database, err)
+	}
+	t.Cleanup(func() {
+		if _, err := client.DropDatabase(ctx, &dbpb.DropDatabaseRequest{Database: parent + "/databases/" + database}); err != nil {
+			t.Errorf("Failed to drop database %v: %v", database, err)
+		}
+	})
+}
+
+// wait waits for the long-running operation started by a call, which returned
+// the error, to finish.
+func wait(ctx context.Context, ops longrunning.OperationsClient, op *longrunning.Operation, err error) error {
+	for err == nil && !op.GetDone() {
+		time.Sleep(100 * time.Millisecond)
+		op, err = ops.GetOperation(ctx, &longrunning.GetOperationRequest{Name: op.GetName()})
+	}
+	if err != nil {
+		return err
+	}
+	if s := op.GetError(); s != nil {
+		return status.ErrorProto(s)
+	}
+	return nil
+}
+
+// Reset deletes all the data in the Firestore or Datastore emulator, such as
+// between tests sharing an emulator.
+func (e *Emulator) Reset(t *testing.T, project string) {
+	t.Helper()
+	var req *http.Request
+	var err error
+	switch e.Service {
+	case Firestore:
+		req, err = http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%v/emulator/v1/projects/%v/databases/(default)/documents", e.Host, project), nil)
+	case Datastore:
+		req, err = http.NewRequest(http.MethodPost, fmt.Sprintf("http://%v/reset", e.Host), nil)
+	default:
+		t.Fatalf("The %v emulator can't be reset", e.Service.Name)
+	}
+	if err != nil {
+		t.Fatalf("Failed to reset the %v emulator: %v", e.Service.Name, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to reset the %v emulator: %v", e.Service.Name, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to reset the %v emulator: %v", e.Service.Name, resp.Status)
+	}
+}
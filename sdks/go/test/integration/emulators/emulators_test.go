@@ -0,0 +1,83 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emulators
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/longrunning"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStart_EnvVar(t *testing.T) {
+	svc := Service{Name: "test", EnvVar: "BEAM_TEST_EMULATOR_HOST"}
+	setEnv(t, svc.EnvVar, "localhost:1234")
+
+	emu := Start(t, svc)
+	if emu.Host != "localhost:1234" || emu.Service != svc {
+		t.Errorf("Start(%v) = %+v, want the emulator at localhost:1234", svc, emu)
+	}
+}
+
+func TestSetEnv(t *testing.T) {
+	const key = "BEAM_TEST_EMULATOR_ENV"
+	t.Run("set", func(t *testing.T) {
+		setEnv(t, key, "a")
+		if got := os.Getenv(key); got != "a" {
+			t.Errorf("%v = %q, want %q", key, got, "a")
+		}
+	})
+	if _, ok := os.LookupEnv(key); ok {
+		t.Errorf("%v is set after the test, want unset", key)
+	}
+}
+
+func TestReset(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.Path)
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	(&Emulator{Service: Firestore, Host: host}).Reset(t, "p")
+	(&Emulator{Service: Datastore, Host: host}).Reset(t, "p")
+	want := []string{"DELETE /emulator/v1/projects/p/databases/(default)/documents", "POST /reset"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Reset requests = %v, want %v", got, want)
+	}
+}
+
+func TestWait(t *testing.T) {
+	ctx := context.Background()
+	if err := wait(ctx, nil, &longrunning.Operation{Done: true}, nil); err != nil {
+		t.Errorf("wait(done) = %v, want nil", err)
+	}
+	op := &longrunning.Operation{Done: true, Result: &longrunning.Operation_Error{Error: &spb.Status{Code: int32(codes.InvalidArgument), Message: "bad DDL"}}}
+	if err := wait(ctx, nil, op, nil); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("wait(failed) = %v, want InvalidArgument", err)
+	}
+	if err := wait(ctx, nil, nil, status.Error(codes.Unavailable, "down")); status.Code(err) != codes.Unavailable {
+		t.Errorf("wait(call error) = %v, want Unavailable", err)
+	}
+}
@@ -18,13 +18,11 @@ package main
 import (
 	"context"
 	"flag"
-	"reflect"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/io/synthetic"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
-	"github.com/apache/beam/sdks/go/pkg/beam/x/beamx"
-	"github.com/apache/beam/sdks/go/test/load"
+	"github.com/apache/beam/sdks/go/test/load/loadtests"
 )
 
 var (
@@ -42,32 +40,6 @@ var (
 		"A JSON object that describes the configuration for the second synthetic source.")
 )
 
-func init() {
-	beam.RegisterType(reflect.TypeOf((*ungroupAndReiterateFn)(nil)).Elem())
-}
-
-// ungroupAndReiterateFn reiterates given number of times over CoGBK's output.
-type ungroupAndReiterateFn struct {
-	Iterations int
-}
-
-func (fn *ungroupAndReiterateFn) ProcessElement(key []byte, p1values, p2values func(*[]byte) bool, emit func([]byte, []byte)) {
-	var value []byte
-	for i := 0; i < fn.Iterations; i++ {
-		for p1values(&value) {
-			// emit output only once
-			if i == fn.Iterations-1 {
-				emit(key, value)
-			}
-		}
-		for p2values(&value) {
-			if i == fn.Iterations-1 {
-				emit(key, value)
-			}
-		}
-	}
-}
-
 func parseSyntheticConfig(config string) synthetic.SourceConfig {
 	if config == "" {
 		panic("--input_options and --co_input_options not provided")
@@ -80,27 +52,18 @@ func parseSyntheticConfig(config string) synthetic.SourceConfig {
 func main() {
 	flag.Parse()
 	beam.Init()
+
 	ctx := context.Background()
 
+	cfg := loadtests.Config{
+		Source:     parseSyntheticConfig(*syntheticConfig),
+		CoSource:   parseSyntheticConfig(*coSyntheticConfig),
+		Iterations: *iterations,
+	}
 	p, s := beam.NewPipelineWithRoot()
+	loadtests.CoGroupByKey(s, cfg)
 
-	src1 := synthetic.SourceSingle(s, parseSyntheticConfig(*syntheticConfig))
-	pc1 := beam.ParDo(s, &load.RuntimeMonitor{}, src1)
-
-	src2 := synthetic.SourceSingle(s, parseSyntheticConfig(*coSyntheticConfig))
-	pc2 := beam.ParDo(s, &load.RuntimeMonitor{}, src2)
-
-	joined := beam.CoGroupByKey(s, pc1, pc2)
-	pc := beam.ParDo(s, &ungroupAndReiterateFn{Iterations: *iterations}, joined)
-	beam.ParDo(s, &load.RuntimeMonitor{}, pc)
-
-	presult, err := beamx.RunWithMetrics(ctx, p)
-	if err != nil {
+	if _, err := loadtests.Run(ctx, p, cfg); err != nil {
 		log.Fatalf(ctx, "Failed to execute job: %v", err)
 	}
-
-	if presult != nil {
-		metrics := presult.Metrics().AllMetrics()
-		load.PublishMetrics(metrics)
-	}
 }
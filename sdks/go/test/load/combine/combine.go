@@ -16,16 +16,13 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"flag"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/io/synthetic"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
-	"github.com/apache/beam/sdks/go/pkg/beam/transforms/top"
-	"github.com/apache/beam/sdks/go/pkg/beam/x/beamx"
-	"github.com/apache/beam/sdks/go/test/load"
+	"github.com/apache/beam/sdks/go/test/load/loadtests"
 )
 
 var (
@@ -52,36 +49,17 @@ func parseSyntheticConfig() synthetic.SourceConfig {
 	}
 }
 
-func compareLess(key []byte, value []byte) bool {
-	return bytes.Compare(key, value) < 0
-}
-
-func getElement(key []byte, value [][]byte, emit func([]byte, []byte)) {
-	emit(key, value[0])
-}
-
 func main() {
 	flag.Parse()
 	beam.Init()
 
 	ctx := context.Background()
 
+	cfg := loadtests.Config{Source: parseSyntheticConfig(), Fanout: *fanout}
 	p, s := beam.NewPipelineWithRoot()
-	src := synthetic.SourceSingle(s, parseSyntheticConfig())
-	src = beam.ParDo(s, &load.RuntimeMonitor{}, src)
-	for i := 0; i < *fanout; i++ {
-		pcoll := top.LargestPerKey(s, src, *topCount, compareLess)
-		pcoll = beam.ParDo(s, getElement, pcoll)
-		pcoll = beam.ParDo(s, &load.RuntimeMonitor{}, pcoll)
-	}
+	loadtests.Combine(s, cfg, *topCount)
 
-	presult, err := beamx.RunWithMetrics(ctx, p)
-	if err != nil {
+	if _, err := loadtests.Run(ctx, p, cfg); err != nil {
 		log.Fatalf(ctx, "Failed to execute job: %v", err)
 	}
-
-	if presult != nil {
-		metrics := presult.Metrics().AllMetrics()
-		load.PublishMetrics(metrics)
-	}
 }
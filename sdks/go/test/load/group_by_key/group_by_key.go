@@ -24,8 +24,7 @@ import (
 	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/io/synthetic"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
-	"github.com/apache/beam/sdks/go/pkg/beam/x/beamx"
-	"github.com/apache/beam/sdks/go/test/load"
+	"github.com/apache/beam/sdks/go/test/load/loadtests"
 )
 
 var (
@@ -58,32 +57,11 @@ func main() {
 
 	ctx := context.Background()
 
+	cfg := loadtests.Config{Source: parseSyntheticConfig(), Fanout: *fanout, Iterations: *iterations}
 	p, s := beam.NewPipelineWithRoot()
-	src := synthetic.SourceSingle(s, parseSyntheticConfig())
-	src = beam.ParDo(s, &load.RuntimeMonitor{}, src)
-	for i := 0; i < *fanout; i++ {
-		pcoll := beam.GroupByKey(s, src)
-		pcoll = beam.ParDo(s, func(key []byte, values func(*[]byte) bool) ([]byte, []byte) {
-			for i := 0; i < *iterations; i++ {
-				var value []byte
-				for values(&value) {
-					if i == *iterations-1 {
-						return key, value
-					}
-				}
-			}
-			return key, []byte{0}
-		}, pcoll)
-		pcoll = beam.ParDo(s, &load.RuntimeMonitor{}, pcoll)
-	}
+	loadtests.GroupByKey(s, cfg)
 
-	presult, err := beamx.RunWithMetrics(ctx, p)
-	if err != nil {
+	if _, err := loadtests.Run(ctx, p, cfg); err != nil {
 		log.Fatalf(ctx, "Failed to execute job: %v", err)
 	}
-
-	if presult != nil {
-		metrics := presult.Metrics().AllMetrics()
-		load.PublishMetrics(metrics)
-	}
 }
@@ -0,0 +1,294 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loadtests contains the standard Beam load test pipelines, with
+// synthetic inputs parameterized by a Config, so that the performance of the
+// Go SDK can be tracked across releases and runners. The load test binaries
+// under test/load run these pipelines with flags.
+//
+// Each pipeline measures its runtime with load.RuntimeMonitors on its input
+// and outputs, which Run reports with its throughput.
+//
+// For example:
+//
+//	cfg := loadtests.Config{
+//		Source: synthetic.DefaultSourceConfig().NumElements(1000000).ValueSize(100).Build(),
+//		Fanout: 4,
+//	}
+//	p, s := beam.NewPipelineWithRoot()
+//	loadtests.GroupByKey(s, cfg)
+//	res, err := loadtests.Run(ctx, p, cfg)
+package loadtests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/synthetic"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/go/pkg/beam/transforms/top"
+	"github.com/apache/beam/sdks/go/pkg/beam/x/beamx"
+	"github.com/apache/beam/sdks/go/test/load"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*reiterateFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*ungroupAndReiterateFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*counterOperationFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*sideInputFn)(nil)).Elem())
+	beam.RegisterFunction(compareLess)
+	beam.RegisterFunction(getElement)
+}
+
+// Config parameterizes the load test pipelines.
+//
+// The number and size of the input elements are configured by the synthetic
+// source: its NumElements, KeySize and ValueSize. The number of keys is
+// configured by its NumHotKeys with a HotKeyFraction of 1, or by its
+// NumZipfKeys for skewed keys.
+type Config struct {
+	// Source is the configuration of the synthetic input.
+	Source synthetic.SourceConfig
+	// CoSource is the configuration of the second synthetic input of
+	// CoGroupByKey.
+	CoSource synthetic.SourceConfig
+
+	// Fanout is the number of GroupByKeys or Combines of the input that
+	// GroupByKey and Combine perform in parallel. Defaults to 1.
+	Fanout int
+	// Iterations is the number of reiterations over the grouped values that
+	// GroupByKey and CoGroupByKey perform, and the number of ParDos that ParDo
+	// applies in sequence. Defaults to 1.
+	Iterations int
+}
+
+func (c Config) fanout() int {
+	if c.Fanout < 1 {
+		return 1
+	}
+	return c.Fanout
+}
+
+func (c Config) iterations() int {
+	if c.Iterations < 1 {
+		return 1
+	}
+	return c.Iterations
+}
+
+// input returns the number of elements and bytes of the inputs.
+func (c Config) input() (elements int, size int64) {
+	for _, src := range []synthetic.SourceConfig{c.Source, c.CoSource} {
+		elements += src.NumElements
+		size += int64(src.NumElements) * int64(src.KeySize+src.ValueSize)
+	}
+	return elements, size
+}
+
+// source reads the synthetic source, monitoring its runtime.
+func source(s beam.Scope, cfg synthetic.SourceConfig) beam.PCollection {
+	src := synthetic.SourceSingle(s, cfg)
+	return beam.ParDo(s, &load.RuntimeMonitor{}, src)
+}
+
+// GroupByKey groups the input by key Fanout times in parallel, and iterates
+// over the values of each key Iterations times.
+func GroupByKey(s beam.Scope, cfg Config) {
+	s = s.Scope("loadtests.GroupByKey")
+
+	src := source(s, cfg.Source)
+	for i := 0; i < cfg.fanout(); i++ {
+		pcoll := beam.GroupByKey(s, src)
+		pcoll = beam.ParDo(s, &reiterateFn{Iterations: cfg.iterations()}, pcoll)
+		beam.ParDo(s, &load.RuntimeMonitor{}, pcoll)
+	}
+}
+
+// reiterateFn iterates the given number of times over the values of a key,
+// and emits the last value.
+type reiterateFn struct {
+	Iterations int
+}
+
+func (fn *reiterateFn) ProcessElement(key []byte, values func(*[]byte) bool) ([]byte, []byte) {
+	for i := 0; i < fn.Iterations; i++ {
+		var value []byte
+		for values(&value) {
+			if i == fn.Iterations-1 {
+				return key, value
+			}
+		}
+	}
+	return key, []byte{0}
+}
+
+// CoGroupByKey joins the input with the second input by key, and iterates
+// over the values of each key Iterations times.
+func CoGroupByKey(s beam.Scope, cfg Config) {
+	s = s.Scope("loadtests.CoGroupByKey")
+
+	pc1 := source(s, cfg.Source)
+	pc2 := source(s, cfg.CoSource)
+	joined := beam.CoGroupByKey(s, pc1, pc2)
+	pc := beam.ParDo(s, &ungroupAndReiterateFn{Iterations: cfg.iterations()}, joined)
+	beam.ParDo(s, &load.RuntimeMonitor{}, pc)
+}
+
+// ungroupAndReiterateFn reiterates given number of times over CoGBK's output.
+type ungroupAndReiterateFn struct {
+	Iterations int
+}
+
+func (fn *ungroupAndReiterateFn) ProcessElement(key []byte, p1values, p2values func(*[]byte) bool, emit func([]byte, []byte)) {
+	var value []byte
+	for i := 0; i < fn.Iterations; i++ {
+		for p1values(&value) {
+			// emit output only once
+			if i == fn.Iterations-1 {
+				emit(key, value)
+			}
+		}
+		for p2values(&value) {
+			if i == fn.Iterations-1 {
+				emit(key, value)
+			}
+		}
+	}
+}
+
+// ParDo applies Iterations ParDos in sequence to the input, each of which
+// increments the given number of counters the given number of times per
+// element.
+func ParDo(s beam.Scope, cfg Config, numCounters, operations int) {
+	s = s.Scope("loadtests.ParDo")
+
+	pcoll := source(s, cfg.Source)
+	for i := 0; i < cfg.iterations(); i++ {
+		pcoll = beam.ParDo(s, &counterOperationFn{Operations: operations, NumCounters: numCounters}, pcoll)
+	}
+	beam.ParDo(s, &load.RuntimeMonitor{}, pcoll)
+}
+
+type counterOperationFn struct {
+	Operations, NumCounters int
+	counters                []beam.Counter
+}
+
+func (fn *counterOperationFn) Setup() {
+	fn.counters = make([]beam.Counter, fn.NumCounters)
+	for i := 0; i < fn.NumCounters; i++ {
+		fn.counters[i] = beam.NewCounter("counterOperationFn", fmt.Sprint("counter-", i))
+	}
+}
+
+func (fn *counterOperationFn) ProcessElement(ctx context.Context, key []byte, value []byte, emit func([]byte, []byte)) {
+	for i := 0; i < fn.Operations; i++ {
+		for _, counter := range fn.counters {
+			counter.Inc(ctx, 1)
+		}
+	}
+	emit(key, value)
+}
+
+// Combine extracts the topCount largest values of each key of the input
+// Fanout times in parallel.
+func Combine(s beam.Scope, cfg Config, topCount int) {
+	s = s.Scope("loadtests.Combine")
+
+	src := source(s, cfg.Source)
+	for i := 0; i < cfg.fanout(); i++ {
+		pcoll := top.LargestPerKey(s, src, topCount, compareLess)
+		pcoll = beam.ParDo(s, getElement, pcoll)
+		beam.ParDo(s, &load.RuntimeMonitor{}, pcoll)
+	}
+}
+
+func compareLess(key []byte, value []byte) bool {
+	return bytes.Compare(key, value) < 0
+}
+
+func getElement(key []byte, value [][]byte, emit func([]byte, []byte)) {
+	emit(key, value[0])
+}
+
+// SideInput reads the given percentage of the input as a side input.
+func SideInput(s beam.Scope, cfg Config, accessPercentage int) {
+	s = s.Scope("loadtests.SideInput")
+
+	src := source(s, cfg.Source)
+	elementsToAccess := cfg.Source.NumElements * accessPercentage / 100
+	pcoll := beam.ParDo(s, &sideInputFn{ElementsToAccess: elementsToAccess}, beam.Impulse(s), beam.SideInput{Input: src})
+	beam.ParDo(s, &load.RuntimeMonitor{}, pcoll)
+}
+
+type sideInputFn struct {
+	ElementsToAccess int
+}
+
+func (fn *sideInputFn) ProcessElement(_ []byte, values func(*[]byte, *[]byte) bool, emit func([]byte, []byte)) {
+	var key []byte
+	var value []byte
+	i := 0
+	for values(&key, &value) {
+		if i >= fn.ElementsToAccess {
+			break
+		}
+		emit(key, value)
+		i++
+	}
+}
+
+// Result is the performance of a load test pipeline.
+type Result struct {
+	// Runtime is the time from the first bundle reading the input to the last
+	// bundle of the outputs.
+	Runtime time.Duration
+	// Throughput is the number of input elements processed per second.
+	Throughput float64
+	// BytesPerSecond is the size of the input elements processed per second.
+	BytesPerSecond float64
+}
+
+// Run runs a load test pipeline built with the given config on the runner of
+// the --runner flag, publishes its runtime and throughput to InfluxDB, if
+// configured, and returns them. Returns a nil result if the runner doesn't
+// report metrics.
+func Run(ctx context.Context, p *beam.Pipeline, cfg Config) (*Result, error) {
+	presult, err := beamx.RunWithMetrics(ctx, p)
+	if err != nil || presult == nil {
+		return nil, err
+	}
+	metrics := presult.Metrics().AllMetrics()
+	runtime, ok := load.Runtime(metrics)
+	if !ok || runtime <= 0 {
+		load.PublishMetrics(metrics)
+		return nil, nil
+	}
+	elements, size := cfg.input()
+	res := &Result{
+		Runtime:        runtime,
+		Throughput:     float64(elements) / runtime.Seconds(),
+		BytesPerSecond: float64(size) / runtime.Seconds(),
+	}
+	log.Infof(ctx, "Load test ran in %v, at %.1f elements and %.1f bytes per second", res.Runtime, res.Throughput, res.BytesPerSecond)
+	load.PublishMetrics(metrics,
+		load.Measurement{Name: "throughput", Value: res.Throughput},
+		load.Measurement{Name: "bytes_per_second", Value: res.BytesPerSecond})
+	return res, nil
+}
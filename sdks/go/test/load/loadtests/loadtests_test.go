@@ -0,0 +1,60 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/synthetic"
+)
+
+// TestPipelines runs each load test pipeline on a small input.
+func TestPipelines(t *testing.T) {
+	cfg := Config{
+		Source:     synthetic.DefaultSourceConfig().NumElements(50).NumHotKeys(5).HotKeyFraction(1).Build(),
+		CoSource:   synthetic.DefaultSourceConfig().NumElements(20).Build(),
+		Fanout:     2,
+		Iterations: 2,
+	}
+	tests := []struct {
+		name  string
+		build func(s beam.Scope)
+	}{
+		{"GroupByKey", func(s beam.Scope) { GroupByKey(s, cfg) }},
+		{"CoGroupByKey", func(s beam.Scope) { CoGroupByKey(s, cfg) }},
+		{"ParDo", func(s beam.Scope) { ParDo(s, cfg, 2, 3) }},
+		{"Combine", func(s beam.Scope) { Combine(s, cfg, 3) }},
+		{"SideInput", func(s beam.Scope) { SideInput(s, cfg, 50) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, s := beam.NewPipelineWithRoot()
+			test.build(s)
+			res, err := Run(context.Background(), p, cfg)
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if res == nil {
+				t.Fatal("Run returned no result, want the runtime of the pipeline")
+			}
+			if res.Runtime <= 0 || res.Throughput <= 0 || res.BytesPerSecond <= 0 {
+				t.Errorf("Run() = %+v, want positive runtime and throughput", res)
+			}
+		})
+	}
+}
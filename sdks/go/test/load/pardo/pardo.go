@@ -18,14 +18,11 @@ package main
 import (
 	"context"
 	"flag"
-	"fmt"
-	"reflect"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/io/synthetic"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
-	"github.com/apache/beam/sdks/go/pkg/beam/x/beamx"
-	"github.com/apache/beam/sdks/go/test/load"
+	"github.com/apache/beam/sdks/go/test/load/loadtests"
 )
 
 var (
@@ -47,35 +44,6 @@ var (
 		"A JSON object that describes the configuration for synthetic source.")
 )
 
-func init() {
-	beam.RegisterType(reflect.TypeOf((*counterOperationFn)(nil)).Elem())
-}
-
-type counterOperationFn struct {
-	Operations, NumCounters int
-	counters                []beam.Counter
-}
-
-func newCounterOperationFn(operations, numCounters int) *counterOperationFn {
-	return &counterOperationFn{operations, numCounters, nil}
-}
-
-func (fn *counterOperationFn) Setup() {
-	fn.counters = make([]beam.Counter, fn.NumCounters)
-	for i := 0; i < fn.NumCounters; i++ {
-		fn.counters[i] = beam.NewCounter("counterOperationFn", fmt.Sprint("counter-", i))
-	}
-}
-
-func (fn *counterOperationFn) ProcessElement(ctx context.Context, key []byte, value []byte, emit func([]byte, []byte)) {
-	for i := 0; i < fn.Operations; i++ {
-		for _, counter := range fn.counters {
-			counter.Inc(ctx, 1)
-		}
-	}
-	emit(key, value)
-}
-
 func parseSyntheticConfig() synthetic.SourceConfig {
 	if *syntheticConfig == "" {
 		panic("--input_options not provided")
@@ -88,25 +56,14 @@ func parseSyntheticConfig() synthetic.SourceConfig {
 func main() {
 	flag.Parse()
 	beam.Init()
+
 	ctx := context.Background()
 
+	cfg := loadtests.Config{Source: parseSyntheticConfig(), Iterations: *iterations}
 	p, s := beam.NewPipelineWithRoot()
+	loadtests.ParDo(s, cfg, *numCounters, *operations)
 
-	src := synthetic.SourceSingle(s, parseSyntheticConfig())
-
-	pcoll := beam.ParDo(s, &load.RuntimeMonitor{}, src)
-
-	for i := 0; i < *iterations; i++ {
-		pcoll = beam.ParDo(s, newCounterOperationFn(*operations, *numCounters), pcoll)
-	}
-
-	presult, err := beamx.RunWithMetrics(ctx, p)
-	if err != nil {
+	if _, err := loadtests.Run(ctx, p, cfg); err != nil {
 		log.Fatalf(ctx, "Failed to execute job: %v", err)
 	}
-
-	if presult != nil {
-		metrics := presult.Metrics().AllMetrics()
-		load.PublishMetrics(metrics)
-	}
 }
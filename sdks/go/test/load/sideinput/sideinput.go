@@ -18,19 +18,13 @@ package main
 import (
 	"context"
 	"flag"
-	"reflect"
 
 	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/io/synthetic"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
-	"github.com/apache/beam/sdks/go/pkg/beam/x/beamx"
-	"github.com/apache/beam/sdks/go/test/load"
+	"github.com/apache/beam/sdks/go/test/load/loadtests"
 )
 
-func init() {
-	beam.RegisterDoFn(reflect.TypeOf((*doFn)(nil)))
-}
-
 var (
 	accessPercentage = flag.Int(
 		"access_percentage",
@@ -51,50 +45,17 @@ func parseSyntheticConfig() synthetic.SourceConfig {
 	}
 }
 
-type doFn struct {
-	ElementsToAccess int
-}
-
-func (fn *doFn) ProcessElement(_ []byte, values func(*[]byte, *[]byte) bool, emit func([]byte, []byte)) {
-	var key []byte
-	var value []byte
-	i := 0
-	for values(&key, &value) {
-		if i >= fn.ElementsToAccess {
-			break
-		}
-		emit(key, value)
-		i++
-	}
-}
-
 func main() {
 	flag.Parse()
 	beam.Init()
-	ctx := context.Background()
-	p, s := beam.NewPipelineWithRoot()
-
-	syntheticConfig := parseSyntheticConfig()
-	elementsToAccess := syntheticConfig.NumElements * *accessPercentage / 100
 
-	src := synthetic.SourceSingle(s, syntheticConfig)
-	src = beam.ParDo(s, &load.RuntimeMonitor{}, src)
-
-	src = beam.ParDo(
-		s,
-		&doFn{ElementsToAccess: elementsToAccess},
-		beam.Impulse(s),
-		beam.SideInput{Input: src})
+	ctx := context.Background()
 
-	beam.ParDo(s, &load.RuntimeMonitor{}, src)
+	cfg := loadtests.Config{Source: parseSyntheticConfig()}
+	p, s := beam.NewPipelineWithRoot()
+	loadtests.SideInput(s, cfg, *accessPercentage)
 
-	presult, err := beamx.RunWithMetrics(ctx, p)
-	if err != nil {
+	if _, err := loadtests.Run(ctx, p, cfg); err != nil {
 		log.Fatalf(ctx, "Failed to execute job: %v", err)
 	}
-
-	if presult != nil {
-		metrics := presult.Metrics().AllMetrics()
-		load.PublishMetrics(metrics)
-	}
 }
@@ -119,41 +119,65 @@ type loadTestResult struct {
 	value     float64
 }
 
-func newLoadTestResult(value float64) loadTestResult {
+func newLoadTestResult(name string, value float64) loadTestResult {
 	metric := ""
 	if *influxNamespace == "" {
-		metric = runtimeMetricName
+		metric = name
 	} else {
-		metric = fmt.Sprintf("%v_%v", *influxNamespace, runtimeMetricName)
+		metric = fmt.Sprintf("%v_%v", *influxNamespace, name)
 	}
 	return loadTestResult{timestamp: time.Now().Unix(), metric: metric, value: value}
 }
 
-// PublishMetrics calculates the runtime and sends the result to InfluxDB database.
-func PublishMetrics(results metrics.QueryResults) {
+// Measurement is a value measured by a load test, other than its runtime,
+// such as its throughput.
+type Measurement struct {
+	Name  string
+	Value float64
+}
+
+// PublishMetrics calculates the runtime and sends the result to InfluxDB
+// database, along with the given measurements.
+func PublishMetrics(results metrics.QueryResults, measurements ...Measurement) {
 	options := newInfluxDBOptions()
 	if options.validate() {
-		if res := toLoadTestResults(results); len(res) > 0 {
-			publishMetricstoInfluxDB(options, toLoadTestResults(results))
+		res := toLoadTestResults(results)
+		for _, m := range measurements {
+			res = append(res, newLoadTestResult(m.Name, m.Value))
+		}
+		if len(res) > 0 {
+			publishMetricstoInfluxDB(options, res)
 		}
 	} else {
 		log.Print("Missing InfluxDB options. Metrics will not be published to InfluxDB")
 	}
 }
 
-func toLoadTestResults(results metrics.QueryResults) []loadTestResult {
-	res := make([]loadTestResult, 0)
-	matched := make([]metrics.DistributionResult, 0)
+// Runtime calculates the runtime of a pipeline from the distribution metric
+// updated by its RuntimeMonitors. Returns false if it has no RuntimeMonitors.
+func Runtime(results metrics.QueryResults) (time.Duration, bool) {
+	matched := runtimeDistributions(results)
+	if len(matched) == 0 {
+		return 0, false
+	}
+	return time.Duration(extractRuntimeValue(matched) * float64(time.Second)), true
+}
 
+func runtimeDistributions(results metrics.QueryResults) []metrics.DistributionResult {
+	matched := make([]metrics.DistributionResult, 0)
 	for _, dist := range results.Distributions() {
 		if dist.Key.Namespace == runtimeMetricNamespace &&
 			dist.Key.Name == runtimeMetricName {
 			matched = append(matched, dist)
 		}
 	}
+	return matched
+}
 
-	if len(matched) > 0 {
-		res = append(res, newLoadTestResult(extractRuntimeValue(matched)))
+func toLoadTestResults(results metrics.QueryResults) []loadTestResult {
+	res := make([]loadTestResult, 0)
+	if matched := runtimeDistributions(results); len(matched) > 0 {
+		res = append(res, newLoadTestResult(runtimeMetricName, extractRuntimeValue(matched)))
 	}
 	return res
 }